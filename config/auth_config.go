@@ -12,4 +12,9 @@ const (
 	AuthCookieName               string = "authCookie"
 	//
 	TimeValidVerifyTokenMinutes int = 15
+	// Plant logger request signing (HMAC)
+	LoggerSigningKeyEnv      string = "KEY_LOGGER_SIGNING"         // .env variable of the 32 byte hex key encrypting stored signing keys of plant loggers
+	LoggerSignatureMaxAgeSec int    = 5 * 60                       // Signed requests with a timestamp deviating more from server time are rejected
+	LoggerNonceLifetimeSec   int    = 2 * LoggerSignatureMaxAgeSec // Nonces are kept at least as long as a timestamp is accepted
+	LoggerNonceMaxLength     int    = 64
 )
@@ -8,6 +8,7 @@ const (
 	WriteTimeout        int = 20
 	ReadTimeout         int = 20
 	IdleTimeout         int = 60
+	ShutdownTimeout     int = 30 // Time, in seconds, to finish requests in progress on SIGINT or SIGTERM before background jobs are stopped
 	DeleteLogsAfterDays int = 5
 	// Email
 	EmailSendNotifications   bool   = false // If false, no email notifications at all (error & success)
@@ -17,12 +18,121 @@ const (
 	EmailProviderHostEnv     string = "EMAIL_PROVIDER_HOST"
 	EmailAddressSenderEnv    string = "EMAIL_ADDRESS_SENDER_BACKUP"
 	EmailAddressReceiverEnv  string = "EMAIL_ADDRESS_RECEIVER_BACKUP"
+	EmailTimeoutSec          int    = 15 // Deadline, in seconds, of sending an email
 	// AWS S3 Production config .env variable names
 	S3BucketEnv    string = "AWS_S3_BUCKET_NAME"
 	S3RegionEnv    string = "AWS_REGION"
 	S3AccessKeyEnv string = "AWS_ACCESS_KEY_ID"
 	S3SecretKeyEnv string = "AWS_SECRET_ACCESS_KEY"
+	S3TimeoutSec   int    = 30 // Deadline, in seconds, of single S3 operations, eg. uploading a file
 	// Plant config
 	PlantNameLength    int = 50
 	IntervalSecDefault int = 15 * 60 // Default interval, in seconds, for enabling data logging to the plant logger.
+	// Plant devices
+	DevicesMax          int = 200 // Maximum number of devices (inverters, strings, meters, weather stations) per plant
+	DeviceNameMaxLength int = 100
+	// Plant logger channel schema
+	ChannelsMax          int = 50 // Maximum number of channels per plant
+	ChannelNameMaxLength int = 40
+	ChannelUnitMaxLength int = 20
+	// SunSpec polling via Modbus TCP. Plants with poll targets are polled in their logging interval
+	PollTargetsMax          int    = 10 // Maximum number of polled devices per plant
+	PollTargetHostMaxLength int    = 253
+	PollerScanIntervalSec   int    = 5  // Interval, in seconds, of checking which plants are due for polling
+	PollerTimeoutSec        int    = 5  // Timeout, in seconds, of connecting to a device and of each Modbus request
+	PollerRestartDelaySec   int    = 10 // Delay, in seconds, before restarting the poller after a crash
+	PollerPlantTimeoutSec   int    = 60 // Timeout, in seconds, of reading all poll targets of a plant. At most the plant's logging interval
+	PollerConcurrency       int    = 50 // Maximum number of plants polled at the same time
+	PollerLeaseIntervals    int    = 2  // A plant is polled by the server instance holding its lease, for this number of logging intervals. Renewed with each poll, taken over once expired
+	ModbusPortDefault       int    = 502
+	SunSpecBaseAddress      uint16 = 40000 // Default start of the SunSpec register map. Alternative start addresses 50000 and 0 are tried, too
+	// Plausibility rules of measurement values, applied to the default channels if part of a reading
+	PlausibilityPowerToleranceRel     float64 = 0.05 // Relative tolerance of power output compared to voltage output x current output (P ≈ V·I)
+	PlausibilityPowerToleranceAbs     float64 = 5    // Absolute tolerance, in W, of P ≈ V·I. Covers measurement noise at low power, eg. at dawn
+	PlausibilityIrradianceMax         float64 = 1500 // Maximum solar radiation in W/m2. Minimum is 0
+	PlausibilityAmbientTemperatureMin float64 = -50
+	PlausibilityAmbientTemperatureMax float64 = 60
+	PlausibilityModuleTemperatureMin  float64 = -50
+	PlausibilityModuleTemperatureMax  float64 = 100
+	// Statistics
+	StatisticsChannelsMax            int     = 10      // Maximum number of channels analyzed per statistics request
+	StatisticsCompletenessMinPercent float64 = 80      // Statistics warn if less of the expected readings are available, see gap analysis
+	StatisticsRawMaxDays             int     = 7       // Statistics of longer periods are computed from hourly rollups
+	StatisticsHourlyMaxDays          int     = 92      // Statistics of longer periods are computed from daily rollups
+	StatisticsInDatabase             bool    = true    // Statistics of readings are aggregated by MongoDB. Computed from streamed readings if MongoDB lacks the operators, eg. '$percentile' before 7.0
+	StatisticsStreamMaxReadings      int     = 1000000 // Maximum number of readings of statistics computed from streamed readings. Their values and measurement times are held in memory
+	// Exports. CSV of hourly or daily aggregates of readings per plant or device, from rollups
+	ExportHourlyMaxDays int = 366  // Maximum period, in days, of hourly exports
+	ExportDailyMaxDays  int = 3660 // Maximum period, in days, of daily exports
+	// Rollups. Hourly and daily aggregates of readings per plant and device
+	RollupJobIntervalSec     int = 300  // Interval, in seconds, of updating rollups by new and amended readings
+	RollupReprocessWindowSec int = 3600 // Readings stored or amended this long before the previous update are rolled up again, covering inserts in progress during the previous update
+	// Data retention. Readings older than a plant's retention period are archived to the S3 bucket and deleted, rollups are kept
+	RetentionDaysMin        int    = 31        // Minimum retention period of a plant, in days. Readings may arrive this late (clock skew policy default), so they are rolled up before being archived
+	RetentionDaysMax        int    = 10 * 366  // Maximum retention period of a plant, in days
+	RetentionJobIntervalSec int    = 60 * 60   // Interval, in seconds, of archiving readings beyond the retention period
+	RetentionDaysPerRun     int    = 31        // Maximum number of days archived per plant and run. Larger backlogs are archived over several runs
+	RetentionJobLeaseSec    int    = 10 * 60   // Only the server instance holding the lease of the job archives. Renewed after each plant, taken over by another instance once expired
+	ArchiveObjectKeyPrefix  string = "archive" // Prefix of the object keys of archive files in the S3 bucket
+	RehydrationMaxDays      int    = 31        // Maximum period, in days, rehydrated per request
+	RehydrationKeepDays     int    = 7         // Rehydrated readings are deleted again after this number of days. Archive files are kept
+	// Schema migrations. Versioned index and schema changes, applied at startup or by command line
+	MigrationLockTimeoutSec int = 10 * 60 // Locks of migration runs not refreshed for this long are considered stale, eg. of a crashed server, and taken over
+	MigrationLockWaitSec    int = 60      // Time, in seconds, a server waits for a migration run of another instance before giving up
+	MigrationLockRefreshSec int = 60      // Interval, in seconds, of refreshing the lock of a migration run. Must be well below MigrationLockTimeoutSec
+	// Consistency check of plants across pv_plants, plant_logger_config and the plant logger collections, run by command line
+	ConsistencyGracePeriodSec int = 10 * 60 // Plants added more recently are skipped, as their logger collection may still be created
+	// Gap analysis. Missing readings by the plant's logging interval
+	GapToleranceFactor     float64 = 1.5     // Periods without readings longer than this multiple of the logging interval are gaps
+	GapDaylightMarginSec   int     = 1800    // Readings are expected from this time after sunrise to this time before sunset, as inverters start late and stop early
+	GapAnalysisMaxDays     int     = 366     // Maximum period of a gap analysis
+	GapAnalysisMaxReadings int     = 2000000 // Maximum number of readings of a gap analysis. Their measurement times are held in memory
+	GapsReportedMax        int     = 1000    // Maximum number of gaps listed. Further gaps are only counted
+	// Amendments of readings by plant owners (void, annotate, correct)
+	ReadingAmendmentMaxReadings   int = 10000 // Maximum number of readings amended per request
+	ReadingAmendmentTextMaxLength int = 500   // Maximum length of reason and annotation
+	// Historical data import (CSV / JSON lines)
+	ImportMaxBytes        int64 = 256 << 20 // Maximum size of an import request including the file, 256 megabytes
+	ImportFieldMaxBytes   int64 = 16 << 10  // Maximum size of each form field of an import request, eg. the column mapping
+	ImportBatchSize       int   = 1000      // Rows stored per bulk insert
+	ImportReportErrorsMax int   = 1000      // Maximum number of row errors listed in the import report. Further errors are only counted
+	ImportTimeoutSec      int   = 30 * 60   // Read and write timeout of import requests, replacing ReadTimeout and WriteTimeout of the server
+	// Rate limiting per client. Each policy grants a budget of burst requests, refilled with the given requests per second
+	RateLimitAuthPerSec      float64 = 0.2 // Auth routes, by IP. Slows down guessing passwords
+	RateLimitAuthBurst       int     = 5
+	RateLimitDashboardPerSec float64 = 5 // All other routes, by IP
+	RateLimitDashboardBurst  int     = 30
+	RateLimitIngestionPerSec float64 = 2 // Logging routes, by plant or device key. Loggers behind one IP (carrier NAT) have own budgets
+	RateLimitIngestionBurst  int     = 20
+	RateLimitKeyMaxLength    int     = 128 // Longer plant keys aren't valid. Such requests are limited by IP
+	// Logging routes, by IP. Ceiling of all loggers behind one IP, as plant keys are unverified when limiting. Prevents unlimited requests by rotating keys
+	RateLimitIngestionPerIPPerSec float64 = 50
+	RateLimitIngestionPerIPBurst  int     = 500
+	// Request bodies (JSON, CBOR, protobuf), optionally gzip-compressed. Import requests have own limits
+	RequestBodyMaxBytes             int64 = 1 << 20 // Maximum size of a request body as sent, 1 megabyte
+	RequestBodyMaxDecompressedBytes int64 = 1 << 20 // Maximum size of a gzip-compressed request body after decompression. Protects against zip bombs
+	// Asynchronous ingestion of single plant logs. Accepted readings are spooled to disk and stored in bulk
+	IngestSpoolDirEnv        string = "INGEST_SPOOL_DIR"
+	IngestSpoolDirDefault    string = "spool"
+	IngestQueueSize          int    = 10000 // Maximum number of accepted readings not yet stored. Further readings are answered with 429, or 503 while storing fails
+	IngestBatchSize          int    = 500   // Readings stored per bulk insert. A flush starts early once as many readings are queued
+	IngestFlushIntervalMs    int    = 1000  // Interval, in milliseconds, of storing queued readings
+	IngestRetryAfterSec      int    = 5     // Retry-After header of responses rejecting readings due to a full queue
+	IngestShutdownTimeoutSec int    = 10    // Time, in seconds, to store queued readings on shutdown. Remaining readings stay spooled for the next start
+	// Cache of plant logger configs by logger key, as loggers authenticate with every reading
+	LoggerConfigCacheTTLSec        int = 60    // Lifetime, in seconds, of cached configs. Changes of other server instances are picked up latest after this time without change stream
+	LoggerConfigCacheMaxEntries    int = 10000 // Further configs aren't cached until entries expire
+	LoggerConfigCacheWatchRetrySec int = 30    // Delay, in seconds, before reopening the change stream invalidating cached configs after an error
+	// Metrics (expvar) served on a separate address, disabled if not configured
+	MetricsAddrEnv string = "METRICS_ADDR" // eg. 127.0.0.1:9090
+	// Plant logger batch
+	LogBatchMaxReadings int = 500 // Maximum number of buffered readings a logger can submit with one batch request
+	// Plant logger idempotency
+	IdempotencyKeyMaxLength int = 64 // Maximum number of characters of an idempotency key provided by a logger
+	// Plant logger clock skew policy defaults. Measurement times ('measuredAt') provided by loggers deviating further from the time of receipt are rejected or flagged
+	ClockSkewMaxFutureSecDefault int    = 60                 // Tolerance, in seconds, for measurement times lying in the future due to logger clock drift
+	ClockSkewMaxPastSecDefault   int    = 30 * 24 * 60 * 60  // Maximum age, in seconds, of a measurement time. Covers buffered readings of loggers being offline
+	ClockSkewMaxFutureSecLimit   int    = 24 * 60 * 60       // Upper limit for a plant's configurable future tolerance
+	ClockSkewMaxPastSecLimit     int    = 365 * 24 * 60 * 60 // Upper limit for a plant's configurable past tolerance
+	ClockSkewActionDefault       string = "reject"
 )
@@ -9,6 +9,10 @@ const (
 	DatabaseNameFiles             string = "PlantDB"
 	DatabaseNamePlants            string = "PlantDB"
 	DatabaseNamePlantLoggerConfig string = "PlantDB"
+	DatabaseNamePlantLoggerNonce  string = "PlantDB"
+	DatabaseNamePlantDevice       string = "PlantDB"
+	DatabaseNameSchemaMigrations  string = "PlantDB"
+	DatabaseNameLease             string = "PlantDB"
 	DatabaseNamePlantLogger       string = "PlantDBLogger"
 	// Client config production
 	MongoDatabaseSchemeEnv   string = "MONGODB_SCHEME"
@@ -16,11 +20,25 @@ const (
 	MongoDatabasePasswordEnv string = "MONGODB_PASSWORD"
 	MongoDatabaseHostdEnv    string = "MONGODB_HOST"
 	MongoDatabasePortEnv     string = "MONGODB_PORT"
+	// Deadlines, in seconds, of single database operations. Requests exceeding them are answered with 504
+	DatabaseQueryTimeoutSec     int = 10 // Find, count and list operations. Applies to each batch of streamed documents
+	DatabaseWriteTimeoutSec     int = 10 // Insert, update and delete operations
+	DatabaseAggregateTimeoutSec int = 15 // Aggregations, eg. rollups. Below WriteTimeout of the server, so requests are answered
+	DatabaseAdminTimeoutSec     int = 60 // Creating and dropping collections and indexes
+	// Documents fetched per round trip when streaming readings, eg. for statistics and archive files. Only one batch is held in memory
+	DatabaseCursorBatchSize int32 = 1000
 	// Collection names
-	UserAuthCollectionName          string = "user_auth"
-	CollectionNameFiles             string = "files"
-	CollectionNamePhotovoltaicPlant string = "pv_plants"
-	CollectionNamePlantLoggerConfig string = "plant_logger_config"
+	UserAuthCollectionName             string = "user_auth"
+	CollectionNameFiles                string = "files"
+	CollectionNamePhotovoltaicPlant    string = "pv_plants"
+	CollectionNamePlantLoggerConfig    string = "plant_logger_config"
+	CollectionNamePlantLoggerNonce     string = "plant_logger_nonce"
+	CollectionNamePlantDevice          string = "plant_device"
+	CollectionNameSchemaMigrations     string = "schema_migrations"      // Applied schema migrations by version
+	CollectionNameSchemaMigrationsLock string = "schema_migrations_lock" // Lock of the migration run in process, one at a time across server instances
+	CollectionNamePlantRollupState     string = "plant_rollup_state"     // Part of DatabaseNamePlantLogger
+	CollectionNamePlantArchive         string = "plant_archive"          // Part of DatabaseNamePlantLogger. Archive files of readings beyond the retention period
+	CollectionNameLease                string = "leases"                 // Leases of background jobs run by one server instance at a time
 )
 
 // AppConfig holds the application configuration; here for the mongo connection
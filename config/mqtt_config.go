@@ -0,0 +1,19 @@
+package config
+
+// MQTT telemetry bridge configuration parameter
+
+const (
+	// .env variables. The bridge is disabled if no broker url is provided
+	MqttBrokerURLEnv    string = "MQTT_BROKER_URL" // eg. tcp://localhost:1883 or ssl://broker.example.com:8883
+	MqttTopicPatternEnv string = "MQTT_TOPIC_PATTERN"
+	MqttClientIDEnv     string = "MQTT_CLIENT_ID"
+	MqttUsernameEnv     string = "MQTT_USERNAME"
+	MqttPasswordEnv     string = "MQTT_PASSWORD"
+	// Defaults
+	MqttTopicPatternDefault     string = "plants/" + MqttTopicPlantIDPlaceholder + "/telemetry"
+	MqttClientIDDefault         string = "powerplantmanager"
+	MqttTopicPlantIDPlaceholder string = "{publicPlantID}" // Topic level holding the public plant id of the publishing logger
+	MqttQoS                     byte   = 1                 // At least once. Retries are recognized by sequence number or idempotency key
+	MqttConnectTimeoutSec       int    = 10
+	MqttKeepAliveSec            int    = 30
+)
@@ -11,6 +11,8 @@ type RegexPatterns struct {
 	URL      *regexp.Regexp
 	Email    *regexp.Regexp
 	Password *regexp.Regexp
+	Channel  *regexp.Regexp
+	Hostname *regexp.Regexp
 }
 
 var Regex RegexPatterns
@@ -22,4 +24,7 @@ func init() {
 	Regex.URL = regexp.MustCompile(`^https:\/\/(www\.)?[-a-zA-Z0-9@:%._\+~#=]{1,256}\.[a-zA-Z0-9()]{1,6}\b([-a-zA-Z0-9()!@:%_\+.~#?&\/\/=]*)$`)
 	Regex.Email = regexp.MustCompile(`^([^\x00-\x20\x22\x28\x29\x2c\x2e\x3a-\x3c\x3e\x40\x5b-\x5d\x7f-\xff]+|\x22([^\x0d\x22\x5c\x80-\xff]|\x5c[\x00-\x7f])*\x22)(\x2e([^\x00-\x20\x22\x28\x29\x2c\x2e\x3a-\x3c\x3e\x40\x5b-\x5d\x7f-\xff]+|\x22([^\x0d\x22\x5c\x80-\xff]|\x5c[\x00-\x7f])*\x22))*\x40([^\x00-\x20\x22\x28\x29\x2c\x2e\x3a-\x3c\x3e\x40\x5b-\x5d\x7f-\xff]+|\x5b([^\x0d\x5b-\x5d\x80-\xff]|\x5c[\x00-\x7f])*\x5d)(\x2e([^\x00-\x20\x22\x28\x29\x2c\x2e\x3a-\x3c\x3e\x40\x5b-\x5d\x7f-\xff]+|\x5b([^\x0d\x5b-\x5d\x80-\xff]|\x5c[\x00-\x7f])*\x5d))*$`)
 	Regex.Password = regexp.MustCompile(`^[\w\d\S]+$`) // Only alphanumerical and special chars. Lengths here not evaluated and separate in this program (see password validation in auth_validation file)
+
+	Regex.Channel = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)                                                                        // Channel names of plant loggers. Length evaluated separately
+	Regex.Hostname = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`) // Host names of polled devices. Length evaluated separately
 }
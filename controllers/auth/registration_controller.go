@@ -66,12 +66,12 @@ func Registration(emailInterface *email.RepositoryInterface, mongoDBInterface *m
 		var filter bson.M = bson.M{"email": email}
 		var sort bson.D = bson.D{}
 		var user model.UserAuth
-		foundOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(config.DatabaseNameUserAuth, filter, config.UserAuthCollectionName, sort, &user)
+		foundOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNameUserAuth, filter, config.UserAuthCollectionName, sort, &user)
 		// Handle error
 		if err != nil {
 			logger.GetLogger().Errorf("Error in 'Registration()' using 'FindOneInMongo()' when querying email address %s collection '%s' part of database '%s'. Error: %v", email, config.UserAuthCollectionName, config.DatabaseNameUserAuth, err)
 			// Neutral message
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 
@@ -83,11 +83,11 @@ func Registration(emailInterface *email.RepositoryInterface, mongoDBInterface *m
 			// ...providing no indication to potential attackers whether this email might be already taken or not.
 			// An email is send to the users inbox with a notification
 			if verified {
-				err := emailInterface.RepositoryInterface.EmailRegistrationVerifiedAccount(timeStamp, email)
+				err := emailInterface.RepositoryInterface.EmailRegistrationVerifiedAccount(r.Context(), timeStamp, email)
 				if err != nil {
 					logger.GetLogger().Error("Unable to send email in 'Registration()' using 'EmailRegistrationVerifiedAccount()'. Error: ", err)
 					// Neutral response
-					errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+					errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 					return
 				}
 				responsehandler.HandleSuccess(w, "Great, please verify your mailbox to verify your account.", responsehandler.Accepted)
@@ -100,19 +100,19 @@ func Registration(emailInterface *email.RepositoryInterface, mongoDBInterface *m
 				// Update database
 				filter := bson.M{"email": email}
 				update := bson.M{"$set": bson.M{"verify_token": verifyToken, "date_verify_token": time.Now()}}
-				_, errUpdate := mongoDBInterface.RepositoryInterface.UpdateOneInMongo(config.DatabaseNameUserAuth, filter, update, config.UserAuthCollectionName)
+				_, errUpdate := mongoDBInterface.RepositoryInterface.UpdateOneInMongo(r.Context(), config.DatabaseNameUserAuth, filter, update, config.UserAuthCollectionName)
 				if errUpdate != nil {
 					logger.GetLogger().Error("Update of verified account not possible in 'Registration()' using 'UpdateOneInMongo()': ", err)
-					errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+					errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(errUpdate, errHandler.InternalServerError))
 					return
 				}
 
 				// Send email with new token
-				err := emailInterface.RepositoryInterface.EmailNewRegistration(timeStamp, email, verifyLinkValidMinutes, encryptedVerifyToken)
+				err := emailInterface.RepositoryInterface.EmailNewRegistration(r.Context(), timeStamp, email, verifyLinkValidMinutes, encryptedVerifyToken)
 				if err != nil {
 					logger.GetLogger().Error("Unable to send email 'EmailNewRegistration()' in in 'Registration()'. Error: ", err)
 					// Neutral respond message
-					errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+					errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 					return
 				}
 
@@ -142,18 +142,18 @@ func Registration(emailInterface *email.RepositoryInterface, mongoDBInterface *m
 		}
 
 		// Save data
-		_, err = mongoDBInterface.RepositoryInterface.InsertOneToMongo(config.DatabaseNameUserAuth, dataToSave, config.UserAuthCollectionName)
+		_, err = mongoDBInterface.RepositoryInterface.InsertOneToMongo(r.Context(), config.DatabaseNameUserAuth, dataToSave, config.UserAuthCollectionName)
 		if err != nil {
 			logger.GetLogger().Error("Unable to save registration data in 'Registration'. Error: ", err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 
 		// Send email with new token
-		err = emailInterface.RepositoryInterface.EmailNewRegistration(timeStamp, email, verifyLinkValidMinutes, encryptedVerifyToken)
+		err = emailInterface.RepositoryInterface.EmailNewRegistration(r.Context(), timeStamp, email, verifyLinkValidMinutes, encryptedVerifyToken)
 		if err != nil {
 			logger.GetLogger().Error("Unable to send email in 'Registration'. Error: ", err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 
@@ -61,10 +61,10 @@ func RegistrationVerify(emailInterface *email.RepositoryInterface, mongoDBInterf
 		var filter bson.M = bson.M{"verify_token": result}
 		var user model.UserAuth
 		var sort bson.D = bson.D{}
-		foundOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(config.DatabaseNameUserAuth, filter, config.UserAuthCollectionName, sort, &user)
+		foundOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNameUserAuth, filter, config.UserAuthCollectionName, sort, &user)
 		if err != nil { // prepare error handlung
 			logger.GetLogger().Errorf("Error in 'RegistrationVerify()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s'. Error: %v", config.UserAuthCollectionName, config.DatabaseNameUserAuth, err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 		if !foundOne {
@@ -100,11 +100,11 @@ func RegistrationVerify(emailInterface *email.RepositoryInterface, mongoDBInterf
 
 		// Define an update to set a new value for a field
 		update := bson.M{"$set": bson.M{"verified": true, "password": passwordHash}, "$unset": bson.M{"verify_token": "", "date_verify_token": ""}}
-		_, err = mongoDBInterface.RepositoryInterface.UpdateOneInMongo(config.DatabaseNameUserAuth, filterUpdate, update, config.UserAuthCollectionName)
+		_, err = mongoDBInterface.RepositoryInterface.UpdateOneInMongo(r.Context(), config.DatabaseNameUserAuth, filterUpdate, update, config.UserAuthCollectionName)
 
 		if err != nil {
 			logger.GetLogger().Errorf("Update of verified account not possible in 'RegistrationVerify()' using 'UpdateOneInMongo()': %v", err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 
@@ -44,10 +44,10 @@ func Signin(emailInterface *email.RepositoryInterface, mongoDBInterface *mongodb
 		var filter bson.M = bson.M{"email": email}
 		var user model.UserAuth
 		var sort bson.D = bson.D{}
-		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(config.DatabaseNameUserAuth, filter, config.UserAuthCollectionName, sort, &user)
+		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNameUserAuth, filter, config.UserAuthCollectionName, sort, &user)
 		if err != nil {
 			logger.GetLogger().Errorf("Error in 'Signin()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s'. Error: %v", config.UserAuthCollectionName, config.DatabaseNameUserAuth, err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 		// Neutral response if email not in database
@@ -69,7 +69,7 @@ func Signin(emailInterface *email.RepositoryInterface, mongoDBInterface *mongodb
 			//
 			// For safety
 			// Inform user that somebody tried to login with non matching password
-			err = emailInterface.RepositoryInterface.EmailInformUserFailedLogin(timeNow, email)
+			err = emailInterface.RepositoryInterface.EmailInformUserFailedLogin(r.Context(), timeNow, email)
 			if err != nil {
 				logger.GetLogger().Error("Unable to send email in 'Signin()'using 'EmailInformUserFailedLogin()'. Error: ", err)
 				return
@@ -33,9 +33,9 @@ func DeleteImage(awsInterface *aws.MethodInterface, mongoDBInterface *mongodb.Me
 		/////////////////////////////////////////////////////////////////
 		// Delete file on S3
 		var keys = []string{file.Slug}
-		err := awsInterface.RepositoryInterfaceS3.DeleteObjects(os.Getenv("BUCKET_NAME"), keys) // Prepare dependency injection
+		err := awsInterface.RepositoryInterfaceS3.DeleteObjects(r.Context(), os.Getenv("BUCKET_NAME"), keys) // Prepare dependency injection
 		if err != nil {
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			logger.GetLogger().Error("Error deleting S3 image in 'DeleteImage()' using 'DeleteFileFromS3()'. Error: ", err, " Public file ID: ", file.PublicFileID)
 			return
 		}
@@ -43,10 +43,10 @@ func DeleteImage(awsInterface *aws.MethodInterface, mongoDBInterface *mongodb.Me
 		/////////////////////////////////////////////////////////////////
 		// DELETE DOCUMENT IN File collection
 		var filterDeleteDocument bson.M = bson.M{"public_file_id": file.PublicFileID}
-		_, err = mongoDBInterface.RepositoryInterface.DeleteDocumentMongo(config.DatabaseNameFiles, filterDeleteDocument, config.CollectionNameFiles)
+		_, err = mongoDBInterface.RepositoryInterface.DeleteDocumentMongo(r.Context(), config.DatabaseNameFiles, filterDeleteDocument, config.CollectionNameFiles)
 		if err != nil {
 			logger.GetLogger().Error("Unable to delete document in File collection in 'DeleteImage'. Error: ", err, " Public file ID: ", file.PublicFileID)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 
@@ -105,10 +105,10 @@ func UploadImage(awsInterface *aws.MethodInterface, mongoDBInterface *mongodb.Me
 			slug := fileFolder + "/" + f.Filename
 
 			// Upload the file to S3
-			err = awsInterface.RepositoryInterfaceS3.UploadFile(os.Getenv("BUCKET_NAME"), slug, resizedImageByteSlice)
+			err = awsInterface.RepositoryInterfaceS3.UploadFile(r.Context(), os.Getenv("BUCKET_NAME"), slug, resizedImageByteSlice)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
 				logger.GetLogger().Error("Error occurred when uploading image in 'UploadImage' using 'UploadFile()' to S3. Error: ", err, " Request: ", r)
+				errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 				return
 			}
 
@@ -138,10 +138,10 @@ func UploadImage(awsInterface *aws.MethodInterface, mongoDBInterface *mongodb.Me
 			}
 
 			// Save data
-			_, err = mongoDBInterface.RepositoryInterface.InsertOneToMongo(config.DatabaseNameFiles, fileDocument, config.CollectionNameFiles)
+			_, err = mongoDBInterface.RepositoryInterface.InsertOneToMongo(r.Context(), config.DatabaseNameFiles, fileDocument, config.CollectionNameFiles)
 			if err != nil {
 				logger.GetLogger().Errorf("Unable to save file data in 'UploadImage()'. Database name: %s. File document: %+v. Collection name: %s. Error: %v", config.DatabaseNameFiles, fileDocument, config.CollectionNameFiles, err)
-				errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+				errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 				return
 			}
 
@@ -0,0 +1,57 @@
+package plantcontroller
+
+import (
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	"github.com/paulmuenzner/powerplantmanager/utils/data"
+	"github.com/paulmuenzner/powerplantmanager/utils/date"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	stringHandler "github.com/paulmuenzner/powerplantmanager/utils/strings"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func AddDevice(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////////////
+		///////// SETUP //////////////////////////////////////
+		//
+		neutralResponseErr := "We appologize. Adding a device is currently not possible due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+		// Access the new device attached in AddDeviceValidation
+		device, ok := r.Context().Value("plantDevice").(model.PlantDevice)
+		if !ok {
+			logger.GetLogger().Error("Cannot parse and access plantDevice in 'AddDevice()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		//////////////////////////////////////////////////////
+		///////// STORE DATA  ////////////////////////////////
+		//
+		device.ID = primitive.NewObjectID()
+		device.DeviceID = stringHandler.GenerateRandomNumericString(12)
+		device.CreatedAt = date.TimeStamp()
+
+		// Validate data against mongodb plant_device model
+		if err := data.ValidateStruct(device); err != nil {
+			logger.GetLogger().Errorf("Data validation against mongodb plant_device model failed in 'AddDevice()' using 'ValidateStruct()'. Data to save: %+v. Error: %v", device, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		_, err := mongoDBInterface.RepositoryInterface.InsertOneToMongo(r.Context(), config.DatabaseNamePlantDevice, device, config.CollectionNamePlantDevice)
+		if err != nil {
+			logger.GetLogger().Errorf("Unable to save new device in 'AddDevice()' using 'InsertOneToMongo()'. Collection name: %s. Error: %v", config.CollectionNamePlantDevice, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+
+		responsehandler.HandleSuccess(w, "New device added to your plant.", responsehandler.OK, device)
+
+	}
+}
@@ -0,0 +1,172 @@
+package plantcontroller
+
+import (
+	"context"
+	"errors"
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"net/http"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func AddLogBatch(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////////////
+		///////// SETUP //////////////////////////////////////
+		//
+		neutralResponseErr := "Access currently not possible due to internal github.com/paulmuenzner/powerplantmanager update. Our technical team is informed and working on it."
+
+		// Access the validated batch attached in AddPlantLogBatchValidation
+		logBatch, ok := r.Context().Value("plantLogBatch").(loggerhandler.LogBatch)
+		if !ok {
+			logger.GetLogger().Error("Cannot parse and access plantLogBatch in 'AddLogBatch()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		// Access the plant logger collection name from the context
+		collectionName, ok := r.Context().Value("collectionNameLogger").(string)
+		if !ok {
+			logger.GetLogger().Error("Cannot parse and access collectionNameLogger in 'AddLogBatch()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		//////////////////////////////////////////////////////
+		///////// STORE DATA  ////////////////////////////////
+		//
+		// Save all accepted readings with one bulk insert
+		// Readings already stored by a previous request (retry) count as accepted without storing them again
+		rejected := logBatch.Rejected
+		duplicates := logBatch.Duplicates
+		accepted := append([]int{}, logBatch.Duplicates...)
+		if len(logBatch.Accepted) > 0 {
+			// Claim sequence numbers and idempotency keys first, time-series collections can't keep them unique.
+			// Readings claimed by a concurrent retry count as duplicates
+			plantLogs := make([]model.PlantLogger, 0, len(logBatch.Accepted))
+			for _, reading := range logBatch.Accepted {
+				plantLogs = append(plantLogs, reading.Log)
+			}
+			claimedByOther, err := loggerhandler.ClaimReadingIdentities(r.Context(), mongoDBInterface, collectionName, plantLogs)
+			if err != nil {
+				logger.GetLogger().Errorf("Unable to save plant log batch in 'AddLogBatch()' using 'ClaimReadingIdentities()'. Collection name: %s. Error: %v", collectionName, err)
+				errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+				return
+			}
+
+			storing := make([]loggerhandler.BatchReading, 0, len(logBatch.Accepted))
+			documents := make([]interface{}, 0, len(logBatch.Accepted))
+			storedAt := time.Now().UTC()
+			for position, reading := range logBatch.Accepted {
+				if claimedByOther[position] {
+					duplicates = append(duplicates, reading.Index)
+					accepted = append(accepted, reading.Index)
+					continue
+				}
+				reading.Log.StoredAt = &storedAt
+				storing = append(storing, reading)
+				documents = append(documents, reading.Log)
+			}
+
+			failed := map[int]bool{}
+			duplicateKey := map[int]bool{}
+			if len(documents) > 0 {
+				_, err = mongoDBInterface.RepositoryInterface.InsertManyToMongo(r.Context(), config.DatabaseNamePlantLogger, documents, collectionName)
+			}
+			if err != nil {
+				// Unordered bulk insert. Single failed documents are reported as rejected, anything else fails the whole batch
+				var bulkWriteException mongo.BulkWriteException
+				if !errors.As(err, &bulkWriteException) || len(bulkWriteException.WriteErrors) == 0 {
+					logger.GetLogger().Errorf("Unable to save plant log batch in 'AddLogBatch()' using 'InsertManyToMongo()'. Collection name: %s. Error: %v", collectionName, err)
+					releaseClaims(r.Context(), mongoDBInterface, collectionName, storing)
+					errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+					return
+				}
+				for _, writeError := range bulkWriteException.WriteErrors {
+					// Stored before claims existed, as found by the unique indexes of ordinary collections
+					if mongo.IsDuplicateKeyError(writeError) {
+						duplicateKey[writeError.Index] = true
+						continue
+					}
+					failed[writeError.Index] = true
+				}
+				if len(failed) > 0 {
+					logger.GetLogger().Errorf("Partially unable to save plant log batch in 'AddLogBatch()' using 'InsertManyToMongo()'. Collection name: %s. Error: %v", collectionName, err)
+				}
+			}
+
+			// Claims of readings not stored are released, so they can be retried
+			unstored := []loggerhandler.BatchReading{}
+			for position, reading := range storing {
+				if duplicateKey[position] || failed[position] {
+					unstored = append(unstored, reading)
+				}
+			}
+			releaseClaims(r.Context(), mongoDBInterface, collectionName, unstored)
+
+			for position, reading := range storing {
+				if duplicateKey[position] {
+					duplicates = append(duplicates, reading.Index)
+					accepted = append(accepted, reading.Index)
+					continue
+				}
+				if failed[position] {
+					rejected = append(rejected, loggerhandler.BatchRejection{Index: reading.Index, Reason: "Reading could not be stored."})
+					continue
+				}
+				accepted = append(accepted, reading.Index)
+			}
+		}
+
+		// Keep readings rejected for their content in the plant's quarantine collection. Failing to do so doesn't change the response
+		if err := loggerhandler.QuarantineReadings(r.Context(), mongoDBInterface, collectionName, logBatch.QuarantineEntries(time.Now())); err != nil {
+			logger.GetLogger().Errorf("Unable to quarantine rejected readings in 'AddLogBatch()' using 'QuarantineReadings()'. Collection name: %s. Error: %v", collectionName, err)
+		}
+
+		//////////////////////////////////////////////
+		// RESPONSE //////////////////////////////////
+		//
+		sort.Ints(accepted)
+		sort.Ints(duplicates)
+		sort.SliceStable(rejected, func(i, j int) bool {
+			return rejected[i].Index < rejected[j].Index
+		})
+
+		data := map[string]interface{}{
+			"acceptedCount": len(accepted),
+			"rejectedCount": len(rejected),
+			"accepted":      accepted,
+			"duplicates":    duplicates,
+			"rejected":      rejected,
+		}
+
+		// Multi-Status as soon as one reading has been rejected
+		if len(rejected) > 0 {
+			responsehandler.HandleSuccess(w, "Log batch processed. Some readings have been rejected.", responsehandler.MultiStatus, data)
+			return
+		}
+
+		responsehandler.HandleSuccess(w, "Log batch added.", responsehandler.OK, data)
+
+	}
+}
+
+// releaseClaims releases sequence numbers and idempotency keys of batch readings not stored. Failing to do so is logged,
+// retries of these readings are then answered as duplicates.
+func releaseClaims(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionName string, readings []loggerhandler.BatchReading) {
+	plantLogs := make([]model.PlantLogger, 0, len(readings))
+	for _, reading := range readings {
+		plantLogs = append(plantLogs, reading.Log)
+	}
+	if err := loggerhandler.ReleaseReadingIdentities(ctx, mongoDBInterface, collectionName, plantLogs); err != nil {
+		logger.GetLogger().Errorf("Error in 'AddLogBatch()' using 'ReleaseReadingIdentities()'. Collection name: %s. Error: %v", collectionName, err)
+	}
+}
@@ -1,87 +1,79 @@
 package plantcontroller
 
 import (
+	"errors"
+	"net/http"
+	"strconv"
+
 	config "github.com/paulmuenzner/powerplantmanager/config"
 	model "github.com/paulmuenzner/powerplantmanager/models"
 	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	ingestpipeline "github.com/paulmuenzner/powerplantmanager/services/ingestPipeline"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
 	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
-	"github.com/paulmuenzner/powerplantmanager/utils/data"
 	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
-	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
-	"net/http"
-
-	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func AddLogEntry(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+func AddLogEntry(ingestPipeline *ingestpipeline.Pipeline) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		//////////////////////////////////////////////////////
 		///////// SETUP //////////////////////////////////////
 		//
 		neutralResponseErr := "Access currently not possible due to internal github.com/paulmuenzner/powerplantmanager update. Our technical team is informed and working on it."
 
-		// Access the parsed JSON data from the context
-		dataBody, ok := r.Context().Value("requestBody").(map[string]interface{})
-		if !ok {
-			logger.GetLogger().Error("Cannot parse and access JSON of requestBody in 'AddLogEntry'.")
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		// Retry of an already stored reading detected in AddPlantLogValidation. Respond like the original request
+		if isDuplicate, _ := r.Context().Value("plantLogDuplicate").(bool); isDuplicate {
+			responsehandler.HandleSuccess(w, "New log added.", responsehandler.OK)
 			return
 		}
 
-		voltageOutput := dataBody["voltageOutput"].(float64)
-		currentOutput := dataBody["currentOutput"].(float64)
-		powerOutput := dataBody["powerOutput"].(float64)
-		solarRadiation := dataBody["solarRadiation"].(float64)
-		tAmbient := dataBody["tAmbient"].(float64)
-		tModule := dataBody["tModule"].(float64)
-		relativeHumidity := dataBody["relHumidity"].(float64)
-		windSpeed := dataBody["windSpeed"].(float64)
-
-		// Access the parsed JSON plantConfig from the context
-		plantConfig, ok := r.Context().Value("collectionNameLogger").(interface{})
+		// Access the plant logger config from the context
+		plantConfig, ok := r.Context().Value("plantLoggerConfig").(model.PlantLoggerConfig)
 		if !ok {
-			logger.GetLogger().Error("Cannot parse and access JSON of plantConfig in 'AddLogEntry'.")
+			logger.GetLogger().Error("Cannot parse and access plantLoggerConfig in 'AddLogEntry'.")
 			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
 			return
 		}
-		collectionName := plantConfig.(string)
 
 		// PLANT LOG
-		// Prepare data to save new plant document
-		objectIDPlant := primitive.NewObjectID()
-		dataToSaveNewPlantLog := model.PlantLogger{
-			ID:                 objectIDPlant,
-			VoltageOutput:      voltageOutput,
-			CurrentOutput:      currentOutput,
-			PowerOutput:        powerOutput,
-			SolarRadiation:     solarRadiation,
-			AmbientTemperature: tAmbient,
-			ModuleTemperature:  tModule,
-			RelativeHumidity:   relativeHumidity,
-			WindSpeed:          windSpeed,
-			CreatedAt:          time.Now(), // Prepare Use function
-		}
-
-		// Validate data against mongodb pv_plant_model
-		if err := data.ValidateStruct(dataToSaveNewPlantLog); err != nil {
-			logger.GetLogger().Error("Data validation against mongodb pv_plant_model failed in 'AddLogEntry'. Error: ", err)
-			// Neutral response
+		// Access log validated in AddPlantLogValidation including measurement and receipt time
+		dataToSaveNewPlantLog, ok := r.Context().Value("plantLog").(model.PlantLogger)
+		if !ok {
+			logger.GetLogger().Error("Cannot parse and access plantLog in 'AddLogEntry'.")
 			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
 			return
 		}
 
-		// Save document to PhotovoltaicPlant model
-		_, err := mongoDBInterface.RepositoryInterface.InsertOneToMongo(config.DatabaseNamePlantLogger, dataToSaveNewPlantLog, collectionName)
-		if err != nil {
-			logger.GetLogger().Error("Unable to save new plant in 'AddLogEntry'. Error: ", err)
+		// Queue log for storing in the background. It's spooled to disk first, so it's not lost once accepted
+		// Readings not yet stored are checked for retries and the logging interval, too
+		isDuplicate, err := ingestPipeline.Enqueue(plantConfig, dataToSaveNewPlantLog)
+		var rejection *loggerhandler.ReadingRejection
+		switch {
+		case errors.As(err, &rejection):
+			errHandler.HandleError(w, rejection.Reason, errHandler.BadRequest)
+			return
+		case errors.Is(err, ingestpipeline.ErrQueueFull):
+			logger.GetLogger().Warnf("Log of plant with collection %s rejected in 'AddLogEntry()' using 'Enqueue()'. Error: %v", plantConfig.CollectionNameLogger, err)
+			w.Header().Set("Retry-After", strconv.Itoa(config.IngestRetryAfterSec))
+			errHandler.HandleError(w, "Too many logs received. Please retry later.", errHandler.TooManyRequests)
+			return
+		case errors.Is(err, ingestpipeline.ErrUnavailable):
+			logger.GetLogger().Errorf("Error in 'AddLogEntry()' using 'Enqueue()' for collection %s. Error: %v", plantConfig.CollectionNameLogger, err)
+			w.Header().Set("Retry-After", strconv.Itoa(config.IngestRetryAfterSec))
+			errHandler.HandleError(w, "Logs cannot be accepted at the moment. Please retry later.", errHandler.ServiceUnavailable)
+			return
+		case err != nil:
+			logger.GetLogger().Errorf("Error in 'AddLogEntry()' using 'Enqueue()' for collection %s. Error: %v", plantConfig.CollectionNameLogger, err)
 			// Neutral response
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+		if isDuplicate {
+			responsehandler.HandleSuccess(w, "New log added.", responsehandler.OK)
 			return
 		}
 
-		responsehandler.HandleSuccess(w, "New log added.", responsehandler.OK)
+		responsehandler.HandleSuccess(w, "New log accepted.", responsehandler.Accepted)
 
 	}
 }
@@ -5,6 +5,8 @@ import (
 	config "github.com/paulmuenzner/powerplantmanager/config"
 	model "github.com/paulmuenzner/powerplantmanager/models"
 	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	planthandler "github.com/paulmuenzner/powerplantmanager/services/plantHandler"
 	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
 	cookie "github.com/paulmuenzner/powerplantmanager/utils/cookies"
 	"github.com/paulmuenzner/powerplantmanager/utils/data"
@@ -15,7 +17,6 @@ import (
 	"net/http"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func AddPlant(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
@@ -71,15 +72,7 @@ func AddPlant(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
 
 		//////////////////////////////////////////////////////
 		///////// STORE DATA  ////////////////////////////////
-		// Start a session for the transaction
-		session, err := mongoDBInterface.RepositoryInterface.StartSession()
-		if err != nil {
-			logger.GetLogger().Errorf("Unable to start session for transaction in 'AddPlant()'. Error: %v", err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
-			return
-		}
-		defer session.EndSession(context.Background())
-
+		//
 		///////// Two new entries and one new and separate collection for logging related plant //////////
 		// 1 ADD PLANT
 		// Prepare data to save new plant document
@@ -113,6 +106,9 @@ func AddPlant(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
 			URLID:                urlID,
 			CollectionNameLogger: collectionNamePlantLogger,
 			IPWhitelist:          ips,
+			Channels:             loggerhandler.DefaultChannels(),
+			AuthScheme:           model.AuthSchemeSecret,
+			ClockSkewPolicy:      loggerhandler.DefaultClockSkewPolicy(),
 			CreatedAt:            timeStamp,
 		}
 
@@ -123,58 +119,51 @@ func AddPlant(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
 			return
 		}
 
-		// 3 CREATE COLLECTION FOR LOGGER
-		// Prepare data to save new plant
-		// Set up the database and collection names
-		databaseNamePlantLogger := config.DatabaseNamePlantLogger
-
-		// Define the transaction function
-		transactionFunc := func(sessionContext mongo.SessionContext) (interface{}, error) {
+		// Both documents are saved in one transaction. Operations of unitOfWork are part of it
+		err = mongoDBInterface.RepositoryInterface.RunTransaction(r.Context(), func(ctx context.Context, unitOfWork mongodb.Repository) error {
 			/////////////////////////////////////////////////////////////////
 			// NEW PLANT
 			// Save document to PhotovoltaicPlant model
-			_, err = mongoDBInterface.RepositoryInterface.InsertOneToMongo(config.DatabaseNamePlants, dataToSaveNewPlant, config.CollectionNamePhotovoltaicPlant)
+			_, err := unitOfWork.InsertOneToMongo(ctx, config.DatabaseNamePlants, dataToSaveNewPlant, config.CollectionNamePhotovoltaicPlant)
 			if err != nil {
-				logger.GetLogger().Errorf("Unable to save new plant in 'AddPlant()' using 'InsertOneToMongo()'. Collection name: %s. Error: %v", config.CollectionNamePlantLoggerConfig, err)
-				return nil, err
+				logger.GetLogger().Errorf("Unable to save new plant in 'AddPlant()' using 'InsertOneToMongo()'. Collection name: %s. Error: %v", config.CollectionNamePhotovoltaicPlant, err)
+				return err
 			}
 
 			/////////////////////////////////////////////////////////////////
 			// NEW PLANT LOGGER CONFIG
 			// Save document to PlantLoggerConfig
-			_, err = mongoDBInterface.RepositoryInterface.InsertOneToMongo(config.DatabaseNamePlantLoggerConfig, dataToSaveNewPlantLoggerConfig, config.CollectionNamePlantLoggerConfig)
+			_, err = unitOfWork.InsertOneToMongo(ctx, config.DatabaseNamePlantLoggerConfig, dataToSaveNewPlantLoggerConfig, config.CollectionNamePlantLoggerConfig)
 			if err != nil {
 				logger.GetLogger().Errorf("Unable to save new plant logger config in 'AddPlant()' using 'InsertOneToMongo()'. Collection name: %s. Error: %v", config.CollectionNamePlantLoggerConfig, err)
-				return nil, err
-			}
-
-			/////////////////////////////////////////////////////////////////
-			// NEW PLANT LOGGER COLLECTION
-			// Create new plant logger collection including index setup
-			err = mongoDBInterface.RepositoryInterface.CreateNewCollection(databaseNamePlantLogger, collectionNamePlantLogger)
-			if err != nil {
-				logger.GetLogger().Errorf("Unable to setup new plant logger collection in 'AddPlant()' using 'CreateNewCollection()'. Error: %v", err)
-				return nil, err
+				return err
 			}
-
-			return "Transaction completed successfully", nil
-		}
-
-		// Start the transaction
-		_, err = session.WithTransaction(context.Background(), transactionFunc)
+			return nil
+		})
 		if err != nil {
-			logger.GetLogger().Error("Transaction error in 'AddPlant()' using 'WithTransaction()'. Cannot save two new documents and a new database. Error: ", err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			logger.GetLogger().Error("Transaction error in 'AddPlant()' using 'RunTransaction()'. Cannot save two new documents. Error: ", err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 
-		///////////////// INDEXES ////////////////////////////////////////////////////////////
-		// After transaction succeeded, we need to setup indexes in our new collection
-		// Create an index for public plant id in PhotovoltaicPlant model
-		err = mongoDBInterface.RepositoryInterface.CreateUniqueIndex(config.CollectionNamePhotovoltaicPlant, config.DatabaseNamePlants, "public_plant_id", true)
+		///////////////// PLANT LOGGER COLLECTION //////////////////////////////////////////////
+		// Time-series collections can't be created within transactions
+		// Readings are bucketed by measurement time and device, granularity is derived from the logging interval
+		err = planthandler.CreateCollections(r.Context(), mongoDBInterface, collectionNamePlantLogger, dataToSaveNewPlantLoggerConfig.IntervalSec)
 		if err != nil {
-			logger.GetLogger().Error("CreateUniqueIndex error in 'AddPlant()' using 'CreateUniqueIndex()'. Cannot create new index in PhotovoltaicPlant model on 'public_plant_id'. Error: ", err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			logger.GetLogger().Errorf("Unable to setup new plant logger collection in 'AddPlant()' using 'CreateCollections()'. Error: %v", err)
+			// Undo the plant. Also after the request has been canceled. Remains are repaired by the consistency check
+			undoCtx := context.WithoutCancel(r.Context())
+			errUndo := mongoDBInterface.RepositoryInterface.RunTransaction(undoCtx, func(ctx context.Context, unitOfWork mongodb.Repository) error {
+				return planthandler.DeleteDocuments(ctx, &mongodb.MethodInterface{RepositoryInterface: unitOfWork}, publicPlantID)
+			})
+			if errUndo == nil {
+				errUndo = planthandler.DeleteCollections(undoCtx, mongoDBInterface, collectionNamePlantLogger)
+			}
+			if errUndo != nil {
+				logger.GetLogger().Errorf("Unable to undo new plant '%s' in 'AddPlant()'. Run the consistency check. Error: %v", publicPlantID, errUndo)
+			}
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 
@@ -0,0 +1,59 @@
+package plantcontroller
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	cookie "github.com/paulmuenzner/powerplantmanager/utils/cookies"
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	serverConfig "github.com/paulmuenzner/powerplantmanager/utils/server"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Runs the controller against the in-memory repository, no MongoDB server needed
+func TestAddPlant(t *testing.T) {
+	secret := []byte("test-secret")
+	t.Setenv("JWT_SECRET_KEY", hex.EncodeToString(secret))
+	userID := primitive.NewObjectID()
+	token, err := cookie.CreateJWTToken(map[string]interface{}{"userId": userID.Hex()}, secret, time.Minute)
+	assert.NoError(t, err)
+
+	mongoDBInterface := memory.NewMethodInterface()
+	server := httptest.NewServer(serverConfig.BodyRequestParser(AddPlant(mongoDBInterface)))
+	defer server.Close()
+
+	request, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"name":"Rooftop"}`))
+	assert.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+	request.AddCookie(&http.Cookie{Name: config.AuthCookieName, Value: token})
+	response, err := http.DefaultClient.Do(request)
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	var plant model.PhotovoltaicPlant
+	found, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(context.Background(), config.DatabaseNamePlants, bson.M{"user_id": userID}, config.CollectionNamePhotovoltaicPlant, nil, &plant)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "Rooftop", plant.Name)
+
+	var loggerConfig model.PlantLoggerConfig
+	found, err = mongoDBInterface.RepositoryInterface.FindOneInMongo(context.Background(), config.DatabaseNamePlantLoggerConfig, bson.M{"_id": plant.ID}, config.CollectionNamePlantLoggerConfig, nil, &loggerConfig)
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	collections, err := mongoDBInterface.RepositoryInterface.ListCollections(context.Background(), config.DatabaseNamePlantLogger, bson.M{"name": loggerConfig.CollectionNameLogger})
+	assert.NoError(t, err)
+	assert.Len(t, collections, 1)
+	assert.Equal(t, "timeseries", collections[0].Type)
+}
@@ -0,0 +1,71 @@
+package plantcontroller
+
+import (
+	"fmt"
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func AmendReadings(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////////////
+		///////// SETUP //////////////////////////////////////
+		//
+		neutralResponseErr := "We appologize. Amending readings currently not possible due to github.com/paulmuenzner/powerplantmanager updates. Please, try again later."
+
+		// Access plant, config, amendment and selection of readings attached in AmendReadingsValidation
+		plant, plantOk := r.Context().Value("plantRequest").(model.PhotovoltaicPlant)
+		plantLoggerConfig, configOk := r.Context().Value("plantLoggerConfig").(model.PlantLoggerConfig)
+		amendment, amendmentOk := r.Context().Value("amendment").(loggerhandler.Amendment)
+		filter, filterOk := r.Context().Value("amendmentFilter").(bson.M)
+		if !plantOk || !configOk || !amendmentOk || !filterOk {
+			logger.GetLogger().Error("Cannot access plant, plant logger config, amendment or filter attached in 'AmendReadingsValidation()' in 'AmendReadings()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		// One reading more than amended at most is read, so large periods aren't loaded before being rejected
+		readings, err := loggerhandler.FindReadingsToAmend(r.Context(), mongoDBInterface, plantLoggerConfig.CollectionNameLogger, filter)
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'AmendReadings()' using 'FindReadingsToAmend()'. Error: %v", err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+		if _, byID := filter["_id"]; byID && len(readings) == 0 {
+			errHandler.HandleError(w, "'readingID' must be the id of a reading of this plant.", errHandler.BadRequest)
+			return
+		}
+		if len(readings) > config.ReadingAmendmentMaxReadings {
+			errHandler.HandleError(w, fmt.Sprintf("The period contains more than %d readings, the maximum amended per request.", config.ReadingAmendmentMaxReadings), errHandler.BadRequest)
+			return
+		}
+
+		// Corrected values are checked for plausibility per reading. Other errors are server errors
+		if amendment.Action == "correct" && len(readings) == 1 {
+			if _, _, err := loggerhandler.Amend(loggerhandler.StateOfReading(readings[0]), amendment); err != nil {
+				errHandler.HandleError(w, err.Error(), errHandler.BadRequest)
+				return
+			}
+		}
+
+		amended, err := loggerhandler.AmendReadings(r.Context(), mongoDBInterface, plantLoggerConfig.CollectionNameLogger, readings, amendment, plant.User, time.Now())
+		if err != nil {
+			logger.GetLogger().Error(err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+
+		data := map[string]interface{}{"selectedReadings": len(readings), "amendedReadings": amended}
+		responsehandler.HandleSuccess(w, "Readings amended.", responsehandler.OK, data)
+
+	}
+}
@@ -0,0 +1,53 @@
+package plantcontroller
+
+import (
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func DeleteDevice(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Readings of the device are kept and stay tagged with its device id, so they remain part of plant statistics
+
+		//////////////////////////////////////////////////////
+		///////// SETUP //////////////////////////////////////
+		//
+		neutralResponseErr := "We appologize. Deletion currently not possible due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+		// Access the device attached in DeleteDeviceValidation
+		device, ok := r.Context().Value("plantDevice").(model.PlantDevice)
+		if !ok {
+			logger.GetLogger().Error("Cannot parse and access plantDevice in 'DeleteDevice()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		_, err := mongoDBInterface.RepositoryInterface.DeleteDocumentMongo(r.Context(), config.DatabaseNamePlantDevice, bson.M{"_id": device.ID}, config.CollectionNamePlantDevice)
+		if err != nil {
+			logger.GetLogger().Errorf("Unable to delete device in 'DeleteDevice()' using 'DeleteDocumentMongo()'. Device id: %s. Error: %v", device.DeviceID, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+
+		// Poll targets of the device are removed, as its readings would be rejected
+		filterConfig := bson.M{"public_plant_id": device.PublicPlantID}
+		updateConfig := bson.M{"$pull": bson.M{"poll_targets": bson.M{"device_id": device.DeviceID}}}
+		_, err = mongoDBInterface.RepositoryInterface.UpdateOneInMongo(r.Context(), config.DatabaseNamePlantLoggerConfig, filterConfig, updateConfig, config.CollectionNamePlantLoggerConfig)
+		if err != nil {
+			logger.GetLogger().Errorf("Unable to remove poll targets of deleted device in 'DeleteDevice()' using 'UpdateOneInMongo()'. Device id: %s. Error: %v", device.DeviceID, err)
+		}
+		// Credentials of the deleted device must not be accepted anymore
+		loggerhandler.InvalidateLoggerConfig(device.PublicPlantID)
+
+		responsehandler.HandleSuccess(w, "Deletion accomplished.", responsehandler.OK)
+
+	}
+}
@@ -2,20 +2,21 @@ package plantcontroller
 
 import (
 	"context"
-	config "github.com/paulmuenzner/powerplantmanager/config"
+	"github.com/paulmuenzner/powerplantmanager/services/archive"
 	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	planthandler "github.com/paulmuenzner/powerplantmanager/services/plantHandler"
 	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	"github.com/paulmuenzner/powerplantmanager/utils/aws"
 	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
 	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
 	"net/http"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func DeletePlant(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+func DeletePlant(mongoDBInterface *mongodb.MethodInterface, awsInterface *aws.MethodInterface, archiveBucketName string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Deletion of plant means deleting document in PhotovoltaicPlant and PlantLoggerConfig, and deletion of PlantLoggerCollection
+		// Deletion of plant means deleting document in PhotovoltaicPlant and PlantLoggerConfig, its devices, deletion of PlantLoggerCollection and its quarantine collection,
+		// and deletion of its archive files
 
 		//////////////////////////////////////////////////////
 		///////// SETUP //////////////////////////////////////
@@ -37,56 +38,33 @@ func DeletePlant(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
 			return
 		}
 
-		// Start a session for the transaction
-		session, err := mongoDBInterface.RepositoryInterface.StartSession()
+		//////////////////////////////////////////////////////
+		///////// DELETE TRANSACTION /////////////////////////
+		//
+		// Documents in PlantLoggerConfig and PhotovoltaicPlant and the devices are deleted in one transaction. Operations of unitOfWork are part of it
+		err := mongoDBInterface.RepositoryInterface.RunTransaction(r.Context(), func(ctx context.Context, unitOfWork mongodb.Repository) error {
+			return planthandler.DeleteDocuments(ctx, &mongodb.MethodInterface{RepositoryInterface: unitOfWork}, publicPlantID)
+		})
 		if err != nil {
-			logger.GetLogger().Error("Unable to start session for transaction in 'DeletePlant()'. Error: ", err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			logger.GetLogger().Error("Transaction error occurred in 'DeletePlant()' while using 'RunTransaction()'. Unable to delete plant documents. Error: ", err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
-		defer session.EndSession(context.Background())
-
-		// Input data
-		var filterDeleteDocument bson.M = bson.M{"public_plant_id": publicPlantID}
+		// Loggers of the deleted plant and its devices must not be accepted anymore
+		loggerhandler.InvalidateLoggerConfig(publicPlantID)
 
 		//////////////////////////////////////////////////////
-		///////// DELETE TRANSACTION /////////////////////////
+		///////// DELETE COLLECTIONS /////////////////////////
 		//
-		// Define the transaction function
-		transactionFunc := func(sessionContext mongo.SessionContext) (interface{}, error) {
-			/////////////////////////////////////////////////////////////////
-			// DELETE DOCUMENT IN PlantLoggerConfig
-			_, err := mongoDBInterface.RepositoryInterface.DeleteDocumentMongo(config.DatabaseNamePlantLoggerConfig, filterDeleteDocument, config.CollectionNamePlantLoggerConfig)
-			if err != nil {
-				logger.GetLogger().Error("Unable to delete PlantLoggerConfig document in 'DeletePlant()' using 'DeleteDocumentMongo()'. Error: ", err)
-				return nil, err
-			}
-
-			/////////////////////////////////////////////////////////////////
-			// DELETE DOCUMENT IN PhotovoltaicPlant
-			_, err = mongoDBInterface.RepositoryInterface.DeleteDocumentMongo(config.DatabaseNamePlants, filterDeleteDocument, config.CollectionNamePhotovoltaicPlant)
-			if err != nil {
-				logger.GetLogger().Error("Unable to delete PhotovoltaicPlant document in 'DeletePlant()' using 'DeleteDocumentMongo()'. Error: ", err)
-				return nil, err
-			}
-
-			/////////////////////////////////////////////////////////////////
-			// DELETE LOGGER COLLECTION
-			err = mongoDBInterface.RepositoryInterface.DeleteCollectionMongo(config.DatabaseNamePlantLogger, collectionNameLogger)
-			if err != nil {
-				logger.GetLogger().Error("Unable to delete Logger Collection document in 'DeletePlant()' using 'DeleteDocumentMongo()'. Error: ", err)
-				return nil, err
-			}
-
-			return "Transaction completed successfully", nil
+		// Collections can't be dropped within transactions. The plant is deleted already, so they're dropped after the request has been canceled, too.
+		// Collections left by a failure are repaired by the consistency check
+		err = planthandler.DeleteCollections(context.WithoutCancel(r.Context()), mongoDBInterface, collectionNameLogger)
+		if err != nil {
+			logger.GetLogger().Errorf("Unable to delete collections of plant '%s' in 'DeletePlant()' using 'DeleteCollections()'. Run the consistency check. Error: %v", publicPlantID, err)
 		}
-
-		// Start the transaction
-		_, err = session.WithTransaction(context.Background(), transactionFunc)
+		_, err = archive.DeleteArchives(context.WithoutCancel(r.Context()), mongoDBInterface, awsInterface, archiveBucketName, collectionNameLogger)
 		if err != nil {
-			logger.GetLogger().Error("Transaction error occurred in 'DeletePlant()' while using 'WithTransaction()'. Unable to delete two documents from the collection. Error: ", err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
-			return
+			logger.GetLogger().Errorf("Unable to delete archive files of plant '%s' in 'DeletePlant()' using 'DeleteArchives()'. Run the consistency check. Error: %v", publicPlantID, err)
 		}
 
 		responsehandler.HandleSuccess(w, "Deletion accomplished.", responsehandler.OK)
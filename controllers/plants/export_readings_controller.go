@@ -0,0 +1,74 @@
+package plantcontroller
+
+import (
+	"fmt"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	rollup "github.com/paulmuenzner/powerplantmanager/services/rollup"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"net/http"
+	"time"
+)
+
+func ExportReadings(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////////////
+		///////// SETUP //////////////////////////////////////
+		//
+		neutralResponseErr := "We appologize. Export currently not available due to github.com/paulmuenzner/powerplantmanager updates. Please, try again later."
+
+		// Access values attached in ExportReadingsValidation
+		plantLoggerConfig, configOk := r.Context().Value("plantLoggerConfig").(model.PlantLoggerConfig)
+		resolution, resolutionOk := r.Context().Value("exportResolution").(rollup.Level)
+		dateStart, dateStartOk := r.Context().Value("exportDateStart").(time.Time)
+		dateEnd, dateEndOk := r.Context().Value("exportDateEnd").(time.Time)
+		if !configOk || !resolutionOk || !dateStartOk || !dateEndOk {
+			logger.GetLogger().Error("Cannot access plant logger config, resolution or period attached in 'ExportReadingsValidation()' in 'ExportReadings()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+		// Readings of one device only if requested. Otherwise readings of all devices are rolled up to the plant, or grouped by device if requested
+		deviceID, _ := r.Context().Value("exportDeviceID").(string)
+		groupByDevice, _ := r.Context().Value("exportGroupByDevice").(bool)
+		collectionNameLogger := plantLoggerConfig.CollectionNameLogger
+
+		//////////////////////////////////////////////
+		// AGGREGATES FROM ROLLUPS ///////////////////
+		//
+		// Exports are built from rollups, so voided readings are excluded. Plants not rolled up yet can't be exported
+		_, rolledUp, err := rollup.FindState(r.Context(), mongoDBInterface, collectionNameLogger)
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'ExportReadings()' using 'FindState()' for collection '%s'. Error: %v", collectionNameLogger, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+		if !rolledUp {
+			errHandler.HandleError(w, "Readings of this plant are not rolled up yet. Please, try again in a few minutes.", errHandler.Conflict)
+			return
+		}
+
+		// Partial hours or days at the edges of the period are rolled up from the readings
+		rollups, err := rollup.FindPeriod(r.Context(), mongoDBInterface, plantLoggerConfig, resolution, dateStart, dateEnd, deviceID)
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'ExportReadings()' using 'FindPeriod()'. Error: %v", err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+		exported := rollup.Export(rollups, resolution, groupByDevice)
+
+		// Columns of all channels of the plant's channel schema, required channels first
+		channels := loggerhandler.EffectiveChannels(plantLoggerConfig)
+		channelNames := append(loggerhandler.ChannelNames(channels, true), loggerhandler.ChannelNames(channels, false)...)
+
+		// Streamed as CSV file. Once started, failures can't be answered with an error response anymore
+		fileName := fmt.Sprintf("%s_%s_%s_%s.csv", plantLoggerConfig.PublicPlantID, resolution, dateStart.UTC().Format("20060102T150405Z"), dateEnd.UTC().Format("20060102T150405Z"))
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		if err := rollup.WriteCSV(w, exported, channelNames, groupByDevice); err != nil {
+			logger.GetLogger().Errorf("Error in 'ExportReadings()' using 'WriteCSV()' for collection '%s'. Error: %v", collectionNameLogger, err)
+		}
+
+	}
+}
@@ -0,0 +1,44 @@
+package plantcontroller
+
+import (
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/services/archive"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"net/http"
+	"time"
+)
+
+func GetArchives(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////////////
+		///////// SETUP //////////////////////////////////////
+		//
+		neutralResponseErr := "We appologize. Archives currently not available due to github.com/paulmuenzner/powerplantmanager updates. Please, try again later."
+
+		// Access config attached in GetArchivesValidation
+		plantLoggerConfig, ok := r.Context().Value("plantLoggerConfig").(model.PlantLoggerConfig)
+		if !ok {
+			logger.GetLogger().Error("Cannot access plant logger config attached in 'GetArchivesValidation()' in 'GetArchives()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		// All archive files of the plant, oldest day first
+		archives, err := archive.FindArchives(r.Context(), mongoDBInterface, plantLoggerConfig.CollectionNameLogger, time.Time{}, time.Now().Add(24*time.Hour))
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'GetArchives()' using 'FindArchives()'. Error: %v", err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+
+		data := map[string]interface{}{
+			"retentionDays": plantLoggerConfig.RetentionDays,
+			"archives":      archives,
+		}
+		responsehandler.HandleSuccess(w, "Requested archives retrieved.", responsehandler.OK, data)
+
+	}
+}
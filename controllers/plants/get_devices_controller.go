@@ -0,0 +1,44 @@
+package plantcontroller
+
+import (
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func GetDevices(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////////////
+		///////// SETUP //////////////////////////////////////
+		//
+		neutralResponseErr := "We appologize. Devices currently not available due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+		// Access the plant attached in GetDevicesValidation
+		plant, ok := r.Context().Value("plantRequest").(model.PhotovoltaicPlant)
+		if !ok {
+			logger.GetLogger().Error("Cannot parse and access plantRequest in 'GetDevices()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		// Credentials of devices are not part of the response (see json tags of model)
+		devices := []model.PlantDevice{}
+		var filter bson.M = bson.M{"public_plant_id": plant.PublicPlantID}
+		var sort bson.D = bson.D{{Key: "created_at", Value: 1}}
+		err := mongoDBInterface.RepositoryInterface.FindManyInMongo(r.Context(), config.DatabaseNamePlantDevice, filter, config.CollectionNamePlantDevice, sort, &devices)
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'GetDevices()' using 'FindManyInMongo()' in collection '%s' part of database '%s' finding devices of plant %s. Error: %v", config.CollectionNamePlantDevice, config.DatabaseNamePlantDevice, plant.PublicPlantID, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+
+		responsehandler.HandleSuccess(w, "Requested devices retrieved.", responsehandler.OK, devices)
+
+	}
+}
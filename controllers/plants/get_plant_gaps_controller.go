@@ -0,0 +1,50 @@
+package plantcontroller
+
+import (
+	"errors"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	gapanalysis "github.com/paulmuenzner/powerplantmanager/services/gapAnalysis"
+	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"net/http"
+	"time"
+)
+
+func GetPlantGaps(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////////////
+		///////// SETUP //////////////////////////////////////
+		//
+		neutralResponseErr := "We appologize. Gap analysis currently not available due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+		// Access plant, config and period attached in GetPlantGapsValidation
+		plant, plantOk := r.Context().Value("plantRequest").(model.PhotovoltaicPlant)
+		plantLoggerConfig, configOk := r.Context().Value("plantLoggerConfig").(model.PlantLoggerConfig)
+		dateStart, startOk := r.Context().Value("gapDateStart").(time.Time)
+		dateEnd, endOk := r.Context().Value("gapDateEnd").(time.Time)
+		if !plantOk || !configOk || !startOk || !endOk {
+			logger.GetLogger().Error("Cannot access plant, plant logger config or period attached in 'GetPlantGapsValidation()' in 'GetPlantGaps()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+		deviceID, _ := r.Context().Value("gapDeviceID").(string)
+
+		measuredAt, err := gapanalysis.FindMeasurementTimes(r.Context(), mongoDBInterface, plantLoggerConfig.CollectionNameLogger, deviceID, dateStart, dateEnd)
+		if errors.Is(err, gapanalysis.ErrTooManyReadings) {
+			logger.GetLogger().Warnf("Gap analysis of collection '%s' rejected in 'GetPlantGaps()' using 'FindMeasurementTimes()'. Error: %v", plantLoggerConfig.CollectionNameLogger, err)
+			errHandler.HandleError(w, "Too many readings in requested period. Please, request a shorter period.", errHandler.BadRequest)
+			return
+		}
+		if err != nil {
+			logger.GetLogger().Error(err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+
+		report := gapanalysis.Analyze(measuredAt, plantLoggerConfig.IntervalSec, plant.Coordinates, dateStart, dateEnd)
+		responsehandler.HandleSuccess(w, "Requested gap analysis retrieved.", responsehandler.OK, report)
+
+	}
+}
@@ -0,0 +1,51 @@
+package plantcontroller
+
+import (
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func GetReadingHistory(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////////////
+		///////// SETUP //////////////////////////////////////
+		//
+		neutralResponseErr := "We appologize. Reading history currently not available due to github.com/paulmuenzner/powerplantmanager updates. Please, try again later."
+
+		// Access config attached in GetReadingHistoryValidation
+		plantLoggerConfig, ok := r.Context().Value("plantLoggerConfig").(model.PlantLoggerConfig)
+		if !ok {
+			logger.GetLogger().Error("Cannot access plant logger config attached in 'GetReadingHistoryValidation()' in 'GetReadingHistory()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		// History of one reading only if requested
+		filter := bson.M{}
+		if readingID, ok := r.Context().Value("historyReadingID").(primitive.ObjectID); ok {
+			filter["reading_id"] = readingID
+		}
+
+		// Latest amendments first
+		audits := []model.PlantLogAudit{}
+		collectionNameAudit := loggerhandler.AuditCollectionName(plantLoggerConfig.CollectionNameLogger)
+		err := mongoDBInterface.RepositoryInterface.FindManyInMongo(r.Context(), config.DatabaseNamePlantLogger, filter, collectionNameAudit, bson.D{{Key: "created_at", Value: -1}}, &audits)
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'GetReadingHistory()' using 'FindManyInMongo()' in collection '%s' part of database '%s'. Error: %v", collectionNameAudit, config.DatabaseNamePlantLogger, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+
+		responsehandler.HandleSuccess(w, "Requested reading history retrieved.", responsehandler.OK, audits)
+
+	}
+}
@@ -0,0 +1,72 @@
+package plantcontroller
+
+import (
+	"errors"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	importhandler "github.com/paulmuenzner/powerplantmanager/services/importHandler"
+	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"net/http"
+	"time"
+)
+
+func ImportPlantLogs(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////////////
+		///////// SETUP //////////////////////////////////////
+		//
+		neutralResponseErr := "We appologize. Import currently not possible due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+		// Access plant logger config, import options and file rows attached in ImportPlantLogsValidation
+		plantConfig, ok := r.Context().Value("plantLoggerConfig").(model.PlantLoggerConfig)
+		if !ok {
+			logger.GetLogger().Error("Cannot parse and access plantLoggerConfig in 'ImportPlantLogs()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+		options, ok := r.Context().Value("importOptions").(importhandler.ImportOptions)
+		if !ok {
+			logger.GetLogger().Error("Cannot parse and access importOptions in 'ImportPlantLogs()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+		rows, ok := r.Context().Value("importRows").(importhandler.RowReader)
+		if !ok {
+			logger.GetLogger().Error("Cannot parse and access importRows in 'ImportPlantLogs()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		//////////////////////////////////////////////////////
+		///////// STORE DATA  ////////////////////////////////
+		//
+		// Rows are stored in bulk while the file is read. An aborted import keeps the rows stored so far and can be repeated, as stored rows are skipped
+		report, err := importhandler.Import(r.Context(), mongoDBInterface, plantConfig, options, rows, time.Now())
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				responsehandler.HandleSuccess(w, "Import file is too large. Rows read so far have been processed, see report.", responsehandler.MultiStatus, map[string]interface{}{"report": report})
+				return
+			}
+			logger.GetLogger().Errorf("Import of plant logs aborted in 'ImportPlantLogs()' using 'Import()' for plant '%s'. Rows read: %d. Error: %v", plantConfig.PublicPlantID, report.RowsRead, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+
+		//////////////////////////////////////////////
+		// RESPONSE //////////////////////////////////
+		//
+		data := map[string]interface{}{"report": report}
+
+		// Multi-Status as soon as one row has been rejected
+		if report.RowsRejected > 0 {
+			responsehandler.HandleSuccess(w, "Import processed. Some rows have been rejected.", responsehandler.MultiStatus, data)
+			return
+		}
+
+		responsehandler.HandleSuccess(w, "Import accomplished.", responsehandler.OK, data)
+
+	}
+}
@@ -0,0 +1,42 @@
+package plantcontroller
+
+import (
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/services/archive"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	"github.com/paulmuenzner/powerplantmanager/utils/aws"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"net/http"
+	"time"
+)
+
+func RehydrateArchive(mongoDBInterface *mongodb.MethodInterface, awsInterface *aws.MethodInterface, bucketName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////////////
+		///////// SETUP //////////////////////////////////////
+		//
+		neutralResponseErr := "We appologize. Rehydrating archives currently not possible due to github.com/paulmuenzner/powerplantmanager updates. Please, try again later."
+
+		// Access config and period attached in RehydrateArchiveValidation
+		plantLoggerConfig, configOk := r.Context().Value("plantLoggerConfig").(model.PlantLoggerConfig)
+		dateStart, startOk := r.Context().Value("rehydrationStart").(time.Time)
+		dateEnd, endOk := r.Context().Value("rehydrationEnd").(time.Time)
+		if !configOk || !startOk || !endOk {
+			logger.GetLogger().Error("Cannot access plant logger config or period attached in 'RehydrateArchiveValidation()' in 'RehydrateArchive()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		rehydration, err := archive.Rehydrate(r.Context(), mongoDBInterface, awsInterface, bucketName, plantLoggerConfig.CollectionNameLogger, dateStart, dateEnd, time.Now())
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'RehydrateArchive()' using 'Rehydrate()'. Error: %v", err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+
+		responsehandler.HandleSuccess(w, "Archived readings rehydrated.", responsehandler.OK, rehydration)
+
+	}
+}
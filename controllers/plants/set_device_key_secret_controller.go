@@ -0,0 +1,90 @@
+package plantcontroller
+
+import (
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	crypto "github.com/paulmuenzner/powerplantmanager/utils/crypto"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	stringHandler "github.com/paulmuenzner/powerplantmanager/utils/strings"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func SetDeviceKeySecret(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////////////
+		///////// SETUP //////////////////////////////////////
+		//
+		neutralResponseErr := "We appologize. Creating device credentials is currently not possible due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+		/////////////////////////////////////////////////////////////
+		// Generate Keys
+		// Same format as key and secret of a plant, as both are accepted by the logging API
+		secret, err := crypto.ByteSize18.GenerateKey()
+		if err != nil {
+			logger.GetLogger().Error("Error creating secret in 'SetDeviceKeySecret()'. Error: ", err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+		hashedSecret, err := crypto.Hash(secret)
+		if err != nil {
+			logger.GetLogger().Error("Cannot hash secret in 'SetDeviceKeySecret()'. Error: ", err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+		encryptedSigningKey, err := loggerhandler.EncryptSigningKey(secret)
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'SetDeviceKeySecret()' using 'EncryptSigningKey()'. Is '%s' a 32 byte hex key in .env? Error: %v", config.LoggerSigningKeyEnv, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+		deviceKey := stringHandler.GenerateRandomNumericString(40)
+
+		//////////////////////////////////////////////
+		// ACCESS REQUEST ATTACHMENT /////////////////
+		//
+		// Access the device attached in SetDeviceKeySecretValidation
+		device, ok := r.Context().Value("plantDevice").(model.PlantDevice)
+		if !ok {
+			logger.GetLogger().Error("Cannot parse and access plantDevice in 'SetDeviceKeySecret()'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		// Update PlantDevice finally
+		filterUpdate := bson.M{"_id": device.ID}
+		update := bson.M{"$set": bson.M{"key": deviceKey, "secret": hashedSecret, "signing_key": encryptedSigningKey}}
+
+		_, errUpdate := mongoDBInterface.RepositoryInterface.UpdateOneInMongo(r.Context(), config.DatabaseNamePlantDevice, filterUpdate, update, config.CollectionNamePlantDevice)
+		if errUpdate != nil {
+			logger.GetLogger().Errorf("Unable to update device credentials in 'SetDeviceKeySecret()' using 'UpdateOneInMongo()'. Device id: %s. Error: %v", device.DeviceID, errUpdate)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(errUpdate, errHandler.InternalServerError))
+			return
+		}
+		// The previous key and secret of the device must not be accepted anymore
+		loggerhandler.InvalidateLoggerConfig(device.PublicPlantID)
+
+		//////////////////////////////////////////////
+		// POSITIVE RESPONSE /////////////////////////
+		//
+		type Data struct {
+			DeviceID string
+			Key      string
+			Secret   string
+		}
+
+		data := Data{
+			DeviceID: device.DeviceID,
+			Key:      deviceKey,
+			Secret:   secret,
+		}
+
+		responsehandler.HandleSuccess(w, "New device key and secret created. Please note them in a safe place. We cannot retrieve them again. If they get lost, you must create a new key and secret here.", responsehandler.OK, data)
+
+	}
+}
@@ -4,6 +4,7 @@ import (
 	config "github.com/paulmuenzner/powerplantmanager/config"
 	model "github.com/paulmuenzner/powerplantmanager/models"
 	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
 	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
 	crypto "github.com/paulmuenzner/powerplantmanager/utils/crypto"
 	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
@@ -36,6 +37,13 @@ func SetKeySecret(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
 			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
 			return
 		}
+		// Signing key for signed requests (HMAC). Derived from secret and stored encrypted, as it must be available to verify signatures
+		encryptedSigningKey, err := loggerhandler.EncryptSigningKey(secret)
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'SetKeySecret()' using 'EncryptSigningKey()'. Is '%s' a 32 byte hex key in .env? Error: %v", config.LoggerSigningKeyEnv, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
 		plantKey := stringHandler.GenerateRandomNumericString(40)
 
 		//////////////////////////////////////////////
@@ -51,14 +59,16 @@ func SetKeySecret(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
 
 		// Update PlantLoggerConfig finally
 		filterUpdate := bson.M{"_id": plantQuery.ID}
-		update := bson.M{"$set": bson.M{"key": plantKey, "secret": hashedSecret}}
+		update := bson.M{"$set": bson.M{"key": plantKey, "secret": hashedSecret, "signing_key": encryptedSigningKey}}
 
-		_, errUpdate := mongoDBInterface.RepositoryInterface.UpdateOneInMongo(config.DatabaseNamePlantLoggerConfig, filterUpdate, update, config.CollectionNamePlantLoggerConfig)
+		_, errUpdate := mongoDBInterface.RepositoryInterface.UpdateOneInMongo(r.Context(), config.DatabaseNamePlantLoggerConfig, filterUpdate, update, config.CollectionNamePlantLoggerConfig)
 		if errUpdate != nil {
 			logger.GetLogger().Error("Update of verified account not possible RegistrationVerify: ", errUpdate)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(errUpdate, errHandler.InternalServerError))
 			return
 		}
+		// The previous key and secret must not be accepted anymore
+		loggerhandler.InvalidateLoggerConfig(plantQuery.PublicPlantID)
 
 		//////////////////////////////////////////////
 		// POSITIVE RESPONSE /////////////////////////
@@ -4,6 +4,7 @@ import (
 	config "github.com/paulmuenzner/powerplantmanager/config"
 	model "github.com/paulmuenzner/powerplantmanager/models"
 	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
 	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
 	ip "github.com/paulmuenzner/powerplantmanager/utils/ip"
 	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
@@ -61,14 +62,57 @@ func SetPlantConfig(mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc
 
 		// Update PlantLoggerConfig finally
 		filterUpdate := bson.M{"_id": plantQuery.ID}
-		update := bson.M{"$set": bson.M{"interval_sec": intervalSec, "ip_whitelist": ipWhiteListExtended}}
+		updateFields := bson.M{"interval_sec": intervalSec, "ip_whitelist": ipWhiteListExtended}
+		// Clock skew policy is optional and only attached in SetPlantConfigValidation if provided
+		if clockSkewPolicy, ok := r.Context().Value("clockSkewPolicy").(model.ClockSkewPolicy); ok {
+			updateFields["clock_skew_policy"] = clockSkewPolicy
+		}
+		// Authentication scheme is optional and only attached in SetPlantConfigValidation if provided
+		if authScheme, ok := r.Context().Value("authScheme").(string); ok {
+			updateFields["auth_scheme"] = authScheme
+		}
+		// Channel schema is optional and only attached in SetPlantConfigValidation if provided
+		if channels, ok := r.Context().Value("channels").([]model.Channel); ok {
+			updateFields["channels"] = channels
+		}
+		// Poll targets are optional and only attached in SetPlantConfigValidation if provided
+		if pollTargets, ok := r.Context().Value("pollTargets").([]model.PollTarget); ok {
+			updateFields["poll_targets"] = pollTargets
+		}
+		// Retention period is optional and only attached in SetPlantConfigValidation if provided
+		if retentionDays, ok := r.Context().Value("retentionDays").(int); ok {
+			updateFields["retention_days"] = retentionDays
+		}
+		update := bson.M{"$set": updateFields}
 
-		_, errUpdate := mongoDBInterface.RepositoryInterface.UpdateOneInMongo(config.DatabaseNamePlantLoggerConfig, filterUpdate, update, config.CollectionNamePlantLoggerConfig)
+		_, errUpdate := mongoDBInterface.RepositoryInterface.UpdateOneInMongo(r.Context(), config.DatabaseNamePlantLoggerConfig, filterUpdate, update, config.CollectionNamePlantLoggerConfig)
 		if errUpdate != nil {
 			logger.GetLogger().Errorf("Update of verified account not possible in 'SetPlantConfig()' using 'UpdateOneInMongo()': %v", errUpdate)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(errUpdate, errHandler.InternalServerError))
 			return
 		}
+		// Loggers are authenticated against the new configuration from now on
+		loggerhandler.InvalidateLoggerConfig(plantQuery.PublicPlantID)
+
+		// Coarser logging intervals are stored more efficiently with a coarser granularity of the time-series collection. Readings are stored anyway
+		var plantLoggerConfig model.PlantLoggerConfig
+		_, errGranularity := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNamePlantLoggerConfig, filterUpdate, config.CollectionNamePlantLoggerConfig, bson.D{}, &plantLoggerConfig)
+		if errGranularity == nil {
+			errGranularity = loggerhandler.AdjustGranularity(r.Context(), mongoDBInterface, plantLoggerConfig.CollectionNameLogger, intervalSec)
+		}
+		if errGranularity != nil {
+			logger.GetLogger().Warnf("Granularity of plant logger collection not adjusted in 'SetPlantConfig()' using 'AdjustGranularity()'. Public plant id: %s. Error: %v", plantQuery.PublicPlantID, errGranularity)
+		}
+
+		// Coordinates are optional and only attached in SetPlantConfigValidation if provided. Part of the plant document
+		if coordinates, ok := r.Context().Value("coordinates").(model.Coordinates); ok {
+			_, errUpdate := mongoDBInterface.RepositoryInterface.UpdateOneInMongo(r.Context(), config.DatabaseNamePlants, filterUpdate, bson.M{"$set": bson.M{"coordinates": coordinates}}, config.CollectionNamePhotovoltaicPlant)
+			if errUpdate != nil {
+				logger.GetLogger().Errorf("Update of plant coordinates not possible in 'SetPlantConfig()' using 'UpdateOneInMongo()': %v", errUpdate)
+				errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(errUpdate, errHandler.InternalServerError))
+				return
+			}
+		}
 
 		responsehandler.HandleSuccess(w, "Plant configuration updated.", responsehandler.OK)
 
@@ -1,15 +1,21 @@
 package plantcontroller
 
 import (
+	"errors"
+	"fmt"
 	config "github.com/paulmuenzner/powerplantmanager/config"
 	model "github.com/paulmuenzner/powerplantmanager/models"
 	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	gapanalysis "github.com/paulmuenzner/powerplantmanager/services/gapAnalysis"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	readingstatistics "github.com/paulmuenzner/powerplantmanager/services/readingStatistics"
 	responsehandler "github.com/paulmuenzner/powerplantmanager/services/responseHandler"
+	rollup "github.com/paulmuenzner/powerplantmanager/services/rollup"
 	cookie "github.com/paulmuenzner/powerplantmanager/utils/cookies"
 	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
 	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
-	"github.com/paulmuenzner/powerplantmanager/utils/statistic"
 	"net/http"
+	"slices"
 
 	"time"
 
@@ -93,94 +99,151 @@ func GetPlantStatistics(mongoDBInterface *mongodb.MethodInterface) http.HandlerF
 		//////////////////////////////////////////////
 		// REQUEST BODY VALIDATION ///////////////////
 		//
-		// Query plant logs
+		// Query plant logs by measurement time
+		// Logs stored before measurement times were introduced only carry 'created_at'
+		timeRange := bson.M{
+			"$gte": dateStart,
+			"$lt":  dateEnd,
+		}
 		filter := bson.M{
-			"created_at": bson.M{
-				"$gte": dateStart,
-				"$lt":  dateEnd,
+			"$or": bson.A{
+				bson.M{"measured_at": timeRange},
+				bson.M{"measured_at": bson.M{"$exists": false}, "created_at": timeRange},
 			},
 		}
 
-		var plantLogger []model.PlantLogger
-		var sortCriteria bson.D = bson.D{}
-		err = mongoDBInterface.RepositoryInterface.FindManyInMongo(config.DatabaseNamePlantLogger, filter, collectionNameLogger, sortCriteria, &plantLogger)
-		if err != nil {
-			log := "User with _id " + userID + " requested non-existing plant logger for collection name " + collectionNameLogger + " in controller 'GetPlantStatistics'."
-			logger.GetLogger().Error(log, err)
-			errHandler.HandleError(w, "Requested plant not available.", errHandler.BadRequest)
+		// Readings of one device only if requested. Otherwise readings of all devices are rolled up to the plant
+		deviceID, _ := r.Context().Value("statisticsDeviceID").(string)
+		if deviceID != "" {
+			filter["device_id"] = deviceID
+		}
+
+		// Readings voided by the owner are excluded unless requested otherwise
+		includeVoided, _ := r.Context().Value("statisticsIncludeVoided").(bool)
+		if !includeVoided {
+			filter = loggerhandler.ExcludeVoided(filter)
+		}
+
+		// Channels to analyze. Power output and solar radiation if not requested otherwise
+		statisticsChannels, ok := r.Context().Value("statisticsChannels").([]string)
+		if !ok {
+			statisticsChannels = []string{"powerOutput", "solarRadiation"}
+		}
+		groupByDevice, _ := r.Context().Value("statisticsGroupByDevice").(bool)
+
+		plantLoggerConfig, configOk := r.Context().Value("plantLoggerConfig").(model.PlantLoggerConfig)
+		plant, plantOk := r.Context().Value("plantRequest").(model.PhotovoltaicPlant)
+		if !configOk || !plantOk {
+			logger.GetLogger().Error("Cannot access plantLoggerConfig or plantRequest in 'GetPlantStatistics'.")
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
 			return
 		}
 
-		// Restructure retrieved data for statistical analysis
-		solarRadiation := []float64{}
-		powerOutputs := []float64{}
-		for _, plant := range plantLogger {
-			solarRadiation = append(solarRadiation, plant.SolarRadiation)
-			powerOutputs = append(powerOutputs, plant.PowerOutput)
-		}
-
-		// Mean
-		meanPowerOutputs, _ := statistic.Mean(powerOutputs)
-		meanSolarRadiation, _ := statistic.Mean(solarRadiation)
-
-		// Median
-		medianPowerOutputs, _ := statistic.Median(powerOutputs, 0.5, nil)
-		medianSolarRadiation, _ := statistic.Median(solarRadiation, 0.5, nil)
-
-		// Variance
-		variancePowerOutputs, _ := statistic.Variance(powerOutputs, nil)
-		varianceSolarRadiation, _ := statistic.Variance(solarRadiation, nil)
-
-		// Variance
-		standardDeviationPowerOutputs, _ := statistic.StandardDeviation(powerOutputs, nil)
-		standardDeviationSolarRadiation, _ := statistic.StandardDeviation(solarRadiation, nil)
-
-		// Skewness
-		skewnessPowerOutputs, _ := statistic.Skewness(powerOutputs, nil)
-		skewnessSolarRadiation, _ := statistic.Skewness(solarRadiation, nil)
-
-		// Quantile
-		quantile25PowerOutputs, quantile75PowerOutputs, iqrPowerOutputs, lowerBoundPowerOutputs, upperBoundPowerOutputs, outliersPowerOutputs, quantile90PowerOutputs, quantile95PowerOutputs := statistic.Quantile(powerOutputs)
-		quantile25SolarRadiation, quantile75SolarRadiation, iqrSolarRadiation, lowerBoundSolarRadiation, upperBoundSolarRadiation, outliersSolarRadiation, quantile90SolarRadiation, quantile95SolarRadiation := statistic.Quantile(solarRadiation)
-
-		// Correlation
-		correlationPowerSolar, _ := statistic.Correlation(powerOutputs, solarRadiation)
-
-		data := map[string]interface{}{
-			"powerOutput": map[string]interface{}{
-				"mean":               meanPowerOutputs,
-				"variance":           variancePowerOutputs,
-				"median":             medianPowerOutputs,
-				"standardDeviation":  standardDeviationPowerOutputs,
-				"skewness":           skewnessPowerOutputs,
-				"quantile25":         quantile25PowerOutputs,
-				"quantile75":         quantile75PowerOutputs,
-				"quantile90":         quantile90PowerOutputs,
-				"quantile95":         quantile95PowerOutputs,
-				"interquartileRange": iqrPowerOutputs,
-				"lowerBound":         lowerBoundPowerOutputs,
-				"upperBound":         upperBoundPowerOutputs,
-				"outliers":           outliersPowerOutputs,
-			},
-			"solarRadiation": map[string]interface{}{
-				"mean":               meanSolarRadiation,
-				"variance":           varianceSolarRadiation,
-				"median":             medianSolarRadiation,
-				"standardDeviation":  standardDeviationSolarRadiation,
-				"skewness":           skewnessSolarRadiation,
-				"quantile25":         quantile25SolarRadiation,
-				"quantile75":         quantile75SolarRadiation,
-				"quantile90":         quantile90SolarRadiation,
-				"quantile95":         quantile95SolarRadiation,
-				"interquartileRange": iqrSolarRadiation,
-				"lowerBound":         lowerBoundSolarRadiation,
-				"upperBound":         upperBoundSolarRadiation,
-				"outliers":           outliersSolarRadiation,
-			},
-			"correlationPowerSolar": correlationPowerSolar,
+		//////////////////////////////////////////////
+		// STATISTICS FROM ROLLUPS ///////////////////
+		//
+		// Long periods are analyzed from hourly or daily rollups. Rollups don't contain voided readings, so these are always analyzed from raw readings
+		// Plants not rolled up yet are analyzed from raw readings as well
+		resolution := rollup.ChooseLevel(dateStart, dateEnd)
+		if resolution != rollup.LevelRaw && !includeVoided {
+			_, rolledUp, err := rollup.FindState(r.Context(), mongoDBInterface, collectionNameLogger)
+			if err != nil {
+				logger.GetLogger().Errorf("Error in 'GetPlantStatistics()' using 'FindState()' for collection '%s'. Error: %v", collectionNameLogger, err)
+				errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+				return
+			}
+			if rolledUp {
+				// Partial hours or days at the edges of the period are rolled up from readings
+				rollups, err := rollup.FindPeriod(r.Context(), mongoDBInterface, plantLoggerConfig, resolution, dateStart, dateEnd, deviceID)
+				if err != nil {
+					logger.GetLogger().Errorf("Error in 'GetPlantStatistics()' using 'FindPeriod()'. Error: %v", err)
+					errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+					return
+				}
+
+				data := rollup.Statistics(rollups, statisticsChannels)
+				if energyWh, provided := rollup.Energy(rollups); provided && slices.Contains(statisticsChannels, "powerOutput") {
+					data["energyWh"] = energyWh
+				}
+
+				// Statistics per device in addition if requested. Readings reported for the plant as a whole are grouped as 'unassigned'
+				if groupByDevice {
+					rollupsByDevice := map[string][]model.PlantRollup{}
+					for _, plantRollup := range rollups {
+						deviceID := plantRollup.DeviceID
+						if deviceID == "" {
+							deviceID = "unassigned"
+						}
+						rollupsByDevice[deviceID] = append(rollupsByDevice[deviceID], plantRollup)
+					}
+					devices := map[string]interface{}{}
+					for deviceID, deviceRollups := range rollupsByDevice {
+						devices[deviceID] = rollup.Statistics(deviceRollups, statisticsChannels)
+					}
+					data["devices"] = devices
+				}
+
+				completeness, warnings := rollupsCompleteness(rollups, plantLoggerConfig.IntervalSec, plant.Coordinates, dateStart, dateEnd)
+				data["completeness"] = completeness
+				data["warnings"] = warnings
+				data["resolution"] = resolution
+				data["approximated"] = rollup.ApproximatedKeyFigures
+
+				responsehandler.HandleSuccess(w, "Requested statistical data retrieved.", responsehandler.OK, data)
+				return
+			}
 		}
 
+		//////////////////////////////////////////////
+		// STATISTICS FROM READINGS //////////////////
+		//
+		// Aggregated by MongoDB, or computed from streamed readings if it lacks the operators
+		query := readingstatistics.Query{CollectionNameLogger: collectionNameLogger, Filter: filter, Channels: statisticsChannels, GroupByDevice: groupByDevice, IntervalSec: plantLoggerConfig.IntervalSec}
+		result, err := readingstatistics.Compute(r.Context(), mongoDBInterface, query)
+		if errors.Is(err, readingstatistics.ErrTooManyReadings) {
+			logger.GetLogger().Warnf("Statistics of collection '%s' rejected in 'GetPlantStatistics()' using 'Compute()'. Error: %v", collectionNameLogger, err)
+			errHandler.HandleError(w, "Too many readings in requested period. Please, request a shorter period.", errHandler.BadRequest)
+			return
+		}
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'GetPlantStatistics()' using 'Compute()' for user with _id %s and collection '%s'. Error: %v", userID, collectionNameLogger, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+		data := result.Data
+
+		// Completeness of the readings analyzed. Statistics of few readings may be misleading
+		completeness, warnings := completenessOfReport(gapanalysis.Analyze(result.MeasuredAt, plantLoggerConfig.IntervalSec, plant.Coordinates, dateStart, dateEnd))
+		data["completeness"] = completeness
+		data["warnings"] = warnings
+		data["resolution"] = rollup.LevelRaw
+		data["approximated"] = []string{}
+
 		responsehandler.HandleSuccess(w, "Requested statistical data retrieved.", responsehandler.OK, data)
 
 	}
 }
+
+// rollupsCompleteness returns the completeness of the readings rolled up in rollups like the completeness of raw readings. Rollups don't keep measurement times,
+// so missing readings are the expected readings not rolled up
+func rollupsCompleteness(rollups []model.PlantRollup, intervalSec int, coordinates model.Coordinates, dateStart, dateEnd time.Time) (map[string]interface{}, []string) {
+	report := gapanalysis.Analyze(nil, intervalSec, coordinates, dateStart, dateEnd)
+	report.MissingReadings = max(0, report.ExpectedReadings-rollup.Readings(rollups))
+	report.CompletenessPercent = gapanalysis.Completeness(report.ExpectedReadings, report.MissingReadings)
+	return completenessOfReport(report)
+}
+
+// completenessOfReport returns the completeness of a gap analysis report and warnings if less than StatisticsCompletenessMinPercent of the expected readings are available
+func completenessOfReport(report gapanalysis.Report) (map[string]interface{}, []string) {
+	warnings := []string{}
+	if report.CompletenessPercent != nil && *report.CompletenessPercent < config.StatisticsCompletenessMinPercent {
+		warnings = append(warnings, fmt.Sprintf("Low data coverage: only %.2f %% of the expected readings are available (%d of %d readings missing). Statistics may not be representative.", *report.CompletenessPercent, report.MissingReadings, report.ExpectedReadings))
+	}
+	completeness := map[string]interface{}{
+		"completenessPercent": report.CompletenessPercent,
+		"expectedReadings":    report.ExpectedReadings,
+		"missingReadings":     report.MissingReadings,
+		"nightExcluded":       report.NightExcluded,
+	}
+	return completeness, warnings
+}
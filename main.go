@@ -2,26 +2,39 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	config "github.com/paulmuenzner/powerplantmanager/config"
 	routes "github.com/paulmuenzner/powerplantmanager/routes"
+	archive "github.com/paulmuenzner/powerplantmanager/services/archive"
 	errorHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	ingestPipeline "github.com/paulmuenzner/powerplantmanager/services/ingestPipeline"
+	loggerHandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	migration "github.com/paulmuenzner/powerplantmanager/services/migration"
+	mqttBridge "github.com/paulmuenzner/powerplantmanager/services/mqttBridge"
+	plantHandler "github.com/paulmuenzner/powerplantmanager/services/plantHandler"
+	rateLimit "github.com/paulmuenzner/powerplantmanager/services/rateLimit"
+	rollup "github.com/paulmuenzner/powerplantmanager/services/rollup"
+	sunspecPoller "github.com/paulmuenzner/powerplantmanager/services/sunspecPoller"
 	aws "github.com/paulmuenzner/powerplantmanager/utils/aws"
+	awsMemory "github.com/paulmuenzner/powerplantmanager/utils/aws/memory"
 	emailHandler "github.com/paulmuenzner/powerplantmanager/utils/email"
+	emailMemory "github.com/paulmuenzner/powerplantmanager/utils/email/memory"
 	env "github.com/paulmuenzner/powerplantmanager/utils/env"
 	ip "github.com/paulmuenzner/powerplantmanager/utils/ip"
 	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
 	mongoDB "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
 	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	memory "github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
 	serverConfig "github.com/paulmuenzner/powerplantmanager/utils/server"
 
-	"github.com/didip/tollbooth"
-	"github.com/didip/tollbooth/limiter"
-
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -32,6 +45,16 @@ type AppConfig struct {
 }
 
 func main() {
+	// Command line flags. Without, the server is started
+	migrateTimeSeries := flag.Bool("migrate-timeseries", false, "Convert plant logger collections into time-series collections and exit, same as -migrate up -migrate-to 8. Stop all server instances before.")
+	migrate := flag.String("migrate", "", "Run schema migrations and exit: 'up' applies pending migrations (manual ones only with -migrate-to), 'down' reverts applied ones, 'status' lists them. The server applies pending migrations at startup, too.")
+	migrateTo := flag.Int("migrate-to", -1, "Target version of -migrate. Default: 'up' applies all pending migrations, 'down' reverts the latest applied one.")
+	dryRun := flag.Bool("dry-run", false, "With -migrate, list the migrations that would be applied or reverted without running them.")
+	checkConsistency := flag.Bool("check-consistency", false, "List plants stored inconsistently across plants, plant logger configs and plant logger collections, eg. orphaned collections, and exit.")
+	repair := flag.Bool("repair", false, "With -check-consistency, repair the inconsistencies found.")
+	dev := flag.Bool("dev", false, "Use an in-memory database, S3 bucket and email client instead of MongoDB, S3 and SMTP for local development. No credentials needed, emails are logged. Data is lost when the server stops.")
+	flag.Parse()
+
 	router := mux.NewRouter()
 	router.Use(ip.RealIP)
 
@@ -41,7 +64,9 @@ func main() {
 
 	// Load environment variables from .env file
 	err := godotenv.Load()
-	if err != nil {
+	if err != nil && *dev {
+		logger.GetLogger().Warnf("Cannot load environment variables from .env file in 'main.go'. Development mode: default values used. Error: %v", err)
+	} else if err != nil {
 		logger.GetLogger().Errorf("Cannot load environment variables from .env file in 'main.go'. Default value used. Error: %v", err)
 		return
 	}
@@ -60,10 +85,6 @@ func main() {
 	// PROTECTION /////////////////////////////////
 	///////////////////////////////////////////////
 
-	// Create a rate limiter with a limit of 3 requests per second
-	lim := tollbooth.NewLimiter(3, &limiter.ExpirableOptions{DefaultExpirationTTL: time.Hour})
-	router.Use(serverConfig.TollboothMiddleware(lim))
-
 	// Apply the custom middleware to check request methods with the allowed methods parameter
 	allowedMethods := map[string]bool{"GET": true, "POST": true, "PUT": true, "DELETE": true}
 	router.Use(serverConfig.CheckAllowedMethodsMiddleware(allowedMethods))
@@ -90,70 +111,330 @@ func main() {
 	// END PARSER COOKIE LOGGER MIDDLEWARE ////////
 	///////////////////////////////////////////////
 
+	///////////////////////////////////////////////
+	// RATE LIMITING //////////////////////////////
+	///////////////////////////////////////////////
+
+	// Separate budgets for auth routes and other routes by IP and for logging routes by plant key. After the body parser, as legacy loggers send their key in the body
+	router.Use(rateLimit.Middleware(rateLimit.NewPolicies()))
+
+	///////////////////////////////////////////////
+	// END RATE LIMITING //////////////////////////
+	///////////////////////////////////////////////
+
 	///////////////////////////////////////////////
 	// CONNECT DATABASE MONGODB ///////////////////
 	///////////////////////////////////////////////
 
-	// Get Uniform Resource Identifier
-	mongodbURI, err := mongoDB.ClientConfig()
-	if err != nil {
-		logger.GetLogger().Warnf("Cannot retrieve .env value for Mongo URI in 'main.go'. Default value used. Error: %v", err)
+	// Provide interface for database repository
+	var mongoDBInterface *mongodb.MethodInterface
+	if *dev {
+		// In-memory database, no MongoDB server needed
+		logger.GetLogger().Warn("Development mode: in-memory database used instead of MongoDB. Data is lost when the server stops.")
+		mongoDBInterface = memory.NewMethodInterface()
+	} else {
+		// Get Uniform Resource Identifier
+		mongodbURI, err := mongoDB.ClientConfig()
+		if err != nil {
+			logger.GetLogger().Warnf("Cannot retrieve .env value for Mongo URI in 'main.go'. Default value used. Error: %v", err)
+		}
+
+		// Connect to database
+		client, err := mongoDB.ConnectToMongoDB(mongodbURI)
+		if err != nil {
+			logger.GetLogger().Errorf("Connection with MongoDB failed due to following error: %v", err)
+			return
+		}
+		mongoDBInterface = mongodb.NewMongoDBMethodInterface(client)
+
+		// Disconnect from MongoDB
+		defer func() {
+			if err := client.MongoDB.Disconnect(context.TODO()); err != nil {
+				logger.GetLogger().Errorf("Disconnection MongoDB failed due to following error: %v", err)
+				return
+			}
+		}()
 	}
 
-	// Connect to database
-	client, err := mongoDB.ConnectToMongoDB(mongodbURI)
-	if err != nil {
-		logger.GetLogger().Errorf("Connection with MongoDB failed due to following error: %v", err)
+	///////////////////////////////////////////////
+	// END CONNECT DATABASE MONGODB ///////////////
+	///////////////////////////////////////////////
+
+	///////////////////////////////////////////////
+	// MIGRATION //////////////////////////////////
+	///////////////////////////////////////////////
+
+	// Schema migrations by command line. The server isn't started
+	if *migrate == "status" {
+		statuses, err := migration.Statuses(context.Background(), mongoDBInterface)
+		if err != nil {
+			logger.GetLogger().Error("Error in 'main()' utilizing 'Statuses()'. Cannot list schema migrations. Error: ", err)
+			fmt.Printf("Listing schema migrations failed. Error: %v\n", err)
+			return
+		}
+		for _, status := range statuses {
+			if status.AppliedAt == nil {
+				fmt.Printf("%d %s: pending\n", status.Version, status.Name)
+				continue
+			}
+			fmt.Printf("%d %s: applied %s\n", status.Version, status.Name, status.AppliedAt.Format(time.RFC3339))
+		}
+		return
+	}
+	if *migrate != "" {
+		steps, err := migration.Run(context.Background(), mongoDBInterface, *migrate, *migrateTo, *dryRun)
+		for _, step := range steps {
+			if *dryRun {
+				fmt.Printf("%d %s: would run %s\n", step.Version, step.Name, step.Direction)
+				continue
+			}
+			fmt.Printf("%d %s: %s in %s\n", step.Version, step.Name, step.Direction, step.Duration)
+		}
+		if err != nil {
+			logger.GetLogger().Error("Error in 'main()' utilizing 'Run()'. Schema migration failed. Error: ", err)
+			fmt.Printf("Schema migration failed. Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Schema migrations finished. Migrations run: %d\n", len(steps))
 		return
 	}
 
-	// Provide interface for database repository
-	mongoDBInterface := mongodb.NewMongoDBMethodInterface(client)
+	// Converts plant logger collections created before time-series collections were introduced by applying pending schema migrations up to
+	// the conversion. The server isn't started
+	if *migrateTimeSeries {
+		steps, err := migration.Run(context.Background(), mongoDBInterface, migration.DirectionUp, migration.VersionTimeSeries, false)
+		for _, step := range steps {
+			fmt.Printf("%d %s: %s in %s\n", step.Version, step.Name, step.Direction, step.Duration)
+		}
+		if err != nil {
+			logger.GetLogger().Error("Error in 'main()' utilizing 'Run()'. Migration to time-series collections failed. Error: ", err)
+			fmt.Printf("Migration failed, collections converted are listed in the log file. Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Migration finished, collections converted are listed in the log file. Migrations run: %d\n", len(steps))
+		return
+	}
 
-	// Disconnect from MongoDB
-	defer func() {
-		if err := client.MongoDB.Disconnect(context.TODO()); err != nil {
-			logger.GetLogger().Errorf("Disconnection MongoDB failed due to following error: %v", err)
+	// Finds and repairs remains of plants added or deleted partially. The server isn't started
+	if *checkConsistency {
+		// Archive files of deleted plants are deleted from the S3 bucket. Without S3 config, only their repair fails
+		var checkAwsInterface *aws.MethodInterface
+		awsClientConfig, bucketName, err := aws.S3ProductionConfig()
+		if err == nil {
+			checkAwsInterface, err = aws.GetAwsMethods(awsClientConfig)
+		}
+		if err != nil {
+			logger.GetLogger().Error("Error in 'main()' utilizing 'S3ProductionConfig()' or 'GetAwsMethods()'. Orphaned archive files can't be repaired. Error: ", err)
+		}
+		inconsistencies, err := plantHandler.Check(context.Background(), mongoDBInterface, checkAwsInterface, bucketName, *repair, time.Now())
+		if err != nil {
+			logger.GetLogger().Error("Error in 'main()' utilizing 'Check()'. Consistency check failed. Error: ", err)
+			fmt.Printf("Consistency check failed. Error: %v\n", err)
 			return
 		}
-	}()
+		for _, inconsistency := range inconsistencies {
+			status := "found"
+			switch {
+			case inconsistency.Err != nil:
+				logger.GetLogger().Errorf("Repair of inconsistency '%s' of plant '%s' failed. Error: %v", inconsistency.Kind, inconsistency.PublicPlantID, inconsistency.Err)
+				status = "repair failed, see log file"
+			case inconsistency.Repaired:
+				status = "repaired"
+			}
+			fmt.Printf("%s: plant '%s', collection '%s', device '%s': %s\n", inconsistency.Kind, inconsistency.PublicPlantID, inconsistency.CollectionName, inconsistency.DeviceID, status)
+		}
+		fmt.Printf("Consistency check finished. Inconsistencies found: %d\n", len(inconsistencies))
+		return
+	}
 
 	///////////////////////////////////////////////
-	// END CONNECT DATABASE MONGODB ///////////////
+	// END MIGRATION //////////////////////////////
 	///////////////////////////////////////////////
 
 	///////////////////////////////////////////////
-	// PRODUCTION CONFIG //////////////////////////
+	// SCHEMA MIGRATIONS //////////////////////////
 	///////////////////////////////////////////////
 
-	// Email client config production
-	emailClientConfig, err := emailHandler.ProductionConfig()
+	// Pending schema migrations are applied before serving, apart from manual ones (time-series conversion). Server instances starting at the same time wait for each other
+	steps, err := migration.Run(context.Background(), mongoDBInterface, migration.DirectionUp, -1, false)
 	if err != nil {
-		logger.GetLogger().Error("Error in 'main()' utilizing 'ProductionConfig()' retrieving emailClientConfig. Error: ", err)
+		logger.GetLogger().Error("Error in 'main()' utilizing 'Run()'. Cannot apply pending schema migrations. Error: ", err)
 		return
 	}
-	emailInterface, err := emailHandler.GetEmailRepositoryInterface(emailClientConfig)
-	if err != nil {
-		logger.GetLogger().Error("Error in 'main()' utilizing 'GetEmailRepositoryInterface()'. Cannot create 'emailInterface'. Error: ", err)
-		return
+	for _, step := range steps {
+		logger.GetLogger().Infof("Schema migration %d '%s' applied in %s", step.Version, step.Name, step.Duration)
 	}
 
-	// AWS client config production
-	awsClientConfig, _, err := aws.S3ProductionConfig()
+	///////////////////////////////////////////////
+	// END SCHEMA MIGRATIONS //////////////////////
+	///////////////////////////////////////////////
+
+	///////////////////////////////////////////////
+	// INGESTION PIPELINE /////////////////////////
+	///////////////////////////////////////////////
+
+	// Logs accepted by the logging route are spooled to disk and stored in bulk in the background
+	spoolDir, err := env.GetEnvValue(config.IngestSpoolDirEnv, config.IngestSpoolDirDefault)
 	if err != nil {
-		logger.GetLogger().Error("Error in 'main()' utilizing 'S3ProductionConfig()' retrieving awsClientConfig. Error: ", err)
-		return
+		logger.GetLogger().Warnf("Cannot retrieve .env value for %s in 'main.go'. Default value used. Error: %v", config.IngestSpoolDirEnv, err)
 	}
-	awsInterface, err := aws.GetAwsMethods(awsClientConfig)
+	pipeline, err := ingestPipeline.NewPipeline(mongoDBInterface, spoolDir)
 	if err != nil {
-		logger.GetLogger().Error("Error in 'main()' utilizing 'GetAwsMethods()'. Cannot create 'awsInterface'. Error: ", err)
+		logger.GetLogger().Error("Error in 'main()' utilizing 'NewPipeline()'. Cannot open ingestion spool. Error: ", err)
 		return
 	}
+	if recovered, skippedLines := pipeline.Recovered(); recovered > 0 || skippedLines > 0 {
+		logger.GetLogger().Warnf("Ingestion pipeline recovered %d spooled logs of the previous run. Unreadable spool lines skipped: %d", recovered, skippedLines)
+	}
+	pipeline.Start()
+	defer pipeline.Stop()
+
+	///////////////////////////////////////////////
+	// END INGESTION PIPELINE /////////////////////
+	///////////////////////////////////////////////
+
+	///////////////////////////////////////////////
+	// LOGGER CONFIG CACHE ////////////////////////
+	///////////////////////////////////////////////
+
+	// Plant logger configs are cached by logger key. Changes of other server instances invalidate them via change streams if supported by MongoDB.
+	// The in-memory database serves a single instance and has no change streams
+	if !*dev {
+		configCacheWatcher := loggerHandler.NewConfigCacheWatcher(mongoDBInterface)
+		configCacheWatcher.Start()
+		defer configCacheWatcher.Stop()
+	}
+
+	///////////////////////////////////////////////
+	// END LOGGER CONFIG CACHE ////////////////////
+	///////////////////////////////////////////////
+
+	///////////////////////////////////////////////
+	// METRICS ////////////////////////////////////
+	///////////////////////////////////////////////
+
+	// Optional. Metrics are served on a separate address at /debug/vars
+	metricsAddr, _ := env.GetEnvValue(config.MetricsAddrEnv, "")
+	if metricsAddr != "" {
+		metricsServer := serverConfig.NewMetricsServer(metricsAddr)
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.GetLogger().Error("Error in 'main()' utilizing 'ListenAndServe()' of metrics server. Error: ", err)
+			}
+		}()
+		defer metricsServer.Close()
+	}
+
+	///////////////////////////////////////////////
+	// END METRICS ////////////////////////////////
+	///////////////////////////////////////////////
+
+	///////////////////////////////////////////////
+	// MQTT TELEMETRY BRIDGE //////////////////////
+	///////////////////////////////////////////////
+
+	// Optional. Plant loggers may publish their logs to an MQTT broker instead of calling the logging route
+	mqttOptions, mqttEnabled := mqttBridge.OptionsFromEnv()
+	if mqttEnabled {
+		bridge, err := mqttBridge.NewBridge(mqttOptions, mqttBridge.TelemetryHandler(mongoDBInterface))
+		if err != nil {
+			logger.GetLogger().Error("Error in 'main()' utilizing 'NewBridge()'. Cannot create MQTT bridge. Error: ", err)
+			return
+		}
+		if err := bridge.Start(); err != nil {
+			logger.GetLogger().Error("Error in 'main()' utilizing 'Start()'. Cannot start MQTT bridge. Error: ", err)
+			return
+		}
+		defer bridge.Stop()
+	}
+
+	///////////////////////////////////////////////
+	// END MQTT TELEMETRY BRIDGE //////////////////
+	///////////////////////////////////////////////
+
+	///////////////////////////////////////////////
+	// SUNSPEC POLLER /////////////////////////////
+	///////////////////////////////////////////////
+
+	// Pulls readings via Modbus TCP from plants with poll targets in their logging interval
+	poller := sunspecPoller.NewPoller(mongoDBInterface)
+	poller.Start()
+	defer poller.Stop()
+
+	///////////////////////////////////////////////
+	// END SUNSPEC POLLER /////////////////////////
+	///////////////////////////////////////////////
+
+	///////////////////////////////////////////////
+	// ROLLUPS ////////////////////////////////////
+	///////////////////////////////////////////////
+
+	// Hourly and daily rollups of readings. Statistics of long periods are computed from rollups
+	rollupJob := rollup.NewJob(mongoDBInterface)
+	rollupJob.Start()
+	defer rollupJob.Stop()
+
+	///////////////////////////////////////////////
+	// END ROLLUPS ////////////////////////////////
+	///////////////////////////////////////////////
+
+	///////////////////////////////////////////////
+	// PRODUCTION CONFIG //////////////////////////
+	///////////////////////////////////////////////
+
+	var emailInterface *emailHandler.RepositoryInterface
+	var awsInterface *aws.MethodInterface
+	var bucketName string
+	if *dev {
+		// In-memory S3 bucket and email client, no credentials needed. Emails are logged instead of sent
+		logger.GetLogger().Warn("Development mode: in-memory S3 bucket and email client used. Emails are logged instead of sent.")
+		emailInterface = emailMemory.NewRepositoryInterface()
+		awsInterface = awsMemory.NewMethodInterface()
+		bucketName = "dev"
+	} else {
+		// Email client config production
+		emailClientConfig, err := emailHandler.ProductionConfig()
+		if err != nil {
+			logger.GetLogger().Error("Error in 'main()' utilizing 'ProductionConfig()' retrieving emailClientConfig. Error: ", err)
+			return
+		}
+		emailInterface, err = emailHandler.GetEmailRepositoryInterface(emailClientConfig)
+		if err != nil {
+			logger.GetLogger().Error("Error in 'main()' utilizing 'GetEmailRepositoryInterface()'. Cannot create 'emailInterface'. Error: ", err)
+			return
+		}
+
+		// AWS client config production
+		var awsClientConfig *aws.ClientConfigData
+		awsClientConfig, bucketName, err = aws.S3ProductionConfig()
+		if err != nil {
+			logger.GetLogger().Error("Error in 'main()' utilizing 'S3ProductionConfig()' retrieving awsClientConfig. Error: ", err)
+			return
+		}
+		awsInterface, err = aws.GetAwsMethods(awsClientConfig)
+		if err != nil {
+			logger.GetLogger().Error("Error in 'main()' utilizing 'GetAwsMethods()'. Cannot create 'awsInterface'. Error: ", err)
+			return
+		}
+	}
 
 	///////////////////////////////////////////////
 	// END PRODUCTION CONFIG //////////////////////
 	///////////////////////////////////////////////
 
+	///////////////////////////////////////////////
+	// DATA RETENTION /////////////////////////////
+	///////////////////////////////////////////////
+
+	// Readings beyond the retention period of a plant are archived to the S3 bucket and deleted, rollups are kept
+	retentionJob := archive.NewJob(mongoDBInterface, awsInterface, bucketName)
+	retentionJob.Start()
+	defer retentionJob.Stop()
+
+	///////////////////////////////////////////////
+	// END DATA RETENTION /////////////////////////
+	///////////////////////////////////////////////
+
 	///////////////////////////////////////////////
 	// ROUTING ////////////////////////////////////
 	///////////////////////////////////////////////
@@ -166,7 +447,10 @@ func main() {
 	serverConfig.CreateSubrouter(router, "/files", routes.CreateFileSubrouter, awsInterface, emailInterface, mongoDBInterface)
 
 	// Power plants
-	serverConfig.CreateSubrouter(router, "/plants", routes.CreatePlantsSubrouter, awsInterface, emailInterface, mongoDBInterface)
+	plantsSubrouter := func(awsInterface *aws.MethodInterface, emailInterface *emailHandler.RepositoryInterface, mongoDBInterface *mongodb.MethodInterface) *mux.Router {
+		return routes.CreatePlantsSubrouter(awsInterface, emailInterface, mongoDBInterface, pipeline, bucketName)
+	}
+	serverConfig.CreateSubrouter(router, "/plants", plantsSubrouter, awsInterface, emailInterface, mongoDBInterface)
 
 	// Set a custom NotFoundHandler
 	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -191,11 +475,29 @@ func main() {
 		Handler:      router,                                           // The handler to invoke for each incoming request. In this case, it's set to the Gorilla Mux router (`router`).
 	}
 
+	// On SIGINT or SIGTERM requests in progress are finished, then the deferred stops of background jobs and ingestion pipeline run
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
 	fmt.Printf("Server started! Open http://localhost:%s\n", port)
-	err = server.ListenAndServe()
-	if err != nil {
+	select {
+	case err = <-serverErr:
+		// Readings queued by the ingestion pipeline stay spooled and are stored after the next start
 		logger.GetLogger().Error("Servere error: ", err)
 		log.Fatal(err)
+	case <-signalCtx.Done():
+	}
+	stopSignals()
+
+	logger.GetLogger().Info("Shutting down server.")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.GetLogger().Error("Error in 'main()' using 'Shutdown()'. Requests in progress canceled. Error: ", err)
 	}
 
 }
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Lease of a background job, held by one server instance at a time, see services/lease
+type Lease struct {
+	ID        string    `bson:"_id"`   // Name of the job, eg. 'retention'
+	Owner     string    `bson:"owner"` // Host name and process id of the server instance
+	ExpiresAt time.Time `bson:"expires_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Archive file of the readings of one plant measured on one day (UTC), written to the S3 bucket before the readings are deleted
+// Readings arriving late for an archived day are archived with another file of the same day
+type PlantArchive struct {
+	ID                   primitive.ObjectID `bson:"_id" json:"-"`
+	CollectionNameLogger string             `bson:"collection_name_logger" json:"-"`
+	Day                  time.Time          `bson:"day" json:"day"`               // Start of the day the readings were measured
+	ObjectKey            string             `bson:"object_key" json:"object_key"` // Key of the gzip-compressed CSV file in the S3 bucket
+	Readings             int                `bson:"readings" json:"readings"`
+	VoidedReadings       int                `bson:"voided_readings" json:"voided_readings"` // Readings voided by the owner, part of Readings. Archived with their flag
+	Bytes                int                `bson:"bytes" json:"bytes"`                     // Size of the compressed file
+	ArchivedAt           time.Time          `bson:"archived_at" json:"archived_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Device of a plant reporting its own readings, eg. an inverter or a revenue meter
+// Readings are tagged with the device id. Devices may have own credentials, otherwise they log with those of the plant
+type PlantDevice struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	PublicPlantID  string             `bson:"public_plant_id" json:"public_plant_id" validate:"required" unique:"false"`
+	DeviceID       string             `bson:"device_id" json:"device_id" validate:"required" unique:"true"` // Public id of the device
+	Type           string             `bson:"type" json:"type" validate:"required" unique:"false"`          // DeviceTypeInverter, DeviceTypeString, DeviceTypeMeter or DeviceTypeWeatherStation
+	Name           string             `bson:"name" json:"name" validate:"max=100,required" unique:"false"`
+	ParentDeviceID string             `bson:"parent_device_id,omitempty" json:"parent_device_id,omitempty" unique:"false"` // Optional, eg. inverter of a string
+	Key            string             `bson:"key,omitempty" json:"-" unique:"true"`                                        // Optional credentials of the device
+	Secret         string             `bson:"secret,omitempty" json:"-"`
+	SigningKey     string             `bson:"signing_key,omitempty" json:"-"` // HMAC signing key derived from secret, encrypted with .env key
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at" validate:"required"`
+}
+
+// Device types
+const (
+	DeviceTypeInverter       string = "inverter"
+	DeviceTypeString         string = "string" // MPPT tracker or string of modules
+	DeviceTypeMeter          string = "meter"  // Eg. revenue meter
+	DeviceTypeWeatherStation string = "weather_station"
+)
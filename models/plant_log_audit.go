@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Amendment of a reading by the plant owner, kept in a separate audit collection per plant
+// Before and After hold the state of the reading, so original values can always be restored
+type PlantLogAudit struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	ReadingID primitive.ObjectID `bson:"reading_id" json:"reading_id"`
+	Action    string             `bson:"action" json:"action"` // 'void', 'unvoid', 'annotate' or 'correct'
+	Reason    string             `bson:"reason" json:"reason"`
+	User      primitive.ObjectID `bson:"user" json:"user"` // User who amended the reading
+	Before    ReadingState       `bson:"before" json:"before"`
+	After     ReadingState       `bson:"after" json:"after"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// State of a reading subject to amendments
+type ReadingState struct {
+	Values     map[string]float64 `bson:"values" json:"values"` // Measurement values by channel name. Read from the legacy fields for readings stored before channel schemas existed
+	Voided     bool               `bson:"voided" json:"voided"`
+	Corrected  bool               `bson:"corrected" json:"corrected"`
+	Annotation string             `bson:"annotation" json:"annotation"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Claim of the sequence number (per device) or idempotency key of a reading, kept in a separate identity collection per plant.
+// Time-series collections don't support unique indexes, so unique indexes of the identity collection keep readings unique instead.
+// Claims are kept when readings are archived, so retries of archived readings are still recognized.
+type PlantLogIdentity struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	ReadingID      primitive.ObjectID `bson:"reading_id"`          // Reading claiming the sequence number or idempotency key
+	DeviceID       string             `bson:"device_id,omitempty"` // Scope of the sequence number
+	Sequence       *int64             `bson:"sequence,omitempty"`  // Either sequence number or idempotency key is set
+	IdempotencyKey string             `bson:"idempotency_key,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at"`
+}
@@ -6,18 +6,34 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// Simplified place holder model
-// More parameters can be added as per needs
-// In some cases it can make sense to break up the structure into more models/collections
+// Reading of a plant logger
+// Measurement values are stored generically by channel name, as defined by the channel schema of the plant's PlantLoggerConfig
 type PlantLogger struct {
-	ID                 primitive.ObjectID `bson:"_id"`
-	VoltageOutput      float64            `bson:"voltage_output" json:"voltage_output" validate:"required"`   // Unit: Volt (V), Symbol: Vdc
-	CurrentOutput      float64            `bson:"current_output" json:"current_output" validate:"required"`   // Unit: Ampere (A), Symbol: Idc
-	PowerOutput        float64            `bson:"power_output" json:"power_output" validate:"required"`       // Unit: Wattage (W), Symbol: Pdc
-	SolarRadiation     float64            `bson:"solar_radiation" json:"solar_radiation" validate:"required"` // Unit: W/m2, Symbol: G
-	AmbientTemperature float64            `bson:"t_ambient" json:"t_ambient" validate:"required"`             // Unit: °C, Symbol: Tamb
-	ModuleTemperature  float64            `bson:"t_module" json:"t_module" validate:"required"`               // Unit: °C, Symbol: Tmod
-	RelativeHumidity   float64            `bson:"rel_humidity" json:"rel_humidity" validate:"required"`       // Rel. humidity a measurement range of 0 to 100% RH
-	WindSpeed          float64            `bson:"wind_speed" json:"wind_speed" validate:"required"`           // Unit: m/s, Symbol: Sw
-	CreatedAt          time.Time          `bson:"created_at" json:"created_at" validate:"required"`
+	ID       primitive.ObjectID `bson:"_id"`
+	DeviceID string             `bson:"device_id,omitempty" json:"device_id,omitempty"` // Reporting device of the plant. Empty if reported for the plant as a whole
+	Values   map[string]float64 `bson:"values,omitempty" json:"values,omitempty"`       // Measurement values by channel name, eg. 'powerOutput'
+	// Legacy measurement fields of readings stored before channel schemas were introduced. Only read, new readings store their values in Values
+	VoltageOutput      float64    `bson:"voltage_output,omitempty" json:"voltage_output,omitempty"`   // Unit: Volt (V), Symbol: Vdc
+	CurrentOutput      float64    `bson:"current_output,omitempty" json:"current_output,omitempty"`   // Unit: Ampere (A), Symbol: Idc
+	PowerOutput        float64    `bson:"power_output,omitempty" json:"power_output,omitempty"`       // Unit: Wattage (W), Symbol: Pdc
+	SolarRadiation     float64    `bson:"solar_radiation,omitempty" json:"solar_radiation,omitempty"` // Unit: W/m2, Symbol: G
+	AmbientTemperature float64    `bson:"t_ambient,omitempty" json:"t_ambient,omitempty"`             // Unit: °C, Symbol: Tamb
+	ModuleTemperature  float64    `bson:"t_module,omitempty" json:"t_module,omitempty"`               // Unit: °C, Symbol: Tmod
+	RelativeHumidity   float64    `bson:"rel_humidity,omitempty" json:"rel_humidity,omitempty"`       // Rel. humidity a measurement range of 0 to 100% RH
+	WindSpeed          float64    `bson:"wind_speed,omitempty" json:"wind_speed,omitempty"`           // Unit: m/s, Symbol: Sw
+	CreatedAt          *time.Time `bson:"created_at,omitempty" json:"-"`                              // Time of receipt of readings stored before measurement times were introduced. They have no MeasuredAt
+	MeasuredAt         time.Time  `bson:"measured_at" json:"measured_at" validate:"required"`         // Time of measurement. Provided by the logger ('measuredAt') or, if missing or flagged, time of receipt
+	ReceivedAt         time.Time  `bson:"received_at" json:"received_at" validate:"required"`         // Time the reading arrived at the server
+	StoredAt           *time.Time `bson:"stored_at,omitempty" json:"stored_at,omitempty"`             // Time the reading was written to the database, later than ReceivedAt if spooled or imported. Rollups are updated by it
+	ReportedAt         *time.Time `bson:"reported_at,omitempty" json:"reported_at,omitempty"`         // Original measurement time provided by the logger, only kept if flagged by the clock skew policy
+	ClockSkewFlagged   bool       `bson:"clock_skew_flagged,omitempty" json:"clock_skew_flagged,omitempty"`
+	Sequence           *int64     `bson:"sequence,omitempty" json:"sequence,omitempty"`               // Optional sequence number provided by the logger. Unique per device (or plant without device) within the plant logger collection
+	IdempotencyKey     string     `bson:"idempotency_key,omitempty" json:"idempotency_key,omitempty"` // Optional idempotency key provided by the logger. Unique per plant logger collection
+	// Amendments by the plant owner. Original values are kept in the plant's audit collection, see PlantLogAudit
+	Voided     bool       `bson:"voided,omitempty" json:"voided,omitempty"`         // Voided readings are excluded from statistics unless requested otherwise
+	Corrected  bool       `bson:"corrected,omitempty" json:"corrected,omitempty"`   // Values have been corrected by the owner
+	Annotation string     `bson:"annotation,omitempty" json:"annotation,omitempty"` // Note of the owner, eg. 'Logger test by technician'
+	AmendedAt  *time.Time `bson:"amended_at,omitempty" json:"amended_at,omitempty"` // Time of the latest amendment. Rollups of amended readings are updated
+	// Readings restored from an archive file for analysis. Read-only, deleted again after RehydrationKeepDays
+	RehydratedAt *time.Time `bson:"rehydrated_at,omitempty" json:"rehydrated_at,omitempty"`
 }
@@ -14,8 +14,61 @@ type PlantLoggerConfig struct {
 	IntervalSec          int                `bson:"interval_sec" json:"interval_sec"  validate:"required" unique:"false"` // Important security feature prventing spamming ('rate limiting'). Time window for logging new data (document)
 	Key                  string             `bson:"key" json:"key"`
 	Secret               string             `bson:"secret" json:"secret"`
+	SigningKey           string             `bson:"signing_key" json:"signing_key"` // HMAC signing key derived from secret, encrypted with .env key. Needed to verify signed requests
+	AuthScheme           string             `bson:"auth_scheme" json:"auth_scheme"` // AuthSchemeSecret (default if empty), AuthSchemeHMAC or AuthSchemeBoth
 	URLID                string             `bson:"url_id" json:"url_id"`
 	CollectionNameLogger string             `bson:"collection_name_logger" json:"collection_name_logger" validate:"required" unique:"true"` // Logging of plant measurements is realized with a separate database collection for each plant
 	IPWhitelist          []string           `bson:"ip_whitelist" json:"ip_whitelist" unique:"false"`
+	Channels             []Channel          `bson:"channels" json:"channels" unique:"false"`                   // Channel schema. Measurements a reading is validated against. Configs without channels use the default channels
+	ClockSkewPolicy      ClockSkewPolicy    `bson:"clock_skew_policy" json:"clock_skew_policy" unique:"false"` // Handling of measurement times provided by the logger
+	PollTargets          []PollTarget       `bson:"poll_targets" json:"poll_targets" unique:"false"`           // SunSpec devices polled by the server via Modbus TCP. Empty if the plant only pushes readings
+	RetentionDays        int                `bson:"retention_days" json:"retention_days" unique:"false"`       // Readings measured longer ago are archived to the S3 bucket and deleted. 0 keeps readings forever
 	CreatedAt            time.Time          `bson:"created_at" json:"created_at" validate:"required"`
 }
+
+// Channel defines one measurement of a plant logger, eg. rear-side irradiance, AC power or current of one MPPT
+type Channel struct {
+	Name     string   `bson:"name" json:"name"`         // Request key and storage key of the measurement value, eg. 'rearIrradiance'
+	Unit     string   `bson:"unit" json:"unit"`         // Unit of measurement, eg. 'W/m2'
+	Required bool     `bson:"required" json:"required"` // Readings without value of a required channel are rejected
+	Min      *float64 `bson:"min,omitempty" json:"min,omitempty"`
+	Max      *float64 `bson:"max,omitempty" json:"max,omitempty"`
+}
+
+// PollTarget is a SunSpec compliant device the server reads measurements from via Modbus TCP in the plant's logging interval
+// Values of targets with the same device are merged into one reading, tagged with the device
+type PollTarget struct {
+	Host     string `bson:"host" json:"host"`                               // Host name or ip address. Must be reachable from the server, eg. via VPN
+	Port     int    `bson:"port" json:"port"`                               // Modbus TCP port, usually 502
+	UnitID   int    `bson:"unit_id" json:"unit_id"`                         // Modbus unit identifier of the device, 1 to 247
+	Model    int    `bson:"model" json:"model"`                             // SunSpec model read: SunSpecModelInverterSinglePhase, SunSpecModelInverterThreePhase or SunSpecModelMetStation
+	DeviceID string `bson:"device_id,omitempty" json:"device_id,omitempty"` // Device of the plant's device registry. Empty if reporting for the plant as a whole
+}
+
+// SunSpec models supported for polling
+const (
+	SunSpecModelInverterSinglePhase int = 101
+	SunSpecModelInverterThreePhase  int = 103
+	SunSpecModelMetStation          int = 307
+)
+
+// Authentication schemes of the plant logging API
+const (
+	AuthSchemeSecret string = "secret" // Legacy. Key and secret as part of request body
+	AuthSchemeHMAC   string = "hmac"   // Request signed with HMAC-SHA256, provided in headers
+	AuthSchemeBoth   string = "both"   // Both accepted, eg. while migrating loggers from secret to hmac
+)
+
+// Clock skew actions
+const (
+	ClockSkewActionReject string = "reject" // Reading is rejected
+	ClockSkewActionFlag   string = "flag"   // Reading is stored with time of receipt as measurement time and flagged
+)
+
+// ClockSkewPolicy defines how far the measurement time provided by a logger may lie in the future or past compared to the time of receipt
+// Configs without policy (Action empty) fall back to default values in base_config
+type ClockSkewPolicy struct {
+	MaxFutureSec int    `bson:"max_future_sec" json:"max_future_sec"`
+	MaxPastSec   int    `bson:"max_past_sec" json:"max_past_sec"`
+	Action       string `bson:"action" json:"action"` // ClockSkewActionReject or ClockSkewActionFlag
+}
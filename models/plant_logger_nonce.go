@@ -0,0 +1,11 @@
+package models
+
+import (
+	"time"
+)
+
+// Nonce of a signed plant logger request. Stored to prevent replays and removed by TTL index after LoggerNonceLifetimeSec
+type PlantLoggerNonce struct {
+	ID        string    `bson:"_id"` // Plant logger key and nonce. Unique per logger
+	CreatedAt time.Time `bson:"created_at" json:"created_at" validate:"required"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reading rejected by ingestion validation, kept for review in a separate quarantine collection per plant
+type PlantQuarantine struct {
+	ID         primitive.ObjectID     `bson:"_id"`
+	Reading    map[string]interface{} `bson:"reading" json:"reading"` // Reading as submitted by the logger, without credentials
+	Reason     string                 `bson:"reason" json:"reason"`
+	Source     string                 `bson:"source" json:"source"` // Route or channel the reading was submitted by, eg. 'log' or 'batch'
+	ReceivedAt time.Time              `bson:"received_at" json:"received_at" validate:"required"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// Aggregate of the readings of one device (or of readings reported for the plant as a whole) within one hour or day (UTC)
+// Kept in separate collections per plant and level, so statistics of long periods don't need to load each reading
+type PlantRollup struct {
+	ID          RollupKey                   `bson:"_id" json:"-"`
+	DeviceID    string                      `bson:"device_id,omitempty" json:"device_id,omitempty"`
+	PeriodStart time.Time                   `bson:"period_start" json:"period_start"`
+	Readings    int                         `bson:"readings" json:"readings"`                       // Number of readings, voided readings excluded
+	Channels    map[string]ChannelAggregate `bson:"channels" json:"channels"`                       // Aggregates by channel name
+	EnergyWh    *float64                    `bson:"energy_wh,omitempty" json:"energy_wh,omitempty"` // Energy from 'powerOutput' readings, each covering the logging interval. Unit: Wh
+	UpdatedAt   time.Time                   `bson:"updated_at" json:"updated_at"`
+}
+
+// Unique key of a rollup per collection
+type RollupKey struct {
+	DeviceID    string    `bson:"device_id,omitempty"`
+	PeriodStart time.Time `bson:"period_start"`
+}
+
+// Aggregate of the values of one channel. Sums of squared and cubed deviations from the mean allow exact variance and skewness of any number of rollups
+type ChannelAggregate struct {
+	Count int      `bson:"count" json:"count"`
+	Min   float64  `bson:"min" json:"min"`
+	Max   float64  `bson:"max" json:"max"`
+	Sum   float64  `bson:"sum" json:"sum"`
+	Mean  float64  `bson:"mean" json:"mean"`
+	M2    *float64 `bson:"m2,omitempty" json:"m2,omitempty"` // Sum of squared deviations from the mean. Nil for rollups stored before, see SumSquares
+	M3    *float64 `bson:"m3,omitempty" json:"m3,omitempty"` // Sum of cubed deviations from the mean
+	// Sums of squares and cubes of the values of rollups stored before deviations from the mean. Rollups of readings still stored are recomputed by migration
+	SumSquares float64 `bson:"sum_squares,omitempty" json:"-"`
+	SumCubes   float64 `bson:"sum_cubes,omitempty" json:"-"`
+}
+
+// Progress of the rollups of a plant logger collection
+type PlantRollupState struct {
+	ID        string    `bson:"_id"`        // Name of the plant logger collection
+	CheckedAt time.Time `bson:"checked_at"` // Start of the latest update. Readings stored or amended before are rolled up, apart from RollupReprocessWindowSec
+}
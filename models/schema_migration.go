@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Schema migration applied to the database, see services/migration
+type SchemaMigration struct {
+	Version    int       `bson:"_id" json:"version"`
+	Name       string    `bson:"name" json:"name"`
+	AppliedAt  time.Time `bson:"applied_at" json:"applied_at"`
+	DurationMs int64     `bson:"duration_ms" json:"duration_ms"`
+}
+
+// Lock of a migration run. The single document with id 'lock' exists while a server instance migrates
+type SchemaMigrationLock struct {
+	ID       string    `bson:"_id"`
+	Owner    string    `bson:"owner"` // Host name and process id of the server instance
+	LockedAt time.Time `bson:"locked_at"`
+}
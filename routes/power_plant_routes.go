@@ -3,6 +3,7 @@ package routes
 import (
 	plantcontroller "github.com/paulmuenzner/powerplantmanager/controllers/plants"
 	error "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	ingestpipeline "github.com/paulmuenzner/powerplantmanager/services/ingestPipeline"
 	v "github.com/paulmuenzner/powerplantmanager/services/routevalidation"
 	"github.com/paulmuenzner/powerplantmanager/utils/aws"
 	"github.com/paulmuenzner/powerplantmanager/utils/email"
@@ -12,16 +13,28 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func CreatePlantsSubrouter(awsInterface *aws.MethodInterface, emailInterface *email.RepositoryInterface, mongoDBInterface *mongodb.MethodInterface) *mux.Router {
+func CreatePlantsSubrouter(awsInterface *aws.MethodInterface, emailInterface *email.RepositoryInterface, mongoDBInterface *mongodb.MethodInterface, ingestPipeline *ingestpipeline.Pipeline, archiveBucketName string) *mux.Router {
 	plantRouter := mux.NewRouter()
 
 	// Sub-routes
 	plantRouter.HandleFunc("/add", v.AddPlantValidation(plantcontroller.AddPlant(mongoDBInterface), mongoDBInterface)).Methods("POST").Name("AddPlant")
-	plantRouter.HandleFunc("/log/{apiID:[0-9]+}", v.AddPlantLogValidation(plantcontroller.AddLogEntry(mongoDBInterface), mongoDBInterface)).Methods("POST").Name("AddLog")
+	plantRouter.HandleFunc("/log/{apiID:[0-9]+}", v.AddPlantLogValidation(plantcontroller.AddLogEntry(ingestPipeline), mongoDBInterface)).Methods("POST").Name("AddLog")
+	plantRouter.HandleFunc("/log/{apiID:[0-9]+}/batch", v.AddPlantLogBatchValidation(plantcontroller.AddLogBatch(mongoDBInterface), mongoDBInterface, ingestPipeline)).Methods("POST").Name("AddLogBatch")
+	plantRouter.HandleFunc("/import", v.ImportPlantLogsValidation(plantcontroller.ImportPlantLogs(mongoDBInterface), mongoDBInterface)).Methods("POST").Name("ImportPlantLogs")
 	plantRouter.HandleFunc("/setconfig", v.SetPlantConfigValidation(plantcontroller.SetPlantConfig(mongoDBInterface), mongoDBInterface)).Methods("PUT").Name("SetPlantConfig")
 	plantRouter.HandleFunc("/keysecret", v.SetKeySecretValidation(plantcontroller.SetKeySecret(mongoDBInterface), mongoDBInterface)).Methods("PUT").Name("SetKeySecret")
-	plantRouter.HandleFunc("/delete", v.DeletePlantValidation(plantcontroller.DeletePlant(mongoDBInterface), mongoDBInterface)).Methods("DELETE").Name("DeletePlant")
+	plantRouter.HandleFunc("/delete", v.DeletePlantValidation(plantcontroller.DeletePlant(mongoDBInterface, awsInterface, archiveBucketName), mongoDBInterface)).Methods("DELETE").Name("DeletePlant")
+	plantRouter.HandleFunc("/device/add", v.AddDeviceValidation(plantcontroller.AddDevice(mongoDBInterface), mongoDBInterface)).Methods("POST").Name("AddDevice")
+	plantRouter.HandleFunc("/device/keysecret", v.SetDeviceKeySecretValidation(plantcontroller.SetDeviceKeySecret(mongoDBInterface), mongoDBInterface)).Methods("PUT").Name("SetDeviceKeySecret")
+	plantRouter.HandleFunc("/device/delete", v.DeleteDeviceValidation(plantcontroller.DeleteDevice(mongoDBInterface), mongoDBInterface)).Methods("DELETE").Name("DeleteDevice")
+	plantRouter.HandleFunc("/devices", v.GetDevicesValidation(plantcontroller.GetDevices(mongoDBInterface), mongoDBInterface)).Methods("Get").Name("GetDevices")
 	plantRouter.HandleFunc("/statistics", v.GetPlantStatisticsValidation(plantcontroller.GetPlantStatistics(mongoDBInterface), mongoDBInterface)).Methods("Get").Name("GetStatistics")
+	plantRouter.HandleFunc("/gaps", v.GetPlantGapsValidation(plantcontroller.GetPlantGaps(mongoDBInterface), mongoDBInterface)).Methods("Get").Name("GetGaps")
+	plantRouter.HandleFunc("/readings/amend", v.AmendReadingsValidation(plantcontroller.AmendReadings(mongoDBInterface), mongoDBInterface)).Methods("PUT").Name("AmendReadings")
+	plantRouter.HandleFunc("/readings/export", v.ExportReadingsValidation(plantcontroller.ExportReadings(mongoDBInterface), mongoDBInterface)).Methods("Get").Name("ExportReadings")
+	plantRouter.HandleFunc("/readings/history", v.GetReadingHistoryValidation(plantcontroller.GetReadingHistory(mongoDBInterface), mongoDBInterface)).Methods("Get").Name("GetReadingHistory")
+	plantRouter.HandleFunc("/archives", v.GetArchivesValidation(plantcontroller.GetArchives(mongoDBInterface), mongoDBInterface)).Methods("Get").Name("GetArchives")
+	plantRouter.HandleFunc("/archives/rehydrate", v.RehydrateArchiveValidation(plantcontroller.RehydrateArchive(mongoDBInterface, awsInterface, archiveBucketName), mongoDBInterface)).Methods("POST").Name("RehydrateArchive")
 
 	// Set a custom NotFoundHandler
 	plantRouter.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,316 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	"github.com/paulmuenzner/powerplantmanager/services/rollup"
+	"github.com/paulmuenzner/powerplantmanager/utils/aws"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ObjectKey returns the key of an archive file of the plant logger collection collectionNameLogger with readings measured on day, partitioned by date.
+// The time of archiving keeps files of readings arriving late for an archived day apart.
+// Eg. 'archive/plant_logger_123456789012/year=2024/month=01/day=15/1709251200000000000.csv.gz'
+func ObjectKey(collectionNameLogger string, day, archivedAt time.Time) string {
+	day = day.UTC()
+	return fmt.Sprintf("%s/%s/year=%d/month=%02d/day=%02d/%d.csv.gz", config.ArchiveObjectKeyPrefix, collectionNameLogger, day.Year(), day.Month(), day.Day(), archivedAt.UnixNano())
+}
+
+// Report of the retention of one plant
+type Report struct {
+	PublicPlantID     string
+	Cutoff            time.Time            // Readings measured before are archived. Zero if the plant keeps readings forever
+	Archives          []model.PlantArchive // Archive files written
+	Readings          int                  // Readings archived and deleted
+	RehydratedDeleted int64                // Rehydrated readings deleted after RehydrationKeepDays
+	Pending           bool                 // Readings beyond the retention period remain, as they're not rolled up yet or RetentionDaysPerRun has been reached
+}
+
+// ArchivePlant writes the readings of a plant measured before its retention period to the S3 bucket, one gzip-compressed CSV file per day, and deletes them.
+// Readings are only archived once rolled up, so rollups and statistics of long periods keep covering them. Rehydrated readings expired are deleted.
+func ArchivePlant(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, awsInterface *aws.MethodInterface, bucketName string, plantLoggerConfig model.PlantLoggerConfig, now time.Time) (Report, error) {
+	collectionNameLogger := plantLoggerConfig.CollectionNameLogger
+	report := Report{PublicPlantID: plantLoggerConfig.PublicPlantID, Cutoff: loggerhandler.RetentionCutoff(plantLoggerConfig, now), Archives: []model.PlantArchive{}}
+
+	// Archive files are kept, so rehydrated readings are simply deleted
+	expired := now.UTC().AddDate(0, 0, -config.RehydrationKeepDays)
+	deleted, err := mongoDBInterface.RepositoryInterface.DeleteManyMongo(ctx, config.DatabaseNamePlantLogger, bson.M{"rehydrated_at": bson.M{"$lt": expired}}, collectionNameLogger)
+	if err != nil {
+		return report, fmt.Errorf("Error in 'ArchivePlant()' using 'DeleteManyMongo()' deleting rehydrated readings of collection '%s'. Error: %v", collectionNameLogger, err)
+	}
+	report.RehydratedDeleted = deleted
+
+	if report.Cutoff.IsZero() {
+		return report, nil
+	}
+
+	// Readings received late are rolled up again within RollupReprocessWindowSec, so they must be kept until then
+	state, rolledUp, err := rollup.FindState(ctx, mongoDBInterface, collectionNameLogger)
+	if err != nil {
+		return report, fmt.Errorf("Error in 'ArchivePlant()' using 'FindState()' for collection '%s'. Error: %v", collectionNameLogger, err)
+	}
+	cutoff := report.Cutoff
+	if !rolledUp {
+		report.Pending = true
+		return report, nil
+	}
+	if rolledUpUntil := rollup.LevelDay.Truncate(state.CheckedAt.Add(-time.Duration(config.RollupReprocessWindowSec) * time.Second)); rolledUpUntil.Before(cutoff) {
+		cutoff = rolledUpUntil
+		report.Pending = true
+	}
+
+	for days := 0; ; days++ {
+		var first model.PlantLogger
+		filter := loggerhandler.ExcludeRehydrated(measuredWithin(bson.M{"$lt": cutoff}))
+		found, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(ctx, config.DatabaseNamePlantLogger, filter, collectionNameLogger, bson.D{{Key: "measured_at", Value: 1}}, &first)
+		if err != nil {
+			return report, fmt.Errorf("Error in 'ArchivePlant()' using 'FindOneInMongo()' finding the oldest reading of collection '%s'. Error: %v", collectionNameLogger, err)
+		}
+		if !found {
+			return report, nil
+		}
+		if days == config.RetentionDaysPerRun {
+			report.Pending = true
+			return report, nil
+		}
+
+		archive, complete, err := archiveDay(ctx, mongoDBInterface, awsInterface, bucketName, collectionNameLogger, rollup.LevelDay.Truncate(measurementTime(first)), now)
+		if err != nil {
+			return report, err
+		}
+		report.Archives = append(report.Archives, archive)
+		report.Readings += archive.Readings
+		// Readings left of this day are archived by the next run, which names its files by a later time
+		if !complete {
+			report.Pending = true
+			return report, nil
+		}
+	}
+}
+
+// archiveDay writes the readings of collectionNameLogger measured on day to an archive file, records it and deletes the readings.
+// Readings are streamed twice, first to find the channels of the header, then to write the rows, so only the compressed file is held in memory.
+// Readings stored in between with a channel not in the header are left and complete is false.
+func archiveDay(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, awsInterface *aws.MethodInterface, bucketName, collectionNameLogger string, day, now time.Time) (archive model.PlantArchive, complete bool, err error) {
+	filter := loggerhandler.ExcludeRehydrated(measuredWithin(bson.M{"$gte": day, "$lt": day.Add(24 * time.Hour)}))
+
+	channelNames := []string{}
+	err = forEachReading(ctx, mongoDBInterface, collectionNameLogger, filter, mongodb.FindOptions{Projection: bson.M{"values": 1}, BatchSize: config.DatabaseCursorBatchSize}, func(reading model.PlantLogger) error {
+		channelNames = AddChannelNames(channelNames, reading)
+		return nil
+	})
+	if err != nil {
+		return archive, false, fmt.Errorf("Error in 'archiveDay()' using 'forEachReading()' finding the channels of collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+
+	var buffer bytes.Buffer
+	encoder, err := NewEncoder(&buffer, channelNames)
+	if err != nil {
+		return archive, false, fmt.Errorf("Error in 'archiveDay()' using 'NewEncoder()' for collection '%s'. Error: %v", collectionNameLogger, err)
+	}
+	complete = true
+	ids := []primitive.ObjectID{}
+	voided := 0
+	err = forEachReading(ctx, mongoDBInterface, collectionNameLogger, filter, mongodb.FindOptions{Sort: bson.D{{Key: "measured_at", Value: 1}}, BatchSize: config.DatabaseCursorBatchSize}, func(reading model.PlantLogger) error {
+		// Readings stored before measurement times were introduced were measured and received at 'created_at'
+		if reading.MeasuredAt.IsZero() && reading.CreatedAt != nil {
+			reading.MeasuredAt, reading.ReceivedAt = *reading.CreatedAt, *reading.CreatedAt
+		}
+		written, err := encoder.Write(reading)
+		if err != nil {
+			return err
+		}
+		if !written {
+			complete = false
+			return nil
+		}
+		ids = append(ids, reading.ID)
+		if reading.Voided {
+			voided++
+		}
+		return nil
+	})
+	if err != nil {
+		return archive, false, fmt.Errorf("Error in 'archiveDay()' using 'forEachReading()' encoding the readings of collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return archive, false, fmt.Errorf("Error in 'archiveDay()' using 'Close()' for collection '%s'. Error: %v", collectionNameLogger, err)
+	}
+
+	archive = model.PlantArchive{
+		ID:                   primitive.NewObjectID(),
+		CollectionNameLogger: collectionNameLogger,
+		Day:                  day,
+		ObjectKey:            ObjectKey(collectionNameLogger, day, now),
+		Readings:             len(ids),
+		VoidedReadings:       voided,
+		Bytes:                buffer.Len(),
+		ArchivedAt:           now.UTC(),
+	}
+
+	// Readings are only deleted once their archive file is written and recorded
+	if err := awsInterface.RepositoryInterfaceS3.UploadFile(ctx, bucketName, archive.ObjectKey, buffer.Bytes()); err != nil {
+		return archive, false, fmt.Errorf("Error in 'archiveDay()' using 'UploadFile()' for collection '%s'. Error: %v", collectionNameLogger, err)
+	}
+	if _, err := mongoDBInterface.RepositoryInterface.InsertOneToMongo(ctx, config.DatabaseNamePlantLogger, archive, config.CollectionNamePlantArchive); err != nil {
+		return archive, false, fmt.Errorf("Error in 'archiveDay()' using 'InsertOneToMongo()' recording archive file '%s'. Error: %v", archive.ObjectKey, err)
+	}
+	for start := 0; start < len(ids); start += int(config.DatabaseCursorBatchSize) {
+		batch := ids[start:min(start+int(config.DatabaseCursorBatchSize), len(ids))]
+		if _, err := mongoDBInterface.RepositoryInterface.DeleteManyMongo(ctx, config.DatabaseNamePlantLogger, bson.M{"_id": bson.M{"$in": batch}}, collectionNameLogger); err != nil {
+			return archive, false, fmt.Errorf("Error in 'archiveDay()' using 'DeleteManyMongo()' deleting archived readings of collection '%s'. Error: %v", collectionNameLogger, err)
+		}
+	}
+	return archive, complete, nil
+}
+
+// measuredWithin matches readings measured within timeRange. Readings stored before measurement times were introduced only carry 'created_at'
+func measuredWithin(timeRange bson.M) bson.M {
+	return bson.M{
+		"$or": bson.A{
+			bson.M{"measured_at": timeRange},
+			bson.M{"measured_at": bson.M{"$exists": false}, "created_at": timeRange},
+		},
+	}
+}
+
+// measurementTime returns the measurement time of a reading, 'created_at' for readings stored before measurement times were introduced
+func measurementTime(reading model.PlantLogger) time.Time {
+	if reading.MeasuredAt.IsZero() && reading.CreatedAt != nil {
+		return *reading.CreatedAt
+	}
+	return reading.MeasuredAt
+}
+
+// forEachReading streams the readings of collectionNameLogger matching filter to fn. Streaming stops at the first error of fn
+func forEachReading(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, filter bson.M, findOptions mongodb.FindOptions, fn func(reading model.PlantLogger) error) error {
+	cursor, err := mongoDBInterface.RepositoryInterface.FindCursorInMongo(ctx, config.DatabaseNamePlantLogger, filter, collectionNameLogger, findOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var reading model.PlantLogger
+		if err := cursor.Decode(&reading); err != nil {
+			return err
+		}
+		if err := fn(reading); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// FindArchives returns the archive files of the plant logger collection collectionNameLogger with readings measured between the days of start and end, oldest first
+func FindArchives(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, start, end time.Time) ([]model.PlantArchive, error) {
+	archives := []model.PlantArchive{}
+	filter := bson.M{"collection_name_logger": collectionNameLogger, "day": bson.M{"$gte": rollup.LevelDay.Truncate(start), "$lt": end}}
+	err := mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNamePlantLogger, filter, config.CollectionNamePlantArchive, bson.D{{Key: "day", Value: 1}, {Key: "archived_at", Value: 1}}, &archives)
+	if err != nil {
+		return nil, fmt.Errorf("Error in 'FindArchives()' using 'FindManyInMongo()' for collection '%s'. Error: %v", collectionNameLogger, err)
+	}
+	return archives, nil
+}
+
+// DeleteArchives deletes the archive files of the plant logger collection collectionNameLogger from the S3 bucket and their records, eg. after its plant
+// has been deleted. Records are deleted after their files, so files left by a failure are still found. Returns the number of archive files deleted.
+func DeleteArchives(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, awsInterface *aws.MethodInterface, bucketName, collectionNameLogger string) (int, error) {
+	var archives []model.PlantArchive
+	filter := bson.M{"collection_name_logger": collectionNameLogger}
+	if err := mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNamePlantLogger, filter, config.CollectionNamePlantArchive, bson.D{}, &archives); err != nil {
+		return 0, fmt.Errorf("Error in 'DeleteArchives()' using 'FindManyInMongo()' for collection '%s'. Error: %v", collectionNameLogger, err)
+	}
+	if len(archives) == 0 {
+		return 0, nil
+	}
+	// S3 deletes at most 1000 objects per request
+	for start := 0; start < len(archives); start += 1000 {
+		objectKeys := []string{}
+		for _, archive := range archives[start:min(start+1000, len(archives))] {
+			objectKeys = append(objectKeys, archive.ObjectKey)
+		}
+		if err := awsInterface.RepositoryInterfaceS3.DeleteObjects(ctx, bucketName, objectKeys); err != nil {
+			return 0, fmt.Errorf("Error in 'DeleteArchives()' using 'DeleteObjects()' for collection '%s'. Error: %v", collectionNameLogger, err)
+		}
+	}
+	if _, err := mongoDBInterface.RepositoryInterface.DeleteManyMongo(ctx, config.DatabaseNamePlantLogger, filter, config.CollectionNamePlantArchive); err != nil {
+		return 0, fmt.Errorf("Error in 'DeleteArchives()' using 'DeleteManyMongo()' for collection '%s'. Error: %v", collectionNameLogger, err)
+	}
+	return len(archives), nil
+}
+
+// Result of a rehydration
+type Rehydration struct {
+	Archives      int `json:"archives"`      // Archive files read
+	Rehydrated    int `json:"rehydrated"`    // Readings restored
+	AlreadyStored int `json:"alreadyStored"` // Readings stored already, eg. rehydrated before. Kept for another RehydrationKeepDays
+}
+
+// Rehydrate restores the readings of all archive files of the plant logger collection collectionNameLogger of the days between start and end for analysis.
+// Restored readings are flagged with 'rehydrated_at', read-only and deleted again after RehydrationKeepDays.
+func Rehydrate(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, awsInterface *aws.MethodInterface, bucketName, collectionNameLogger string, start, end time.Time, now time.Time) (Rehydration, error) {
+	rehydration := Rehydration{}
+	archives, err := FindArchives(ctx, mongoDBInterface, collectionNameLogger, start, end)
+	if err != nil || len(archives) == 0 {
+		return rehydration, err
+	}
+
+	// Readings of these days stored already, rehydrated or not yet deleted after archiving
+	days := bson.M{"$gte": archives[0].Day, "$lt": archives[len(archives)-1].Day.Add(24 * time.Hour)}
+	storedFilter := measuredWithin(days)
+	storedIDs := map[primitive.ObjectID]bool{}
+	err = forEachReading(ctx, mongoDBInterface, collectionNameLogger, storedFilter, mongodb.FindOptions{Projection: bson.M{"_id": 1}, BatchSize: config.DatabaseCursorBatchSize}, func(reading model.PlantLogger) error {
+		storedIDs[reading.ID] = true
+		return nil
+	})
+	if err != nil {
+		return rehydration, fmt.Errorf("Error in 'Rehydrate()' using 'forEachReading()' in collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+
+	for _, archive := range archives {
+		data, err := awsInterface.RepositoryInterfaceS3.DownloadFile(ctx, bucketName, archive.ObjectKey)
+		if err != nil {
+			return rehydration, fmt.Errorf("Error in 'Rehydrate()' using 'DownloadFile()' for archive file '%s'. Error: %v", archive.ObjectKey, err)
+		}
+		readings, err := Decode(data)
+		if err != nil {
+			return rehydration, fmt.Errorf("Error in 'Rehydrate()' using 'Decode()' for archive file '%s'. Error: %v", archive.ObjectKey, err)
+		}
+
+		documents := []interface{}{}
+		for _, reading := range readings {
+			if storedIDs[reading.ID] {
+				rehydration.AlreadyStored++
+				continue
+			}
+			storedIDs[reading.ID] = true
+			reading.RehydratedAt = &now
+			documents = append(documents, reading)
+		}
+		if len(documents) > 0 {
+			if _, err := mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, documents, collectionNameLogger); err != nil {
+				return rehydration, fmt.Errorf("Error in 'Rehydrate()' using 'InsertManyToMongo()' restoring archive file '%s'. Error: %v", archive.ObjectKey, err)
+			}
+		}
+		rehydration.Archives++
+		rehydration.Rehydrated += len(documents)
+	}
+
+	// Readings rehydrated before are kept as long as the latest ones
+	if rehydration.AlreadyStored > 0 {
+		_, err := mongoDBInterface.RepositoryInterface.UpdateManyInMongo(ctx, config.DatabaseNamePlantLogger, bson.M{"measured_at": days, "rehydrated_at": bson.M{"$exists": true}}, bson.M{"$set": bson.M{"rehydrated_at": now}}, collectionNameLogger)
+		if err != nil {
+			return rehydration, fmt.Errorf("Error in 'Rehydrate()' using 'UpdateManyInMongo()' in collection '%s'. Error: %v", collectionNameLogger, err)
+		}
+	}
+	return rehydration, nil
+}
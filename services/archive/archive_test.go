@@ -0,0 +1,150 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	awsMemory "github.com/paulmuenzner/powerplantmanager/utils/aws/memory"
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	"github.com/stretchr/testify/assert"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestObjectKey(t *testing.T) {
+	day := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	archivedAt := time.Unix(1709251200, 0)
+	assert.Equal(t, "archive/plant_logger_123456789012/year=2024/month=01/day=15/1709251200000000000.csv.gz", ObjectKey("plant_logger_123456789012", day, archivedAt))
+}
+
+func TestEncodeDecode(t *testing.T) {
+	measuredAt := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	reportedAt := measuredAt.Add(-2 * time.Hour)
+	sequence := int64(42)
+	readings := []model.PlantLogger{
+		{
+			ID:               primitive.NewObjectID(),
+			DeviceID:         "inverter-1",
+			Values:           map[string]float64{"powerOutput": 2500.5, "acFrequency": 50.01},
+			MeasuredAt:       measuredAt,
+			ReceivedAt:       measuredAt.Add(time.Second),
+			ReportedAt:       &reportedAt,
+			ClockSkewFlagged: true,
+			Sequence:         &sequence,
+			IdempotencyKey:   "key-1",
+			Voided:           true,
+			Annotation:       "Logger test, \"technician\"",
+		},
+		// Legacy reading, values are archived by channel name
+		{
+			ID:          primitive.NewObjectID(),
+			PowerOutput: 1200,
+			MeasuredAt:  measuredAt.Add(15 * time.Minute),
+			ReceivedAt:  measuredAt.Add(15 * time.Minute),
+		},
+	}
+
+	data, err := Encode(readings)
+	assert.NoError(t, err)
+	decoded, err := Decode(data)
+	assert.NoError(t, err)
+	assert.Len(t, decoded, 2)
+
+	assert.Equal(t, readings[0].ID, decoded[0].ID)
+	assert.Equal(t, readings[0].Values, decoded[0].Values)
+	assert.True(t, readings[0].MeasuredAt.Equal(decoded[0].MeasuredAt))
+	assert.True(t, reportedAt.Equal(*decoded[0].ReportedAt))
+	assert.Equal(t, sequence, *decoded[0].Sequence)
+	assert.Equal(t, "key-1", decoded[0].IdempotencyKey)
+	assert.True(t, decoded[0].Voided)
+	assert.True(t, decoded[0].ClockSkewFlagged)
+	assert.Equal(t, readings[0].Annotation, decoded[0].Annotation)
+	assert.Nil(t, decoded[0].AmendedAt)
+
+	assert.Equal(t, 1200.0, decoded[1].Values["powerOutput"])
+	assert.Equal(t, 0.0, decoded[1].Values["windSpeed"])
+	assert.NotContains(t, decoded[1].Values, "acFrequency")
+	assert.Nil(t, decoded[1].Sequence)
+}
+
+func TestEncoderSkipsUnknownChannels(t *testing.T) {
+	measuredAt := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	var buffer bytes.Buffer
+	encoder, err := NewEncoder(&buffer, []string{"powerOutput"})
+	assert.NoError(t, err)
+
+	written, err := encoder.Write(model.PlantLogger{ID: primitive.NewObjectID(), Values: map[string]float64{"powerOutput": 10}, MeasuredAt: measuredAt})
+	assert.NoError(t, err)
+	assert.True(t, written)
+	// Reading stored after the header was written, with a channel without column
+	written, err = encoder.Write(model.PlantLogger{ID: primitive.NewObjectID(), Values: map[string]float64{"acFrequency": 50}, MeasuredAt: measuredAt})
+	assert.NoError(t, err)
+	assert.False(t, written)
+	assert.NoError(t, encoder.Close())
+
+	decoded, err := Decode(buffer.Bytes())
+	assert.NoError(t, err)
+	assert.Len(t, decoded, 1)
+	assert.Equal(t, map[string]float64{"powerOutput": 10}, decoded[0].Values)
+}
+
+func TestDecodeRejectsUnknownFiles(t *testing.T) {
+	_, err := Decode([]byte("id,measured_at\n"))
+	assert.Error(t, err)
+}
+
+func TestArchivePlantLegacyReadingsAndDeleteArchives(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	awsInterface := awsMemory.NewMethodInterface()
+	repository := mongoDBInterface.RepositoryInterface
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	plantConfig := model.PlantLoggerConfig{PublicPlantID: "100", CollectionNameLogger: "plant_logger_190001", RetentionDays: 31}
+	_, err := repository.InsertOneToMongo(ctx, config.DatabaseNamePlantLogger, model.PlantRollupState{ID: plantConfig.CollectionNameLogger, CheckedAt: now}, config.CollectionNamePlantRollupState)
+	assert.NoError(t, err)
+
+	// Reading stored before measurement times existed and a voided reading, beyond the retention period, and a recent reading
+	createdAt := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	readings := []interface{}{
+		bson.M{"_id": primitive.NewObjectID(), "created_at": createdAt, "power_output": 400.0},
+		model.PlantLogger{ID: primitive.NewObjectID(), Values: map[string]float64{"powerOutput": 9000}, MeasuredAt: createdAt.Add(time.Hour), ReceivedAt: createdAt.Add(time.Hour), Voided: true},
+		model.PlantLogger{ID: primitive.NewObjectID(), Values: map[string]float64{"powerOutput": 410}, MeasuredAt: now.Add(-time.Hour), ReceivedAt: now.Add(-time.Hour)},
+	}
+	_, err = repository.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, readings, plantConfig.CollectionNameLogger)
+	assert.NoError(t, err)
+
+	report, err := ArchivePlant(ctx, mongoDBInterface, awsInterface, "bucket", plantConfig, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Readings)
+	assert.Len(t, report.Archives, 1)
+	assert.Equal(t, 1, report.Archives[0].VoidedReadings)
+	assert.True(t, report.Archives[0].Day.Equal(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)))
+	remaining, err := repository.CountDocumentsInMongo(ctx, config.DatabaseNamePlantLogger, plantConfig.CollectionNameLogger, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, remaining)
+
+	data, err := awsInterface.RepositoryInterfaceS3.DownloadFile(ctx, "bucket", report.Archives[0].ObjectKey)
+	assert.NoError(t, err)
+	decoded, err := Decode(data)
+	assert.NoError(t, err)
+	assert.Len(t, decoded, 2)
+	assert.True(t, decoded[0].MeasuredAt.Equal(createdAt))
+	assert.Equal(t, 400.0, decoded[0].Values["powerOutput"])
+	assert.True(t, decoded[1].Voided)
+
+	// Archive files are deleted with the plant
+	deleted, err := DeleteArchives(ctx, mongoDBInterface, awsInterface, "bucket", plantConfig.CollectionNameLogger)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	exists, err := awsInterface.RepositoryInterfaceS3.S3ObjectExists(ctx, report.Archives[0].ObjectKey, "bucket")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	archives, err := FindArchives(ctx, mongoDBInterface, plantConfig.CollectionNameLogger, createdAt, now)
+	assert.NoError(t, err)
+	assert.Empty(t, archives)
+}
@@ -0,0 +1,243 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Columns of archive files preceding the measurement values. Each value has a column of its channel name prefixed by valueColumnPrefix
+var columns = []string{"id", "device_id", "measured_at", "received_at", "reported_at", "clock_skew_flagged", "sequence", "idempotency_key", "voided", "corrected", "annotation", "amended_at"}
+
+const valueColumnPrefix = "values."
+
+// Encode writes readings as gzip-compressed CSV with a header row, see Encoder
+func Encode(readings []model.PlantLogger) ([]byte, error) {
+	channelNames := []string{}
+	for _, reading := range readings {
+		channelNames = AddChannelNames(channelNames, reading)
+	}
+
+	var buffer bytes.Buffer
+	encoder, err := NewEncoder(&buffer, channelNames)
+	if err != nil {
+		return nil, err
+	}
+	for _, reading := range readings {
+		if _, err := encoder.Write(reading); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// AddChannelNames adds the channels of reading missing in channelNames. Readings stored before channel schemas existed have the default channels
+func AddChannelNames(channelNames []string, reading model.PlantLogger) []string {
+	for channel := range loggerhandler.StateOfReading(reading).Values {
+		if !slices.Contains(channelNames, channel) {
+			channelNames = append(channelNames, channel)
+		}
+	}
+	return channelNames
+}
+
+// Encoder writes readings one at a time as gzip-compressed CSV with a header row, so readings can be streamed into an archive file.
+// The value columns are fixed by the header, values of readings stored before channel schemas existed are written by their channel names.
+// Empty cells stand for missing values and fields.
+type Encoder struct {
+	gzipWriter   *gzip.Writer
+	writer       *csv.Writer
+	channelNames []string
+}
+
+// NewEncoder writes the header row with a value column of each of channelNames to w
+func NewEncoder(w io.Writer, channelNames []string) (*Encoder, error) {
+	channelNames = slices.Clone(channelNames)
+	slices.Sort(channelNames)
+	gzipWriter := gzip.NewWriter(w)
+	encoder := &Encoder{gzipWriter: gzipWriter, writer: csv.NewWriter(gzipWriter), channelNames: channelNames}
+
+	header := append([]string{}, columns...)
+	for _, channel := range channelNames {
+		header = append(header, valueColumnPrefix+channel)
+	}
+	if err := encoder.writer.Write(header); err != nil {
+		return nil, err
+	}
+	return encoder, nil
+}
+
+// Write writes one reading. Readings with a channel without column aren't written and false is returned, as their values would be lost
+func (e *Encoder) Write(reading model.PlantLogger) (bool, error) {
+	values := loggerhandler.StateOfReading(reading).Values
+	for channel := range values {
+		if _, found := slices.BinarySearch(e.channelNames, channel); !found {
+			return false, nil
+		}
+	}
+
+	row := []string{
+		reading.ID.Hex(),
+		reading.DeviceID,
+		formatTime(&reading.MeasuredAt),
+		formatTime(&reading.ReceivedAt),
+		formatTime(reading.ReportedAt),
+		formatBool(reading.ClockSkewFlagged),
+		"",
+		reading.IdempotencyKey,
+		formatBool(reading.Voided),
+		formatBool(reading.Corrected),
+		reading.Annotation,
+		formatTime(reading.AmendedAt),
+	}
+	if reading.Sequence != nil {
+		row[6] = strconv.FormatInt(*reading.Sequence, 10)
+	}
+	for _, channel := range e.channelNames {
+		value, exists := values[channel]
+		if !exists {
+			row = append(row, "")
+			continue
+		}
+		row = append(row, strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	if err := e.writer.Write(row); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Close flushes the rows written and completes the gzip stream. It doesn't close the underlying writer
+func (e *Encoder) Close() error {
+	e.writer.Flush()
+	if err := e.writer.Error(); err != nil {
+		return err
+	}
+	return e.gzipWriter.Close()
+}
+
+// Decode reads the readings of an archive file written by Encode
+func Decode(data []byte) ([]model.PlantLogger, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Archive file isn't gzip-compressed. Error: %v", err)
+	}
+	defer gzipReader.Close()
+	reader := csv.NewReader(gzipReader)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Archive file has no header row. Error: %v", err)
+	}
+	if len(header) < len(columns) || !slices.Equal(header[:len(columns)], columns) {
+		return nil, fmt.Errorf("Archive file has unexpected columns: %s", strings.Join(header, ", "))
+	}
+
+	readings := []model.PlantLogger{}
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return readings, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Row %d of archive file unreadable. Error: %v", line, err)
+		}
+		reading, err := decodeRow(header, row)
+		if err != nil {
+			return nil, fmt.Errorf("Row %d of archive file invalid. Error: %v", line, err)
+		}
+		readings = append(readings, reading)
+	}
+}
+
+// decodeRow converts one row of an archive file into a reading
+func decodeRow(header, row []string) (model.PlantLogger, error) {
+	var reading model.PlantLogger
+	var err error
+	if reading.ID, err = primitive.ObjectIDFromHex(row[0]); err != nil {
+		return reading, err
+	}
+	reading.DeviceID = row[1]
+	measuredAt, err := parseTime(row[2])
+	if err != nil || measuredAt == nil {
+		return reading, fmt.Errorf("'measured_at' must be an RFC3339 time. Error: %v", err)
+	}
+	reading.MeasuredAt = *measuredAt
+	receivedAt, err := parseTime(row[3])
+	if err != nil {
+		return reading, err
+	}
+	if receivedAt != nil {
+		reading.ReceivedAt = *receivedAt
+	}
+	if reading.ReportedAt, err = parseTime(row[4]); err != nil {
+		return reading, err
+	}
+	reading.ClockSkewFlagged = row[5] == "true"
+	if row[6] != "" {
+		sequence, err := strconv.ParseInt(row[6], 10, 64)
+		if err != nil {
+			return reading, err
+		}
+		reading.Sequence = &sequence
+	}
+	reading.IdempotencyKey = row[7]
+	reading.Voided = row[8] == "true"
+	reading.Corrected = row[9] == "true"
+	reading.Annotation = row[10]
+	if reading.AmendedAt, err = parseTime(row[11]); err != nil {
+		return reading, err
+	}
+
+	reading.Values = map[string]float64{}
+	for index := len(columns); index < len(header); index++ {
+		if row[index] == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(row[index], 64)
+		if err != nil {
+			return reading, err
+		}
+		reading.Values[strings.TrimPrefix(header[index], valueColumnPrefix)] = value
+	}
+	return reading, nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(cell string) (*time.Time, error) {
+	if cell == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, cell)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "true"
+	}
+	return ""
+}
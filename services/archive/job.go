@@ -0,0 +1,111 @@
+package archive
+
+import (
+	"context"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/services/lease"
+	"github.com/paulmuenzner/powerplantmanager/utils/aws"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Name of the lease of the retention job
+const leaseName = "retention"
+
+// Job archives readings beyond the retention period of each plant every RetentionJobIntervalSec and reports what it archived to the log.
+// Archive files are recorded in the plant archive collection, listed per plant by route '/plants/archives'.
+// Each server instance runs the job, but only the instance holding its lease archives, so plants aren't archived twice concurrently.
+type Job struct {
+	mongoDBInterface *mongodb.MethodInterface
+	awsInterface     *aws.MethodInterface
+	bucketName       string
+	owner            string // Owner of the lease, see lease.Owner
+	stop             chan struct{}
+	done             chan struct{}
+}
+
+// NewJob creates a retention job archiving to bucketName. Call Start to run it in the background.
+func NewJob(mongoDBInterface *mongodb.MethodInterface, awsInterface *aws.MethodInterface, bucketName string) *Job {
+	return &Job{mongoDBInterface: mongoDBInterface, awsInterface: awsInterface, bucketName: bucketName, owner: lease.Owner()}
+}
+
+// Start runs the job in the background, beginning with a run right away
+func (job *Job) Start() {
+	job.stop = make(chan struct{})
+	job.done = make(chan struct{})
+	go job.run()
+}
+
+// Stop stops the job, waiting for an archive file in process
+func (job *Job) Stop() {
+	close(job.stop)
+	<-job.done
+}
+
+// run archives readings of all plants until stopped
+func (job *Job) run() {
+	defer close(job.done)
+	ctx := context.Background() // Runs in process are finished on Stop, not canceled
+	ticker := time.NewTicker(time.Duration(config.RetentionJobIntervalSec) * time.Second)
+	defer ticker.Stop()
+	job.archiveAll(ctx, time.Now())
+	for {
+		select {
+		case <-job.stop:
+			return
+		case now := <-ticker.C:
+			job.archiveAll(ctx, now)
+		}
+	}
+}
+
+// archiveAll archives readings of each plant if this instance holds the lease of the job. The lease is renewed before each plant and released after the run.
+// Plants failing are retried with the next run
+func (job *Job) archiveAll(ctx context.Context, now time.Time) {
+	if !job.renewLease(ctx, now) {
+		return
+	}
+	defer func() {
+		if err := lease.Release(ctx, job.mongoDBInterface, leaseName, job.owner); err != nil {
+			logger.GetLogger().Errorf("Error in 'archiveAll()' using 'Release()'. The lease expires after %d seconds. Error: %v", config.RetentionJobLeaseSec, err)
+		}
+	}()
+
+	var plantConfigs []model.PlantLoggerConfig
+	err := job.mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNamePlantLoggerConfig, bson.M{}, config.CollectionNamePlantLoggerConfig, bson.D{}, &plantConfigs)
+	if err != nil {
+		logger.GetLogger().Errorf("Error in 'archiveAll()' using 'FindManyInMongo()' in collection '%s' part of database '%s'. Error: %v", config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, err)
+		return
+	}
+	for _, plantConfig := range plantConfigs {
+		select {
+		case <-job.stop:
+			return
+		default:
+		}
+		if !job.renewLease(ctx, time.Now()) {
+			return
+		}
+		report, err := ArchivePlant(ctx, job.mongoDBInterface, job.awsInterface, job.bucketName, plantConfig, now)
+		if len(report.Archives) > 0 || report.RehydratedDeleted > 0 {
+			logger.GetLogger().Infof("Retention of plant '%s': %d readings measured before %s archived to %d files, %d rehydrated readings deleted. Readings pending: %t", report.PublicPlantID, report.Readings, report.Cutoff.Format(time.DateOnly), len(report.Archives), report.RehydratedDeleted, report.Pending)
+		}
+		if err != nil {
+			logger.GetLogger().Errorf("Archiving readings of plant '%s' failed in 'archiveAll()'. Error: %v", plantConfig.PublicPlantID, err)
+		}
+	}
+}
+
+// renewLease acquires or renews the lease of the job until RetentionJobLeaseSec after now. Returns false if held by another instance or failing
+func (job *Job) renewLease(ctx context.Context, now time.Time) bool {
+	acquired, err := lease.Acquire(ctx, job.mongoDBInterface, leaseName, job.owner, time.Duration(config.RetentionJobLeaseSec)*time.Second, now)
+	if err != nil {
+		logger.GetLogger().Errorf("Error in 'renewLease()' using 'Acquire()'. Readings are archived by the next run. Error: %v", err)
+	}
+	return acquired
+}
@@ -0,0 +1,20 @@
+package errorhandler
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StatusOf returns the status of the response to a request failed by err: GatewayTimeout if an operation exceeded its deadline,
+// ServiceUnavailable if the request has been canceled, eg. by the client disconnecting, and status otherwise
+func StatusOf(err error, status CustomStatus) CustomStatus {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err):
+		return GatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return ServiceUnavailable
+	}
+	return status
+}
@@ -0,0 +1,17 @@
+package errorhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusOf(t *testing.T) {
+	wrapped := fmt.Errorf("Error in 'FindOneInMongo()'. Error: %w", context.DeadlineExceeded)
+	assert.Equal(t, GatewayTimeout, StatusOf(wrapped, InternalServerError))
+	assert.Equal(t, ServiceUnavailable, StatusOf(context.Canceled, InternalServerError))
+	assert.Equal(t, InternalServerError, StatusOf(errors.New("failed"), InternalServerError))
+}
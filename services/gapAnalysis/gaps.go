@@ -0,0 +1,171 @@
+package gapanalysis
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/utils/solar"
+)
+
+// Gap is a period without readings of at least one logging interval
+type Gap struct {
+	Start           time.Time `json:"start"` // Previous reading or begin of daylight
+	End             time.Time `json:"end"`   // Next reading or end of daylight
+	MissingReadings int       `json:"missingReadings"`
+}
+
+// DayCompleteness is the completeness of the readings of one day. Days are local days by mean solar time of the plant's longitude, UTC days without coordinates.
+// CompletenessPercent is nil if no readings are expected, eg. polar night.
+type DayCompleteness struct {
+	Date                string     `json:"date"`
+	Sunrise             *time.Time `json:"sunrise,omitempty"`
+	Sunset              *time.Time `json:"sunset,omitempty"`
+	ExpectedReadings    int        `json:"expectedReadings"`
+	MissingReadings     int        `json:"missingReadings"`
+	CompletenessPercent *float64   `json:"completenessPercent"`
+}
+
+// Report is the gap analysis of a period. Gaps lists the first GapsReportedMax gaps, GapsTotal counts all
+type Report struct {
+	IntervalSec         int               `json:"intervalSec"`
+	NightExcluded       bool              `json:"nightExcluded"` // False without plant coordinates
+	ExpectedReadings    int               `json:"expectedReadings"`
+	MissingReadings     int               `json:"missingReadings"`
+	CompletenessPercent *float64          `json:"completenessPercent"`
+	Days                []DayCompleteness `json:"days"`
+	Gaps                []Gap             `json:"gaps"`
+	GapsTotal           int               `json:"gapsTotal"`
+}
+
+// HasCoordinates returns false for plants without coordinates. Latitude and longitude 0 lie in the ocean and count as not provided
+func HasCoordinates(coordinates model.Coordinates) bool {
+	return coordinates.Latitude != 0 || coordinates.Longitude != 0
+}
+
+// Analyze finds gaps in the measurement times of readings between start and end, expecting one reading per intervalSec.
+// With coordinates, readings are only expected between sunrise and sunset, shortened by GapDaylightMarginSec at both ends, as loggers may sleep at night.
+// A gap is a period between consecutive readings, or between begin or end of daylight and the next or previous reading, longer than GapToleranceFactor intervals.
+func Analyze(measuredAt []time.Time, intervalSec int, coordinates model.Coordinates, start, end time.Time) Report {
+	sorted := append([]time.Time{}, measuredAt...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	interval := time.Duration(intervalSec) * time.Second
+	nightExcluded := HasCoordinates(coordinates)
+
+	// Days by mean solar time. UTC without coordinates
+	offset := time.Duration(0)
+	if nightExcluded {
+		offset = time.Duration(coordinates.Longitude / 15 * float64(time.Hour)).Round(time.Minute)
+	}
+	localStart := start.UTC().Add(offset)
+	day := time.Date(localStart.Year(), localStart.Month(), localStart.Day(), 0, 0, 0, 0, time.UTC)
+
+	report := Report{IntervalSec: intervalSec, NightExcluded: nightExcluded, Days: []DayCompleteness{}, Gaps: []Gap{}}
+	if intervalSec <= 0 {
+		return report
+	}
+	for ; day.Add(-offset).Before(end); day = day.AddDate(0, 0, 1) {
+		dayStart, dayEnd := day.Add(-offset), day.AddDate(0, 0, 1).Add(-offset)
+		dayCompleteness := DayCompleteness{Date: day.Format("2006-01-02")}
+
+		windowStart, windowEnd := dayStart, dayEnd
+		if nightExcluded {
+			sunrise, sunset, daylight := solar.SunriseSunset(day, coordinates.Latitude, coordinates.Longitude)
+			switch daylight {
+			case solar.DaylightNormal:
+				dayCompleteness.Sunrise, dayCompleteness.Sunset = &sunrise, &sunset
+				margin := time.Duration(config.GapDaylightMarginSec) * time.Second
+				windowStart, windowEnd = sunrise.Add(margin), sunset.Add(-margin)
+			case solar.DaylightPolarNight:
+				windowEnd = windowStart
+			}
+		}
+		if windowStart.Before(start) {
+			windowStart = start
+		}
+		if windowEnd.After(end) {
+			windowEnd = end
+		}
+
+		if windowStart.Before(windowEnd) {
+			expected := int(windowEnd.Sub(windowStart) / interval)
+			missing := 0
+			for _, gap := range windowGaps(sorted, windowStart, windowEnd, interval) {
+				missing += gap.MissingReadings
+				report.GapsTotal++
+				if len(report.Gaps) < config.GapsReportedMax {
+					report.Gaps = append(report.Gaps, gap)
+				}
+			}
+			dayCompleteness.ExpectedReadings = expected
+			dayCompleteness.MissingReadings = min(missing, expected)
+		}
+		dayCompleteness.CompletenessPercent = Completeness(dayCompleteness.ExpectedReadings, dayCompleteness.MissingReadings)
+
+		report.ExpectedReadings += dayCompleteness.ExpectedReadings
+		report.MissingReadings += dayCompleteness.MissingReadings
+		report.Days = append(report.Days, dayCompleteness)
+	}
+	report.CompletenessPercent = Completeness(report.ExpectedReadings, report.MissingReadings)
+	return report
+}
+
+// windowGaps returns the gaps of sorted measurement times within [windowStart, windowEnd)
+func windowGaps(sorted []time.Time, windowStart, windowEnd time.Time, interval time.Duration) []Gap {
+	tolerance := time.Duration(config.GapToleranceFactor * float64(interval))
+	first := sort.Search(len(sorted), func(i int) bool { return !sorted[i].Before(windowStart) })
+	last := sort.Search(len(sorted), func(i int) bool { return !sorted[i].Before(windowEnd) })
+	readings := sorted[first:last]
+
+	if len(readings) == 0 {
+		if missing := int(windowEnd.Sub(windowStart) / interval); missing > 0 {
+			return []Gap{{Start: windowStart, End: windowEnd, MissingReadings: missing}}
+		}
+		return nil
+	}
+
+	gaps := []Gap{}
+	// Begin and end of daylight expect a reading within one interval
+	if readings[0].Sub(windowStart) > tolerance {
+		gaps = append(gaps, Gap{Start: windowStart, End: readings[0], MissingReadings: int(readings[0].Sub(windowStart) / interval)})
+	}
+	for i := 1; i < len(readings); i++ {
+		if difference := readings[i].Sub(readings[i-1]); difference > tolerance {
+			missing := int(math.Round(float64(difference)/float64(interval))) - 1
+			gaps = append(gaps, Gap{Start: readings[i-1], End: readings[i], MissingReadings: missing})
+		}
+	}
+	// The window end is exclusive, so a reading is expected before it
+	if lastReading := readings[len(readings)-1]; windowEnd.Sub(lastReading) > tolerance {
+		missing := int(math.Ceil(float64(windowEnd.Sub(lastReading))/float64(interval))) - 1
+		gaps = append(gaps, Gap{Start: lastReading, End: windowEnd, MissingReadings: missing})
+	}
+	return gaps
+}
+
+// Completeness returns the percentage of expected readings not missing, rounded to two decimals. Nil if no readings are expected
+func Completeness(expected, missing int) *float64 {
+	if expected <= 0 {
+		return nil
+	}
+	percent := math.Round(float64(expected-missing)/float64(expected)*10000) / 100
+	return &percent
+}
+
+// ParseCoordinates converts coordinates of the parsed request body, an object with 'latitude' and 'longitude' in degrees
+func ParseCoordinates(raw interface{}) (model.Coordinates, error) {
+	errInvalid := errors.New("'coordinates' must be an object with 'latitude' (-90 to 90) and 'longitude' (-180 to 180) in degrees.")
+	rawCoordinates, ok := raw.(map[string]interface{})
+	if !ok || len(rawCoordinates) != 2 {
+		return model.Coordinates{}, errInvalid
+	}
+	latitude, latitudeValid := rawCoordinates["latitude"].(float64)
+	longitude, longitudeValid := rawCoordinates["longitude"].(float64)
+	if !latitudeValid || !longitudeValid || math.Abs(latitude) > 90 || math.Abs(longitude) > 180 {
+		return model.Coordinates{}, errInvalid
+	}
+	return model.Coordinates{Latitude: latitude, Longitude: longitude}, nil
+}
@@ -0,0 +1,117 @@
+package gapanalysis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	"github.com/stretchr/testify/assert"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// readings returns measurement times every interval from start to end (exclusive), with jitter of a few seconds
+func readings(start, end time.Time, interval time.Duration) []time.Time {
+	measuredAt := []time.Time{}
+	for i, current := 0, start; current.Before(end); i, current = i+1, current.Add(interval) {
+		measuredAt = append(measuredAt, current.Add(time.Duration(i%3)*time.Second))
+	}
+	return measuredAt
+}
+
+func TestAnalyzeWithoutCoordinates(t *testing.T) {
+	start := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	end := start.Add(48 * time.Hour)
+	measuredAt := append(readings(start, start.Add(10*time.Hour), 15*time.Minute), readings(start.Add(12*time.Hour), end, 15*time.Minute)...)
+
+	report := Analyze(measuredAt, 900, model.Coordinates{}, start, end)
+	assert.False(t, report.NightExcluded)
+	assert.Equal(t, 192, report.ExpectedReadings)
+	assert.Equal(t, 8, report.MissingReadings)
+	assert.Equal(t, []Gap{{Start: start.Add(10*time.Hour - 15*time.Minute), End: start.Add(12 * time.Hour), MissingReadings: 8}}, report.Gaps)
+	assert.Len(t, report.Days, 2)
+	assert.Equal(t, "2024-03-10", report.Days[0].Date)
+	assert.Equal(t, 91.67, *report.Days[0].CompletenessPercent)
+	assert.Equal(t, 100.0, *report.Days[1].CompletenessPercent)
+}
+
+func TestAnalyzeExcludesNight(t *testing.T) {
+	// Berlin. Readings from sunrise to sunset only, as loggers sleep at night
+	coordinates := model.Coordinates{Latitude: 52.52, Longitude: 13.405}
+	start := time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	measuredAt := readings(time.Date(2024, 6, 21, 2, 50, 0, 0, time.UTC), time.Date(2024, 6, 21, 19, 30, 0, 0, time.UTC), 5*time.Minute)
+
+	report := Analyze(measuredAt, 300, coordinates, start, end)
+	assert.True(t, report.NightExcluded)
+	assert.Empty(t, report.Gaps)
+	assert.Equal(t, 100.0, *report.CompletenessPercent)
+	assert.NotNil(t, report.Days[0].Sunrise)
+
+	// Without readings in the afternoon
+	report = Analyze(measuredAt[:len(measuredAt)/2], 300, coordinates, start, end)
+	assert.Len(t, report.Gaps, 1)
+	assert.InDelta(t, 50, *report.CompletenessPercent, 2)
+}
+
+func TestAnalyzePolarNight(t *testing.T) {
+	start := time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC)
+	report := Analyze(nil, 900, model.Coordinates{Latitude: 78.22, Longitude: 15.65}, start, start.Add(24*time.Hour))
+	assert.Equal(t, 0, report.ExpectedReadings)
+	assert.Nil(t, report.CompletenessPercent)
+	assert.Empty(t, report.Gaps)
+}
+
+func TestAnalyzeLimitsGapsReported(t *testing.T) {
+	// A reading every other interval
+	start := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Duration(config.GapsReportedMax+10) * 2 * time.Minute)
+	report := Analyze(readings(start, end, 2*time.Minute), 60, model.Coordinates{}, start, end)
+	assert.Len(t, report.Gaps, config.GapsReportedMax)
+	assert.Greater(t, report.GapsTotal, config.GapsReportedMax)
+}
+
+func TestParseCoordinates(t *testing.T) {
+	coordinates, err := ParseCoordinates(map[string]interface{}{"latitude": 52.52, "longitude": 13.405})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Coordinates{Latitude: 52.52, Longitude: 13.405}, coordinates)
+
+	for _, raw := range []interface{}{
+		map[string]interface{}{"latitude": 91.0, "longitude": 13.405},
+		map[string]interface{}{"latitude": 52.52, "longitude": -181.0},
+		map[string]interface{}{"latitude": "52.52", "longitude": 13.405},
+		map[string]interface{}{"latitude": 52.52},
+		[]interface{}{52.52, 13.405},
+	} {
+		_, err := ParseCoordinates(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestFindMeasurementTimesExcludesVoided(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	collectionNameLogger := "plant_logger_150001"
+	start := time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)
+	readings := []interface{}{
+		model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inverter-1", MeasuredAt: start},
+		model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inverter-1", MeasuredAt: start.Add(15 * time.Minute), Voided: true},
+		// Stored before measurement times existed
+		bson.M{"_id": primitive.NewObjectID(), "device_id": "inverter-1", "created_at": start.Add(30 * time.Minute)},
+		model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inverter-2", MeasuredAt: start.Add(45 * time.Minute)},
+	}
+	_, err := mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, readings, collectionNameLogger)
+	assert.NoError(t, err)
+
+	measuredAt, err := FindMeasurementTimes(ctx, mongoDBInterface, collectionNameLogger, "inverter-1", start, start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []time.Time{start, start.Add(30 * time.Minute)}, measuredAt)
+
+	// The voided reading counts as missing
+	report := Analyze(measuredAt, 900, model.Coordinates{}, start, start.Add(time.Hour))
+	assert.Equal(t, 2, report.MissingReadings)
+}
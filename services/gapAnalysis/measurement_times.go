@@ -0,0 +1,66 @@
+package gapanalysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrTooManyReadings is returned if a period holds more than GapAnalysisMaxReadings readings, as their measurement times are held in memory
+var ErrTooManyReadings = errors.New("too many readings in period")
+
+// measurementTime holds the time of a stored reading. Readings stored before measurement times were introduced only carry 'created_at'
+type measurementTime struct {
+	MeasuredAt time.Time `bson:"measured_at"`
+	CreatedAt  time.Time `bson:"created_at"`
+}
+
+// FindMeasurementTimes returns the measurement times of the readings in a plant logger collection between start and end.
+// Readings of one device only if deviceID isn't empty, otherwise readings of all devices count for the plant. Readings voided by the owner are missing.
+// The times are held in memory, so at most GapAnalysisMaxReadings readings are read. Returns ErrTooManyReadings beyond.
+func FindMeasurementTimes(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger, deviceID string, start, end time.Time) ([]time.Time, error) {
+	timeRange := bson.M{"$gte": start, "$lt": end}
+	filter := loggerhandler.ExcludeVoided(bson.M{
+		"$or": bson.A{
+			bson.M{"measured_at": timeRange},
+			bson.M{"measured_at": bson.M{"$exists": false}, "created_at": timeRange},
+		},
+	})
+	if deviceID != "" {
+		filter["device_id"] = deviceID
+	}
+
+	// Only the times are fetched, one batch at a time
+	findOptions := mongodb.FindOptions{Projection: bson.M{"measured_at": 1, "created_at": 1}, BatchSize: config.DatabaseCursorBatchSize}
+	cursor, err := mongoDBInterface.RepositoryInterface.FindCursorInMongo(ctx, config.DatabaseNamePlantLogger, filter, collectionNameLogger, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("Error in 'FindMeasurementTimes()' using 'FindCursorInMongo()' in collection '%s' part of database '%s'. Error: %w", collectionNameLogger, config.DatabaseNamePlantLogger, err)
+	}
+	defer cursor.Close(ctx)
+
+	measuredAt := []time.Time{}
+	for cursor.Next(ctx) {
+		var reading measurementTime
+		if err := cursor.Decode(&reading); err != nil {
+			return nil, fmt.Errorf("Error in 'FindMeasurementTimes()' using 'Decode()' in collection '%s'. Error: %v", collectionNameLogger, err)
+		}
+		if len(measuredAt) == config.GapAnalysisMaxReadings {
+			return nil, fmt.Errorf("Error in 'FindMeasurementTimes()' in collection '%s', more than %d readings. Error: %w", collectionNameLogger, config.GapAnalysisMaxReadings, ErrTooManyReadings)
+		}
+		if reading.MeasuredAt.IsZero() {
+			reading.MeasuredAt = reading.CreatedAt
+		}
+		measuredAt = append(measuredAt, reading.MeasuredAt)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("Error in 'FindMeasurementTimes()' using 'Next()' in collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+	return measuredAt, nil
+}
@@ -0,0 +1,268 @@
+package importhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RowError reports why the row at Line of the import file has not been imported
+type RowError struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// ImportReport is the outcome of an import. Rows already stored (same measurement time and device) are skipped as duplicates,
+// so a failed or partial import can be repeated with the same file.
+type ImportReport struct {
+	RowsRead        int        `json:"rowsRead"`
+	RowsImported    int        `json:"rowsImported"`
+	RowsDuplicate   int        `json:"rowsDuplicate"`
+	RowsRejected    int        `json:"rowsRejected"`
+	Errors          []RowError `json:"errors"`
+	ErrorsTruncated bool       `json:"errorsTruncated"` // More than ImportReportErrorsMax rows have been rejected
+}
+
+func (report *ImportReport) reject(line int, reason string) {
+	report.RowsRejected++
+	if len(report.Errors) >= config.ImportReportErrorsMax {
+		report.ErrorsTruncated = true
+		return
+	}
+	report.Errors = append(report.Errors, RowError{Line: line, Reason: reason})
+}
+
+// importRow is a parsed row waiting for its bulk insert
+type importRow struct {
+	line int
+	log  model.PlantLogger
+}
+
+// Import reads all rows of an import file, validates them against the plant's channel schema and stores valid rows in bulk into the plant logger collection.
+// Rows are not subject to the plant's logging interval and clock skew policy for past measurement times, as they are historical.
+// Returns an error only if the import cannot be continued, eg. the file cannot be read or the database fails. The report then covers the rows processed so far.
+func Import(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, plantConfig model.PlantLoggerConfig, options ImportOptions, rows RowReader, receivedAt time.Time) (ImportReport, error) {
+	report := ImportReport{Errors: []RowError{}}
+	channels := loggerhandler.EffectiveChannels(plantConfig)
+	seen := map[time.Time]bool{} // Measurement times within the file
+	batch := make([]importRow, 0, config.ImportBatchSize)
+
+	for {
+		row, line, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, ErrRow) {
+			report.RowsRead++
+			report.reject(line, err.Error())
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("Error in 'Import()' reading line %d of import file. Error: %w", line, err)
+		}
+		report.RowsRead++
+
+		plantLog, err := ParseRow(row, options, channels, plantConfig.ClockSkewPolicy, receivedAt)
+		if err != nil {
+			report.reject(line, err.Error())
+			continue
+		}
+		if seen[plantLog.MeasuredAt] {
+			report.reject(line, "Measurement time occurs more than once in file.")
+			continue
+		}
+		seen[plantLog.MeasuredAt] = true
+
+		batch = append(batch, importRow{line: line, log: plantLog})
+		if len(batch) == config.ImportBatchSize {
+			if err := storeBatch(ctx, mongoDBInterface, plantConfig.CollectionNameLogger, options.DeviceID, batch, &report); err != nil {
+				return report, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := storeBatch(ctx, mongoDBInterface, plantConfig.CollectionNameLogger, options.DeviceID, batch, &report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// ParseRow converts a row of an import file into a plant log. Values are validated against the channel schema and plausibility rules like pushed readings.
+// Measurement times further in the future than tolerated by the clock skew policy are rejected. The returned error is suitable for the report.
+func ParseRow(row map[string]interface{}, options ImportOptions, channels []model.Channel, policy model.ClockSkewPolicy, receivedAt time.Time) (model.PlantLogger, error) {
+	rawTimestamp, exists := row[options.TimestampColumn]
+	if !exists {
+		return model.PlantLogger{}, fmt.Errorf("Missing timestamp column '%s'.", options.TimestampColumn)
+	}
+	measuredAt, err := parseTimestamp(rawTimestamp, options)
+	if err != nil {
+		return model.PlantLogger{}, err
+	}
+	maxFuture := time.Second * time.Duration(loggerhandler.EffectiveClockSkewPolicy(policy).MaxFutureSec)
+	if measuredAt.After(receivedAt.Add(maxFuture)) {
+		return model.PlantLogger{}, errors.New("Measurement time lies in the future.")
+	}
+
+	// Empty cells of optional channels count as missing values
+	data := map[string]interface{}{}
+	for channelName, column := range options.Mapping {
+		rawValue, exists := row[column]
+		if !exists {
+			return model.PlantLogger{}, fmt.Errorf("Missing column '%s' of channel '%s'.", column, channelName)
+		}
+		value, present, err := parseValue(rawValue, options.DecimalSeparator)
+		if err != nil {
+			return model.PlantLogger{}, fmt.Errorf("Value '%v' of column '%s' is not a number.", rawValue, column)
+		}
+		if present {
+			data[channelName] = value
+		}
+	}
+
+	plantLog, err := loggerhandler.ParseMeasurements(data, channels)
+	if err != nil {
+		return model.PlantLogger{}, err
+	}
+	plantLog.DeviceID = options.DeviceID
+	plantLog.MeasuredAt = measuredAt.UTC().Truncate(time.Millisecond) // Precision of stored dates, needed to recognize duplicates
+	plantLog.ReceivedAt = receivedAt.UTC()
+	return plantLog, nil
+}
+
+// parseTimestamp reads a measurement time in the format and time zone of options. JSON lines may provide unix timestamps as numbers
+func parseTimestamp(raw interface{}, options ImportOptions) (time.Time, error) {
+	invalid := fmt.Errorf("Timestamp '%v' doesn't match format '%s'.", raw, options.TimestampFormat)
+	if number, isNumber := raw.(float64); isNumber {
+		raw = strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	value, ok := raw.(string)
+	if !ok {
+		return time.Time{}, invalid
+	}
+	value = strings.TrimSpace(value)
+
+	switch options.TimestampFormat {
+	case TimestampRFC3339:
+		measuredAt, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		return measuredAt, nil
+	case TimestampUnix, TimestampUnixMs:
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		if options.TimestampFormat == TimestampUnixMs {
+			return time.UnixMilli(number), nil
+		}
+		return time.Unix(number, 0), nil
+	}
+
+	// Layouts with zone or offset use it, others the time zone of options
+	measuredAt, err := time.ParseInLocation(options.TimestampFormat, value, options.Location)
+	if err != nil {
+		return time.Time{}, invalid
+	}
+	return measuredAt, nil
+}
+
+// parseValue reads a measurement value. CSV cells are strings, JSON lines provide numbers. Empty cells and null are not present
+func parseValue(raw interface{}, decimalSeparator string) (float64, bool, error) {
+	switch value := raw.(type) {
+	case nil:
+		return 0, false, nil
+	case float64:
+		return value, true, nil
+	case string:
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return 0, false, nil
+		}
+		if decimalSeparator == "," {
+			value = strings.Replace(value, ",", ".", 1)
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false, err
+		}
+		if math.IsNaN(number) || math.IsInf(number, 0) {
+			return 0, false, errors.New("value is not a finite number")
+		}
+		return number, true, nil
+	}
+	return 0, false, errors.New("value is not a number")
+}
+
+// storeBatch stores the rows of a batch not yet stored. Rows with the measurement time of a stored reading of the same device are duplicates
+func storeBatch(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger, deviceID string, batch []importRow, report *ImportReport) error {
+	measuredAts := make([]time.Time, 0, len(batch))
+	for _, row := range batch {
+		measuredAts = append(measuredAts, row.log.MeasuredAt)
+	}
+	filter := loggerhandler.DeviceFilter(deviceID)
+	filter["measured_at"] = bson.M{"$in": measuredAts}
+	var storedLogs []model.PlantLogger
+	if err := mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNamePlantLogger, filter, collectionNameLogger, bson.D{}, &storedLogs); err != nil {
+		return fmt.Errorf("Error in 'storeBatch()' using 'FindManyInMongo()' in collection '%s' part of database '%s'. Error: %v", collectionNameLogger, config.DatabaseNamePlantLogger, err)
+	}
+	stored := make(map[time.Time]bool, len(storedLogs))
+	for _, storedLog := range storedLogs {
+		stored[storedLog.MeasuredAt.UTC()] = true
+	}
+
+	rows := make([]importRow, 0, len(batch))
+	documents := make([]interface{}, 0, len(batch))
+	storedAt := time.Now().UTC()
+	for _, row := range batch {
+		if stored[row.log.MeasuredAt] {
+			report.RowsDuplicate++
+			continue
+		}
+		row.log.ID = primitive.NewObjectID()
+		row.log.StoredAt = &storedAt
+		rows = append(rows, row)
+		documents = append(documents, row.log)
+	}
+	if len(documents) == 0 {
+		return nil
+	}
+
+	// Unordered bulk insert. Single failed documents are reported as rejected, anything else stops the import
+	_, err := mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, documents, collectionNameLogger)
+	failed := map[int]bool{}
+	if err != nil {
+		var bulkWriteException mongo.BulkWriteException
+		if !errors.As(err, &bulkWriteException) || len(bulkWriteException.WriteErrors) == 0 {
+			return fmt.Errorf("Error in 'storeBatch()' using 'InsertManyToMongo()' in collection '%s' part of database '%s'. Error: %v", collectionNameLogger, config.DatabaseNamePlantLogger, err)
+		}
+		for _, writeError := range bulkWriteException.WriteErrors {
+			failed[writeError.Index] = true
+		}
+	}
+	for position, row := range rows {
+		if failed[position] {
+			report.reject(row.line, "Row could not be stored.")
+			continue
+		}
+		report.RowsImported++
+	}
+	return nil
+}
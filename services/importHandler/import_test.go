@@ -0,0 +1,169 @@
+package importhandler
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testChannels = []model.Channel{
+	{Name: "powerOutput", Unit: "W", Required: true},
+	{Name: "tModule", Unit: "°C", Required: false},
+}
+
+func TestParseImportOptions(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	options, err := ParseImportOptions(map[string]string{
+		"publicPlantID":    "970407102018637",
+		"format":           "csv",
+		"mapping":          `{"powerOutput": "P_DC [W]", "tModule": "T_Mod"}`,
+		"timestampColumn":  "Zeit",
+		"timestampFormat":  "02.01.2006 15:04",
+		"timezone":         "Europe/Berlin",
+		"delimiter":        ";",
+		"decimalSeparator": ",",
+	}, testChannels)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportOptions{
+		Format:           FormatCSV,
+		Mapping:          map[string]string{"powerOutput": "P_DC [W]", "tModule": "T_Mod"},
+		TimestampColumn:  "Zeit",
+		TimestampFormat:  "02.01.2006 15:04",
+		Location:         berlin,
+		Delimiter:        ';',
+		DecimalSeparator: ",",
+	}, options)
+
+	valid := map[string]string{"format": "jsonl", "mapping": `{"powerOutput": "p"}`, "timestampColumn": "ts"}
+	testCases := []struct {
+		name    string
+		changes map[string]string
+	}{
+		{"UnknownFormat", map[string]string{"format": "xlsx"}},
+		{"MappingNoJSON", map[string]string{"mapping": "powerOutput=p"}},
+		{"MappingUnknownChannel", map[string]string{"mapping": `{"powerOutput": "p", "acPower": "ac"}`}},
+		{"RequiredChannelNotMapped", map[string]string{"mapping": `{"tModule": "t"}`}},
+		{"MissingTimestampColumn", map[string]string{"timestampColumn": ""}},
+		{"LayoutWithoutYear", map[string]string{"timestampFormat": "dd.mm.yyyy"}},
+		{"UnknownTimezone", map[string]string{"timezone": "Mars/Olympus"}},
+		{"DelimiterTooLong", map[string]string{"delimiter": ";;"}},
+		{"DecimalSeparatorEqualsDelimiter", map[string]string{"decimalSeparator": ","}},
+		{"UnknownField", map[string]string{"sheet": "1"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fields := map[string]string{}
+			for key, value := range valid {
+				fields[key] = value
+			}
+			for key, value := range tc.changes {
+				fields[key] = value
+			}
+			_, err := ParseImportOptions(fields, testChannels)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCSVRowReader(t *testing.T) {
+	file := "\uFEFFZeit;P_DC [W];T_Mod\n01.05.2023 12:15;2351,5;41,2\n01.05.2023 12:30;2400;\n01.05.2023 12:45;2410\n"
+	options := ImportOptions{Format: FormatCSV, Delimiter: ';'}
+	rows, err := NewRowReader(strings.NewReader(file), options)
+	require.NoError(t, err)
+
+	row, line, err := rows.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, line)
+	assert.Equal(t, map[string]interface{}{"Zeit": "01.05.2023 12:15", "P_DC [W]": "2351,5", "T_Mod": "41,2"}, row)
+
+	_, line, err = rows.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, line)
+
+	// Row with missing field is reported, reading continues
+	_, line, err = rows.Next()
+	assert.True(t, errors.Is(err, ErrRow))
+	assert.Equal(t, 4, line)
+
+	_, _, err = rows.Next()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = NewRowReader(strings.NewReader(""), options)
+	assert.Error(t, err)
+}
+
+func TestJSONLinesRowReader(t *testing.T) {
+	file := "{\"ts\": 1682936100, \"p\": 2351.5}\n\nnot json\n{\"ts\": 1682937000, \"p\": null}\n"
+	rows, err := NewRowReader(strings.NewReader(file), ImportOptions{Format: FormatJSONLines})
+	require.NoError(t, err)
+
+	row, line, err := rows.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, line)
+	assert.Equal(t, map[string]interface{}{"ts": 1682936100.0, "p": 2351.5}, row)
+
+	_, line, err = rows.Next()
+	assert.True(t, errors.Is(err, ErrRow))
+	assert.Equal(t, 3, line)
+
+	_, line, err = rows.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, line)
+
+	_, _, err = rows.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestParseRow(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	receivedAt := time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC)
+	csvOptions := ImportOptions{Format: FormatCSV, Mapping: map[string]string{"powerOutput": "P", "tModule": "T"}, TimestampColumn: "Zeit", TimestampFormat: "02.01.2006 15:04", Location: berlin, DecimalSeparator: ",", DeviceID: "100000000001"}
+
+	// Local summer time of Berlin is UTC+2
+	plantLog, err := ParseRow(map[string]interface{}{"Zeit": "01.05.2023 12:15", "P": "2351,5", "T": "41,2"}, csvOptions, testChannels, model.ClockSkewPolicy{}, receivedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 5, 1, 10, 15, 0, 0, time.UTC), plantLog.MeasuredAt)
+	assert.Equal(t, receivedAt, plantLog.ReceivedAt)
+	assert.Equal(t, map[string]float64{"powerOutput": 2351.5, "tModule": 41.2}, plantLog.Values)
+	assert.Equal(t, "100000000001", plantLog.DeviceID)
+
+	// Empty cell of optional channel
+	plantLog, err = ParseRow(map[string]interface{}{"Zeit": "01.05.2023 12:30", "P": "2400", "T": ""}, csvOptions, testChannels, model.ClockSkewPolicy{}, receivedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"powerOutput": 2400}, plantLog.Values)
+
+	jsonOptions := ImportOptions{Format: FormatJSONLines, Mapping: map[string]string{"powerOutput": "p"}, TimestampColumn: "ts", TimestampFormat: TimestampUnix, Location: time.UTC, DecimalSeparator: "."}
+	plantLog, err = ParseRow(map[string]interface{}{"ts": 1682936100.0, "p": 2351.5}, jsonOptions, testChannels, model.ClockSkewPolicy{}, receivedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1682936100, 0).UTC(), plantLog.MeasuredAt)
+
+	rejected := []struct {
+		name    string
+		row     map[string]interface{}
+		options ImportOptions
+	}{
+		{"MissingTimestamp", map[string]interface{}{"P": "2400", "T": "40"}, csvOptions},
+		{"TimestampFormat", map[string]interface{}{"Zeit": "2023-05-01 12:15", "P": "2400", "T": "40"}, csvOptions},
+		{"Future", map[string]interface{}{"Zeit": "01.05.2030 12:15", "P": "2400", "T": "40"}, csvOptions},
+		{"NoNumber", map[string]interface{}{"Zeit": "01.05.2023 12:15", "P": "n/a", "T": "40"}, csvOptions},
+		{"NotFinite", map[string]interface{}{"Zeit": "01.05.2023 12:15", "P": "NaN", "T": "40"}, csvOptions},
+		{"MissingColumn", map[string]interface{}{"Zeit": "01.05.2023 12:15", "P": "2400"}, csvOptions},
+		{"MissingRequiredValue", map[string]interface{}{"ts": 1682936100.0, "p": nil}, jsonOptions},
+		{"ValueNoNumber", map[string]interface{}{"ts": 1682936100.0, "p": true}, jsonOptions},
+	}
+	for _, tc := range rejected {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseRow(tc.row, tc.options, testChannels, model.ClockSkewPolicy{}, receivedAt)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package importhandler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+)
+
+// Import file formats
+const (
+	FormatCSV        string = "csv"
+	FormatJSONLines  string = "jsonl"
+	TimestampRFC3339 string = "rfc3339" // eg. 2023-05-01T12:15:00+02:00
+	TimestampUnix    string = "unix"    // Seconds since 1970-01-01 UTC
+	TimestampUnixMs  string = "unixms"  // Milliseconds since 1970-01-01 UTC
+)
+
+// ImportOptions describe how rows of an import file map onto plant logs
+type ImportOptions struct {
+	Format           string
+	Mapping          map[string]string // Column of the file by channel name
+	TimestampColumn  string
+	TimestampFormat  string         // TimestampRFC3339, TimestampUnix, TimestampUnixMs or a Go time layout, eg. '02.01.2006 15:04'
+	Location         *time.Location // Time zone of timestamps without offset
+	Delimiter        rune           // CSV only
+	DecimalSeparator string         // CSV only, '.' or ','
+	DeviceID         string         // Device all rows are tagged with. Empty for the plant as a whole
+}
+
+// Form fields of an import request besides the file
+var optionKeys = []string{"format", "mapping", "timestampColumn", "timestampFormat", "timezone", "delimiter", "decimalSeparator", "deviceID"}
+
+// ParseImportOptions converts the form fields of an import request into ImportOptions, validated against the plant's channel schema.
+// 'format', 'mapping' (JSON object of channel name to column) and 'timestampColumn' are required. The returned error is suitable for the response.
+func ParseImportOptions(fields map[string]string, channels []model.Channel) (ImportOptions, error) {
+	options := ImportOptions{TimestampFormat: TimestampRFC3339, Location: time.UTC, Delimiter: ',', DecimalSeparator: "."}
+	for key := range fields {
+		if key != "publicPlantID" && !slices.Contains(optionKeys, key) {
+			return options, fmt.Errorf("Unknown field '%s'. Allowed fields: publicPlantID, %s and file.", key, strings.Join(optionKeys, ", "))
+		}
+	}
+
+	options.Format = fields["format"]
+	if options.Format != FormatCSV && options.Format != FormatJSONLines {
+		return options, fmt.Errorf("'format' must be '%s' or '%s'.", FormatCSV, FormatJSONLines)
+	}
+
+	if err := json.Unmarshal([]byte(fields["mapping"]), &options.Mapping); err != nil || len(options.Mapping) == 0 {
+		return options, errors.New("'mapping' must be a JSON object of channel names to column names, eg. {\"powerOutput\": \"P_DC\"}.")
+	}
+	for channelName, column := range options.Mapping {
+		if _, exists := loggerhandler.FindChannel(channels, channelName); !exists {
+			return options, fmt.Errorf("'mapping': '%s' is not a channel of this plant.", channelName)
+		}
+		if column == "" {
+			return options, fmt.Errorf("'mapping': column of channel '%s' must not be empty.", channelName)
+		}
+	}
+	for _, channelName := range loggerhandler.ChannelNames(channels, true) {
+		if _, mapped := options.Mapping[channelName]; !mapped {
+			return options, fmt.Errorf("'mapping': required channel '%s' must be mapped.", channelName)
+		}
+	}
+
+	options.TimestampColumn = fields["timestampColumn"]
+	if options.TimestampColumn == "" {
+		return options, errors.New("'timestampColumn' must be the column of the measurement time.")
+	}
+
+	if timestampFormat, exists := fields["timestampFormat"]; exists {
+		// Layouts without year are most likely mistaken
+		if timestampFormat != TimestampRFC3339 && timestampFormat != TimestampUnix && timestampFormat != TimestampUnixMs && !strings.Contains(timestampFormat, "2006") {
+			return options, fmt.Errorf("'timestampFormat' must be '%s', '%s', '%s' or a Go time layout with reference time 2006-01-02 15:04:05, eg. '02.01.2006 15:04'.", TimestampRFC3339, TimestampUnix, TimestampUnixMs)
+		}
+		options.TimestampFormat = timestampFormat
+	}
+
+	if timezone, exists := fields["timezone"]; exists {
+		location, err := time.LoadLocation(timezone)
+		if err != nil || timezone == "" || timezone == "Local" {
+			return options, errors.New("'timezone' must be an IANA time zone, eg. 'Europe/Berlin' or 'UTC'.")
+		}
+		options.Location = location
+	}
+
+	if delimiter, exists := fields["delimiter"]; exists {
+		runeDelimiter, size := utf8.DecodeRuneInString(delimiter)
+		if size == 0 || size != len(delimiter) || runeDelimiter == '"' || runeDelimiter == '\r' || runeDelimiter == '\n' || runeDelimiter == utf8.RuneError {
+			return options, errors.New("'delimiter' must be a single character, eg. ';'.")
+		}
+		options.Delimiter = runeDelimiter
+	}
+
+	if decimalSeparator, exists := fields["decimalSeparator"]; exists {
+		if decimalSeparator != "." && decimalSeparator != "," {
+			return options, errors.New("'decimalSeparator' must be '.' or ','.")
+		}
+		if decimalSeparator == string(options.Delimiter) {
+			return options, errors.New("'decimalSeparator' must differ from 'delimiter'.")
+		}
+		options.DecimalSeparator = decimalSeparator
+	}
+
+	options.DeviceID = fields["deviceID"]
+	return options, nil
+}
@@ -0,0 +1,110 @@
+package importhandler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrRow is wrapped by errors of single rows which don't prevent reading further rows, eg. a malformed JSON line
+var ErrRow = errors.New("invalid row")
+
+// RowReader streams the rows of an import file. Next returns io.EOF after the last row.
+// Line is the line of the row within the file, starting with 1, to reference it in the import report.
+type RowReader interface {
+	Next() (row map[string]interface{}, line int, err error)
+}
+
+// NewRowReader returns a row reader of the file format of options
+func NewRowReader(file io.Reader, options ImportOptions) (RowReader, error) {
+	switch options.Format {
+	case FormatCSV:
+		return newCSVReader(file, options.Delimiter)
+	case FormatJSONLines:
+		return newJSONLinesReader(file), nil
+	}
+	return nil, fmt.Errorf("unknown import format '%s'", options.Format)
+}
+
+// csvReader reads CSV files with header. Values are strings
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCSVReader(file io.Reader, delimiter rune) (*csvReader, error) {
+	reader := csv.NewReader(file)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = 0 // All rows must have as many fields as the header
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV file is empty. The first row must be the header.")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read header of CSV file. Error: %w", err)
+	}
+	// Exports often start with a byte order mark
+	columns := make([]string, len(header))
+	for i, column := range header {
+		columns[i] = strings.TrimSpace(column)
+	}
+	columns[0] = strings.TrimPrefix(columns[0], "\uFEFF")
+	return &csvReader{reader: reader, header: columns}, nil
+}
+
+func (reader *csvReader) Next() (map[string]interface{}, int, error) {
+	record, err := reader.reader.Read()
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	var parseError *csv.ParseError
+	if errors.As(err, &parseError) {
+		return nil, parseError.StartLine, fmt.Errorf("%w: %v", ErrRow, parseError.Err)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	line, _ := reader.reader.FieldPos(0)
+	row := make(map[string]interface{}, len(record))
+	for i, value := range record {
+		row[reader.header[i]] = value
+	}
+	return row, line, nil
+}
+
+// jsonLinesReader reads files with one JSON object per line. Blank lines are skipped
+type jsonLinesReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newJSONLinesReader(file io.Reader) *jsonLinesReader {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	return &jsonLinesReader{scanner: scanner}
+}
+
+func (reader *jsonLinesReader) Next() (map[string]interface{}, int, error) {
+	for reader.scanner.Scan() {
+		reader.line++
+		content := bytes.TrimSpace(reader.scanner.Bytes())
+		if len(content) == 0 {
+			continue
+		}
+		var row map[string]interface{}
+		if err := json.Unmarshal(content, &row); err != nil || row == nil {
+			return nil, reader.line, fmt.Errorf("%w: line must be a JSON object", ErrRow)
+		}
+		return row, reader.line, nil
+	}
+	if err := reader.scanner.Err(); err != nil {
+		return nil, reader.line + 1, err
+	}
+	return nil, 0, io.EOF
+}
@@ -0,0 +1,390 @@
+package ingestpipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	"github.com/paulmuenzner/powerplantmanager/utils/data"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrQueueFull   = errors.New("ingestion queue is full")
+	ErrUnavailable = errors.New("ingestion is unavailable")
+)
+
+// storeFunc stores readings of one plant logger collection with one bulk write. With retry, some of the readings may be stored already
+type storeFunc func(collectionNameLogger string, plantLogs []model.PlantLogger, retry bool) error
+
+// Pipeline accepts validated single readings, spools them to disk and stores them in bulk per plant logger collection in the background.
+// A reading is acknowledged once spooled, so readings accepted before a crash are stored after the next start.
+// Readings not yet stored count towards the logging interval and retry detection of their device, as validation only sees stored readings.
+// Readings of the latest flush stay tracked until the next one, as requests validated before they were stored haven't seen them either.
+type Pipeline struct {
+	store        storeFunc
+	mutex        sync.Mutex
+	spool        *spool
+	pending      []spoolRecord                               // Accepted readings not yet stored, oldest first
+	identities   map[string]loggerhandler.ReadingIdentitySet // Sequence numbers and idempotency keys of tracked readings by collection
+	measuredAt   map[string][]time.Time                      // Measurement times of tracked readings by collection and device, sorted ascending
+	storeFailing bool                                        // Latest flush failed, eg. database unavailable
+	stopped      bool
+	recovered    int // Readings spooled by a previous run
+	skipped      int // Unreadable spool lines of a previous run
+	flushNow     chan struct{}
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+// NewPipeline creates a pipeline spooling to spoolDir. Readings spooled by a previous run are queued again. Call Start to store readings in the background.
+func NewPipeline(mongoDBInterface *mongodb.MethodInterface, spoolDir string) (*Pipeline, error) {
+	spool, records, skipped, err := openSpool(spoolDir)
+	if err != nil {
+		return nil, err
+	}
+	pipeline := &Pipeline{
+		store:      storeLogs(mongoDBInterface),
+		spool:      spool,
+		pending:    records,
+		identities: map[string]loggerhandler.ReadingIdentitySet{},
+		measuredAt: map[string][]time.Time{},
+		flushNow:   make(chan struct{}, 1),
+		recovered:  len(records),
+		skipped:    skipped,
+	}
+	for _, record := range records {
+		pipeline.track(record)
+	}
+	return pipeline, nil
+}
+
+// Recovered returns the number of readings spooled by a previous run and queued again, and the number of unreadable spool lines skipped
+func (pipeline *Pipeline) Recovered() (readings int, skippedLines int) {
+	return pipeline.recovered, pipeline.skipped
+}
+
+// Pending returns the number of accepted readings not yet stored
+func (pipeline *Pipeline) Pending() int {
+	pipeline.mutex.Lock()
+	defer pipeline.mutex.Unlock()
+	return len(pipeline.pending)
+}
+
+// PendingReadings returns the measurement times, sorted ascending, of readings of device deviceID (empty for readings reported for the plant as a whole)
+// not yet stored to the plant logger collection collectionNameLogger, and the sequence numbers and idempotency keys of readings of the collection not yet stored.
+// Readings written to the collection directly, eg. batches, are validated against them in addition to the stored readings.
+func (pipeline *Pipeline) PendingReadings(collectionNameLogger, deviceID string) ([]time.Time, loggerhandler.ReadingIdentitySet) {
+	pipeline.mutex.Lock()
+	defer pipeline.mutex.Unlock()
+	measuredAt := append([]time.Time{}, pipeline.measuredAt[measuredAtKey(collectionNameLogger, deviceID)]...)
+	identities := loggerhandler.ReadingIdentitySet{}
+	for identity := range pipeline.identities[collectionNameLogger] {
+		identities[identity] = true
+	}
+	return measuredAt, identities
+}
+
+// Enqueue accepts a reading validated by ValidateLogEntry for the plant logger collection of plantConfig.
+// Returns true if it's a retry of a pending reading (same sequence number or idempotency key), which is not queued again.
+// Readings within the logging interval of a pending reading of the same device are rejected with *ReadingRejection.
+// ErrQueueFull and ErrUnavailable ask the logger to retry later. A reading failing with ErrUnavailable once spooled may still be stored,
+// its retry is recognized as duplicate then.
+func (pipeline *Pipeline) Enqueue(plantConfig model.PlantLoggerConfig, plantLog model.PlantLogger) (bool, error) {
+	position, isDuplicate, err := pipeline.enqueue(plantConfig, plantLog)
+	if err != nil || isDuplicate {
+		return isDuplicate, err
+	}
+	// Synced outside the lock, so readings spooled meanwhile share one sync
+	if err := pipeline.spool.sync(position); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return false, nil
+}
+
+// enqueue validates a reading against pending readings, writes it to the spool and queues it. Returns its position in the spool to sync
+func (pipeline *Pipeline) enqueue(plantConfig model.PlantLoggerConfig, plantLog model.PlantLogger) (uint64, bool, error) {
+	pipeline.mutex.Lock()
+	defer pipeline.mutex.Unlock()
+
+	if pipeline.stopped {
+		return 0, false, ErrUnavailable
+	}
+	collectionNameLogger := plantConfig.CollectionNameLogger
+	if pipeline.identities[collectionNameLogger].Contains(plantLog) {
+		return 0, true, nil
+	}
+	if loggerhandler.IsWithinLogIntervalOfAny(plantLog.MeasuredAt, pipeline.measuredAt[measuredAtKey(collectionNameLogger, plantLog.DeviceID)], plantConfig.IntervalSec) {
+		return 0, false, loggerhandler.LogIntervalRejection(plantConfig.IntervalSec)
+	}
+	if len(pipeline.pending) >= config.IngestQueueSize {
+		if pipeline.storeFailing {
+			return 0, false, ErrUnavailable
+		}
+		return 0, false, ErrQueueFull
+	}
+
+	// The id is assigned once, so storing a reading again after a crash is recognized as duplicate
+	plantLog.ID = primitive.NewObjectID()
+	if err := data.ValidateStruct(plantLog); err != nil {
+		return 0, false, fmt.Errorf("Data validation against mongodb plant logger model failed in 'Enqueue()' using 'ValidateStruct()'. Error: %v", err)
+	}
+	record := spoolRecord{Collection: collectionNameLogger, Log: plantLog}
+	position, err := pipeline.spool.write(record)
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	pipeline.pending = append(pipeline.pending, record)
+	pipeline.track(record)
+
+	if len(pipeline.pending) >= config.IngestBatchSize {
+		select {
+		case pipeline.flushNow <- struct{}{}:
+		default:
+		}
+	}
+	return position, false, nil
+}
+
+// track adds a reading to identities and measurement times
+func (pipeline *Pipeline) track(record spoolRecord) {
+	identities, exists := pipeline.identities[record.Collection]
+	if !exists {
+		identities = loggerhandler.ReadingIdentitySet{}
+		pipeline.identities[record.Collection] = identities
+	}
+	identities.Add(record.Log)
+	key := measuredAtKey(record.Collection, record.Log.DeviceID)
+	measuredAt := pipeline.measuredAt[key]
+	index := sort.Search(len(measuredAt), func(i int) bool { return measuredAt[i].After(record.Log.MeasuredAt) })
+	measuredAt = append(measuredAt, time.Time{})
+	copy(measuredAt[index+1:], measuredAt[index:])
+	measuredAt[index] = record.Log.MeasuredAt
+	pipeline.measuredAt[key] = measuredAt
+}
+
+func measuredAtKey(collectionNameLogger, deviceID string) string {
+	return collectionNameLogger + "\x00" + deviceID
+}
+
+// Start stores queued readings in the background in the flush interval, or earlier once a batch is full
+func (pipeline *Pipeline) Start() {
+	pipeline.stop = make(chan struct{})
+	pipeline.done = make(chan struct{})
+	go pipeline.run()
+}
+
+// Stop stops accepting readings and stores queued readings. Readings not stored within the shutdown timeout stay spooled for the next start
+func (pipeline *Pipeline) Stop() {
+	pipeline.mutex.Lock()
+	pipeline.stopped = true
+	pipeline.mutex.Unlock()
+
+	close(pipeline.stop)
+	select {
+	case <-pipeline.done:
+	case <-time.After(time.Duration(config.IngestShutdownTimeoutSec) * time.Second):
+		logger.GetLogger().Warnf("Ingestion pipeline stopped in 'Stop()' with %d readings not stored. They are stored after the next start.", pipeline.Pending())
+		return
+	}
+
+	pipeline.mutex.Lock()
+	defer pipeline.mutex.Unlock()
+	if err := pipeline.spool.close(); err != nil {
+		logger.GetLogger().Errorf("Error in 'Stop()' using 'close()' of ingestion spool. Error: %v", err)
+	}
+}
+
+// run flushes until stopped, with a last flush on stop
+func (pipeline *Pipeline) run() {
+	defer close(pipeline.done)
+	ticker := time.NewTicker(time.Duration(config.IngestFlushIntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pipeline.stop:
+			pipeline.logFlush(pipeline.flush())
+			return
+		case <-ticker.C:
+		case <-pipeline.flushNow:
+		}
+		pipeline.logFlush(pipeline.flush())
+	}
+}
+
+func (pipeline *Pipeline) logFlush(err error) {
+	if err != nil {
+		logger.GetLogger().Errorf("Error in 'run()' of ingestion pipeline using 'flush()'. %d readings waiting. Error: %v", pipeline.Pending(), err)
+	}
+}
+
+// flush stores all pending readings. On failure they are queued again, ahead of readings accepted meanwhile.
+// Stored readings are removed from the spool, as their spool segments are rotated before storing.
+func (pipeline *Pipeline) flush() error {
+	pipeline.mutex.Lock()
+	if len(pipeline.pending) == 0 {
+		pipeline.mutex.Unlock()
+		return nil
+	}
+	segments, err := pipeline.spool.rotate()
+	if err != nil {
+		pipeline.mutex.Unlock()
+		return err
+	}
+	batch := pipeline.pending
+	pipeline.pending = nil
+	pipeline.mutex.Unlock()
+
+	err = pipeline.storeBatch(batch)
+
+	pipeline.mutex.Lock()
+	defer pipeline.mutex.Unlock()
+	if err != nil {
+		for index := range batch {
+			batch[index].attempted = true
+		}
+		pipeline.pending = append(batch, pipeline.pending...)
+		pipeline.storeFailing = true
+		return err
+	}
+	pipeline.storeFailing = false
+	// Readings stored before are found by validation from now on
+	pipeline.identities = map[string]loggerhandler.ReadingIdentitySet{}
+	pipeline.measuredAt = map[string][]time.Time{}
+	for _, record := range append(batch, pipeline.pending...) {
+		pipeline.track(record)
+	}
+	return pipeline.spool.release(segments)
+}
+
+// storeBatch stores readings grouped by plant logger collection in bulk writes of at most IngestBatchSize readings.
+// A failure stops storing, the whole batch is retried. Readings stored already are then recognized by their id, looked up for collections with readings attempted before only.
+func (pipeline *Pipeline) storeBatch(batch []spoolRecord) error {
+	collections := []string{}
+	logsByCollection := map[string][]model.PlantLogger{}
+	retryByCollection := map[string]bool{}
+	for _, record := range batch {
+		if _, exists := logsByCollection[record.Collection]; !exists {
+			collections = append(collections, record.Collection)
+		}
+		logsByCollection[record.Collection] = append(logsByCollection[record.Collection], record.Log)
+		retryByCollection[record.Collection] = retryByCollection[record.Collection] || record.attempted
+	}
+
+	for _, collectionNameLogger := range collections {
+		plantLogs := logsByCollection[collectionNameLogger]
+		for start := 0; start < len(plantLogs); start += config.IngestBatchSize {
+			end := min(start+config.IngestBatchSize, len(plantLogs))
+			if err := pipeline.store(collectionNameLogger, plantLogs[start:end], retryByCollection[collectionNameLogger]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// storeLogs stores readings with an unordered bulk insert. Duplicates of stored readings (same id, sequence number or idempotency key) count as stored.
+// Other readings failing on their own are dropped with an error log, as retrying them would block the pipeline.
+func storeLogs(mongoDBInterface *mongodb.MethodInterface) storeFunc {
+	return func(collectionNameLogger string, plantLogs []model.PlantLogger, retry bool) error {
+		ctx := context.Background() // Stored in the background, independent of the requests having accepted the readings
+		var err error
+		if retry {
+			plantLogs, err = withoutStoredLogs(ctx, mongoDBInterface, collectionNameLogger, plantLogs)
+			if err != nil {
+				return err
+			}
+		}
+		// Claim sequence numbers and idempotency keys first, time-series collections can't keep them unique.
+		// Claims of a failed attempt belong to the same readings and are no conflict
+		claimedByOther, err := loggerhandler.ClaimReadingIdentities(ctx, mongoDBInterface, collectionNameLogger, plantLogs)
+		if err != nil {
+			return fmt.Errorf("Unable to save plant logs in 'storeLogs()' using 'ClaimReadingIdentities()'. Collection name: %s. Error: %w", collectionNameLogger, err)
+		}
+		storing := make([]model.PlantLogger, 0, len(plantLogs))
+		documents := make([]interface{}, 0, len(plantLogs))
+		storedAt := time.Now().UTC()
+		for position, plantLog := range plantLogs {
+			if claimedByOther[position] {
+				continue
+			}
+			plantLog.StoredAt = &storedAt
+			storing = append(storing, plantLog)
+			documents = append(documents, plantLog)
+		}
+		if len(documents) == 0 {
+			return nil
+		}
+		_, err = mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, documents, collectionNameLogger)
+		if err == nil {
+			return nil
+		}
+		var bulkWriteException mongo.BulkWriteException
+		if !errors.As(err, &bulkWriteException) || len(bulkWriteException.WriteErrors) == 0 || bulkWriteException.WriteConcernError != nil {
+			return fmt.Errorf("Unable to save plant logs in 'storeLogs()' using 'InsertManyToMongo()'. Collection name: %s. Error: %v", collectionNameLogger, err)
+		}
+		// Claims of dropped readings are released. Duplicates keep them, they may be the readings themselves stored by a failed attempt
+		dropped := []model.PlantLogger{}
+		for _, writeError := range bulkWriteException.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeError) {
+				dropped = append(dropped, storing[writeError.Index])
+				logger.GetLogger().Errorf("Plant log dropped in 'storeLogs()' using 'InsertManyToMongo()'. Collection name: %s. Measured at: %v. Error: %v", collectionNameLogger, storing[writeError.Index].MeasuredAt, writeError)
+			}
+		}
+		if err := loggerhandler.ReleaseReadingIdentities(ctx, mongoDBInterface, collectionNameLogger, dropped); err != nil {
+			logger.GetLogger().Errorf("Error in 'storeLogs()' using 'ReleaseReadingIdentities()'. Collection name: %s. Error: %v", collectionNameLogger, err)
+		}
+		return nil
+	}
+}
+
+// withoutStoredLogs returns plantLogs without readings stored already by a failed attempt of storing the batch.
+// Time-series collections have no unique index on the id, so stored readings must be looked up instead of failing as duplicates.
+func withoutStoredLogs(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, plantLogs []model.PlantLogger) ([]model.PlantLogger, error) {
+	if len(plantLogs) == 0 {
+		return plantLogs, nil
+	}
+	ids := make(bson.A, 0, len(plantLogs))
+	first, last := plantLogs[0].MeasuredAt, plantLogs[0].MeasuredAt
+	for _, plantLog := range plantLogs {
+		ids = append(ids, plantLog.ID)
+		if plantLog.MeasuredAt.Before(first) {
+			first = plantLog.MeasuredAt
+		}
+		if plantLog.MeasuredAt.After(last) {
+			last = plantLog.MeasuredAt
+		}
+	}
+	// The period of the readings lets time-series collections skip buckets of other periods
+	filter := bson.M{"_id": bson.M{"$in": ids}, "measured_at": bson.M{"$gte": first, "$lte": last}}
+	var storedLogs []model.PlantLogger
+	if err := mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNamePlantLogger, filter, collectionNameLogger, bson.D{}, &storedLogs); err != nil {
+		return nil, fmt.Errorf("Unable to find stored plant logs in 'withoutStoredLogs()' using 'FindManyInMongo()'. Collection name: %s. Error: %v", collectionNameLogger, err)
+	}
+	if len(storedLogs) == 0 {
+		return plantLogs, nil
+	}
+
+	stored := make(map[primitive.ObjectID]bool, len(storedLogs))
+	for _, storedLog := range storedLogs {
+		stored[storedLog.ID] = true
+	}
+	unstoredLogs := make([]model.PlantLogger, 0, len(plantLogs)-len(storedLogs))
+	for _, plantLog := range plantLogs {
+		if !stored[plantLog.ID] {
+			unstoredLogs = append(unstoredLogs, plantLog)
+		}
+	}
+	return unstoredLogs, nil
+}
@@ -0,0 +1,251 @@
+package ingestpipeline
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingStore collects stored readings by collection and counts retried stores, failing while err is set
+type recordingStore struct {
+	stored  map[string][]model.PlantLogger
+	retries int
+	err     error
+}
+
+func (store *recordingStore) store(collectionNameLogger string, plantLogs []model.PlantLogger, retry bool) error {
+	if store.err != nil {
+		return store.err
+	}
+	if retry {
+		store.retries++
+	}
+	store.stored[collectionNameLogger] = append(store.stored[collectionNameLogger], plantLogs...)
+	return nil
+}
+
+func newTestPipeline(t *testing.T, spoolDir string) (*Pipeline, *recordingStore) {
+	pipeline, err := NewPipeline(nil, spoolDir)
+	require.NoError(t, err)
+	store := &recordingStore{stored: map[string][]model.PlantLogger{}}
+	pipeline.store = store.store
+	return pipeline, store
+}
+
+func testReading(measuredAt time.Time, deviceID string) model.PlantLogger {
+	return model.PlantLogger{DeviceID: deviceID, Values: map[string]float64{"powerOutput": 4200}, MeasuredAt: measuredAt, ReceivedAt: measuredAt}
+}
+
+func spoolSegments(t *testing.T, spoolDir string) int {
+	segments, err := listSegments(spoolDir)
+	require.NoError(t, err)
+	return len(segments)
+}
+
+func TestPipelineStoresPerCollection(t *testing.T) {
+	spoolDir := t.TempDir()
+	pipeline, store := newTestPipeline(t, spoolDir)
+	plantA := model.PlantLoggerConfig{CollectionNameLogger: "plantA", IntervalSec: 900}
+	plantB := model.PlantLoggerConfig{CollectionNameLogger: "plantB", IntervalSec: 900}
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, enqueue := range []struct {
+		plantConfig model.PlantLoggerConfig
+		plantLog    model.PlantLogger
+	}{
+		{plantA, testReading(start, "")},
+		{plantB, testReading(start, "")},
+		{plantA, testReading(start, "inverter-1")}, // Devices keep their own logging interval
+		{plantA, testReading(start.Add(15*time.Minute), "")},
+	} {
+		_, err := pipeline.Enqueue(enqueue.plantConfig, enqueue.plantLog)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 4, pipeline.Pending())
+
+	require.NoError(t, pipeline.flush())
+	assert.Equal(t, 0, pipeline.Pending())
+	assert.Len(t, store.stored["plantA"], 3)
+	assert.Len(t, store.stored["plantB"], 1)
+	assert.False(t, store.stored["plantA"][0].ID.IsZero())
+	// Only the new, empty active segment is left
+	assert.Equal(t, 1, spoolSegments(t, spoolDir))
+
+	// Stored readings still count towards the logging interval
+	_, err := pipeline.Enqueue(plantA, testReading(start.Add(20*time.Minute), ""))
+	var rejection *loggerhandler.ReadingRejection
+	assert.True(t, errors.As(err, &rejection))
+}
+
+func TestPipelineRecognizesPendingRetries(t *testing.T) {
+	pipeline, _ := newTestPipeline(t, t.TempDir())
+	plantConfig := model.PlantLoggerConfig{CollectionNameLogger: "plant", IntervalSec: 900}
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	sequence := int64(7)
+
+	plantLog := testReading(start, "")
+	plantLog.Sequence = &sequence
+	isDuplicate, err := pipeline.Enqueue(plantConfig, plantLog)
+	require.NoError(t, err)
+	assert.False(t, isDuplicate)
+
+	isDuplicate, err = pipeline.Enqueue(plantConfig, plantLog)
+	require.NoError(t, err)
+	assert.True(t, isDuplicate)
+	assert.Equal(t, 1, pipeline.Pending())
+
+	// Same sequence number of another device is a different reading
+	otherDevice := testReading(start, "inverter-1")
+	otherDevice.Sequence = &sequence
+	isDuplicate, err = pipeline.Enqueue(plantConfig, otherDevice)
+	require.NoError(t, err)
+	assert.False(t, isDuplicate)
+}
+
+func TestPipelineChecksAllPendingReadings(t *testing.T) {
+	pipeline, _ := newTestPipeline(t, t.TempDir())
+	plantConfig := model.PlantLoggerConfig{CollectionNameLogger: "plant", IntervalSec: 900}
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	sequence := int64(7)
+
+	first := testReading(start, "")
+	first.Sequence = &sequence
+	for _, plantLog := range []model.PlantLogger{first, testReading(start.Add(time.Hour), "")} {
+		_, err := pipeline.Enqueue(plantConfig, plantLog)
+		require.NoError(t, err)
+	}
+
+	// Backfilled reading close to the earlier pending reading, not the latest one
+	_, err := pipeline.Enqueue(plantConfig, testReading(start.Add(5*time.Minute), ""))
+	var rejection *loggerhandler.ReadingRejection
+	assert.True(t, errors.As(err, &rejection))
+	_, err = pipeline.Enqueue(plantConfig, testReading(start.Add(30*time.Minute), ""))
+	require.NoError(t, err)
+
+	measuredAt, identities := pipeline.PendingReadings("plant", "")
+	assert.Equal(t, []time.Time{start, start.Add(30 * time.Minute), start.Add(time.Hour)}, measuredAt)
+	assert.True(t, identities.Contains(first))
+	measuredAt, _ = pipeline.PendingReadings("plant", "inverter-1")
+	assert.Empty(t, measuredAt)
+
+	// Readings of the latest flush stay tracked, requests validated before it haven't seen them stored
+	require.NoError(t, pipeline.flush())
+	measuredAt, identities = pipeline.PendingReadings("plant", "")
+	assert.Len(t, measuredAt, 3)
+	assert.True(t, identities.Contains(first))
+}
+
+func TestPipelineReplaysSpoolAfterCrash(t *testing.T) {
+	spoolDir := t.TempDir()
+	pipeline, _ := newTestPipeline(t, spoolDir)
+	plantConfig := model.PlantLoggerConfig{CollectionNameLogger: "plant", IntervalSec: 900}
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		_, err := pipeline.Enqueue(plantConfig, testReading(start.Add(time.Duration(i)*15*time.Minute), ""))
+		require.NoError(t, err)
+	}
+	acknowledged := append([]spoolRecord{}, pipeline.pending...)
+
+	// Crash without stopping. The next start queues the acknowledged readings again, keeping their ids
+	restarted, store := newTestPipeline(t, spoolDir)
+	assert.Equal(t, 3, restarted.Pending())
+	_, err := restarted.Enqueue(plantConfig, testReading(start.Add(30*time.Minute), ""))
+	var rejection *loggerhandler.ReadingRejection
+	assert.True(t, errors.As(err, &rejection))
+
+	require.NoError(t, restarted.flush())
+	require.Len(t, store.stored["plant"], 3)
+	for i, record := range acknowledged {
+		assert.Equal(t, record.Log.ID, store.stored["plant"][i].ID)
+		assert.True(t, record.Log.MeasuredAt.Equal(store.stored["plant"][i].MeasuredAt))
+	}
+	assert.Equal(t, 1, spoolSegments(t, spoolDir))
+}
+
+func TestPipelineSkipsTornSpoolLine(t *testing.T) {
+	spoolDir := t.TempDir()
+	pipeline, _ := newTestPipeline(t, spoolDir)
+	plantConfig := model.PlantLoggerConfig{CollectionNameLogger: "plant", IntervalSec: 900}
+	_, err := pipeline.Enqueue(plantConfig, testReading(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC), ""))
+	require.NoError(t, err)
+
+	// Crash during the append of a second reading
+	file, err := os.OpenFile(pipeline.spool.active.Name(), os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"collection":"plant","log":{"measu`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	records, skipped, err := readSegment(pipeline.spool.active.Name())
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 1, skipped)
+}
+
+func TestPipelineBackpressure(t *testing.T) {
+	pipeline, store := newTestPipeline(t, t.TempDir())
+	plantConfig := model.PlantLoggerConfig{CollectionNameLogger: "plant", IntervalSec: 900}
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	_, err := pipeline.Enqueue(plantConfig, testReading(start, ""))
+	require.NoError(t, err)
+
+	// Failed flush keeps readings queued and spooled
+	store.err = errors.New("database unavailable")
+	assert.Error(t, pipeline.flush())
+	assert.Equal(t, 1, pipeline.Pending())
+	assert.Equal(t, 2, spoolSegments(t, pipeline.spool.dir))
+
+	// Full queue: 503 while storing fails, otherwise 429
+	pipeline.pending = append(pipeline.pending, make([]spoolRecord, config.IngestQueueSize)...)
+	_, err = pipeline.Enqueue(plantConfig, testReading(start.Add(time.Hour), ""))
+	assert.ErrorIs(t, err, ErrUnavailable)
+	pipeline.storeFailing = false
+	_, err = pipeline.Enqueue(plantConfig, testReading(start.Add(time.Hour), ""))
+	assert.ErrorIs(t, err, ErrQueueFull)
+	pipeline.pending = pipeline.pending[:1]
+
+	store.err = nil
+	require.NoError(t, pipeline.flush())
+	assert.Len(t, store.stored["plant"], 1)
+	assert.Equal(t, 1, store.retries)
+	assert.Equal(t, 1, spoolSegments(t, pipeline.spool.dir))
+}
+
+func TestPipelineConcurrentEnqueue(t *testing.T) {
+	spoolDir := t.TempDir()
+	pipeline, _ := newTestPipeline(t, spoolDir)
+	plantConfig := model.PlantLoggerConfig{CollectionNameLogger: "plant", IntervalSec: 900}
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Readings of concurrent requests share syncs and are all spooled once accepted
+	var waitGroup sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			_, err := pipeline.Enqueue(plantConfig, testReading(start.Add(time.Duration(i)*time.Hour), ""))
+			errs <- err
+		}(i)
+	}
+	waitGroup.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 50, pipeline.Pending())
+	assert.Equal(t, pipeline.spool.written, pipeline.spool.synced)
+
+	records, skipped, err := readSegment(pipeline.spool.active.Name())
+	require.NoError(t, err)
+	assert.Len(t, records, 50)
+	assert.Equal(t, 0, skipped)
+}
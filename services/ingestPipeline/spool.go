@@ -0,0 +1,319 @@
+package ingestpipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	model "github.com/paulmuenzner/powerplantmanager/models"
+)
+
+const segmentSuffix = ".jsonl"
+
+// spoolRecord is a line of a spool segment: an accepted reading and the plant logger collection to store it in
+type spoolRecord struct {
+	Collection string            `json:"collection"`
+	Log        model.PlantLogger `json:"log"`
+	attempted  bool              // Storing has been attempted before, eg. by a previous run, so the reading may be stored already
+}
+
+// spool persists accepted readings in append-only segment files of one JSON line per reading. Each record is synced to disk before the reading is acknowledged.
+// Records written while a sync is running share the next sync (group commit), so concurrent readings don't wait for one sync each.
+// The active segment is rotated on each flush. Closed segments are removed once all their readings are stored.
+// The pipeline serializes all calls but sync, which runs concurrently to them.
+type spool struct {
+	dir           string
+	syncMutex     sync.Mutex // Held while syncing, rotating and closing the active segment. Acquired before mutex
+	mutex         sync.Mutex // Guards active, activeStart, written, synced and syncFailed, as sync runs outside the pipeline's lock
+	active        *os.File
+	activeStart   uint64 // Records written before the active segment was opened
+	activeRecords int
+	nextSegment   int
+	closed        []string // Segments whose readings are waiting to be stored, oldest first
+	written       uint64   // Records written, including those of closed segments
+	synced        uint64   // Records written and synced. Records of segments failed to sync are never synced
+	syncFailed    bool     // The active segment failed to sync and is abandoned by the next write
+}
+
+// openSpool opens the spool in dir, creating dir if missing. Returns the readings of segments left by a previous run, which have been acknowledged but possibly not stored,
+// and the number of unreadable lines skipped.
+func openSpool(dir string) (*spool, []spoolRecord, int, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, nil, 0, fmt.Errorf("Cannot create spool directory '%s'. Error: %w", dir, err)
+	}
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	spool := &spool{dir: dir, nextSegment: 1}
+	records := []spoolRecord{}
+	skippedLines := 0
+	for _, segment := range segments {
+		segmentRecords, skipped, err := readSegment(filepath.Join(dir, segment.name))
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		for _, record := range segmentRecords {
+			record.attempted = true
+			records = append(records, record)
+		}
+		skippedLines += skipped
+		spool.closed = append(spool.closed, segment.name)
+		spool.nextSegment = segment.number + 1
+	}
+	if err := spool.openSegment(); err != nil {
+		return nil, nil, 0, err
+	}
+	return spool, records, skippedLines, nil
+}
+
+type segmentFile struct {
+	name   string
+	number int
+}
+
+// listSegments returns the segment files of dir in order of creation
+func listSegments(dir string) ([]segmentFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read spool directory '%s'. Error: %w", dir, err)
+	}
+	segments := []segmentFile{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSuffix(name, segmentSuffix))
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segmentFile{name: name, number: number})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].number < segments[j].number })
+	return segments, nil
+}
+
+// readSegment reads the records of a segment and counts skipped lines. A torn last line of a crash during an append belongs to a reading never acknowledged
+func readSegment(path string) ([]spoolRecord, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Cannot open spool segment '%s'. Error: %w", path, err)
+	}
+	defer file.Close()
+
+	records := []spoolRecord{}
+	skipped := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var record spoolRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Collection == "" {
+			skipped++
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("Cannot read spool segment '%s'. Error: %w", path, err)
+	}
+	return records, skipped, nil
+}
+
+func (spool *spool) segmentName(number int) string {
+	return fmt.Sprintf("%020d%s", number, segmentSuffix)
+}
+
+// openSegment creates the next active segment. The directory is synced, so the segment survives a crash
+func (spool *spool) openSegment() error {
+	path := filepath.Join(spool.dir, spool.segmentName(spool.nextSegment))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("Cannot create spool segment '%s'. Error: %w", path, err)
+	}
+	if err := syncDir(spool.dir); err != nil {
+		file.Close()
+		return err
+	}
+	spool.mutex.Lock()
+	spool.active, spool.activeStart, spool.syncFailed = file, spool.written, false
+	spool.mutex.Unlock()
+	spool.activeRecords = 0
+	spool.nextSegment++
+	return nil
+}
+
+// write writes a record to the active segment without syncing it. Returns the position of the record to sync. A segment failed to open on rotation,
+// or failed to sync, is replaced first
+func (spool *spool) write(record spoolRecord) (uint64, error) {
+	spool.mutex.Lock()
+	syncFailed := spool.syncFailed
+	spool.mutex.Unlock()
+	if syncFailed {
+		spool.abandonActive()
+	}
+	if spool.active == nil {
+		if err := spool.openSegment(); err != nil {
+			return 0, err
+		}
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return 0, fmt.Errorf("Cannot encode reading for spool. Error: %w", err)
+	}
+
+	spool.mutex.Lock()
+	_, err = spool.active.Write(append(line, '\n'))
+	if err == nil {
+		spool.written++
+	}
+	position := spool.written
+	spool.mutex.Unlock()
+	if err != nil {
+		err = fmt.Errorf("Cannot write to spool segment '%s'. Error: %w", spool.active.Name(), err)
+		spool.abandonActive()
+		return 0, err
+	}
+	spool.activeRecords++
+	return position, nil
+}
+
+// sync returns once the record at position is synced to disk. One sync covers all records written before it starts,
+// so callers waiting meanwhile are covered by the next sync. Fails for records of a segment failed to sync, as a later sync may
+// succeed without the lost records. Safe for concurrent use with the other calls
+func (spool *spool) sync(position uint64) error {
+	spool.syncMutex.Lock()
+	defer spool.syncMutex.Unlock()
+
+	spool.mutex.Lock()
+	if spool.synced >= position {
+		spool.mutex.Unlock()
+		return nil
+	}
+	if spool.syncFailed || spool.active == nil || position <= spool.activeStart {
+		spool.mutex.Unlock()
+		return errors.New("Spool segment of the reading failed to be written or synced.")
+	}
+	target, active := spool.written, spool.active
+	spool.mutex.Unlock()
+
+	if err := active.Sync(); err != nil {
+		spool.mutex.Lock()
+		spool.syncFailed = true
+		spool.mutex.Unlock()
+		return fmt.Errorf("Cannot sync spool segment '%s'. Error: %w", active.Name(), err)
+	}
+	spool.mutex.Lock()
+	spool.synced = target
+	spool.mutex.Unlock()
+	return nil
+}
+
+// closeActive syncs and closes the active segment, so all records written are synced unless it failed to sync before. Callers hold syncMutex
+func (spool *spool) closeActive() error {
+	name := spool.active.Name()
+	err := spool.active.Sync()
+	if closeErr := spool.active.Close(); err == nil {
+		err = closeErr
+	}
+	spool.mutex.Lock()
+	spool.active = nil
+	if err == nil && !spool.syncFailed {
+		spool.synced = spool.written
+	}
+	spool.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("Cannot close spool segment '%s'. Error: %w", name, err)
+	}
+	return nil
+}
+
+// abandonActive closes the active segment after a failed write or sync, so a partially written line isn't continued by the next reading.
+// Its records are kept like those of a rotated segment, as their readings are pending.
+func (spool *spool) abandonActive() {
+	spool.syncMutex.Lock()
+	defer spool.syncMutex.Unlock()
+
+	if spool.active == nil {
+		return
+	}
+	name := spool.active.Name()
+	spool.active.Close()
+	spool.mutex.Lock()
+	spool.active = nil
+	spool.mutex.Unlock()
+	if spool.activeRecords > 0 {
+		spool.closed = append(spool.closed, filepath.Base(name))
+		return
+	}
+	os.Remove(name)
+}
+
+// rotate closes the active segment, if it holds records, and opens a new one. Returns the number of closed segments
+// whose readings are all part of the next flush
+func (spool *spool) rotate() (int, error) {
+	spool.syncMutex.Lock()
+	defer spool.syncMutex.Unlock()
+
+	if spool.active != nil && spool.activeRecords > 0 {
+		name := filepath.Base(spool.active.Name())
+		if err := spool.closeActive(); err != nil {
+			return len(spool.closed), err
+		}
+		spool.closed = append(spool.closed, name)
+		if err := spool.openSegment(); err != nil {
+			return len(spool.closed), err
+		}
+	}
+	return len(spool.closed), nil
+}
+
+// release removes the oldest count closed segments, as their readings are stored
+func (spool *spool) release(count int) error {
+	for _, name := range spool.closed[:count] {
+		if err := os.Remove(filepath.Join(spool.dir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Cannot remove spool segment '%s'. Error: %w", name, err)
+		}
+	}
+	spool.closed = spool.closed[count:]
+	return syncDir(spool.dir)
+}
+
+// close closes the active segment. An empty active segment is removed
+func (spool *spool) close() error {
+	spool.syncMutex.Lock()
+	defer spool.syncMutex.Unlock()
+
+	if spool.active == nil {
+		return nil
+	}
+	name := spool.active.Name()
+	if err := spool.closeActive(); err != nil {
+		return err
+	}
+	if spool.activeRecords == 0 {
+		return os.Remove(name)
+	}
+	return nil
+}
+
+// syncDir syncs the directory entries of dir, making created and removed segments durable
+func syncDir(dir string) error {
+	directory, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("Cannot open spool directory '%s'. Error: %w", dir, err)
+	}
+	defer directory.Close()
+	if err := directory.Sync(); err != nil {
+		return fmt.Errorf("Cannot sync spool directory '%s'. Error: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package lease
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Leases let one server instance at a time run a background job, eg. archiving readings. A lease is a document per job with its owner and expiry.
+// Owners renew their lease while running. Leases of crashed instances expire and are taken over.
+
+// Owner returns the owner name of this server instance: host name and process id
+func Owner() string {
+	hostName, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", hostName, os.Getpid())
+}
+
+// Acquire acquires the lease name for owner until now+duration, or renews it if owner holds it already.
+// Returns false if another owner holds a lease not expired.
+func Acquire(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, name, owner string, duration time.Duration, now time.Time) (bool, error) {
+	repository := mongoDBInterface.RepositoryInterface
+	expiresAt := now.Add(duration).UTC()
+
+	// Renew own lease or take over an expired one
+	filter := bson.M{"_id": name, "$or": bson.A{bson.M{"owner": owner}, bson.M{"expires_at": bson.M{"$lte": now}}}}
+	result, err := repository.UpdateOneInMongo(ctx, config.DatabaseNameLease, filter, bson.M{"$set": bson.M{"owner": owner, "expires_at": expiresAt}}, config.CollectionNameLease)
+	if err != nil {
+		return false, fmt.Errorf("Error in 'Acquire()' using 'UpdateOneInMongo()' for lease '%s'. Error: %w", name, err)
+	}
+	if result.MatchedCount > 0 {
+		return true, nil
+	}
+
+	// No lease yet. Another owner creating it concurrently holds it
+	_, err = repository.InsertOneToMongo(ctx, config.DatabaseNameLease, model.Lease{ID: name, Owner: owner, ExpiresAt: expiresAt}, config.CollectionNameLease)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Error in 'Acquire()' using 'InsertOneToMongo()' for lease '%s'. Error: %w", name, err)
+	}
+	return true, nil
+}
+
+// Release releases the lease name if held by owner, so another instance can acquire it right away
+func Release(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, name, owner string) error {
+	_, err := mongoDBInterface.RepositoryInterface.DeleteDocumentMongo(ctx, config.DatabaseNameLease, bson.M{"_id": name, "owner": owner}, config.CollectionNameLease)
+	if err != nil {
+		return fmt.Errorf("Error in 'Release()' using 'DeleteDocumentMongo()' for lease '%s'. Error: %w", name, err)
+	}
+	return nil
+}
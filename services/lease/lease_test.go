@@ -0,0 +1,56 @@
+package lease
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquire(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	acquired, err := Acquire(ctx, mongoDBInterface, "retention", "host-a:1", time.Minute, now)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// Held by another instance until expired
+	acquired, err = Acquire(ctx, mongoDBInterface, "retention", "host-b:2", time.Minute, now.Add(30*time.Second))
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	// Renewed by its owner
+	acquired, err = Acquire(ctx, mongoDBInterface, "retention", "host-a:1", time.Minute, now.Add(50*time.Second))
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	acquired, err = Acquire(ctx, mongoDBInterface, "retention", "host-b:2", time.Minute, now.Add(90*time.Second))
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	// Taken over once expired, eg. after a crash
+	acquired, err = Acquire(ctx, mongoDBInterface, "retention", "host-b:2", time.Minute, now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	acquired, err = Acquire(ctx, mongoDBInterface, "retention", "host-a:1", time.Minute, now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	// Other leases are independent
+	acquired, err = Acquire(ctx, mongoDBInterface, "rollup", "host-a:1", time.Minute, now)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// Released by its owner only
+	assert.NoError(t, Release(ctx, mongoDBInterface, "retention", "host-a:1"))
+	acquired, err = Acquire(ctx, mongoDBInterface, "retention", "host-a:1", time.Minute, now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+	assert.NoError(t, Release(ctx, mongoDBInterface, "retention", "host-b:2"))
+	acquired, err = Acquire(ctx, mongoDBInterface, "retention", "host-a:1", time.Minute, now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+}
@@ -0,0 +1,213 @@
+package loggerhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Actions of plant owners amending readings
+var AmendmentActions = []string{"void", "unvoid", "annotate", "correct"}
+
+// Amendment of readings requested by the plant owner
+type Amendment struct {
+	Action     string
+	Reason     string
+	Annotation string             // New annotation, action 'annotate' only. Empty removes the annotation
+	Values     map[string]float64 // Corrected values by channel name, action 'correct' only. Values of other channels are kept
+}
+
+// IsAmendmentAction validates if action is one of AmendmentActions
+func IsAmendmentAction(action string) bool {
+	return slices.Contains(AmendmentActions, action)
+}
+
+// AuditCollectionName returns the name of the plant's audit collection, sharing the id of its logger collection
+// Eg. 'plant_audit_123456789012' for logger collection 'plant_logger_123456789012'
+func AuditCollectionName(collectionNameLogger string) string {
+	return "plant_audit_" + strings.TrimPrefix(collectionNameLogger, "plant_logger_")
+}
+
+// ExcludeVoided restricts a filter of readings to readings not voided by the plant owner. Statistics and exports use it by default.
+func ExcludeVoided(filter bson.M) bson.M {
+	filter["voided"] = bson.M{"$ne": true}
+	return filter
+}
+
+// StateOfReading returns the state of a stored reading subject to amendments. Values of readings stored before channel schemas existed are read from their legacy fields.
+func StateOfReading(plantLog model.PlantLogger) model.ReadingState {
+	values := map[string]float64{}
+	if plantLog.Values != nil {
+		values = maps.Clone(plantLog.Values)
+	} else {
+		for _, channel := range DefaultChannels() {
+			values[channel.Name], _ = ChannelValue(plantLog, channel.Name)
+		}
+	}
+	return model.ReadingState{Values: values, Voided: plantLog.Voided, Corrected: plantLog.Corrected, Annotation: plantLog.Annotation}
+}
+
+// ParseCorrectedValues converts corrected values of the parsed request body, an object of values by channel name.
+// Each channel must be defined in the channel schema and each value must be a number within min and max of its channel. The returned error is suitable for the response.
+func ParseCorrectedValues(raw interface{}, channels []model.Channel) (map[string]float64, error) {
+	data, ok := raw.(map[string]interface{})
+	if !ok || len(data) == 0 {
+		return nil, errors.New("'values' must be a non-empty object of values by channel name.")
+	}
+	values := make(map[string]float64, len(data))
+	for name, rawValue := range data {
+		channel, defined := FindChannel(channels, name)
+		if !defined {
+			return nil, fmt.Errorf("Channel '%s' is not defined for this plant.", name)
+		}
+		value, ok := rawValue.(float64)
+		if !ok {
+			return nil, fmt.Errorf("Value of channel '%s' must be a number.", name)
+		}
+		if channel.Min != nil && value < *channel.Min {
+			return nil, fmt.Errorf("Value of channel '%s' is below its minimum %v.", name, *channel.Min)
+		}
+		if channel.Max != nil && value > *channel.Max {
+			return nil, fmt.Errorf("Value of channel '%s' exceeds its maximum %v.", name, *channel.Max)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// Amend applies amendment to the state of a reading. Returns the new state and if the reading changes.
+// Corrected values are merged into the values of the reading, the result must be physically plausible. The returned error is suitable for the response.
+func Amend(before model.ReadingState, amendment Amendment) (model.ReadingState, bool, error) {
+	after := before
+	switch amendment.Action {
+	case "void":
+		after.Voided = true
+	case "unvoid":
+		after.Voided = false
+	case "annotate":
+		after.Annotation = amendment.Annotation
+	case "correct":
+		after.Values = maps.Clone(before.Values)
+		maps.Copy(after.Values, amendment.Values)
+		if maps.Equal(after.Values, before.Values) {
+			return before, false, nil
+		}
+		if err := CheckPlausibility(after.Values); err != nil {
+			return before, false, err
+		}
+		after.Corrected = true
+		return after, true, nil
+	default:
+		return before, false, fmt.Errorf("'action' must be one of: %s.", strings.Join(AmendmentActions, ", "))
+	}
+	return after, after.Voided != before.Voided || after.Annotation != before.Annotation, nil
+}
+
+// amendmentUpdate returns the update of a reading changed to state after by action at now.
+// 'amended_at' lets rollups of the reading's period be recomputed.
+func amendmentUpdate(action string, after model.ReadingState, now time.Time) bson.M {
+	amendedAt := bson.M{"amended_at": now.UTC()}
+	switch action {
+	case "void":
+		return bson.M{"$set": bson.M{"voided": true, "amended_at": now.UTC()}}
+	case "unvoid":
+		return bson.M{"$set": amendedAt, "$unset": bson.M{"voided": ""}}
+	case "annotate":
+		if after.Annotation == "" {
+			return bson.M{"$set": amendedAt, "$unset": bson.M{"annotation": ""}}
+		}
+		return bson.M{"$set": bson.M{"annotation": after.Annotation, "amended_at": now.UTC()}}
+	}
+	// Legacy fields of readings stored before channel schemas existed are ignored once values are stored, see ChannelValue
+	return bson.M{"$set": bson.M{"values": after.Values, "corrected": true, "amended_at": now.UTC()}}
+}
+
+// FindReadingsToAmend returns the readings of the plant logger collection collectionNameLogger matching filter. At most ReadingAmendmentMaxReadings are amended
+// per request, so one more is read at most: more returned readings than ReadingAmendmentMaxReadings mean the selection is too large.
+func FindReadingsToAmend(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, filter bson.M) ([]model.PlantLogger, error) {
+	findOptions := mongodb.FindOptions{Limit: int64(config.ReadingAmendmentMaxReadings) + 1, BatchSize: config.DatabaseCursorBatchSize}
+	cursor, err := mongoDBInterface.RepositoryInterface.FindCursorInMongo(ctx, config.DatabaseNamePlantLogger, filter, collectionNameLogger, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("Error in 'FindReadingsToAmend()' using 'FindCursorInMongo()' in collection '%s' part of database '%s'. Error: %w", collectionNameLogger, config.DatabaseNamePlantLogger, err)
+	}
+	defer cursor.Close(ctx)
+
+	readings := []model.PlantLogger{}
+	for cursor.Next(ctx) {
+		var reading model.PlantLogger
+		if err := cursor.Decode(&reading); err != nil {
+			return nil, fmt.Errorf("Error in 'FindReadingsToAmend()' using 'Decode()' in collection '%s'. Error: %w", collectionNameLogger, err)
+		}
+		readings = append(readings, reading)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("Error in 'FindReadingsToAmend()' using 'Next()' in collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+	return readings, nil
+}
+
+// AmendReadings applies amendment to readings of the plant logger collection collectionNameLogger on behalf of user.
+// Each changed reading is recorded in the plant's audit collection before it's updated, so no change is ever made without audit entry.
+// Readings not changed by the amendment, eg. voiding voided readings, are skipped. Returns the number of amended readings.
+func AmendReadings(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, readings []model.PlantLogger, amendment Amendment, user primitive.ObjectID, now time.Time) (int, error) {
+	audits := []interface{}{}
+	ids := []primitive.ObjectID{}
+	updates := []bson.M{}
+	for _, reading := range readings {
+		before := StateOfReading(reading)
+		after, changed, err := Amend(before, amendment)
+		if err != nil {
+			return 0, err
+		}
+		if !changed {
+			continue
+		}
+		audits = append(audits, model.PlantLogAudit{
+			ID:        primitive.NewObjectID(),
+			ReadingID: reading.ID,
+			Action:    amendment.Action,
+			Reason:    amendment.Reason,
+			User:      user,
+			Before:    before,
+			After:     after,
+			CreatedAt: now.UTC(),
+		})
+		ids = append(ids, reading.ID)
+		updates = append(updates, amendmentUpdate(amendment.Action, after, now))
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	_, err := mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, audits, AuditCollectionName(collectionNameLogger))
+	if err != nil {
+		return 0, fmt.Errorf("Error in 'AmendReadings()' using 'InsertManyToMongo()' storing audit entries of collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+
+	// Corrections differ per reading. All other actions update readings alike
+	if amendment.Action == "correct" {
+		for index, id := range ids {
+			_, err := mongoDBInterface.RepositoryInterface.UpdateOneInMongo(ctx, config.DatabaseNamePlantLogger, bson.M{"_id": id}, updates[index], collectionNameLogger)
+			if err != nil {
+				return 0, fmt.Errorf("Error in 'AmendReadings()' using 'UpdateOneInMongo()' correcting reading %s of collection '%s'. Error: %w", id.Hex(), collectionNameLogger, err)
+			}
+		}
+		return len(ids), nil
+	}
+	_, err = mongoDBInterface.RepositoryInterface.UpdateManyInMongo(ctx, config.DatabaseNamePlantLogger, bson.M{"_id": bson.M{"$in": ids}}, updates[0], collectionNameLogger)
+	if err != nil {
+		return 0, fmt.Errorf("Error in 'AmendReadings()' using 'UpdateManyInMongo()' amending readings of collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,114 @@
+package loggerhandler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	"github.com/stretchr/testify/assert"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAuditCollectionName(t *testing.T) {
+	assert.Equal(t, "plant_audit_123456789012", AuditCollectionName("plant_logger_123456789012"))
+}
+
+func TestExcludeVoided(t *testing.T) {
+	filter := ExcludeVoided(bson.M{"device_id": "inverter-1"})
+	assert.Equal(t, bson.M{"device_id": "inverter-1", "voided": bson.M{"$ne": true}}, filter)
+}
+
+func TestFindReadingsToAmend(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	collectionNameLogger := "plant_logger_160001"
+	measuredAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	readings := []interface{}{}
+	for i := 0; i < config.ReadingAmendmentMaxReadings+5; i++ {
+		readings = append(readings, model.PlantLogger{ID: primitive.NewObjectID(), MeasuredAt: measuredAt.Add(time.Duration(i) * time.Second)})
+	}
+	_, err := mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, readings, collectionNameLogger)
+	assert.NoError(t, err)
+
+	// One reading more than amended at most is read
+	found, err := FindReadingsToAmend(ctx, mongoDBInterface, collectionNameLogger, bson.M{})
+	assert.NoError(t, err)
+	assert.Len(t, found, config.ReadingAmendmentMaxReadings+1)
+
+	found, err = FindReadingsToAmend(ctx, mongoDBInterface, collectionNameLogger, bson.M{"measured_at": measuredAt})
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+}
+
+func TestStateOfReadingLegacy(t *testing.T) {
+	state := StateOfReading(model.PlantLogger{PowerOutput: 500, VoltageOutput: 50, CurrentOutput: 10, Annotation: "Cleaned modules"})
+	assert.Len(t, state.Values, len(DefaultChannels()))
+	assert.Equal(t, 500.0, state.Values["powerOutput"])
+	assert.Equal(t, 0.0, state.Values["windSpeed"])
+	assert.Equal(t, "Cleaned modules", state.Annotation)
+}
+
+func TestAmendVoid(t *testing.T) {
+	before := model.ReadingState{Values: map[string]float64{"powerOutput": 500}}
+
+	after, changed, err := Amend(before, Amendment{Action: "void", Reason: "Logger test by technician"})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, after.Voided)
+	assert.False(t, before.Voided)
+
+	// Voiding voided readings doesn't change them
+	_, changed, err = Amend(after, Amendment{Action: "void", Reason: "Logger test by technician"})
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	after, changed, _ = Amend(after, Amendment{Action: "unvoid", Reason: "Valid after all"})
+	assert.True(t, changed)
+	assert.False(t, after.Voided)
+}
+
+func TestAmendCorrect(t *testing.T) {
+	before := model.ReadingState{Values: map[string]float64{"voltageOutput": 50, "currentOutput": 10, "powerOutput": 5000, "tModule": 40}}
+
+	// Other values are kept, the original values remain unchanged
+	after, changed, err := Amend(before, Amendment{Action: "correct", Values: map[string]float64{"powerOutput": 500}})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, after.Corrected)
+	assert.Equal(t, map[string]float64{"voltageOutput": 50, "currentOutput": 10, "powerOutput": 500, "tModule": 40}, after.Values)
+	assert.Equal(t, 5000.0, before.Values["powerOutput"])
+
+	// Corrections must be plausible
+	_, _, err = Amend(before, Amendment{Action: "correct", Values: map[string]float64{"powerOutput": 4000}})
+	assert.Error(t, err)
+
+	// Unchanged values
+	_, changed, err = Amend(after, Amendment{Action: "correct", Values: map[string]float64{"powerOutput": 500}})
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestParseCorrectedValues(t *testing.T) {
+	max := 1000.0
+	channels := []model.Channel{{Name: "powerOutput", Unit: "W", Required: true, Max: &max}}
+
+	values, err := ParseCorrectedValues(map[string]interface{}{"powerOutput": 800.0}, channels)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"powerOutput": 800}, values)
+
+	for _, raw := range []interface{}{
+		map[string]interface{}{},
+		map[string]interface{}{"powerOutput": 1200.0},
+		map[string]interface{}{"powerOutput": "800"},
+		map[string]interface{}{"rearIrradiance": 800.0},
+		[]interface{}{800.0},
+	} {
+		_, err := ParseCorrectedValues(raw, channels)
+		assert.Error(t, err, raw)
+	}
+}
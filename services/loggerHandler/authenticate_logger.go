@@ -0,0 +1,173 @@
+package loggerhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	crypto "github.com/paulmuenzner/powerplantmanager/utils/crypto"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Errors returned by AuthenticateLogger. Callers decide on the response, but should not reveal the exact reason to the client.
+var (
+	ErrLoggerNotFound   = errors.New("no plant logger config found for provided key")
+	ErrURLIDInvalid     = errors.New("url id does not match plant logger config")
+	ErrSecretInvalid    = errors.New("secret does not match plant logger config")
+	ErrIPNotWhitelisted = errors.New("ip address is not whitelisted for plant logger")
+	ErrSchemeNotAllowed = errors.New("authentication scheme is not enabled for plant logger")
+	ErrPlantIDInvalid   = errors.New("public plant id does not match plant logger config")
+)
+
+// AuthenticateLogger finds the plant logger config by key and validates url id (apiID), secret and ip whitelist.
+// The key may also belong to a device of the plant with own credentials. The device is returned in this case, otherwise an empty device.
+// Used by every route and channel receiving plant logs, so a logger is authenticated exactly once per request.
+func AuthenticateLogger(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, key, secret, apiID, normalizedIP string) (model.PlantLoggerConfig, model.PlantDevice, error) {
+	plantConfig, device, err := findLoggerConfig(ctx, mongoDBInterface, key, apiID)
+	if err != nil {
+		return plantConfig, device, err
+	}
+
+	// Plants migrated to signed requests don't accept the secret anymore
+	if !AllowsAuthScheme(plantConfig, model.AuthSchemeSecret) {
+		return plantConfig, device, ErrSchemeNotAllowed
+	}
+
+	// Validate secret
+	if !crypto.IsHashValid(secret, loggerSecret(plantConfig, device)) {
+		return plantConfig, device, ErrSecretInvalid
+	}
+
+	// Validate ip against white list
+	if !IsIPWhitelisted(plantConfig, normalizedIP) {
+		return plantConfig, device, ErrIPNotWhitelisted
+	}
+
+	return plantConfig, device, nil
+}
+
+// AuthenticateMQTTLogger authenticates a plant logger publishing via MQTT by key and secret of the message.
+// Instead of url id, the public plant id of the topic must match. The ip whitelist doesn't apply, as messages are relayed by the broker.
+func AuthenticateMQTTLogger(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, key, secret, publicPlantID string) (model.PlantLoggerConfig, model.PlantDevice, error) {
+	plantConfig, device, err := findLoggerConfigByKey(ctx, mongoDBInterface, key)
+	if err != nil {
+		return plantConfig, device, err
+	}
+	if plantConfig.PublicPlantID != publicPlantID {
+		return plantConfig, device, ErrPlantIDInvalid
+	}
+	if !AllowsAuthScheme(plantConfig, model.AuthSchemeSecret) {
+		return plantConfig, device, ErrSchemeNotAllowed
+	}
+	if !crypto.IsHashValid(secret, loggerSecret(plantConfig, device)) {
+		return plantConfig, device, ErrSecretInvalid
+	}
+	return plantConfig, device, nil
+}
+
+// findLoggerConfig finds the plant logger config by key and validates url id (apiID)
+func findLoggerConfig(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, key, apiID string) (model.PlantLoggerConfig, model.PlantDevice, error) {
+	plantConfig, device, err := findLoggerConfigByKey(ctx, mongoDBInterface, key)
+	if err != nil {
+		return plantConfig, device, err
+	}
+
+	// Validate url id
+	if plantConfig.URLID != apiID {
+		return plantConfig, device, ErrURLIDInvalid
+	}
+
+	return plantConfig, device, nil
+}
+
+// findLoggerConfigByKey finds the plant logger config by key
+// Keys not belonging to a plant are looked up in the plant devices. The plant logger config of the device's plant is returned together with the device.
+// Found configs are cached for LoggerConfigCacheTTLSec, see InvalidateLoggerConfig.
+func findLoggerConfigByKey(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, key string) (model.PlantLoggerConfig, model.PlantDevice, error) {
+	entry, generation, cached := loggerConfigs.get(key, time.Now())
+	if cached {
+		return entry.plantConfig, entry.device, nil
+	}
+	plantConfig, device, err := readLoggerConfigByKey(ctx, mongoDBInterface, key)
+	if err != nil {
+		return plantConfig, device, err
+	}
+	loggerConfigs.put(key, plantConfig, device, generation, time.Now())
+	return plantConfig, device, nil
+}
+
+// readLoggerConfigByKey reads the plant logger config and, for device keys, the device of key from the database
+func readLoggerConfigByKey(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, key string) (model.PlantLoggerConfig, model.PlantDevice, error) {
+	var plantConfig model.PlantLoggerConfig
+	var device model.PlantDevice
+
+	// Find plant by provided key
+	var filter bson.M = bson.M{"key": key}
+	var sort bson.D = bson.D{}
+	findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(ctx, config.DatabaseNamePlantLoggerConfig, filter, config.CollectionNamePlantLoggerConfig, sort, &plantConfig)
+	if err != nil {
+		return plantConfig, device, fmt.Errorf("Error in 'readLoggerConfigByKey()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s' for plant logging key %s. Error: %w", config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, key, err)
+	}
+
+	// Find device by provided key and its plant
+	if !findOne {
+		findOne, err = mongoDBInterface.RepositoryInterface.FindOneInMongo(ctx, config.DatabaseNamePlantDevice, filter, config.CollectionNamePlantDevice, sort, &device)
+		if err != nil {
+			return plantConfig, device, fmt.Errorf("Error in 'readLoggerConfigByKey()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s' for device logging key %s. Error: %w", config.CollectionNamePlantDevice, config.DatabaseNamePlantDevice, key, err)
+		}
+		if !findOne {
+			return plantConfig, device, ErrLoggerNotFound
+		}
+
+		var filterPlant bson.M = bson.M{"public_plant_id": device.PublicPlantID}
+		findOne, err = mongoDBInterface.RepositoryInterface.FindOneInMongo(ctx, config.DatabaseNamePlantLoggerConfig, filterPlant, config.CollectionNamePlantLoggerConfig, sort, &plantConfig)
+		if err != nil {
+			return plantConfig, device, fmt.Errorf("Error in 'readLoggerConfigByKey()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s' for plant %s of device %s. Error: %w", config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, device.PublicPlantID, device.DeviceID, err)
+		}
+		if !findOne {
+			return plantConfig, device, ErrLoggerNotFound
+		}
+	}
+
+	return plantConfig, device, nil
+}
+
+// loggerSecret returns the hashed secret of the device if authenticated with device credentials, otherwise of the plant
+func loggerSecret(plantConfig model.PlantLoggerConfig, device model.PlantDevice) string {
+	if device.DeviceID != "" {
+		return device.Secret
+	}
+	return plantConfig.Secret
+}
+
+// loggerSigningKey returns the encrypted signing key of the device if authenticated with device credentials, otherwise of the plant
+func loggerSigningKey(plantConfig model.PlantLoggerConfig, device model.PlantDevice) string {
+	if device.DeviceID != "" {
+		return device.SigningKey
+	}
+	return plantConfig.SigningKey
+}
+
+// AllowsAuthScheme checks if the plant logger accepts requests authenticated with scheme. Configs without scheme only accept the secret
+func AllowsAuthScheme(plantConfig model.PlantLoggerConfig, scheme string) bool {
+	configured := plantConfig.AuthScheme
+	if configured == "" {
+		configured = model.AuthSchemeSecret
+	}
+	return configured == scheme || configured == model.AuthSchemeBoth
+}
+
+// IsIPWhitelisted checks if the normalized ip address is part of the plant's ip whitelist
+func IsIPWhitelisted(plantConfig model.PlantLoggerConfig, normalizedIP string) bool {
+	for _, ip := range plantConfig.IPWhitelist {
+		if ip == normalizedIP {
+			return true
+		}
+	}
+	return false
+}
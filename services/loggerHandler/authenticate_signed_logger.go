@@ -0,0 +1,165 @@
+package loggerhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	crypto "github.com/paulmuenzner/powerplantmanager/utils/crypto"
+	env "github.com/paulmuenzner/powerplantmanager/utils/env"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Headers of a signed plant logger request
+const (
+	HeaderPlantKey       string = "X-Plant-Key"
+	HeaderPlantTimestamp string = "X-Plant-Timestamp" // Unix time in seconds
+	HeaderPlantNonce     string = "X-Plant-Nonce"     // Random value, unique per request
+	HeaderPlantSignature string = "X-Plant-Signature" // Hex encoded HMAC-SHA256 of the canonical request
+)
+
+// Errors returned by AuthenticateSignedLogger in addition to those of AuthenticateLogger
+var (
+	ErrSignatureHeadersInvalid = errors.New("signature headers missing or malformed")
+	ErrSignatureExpired        = errors.New("signature timestamp outside of accepted time window")
+	ErrSignatureInvalid        = errors.New("signature does not match plant logger config")
+	ErrNonceReused             = errors.New("nonce has already been used")
+)
+
+// IsSignedRequest reports if a plant logger request carries a signature header and must be authenticated by AuthenticateSignedLogger
+func IsSignedRequest(r *http.Request) bool {
+	return r.Header.Get(HeaderPlantSignature) != ""
+}
+
+// CanonicalRequest builds the message signed by a plant logger. Fields are separated by a line feed:
+// HTTP method, URL path, timestamp, nonce and hex encoded SHA-256 hash of the request body
+func CanonicalRequest(method, path, timestamp, nonce string, body []byte) string {
+	return strings.Join([]string{strings.ToUpper(method), path, timestamp, nonce, crypto.SHA256Hex(body)}, "\n")
+}
+
+// requestPath returns the path requested by the logger. Subrouters strip their prefix from the url path, eg. '/plants', the request uri keeps it
+func requestPath(r *http.Request) string {
+	requestURI, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil || requestURI.Path == "" {
+		return r.URL.Path
+	}
+	return requestURI.Path
+}
+
+// AuthenticateSignedLogger authenticates a plant logger request signed with HMAC-SHA256 (see CanonicalRequest) instead of secret.
+// It validates key, url id (apiID), timestamp, signature and ip whitelist and finally stores the nonce to reject replays.
+// Returns the plant logger config, the device if signed with device credentials (see AuthenticateLogger) and the key provided by header.
+func AuthenticateSignedLogger(mongoDBInterface *mongodb.MethodInterface, r *http.Request, rawBody []byte, apiID, normalizedIP string, now time.Time) (model.PlantLoggerConfig, model.PlantDevice, string, error) {
+	key := r.Header.Get(HeaderPlantKey)
+	timestamp := r.Header.Get(HeaderPlantTimestamp)
+	nonce := r.Header.Get(HeaderPlantNonce)
+	signature := r.Header.Get(HeaderPlantSignature)
+	if key == "" || timestamp == "" || nonce == "" || signature == "" || len(nonce) > config.LoggerNonceMaxLength {
+		return model.PlantLoggerConfig{}, model.PlantDevice{}, key, ErrSignatureHeadersInvalid
+	}
+
+	// Reject stale or future timestamps. Together with the nonce store this prevents replays
+	timestampSec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return model.PlantLoggerConfig{}, model.PlantDevice{}, key, ErrSignatureHeadersInvalid
+	}
+	if absDuration(now.Sub(time.Unix(timestampSec, 0))) > time.Second*time.Duration(config.LoggerSignatureMaxAgeSec) {
+		return model.PlantLoggerConfig{}, model.PlantDevice{}, key, ErrSignatureExpired
+	}
+
+	plantConfig, device, err := findLoggerConfig(r.Context(), mongoDBInterface, key, apiID)
+	if err != nil {
+		return plantConfig, device, key, err
+	}
+	if !AllowsAuthScheme(plantConfig, model.AuthSchemeHMAC) {
+		return plantConfig, device, key, ErrSchemeNotAllowed
+	}
+
+	// Validate signature with stored signing key. Keys created before signed requests existed have no signing key
+	encryptedSigningKey := loggerSigningKey(plantConfig, device)
+	if encryptedSigningKey == "" {
+		return plantConfig, device, key, ErrSignatureInvalid
+	}
+	signingKey, err := DecryptSigningKey(encryptedSigningKey)
+	if err != nil {
+		return plantConfig, device, key, err
+	}
+	if !crypto.IsHMACValid(signingKey, CanonicalRequest(r.Method, requestPath(r), timestamp, nonce, rawBody), signature) {
+		return plantConfig, device, key, ErrSignatureInvalid
+	}
+
+	// Validate ip against white list
+	if !IsIPWhitelisted(plantConfig, normalizedIP) {
+		return plantConfig, device, key, ErrIPNotWhitelisted
+	}
+
+	// Store nonce. Fails with duplicate key if already used within its lifetime
+	if err := storeNonce(r.Context(), mongoDBInterface, key, nonce, now); err != nil {
+		return plantConfig, device, key, err
+	}
+
+	return plantConfig, device, key, nil
+}
+
+// EncryptSigningKey derives the signing key from a plant logger secret and encrypts it with the .env key for storing it in PlantLoggerConfig
+func EncryptSigningKey(secret string) (string, error) {
+	keyHex, err := env.GetEnvValue(config.LoggerSigningKeyEnv, "")
+	if err != nil {
+		return "", err
+	}
+	return crypto.EncryptWithKeyHex(crypto.DeriveSigningKey(secret), keyHex)
+}
+
+// DecryptSigningKey decrypts a signing key stored in PlantLoggerConfig
+func DecryptSigningKey(encryptedSigningKey string) (string, error) {
+	keyHex, err := env.GetEnvValue(config.LoggerSigningKeyEnv, "")
+	if err != nil {
+		return "", err
+	}
+	signingKey, err := crypto.DecryptWithKeyHex(encryptedSigningKey, keyHex)
+	if err != nil {
+		return "", fmt.Errorf("Error in 'DecryptSigningKey()' using 'DecryptWithKeyHex()'. Error: %v", err)
+	}
+	return signingKey, nil
+}
+
+// Creation of nonce collection indexes is needed once per process
+var (
+	nonceIndexMutex   sync.Mutex
+	nonceIndexCreated bool
+)
+
+// storeNonce saves the nonce of a signed request. Returns ErrNonceReused if the same logger used the nonce before.
+func storeNonce(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, key, nonce string, now time.Time) error {
+	nonceIndexMutex.Lock()
+	if !nonceIndexCreated {
+		// Nonces expire once their timestamp would be rejected anyway
+		err := mongoDBInterface.RepositoryInterface.CreateTTLIndex(ctx, config.CollectionNamePlantLoggerNonce, config.DatabaseNamePlantLoggerNonce, "created_at", int32(config.LoggerNonceLifetimeSec))
+		if err != nil {
+			nonceIndexMutex.Unlock()
+			return fmt.Errorf("Error in 'storeNonce()' using 'CreateTTLIndex()'. Error: %w", err)
+		}
+		nonceIndexCreated = true
+	}
+	nonceIndexMutex.Unlock()
+
+	plantLoggerNonce := model.PlantLoggerNonce{ID: key + ":" + nonce, CreatedAt: now}
+	_, err := mongoDBInterface.RepositoryInterface.InsertOneToMongo(ctx, config.DatabaseNamePlantLoggerNonce, plantLoggerNonce, config.CollectionNamePlantLoggerNonce)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrNonceReused
+	}
+	if err != nil {
+		return fmt.Errorf("Error in 'storeNonce()' using 'InsertOneToMongo()'. Error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package loggerhandler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/utils/aws"
+	crypto "github.com/paulmuenzner/powerplantmanager/utils/crypto"
+	"github.com/paulmuenzner/powerplantmanager/utils/email"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	server "github.com/paulmuenzner/powerplantmanager/utils/server"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCanonicalRequest(t *testing.T) {
+	body := []byte(`{"powerOutput":114.8}`)
+	canonical := CanonicalRequest("post", "/plants/log/123", "1710064800", "a1b2c3", body)
+
+	assert.Equal(t, "POST\n/plants/log/123\n1710064800\na1b2c3\n"+crypto.SHA256Hex(body), canonical)
+}
+
+func TestSignatureVerification(t *testing.T) {
+	t.Setenv(config.LoggerSigningKeyEnv, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	secret := "c2a1d375159502956e552e0e5d57de6735ec"
+	canonical := CanonicalRequest("POST", "/plants/log/123", "1710064800", "a1b2c3", []byte(`{}`))
+
+	// Logger side
+	signature, err := crypto.SignHMAC(crypto.DeriveSigningKey(secret), canonical)
+	assert.NoError(t, err)
+
+	// Server side with stored, encrypted signing key
+	encryptedSigningKey, err := EncryptSigningKey(secret)
+	assert.NoError(t, err)
+	signingKey, err := DecryptSigningKey(encryptedSigningKey)
+	assert.NoError(t, err)
+
+	assert.True(t, crypto.IsHMACValid(signingKey, canonical, signature))
+	assert.False(t, crypto.IsHMACValid(signingKey, canonical+"x", signature))
+	assert.False(t, crypto.IsHMACValid(signingKey, canonical, "not hex"))
+	assert.False(t, crypto.IsHMACValid(crypto.DeriveSigningKey("other secret"), canonical, signature))
+}
+
+func TestSignedRequestThroughSubrouter(t *testing.T) {
+	t.Setenv(config.LoggerSigningKeyEnv, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	secret := "c2a1d375159502956e552e0e5d57de6735ec"
+	encryptedSigningKey, err := EncryptSigningKey(secret)
+	assert.NoError(t, err)
+	mongoDBInterface := memory.NewMethodInterface()
+	plantConfig := model.PlantLoggerConfig{ID: primitive.NewObjectID(), Key: "signed-subrouter-key", URLID: "123", SigningKey: encryptedSigningKey, AuthScheme: model.AuthSchemeHMAC, IPWhitelist: []string{"192.0.2.1"}}
+	_, err = mongoDBInterface.RepositoryInterface.InsertOneToMongo(context.Background(), config.DatabaseNamePlantLoggerConfig, plantConfig, config.CollectionNamePlantLoggerConfig)
+	assert.NoError(t, err)
+
+	// Handler mounted below '/plants' like the plant routes
+	var authErr error
+	plantsSubrouter := func(awsInterface *aws.MethodInterface, emailInterface *email.RepositoryInterface, mongoDBInterface *mongodb.MethodInterface) *mux.Router {
+		plantRouter := mux.NewRouter()
+		plantRouter.HandleFunc("/log/{apiID:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+			_, _, _, authErr = AuthenticateSignedLogger(mongoDBInterface, r, []byte(`{}`), mux.Vars(r)["apiID"], "192.0.2.1", time.Now())
+		}).Methods("POST")
+		return plantRouter
+	}
+	router := mux.NewRouter()
+	server.CreateSubrouter(router, "/plants", plantsSubrouter, nil, nil, mongoDBInterface)
+
+	for index, path := range []string{"/plants/log/123", "/log/123"} {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonce := "nonce" + strconv.Itoa(index)
+		signature, err := crypto.SignHMAC(crypto.DeriveSigningKey(secret), CanonicalRequest("POST", path, timestamp, nonce, []byte(`{}`)))
+		assert.NoError(t, err)
+		r := httptest.NewRequest("POST", "/plants/log/123", strings.NewReader(`{}`))
+		r.Header.Set(HeaderPlantKey, plantConfig.Key)
+		r.Header.Set(HeaderPlantTimestamp, timestamp)
+		r.Header.Set(HeaderPlantNonce, nonce)
+		r.Header.Set(HeaderPlantSignature, signature)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+
+		// Only the full path documented is signed
+		if index == 0 {
+			assert.NoError(t, authErr)
+		} else {
+			assert.ErrorIs(t, authErr, ErrSignatureInvalid)
+		}
+	}
+}
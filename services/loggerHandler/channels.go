@@ -0,0 +1,216 @@
+package loggerhandler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	v "github.com/paulmuenzner/powerplantmanager/utils/validate"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Request keys of a reading which cannot be used as channel name
+var reservedChannelNames = []string{"key", "secret", "readings", "measuredAt", "sequence", "idempotencyKey"}
+
+// DefaultChannels returns the channel schema of plants without own channels. Matches the measurements of readings stored before channel schemas existed
+func DefaultChannels() []model.Channel {
+	return []model.Channel{
+		{Name: "voltageOutput", Unit: "V", Required: true},
+		{Name: "currentOutput", Unit: "A", Required: true},
+		{Name: "powerOutput", Unit: "W", Required: true},
+		{Name: "solarRadiation", Unit: "W/m2", Required: true},
+		{Name: "tAmbient", Unit: "°C", Required: true},
+		{Name: "tModule", Unit: "°C", Required: true},
+		{Name: "relHumidity", Unit: "%", Required: true},
+		{Name: "windSpeed", Unit: "m/s", Required: true},
+	}
+}
+
+// EffectiveChannels returns the channel schema of a plant or the default channels for plants without own channels
+func EffectiveChannels(plantConfig model.PlantLoggerConfig) []model.Channel {
+	if len(plantConfig.Channels) == 0 {
+		return DefaultChannels()
+	}
+	return plantConfig.Channels
+}
+
+// FindChannel returns the channel with name of a channel schema
+func FindChannel(channels []model.Channel, name string) (model.Channel, bool) {
+	for _, channel := range channels {
+		if channel.Name == name {
+			return channel, true
+		}
+	}
+	return model.Channel{}, false
+}
+
+// ChannelNames returns the names of required or optional channels of a channel schema
+func ChannelNames(channels []model.Channel, required bool) []string {
+	names := []string{}
+	for _, channel := range channels {
+		if channel.Required == required {
+			names = append(names, channel.Name)
+		}
+	}
+	return names
+}
+
+// ParseMeasurements converts the measurement values of one reading from the parsed request body into a PlantLogger, validated against the channel schema.
+// Values of required channels must be present, all present values must be numbers within min and max of their channel and physically plausible.
+// ID and timestamps are not set and must be assigned by the caller. The returned error is suitable for the response.
+func ParseMeasurements(data map[string]interface{}, channels []model.Channel) (model.PlantLogger, error) {
+	values := make(map[string]float64, len(channels))
+	for _, channel := range channels {
+		raw, exists := data[channel.Name]
+		if !exists {
+			if channel.Required {
+				return model.PlantLogger{}, fmt.Errorf("Missing value of required channel '%s'.", channel.Name)
+			}
+			continue
+		}
+		value, ok := raw.(float64)
+		if !ok {
+			return model.PlantLogger{}, fmt.Errorf("Value of channel '%s' must be a number.", channel.Name)
+		}
+		if channel.Min != nil && value < *channel.Min {
+			return model.PlantLogger{}, fmt.Errorf("Value of channel '%s' is below its minimum %v.", channel.Name, *channel.Min)
+		}
+		if channel.Max != nil && value > *channel.Max {
+			return model.PlantLogger{}, fmt.Errorf("Value of channel '%s' exceeds its maximum %v.", channel.Name, *channel.Max)
+		}
+		values[channel.Name] = value
+	}
+
+	// Values have been checked for presence, not for being non-zero. Zero values (eg. power output at night) are valid
+	if err := CheckPlausibility(values); err != nil {
+		return model.PlantLogger{}, err
+	}
+
+	return model.PlantLogger{Values: values}, nil
+}
+
+// ChannelValue returns the value of a channel of a stored reading. Readings stored before channel schemas existed are read from their legacy fields.
+func ChannelValue(plantLog model.PlantLogger, name string) (float64, bool) {
+	if plantLog.Values != nil {
+		value, exists := plantLog.Values[name]
+		return value, exists
+	}
+
+	switch name {
+	case "voltageOutput":
+		return plantLog.VoltageOutput, true
+	case "currentOutput":
+		return plantLog.CurrentOutput, true
+	case "powerOutput":
+		return plantLog.PowerOutput, true
+	case "solarRadiation":
+		return plantLog.SolarRadiation, true
+	case "tAmbient":
+		return plantLog.AmbientTemperature, true
+	case "tModule":
+		return plantLog.ModuleTemperature, true
+	case "relHumidity":
+		return plantLog.RelativeHumidity, true
+	case "windSpeed":
+		return plantLog.WindSpeed, true
+	}
+	return 0, false
+}
+
+// Legacy fields of readings stored before channel schemas existed by channel name, see ChannelValue
+var LegacyFields = map[string]string{
+	"voltageOutput":  "voltage_output",
+	"currentOutput":  "current_output",
+	"powerOutput":    "power_output",
+	"solarRadiation": "solar_radiation",
+	"tAmbient":       "t_ambient",
+	"tModule":        "t_module",
+	"relHumidity":    "rel_humidity",
+	"windSpeed":      "wind_speed",
+}
+
+// ChannelProjection returns the projection of readings on what analyzing channels needs: measurement time, device and the values, including legacy fields of channels
+func ChannelProjection(channels []string) bson.M {
+	projection := bson.M{"measured_at": 1, "device_id": 1, "values": 1}
+	for _, channel := range channels {
+		if field, exists := LegacyFields[channel]; exists {
+			projection[field] = 1
+		}
+	}
+	return projection
+}
+
+// ParseChannels converts a channel schema of the parsed request body into channels.
+// Each channel is an object with 'name', 'unit', 'required' and optional 'min' and 'max'. The returned error is suitable for the response.
+func ParseChannels(raw interface{}) ([]model.Channel, error) {
+	rawChannels, ok := raw.([]interface{})
+	if !ok || len(rawChannels) == 0 {
+		return nil, errors.New("'channels' must be a non-empty array of channels.")
+	}
+	if len(rawChannels) > config.ChannelsMax {
+		return nil, fmt.Errorf("Maximum number of channels per plant: %d", config.ChannelsMax)
+	}
+
+	channels := make([]model.Channel, 0, len(rawChannels))
+	names := []string{}
+	for index, rawChannel := range rawChannels {
+		data, ok := rawChannel.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Channel %d must be an object.", index)
+		}
+		validateKeys := v.Validate(data).
+			HasMapExactKeys(ExpectedReadingKeys(data, []string{"name", "unit", "required"}, []string{"min", "max"}), fmt.Sprintf("Channel %d must contain 'name', 'unit', 'required' and optionally 'min' and 'max'.", index)).
+			GetResult()
+		if len(validateKeys) > 0 {
+			return nil, errors.New(validateKeys[0])
+		}
+
+		name, nameValid := data["name"].(string)
+		if !nameValid || len(name) > config.ChannelNameMaxLength || !config.Regex.Channel.MatchString(name) {
+			return nil, fmt.Errorf("Channel %d: 'name' must start with a letter, contain letters, digits or '_' only and not exceed %d characters.", index, config.ChannelNameMaxLength)
+		}
+		if slices.Contains(reservedChannelNames, name) {
+			return nil, fmt.Errorf("Channel %d: '%s' is reserved and cannot be used as channel name.", index, name)
+		}
+		if slices.Contains(names, name) {
+			return nil, fmt.Errorf("Channel %d: '%s' is defined more than once.", index, name)
+		}
+		names = append(names, name)
+
+		unit, unitValid := data["unit"].(string)
+		if !unitValid || len(unit) > config.ChannelUnitMaxLength {
+			return nil, fmt.Errorf("Channel %d: 'unit' must be a string of at most %d characters.", index, config.ChannelUnitMaxLength)
+		}
+		required, requiredValid := data["required"].(bool)
+		if !requiredValid {
+			return nil, fmt.Errorf("Channel %d: 'required' must be true or false.", index)
+		}
+
+		channel := model.Channel{Name: name, Unit: unit, Required: required}
+		for _, limit := range []string{"min", "max"} {
+			rawLimit, exists := data[limit]
+			if !exists {
+				continue
+			}
+			value, ok := rawLimit.(float64)
+			if !ok {
+				return nil, fmt.Errorf("Channel %d: '%s' must be a number.", index, limit)
+			}
+			if limit == "min" {
+				channel.Min = &value
+			} else {
+				channel.Max = &value
+			}
+		}
+		if channel.Min != nil && channel.Max != nil && *channel.Min > *channel.Max {
+			return nil, fmt.Errorf("Channel %d: 'min' must not exceed 'max'.", index)
+		}
+
+		channels = append(channels, channel)
+	}
+
+	return channels, nil
+}
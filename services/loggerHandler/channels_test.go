@@ -0,0 +1,83 @@
+package loggerhandler
+
+import (
+	"testing"
+
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMeasurements(t *testing.T) {
+	minFrequency, maxFrequency := 45.0, 65.0
+	channels := []model.Channel{
+		{Name: "acPower", Unit: "W", Required: true},
+		{Name: "gridFrequency", Unit: "Hz", Required: true, Min: &minFrequency, Max: &maxFrequency},
+		{Name: "rearIrradiance", Unit: "W/m2", Required: false},
+	}
+
+	testCases := []struct {
+		name          string
+		data          map[string]interface{}
+		expectedError bool
+		expected      map[string]float64
+	}{
+		{"AllChannels", map[string]interface{}{"acPower": 1200.0, "gridFrequency": 50.0, "rearIrradiance": 80.0}, false, map[string]float64{"acPower": 1200, "gridFrequency": 50, "rearIrradiance": 80}},
+		{"OptionalMissing", map[string]interface{}{"acPower": 0.0, "gridFrequency": 50.0}, false, map[string]float64{"acPower": 0, "gridFrequency": 50}},
+		{"RequiredMissing", map[string]interface{}{"gridFrequency": 50.0}, true, nil},
+		{"NoNumber", map[string]interface{}{"acPower": "1200", "gridFrequency": 50.0}, true, nil},
+		{"BelowMinimum", map[string]interface{}{"acPower": 1200.0, "gridFrequency": 40.0}, true, nil},
+		{"AboveMaximum", map[string]interface{}{"acPower": 1200.0, "gridFrequency": 70.0}, true, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plantLog, err := ParseMeasurements(tc.data, channels)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, plantLog.Values)
+		})
+	}
+}
+
+func TestChannelValue(t *testing.T) {
+	legacy := model.PlantLogger{PowerOutput: 114.8, SolarRadiation: 246}
+	value, exists := ChannelValue(legacy, "powerOutput")
+	assert.True(t, exists)
+	assert.Equal(t, 114.8, value)
+	_, exists = ChannelValue(legacy, "acPower")
+	assert.False(t, exists)
+
+	generic := model.PlantLogger{Values: map[string]float64{"acPower": 1200}}
+	value, exists = ChannelValue(generic, "acPower")
+	assert.True(t, exists)
+	assert.Equal(t, 1200.0, value)
+	_, exists = ChannelValue(generic, "powerOutput")
+	assert.False(t, exists)
+}
+
+func TestParseChannels(t *testing.T) {
+	channels, err := ParseChannels([]interface{}{
+		map[string]interface{}{"name": "acPower", "unit": "W", "required": true},
+		map[string]interface{}{"name": "gridFrequency", "unit": "Hz", "required": false, "min": 45.0, "max": 65.0},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, channels, 2)
+	assert.Equal(t, 45.0, *channels[1].Min)
+	assert.Nil(t, channels[0].Max)
+
+	invalid := []interface{}{
+		[]interface{}{},
+		[]interface{}{map[string]interface{}{"name": "acPower", "unit": "W"}},
+		[]interface{}{map[string]interface{}{"name": "1acPower", "unit": "W", "required": true}},
+		[]interface{}{map[string]interface{}{"name": "secret", "unit": "W", "required": true}},
+		[]interface{}{map[string]interface{}{"name": "acPower", "unit": "W", "required": true}, map[string]interface{}{"name": "acPower", "unit": "W", "required": true}},
+		[]interface{}{map[string]interface{}{"name": "acPower", "unit": "W", "required": true, "min": 10.0, "max": 5.0}},
+	}
+	for _, raw := range invalid {
+		_, err := ParseChannels(raw)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,110 @@
+package loggerhandler
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// loggerConfigEntry is a cached plant logger config of a logger key. Device is set if the key belongs to a device
+type loggerConfigEntry struct {
+	plantConfig model.PlantLoggerConfig
+	device      model.PlantDevice
+	expiresAt   time.Time
+}
+
+// loggerConfigCache caches plant logger configs by logger key for a short time. Unknown keys aren't cached, so new keys work immediately.
+// Each invalidation increases the generation. Lookups started in an older generation may have read the old document and aren't cached.
+// Cached configs are shared by requests and must not be modified.
+type loggerConfigCache struct {
+	mutex      sync.Mutex
+	entries    map[string]loggerConfigEntry
+	generation uint64
+	ttl        time.Duration
+	maxEntries int
+	metrics    *expvar.Map
+}
+
+func newLoggerConfigCache(ttl time.Duration, maxEntries int, metrics *expvar.Map) *loggerConfigCache {
+	cache := &loggerConfigCache{entries: map[string]loggerConfigEntry{}, ttl: ttl, maxEntries: maxEntries, metrics: metrics}
+	metrics.Set("entries", expvar.Func(func() any {
+		cache.mutex.Lock()
+		defer cache.mutex.Unlock()
+		return len(cache.entries)
+	}))
+	return cache
+}
+
+// Plant logger configs cached by findLoggerConfigByKey. Metrics are published as expvar 'loggerConfigCache': hits, misses, invalidations and entries
+var loggerConfigs = newLoggerConfigCache(time.Duration(config.LoggerConfigCacheTTLSec)*time.Second, config.LoggerConfigCacheMaxEntries, expvar.NewMap("loggerConfigCache"))
+
+// get returns the unexpired entry of key and the current generation to pass to put after a miss
+func (cache *loggerConfigCache) get(key string, now time.Time) (loggerConfigEntry, uint64, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry, found := cache.entries[key]
+	if found && now.Before(entry.expiresAt) {
+		cache.metrics.Add("hits", 1)
+		return entry, cache.generation, true
+	}
+	cache.metrics.Add("misses", 1)
+	return loggerConfigEntry{}, cache.generation, false
+}
+
+// put caches a config read in generation, unless invalidated meanwhile. Expired entries are removed if the cache is full
+func (cache *loggerConfigCache) put(key string, plantConfig model.PlantLoggerConfig, device model.PlantDevice, generation uint64, now time.Time) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if generation != cache.generation {
+		return
+	}
+	if len(cache.entries) >= cache.maxEntries {
+		for cachedKey, entry := range cache.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(cache.entries, cachedKey)
+			}
+		}
+		if len(cache.entries) >= cache.maxEntries {
+			return
+		}
+	}
+	cache.entries[key] = loggerConfigEntry{plantConfig: plantConfig, device: device, expiresAt: now.Add(cache.ttl)}
+}
+
+// invalidate removes all entries matching and starts a new generation
+func (cache *loggerConfigCache) invalidate(matches func(entry loggerConfigEntry) bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.generation++
+	cache.metrics.Add("invalidations", 1)
+	for key, entry := range cache.entries {
+		if matches(entry) {
+			delete(cache.entries, key)
+		}
+	}
+}
+
+// InvalidateLoggerConfig removes the cached plant logger config of a plant, including those cached for keys of its devices.
+// Must be called after each change of a plant logger config or of a device's credentials.
+func InvalidateLoggerConfig(publicPlantID string) {
+	loggerConfigs.invalidate(func(entry loggerConfigEntry) bool {
+		return entry.plantConfig.PublicPlantID == publicPlantID
+	})
+}
+
+// invalidateLoggerConfigDocument removes cached entries read from the plant logger config or device document with id
+func invalidateLoggerConfigDocument(id primitive.ObjectID) {
+	loggerConfigs.invalidate(func(entry loggerConfigEntry) bool {
+		return entry.plantConfig.ID == id || (entry.device.DeviceID != "" && entry.device.ID == id)
+	})
+}
+
+// invalidateLoggerConfigs removes all cached entries
+func invalidateLoggerConfigs() {
+	loggerConfigs.invalidate(func(loggerConfigEntry) bool { return true })
+}
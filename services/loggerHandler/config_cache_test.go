@@ -0,0 +1,102 @@
+package loggerhandler
+
+import (
+	"expvar"
+	"testing"
+	"time"
+
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/stretchr/testify/assert"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestLoggerConfigCache(maxEntries int) *loggerConfigCache {
+	return newLoggerConfigCache(time.Minute, maxEntries, new(expvar.Map).Init())
+}
+
+func TestLoggerConfigCacheExpires(t *testing.T) {
+	cache := newTestLoggerConfigCache(10)
+	now := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	plantConfig := model.PlantLoggerConfig{PublicPlantID: "970407102018637", IntervalSec: 900}
+
+	_, generation, found := cache.get("plantKey", now)
+	assert.False(t, found)
+	cache.put("plantKey", plantConfig, model.PlantDevice{}, generation, now)
+
+	entry, _, found := cache.get("plantKey", now.Add(59*time.Second))
+	assert.True(t, found)
+	assert.Equal(t, plantConfig, entry.plantConfig)
+	_, _, found = cache.get("plantKey", now.Add(time.Minute))
+	assert.False(t, found)
+
+	assert.Equal(t, "1", cache.metrics.Get("hits").String())
+	assert.Equal(t, "2", cache.metrics.Get("misses").String())
+	assert.Equal(t, "1", cache.metrics.Get("entries").String())
+}
+
+func TestLoggerConfigCacheInvalidation(t *testing.T) {
+	cache := newTestLoggerConfigCache(10)
+	now := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	plantA := model.PlantLoggerConfig{ID: primitive.NewObjectID(), PublicPlantID: "plantA"}
+	plantB := model.PlantLoggerConfig{ID: primitive.NewObjectID(), PublicPlantID: "plantB"}
+	device := model.PlantDevice{ID: primitive.NewObjectID(), PublicPlantID: "plantA", DeviceID: "inverter-1"}
+
+	_, generation, _ := cache.get("plantKeyA", now)
+	cache.put("plantKeyA", plantA, model.PlantDevice{}, generation, now)
+	cache.put("deviceKeyA", plantA, device, generation, now)
+	cache.put("plantKeyB", plantB, model.PlantDevice{}, generation, now)
+
+	// Device documents only invalidate keys of the device
+	cache.invalidate(func(entry loggerConfigEntry) bool {
+		return entry.device.DeviceID != "" && entry.device.ID == device.ID
+	})
+	_, _, found := cache.get("deviceKeyA", now)
+	assert.False(t, found)
+	_, _, found = cache.get("plantKeyA", now)
+	assert.True(t, found)
+
+	// Plants invalidate keys of their devices, too
+	cache.put("deviceKeyA", plantA, device, cache.generation, now)
+	cache.invalidate(func(entry loggerConfigEntry) bool { return entry.plantConfig.PublicPlantID == "plantA" })
+	_, _, found = cache.get("plantKeyA", now)
+	assert.False(t, found)
+	_, _, found = cache.get("deviceKeyA", now)
+	assert.False(t, found)
+	_, _, found = cache.get("plantKeyB", now)
+	assert.True(t, found)
+}
+
+func TestLoggerConfigCacheSkipsStaleReads(t *testing.T) {
+	cache := newTestLoggerConfigCache(10)
+	now := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	plantConfig := model.PlantLoggerConfig{PublicPlantID: "970407102018637"}
+
+	// The config is changed while it's read. The read config may be the old one
+	_, generation, _ := cache.get("plantKey", now)
+	cache.invalidate(func(entry loggerConfigEntry) bool {
+		return entry.plantConfig.PublicPlantID == plantConfig.PublicPlantID
+	})
+	cache.put("plantKey", plantConfig, model.PlantDevice{}, generation, now)
+	_, _, found := cache.get("plantKey", now)
+	assert.False(t, found)
+}
+
+func TestLoggerConfigCacheMaxEntries(t *testing.T) {
+	cache := newTestLoggerConfigCache(2)
+	now := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	cache.put("key1", model.PlantLoggerConfig{}, model.PlantDevice{}, 0, now)
+	cache.put("key2", model.PlantLoggerConfig{}, model.PlantDevice{}, 0, now.Add(30*time.Second))
+
+	// Full
+	cache.put("key3", model.PlantLoggerConfig{}, model.PlantDevice{}, 0, now.Add(30*time.Second))
+	_, _, found := cache.get("key3", now.Add(30*time.Second))
+	assert.False(t, found)
+
+	// Expired entries make room
+	cache.put("key3", model.PlantLoggerConfig{}, model.PlantDevice{}, 0, now.Add(time.Minute))
+	_, _, found = cache.get("key3", now.Add(time.Minute))
+	assert.True(t, found)
+	_, _, found = cache.get("key2", now.Add(time.Minute))
+	assert.True(t, found)
+}
@@ -0,0 +1,110 @@
+package loggerhandler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Error code of MongoDB deployments without change streams, ie. standalone servers
+const changeStreamsNotSupportedCode int32 = 40573
+
+// changeEvent holds the fields of a change stream event needed for invalidation
+type changeEvent struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+}
+
+// ConfigCacheWatcher invalidates cached plant logger configs on changes of plant logger configs and devices made by any server instance, using change streams.
+// Without, changes of other instances are picked up after LoggerConfigCacheTTLSec. Standalone MongoDB servers don't support change streams, the watcher ends then.
+type ConfigCacheWatcher struct {
+	mongoDBInterface *mongodb.MethodInterface
+	cancel           context.CancelFunc
+	waitGroup        sync.WaitGroup
+}
+
+// NewConfigCacheWatcher creates a watcher. Call Start to run it in the background.
+func NewConfigCacheWatcher(mongoDBInterface *mongodb.MethodInterface) *ConfigCacheWatcher {
+	return &ConfigCacheWatcher{mongoDBInterface: mongoDBInterface}
+}
+
+// Start watches plant logger configs and devices in the background
+func (watcher *ConfigCacheWatcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	watcher.cancel = cancel
+	for _, collection := range []struct{ databaseName, collectionName string }{
+		{config.DatabaseNamePlantLoggerConfig, config.CollectionNamePlantLoggerConfig},
+		{config.DatabaseNamePlantDevice, config.CollectionNamePlantDevice},
+	} {
+		watcher.waitGroup.Add(1)
+		go func(databaseName, collectionName string) {
+			defer watcher.waitGroup.Done()
+			watcher.watch(ctx, databaseName, collectionName)
+		}(collection.databaseName, collection.collectionName)
+	}
+}
+
+// Stop stops watching
+func (watcher *ConfigCacheWatcher) Stop() {
+	watcher.cancel()
+	watcher.waitGroup.Wait()
+}
+
+// watch invalidates cached configs on each change of collection until stopped. The change stream is reopened after errors.
+// Changes may have been missed meanwhile, so all cached configs are invalidated on reopening.
+func (watcher *ConfigCacheWatcher) watch(ctx context.Context, databaseName, collectionName string) {
+	for {
+		err := watcher.watchOnce(ctx, databaseName, collectionName)
+		if ctx.Err() != nil {
+			return
+		}
+		var commandError mongo.CommandError
+		if errors.As(err, &commandError) && commandError.Code == changeStreamsNotSupportedCode {
+			logger.GetLogger().Warnf("MongoDB doesn't support change streams. Cached plant logger configs of collection '%s' are only refreshed by other server instances after %d seconds.", collectionName, config.LoggerConfigCacheTTLSec)
+			return
+		}
+		logger.GetLogger().Warnf("Change stream of collection '%s' part of database '%s' failed in 'watch()'. Reopening in %d seconds. Error: %v", collectionName, databaseName, config.LoggerConfigCacheWatchRetrySec, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(config.LoggerConfigCacheWatchRetrySec) * time.Second):
+		}
+		invalidateLoggerConfigs()
+	}
+}
+
+// watchOnce reads change events of collection until the stream fails or ctx is cancelled
+func (watcher *ConfigCacheWatcher) watchOnce(ctx context.Context, databaseName, collectionName string) error {
+	stream, err := watcher.mongoDBInterface.RepositoryInterface.WatchCollection(ctx, databaseName, collectionName)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var event changeEvent
+		if err := stream.Decode(&event); err != nil {
+			return err
+		}
+		switch event.OperationType {
+		case "insert":
+			// New documents aren't cached yet
+		case "update", "replace", "delete":
+			invalidateLoggerConfigDocument(event.DocumentKey.ID)
+		default:
+			// Collection dropped or renamed
+			invalidateLoggerConfigs()
+		}
+	}
+	return stream.Err()
+}
@@ -0,0 +1,77 @@
+package loggerhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Errors returned by ResolveReadingDevice. Messages are suitable for the response
+var (
+	ErrDeviceIDInvalid = errors.New("'deviceID' must be the id of a device of this plant.")
+	ErrDeviceMismatch  = errors.New("'deviceID' does not match the device the credentials belong to.")
+)
+
+// DeviceTypes lists the types of devices a plant may consist of
+var DeviceTypes = []string{model.DeviceTypeInverter, model.DeviceTypeString, model.DeviceTypeMeter, model.DeviceTypeWeatherStation}
+
+// IsDeviceType reports if deviceType is one of DeviceTypes
+func IsDeviceType(deviceType string) bool {
+	return slices.Contains(DeviceTypes, deviceType)
+}
+
+// FindDevice finds the device with deviceID of the plant with publicPlantID. Returns false if the plant has no such device
+func FindDevice(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, publicPlantID, deviceID string) (model.PlantDevice, bool, error) {
+	var device model.PlantDevice
+	var filter bson.M = bson.M{"public_plant_id": publicPlantID, "device_id": deviceID}
+	findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(ctx, config.DatabaseNamePlantDevice, filter, config.CollectionNamePlantDevice, bson.D{}, &device)
+	if err != nil {
+		return device, false, fmt.Errorf("Error in 'FindDevice()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s' for device %s of plant %s. Error: %w", config.CollectionNamePlantDevice, config.DatabaseNamePlantDevice, deviceID, publicPlantID, err)
+	}
+	return device, findOne, nil
+}
+
+// ResolveReadingDevice returns the id of the device a reading is tagged with. Empty if reported for the plant as a whole.
+// Loggers authenticated with device credentials (authenticatedDevice) report for their device. Loggers using the plant credentials
+// may report for any device of the plant by the optional request key 'deviceID'.
+func ResolveReadingDevice(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, plantConfig model.PlantLoggerConfig, authenticatedDevice model.PlantDevice, data map[string]interface{}) (string, error) {
+	raw, exists := data["deviceID"]
+	if !exists {
+		return authenticatedDevice.DeviceID, nil
+	}
+	deviceID, ok := raw.(string)
+	if !ok || deviceID == "" {
+		return "", ErrDeviceIDInvalid
+	}
+	if authenticatedDevice.DeviceID != "" {
+		if deviceID != authenticatedDevice.DeviceID {
+			return "", ErrDeviceMismatch
+		}
+		return deviceID, nil
+	}
+
+	_, found, err := FindDevice(ctx, mongoDBInterface, plantConfig.PublicPlantID, deviceID)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", ErrDeviceIDInvalid
+	}
+	return deviceID, nil
+}
+
+// DeviceFilter returns a filter matching the readings of the device with deviceID. Readings reported for the plant as a whole if deviceID is empty.
+func DeviceFilter(deviceID string) bson.M {
+	// Readings without device don't carry the field. A null filter matches missing fields
+	if deviceID == "" {
+		return bson.M{"device_id": nil}
+	}
+	return bson.M{"device_id": deviceID}
+}
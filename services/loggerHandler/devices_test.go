@@ -0,0 +1,46 @@
+package loggerhandler
+
+import (
+	"context"
+	"testing"
+
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestResolveReadingDevice(t *testing.T) {
+	plantConfig := model.PlantLoggerConfig{PublicPlantID: "123456789012345"}
+	inverter := model.PlantDevice{PublicPlantID: plantConfig.PublicPlantID, DeviceID: "100000000001", Type: model.DeviceTypeInverter}
+
+	// Cases not requiring a device lookup
+	testCases := []struct {
+		name          string
+		device        model.PlantDevice
+		data          map[string]interface{}
+		expected      string
+		expectedError error
+	}{
+		{"PlantCredentialsWithoutDevice", model.PlantDevice{}, map[string]interface{}{}, "", nil},
+		{"DeviceCredentials", inverter, map[string]interface{}{}, "100000000001", nil},
+		{"DeviceCredentialsSameDevice", inverter, map[string]interface{}{"deviceID": "100000000001"}, "100000000001", nil},
+		{"DeviceCredentialsOtherDevice", inverter, map[string]interface{}{"deviceID": "100000000002"}, "", ErrDeviceMismatch},
+		{"NoString", model.PlantDevice{}, map[string]interface{}{"deviceID": 100000000001.0}, "", ErrDeviceIDInvalid},
+		{"Empty", inverter, map[string]interface{}{"deviceID": ""}, "", ErrDeviceIDInvalid},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deviceID, err := ResolveReadingDevice(context.Background(), nil, plantConfig, tc.device, tc.data)
+			assert.Equal(t, tc.expectedError, err)
+			assert.Equal(t, tc.expected, deviceID)
+		})
+	}
+}
+
+func TestDeviceFilter(t *testing.T) {
+	assert.Equal(t, bson.M{"device_id": nil}, DeviceFilter(""))
+	assert.Equal(t, bson.M{"device_id": "100000000001"}, DeviceFilter("100000000001"))
+	assert.True(t, IsDeviceType(model.DeviceTypeWeatherStation))
+	assert.False(t, IsDeviceType("battery"))
+}
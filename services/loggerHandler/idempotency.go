@@ -0,0 +1,332 @@
+package loggerhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrSequenceInvalid       = errors.New("invalid sequence number")
+	ErrIdempotencyKeyInvalid = errors.New("invalid idempotency key")
+)
+
+// IdentityKeys lists the optional request keys identifying a reading for idempotent submission
+var IdentityKeys = []string{"sequence", "idempotencyKey"}
+
+// ExpectedReadingKeys returns the required keys of a reading extended by those optional keys present in data
+func ExpectedReadingKeys(data map[string]interface{}, required []string, optional []string) []string {
+	expectedKeys := append([]string{}, required...)
+	for _, key := range optional {
+		if _, exists := data[key]; exists {
+			expectedKeys = append(expectedKeys, key)
+		}
+	}
+	return expectedKeys
+}
+
+// ParseReadingIdentity sets the optional sequence number ('sequence') and idempotency key ('idempotencyKey') of a reading.
+// A logger retrying a reading must resubmit it with the same sequence number or idempotency key. See IdentityRejectionReason for errors
+func ParseReadingIdentity(data map[string]interface{}, plantLog *model.PlantLogger) error {
+	if raw, exists := data["sequence"]; exists {
+		sequence, ok := raw.(float64)
+		if !ok || sequence < 0 || sequence > 1<<53 || sequence != math.Trunc(sequence) {
+			return ErrSequenceInvalid
+		}
+		sequenceInt := int64(sequence)
+		plantLog.Sequence = &sequenceInt
+	}
+	if raw, exists := data["idempotencyKey"]; exists {
+		idempotencyKey, ok := raw.(string)
+		if !ok || len(idempotencyKey) == 0 || len(idempotencyKey) > config.IdempotencyKeyMaxLength {
+			return ErrIdempotencyKeyInvalid
+		}
+		plantLog.IdempotencyKey = idempotencyKey
+	}
+	return nil
+}
+
+// IdentityRejectionReason translates errors of ParseReadingIdentity into a response message
+func IdentityRejectionReason(err error) string {
+	if errors.Is(err, ErrSequenceInvalid) {
+		return "'sequence' must be a non-negative integer."
+	}
+	return fmt.Sprintf("'idempotencyKey' must be a non-empty string of at most %d characters.", config.IdempotencyKeyMaxLength)
+}
+
+// readingIdentities returns the identifiers of a reading in a uniform format. Empty if the reading carries neither sequence number nor idempotency key.
+// Devices of a plant count their sequence numbers independently, so sequence numbers are scoped by device. Idempotency keys are unique per plant.
+func readingIdentities(plantLog model.PlantLogger) []string {
+	identities := []string{}
+	if plantLog.Sequence != nil {
+		identities = append(identities, "sequence:"+plantLog.DeviceID+":"+strconv.FormatInt(*plantLog.Sequence, 10))
+	}
+	if plantLog.IdempotencyKey != "" {
+		identities = append(identities, "idempotency_key:"+plantLog.IdempotencyKey)
+	}
+	return identities
+}
+
+// ReadingIdentitySet holds sequence numbers and idempotency keys of readings already stored or submitted
+type ReadingIdentitySet map[string]bool
+
+// NewReadingIdentitySet collects the identifiers of all plantLogs
+func NewReadingIdentitySet(plantLogs []model.PlantLogger) ReadingIdentitySet {
+	set := ReadingIdentitySet{}
+	for _, plantLog := range plantLogs {
+		set.Add(plantLog)
+	}
+	return set
+}
+
+// Add adds sequence number and idempotency key of plantLog to the set
+func (set ReadingIdentitySet) Add(plantLog model.PlantLogger) {
+	for _, identity := range readingIdentities(plantLog) {
+		set[identity] = true
+	}
+}
+
+// Contains reports if sequence number or idempotency key of plantLog is part of the set
+func (set ReadingIdentitySet) Contains(plantLog model.PlantLogger) bool {
+	for _, identity := range readingIdentities(plantLog) {
+		if set[identity] {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadingIdentityFilter returns a filter matching stored readings sharing sequence number (of the same device) or idempotency key with any of plantLogs.
+// Returns nil if none of plantLogs carries sequence number or idempotency key.
+func ReadingIdentityFilter(plantLogs ...model.PlantLogger) bson.M {
+	sequencesByDevice := map[string]bson.A{}
+	deviceIDs := []string{}
+	idempotencyKeys := bson.A{}
+	for _, plantLog := range plantLogs {
+		if plantLog.Sequence != nil {
+			if _, exists := sequencesByDevice[plantLog.DeviceID]; !exists {
+				deviceIDs = append(deviceIDs, plantLog.DeviceID)
+			}
+			sequencesByDevice[plantLog.DeviceID] = append(sequencesByDevice[plantLog.DeviceID], *plantLog.Sequence)
+		}
+		if plantLog.IdempotencyKey != "" {
+			idempotencyKeys = append(idempotencyKeys, plantLog.IdempotencyKey)
+		}
+	}
+
+	conditions := bson.A{}
+	for _, deviceID := range deviceIDs {
+		condition := DeviceFilter(deviceID)
+		condition["sequence"] = bson.M{"$in": sequencesByDevice[deviceID]}
+		conditions = append(conditions, condition)
+	}
+	if len(idempotencyKeys) > 0 {
+		conditions = append(conditions, bson.M{"idempotency_key": bson.M{"$in": idempotencyKeys}})
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+	return bson.M{"$or": conditions}
+}
+
+// Plant logger collections whose idempotency indexes have been ensured by this process
+var ensuredIdempotencyIndexes sync.Map
+
+// EnsureIdempotencyIndexes creates unique indexes on sequence number (per device) and idempotency key of a plant logger collection.
+// Indexes are only covering readings carrying the field. Each collection is handled once per process, covering collections created before idempotent submission existed.
+// Time-series collections don't support unique indexes. Their indexes only speed up finding stored readings by sequence number or idempotency key,
+// readings are kept unique by claims in the plant's identity collection instead, see ClaimReadingIdentities.
+func EnsureIdempotencyIndexes(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionName string) error {
+	if _, ensured := ensuredIdempotencyIndexes.Load(collectionName); ensured {
+		return nil
+	}
+	collection, _, err := findLoggerCollection(ctx, mongoDBInterface, collectionName)
+	if err != nil {
+		return err
+	}
+	if collection.Type == "timeseries" {
+		if err := mongoDBInterface.RepositoryInterface.CreateIndex(ctx, collectionName, config.DatabaseNamePlantLogger, "device_id", "sequence"); err != nil {
+			return err
+		}
+		if err := mongoDBInterface.RepositoryInterface.CreateIndex(ctx, collectionName, config.DatabaseNamePlantLogger, "idempotency_key"); err != nil {
+			return err
+		}
+		ensuredIdempotencyIndexes.Store(collectionName, true)
+		return nil
+	}
+
+	// Sequence numbers were unique per plant before devices existed. Replace the index by one scoped by device
+	if err := mongoDBInterface.RepositoryInterface.DropIndex(ctx, collectionName, config.DatabaseNamePlantLogger, "sequence_1"); err != nil {
+		return err
+	}
+	if err := ensureUniqueIdentityIndexes(ctx, mongoDBInterface, collectionName); err != nil {
+		return err
+	}
+	ensuredIdempotencyIndexes.Store(collectionName, true)
+	return nil
+}
+
+// ensureUniqueIdentityIndexes creates the unique indexes on sequence number (per device) and idempotency key of a plant logger or identity collection
+func ensureUniqueIdentityIndexes(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionName string) error {
+	if err := mongoDBInterface.RepositoryInterface.CreatePartialUniqueIndex(ctx, collectionName, config.DatabaseNamePlantLogger, "sequence", "device_id"); err != nil {
+		return err
+	}
+	return mongoDBInterface.RepositoryInterface.CreatePartialUniqueIndex(ctx, collectionName, config.DatabaseNamePlantLogger, "idempotency_key")
+}
+
+// IdentityCollectionName returns the name of the plant's collection of claimed sequence numbers and idempotency keys, sharing the id of its logger collection
+// Eg. 'plant_identity_123456789012' for logger collection 'plant_logger_123456789012'
+func IdentityCollectionName(collectionNameLogger string) string {
+	return "plant_identity_" + strings.TrimPrefix(collectionNameLogger, "plant_logger_")
+}
+
+// readingClaims returns the claims of sequence number and idempotency key of a reading. Empty if the reading carries neither
+func readingClaims(plantLog model.PlantLogger, now time.Time) []interface{} {
+	claims := []interface{}{}
+	if plantLog.Sequence != nil {
+		claims = append(claims, model.PlantLogIdentity{ID: primitive.NewObjectID(), ReadingID: plantLog.ID, DeviceID: plantLog.DeviceID, Sequence: plantLog.Sequence, CreatedAt: now})
+	}
+	if plantLog.IdempotencyKey != "" {
+		claims = append(claims, model.PlantLogIdentity{ID: primitive.NewObjectID(), ReadingID: plantLog.ID, IdempotencyKey: plantLog.IdempotencyKey, CreatedAt: now})
+	}
+	return claims
+}
+
+// ClaimReadingIdentities claims sequence numbers and idempotency keys of plantLogs in the plant's identity collection before they are stored.
+// Returns the positions of plantLogs whose sequence number or idempotency key is claimed by another reading already, ie. duplicates not to be stored.
+// Their own claims are released. Claims of the same reading, eg. by a retried attempt of storing it, are no conflict.
+// Concurrent retries of a reading are thus stored once, although time-series collections don't support unique indexes. Release the claims of readings failing to be stored.
+func ClaimReadingIdentities(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, plantLogs []model.PlantLogger) (map[int]bool, error) {
+	duplicates := map[int]bool{}
+	claims := []interface{}{}
+	now := time.Now().UTC()
+	for _, plantLog := range plantLogs {
+		claims = append(claims, readingClaims(plantLog, now)...)
+	}
+	if len(claims) == 0 {
+		return duplicates, nil
+	}
+
+	collectionNameIdentity := IdentityCollectionName(collectionNameLogger)
+	if _, ensured := ensuredIdempotencyIndexes.Load(collectionNameIdentity); !ensured {
+		if err := ensureUniqueIdentityIndexes(ctx, mongoDBInterface, collectionNameIdentity); err != nil {
+			return nil, fmt.Errorf("Error in 'ClaimReadingIdentities()' using 'ensureUniqueIdentityIndexes()' for collection '%s'. Error: %w", collectionNameIdentity, err)
+		}
+		ensuredIdempotencyIndexes.Store(collectionNameIdentity, true)
+	}
+
+	_, err := mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, claims, collectionNameIdentity)
+	if err == nil {
+		return duplicates, nil
+	}
+	// Unordered bulk insert. Anything but claims conflicting with stored claims fails all readings
+	var bulkWriteException mongo.BulkWriteException
+	failed := !errors.As(err, &bulkWriteException) || len(bulkWriteException.WriteErrors) == 0 || bulkWriteException.WriteConcernError != nil
+	for _, writeError := range bulkWriteException.WriteErrors {
+		failed = failed || !mongo.IsDuplicateKeyError(writeError)
+	}
+	if failed {
+		if errRelease := ReleaseReadingIdentities(ctx, mongoDBInterface, collectionNameLogger, plantLogs); errRelease != nil {
+			err = fmt.Errorf("%v. Claims not released: %v", err, errRelease)
+		}
+		return nil, fmt.Errorf("Error in 'ClaimReadingIdentities()' using 'InsertManyToMongo()' in collection '%s'. Error: %w", collectionNameIdentity, err)
+	}
+
+	// Find the readings holding the conflicting claims
+	var storedClaims []model.PlantLogIdentity
+	if err := mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNamePlantLogger, ReadingIdentityFilter(plantLogs...), collectionNameIdentity, bson.D{}, &storedClaims); err != nil {
+		return nil, fmt.Errorf("Error in 'ClaimReadingIdentities()' using 'FindManyInMongo()' in collection '%s'. Error: %w", collectionNameIdentity, err)
+	}
+	claimedBy := map[string]primitive.ObjectID{}
+	for _, storedClaim := range storedClaims {
+		for _, identity := range readingIdentities(model.PlantLogger{DeviceID: storedClaim.DeviceID, Sequence: storedClaim.Sequence, IdempotencyKey: storedClaim.IdempotencyKey}) {
+			claimedBy[identity] = storedClaim.ReadingID
+		}
+	}
+	duplicateLogs := []model.PlantLogger{}
+	for position, plantLog := range plantLogs {
+		for _, identity := range readingIdentities(plantLog) {
+			if readingID, claimed := claimedBy[identity]; claimed && readingID != plantLog.ID {
+				duplicates[position] = true
+			}
+		}
+		if duplicates[position] {
+			duplicateLogs = append(duplicateLogs, plantLog)
+		}
+	}
+	if err := ReleaseReadingIdentities(ctx, mongoDBInterface, collectionNameLogger, duplicateLogs); err != nil {
+		return nil, err
+	}
+	return duplicates, nil
+}
+
+// ReleaseReadingIdentities releases the claims of plantLogs, eg. after failing to store them
+func ReleaseReadingIdentities(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, plantLogs []model.PlantLogger) error {
+	readingIDs := bson.A{}
+	for _, plantLog := range plantLogs {
+		if plantLog.Sequence != nil || plantLog.IdempotencyKey != "" {
+			readingIDs = append(readingIDs, plantLog.ID)
+		}
+	}
+	if len(readingIDs) == 0 {
+		return nil
+	}
+	collectionNameIdentity := IdentityCollectionName(collectionNameLogger)
+	if _, err := mongoDBInterface.RepositoryInterface.DeleteManyMongo(ctx, config.DatabaseNamePlantLogger, bson.M{"reading_id": bson.M{"$in": readingIDs}}, collectionNameIdentity); err != nil {
+		return fmt.Errorf("Error in 'ReleaseReadingIdentities()' using 'DeleteManyMongo()' in collection '%s'. Error: %w", collectionNameIdentity, err)
+	}
+	return nil
+}
+
+// ClaimStoredReadingIdentities claims sequence numbers and idempotency keys of the readings stored in the plant logger collection collectionNameLogger,
+// eg. after converting it to a time-series collection. Returns the number of readings claimed, including those claimed already, so it can be repeated.
+func ClaimStoredReadingIdentities(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string) (int64, error) {
+	filter := bson.M{"$or": bson.A{bson.M{"sequence": bson.M{"$exists": true}}, bson.M{"idempotency_key": bson.M{"$exists": true}}}}
+	findOptions := mongodb.FindOptions{Projection: bson.M{"device_id": 1, "sequence": 1, "idempotency_key": 1}, BatchSize: config.DatabaseCursorBatchSize}
+	cursor, err := mongoDBInterface.RepositoryInterface.FindCursorInMongo(ctx, config.DatabaseNamePlantLogger, filter, collectionNameLogger, findOptions)
+	if err != nil {
+		return 0, fmt.Errorf("Error in 'ClaimStoredReadingIdentities()' using 'FindCursorInMongo()' in collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+	defer cursor.Close(ctx)
+
+	var claimed int64
+	plantLogs := []model.PlantLogger{}
+	claim := func() error {
+		duplicates, err := ClaimReadingIdentities(ctx, mongoDBInterface, collectionNameLogger, plantLogs)
+		if err != nil {
+			return err
+		}
+		claimed += int64(len(plantLogs) - len(duplicates))
+		plantLogs = plantLogs[:0]
+		return nil
+	}
+	for cursor.Next(ctx) {
+		var plantLog model.PlantLogger
+		if err := cursor.Decode(&plantLog); err != nil {
+			return claimed, fmt.Errorf("Error in 'ClaimStoredReadingIdentities()' using 'Decode()' in collection '%s'. Error: %w", collectionNameLogger, err)
+		}
+		plantLogs = append(plantLogs, plantLog)
+		if len(plantLogs) == config.IngestBatchSize {
+			if err := claim(); err != nil {
+				return claimed, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return claimed, fmt.Errorf("Error in 'ClaimStoredReadingIdentities()' using 'Next()' in collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+	return claimed, claim()
+}
@@ -0,0 +1,171 @@
+package loggerhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	v "github.com/paulmuenzner/powerplantmanager/utils/validate"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	ErrMeasuredAtInvalid  = errors.New("measuredAt is not a RFC3339 timestamp")
+	ErrMeasuredAtInFuture = errors.New("measuredAt lies further in the future than tolerated by clock skew policy")
+	ErrMeasuredAtTooOld   = errors.New("measuredAt lies further in the past than tolerated by clock skew policy")
+)
+
+// DefaultClockSkewPolicy returns the clock skew policy assigned to new plants
+func DefaultClockSkewPolicy() model.ClockSkewPolicy {
+	return model.ClockSkewPolicy{
+		MaxFutureSec: config.ClockSkewMaxFutureSecDefault,
+		MaxPastSec:   config.ClockSkewMaxPastSecDefault,
+		Action:       config.ClockSkewActionDefault,
+	}
+}
+
+// EffectiveClockSkewPolicy returns the plant's clock skew policy or the default policy for plant configs created before policies existed
+func EffectiveClockSkewPolicy(policy model.ClockSkewPolicy) model.ClockSkewPolicy {
+	if policy.Action == "" {
+		return DefaultClockSkewPolicy()
+	}
+	return policy
+}
+
+// ParseMeasuredAt reads the optional measurement time 'measuredAt' (RFC3339) of a reading. Returns nil if not provided.
+func ParseMeasuredAt(data map[string]interface{}) (*time.Time, error) {
+	raw, exists := data["measuredAt"]
+	if !exists {
+		return nil, nil
+	}
+	measuredAtString, ok := raw.(string)
+	if !ok {
+		return nil, ErrMeasuredAtInvalid
+	}
+	measuredAt, err := time.Parse(time.RFC3339Nano, measuredAtString)
+	if err != nil {
+		return nil, ErrMeasuredAtInvalid
+	}
+	return &measuredAt, nil
+}
+
+// ApplyMeasurementTime sets measurement and receipt time of plantLog. Without measuredAt the time of receipt is used as measurement time.
+// A measuredAt outside the plant's clock skew policy either returns ErrMeasuredAtInFuture/ErrMeasuredAtTooOld (action 'reject')
+// or is kept as ReportedAt while the time of receipt is used as measurement time and the reading is flagged (action 'flag').
+func ApplyMeasurementTime(plantLog *model.PlantLogger, measuredAt *time.Time, receivedAt time.Time, policy model.ClockSkewPolicy) error {
+	policy = EffectiveClockSkewPolicy(policy)
+	plantLog.ReceivedAt = receivedAt.UTC()
+	plantLog.MeasuredAt = plantLog.ReceivedAt
+	if measuredAt == nil {
+		return nil
+	}
+
+	var err error
+	switch {
+	case measuredAt.After(receivedAt.Add(time.Second * time.Duration(policy.MaxFutureSec))):
+		err = ErrMeasuredAtInFuture
+	case measuredAt.Before(receivedAt.Add(-time.Second * time.Duration(policy.MaxPastSec))):
+		err = ErrMeasuredAtTooOld
+	}
+	if err == nil {
+		plantLog.MeasuredAt = measuredAt.UTC()
+		return nil
+	}
+	if policy.Action != model.ClockSkewActionFlag {
+		return err
+	}
+
+	reportedAt := measuredAt.UTC()
+	plantLog.ReportedAt = &reportedAt
+	plantLog.ClockSkewFlagged = true
+	return nil
+}
+
+// IsWithinLogInterval reports if two measurement times are closer to each other than the plant's logging interval allows (rate limit).
+// A security buffer of 60 seconds is deducted from the interval. A zero dateLatestEntry (no stored reading yet) never conflicts.
+func IsWithinLogInterval(measuredAt, dateLatestEntry time.Time, intervalSec int) bool {
+	if dateLatestEntry.IsZero() {
+		return false
+	}
+	return absDuration(measuredAt.Sub(dateLatestEntry)) < minimumLogGap(intervalSec)
+}
+
+// IsWithinLogIntervalOfAny reports if measuredAt is within the logging interval of any of measurement times storedTimes, sorted ascending
+func IsWithinLogIntervalOfAny(measuredAt time.Time, storedTimes []time.Time, intervalSec int) bool {
+	index := sort.Search(len(storedTimes), func(i int) bool { return !storedTimes[i].Before(measuredAt) })
+	if index < len(storedTimes) && IsWithinLogInterval(measuredAt, storedTimes[index], intervalSec) {
+		return true
+	}
+	return index > 0 && IsWithinLogInterval(measuredAt, storedTimes[index-1], intervalSec)
+}
+
+// FindLogTimesNear returns the measurement times, sorted ascending, of the stored readings of device deviceID (empty for readings reported for the plant as a whole)
+// within the logging interval of any time between first and last. Readings measured before or after the latest stored reading, eg. backfilled, are checked alike
+func FindLogTimesNear(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger, deviceID string, first, last time.Time, intervalSec int) ([]time.Time, error) {
+	minimumGap := minimumLogGap(intervalSec)
+	if minimumGap <= 0 {
+		return []time.Time{}, nil
+	}
+	filter := DeviceFilter(deviceID)
+	filter["measured_at"] = bson.M{"$gt": first.Add(-minimumGap), "$lt": last.Add(minimumGap)}
+	findOptions := mongodb.FindOptions{Projection: bson.M{"measured_at": 1}, Sort: bson.D{{Key: "measured_at", Value: 1}}, BatchSize: config.DatabaseCursorBatchSize}
+	cursor, err := mongoDBInterface.RepositoryInterface.FindCursorInMongo(ctx, config.DatabaseNamePlantLogger, filter, collectionNameLogger, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("Error in 'FindLogTimesNear()' using 'FindCursorInMongo()' in collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+	defer cursor.Close(ctx)
+
+	storedTimes := []time.Time{}
+	for cursor.Next(ctx) {
+		var plantLog model.PlantLogger
+		if err := cursor.Decode(&plantLog); err != nil {
+			return nil, fmt.Errorf("Error in 'FindLogTimesNear()' using 'Decode()' in collection '%s'. Error: %w", collectionNameLogger, err)
+		}
+		storedTimes = append(storedTimes, plantLog.MeasuredAt)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("Error in 'FindLogTimesNear()' using 'Next()' in collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+	return storedTimes, nil
+}
+
+// minimumLogGap returns the minimum time between two readings of a device, the logging interval of intervalSec seconds less a security buffer of 60 seconds
+func minimumLogGap(intervalSec int) time.Duration {
+	return time.Second * time.Duration(intervalSec-60)
+}
+
+// ParseClockSkewPolicy converts a clock skew policy of the parsed request body ('maxFutureSec', 'maxPastSec', 'action') into a ClockSkewPolicy.
+// Returns an error message suitable for the response if the policy is invalid.
+func ParseClockSkewPolicy(raw interface{}) (model.ClockSkewPolicy, error) {
+	data, ok := raw.(map[string]interface{})
+	if !ok {
+		return model.ClockSkewPolicy{}, errors.New("Clock skew policy must be an object containing 'maxFutureSec', 'maxPastSec' and 'action'.")
+	}
+	validateKeys := v.Validate(data).
+		HasMapExactKeys([]string{"maxFutureSec", "maxPastSec", "action"}, "Clock skew policy must contain exactly 'maxFutureSec', 'maxPastSec' and 'action'.").
+		GetResult()
+	if len(validateKeys) > 0 {
+		return model.ClockSkewPolicy{}, errors.New(validateKeys[0])
+	}
+
+	maxFutureSec, futureValid := data["maxFutureSec"].(float64)
+	if !futureValid || maxFutureSec < 0 || maxFutureSec > float64(config.ClockSkewMaxFutureSecLimit) {
+		return model.ClockSkewPolicy{}, fmt.Errorf("'maxFutureSec' must be a number of seconds between 0 and %d.", config.ClockSkewMaxFutureSecLimit)
+	}
+	maxPastSec, pastValid := data["maxPastSec"].(float64)
+	if !pastValid || maxPastSec < 0 || maxPastSec > float64(config.ClockSkewMaxPastSecLimit) {
+		return model.ClockSkewPolicy{}, fmt.Errorf("'maxPastSec' must be a number of seconds between 0 and %d.", config.ClockSkewMaxPastSecLimit)
+	}
+	action, actionValid := data["action"].(string)
+	if !actionValid || (action != model.ClockSkewActionReject && action != model.ClockSkewActionFlag) {
+		return model.ClockSkewPolicy{}, fmt.Errorf("'action' must be '%s' or '%s'.", model.ClockSkewActionReject, model.ClockSkewActionFlag)
+	}
+
+	return model.ClockSkewPolicy{MaxFutureSec: int(maxFutureSec), MaxPastSec: int(maxPastSec), Action: action}, nil
+}
@@ -0,0 +1,90 @@
+package loggerhandler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestApplyMeasurementTime(t *testing.T) {
+	receivedAt := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	reject := model.ClockSkewPolicy{MaxFutureSec: 60, MaxPastSec: 3600, Action: model.ClockSkewActionReject}
+	flag := model.ClockSkewPolicy{MaxFutureSec: 60, MaxPastSec: 3600, Action: model.ClockSkewActionFlag}
+
+	testCases := []struct {
+		name               string
+		measuredAt         *time.Time
+		policy             model.ClockSkewPolicy
+		expectedErr        error
+		expectedMeasuredAt time.Time
+		expectedFlagged    bool
+	}{
+		{"NotProvided", nil, reject, nil, receivedAt, false},
+		{"WithinPolicy", timePointer(receivedAt.Add(-30 * time.Minute)), reject, nil, receivedAt.Add(-30 * time.Minute), false},
+		{"ToleratedFuture", timePointer(receivedAt.Add(30 * time.Second)), reject, nil, receivedAt.Add(30 * time.Second), false},
+		{"RejectFuture", timePointer(receivedAt.Add(2 * time.Minute)), reject, ErrMeasuredAtInFuture, time.Time{}, false},
+		{"RejectPast", timePointer(receivedAt.Add(-2 * time.Hour)), reject, ErrMeasuredAtTooOld, time.Time{}, false},
+		{"FlagPast", timePointer(receivedAt.Add(-2 * time.Hour)), flag, nil, receivedAt, true},
+		{"DefaultPolicy", timePointer(receivedAt.AddDate(0, 0, -2)), model.ClockSkewPolicy{}, nil, receivedAt.AddDate(0, 0, -2), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var plantLog model.PlantLogger
+			err := ApplyMeasurementTime(&plantLog, tc.measuredAt, receivedAt, tc.policy)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedMeasuredAt, plantLog.MeasuredAt)
+			assert.Equal(t, receivedAt, plantLog.ReceivedAt)
+			assert.Equal(t, tc.expectedFlagged, plantLog.ClockSkewFlagged)
+			if tc.expectedFlagged {
+				assert.Equal(t, tc.measuredAt.UTC(), *plantLog.ReportedAt)
+			}
+		})
+	}
+}
+
+func TestFindLogTimesNear(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	latest := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	earlier := latest.Add(-2 * time.Hour)
+	readings := []interface{}{
+		model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inv-1", MeasuredAt: latest},
+		model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inv-1", MeasuredAt: earlier},
+		model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inv-2", MeasuredAt: earlier.Add(-30 * time.Minute)},
+	}
+	_, err := mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, readings, "plant_logger_1")
+	assert.NoError(t, err)
+
+	// Backfilled reading close to an earlier reading, not to the latest
+	backfilled := earlier.Add(-5 * time.Minute)
+	storedTimes, err := FindLogTimesNear(ctx, mongoDBInterface, "plant_logger_1", "inv-1", backfilled, backfilled, 15*60)
+	assert.NoError(t, err)
+	assert.Len(t, storedTimes, 1)
+	assert.True(t, storedTimes[0].Equal(earlier))
+	assert.True(t, IsWithinLogIntervalOfAny(backfilled, storedTimes, 15*60))
+
+	// Readings of other devices don't conflict
+	storedTimes, err = FindLogTimesNear(ctx, mongoDBInterface, "plant_logger_1", "inv-1", earlier.Add(-30*time.Minute), earlier.Add(-30*time.Minute), 15*60)
+	assert.NoError(t, err)
+	assert.Empty(t, storedTimes)
+
+	stored := []time.Time{earlier, latest}
+	assert.False(t, IsWithinLogIntervalOfAny(earlier.Add(time.Hour), stored, 15*60))
+	assert.True(t, IsWithinLogIntervalOfAny(latest.Add(5*time.Minute), stored, 15*60))
+	assert.False(t, IsWithinLogIntervalOfAny(latest, nil, 15*60))
+}
+
+func timePointer(t time.Time) *time.Time {
+	return &t
+}
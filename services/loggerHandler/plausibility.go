@@ -0,0 +1,43 @@
+package loggerhandler
+
+import (
+	"fmt"
+	"math"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+)
+
+// CheckPlausibility validates the physical plausibility of measurement values by channel name.
+// Rules only apply to the default channels part of the reading, so plants with own channel schemas are checked as far as possible.
+// The returned error is suitable for the response.
+func CheckPlausibility(values map[string]float64) error {
+	// Power output must match voltage output x current output within tolerance (P ≈ V·I)
+	voltage, hasVoltage := values["voltageOutput"]
+	current, hasCurrent := values["currentOutput"]
+	power, hasPower := values["powerOutput"]
+	if hasVoltage && hasCurrent && hasPower {
+		expected := voltage * current
+		tolerance := math.Max(config.PlausibilityPowerToleranceAbs, math.Abs(expected)*config.PlausibilityPowerToleranceRel)
+		if math.Abs(power-expected) > tolerance {
+			return fmt.Errorf("Implausible reading: 'powerOutput' %v deviates from 'voltageOutput' x 'currentOutput' %v by more than %v.", power, expected, tolerance)
+		}
+	}
+
+	ranges := []struct {
+		channel  string
+		min, max float64
+	}{
+		{"solarRadiation", 0, config.PlausibilityIrradianceMax},
+		{"relHumidity", 0, 100},
+		{"tAmbient", config.PlausibilityAmbientTemperatureMin, config.PlausibilityAmbientTemperatureMax},
+		{"tModule", config.PlausibilityModuleTemperatureMin, config.PlausibilityModuleTemperatureMax},
+	}
+	for _, r := range ranges {
+		value, exists := values[r.channel]
+		if exists && (value < r.min || value > r.max) {
+			return fmt.Errorf("Implausible reading: '%s' %v is outside of %v to %v.", r.channel, value, r.min, r.max)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package loggerhandler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPlausibility(t *testing.T) {
+	testCases := []struct {
+		name          string
+		values        map[string]float64
+		expectedError bool
+	}{
+		{"Daytime", map[string]float64{"voltageOutput": 40, "currentOutput": 2.87, "powerOutput": 114.8, "solarRadiation": 246, "tAmbient": 5, "tModule": 6, "relHumidity": 77}, false},
+		{"Night", map[string]float64{"voltageOutput": 0, "currentOutput": 0, "powerOutput": 0, "solarRadiation": 0, "tAmbient": -3, "tModule": -4, "relHumidity": 100}, false},
+		{"PowerWithinTolerance", map[string]float64{"voltageOutput": 40, "currentOutput": 25, "powerOutput": 1030}, false},
+		{"PowerMismatch", map[string]float64{"voltageOutput": 40, "currentOutput": 25, "powerOutput": 1200}, true},
+		{"PowerWithoutCurrent", map[string]float64{"voltageOutput": 0, "currentOutput": 0, "powerOutput": 300}, true},
+		{"IrradianceNegative", map[string]float64{"solarRadiation": -1}, true},
+		{"IrradianceTooHigh", map[string]float64{"solarRadiation": 1600}, true},
+		{"HumidityTooHigh", map[string]float64{"relHumidity": 101}, true},
+		{"AmbientTemperatureTooHigh", map[string]float64{"tAmbient": 85}, true},
+		{"ModuleTemperatureTooLow", map[string]float64{"tModule": -60}, true},
+		{"CustomChannelsOnly", map[string]float64{"acPower": 5000, "gridFrequency": 50}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckPlausibility(tc.values)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestParseMeasurementsZeroValues(t *testing.T) {
+	// Night reading of default channels. Zero values are present values, not missing ones
+	night := map[string]interface{}{
+		"voltageOutput":  0.0,
+		"currentOutput":  0.0,
+		"powerOutput":    0.0,
+		"solarRadiation": 0.0,
+		"tAmbient":       0.0,
+		"tModule":        0.0,
+		"relHumidity":    0.0,
+		"windSpeed":      0.0,
+	}
+	plantLog, err := ParseMeasurements(night, DefaultChannels())
+	assert.NoError(t, err)
+	assert.Len(t, plantLog.Values, 8)
+	assert.Equal(t, 0.0, plantLog.Values["powerOutput"])
+}
+
+func TestQuarantineEntries(t *testing.T) {
+	batch := LogBatch{Rejected: []BatchRejection{
+		{Index: 0, Reason: "Reading must be an object."},
+		{Index: 1, Reason: "Implausible reading.", Reading: map[string]interface{}{"key": "k", "secret": "s", "solarRadiation": 1600.0}},
+	}}
+	receivedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	entries := batch.QuarantineEntries(receivedAt)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "Implausible reading.", entries[0].Reason)
+	assert.Equal(t, "batch", entries[0].Source)
+	assert.Equal(t, receivedAt, entries[0].ReceivedAt)
+	assert.Equal(t, map[string]interface{}{"solarRadiation": 1600.0}, entries[0].Reading)
+	assert.Equal(t, "plant_quarantine_123456789012", QuarantineCollectionName("plant_logger_123456789012"))
+}
@@ -0,0 +1,51 @@
+package loggerhandler
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QuarantineCollectionName returns the name of the plant's quarantine collection, sharing the id of its logger collection
+// Eg. 'plant_quarantine_123456789012' for logger collection 'plant_logger_123456789012'
+func QuarantineCollectionName(collectionNameLogger string) string {
+	return "plant_quarantine_" + strings.TrimPrefix(collectionNameLogger, "plant_logger_")
+}
+
+// NewQuarantineEntry prepares a rejected reading for the quarantine collection. Credentials are removed from the reading.
+func NewQuarantineEntry(reading map[string]interface{}, reason, source string, receivedAt time.Time) model.PlantQuarantine {
+	cleanedReading := make(map[string]interface{}, len(reading))
+	for key, value := range reading {
+		if key == "key" || key == "secret" {
+			continue
+		}
+		cleanedReading[key] = value
+	}
+
+	return model.PlantQuarantine{
+		ID:         primitive.NewObjectID(),
+		Reading:    cleanedReading,
+		Reason:     reason,
+		Source:     source,
+		ReceivedAt: receivedAt.UTC(),
+	}
+}
+
+// QuarantineReadings stores rejected readings in the quarantine collection of the plant with logger collection collectionNameLogger
+func QuarantineReadings(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, entries []model.PlantQuarantine) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		documents = append(documents, entry)
+	}
+	_, err := mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, documents, QuarantineCollectionName(collectionNameLogger))
+	return err
+}
@@ -0,0 +1,36 @@
+package loggerhandler
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ParseRetentionDays converts the retention period of the parsed request body, a whole number of days. 0 keeps readings forever.
+// The returned error is suitable for the response.
+func ParseRetentionDays(raw interface{}) (int, error) {
+	retentionDays, ok := raw.(float64)
+	if !ok || retentionDays != math.Trunc(retentionDays) || (retentionDays != 0 && (retentionDays < float64(config.RetentionDaysMin) || retentionDays > float64(config.RetentionDaysMax))) {
+		return 0, fmt.Errorf("'retentionDays' must be 0 (keep readings forever) or a whole number of days between %d and %d.", config.RetentionDaysMin, config.RetentionDaysMax)
+	}
+	return int(retentionDays), nil
+}
+
+// RetentionCutoff returns the start of the day (UTC) readings measured before are beyond the retention period of a plant. Zero if readings are kept forever
+func RetentionCutoff(plantLoggerConfig model.PlantLoggerConfig, now time.Time) time.Time {
+	if plantLoggerConfig.RetentionDays <= 0 {
+		return time.Time{}
+	}
+	return now.UTC().AddDate(0, 0, -plantLoggerConfig.RetentionDays).Truncate(24 * time.Hour)
+}
+
+// ExcludeRehydrated restricts a filter of readings to readings not restored from an archive file. Rehydrated readings can't be amended, as their archive file stays unchanged
+func ExcludeRehydrated(filter bson.M) bson.M {
+	filter["rehydrated_at"] = bson.M{"$exists": false}
+	return filter
+}
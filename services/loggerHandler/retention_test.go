@@ -0,0 +1,27 @@
+package loggerhandler
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetentionDays(t *testing.T) {
+	for _, valid := range []float64{0, 31, 365} {
+		retentionDays, err := ParseRetentionDays(valid)
+		assert.NoError(t, err)
+		assert.Equal(t, int(valid), retentionDays)
+	}
+	for _, invalid := range []interface{}{-1, 30.0, 31.5, 100000.0, "365"} {
+		_, err := ParseRetentionDays(invalid)
+		assert.Error(t, err)
+	}
+}
+
+func TestRetentionCutoff(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 30, 0, 0, time.UTC)
+	assert.True(t, RetentionCutoff(model.PlantLoggerConfig{}, now).IsZero())
+	assert.Equal(t, time.Date(2024, 2, 9, 0, 0, 0, 0, time.UTC), RetentionCutoff(model.PlantLoggerConfig{RetentionDays: 30}, now))
+}
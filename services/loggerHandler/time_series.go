@@ -0,0 +1,121 @@
+package loggerhandler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Granularities of time-series collections, finest first
+var granularities = []string{"seconds", "minutes", "hours"}
+
+// Granularity returns the granularity of a plant logger collection for a logging interval of intervalSec seconds
+func Granularity(intervalSec int) string {
+	switch {
+	case intervalSec < 60:
+		return "seconds"
+	case intervalSec < 60*60:
+		return "minutes"
+	}
+	return "hours"
+}
+
+// LoggerTimeSeriesOptions returns the options of a plant logger collection. Readings are bucketed by measurement time and device.
+// The time field is 'measured_at', which replaced 'created_at' of readings when loggers started providing measurement times. It holds the time of receipt
+// unless provided by the logger, as 'created_at' did. Each plant has its own collection, so the device is the only metadata.
+func LoggerTimeSeriesOptions(intervalSec int) mongodb.TimeSeriesOptions {
+	return mongodb.TimeSeriesOptions{TimeField: "measured_at", MetaField: "device_id", Granularity: Granularity(intervalSec)}
+}
+
+// CreateLoggerCollection creates the time-series collection of a plant logger with a logging interval of intervalSec seconds
+func CreateLoggerCollection(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, intervalSec int) error {
+	return mongoDBInterface.RepositoryInterface.CreateTimeSeriesCollection(ctx, config.DatabaseNamePlantLogger, collectionNameLogger, LoggerTimeSeriesOptions(intervalSec))
+}
+
+// findLoggerCollection returns the plant logger collection collectionNameLogger and if it exists
+func findLoggerCollection(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string) (mongodb.CollectionInfo, bool, error) {
+	collections, err := mongoDBInterface.RepositoryInterface.ListCollections(ctx, config.DatabaseNamePlantLogger, bson.M{"name": collectionNameLogger})
+	if err != nil || len(collections) == 0 {
+		return mongodb.CollectionInfo{}, false, err
+	}
+	return collections[0], true, nil
+}
+
+// AdjustGranularity coarsens the granularity of a time-series plant logger collection after its logging interval changed to intervalSec seconds.
+// MongoDB doesn't permit finer granularities, the granularity is kept then. Collections not converted to time-series yet are left as they are.
+func AdjustGranularity(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, intervalSec int) error {
+	collection, exists, err := findLoggerCollection(ctx, mongoDBInterface, collectionNameLogger)
+	if err != nil || !exists || collection.Type != "timeseries" {
+		return err
+	}
+	granularity := Granularity(intervalSec)
+	if slices.Index(granularities, granularity) <= slices.Index(granularities, collection.TimeSeriesGranularity) {
+		return nil
+	}
+	return mongoDBInterface.RepositoryInterface.SetTimeSeriesGranularity(ctx, config.DatabaseNamePlantLogger, collectionNameLogger, granularity)
+}
+
+// Result of converting one plant logger collection
+type LoggerMigration struct {
+	CollectionName string
+	Granularity    string
+	Converted      int64
+	Skipped        int64 // Readings without measurement time. Kept in '<collection>_premigration'
+	Err            error
+}
+
+// MigrateLoggerCollections converts all plant logger collections which are ordinary collections into time-series collections.
+// MongoDB can't convert collections in place: each collection is renamed, its readings are copied into a new time-series collection of the original name
+// and the renamed collection is dropped, see ConvertToTimeSeriesCollection. Readings stored before measurement times existed get their 'created_at' as
+// measurement time. Granularity is derived from the logging interval of each plant. Sequence numbers and idempotency keys of the readings are claimed,
+// as the unique indexes of the collection are gone. Readings written meanwhile are lost, so the server must be stopped.
+// A failing collection is restored and the remaining collections are converted anyway.
+func MigrateLoggerCollections(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) ([]LoggerMigration, error) {
+	collections, err := mongoDBInterface.RepositoryInterface.ListCollections(ctx, config.DatabaseNamePlantLogger, bson.M{"name": bson.M{"$regex": "^plant_logger_[0-9]+$"}, "type": "collection"})
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []LoggerMigration{}
+	for _, collection := range collections {
+		migration := LoggerMigration{CollectionName: collection.Name}
+
+		// Collections without plant logger config (eg. of deleted plants) use the default interval
+		var plantLoggerConfig model.PlantLoggerConfig
+		_, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(ctx, config.DatabaseNamePlantLoggerConfig, bson.M{"collection_name_logger": collection.Name}, config.CollectionNamePlantLoggerConfig, bson.D{}, &plantLoggerConfig)
+		if err != nil {
+			migration.Err = err
+			migrations = append(migrations, migration)
+			continue
+		}
+		intervalSec := plantLoggerConfig.IntervalSec
+		if intervalSec == 0 {
+			intervalSec = config.IntervalSecDefault
+		}
+		timeSeries := LoggerTimeSeriesOptions(intervalSec)
+		migration.Granularity = timeSeries.Granularity
+
+		pipeline := mongo.Pipeline{
+			bson.D{{Key: "$set", Value: bson.M{"measured_at": bson.M{"$ifNull": bson.A{"$measured_at", "$created_at"}}}}},
+		}
+		migration.Converted, migration.Skipped, migration.Err = mongoDBInterface.RepositoryInterface.ConvertToTimeSeriesCollection(ctx, config.DatabaseNamePlantLogger, collection.Name, timeSeries, pipeline)
+		if migration.Err == nil {
+			// Indexes of the ordinary collection are gone
+			ensuredIdempotencyIndexes.Delete(collection.Name)
+			if err := EnsureIdempotencyIndexes(ctx, mongoDBInterface, collection.Name); err != nil {
+				migration.Err = fmt.Errorf("Collection converted, but indexes are missing. Error: %v", err)
+			} else if _, err := ClaimStoredReadingIdentities(ctx, mongoDBInterface, collection.Name); err != nil {
+				migration.Err = fmt.Errorf("Collection converted, but sequence numbers and idempotency keys aren't claimed. Claim them by repeating schema migration 'plant_identity_claim_stored_readings'. Error: %v", err)
+			}
+		}
+		migrations = append(migrations, migration)
+	}
+	return migrations, nil
+}
@@ -0,0 +1,125 @@
+package loggerhandler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	"github.com/stretchr/testify/assert"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGranularity(t *testing.T) {
+	assert.Equal(t, "seconds", Granularity(1))
+	assert.Equal(t, "seconds", Granularity(59))
+	assert.Equal(t, "minutes", Granularity(60))
+	assert.Equal(t, "minutes", Granularity(15*60))
+	assert.Equal(t, "hours", Granularity(60*60))
+	assert.Equal(t, "hours", Granularity(24*60*60))
+}
+
+func TestLoggerTimeSeriesOptions(t *testing.T) {
+	assert.Equal(t, mongodb.TimeSeriesOptions{TimeField: "measured_at", MetaField: "device_id", Granularity: "minutes"}, LoggerTimeSeriesOptions(900))
+}
+
+func TestMigrateLoggerCollections(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	repository := mongoDBInterface.RepositoryInterface
+	collectionName := "plant_logger_170001"
+	createdAt := time.Date(2023, time.May, 2, 10, 0, 0, 0, time.UTC)
+	measuredAt := createdAt.Add(time.Hour)
+	sequence := int64(7)
+
+	_, err := repository.InsertOneToMongo(ctx, config.DatabaseNamePlantLoggerConfig, model.PlantLoggerConfig{ID: primitive.NewObjectID(), CollectionNameLogger: collectionName, IntervalSec: 60 * 60}, config.CollectionNamePlantLoggerConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, repository.CreateNewCollection(ctx, config.DatabaseNamePlantLogger, collectionName))
+	readings := []interface{}{
+		// Legacy reading stored before measurement times existed
+		bson.M{"_id": primitive.NewObjectID(), "created_at": createdAt, "power_output": 400.0},
+		model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inv-1", Values: map[string]float64{"powerOutput": 410}, MeasuredAt: measuredAt, ReceivedAt: measuredAt, Sequence: &sequence, IdempotencyKey: "reading-2"},
+		// Reading without any time
+		bson.M{"_id": primitive.NewObjectID(), "power_output": 420.0},
+	}
+	_, err = repository.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, readings, collectionName)
+	assert.NoError(t, err)
+
+	migrations, err := MigrateLoggerCollections(ctx, mongoDBInterface)
+	assert.NoError(t, err)
+	assert.Equal(t, []LoggerMigration{{CollectionName: collectionName, Granularity: "hours", Converted: 2, Skipped: 1}}, migrations)
+
+	collection, exists, err := findLoggerCollection(ctx, mongoDBInterface, collectionName)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "timeseries", collection.Type)
+	assert.Equal(t, "hours", collection.TimeSeriesGranularity)
+
+	var converted []model.PlantLogger
+	assert.NoError(t, repository.FindManyInMongo(ctx, config.DatabaseNamePlantLogger, bson.M{}, collectionName, bson.D{{Key: "measured_at", Value: 1}}, &converted))
+	assert.Len(t, converted, 2)
+	assert.True(t, converted[0].MeasuredAt.Equal(createdAt), "legacy reading measured at its creation")
+	assert.True(t, converted[1].MeasuredAt.Equal(measuredAt))
+
+	// Original collection kept for review, as a reading without time has been skipped
+	kept, err := repository.CountDocumentsInMongo(ctx, config.DatabaseNamePlantLogger, collectionName+"_premigration", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, kept)
+
+	// Sequence number and idempotency key of the converted reading are claimed, so retries are duplicates
+	retry := model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inv-1", MeasuredAt: measuredAt, Sequence: &sequence}
+	duplicates, err := ClaimReadingIdentities(ctx, mongoDBInterface, collectionName, []model.PlantLogger{retry, {ID: primitive.NewObjectID(), IdempotencyKey: "reading-2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]bool{0: true, 1: true}, duplicates)
+
+	// Converted collections are skipped
+	migrations, err = MigrateLoggerCollections(ctx, mongoDBInterface)
+	assert.NoError(t, err)
+	assert.Empty(t, migrations)
+}
+
+func TestClaimReadingIdentities(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	collectionName := "plant_logger_170002"
+	assert.NoError(t, CreateLoggerCollection(ctx, mongoDBInterface, collectionName, 60))
+
+	sequence := int64(1)
+	original := model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inv-1", Sequence: &sequence, IdempotencyKey: "a"}
+	sameSequenceOtherDevice := model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inv-2", Sequence: &sequence}
+	withoutIdentity := model.PlantLogger{ID: primitive.NewObjectID()}
+	duplicates, err := ClaimReadingIdentities(ctx, mongoDBInterface, collectionName, []model.PlantLogger{original, sameSequenceOtherDevice, withoutIdentity})
+	assert.NoError(t, err)
+	assert.Empty(t, duplicates)
+
+	// Concurrent retry of the original reading by idempotency key only, and a reading of this batch claiming the same key
+	retry := model.PlantLogger{ID: primitive.NewObjectID(), IdempotencyKey: "a"}
+	otherSequence := int64(2)
+	newReading := model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inv-1", Sequence: &otherSequence}
+	duplicates, err = ClaimReadingIdentities(ctx, mongoDBInterface, collectionName, []model.PlantLogger{retry, newReading})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]bool{0: true}, duplicates)
+
+	// Claims of the same reading, eg. storing it again after a failure, are no conflict
+	duplicates, err = ClaimReadingIdentities(ctx, mongoDBInterface, collectionName, []model.PlantLogger{original})
+	assert.NoError(t, err)
+	assert.Empty(t, duplicates)
+
+	// Released claims can be claimed again
+	assert.NoError(t, ReleaseReadingIdentities(ctx, mongoDBInterface, collectionName, []model.PlantLogger{newReading}))
+	duplicates, err = ClaimReadingIdentities(ctx, mongoDBInterface, collectionName, []model.PlantLogger{{ID: primitive.NewObjectID(), DeviceID: "inv-1", Sequence: &otherSequence}})
+	assert.NoError(t, err)
+	assert.Empty(t, duplicates)
+
+	// A retry stored concurrently with the original is stored once
+	assert.NoError(t, StoreLogEntry(ctx, mongoDBInterface, collectionName, model.PlantLogger{DeviceID: "inv-3", Sequence: &sequence, MeasuredAt: time.Now(), ReceivedAt: time.Now()}))
+	assert.NoError(t, StoreLogEntry(ctx, mongoDBInterface, collectionName, model.PlantLogger{DeviceID: "inv-3", Sequence: &sequence, MeasuredAt: time.Now(), ReceivedAt: time.Now()}))
+	stored, err := mongoDBInterface.RepositoryInterface.CountDocumentsInMongo(ctx, config.DatabaseNamePlantLogger, collectionName, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stored)
+}
@@ -0,0 +1,210 @@
+package loggerhandler
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/utils/data"
+	v "github.com/paulmuenzner/powerplantmanager/utils/validate"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BatchReading is a validated reading of a batch including its position in the submitted array
+type BatchReading struct {
+	Index int
+	Log   model.PlantLogger
+}
+
+// BatchRejection reports why the reading at Index of a batch has not been accepted
+// Reading is set if the reading has been rejected for its content (measurement values or time) and must be quarantined
+type BatchRejection struct {
+	Index   int                    `json:"index"`
+	Reason  string                 `json:"reason"`
+	Reading map[string]interface{} `json:"-"`
+}
+
+// LogBatch is the outcome of validating a batch of buffered readings
+// Duplicates lists the indexes of readings which have already been stored by a previous request (retry). They count as accepted but must not be stored again.
+type LogBatch struct {
+	Accepted   []BatchReading
+	Duplicates []int
+	Rejected   []BatchRejection
+}
+
+// ValidateLogBatch validates each reading of a batch on its own against the plant's channel schema. A reading must contain its own 'measuredAt' timestamp (RFC3339).
+// All readings are tagged with deviceID, empty if reported for the plant as a whole.
+// Readings whose sequence number or idempotency key is part of storedIdentities are reported as duplicates without further validation.
+// Measurement times are checked against the plant's clock skew policy and must respect the plant's logging interval
+// among each other and towards the stored readings of the device (storedTimes, sorted ascending, see FindLogTimesNear and BatchTimeRange).
+func ValidateLogBatch(readings []interface{}, plantConfig model.PlantLoggerConfig, deviceID string, storedTimes []time.Time, receivedAt time.Time, storedIdentities ReadingIdentitySet) LogBatch {
+	batch := LogBatch{Accepted: []BatchReading{}, Duplicates: []int{}, Rejected: []BatchRejection{}}
+	channels := EffectiveChannels(plantConfig)
+	requiredKeys := append([]string{"measuredAt"}, ChannelNames(channels, true)...)
+	optionalKeys := append(ChannelNames(channels, false), IdentityKeys...)
+	submittedIdentities := ReadingIdentitySet{}
+
+	candidates := []BatchReading{}
+	for index, reading := range readings {
+		item, ok := reading.(map[string]interface{})
+		if !ok {
+			batch.Rejected = append(batch.Rejected, BatchRejection{Index: index, Reason: "Reading must be an object."})
+			continue
+		}
+
+		// Validate if reading exactly contains number and names of expected keys. Optional channels, sequence number and idempotency key may be missing
+		expectedKeys := ExpectedReadingKeys(item, requiredKeys, optionalKeys)
+		validateKeys := v.Validate(item).
+			HasMapExactKeys(expectedKeys, "Reading must contain 'measuredAt' and the values of all required channels only.").
+			GetResult()
+		if len(validateKeys) > 0 {
+			batch.Rejected = append(batch.Rejected, BatchRejection{Index: index, Reason: validateKeys[0]})
+			continue
+		}
+
+		// Measurement time provided by the logger
+		measuredAt, err := ParseMeasuredAt(item)
+		if err != nil {
+			batch.Rejected = append(batch.Rejected, BatchRejection{Index: index, Reason: "'measuredAt' must be a RFC3339 timestamp.", Reading: item})
+			continue
+		}
+
+		// Measurement values
+		plantLog, err := ParseMeasurements(item, channels)
+		if err != nil {
+			batch.Rejected = append(batch.Rejected, BatchRejection{Index: index, Reason: err.Error(), Reading: item})
+			continue
+		}
+		plantLog.DeviceID = deviceID
+
+		// Retry of an already stored reading
+		if err := ParseReadingIdentity(item, &plantLog); err != nil {
+			batch.Rejected = append(batch.Rejected, BatchRejection{Index: index, Reason: IdentityRejectionReason(err)})
+			continue
+		}
+		if storedIdentities.Contains(plantLog) {
+			batch.Duplicates = append(batch.Duplicates, index)
+			continue
+		}
+		if submittedIdentities.Contains(plantLog) {
+			batch.Rejected = append(batch.Rejected, BatchRejection{Index: index, Reason: "Sequence number or idempotency key is used more than once within this batch."})
+			continue
+		}
+		submittedIdentities.Add(plantLog)
+
+		plantLog.ID = primitive.NewObjectID()
+		if err := ApplyMeasurementTime(&plantLog, measuredAt, receivedAt, plantConfig.ClockSkewPolicy); err != nil {
+			batch.Rejected = append(batch.Rejected, BatchRejection{Index: index, Reason: MeasurementTimeRejectionReason(err), Reading: item})
+			continue
+		}
+
+		// Validate data against mongodb plant logger model
+		if err := data.ValidateStruct(plantLog); err != nil {
+			batch.Rejected = append(batch.Rejected, BatchRejection{Index: index, Reason: "Reading does not match plant logger model."})
+			continue
+		}
+
+		candidates = append(candidates, BatchReading{Index: index, Log: plantLog})
+	}
+
+	// Rate limit. Same interval rule as for single logs
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Log.MeasuredAt.Before(candidates[j].Log.MeasuredAt)
+	})
+
+	var datePreviousAccepted time.Time
+	for _, candidate := range candidates {
+		measuredAt := candidate.Log.MeasuredAt
+		if IsWithinLogIntervalOfAny(measuredAt, storedTimes, plantConfig.IntervalSec) {
+			batch.Rejected = append(batch.Rejected, BatchRejection{Index: candidate.Index, Reason: "Reading is too close to an already stored reading."})
+			continue
+		}
+		if IsWithinLogInterval(measuredAt, datePreviousAccepted, plantConfig.IntervalSec) {
+			batch.Rejected = append(batch.Rejected, BatchRejection{Index: candidate.Index, Reason: "Reading is too close to a previous reading of this batch."})
+			continue
+		}
+		datePreviousAccepted = measuredAt
+		batch.Accepted = append(batch.Accepted, candidate)
+	}
+
+	// Report rejections in order of submission
+	sort.SliceStable(batch.Rejected, func(i, j int) bool {
+		return batch.Rejected[i].Index < batch.Rejected[j].Index
+	})
+
+	return batch
+}
+
+// BatchTimeRange returns the earliest and latest measurement time readings of a batch may be stored with: their valid 'measuredAt' and,
+// for readings flagged by the clock skew policy or without measurement time, the time of receipt
+func BatchTimeRange(readings []interface{}, receivedAt time.Time) (time.Time, time.Time) {
+	first, last := receivedAt, receivedAt
+	for _, reading := range readings {
+		item, ok := reading.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if measuredAt, err := ParseMeasuredAt(item); err == nil && measuredAt != nil {
+			if measuredAt.Before(first) {
+				first = *measuredAt
+			}
+			if measuredAt.After(last) {
+				last = *measuredAt
+			}
+		}
+	}
+	return first, last
+}
+
+// QuarantineEntries returns the readings rejected for their content, prepared for the plant's quarantine collection
+func (batch LogBatch) QuarantineEntries(receivedAt time.Time) []model.PlantQuarantine {
+	entries := []model.PlantQuarantine{}
+	for _, rejection := range batch.Rejected {
+		if rejection.Reading == nil {
+			continue
+		}
+		entries = append(entries, NewQuarantineEntry(rejection.Reading, rejection.Reason, "batch", receivedAt))
+	}
+	return entries
+}
+
+// ParseBatchReadingIdentities returns the sequence numbers and idempotency keys of all readings of a batch, needed to look up already stored readings.
+// Readings without or with invalid identity are skipped. They are rejected by ValidateLogBatch if invalid.
+func ParseBatchReadingIdentities(readings []interface{}, deviceID string) []model.PlantLogger {
+	identities := []model.PlantLogger{}
+	for _, reading := range readings {
+		item, ok := reading.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		identity := model.PlantLogger{DeviceID: deviceID}
+		if err := ParseReadingIdentity(item, &identity); err != nil {
+			continue
+		}
+		if len(readingIdentities(identity)) > 0 {
+			identities = append(identities, identity)
+		}
+	}
+	return identities
+}
+
+// MeasurementTimeRejectionReason translates errors of ParseMeasuredAt and ApplyMeasurementTime into a response message
+func MeasurementTimeRejectionReason(err error) string {
+	switch {
+	case errors.Is(err, ErrMeasuredAtInFuture):
+		return "'measuredAt' lies further in the future than tolerated."
+	case errors.Is(err, ErrMeasuredAtTooOld):
+		return "'measuredAt' lies further in the past than tolerated."
+	default:
+		return "'measuredAt' must be a RFC3339 timestamp."
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,134 @@
+package loggerhandler
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func testReading(measuredAt string) map[string]interface{} {
+	return map[string]interface{}{
+		"measuredAt":     measuredAt,
+		"voltageOutput":  40.0,
+		"currentOutput":  2.87,
+		"powerOutput":    114.8,
+		"solarRadiation": 246.0,
+		"tAmbient":       5.0,
+		"tModule":        6.0,
+		"relHumidity":    77.0,
+		"windSpeed":      5.0,
+	}
+}
+
+func TestValidateLogBatch(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	// Latest stored reading and an earlier one
+	storedTimes := []time.Time{time.Date(2024, time.March, 10, 8, 0, 0, 0, time.UTC), time.Date(2024, time.March, 10, 11, 30, 0, 0, time.UTC)}
+
+	missingKey := testReading("2024-03-10T09:00:00Z")
+	delete(missingKey, "windSpeed")
+
+	readings := []interface{}{
+		testReading("2024-03-10T10:00:00Z"), // 0 accepted
+		testReading("2024-03-10T09:00:00Z"), // 1 accepted, buffered readings may arrive unordered
+		testReading("2024-03-10T09:05:00Z"), // 2 too close to reading 1
+		testReading("2024-03-10T08:10:00Z"), // 3 backfilled, too close to an earlier stored reading
+		testReading("2024-03-10T13:00:00Z"), // 4 future
+		testReading("2023-01-01T00:00:00Z"), // 5 too old
+		testReading("not a timestamp"),      // 6 invalid timestamp
+		missingKey,                          // 7 missing measurement
+		"no object",                         // 8 wrong type
+	}
+
+	batch := ValidateLogBatch(readings, model.PlantLoggerConfig{IntervalSec: 15 * 60, ClockSkewPolicy: DefaultClockSkewPolicy()}, "", storedTimes, now, ReadingIdentitySet{})
+
+	acceptedIndexes := []int{}
+	for _, reading := range batch.Accepted {
+		acceptedIndexes = append(acceptedIndexes, reading.Index)
+	}
+	rejectedIndexes := []int{}
+	for _, rejection := range batch.Rejected {
+		rejectedIndexes = append(rejectedIndexes, rejection.Index)
+		assert.NotEmpty(t, rejection.Reason)
+	}
+
+	assert.Equal(t, []int{1, 0}, acceptedIndexes)
+	assert.Equal(t, []int{2, 3, 4, 5, 6, 7, 8}, rejectedIndexes)
+	assert.Equal(t, time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC), batch.Accepted[0].Log.MeasuredAt)
+	assert.Equal(t, now, batch.Accepted[0].Log.ReceivedAt)
+}
+
+func TestValidateLogBatchWithoutStoredReadings(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	readings := []interface{}{testReading("2024-03-10T11:59:00Z")}
+
+	batch := ValidateLogBatch(readings, model.PlantLoggerConfig{IntervalSec: 15 * 60}, "", nil, now, ReadingIdentitySet{})
+
+	assert.Len(t, batch.Accepted, 1)
+	assert.Empty(t, batch.Rejected)
+}
+
+func TestValidateLogBatchIdempotency(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	storedSequence := int64(7)
+	storedIdentities := NewReadingIdentitySet([]model.PlantLogger{{Sequence: &storedSequence}, {IdempotencyKey: "stored"}})
+
+	withIdentity := func(measuredAt string, key string, value interface{}) map[string]interface{} {
+		reading := testReading(measuredAt)
+		reading[key] = value
+		return reading
+	}
+
+	readings := []interface{}{
+		withIdentity("2024-03-10T08:00:00Z", "sequence", 7.0),               // 0 duplicate of stored reading
+		withIdentity("2024-03-10T08:05:00Z", "idempotencyKey", "stored"),    // 1 duplicate of stored reading, interval not checked
+		withIdentity("2024-03-10T09:00:00Z", "sequence", 8.0),               // 2 accepted
+		withIdentity("2024-03-10T10:00:00Z", "sequence", 8.0),               // 3 sequence used twice within batch
+		withIdentity("2024-03-10T11:00:00Z", "sequence", 8.5),               // 4 invalid sequence
+		withIdentity("2024-03-10T11:30:00Z", "idempotencyKey", ""),          // 5 invalid idempotency key
+		withIdentity("2024-03-10T11:45:00Z", "idempotencyKey", "new-entry"), // 6 accepted
+	}
+
+	identities := ParseBatchReadingIdentities(readings, "")
+	assert.Len(t, identities, 5)
+	assert.NotNil(t, ReadingIdentityFilter(identities...))
+	assert.Nil(t, ReadingIdentityFilter(model.PlantLogger{}))
+
+	batch := ValidateLogBatch(readings, model.PlantLoggerConfig{IntervalSec: 15 * 60}, "", nil, now, storedIdentities)
+
+	rejectedIndexes := []int{}
+	for _, rejection := range batch.Rejected {
+		rejectedIndexes = append(rejectedIndexes, rejection.Index)
+	}
+	assert.Equal(t, []int{0, 1}, batch.Duplicates)
+	assert.Equal(t, []int{3, 4, 5}, rejectedIndexes)
+	assert.Equal(t, "'sequence' must be a non-negative integer.", batch.Rejected[1].Reason)
+	assert.Len(t, batch.Accepted, 2)
+	assert.Equal(t, int64(8), *batch.Accepted[0].Log.Sequence)
+	assert.Equal(t, "new-entry", batch.Accepted[1].Log.IdempotencyKey)
+}
+
+func TestValidateLogBatchDeviceSequence(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	storedSequence := int64(7)
+	// Sequence number 7 stored by another device of the plant
+	storedIdentities := NewReadingIdentitySet([]model.PlantLogger{{DeviceID: "inverter-1", Sequence: &storedSequence}})
+
+	reading := testReading("2024-03-10T11:00:00Z")
+	reading["sequence"] = 7.0
+	readings := []interface{}{reading}
+
+	batch := ValidateLogBatch(readings, model.PlantLoggerConfig{IntervalSec: 15 * 60}, "inverter-2", nil, now, storedIdentities)
+
+	assert.Empty(t, batch.Duplicates)
+	assert.Len(t, batch.Accepted, 1)
+	assert.Equal(t, "inverter-2", batch.Accepted[0].Log.DeviceID)
+
+	identities := ParseBatchReadingIdentities(readings, "inverter-2")
+	assert.Equal(t, "inverter-2", identities[0].DeviceID)
+	filter := ReadingIdentityFilter(identities...)
+	assert.Equal(t, bson.A{bson.M{"device_id": "inverter-2", "sequence": bson.M{"$in": bson.A{int64(7)}}}}, filter["$or"])
+}
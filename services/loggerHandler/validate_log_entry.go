@@ -0,0 +1,163 @@
+package loggerhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/utils/data"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	v "github.com/paulmuenzner/powerplantmanager/utils/validate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ReadingRejection is returned by ValidateLogEntry if a reading is rejected for its content. Reason is suitable for the response
+type ReadingRejection struct {
+	Reason string
+}
+
+func (rejection *ReadingRejection) Error() string {
+	return rejection.Reason
+}
+
+// ValidateLogEntry validates a single reading of an authenticated logger against the plant's channel schema, clock skew policy and logging interval.
+// credentialKeys are the keys of data carrying the logger credentials, empty for signed requests. Readings rejected for their measurement values
+// or time are quarantined with source, eg. 'log' or 'mqtt'. Used by every route and channel receiving single plant logs.
+// Returns the reading to store with StoreLogEntry and if it's a retry of an already stored reading, which must not be stored again.
+// Rejections are returned as *ReadingRejection, any other error is internal.
+func ValidateLogEntry(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, plantConfig model.PlantLoggerConfig, device model.PlantDevice, data map[string]interface{}, credentialKeys []string, source string, receivedAt time.Time) (model.PlantLogger, bool, error) {
+	// Validate if data exactly contains number and names of expected keys
+	// Optional channels, measurement time, sequence number, idempotency key and device may be missing
+	channels := EffectiveChannels(plantConfig)
+	requiredKeys := append(append([]string{}, credentialKeys...), ChannelNames(channels, true)...)
+	optionalKeys := append(append(ChannelNames(channels, false), "measuredAt", "deviceID"), IdentityKeys...)
+	expectedKeys := ExpectedReadingKeys(data, requiredKeys, optionalKeys)
+	validateKeys := v.Validate(data).
+		HasMapExactKeys(expectedKeys).
+		GetResult()
+	if len(data) != len(expectedKeys) || len(validateKeys) > 0 {
+		return model.PlantLogger{}, false, &ReadingRejection{Reason: "Request must contain the values of all required channels and only of channels defined for this plant."}
+	}
+
+	// Define and check measurement values against the plant's channel schema
+	plantLog, err := ParseMeasurements(data, channels)
+	if err != nil {
+		quarantineReading(ctx, mongoDBInterface, plantConfig, data, err.Error(), source, receivedAt)
+		return plantLog, false, &ReadingRejection{Reason: err.Error()}
+	}
+
+	// Define and check optional measurement time
+	measuredAt, err := ParseMeasuredAt(data)
+	if err != nil {
+		quarantineReading(ctx, mongoDBInterface, plantConfig, data, MeasurementTimeRejectionReason(err), source, receivedAt)
+		return plantLog, false, &ReadingRejection{Reason: MeasurementTimeRejectionReason(err)}
+	}
+
+	// Define and check reporting device. Sequence numbers are counted per device
+	plantLog.DeviceID, err = ResolveReadingDevice(ctx, mongoDBInterface, plantConfig, device, data)
+	if errors.Is(err, ErrDeviceIDInvalid) || errors.Is(err, ErrDeviceMismatch) {
+		return plantLog, false, &ReadingRejection{Reason: err.Error()}
+	}
+	if err != nil {
+		return plantLog, false, err
+	}
+
+	// Define and check optional sequence number and idempotency key
+	if err := ParseReadingIdentity(data, &plantLog); err != nil {
+		return plantLog, false, &ReadingRejection{Reason: IdentityRejectionReason(err)}
+	}
+
+	// A retry of an already stored reading (same sequence number or idempotency key) is answered like the original request
+	// without storing it again. Hence, it's checked ahead of clock skew policy and rate limit
+	collectionNameLogger := plantConfig.CollectionNameLogger
+	if identityFilter := ReadingIdentityFilter(plantLog); identityFilter != nil {
+		if err := EnsureIdempotencyIndexes(ctx, mongoDBInterface, collectionNameLogger); err != nil {
+			return plantLog, false, fmt.Errorf("Error in 'ValidateLogEntry()' using 'EnsureIdempotencyIndexes()' for collection '%s'. Error: %w", collectionNameLogger, err)
+		}
+
+		var storedLog model.PlantLogger
+		isDuplicate, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(ctx, config.DatabaseNamePlantLogger, identityFilter, collectionNameLogger, bson.D{}, &storedLog)
+		if err != nil {
+			return plantLog, false, fmt.Errorf("Error in 'ValidateLogEntry()' using 'FindOneInMongo()' looking up sequence number or idempotency key in collection '%s' part of database '%s'. Error: %w", collectionNameLogger, config.DatabaseNamePlantLogger, err)
+		}
+		if isDuplicate {
+			return plantLog, true, nil
+		}
+	}
+
+	// Apply plant's clock skew policy to provided measurement time. Time of receipt is used if not provided
+	if err := ApplyMeasurementTime(&plantLog, measuredAt, receivedAt, plantConfig.ClockSkewPolicy); err != nil {
+		quarantineReading(ctx, mongoDBInterface, plantConfig, data, MeasurementTimeRejectionReason(err), source, receivedAt)
+		return plantLog, false, &ReadingRejection{Reason: MeasurementTimeRejectionReason(err)}
+	}
+
+	// Validate time interval to prevent spamming (rate limiter)
+	// Find stored readings of the reporting device within the interval, as devices of a plant report separately. Backfilled readings are checked alike
+	storedTimes, err := FindLogTimesNear(ctx, mongoDBInterface, collectionNameLogger, plantLog.DeviceID, plantLog.MeasuredAt, plantLog.MeasuredAt, plantConfig.IntervalSec)
+	if err != nil {
+		return plantLog, false, fmt.Errorf("Error in 'ValidateLogEntry()' using 'FindLogTimesNear()' in collection '%s' part of database '%s'. Error: %w", collectionNameLogger, config.DatabaseNamePlantLogger, err)
+	}
+	if len(storedTimes) > 0 {
+		return plantLog, false, LogIntervalRejection(plantConfig.IntervalSec)
+	}
+
+	return plantLog, false, nil
+}
+
+// LogIntervalRejection rejects a reading measured within the logging interval of intervalSec seconds of another reading of its device
+func LogIntervalRejection(intervalSec int) *ReadingRejection {
+	return &ReadingRejection{Reason: "No permission to save new log. Minimum time difference between logs in seconds: " + strconv.Itoa(intervalSec)}
+}
+
+// StoreLogEntry saves a reading validated by ValidateLogEntry to the plant logger collection collectionNameLogger.
+// A concurrent retry having stored the same sequence number or idempotency key first is not an error.
+func StoreLogEntry(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, plantLog model.PlantLogger) error {
+	plantLog.ID = primitive.NewObjectID()
+	storedAt := time.Now().UTC()
+	plantLog.StoredAt = &storedAt
+
+	// Validate data against mongodb plant logger model
+	if err := data.ValidateStruct(plantLog); err != nil {
+		return fmt.Errorf("Data validation against mongodb plant logger model failed in 'StoreLogEntry()' using 'ValidateStruct()'. Error: %v", err)
+	}
+
+	// Claim sequence number and idempotency key first, time-series collections can't keep them unique
+	duplicates, err := ClaimReadingIdentities(ctx, mongoDBInterface, collectionNameLogger, []model.PlantLogger{plantLog})
+	if err != nil {
+		return fmt.Errorf("Unable to save new plant log in 'StoreLogEntry()' using 'ClaimReadingIdentities()'. Collection name: %s. Error: %w", collectionNameLogger, err)
+	}
+	if duplicates[0] {
+		return nil
+	}
+
+	_, err = mongoDBInterface.RepositoryInterface.InsertOneToMongo(ctx, config.DatabaseNamePlantLogger, plantLog, collectionNameLogger)
+	if err != nil {
+		if errRelease := ReleaseReadingIdentities(ctx, mongoDBInterface, collectionNameLogger, []model.PlantLogger{plantLog}); errRelease != nil {
+			logger.GetLogger().Errorf("Error in 'StoreLogEntry()' using 'ReleaseReadingIdentities()'. Collection name: %s. Error: %v", collectionNameLogger, errRelease)
+		}
+	}
+	// Stored by a retry before claims existed, as found by the unique indexes of ordinary collections
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to save new plant log in 'StoreLogEntry()' using 'InsertOneToMongo()'. Collection name: %s. Error: %w", collectionNameLogger, err)
+	}
+	return nil
+}
+
+// quarantineReading stores a rejected single reading with its reason. Failing to do so doesn't change the outcome of the validation.
+func quarantineReading(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, plantConfig model.PlantLoggerConfig, data map[string]interface{}, reason, source string, receivedAt time.Time) {
+	entry := NewQuarantineEntry(data, reason, source, receivedAt)
+	if err := QuarantineReadings(ctx, mongoDBInterface, plantConfig.CollectionNameLogger, []model.PlantQuarantine{entry}); err != nil {
+		logger.GetLogger().Errorf("Error in 'quarantineReading()' using 'QuarantineReadings()' for plant with collection '%s'. Error: %v", plantConfig.CollectionNameLogger, err)
+	}
+}
@@ -0,0 +1,120 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const lockID = "lock"
+
+// errLockLost is returned once the lock has been taken over by another run, eg. after this run was paused longer than MigrationLockTimeoutSec
+var errLockLost = errors.New("migration lock taken over by another run")
+
+// runLock is the migration lock held by a run. It's refreshed every MigrationLockRefreshSec, so long migrations aren't taken over as stale
+type runLock struct {
+	mongoDBInterface *mongodb.MethodInterface
+	owner            string
+	cancel           context.CancelFunc
+	waitGroup        sync.WaitGroup
+}
+
+// lock acquires the migration lock, waiting up to MigrationLockWaitSec for a run of another server instance. Stale locks are taken over.
+// The returned context ends when the lock is lost, so migrations in progress stop. Release the lock when done.
+func lock(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) (*runLock, context.Context, error) {
+	repository := mongoDBInterface.RepositoryInterface
+	hostName, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", hostName, os.Getpid())
+	deadline := time.Now().Add(time.Duration(config.MigrationLockWaitSec) * time.Second)
+
+	for {
+		lockedAt := time.Now().UTC()
+		_, err := repository.InsertOneToMongo(ctx, config.DatabaseNameSchemaMigrations, model.SchemaMigrationLock{ID: lockID, Owner: owner, LockedAt: lockedAt}, config.CollectionNameSchemaMigrationsLock)
+		if err == nil {
+			lockCtx, cancel := context.WithCancel(ctx)
+			l := &runLock{mongoDBInterface: mongoDBInterface, owner: owner, cancel: cancel}
+			l.waitGroup.Add(1)
+			go l.keepAlive(lockCtx)
+			return l, lockCtx, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, nil, fmt.Errorf("Error in 'lock()' using 'InsertOneToMongo()' in collection '%s'. Error: %v", config.CollectionNameSchemaMigrationsLock, err)
+		}
+
+		// Locked by another run. Take over stale locks, eg. of a crashed server
+		var current model.SchemaMigrationLock
+		found, err := repository.FindOneInMongo(ctx, config.DatabaseNameSchemaMigrations, bson.M{"_id": lockID}, config.CollectionNameSchemaMigrationsLock, bson.D{}, &current)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error in 'lock()' using 'FindOneInMongo()' in collection '%s'. Error: %v", config.CollectionNameSchemaMigrationsLock, err)
+		}
+		if found && lockedAt.Sub(current.LockedAt) > time.Duration(config.MigrationLockTimeoutSec)*time.Second {
+			_, err := repository.DeleteDocumentMongo(ctx, config.DatabaseNameSchemaMigrations, bson.M{"_id": lockID, "locked_at": current.LockedAt}, config.CollectionNameSchemaMigrationsLock)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Error in 'lock()' using 'DeleteDocumentMongo()'. Cannot take over stale migration lock of '%s'. Error: %v", current.Owner, err)
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, nil, fmt.Errorf("Migrations are locked by '%s' since %s. Retry later or, if no migration is running, delete the lock document in collection '%s'.", current.Owner, current.LockedAt.Format(time.RFC3339), config.CollectionNameSchemaMigrationsLock)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// keepAlive refreshes the lock until ctx ends. A lost lock ends ctx
+func (l *runLock) keepAlive(ctx context.Context) {
+	defer l.waitGroup.Done()
+	ticker := time.NewTicker(time.Duration(config.MigrationLockRefreshSec) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := l.check(ctx)
+			if errors.Is(err, errLockLost) {
+				logger.GetLogger().Errorf("Error in 'keepAlive()' using 'check()'. Migrations in progress are canceled. Error: %v", err)
+				l.cancel()
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				logger.GetLogger().Warnf("Error in 'keepAlive()' using 'check()'. Refreshing the migration lock failed, retried in %d seconds. Error: %v", config.MigrationLockRefreshSec, err)
+			}
+		}
+	}
+}
+
+// check refreshes the lock and returns errLockLost if it's held by another run. Called before each migration is recorded
+func (l *runLock) check(ctx context.Context) error {
+	filter := bson.M{"_id": lockID, "owner": l.owner}
+	result, err := l.mongoDBInterface.RepositoryInterface.UpdateOneInMongo(ctx, config.DatabaseNameSchemaMigrations, filter, bson.M{"$set": bson.M{"locked_at": time.Now().UTC()}}, config.CollectionNameSchemaMigrationsLock)
+	if err != nil {
+		return fmt.Errorf("Error in 'check()' using 'UpdateOneInMongo()' in collection '%s'. Error: %w", config.CollectionNameSchemaMigrationsLock, err)
+	}
+	if result.MatchedCount == 0 {
+		return errLockLost
+	}
+	return nil
+}
+
+// release stops refreshing and deletes the lock, unless taken over meanwhile
+func (l *runLock) release(ctx context.Context) {
+	l.cancel()
+	l.waitGroup.Wait()
+	_, err := l.mongoDBInterface.RepositoryInterface.DeleteDocumentMongo(ctx, config.DatabaseNameSchemaMigrations, bson.M{"_id": lockID, "owner": l.owner}, config.CollectionNameSchemaMigrationsLock)
+	if err != nil {
+		logger.GetLogger().Errorf("Error in 'release()' using 'DeleteDocumentMongo()'. Cannot release migration lock. It is taken over after %d seconds. Error: %v", config.MigrationLockTimeoutSec, err)
+	}
+}
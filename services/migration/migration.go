@@ -0,0 +1,225 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Directions of a migration run
+const (
+	DirectionUp   string = "up"
+	DirectionDown string = "down"
+)
+
+// Migration is a versioned change of the database schema, eg. an index. Up and Down must be safe to run again after partial failure
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error
+	Down    func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error // Nil if the migration can't be reverted
+	Manual  bool                                                                       // Applied only by a run with a target of at least its version, never by applying all pending migrations, eg. at startup
+}
+
+// Step is a migration applied or reverted by a run, or planned to be in a dry run
+type Step struct {
+	Version   int
+	Name      string
+	Direction string
+	Duration  time.Duration
+}
+
+// Status of a migration. AppliedAt is nil for pending migrations
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Run applies pending migrations up to version target (direction up) or reverts applied migrations above version target (direction down).
+// A negative target applies all pending migrations apart from manual ones or reverts the latest applied one. With dryRun, the steps are planned only.
+// Runs of several server instances are serialized by a lock, refreshed while migrations run. Migrations are only recorded while the lock is held.
+// Run stops at the first failing migration and returns the steps done before.
+func Run(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, direction string, target int, dryRun bool) ([]Step, error) {
+	if err := validate(Migrations); err != nil {
+		return nil, err
+	}
+	var migrationLock *runLock
+	if !dryRun {
+		var lockCtx context.Context
+		var err error
+		migrationLock, lockCtx, err = lock(ctx, mongoDBInterface)
+		if err != nil {
+			return nil, err
+		}
+		defer migrationLock.release(ctx)
+		ctx = lockCtx
+	}
+
+	applied, err := findApplied(ctx, mongoDBInterface)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := plan(Migrations, applied, direction, target)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := []Step{}
+	for _, migration := range migrations {
+		step := Step{Version: migration.Version, Name: migration.Name, Direction: direction}
+		if dryRun {
+			steps = append(steps, step)
+			continue
+		}
+		start := time.Now()
+		if direction == DirectionUp {
+			err = apply(ctx, mongoDBInterface, migrationLock, migration, start)
+		} else {
+			err = revert(ctx, mongoDBInterface, migrationLock, migration)
+		}
+		if err != nil {
+			return steps, fmt.Errorf("Migration %d '%s' (%s) failed. Error: %v", migration.Version, migration.Name, direction, err)
+		}
+		step.Duration = time.Since(start)
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// Statuses lists all known migrations with the time they were applied
+func Statuses(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) ([]Status, error) {
+	applied, err := findApplied(ctx, mongoDBInterface)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnknown(Migrations, applied); err != nil {
+		return nil, err
+	}
+	statuses := []Status{}
+	for _, migration := range Migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, exists := applied[migration.Version]; exists {
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// findApplied returns the applied migrations by version
+func findApplied(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) (map[int]model.SchemaMigration, error) {
+	var records []model.SchemaMigration
+	err := mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNameSchemaMigrations, bson.M{}, config.CollectionNameSchemaMigrations, bson.D{{Key: "_id", Value: 1}}, &records)
+	if err != nil {
+		return nil, fmt.Errorf("Error in 'findApplied()' using 'FindManyInMongo()' in collection '%s'. Error: %v", config.CollectionNameSchemaMigrations, err)
+	}
+	applied := map[int]model.SchemaMigration{}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// apply runs a migration and records it as applied, if the lock is still held
+func apply(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, migrationLock *runLock, migration Migration, start time.Time) error {
+	if err := migration.Up(ctx, mongoDBInterface); err != nil {
+		return err
+	}
+	if err := migrationLock.check(ctx); err != nil {
+		return fmt.Errorf("Migration applied, but not recorded. Error: %w", err)
+	}
+	record := model.SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: start.UTC(), DurationMs: time.Since(start).Milliseconds()}
+	_, err := mongoDBInterface.RepositoryInterface.InsertOneToMongo(ctx, config.DatabaseNameSchemaMigrations, record, config.CollectionNameSchemaMigrations)
+	if err != nil {
+		return fmt.Errorf("Migration applied, but not recorded in collection '%s'. Error: %v", config.CollectionNameSchemaMigrations, err)
+	}
+	return nil
+}
+
+// revert reverts a migration and removes its record, if the lock is still held
+func revert(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, migrationLock *runLock, migration Migration) error {
+	if err := migration.Down(ctx, mongoDBInterface); err != nil {
+		return err
+	}
+	if err := migrationLock.check(ctx); err != nil {
+		return fmt.Errorf("Migration reverted, but still recorded. Error: %w", err)
+	}
+	_, err := mongoDBInterface.RepositoryInterface.DeleteDocumentMongo(ctx, config.DatabaseNameSchemaMigrations, bson.M{"_id": migration.Version}, config.CollectionNameSchemaMigrations)
+	if err != nil {
+		return fmt.Errorf("Migration reverted, but still recorded in collection '%s'. Error: %v", config.CollectionNameSchemaMigrations, err)
+	}
+	return nil
+}
+
+// plan returns the migrations to run in their order: pending ones up to target ascending or applied ones above target descending.
+// Older pending migrations are applied even if newer ones are applied already, eg. after merging branches. Manual migrations require a target.
+func plan(migrations []Migration, applied map[int]model.SchemaMigration, direction string, target int) ([]Migration, error) {
+	if err := checkUnknown(migrations, applied); err != nil {
+		return nil, err
+	}
+
+	planned := []Migration{}
+	switch direction {
+	case DirectionUp:
+		for _, migration := range migrations {
+			if _, exists := applied[migration.Version]; !exists && (target < 0 && !migration.Manual || target >= 0 && migration.Version <= target) {
+				planned = append(planned, migration)
+			}
+		}
+	case DirectionDown:
+		if target < 0 {
+			target = 0
+			for version := range applied {
+				target = max(target, version)
+			}
+			target--
+		}
+		for index := len(migrations) - 1; index >= 0; index-- {
+			migration := migrations[index]
+			if _, exists := applied[migration.Version]; !exists || migration.Version <= target {
+				continue
+			}
+			if migration.Down == nil {
+				return nil, fmt.Errorf("Migration %d '%s' can't be reverted.", migration.Version, migration.Name)
+			}
+			planned = append(planned, migration)
+		}
+	default:
+		return nil, fmt.Errorf("Unknown migration direction '%s'. Use '%s' or '%s'.", direction, DirectionUp, DirectionDown)
+	}
+	return planned, nil
+}
+
+// checkUnknown fails if migrations were applied that this server version doesn't know, eg. by a newer version
+func checkUnknown(migrations []Migration, applied map[int]model.SchemaMigration) error {
+	for version, record := range applied {
+		known := slices.ContainsFunc(migrations, func(migration Migration) bool { return migration.Version == version })
+		if !known {
+			return fmt.Errorf("Migration %d '%s' applied to the database is unknown to this server version.", version, record.Name)
+		}
+	}
+	return nil
+}
+
+// validate checks that migrations are ordered by unique positive versions and named
+func validate(migrations []Migration) error {
+	previous := 0
+	for _, migration := range migrations {
+		if migration.Version <= previous {
+			return fmt.Errorf("Migration %d '%s' must have a version greater than %d.", migration.Version, migration.Name, previous)
+		}
+		if migration.Name == "" || migration.Up == nil {
+			return fmt.Errorf("Migration %d must have a name and an up function.", migration.Version)
+		}
+		previous = migration.Version
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package migration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	"github.com/stretchr/testify/assert"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func noop(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error { return nil }
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "first", Up: noop, Down: noop},
+	{Version: 2, Name: "second", Up: noop, Down: noop},
+	{Version: 3, Name: "third", Up: noop},
+	{Version: 5, Name: "fifth", Up: noop, Down: noop},
+}
+
+func applied(versions ...int) map[int]model.SchemaMigration {
+	records := map[int]model.SchemaMigration{}
+	for _, version := range versions {
+		records[version] = model.SchemaMigration{Version: version}
+	}
+	return records
+}
+
+func versions(migrations []Migration) []int {
+	result := []int{}
+	for _, migration := range migrations {
+		result = append(result, migration.Version)
+	}
+	return result
+}
+
+func TestMigrationsAreValid(t *testing.T) {
+	assert.NoError(t, validate(Migrations))
+	for _, migration := range Migrations {
+		// The time-series conversion is irreversible
+		if migration.Version != VersionTimeSeries {
+			assert.NotNil(t, migration.Down, migration.Name)
+		}
+	}
+	_, err := plan(Migrations, applied(1, 2, 3, 4, 5, 6, 7, VersionTimeSeries), DirectionDown, -1)
+	assert.Error(t, err)
+
+	// Not applied with all pending migrations, eg. at startup
+	planned, err := plan(Migrations, applied(), DirectionUp, -1)
+	assert.NoError(t, err)
+	assert.NotContains(t, versions(planned), VersionTimeSeries)
+	planned, err = plan(Migrations, applied(), DirectionUp, VersionTimeSeries)
+	assert.NoError(t, err)
+	assert.Contains(t, versions(planned), VersionTimeSeries)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, validate(testMigrations))
+	assert.Error(t, validate([]Migration{{Version: 2, Name: "a", Up: noop}, {Version: 1, Name: "b", Up: noop}}))
+	assert.Error(t, validate([]Migration{{Version: 1, Name: "a", Up: noop}, {Version: 1, Name: "b", Up: noop}}))
+	assert.Error(t, validate([]Migration{{Version: 0, Name: "a", Up: noop}}))
+	assert.Error(t, validate([]Migration{{Version: 1, Up: noop}}))
+}
+
+func TestPlanUp(t *testing.T) {
+	planned, err := plan(testMigrations, applied(), DirectionUp, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 5}, versions(planned))
+
+	planned, err = plan(testMigrations, applied(1), DirectionUp, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 3}, versions(planned))
+
+	// Older pending migrations are applied after newer ones
+	planned, err = plan(testMigrations, applied(1, 3, 5), DirectionUp, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2}, versions(planned))
+}
+
+func TestPlanDown(t *testing.T) {
+	// Default reverts the latest applied migration
+	planned, err := plan(testMigrations, applied(1, 2, 3, 5), DirectionDown, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5}, versions(planned))
+
+	planned, err = plan(testMigrations, applied(1, 2, 5), DirectionDown, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5, 2, 1}, versions(planned))
+
+	planned, err = plan(testMigrations, applied(), DirectionDown, -1)
+	assert.NoError(t, err)
+	assert.Empty(t, planned)
+
+	// Migration 3 has no down function
+	_, err = plan(testMigrations, applied(1, 2, 3), DirectionDown, 1)
+	assert.Error(t, err)
+}
+
+func TestPlanRejectsUnknownMigrations(t *testing.T) {
+	_, err := plan(testMigrations, applied(1, 4), DirectionUp, -1)
+	assert.Error(t, err)
+	_, err = plan(testMigrations, applied(1), "sideways", -1)
+	assert.Error(t, err)
+}
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	repository := mongoDBInterface.RepositoryInterface
+
+	migrationLock, lockCtx, err := lock(ctx, mongoDBInterface)
+	assert.NoError(t, err)
+	assert.NoError(t, migrationLock.check(ctx))
+
+	// Taken over by another run, eg. after this one paused longer than the timeout. Migrations aren't recorded anymore
+	_, err = repository.UpdateOneInMongo(ctx, config.DatabaseNameSchemaMigrations, bson.M{"_id": lockID}, bson.M{"$set": bson.M{"owner": "other:1"}}, config.CollectionNameSchemaMigrationsLock)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, migrationLock.check(ctx), errLockLost)
+	err = apply(lockCtx, mongoDBInterface, migrationLock, Migration{Version: 1, Name: "first", Up: noop, Down: noop}, time.Now())
+	assert.ErrorIs(t, err, errLockLost)
+	found, err := findApplied(ctx, mongoDBInterface)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+
+	// The lock of the other run is kept
+	migrationLock.release(ctx)
+	assert.Error(t, lockCtx.Err())
+	var current model.SchemaMigrationLock
+	exists, err := repository.FindOneInMongo(ctx, config.DatabaseNameSchemaMigrations, bson.M{"_id": lockID}, config.CollectionNameSchemaMigrationsLock, bson.D{}, &current)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "other:1", current.Owner)
+}
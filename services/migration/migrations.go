@@ -0,0 +1,159 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Migrations of the database schema, ordered by version. Append new migrations with the next version, never change or remove applied ones.
+// Per-plant collections are created with their indexes when a plant is added and aren't covered here.
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "user_auth_unique_email",
+		Up: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			return mongoDBInterface.RepositoryInterface.CreateUniqueIndex(ctx, config.UserAuthCollectionName, config.DatabaseNameUserAuth, "email", true)
+		},
+		Down: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			return mongoDBInterface.RepositoryInterface.DropIndex(ctx, config.UserAuthCollectionName, config.DatabaseNameUserAuth, "email_1")
+		},
+	},
+	{
+		Version: 2,
+		Name:    "pv_plants_unique_public_plant_id",
+		Up: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			return mongoDBInterface.RepositoryInterface.CreateUniqueIndex(ctx, config.CollectionNamePhotovoltaicPlant, config.DatabaseNamePlants, "public_plant_id", true)
+		},
+		Down: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			return mongoDBInterface.RepositoryInterface.DropIndex(ctx, config.CollectionNamePhotovoltaicPlant, config.DatabaseNamePlants, "public_plant_id_1")
+		},
+	},
+	{
+		// Loggers are found by key and by url id. Plants have no key until key and secret are created
+		Version: 3,
+		Name:    "plant_logger_config_unique_key_url_id_collection",
+		Up: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			repository := mongoDBInterface.RepositoryInterface
+			if err := repository.CreateUniqueStringIndex(ctx, config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, "key"); err != nil {
+				return err
+			}
+			if err := repository.CreateUniqueIndex(ctx, config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, "url_id", true); err != nil {
+				return err
+			}
+			return repository.CreateUniqueIndex(ctx, config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, "collection_name_logger", true)
+		},
+		Down: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			return dropIndexes(ctx, mongoDBInterface, config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, "key_1", "url_id_1", "collection_name_logger_1")
+		},
+	},
+	{
+		// Devices are found by device id and, when logging with own credentials, by key. Devices without credentials have no key
+		Version: 4,
+		Name:    "plant_device_unique_device_id_key",
+		Up: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			repository := mongoDBInterface.RepositoryInterface
+			if err := repository.CreateUniqueIndex(ctx, config.CollectionNamePlantDevice, config.DatabaseNamePlantDevice, "device_id", true); err != nil {
+				return err
+			}
+			return repository.CreatePartialUniqueIndex(ctx, config.CollectionNamePlantDevice, config.DatabaseNamePlantDevice, "key")
+		},
+		Down: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			return dropIndexes(ctx, mongoDBInterface, config.CollectionNamePlantDevice, config.DatabaseNamePlantDevice, "device_id_1", "key_1")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "files_unique_public_file_id_slug",
+		Up: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			repository := mongoDBInterface.RepositoryInterface
+			if err := repository.CreateUniqueIndex(ctx, config.CollectionNameFiles, config.DatabaseNameFiles, "public_file_id", true); err != nil {
+				return err
+			}
+			return repository.CreateUniqueIndex(ctx, config.CollectionNameFiles, config.DatabaseNameFiles, "slug", true)
+		},
+		Down: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			return dropIndexes(ctx, mongoDBInterface, config.CollectionNameFiles, config.DatabaseNameFiles, "public_file_id_1", "slug_1")
+		},
+	},
+	{
+		// Rollups store sums of deviations from the mean instead of sums of squares and cubes. Without rollup state, the rollup job rolls up all readings again
+		Version: 6,
+		Name:    "plant_rollups_recompute_deviations",
+		Up: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			_, err := mongoDBInterface.RepositoryInterface.DeleteManyMongo(ctx, config.DatabaseNamePlantLogger, bson.M{}, config.CollectionNamePlantRollupState)
+			return err
+		},
+		// Rollups of both kinds are read, nothing to revert
+		Down: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			return nil
+		},
+	},
+	{
+		// Time-series plant logger collections keep sequence numbers and idempotency keys unique by claims in identity collections.
+		// Claims readings stored before claims existed
+		Version: 7,
+		Name:    "plant_identity_claim_stored_readings",
+		Up: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			collections, err := mongoDBInterface.RepositoryInterface.ListCollections(ctx, config.DatabaseNamePlantLogger, bson.M{"name": bson.M{"$regex": "^plant_logger_[0-9]+$"}, "type": "timeseries"})
+			if err != nil {
+				return err
+			}
+			for _, collection := range collections {
+				if _, err := loggerhandler.ClaimStoredReadingIdentities(ctx, mongoDBInterface, collection.Name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		// Claims are ignored by earlier versions
+		Down: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			return nil
+		},
+	},
+	{
+		// Plant logger collections created before time-series collections were introduced are converted, see MigrateLoggerCollections.
+		// Readings written by other server instances while converting are lost, so it's never applied at startup. Run it by command line with
+		// all server instances stopped. Failed collections are restored and converted again by the next run
+		Version: VersionTimeSeries,
+		Name:    "plant_logger_time_series",
+		Manual:  true,
+		Up: func(ctx context.Context, mongoDBInterface *mongodb.MethodInterface) error {
+			migrations, err := loggerhandler.MigrateLoggerCollections(ctx, mongoDBInterface)
+			if err != nil {
+				return err
+			}
+			failed := []error{}
+			for _, migration := range migrations {
+				if migration.Err != nil {
+					failed = append(failed, fmt.Errorf("Collection '%s': %w", migration.CollectionName, migration.Err))
+					continue
+				}
+				logger.GetLogger().Infof("Plant logger collection '%s' converted to time-series collection: %d readings converted, granularity '%s', %d readings without measurement time kept in '%s_premigration'", migration.CollectionName, migration.Converted, migration.Granularity, migration.Skipped, migration.CollectionName)
+			}
+			return errors.Join(failed...)
+		},
+		// Converting time-series collections back isn't supported, so reverting fails
+		Down: nil,
+	},
+}
+
+// VersionTimeSeries is the version of the migration converting plant logger collections into time-series collections
+const VersionTimeSeries = 8
+
+// dropIndexes drops indexes by name. Missing indexes are skipped
+func dropIndexes(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionName, databaseName string, indexNames ...string) error {
+	for _, indexName := range indexNames {
+		if err := mongoDBInterface.RepositoryInterface.DropIndex(ctx, collectionName, databaseName, indexName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package mqttbridge
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	env "github.com/paulmuenzner/powerplantmanager/utils/env"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MessageHandler processes the payload of a message published to the topic of plant publicPlantID
+// Rejected messages are returned as *loggerhandler.ReadingRejection, any other error is internal.
+type MessageHandler func(publicPlantID string, payload []byte, receivedAt time.Time) error
+
+// Options of the connection to the MQTT broker
+type Options struct {
+	BrokerURL    string
+	TopicPattern string
+	ClientID     string
+	Username     string
+	Password     string
+}
+
+// OptionsFromEnv reads the bridge options from the .env variables. False if no broker url is configured, the bridge is disabled then.
+func OptionsFromEnv() (Options, bool) {
+	brokerURL, err := env.GetEnvValue(config.MqttBrokerURLEnv, "")
+	if err != nil || brokerURL == "" {
+		return Options{}, false
+	}
+	// Optional values, defaults are used if missing
+	topicPattern, _ := env.GetEnvValue(config.MqttTopicPatternEnv, config.MqttTopicPatternDefault)
+	clientID, _ := env.GetEnvValue(config.MqttClientIDEnv, config.MqttClientIDDefault)
+	username, _ := env.GetEnvValue(config.MqttUsernameEnv, "")
+	password, _ := env.GetEnvValue(config.MqttPasswordEnv, "")
+	return Options{BrokerURL: brokerURL, TopicPattern: topicPattern, ClientID: clientID, Username: username, Password: password}, true
+}
+
+// Bridge subscribes to the telemetry topic of all plants at the MQTT broker and passes each message to its handler
+type Bridge struct {
+	client     mqtt.Client
+	pattern    TopicPattern
+	handler    MessageHandler
+	subscribed chan struct{}
+	once       sync.Once
+}
+
+// NewBridge creates a bridge with options. Call Start to connect.
+func NewBridge(options Options, handler MessageHandler) (*Bridge, error) {
+	pattern, err := ParseTopicPattern(options.TopicPattern)
+	if err != nil {
+		return nil, err
+	}
+	bridge := &Bridge{pattern: pattern, handler: handler, subscribed: make(chan struct{})}
+
+	// Subscribing on each connect restores the subscription after reconnects
+	clientOptions := mqtt.NewClientOptions().
+		AddBroker(options.BrokerURL).
+		SetClientID(options.ClientID).
+		SetUsername(options.Username).
+		SetPassword(options.Password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(time.Duration(config.MqttConnectTimeoutSec) * time.Second).
+		SetKeepAlive(time.Duration(config.MqttKeepAliveSec) * time.Second).
+		SetOnConnectHandler(bridge.subscribe).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			logger.GetLogger().Warnf("Connection to MQTT broker lost in 'Bridge'. Reconnecting. Error: %v", err)
+		})
+	bridge.client = mqtt.NewClient(clientOptions)
+	return bridge, nil
+}
+
+// Start connects to the broker and waits until the telemetry topic is subscribed
+func (bridge *Bridge) Start() error {
+	timeout := time.Duration(config.MqttConnectTimeoutSec) * time.Second
+	token := bridge.client.Connect()
+	if !token.WaitTimeout(timeout) {
+		return fmt.Errorf("Error in 'Start()' connecting to MQTT broker. Timeout after %v", timeout)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("Error in 'Start()' connecting to MQTT broker. Error: %v", err)
+	}
+
+	select {
+	case <-bridge.subscribed:
+		return nil
+	case <-time.After(timeout):
+		bridge.Stop()
+		return fmt.Errorf("Error in 'Start()' subscribing to MQTT topic '%s'. Timeout after %v", bridge.pattern.Subscription(), timeout)
+	}
+}
+
+// Stop disconnects from the broker, waiting for messages in process
+func (bridge *Bridge) Stop() {
+	bridge.client.Disconnect(250)
+}
+
+// subscribe subscribes to the telemetry topic. Called by the client on each (re)connect
+func (bridge *Bridge) subscribe(client mqtt.Client) {
+	subscription := bridge.pattern.Subscription()
+	token := client.Subscribe(subscription, config.MqttQoS, bridge.receive)
+	token.Wait()
+	if err := token.Error(); err != nil {
+		logger.GetLogger().Errorf("Error in 'subscribe()' subscribing to MQTT topic '%s'. Error: %v", subscription, err)
+		return
+	}
+	bridge.once.Do(func() { close(bridge.subscribed) })
+}
+
+// receive passes a message to the handler. There is no one to respond to, so rejections are logged only
+func (bridge *Bridge) receive(_ mqtt.Client, message mqtt.Message) {
+	receivedAt := time.Now()
+	publicPlantID, ok := bridge.pattern.PlantID(message.Topic())
+	if !ok {
+		logger.GetLogger().Warnf("Message on MQTT topic '%s' not matching topic pattern ignored in 'receive()'", message.Topic())
+		return
+	}
+
+	err := bridge.handler(publicPlantID, message.Payload(), receivedAt)
+	var rejection *loggerhandler.ReadingRejection
+	if errors.As(err, &rejection) {
+		logger.GetLogger().Warnf("Message on MQTT topic '%s' rejected in 'receive()'. Reason: %s", message.Topic(), rejection.Reason)
+		return
+	}
+	if err != nil {
+		logger.GetLogger().Errorf("Error in 'receive()' handling message on MQTT topic '%s'. Error: %v", message.Topic(), err)
+	}
+}
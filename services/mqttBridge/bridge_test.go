@@ -0,0 +1,94 @@
+package mqttbridge
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	server "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startBroker starts an embedded MQTT broker on a free local port and returns its url
+func startBroker(t *testing.T) string {
+	broker := server.New(&server.Options{})
+	require.NoError(t, broker.AddHook(new(auth.AllowHook), nil))
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	require.NoError(t, broker.AddListener(tcp))
+	go func() {
+		_ = broker.Serve()
+	}()
+	t.Cleanup(func() { _ = broker.Close() })
+	return "tcp://" + tcp.Address()
+}
+
+type receivedMessage struct {
+	publicPlantID string
+	payload       string
+}
+
+func TestBridgeReceivesTelemetry(t *testing.T) {
+	brokerURL := startBroker(t)
+
+	received := make(chan receivedMessage, 1)
+	handler := func(publicPlantID string, payload []byte, receivedAt time.Time) error {
+		received <- receivedMessage{publicPlantID, string(payload)}
+		return nil
+	}
+	bridge, err := NewBridge(Options{BrokerURL: brokerURL, TopicPattern: "plants/{publicPlantID}/telemetry", ClientID: "bridge"}, handler)
+	require.NoError(t, err)
+	require.NoError(t, bridge.Start())
+	defer bridge.Stop()
+
+	// Publish as a plant logger
+	publisher := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(brokerURL).SetClientID("logger"))
+	token := publisher.Connect()
+	require.True(t, token.WaitTimeout(5*time.Second))
+	require.NoError(t, token.Error())
+	defer publisher.Disconnect(250)
+
+	// Messages of other topics are not passed to the handler
+	publisher.Publish("plants/123456789012345/status", 1, false, `{"online":true}`).Wait()
+	publisher.Publish("plants/123456789012345/telemetry", 1, false, `{"key":"k","secret":"s"}`).Wait()
+
+	select {
+	case message := <-received:
+		assert.Equal(t, receivedMessage{"123456789012345", `{"key":"k","secret":"s"}`}, message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("No message received by bridge")
+	}
+}
+
+func TestNewBridgeInvalidPattern(t *testing.T) {
+	_, err := NewBridge(Options{BrokerURL: "tcp://127.0.0.1:1883", TopicPattern: "plants/+/telemetry"}, nil)
+	assert.Error(t, err)
+}
+
+func TestTelemetryHandlerRejections(t *testing.T) {
+	// Rejected before authentication, hence without database
+	handler := TelemetryHandler(nil)
+
+	testCases := []struct {
+		name    string
+		payload string
+	}{
+		{"NoJSON", "voltage=230"},
+		{"NoObject", "[1,2]"},
+		{"MissingSecret", `{"key":"k","voltage":230}`},
+		{"KeyNoString", `{"key":1,"secret":"s"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := handler("123456789012345", []byte(tc.payload), time.Now())
+			var rejection *loggerhandler.ReadingRejection
+			assert.True(t, errors.As(err, &rejection))
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package mqttbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+)
+
+// TelemetryHandler stores telemetry messages as plant logs. A message carries the same JSON body as a request to the add log route,
+// including key and secret, and passes the same authentication and validation. Signed requests are not supported, as there are no headers.
+func TelemetryHandler(mongoDBInterface *mongodb.MethodInterface) MessageHandler {
+	return func(publicPlantID string, payload []byte, receivedAt time.Time) error {
+		ctx := context.Background() // Messages have no deadline of a client waiting for the response
+		var data map[string]interface{}
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return &loggerhandler.ReadingRejection{Reason: "Message must be a JSON object."}
+		}
+
+		// Define and check key and secret. The secret is never logged
+		key, keyValid := data["key"].(string)
+		secret, secretValid := data["secret"].(string)
+		if !keyValid || !secretValid {
+			return &loggerhandler.ReadingRejection{Reason: "Message must contain key and secret."}
+		}
+
+		plantConfig, device, err := loggerhandler.AuthenticateMQTTLogger(ctx, mongoDBInterface, key, secret, publicPlantID)
+		if isAuthenticationError(err) {
+			return &loggerhandler.ReadingRejection{Reason: fmt.Sprintf("Authentication failed for key %s. Error: %v", key, err)}
+		}
+		if err != nil {
+			return fmt.Errorf("Error in 'TelemetryHandler()' using 'AuthenticateMQTTLogger()' for key %s. Error: %v", key, err)
+		}
+
+		plantLog, isDuplicate, err := loggerhandler.ValidateLogEntry(ctx, mongoDBInterface, plantConfig, device, data, []string{"key", "secret"}, "mqtt", receivedAt)
+		if err != nil {
+			return err
+		}
+		// Retries of already stored readings are acknowledged by the broker anyway
+		if isDuplicate {
+			return nil
+		}
+
+		return loggerhandler.StoreLogEntry(ctx, mongoDBInterface, plantConfig.CollectionNameLogger, plantLog)
+	}
+}
+
+// isAuthenticationError checks if err is a failed authentication, contrary to an internal error
+func isAuthenticationError(err error) bool {
+	return errors.Is(err, loggerhandler.ErrLoggerNotFound) ||
+		errors.Is(err, loggerhandler.ErrPlantIDInvalid) ||
+		errors.Is(err, loggerhandler.ErrSchemeNotAllowed) ||
+		errors.Is(err, loggerhandler.ErrSecretInvalid)
+}
@@ -0,0 +1,69 @@
+package mqttbridge
+
+import (
+	"fmt"
+	"strings"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+)
+
+// TopicPattern is a topic with exactly one level being the public plant id placeholder, eg. 'plants/{publicPlantID}/telemetry'
+type TopicPattern struct {
+	levels     []string
+	plantLevel int
+}
+
+// ParseTopicPattern validates pattern. It must contain the public plant id placeholder as one complete level and no wildcards.
+func ParseTopicPattern(pattern string) (TopicPattern, error) {
+	levels := strings.Split(pattern, "/")
+	plantLevel := -1
+	for i, level := range levels {
+		switch {
+		case level == config.MqttTopicPlantIDPlaceholder:
+			if plantLevel >= 0 {
+				return TopicPattern{}, fmt.Errorf("MQTT topic pattern '%s' must contain placeholder '%s' only once", pattern, config.MqttTopicPlantIDPlaceholder)
+			}
+			plantLevel = i
+		case level == "":
+			return TopicPattern{}, fmt.Errorf("MQTT topic pattern '%s' must not contain empty levels", pattern)
+		case strings.ContainsAny(level, "+#") || strings.Contains(level, config.MqttTopicPlantIDPlaceholder):
+			return TopicPattern{}, fmt.Errorf("MQTT topic pattern '%s' must not contain wildcards or placeholder '%s' within a level", pattern, config.MqttTopicPlantIDPlaceholder)
+		}
+	}
+	if plantLevel < 0 {
+		return TopicPattern{}, fmt.Errorf("MQTT topic pattern '%s' must contain placeholder '%s' as one level", pattern, config.MqttTopicPlantIDPlaceholder)
+	}
+	return TopicPattern{levels: levels, plantLevel: plantLevel}, nil
+}
+
+// Subscription returns the topic filter to subscribe to, the placeholder replaced by a single level wildcard
+func (pattern TopicPattern) Subscription() string {
+	levels := append([]string{}, pattern.levels...)
+	levels[pattern.plantLevel] = "+"
+	return strings.Join(levels, "/")
+}
+
+// Topic returns the topic a logger of plant publicPlantID publishes to
+func (pattern TopicPattern) Topic(publicPlantID string) string {
+	levels := append([]string{}, pattern.levels...)
+	levels[pattern.plantLevel] = publicPlantID
+	return strings.Join(levels, "/")
+}
+
+// PlantID returns the public plant id of topic. False if topic doesn't match the pattern.
+func (pattern TopicPattern) PlantID(topic string) (string, bool) {
+	levels := strings.Split(topic, "/")
+	if len(levels) != len(pattern.levels) {
+		return "", false
+	}
+	for i, level := range levels {
+		if i != pattern.plantLevel && level != pattern.levels[i] {
+			return "", false
+		}
+	}
+	publicPlantID := levels[pattern.plantLevel]
+	if publicPlantID == "" {
+		return "", false
+	}
+	return publicPlantID, true
+}
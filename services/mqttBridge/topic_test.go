@@ -0,0 +1,66 @@
+package mqttbridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTopicPattern(t *testing.T) {
+	testCases := []struct {
+		name         string
+		pattern      string
+		subscription string
+		valid        bool
+	}{
+		{"Default", "plants/{publicPlantID}/telemetry", "plants/+/telemetry", true},
+		{"FirstLevel", "{publicPlantID}/telemetry", "+/telemetry", true},
+		{"LastLevel", "site/a/{publicPlantID}", "site/a/+", true},
+		{"NoPlaceholder", "plants/telemetry", "", false},
+		{"PlaceholderTwice", "plants/{publicPlantID}/{publicPlantID}", "", false},
+		{"PlaceholderWithinLevel", "plants/plant-{publicPlantID}/telemetry", "", false},
+		{"SingleLevelWildcard", "plants/{publicPlantID}/+", "", false},
+		{"MultiLevelWildcard", "plants/{publicPlantID}/#", "", false},
+		{"EmptyLevel", "plants//{publicPlantID}", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pattern, err := ParseTopicPattern(tc.pattern)
+			if !tc.valid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.subscription, pattern.Subscription())
+		})
+	}
+}
+
+func TestTopicPatternPlantID(t *testing.T) {
+	pattern, err := ParseTopicPattern("plants/{publicPlantID}/telemetry")
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		topic         string
+		publicPlantID string
+		matches       bool
+	}{
+		{"Match", "plants/123456789012345/telemetry", "123456789012345", true},
+		{"OtherSuffix", "plants/123456789012345/status", "", false},
+		{"MoreLevels", "plants/123456789012345/telemetry/raw", "", false},
+		{"FewerLevels", "plants/123456789012345", "", false},
+		{"EmptyPlantID", "plants//telemetry", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			publicPlantID, matches := pattern.PlantID(tc.topic)
+			assert.Equal(t, tc.matches, matches)
+			assert.Equal(t, tc.publicPlantID, publicPlantID)
+		})
+	}
+
+	assert.Equal(t, "plants/123456789012345/telemetry", pattern.Topic("123456789012345"))
+}
@@ -0,0 +1,197 @@
+package planthandler
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/services/archive"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	"github.com/paulmuenzner/powerplantmanager/services/rollup"
+	"github.com/paulmuenzner/powerplantmanager/utils/aws"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Kinds of inconsistencies found by Check
+const (
+	PlantWithoutConfig      string = "plant_without_config"      // Plant document without logger config. Repaired by deleting the plant and its devices
+	ConfigWithoutPlant      string = "config_without_plant"      // Logger config without plant document. Repaired by deleting the config, devices and collections
+	MissingLoggerCollection string = "missing_logger_collection" // Plant without logger collection. Repaired by creating it
+	OrphanedCollections     string = "orphaned_collections"      // Collections of a plant logger collection without logger config. Repaired by dropping them and their rollups
+	DeviceWithoutPlant      string = "device_without_plant"      // Device of a plant not existing. Repaired by deleting it
+	OrphanedArchives        string = "orphaned_archives"         // Archive files of a plant logger collection without logger config. Repaired by deleting them from the S3 bucket and their records
+)
+
+// Inconsistency found by Check
+type Inconsistency struct {
+	Kind           string
+	PublicPlantID  string // Empty for orphaned collections
+	CollectionName string // Plant logger collection concerned
+	DeviceID       string // Only set for devices without plant
+	Repaired       bool
+	Err            error // Error of the repair
+}
+
+// Collections of a plant logger collection, with the id of the plant logger collection as second submatch
+var plantCollectionName = regexp.MustCompile(fmt.Sprintf("^plant_(logger|quarantine|audit|identity|%s)_([0-9]+)(_premigration)?$", rollupPrefixes()))
+
+func rollupPrefixes() string {
+	prefixes := []string{}
+	for _, level := range rollup.Levels {
+		prefixes = append(prefixes, "rollup_"+string(level))
+	}
+	return strings.Join(prefixes, "|")
+}
+
+// Check finds plants stored inconsistently across pv_plants, plant_logger_config, the plant logger collections and the archive files in the S3 bucket,
+// eg. by a server crashing while adding or deleting a plant, and repairs them if repair is set. Plants added within ConsistencyGracePeriodSec are skipped,
+// as adding them may still be in process. A failing repair is reported with its error and the remaining inconsistencies are repaired anyway.
+// Archive files are deleted from bucketName of awsInterface. Without awsInterface, their repair fails.
+func Check(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, awsInterface *aws.MethodInterface, bucketName string, repair bool, now time.Time) ([]Inconsistency, error) {
+	var plants []model.PhotovoltaicPlant
+	if err := mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNamePlants, bson.M{}, config.CollectionNamePhotovoltaicPlant, bson.D{}, &plants); err != nil {
+		return nil, fmt.Errorf("Error in 'Check()' using 'FindManyInMongo()' in collection '%s'. Error: %w", config.CollectionNamePhotovoltaicPlant, err)
+	}
+	var plantConfigs []model.PlantLoggerConfig
+	if err := mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNamePlantLoggerConfig, bson.M{}, config.CollectionNamePlantLoggerConfig, bson.D{}, &plantConfigs); err != nil {
+		return nil, fmt.Errorf("Error in 'Check()' using 'FindManyInMongo()' in collection '%s'. Error: %w", config.CollectionNamePlantLoggerConfig, err)
+	}
+	var devices []model.PlantDevice
+	if err := mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNamePlantDevice, bson.M{}, config.CollectionNamePlantDevice, bson.D{}, &devices); err != nil {
+		return nil, fmt.Errorf("Error in 'Check()' using 'FindManyInMongo()' in collection '%s'. Error: %w", config.CollectionNamePlantDevice, err)
+	}
+	collections, err := mongoDBInterface.RepositoryInterface.ListCollections(ctx, config.DatabaseNamePlantLogger, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("Error in 'Check()' using 'ListCollections()' in database '%s'. Error: %w", config.DatabaseNamePlantLogger, err)
+	}
+	var archivedCollections []struct {
+		CollectionNameLogger string `bson:"_id"`
+	}
+	pipeline := mongo.Pipeline{bson.D{{Key: "$group", Value: bson.M{"_id": "$collection_name_logger"}}}, bson.D{{Key: "$sort", Value: bson.M{"_id": 1}}}}
+	if err := mongoDBInterface.RepositoryInterface.AggregateInMongo(ctx, config.DatabaseNamePlantLogger, config.CollectionNamePlantArchive, pipeline, &archivedCollections); err != nil {
+		return nil, fmt.Errorf("Error in 'Check()' using 'AggregateInMongo()' in collection '%s'. Error: %w", config.CollectionNamePlantArchive, err)
+	}
+
+	gracePeriodStart := now.Add(-time.Duration(config.ConsistencyGracePeriodSec) * time.Second)
+	plantIDs := map[string]bool{}
+	for _, plant := range plants {
+		plantIDs[plant.PublicPlantID] = true
+	}
+	configIDs := map[string]bool{}
+	collectionNamesLogger := map[string]bool{}
+	for _, plantConfig := range plantConfigs {
+		configIDs[plantConfig.PublicPlantID] = true
+		collectionNamesLogger[plantConfig.CollectionNameLogger] = true
+	}
+	existingCollections := map[string]bool{}
+	for _, collection := range collections {
+		existingCollections[collection.Name] = true
+	}
+
+	inconsistencies := []Inconsistency{}
+	for _, plant := range plants {
+		if !configIDs[plant.PublicPlantID] && plant.CreatedAt.Before(gracePeriodStart) {
+			inconsistencies = append(inconsistencies, Inconsistency{Kind: PlantWithoutConfig, PublicPlantID: plant.PublicPlantID})
+		}
+	}
+	for _, plantConfig := range plantConfigs {
+		if plantConfig.CreatedAt.After(gracePeriodStart) {
+			continue
+		}
+		inconsistency := Inconsistency{PublicPlantID: plantConfig.PublicPlantID, CollectionName: plantConfig.CollectionNameLogger}
+		switch {
+		case !plantIDs[plantConfig.PublicPlantID]:
+			inconsistency.Kind = ConfigWithoutPlant
+		case !existingCollections[plantConfig.CollectionNameLogger]:
+			inconsistency.Kind = MissingLoggerCollection
+		default:
+			continue
+		}
+		inconsistencies = append(inconsistencies, inconsistency)
+	}
+	orphaned := map[string]bool{}
+	for _, collection := range collections {
+		match := plantCollectionName.FindStringSubmatch(collection.Name)
+		if match == nil {
+			continue
+		}
+		collectionNameLogger := "plant_logger_" + match[2]
+		if !collectionNamesLogger[collectionNameLogger] && !orphaned[collectionNameLogger] {
+			orphaned[collectionNameLogger] = true
+			inconsistencies = append(inconsistencies, Inconsistency{Kind: OrphanedCollections, CollectionName: collectionNameLogger})
+		}
+	}
+	for _, archived := range archivedCollections {
+		if !collectionNamesLogger[archived.CollectionNameLogger] {
+			inconsistencies = append(inconsistencies, Inconsistency{Kind: OrphanedArchives, CollectionName: archived.CollectionNameLogger})
+		}
+	}
+	for _, device := range devices {
+		if !plantIDs[device.PublicPlantID] && !configIDs[device.PublicPlantID] {
+			inconsistencies = append(inconsistencies, Inconsistency{Kind: DeviceWithoutPlant, PublicPlantID: device.PublicPlantID, DeviceID: device.DeviceID})
+		}
+	}
+	sort.SliceStable(inconsistencies, func(i, j int) bool { return inconsistencies[i].Kind < inconsistencies[j].Kind })
+
+	if !repair {
+		return inconsistencies, nil
+	}
+	for i := range inconsistencies {
+		inconsistencies[i].Err = repairInconsistency(ctx, mongoDBInterface, awsInterface, bucketName, inconsistencies[i], plantConfigs)
+		inconsistencies[i].Repaired = inconsistencies[i].Err == nil
+	}
+	return inconsistencies, nil
+}
+
+// repairInconsistency repairs one inconsistency found by Check
+func repairInconsistency(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, awsInterface *aws.MethodInterface, bucketName string, inconsistency Inconsistency, plantConfigs []model.PlantLoggerConfig) error {
+	switch inconsistency.Kind {
+	case PlantWithoutConfig, ConfigWithoutPlant:
+		err := mongoDBInterface.RepositoryInterface.RunTransaction(ctx, func(ctx context.Context, unitOfWork mongodb.Repository) error {
+			return DeleteDocuments(ctx, &mongodb.MethodInterface{RepositoryInterface: unitOfWork}, inconsistency.PublicPlantID)
+		})
+		if err != nil {
+			return err
+		}
+		loggerhandler.InvalidateLoggerConfig(inconsistency.PublicPlantID)
+		if inconsistency.Kind == ConfigWithoutPlant {
+			if err := DeleteCollections(ctx, mongoDBInterface, inconsistency.CollectionName); err != nil {
+				return err
+			}
+			return deleteArchives(ctx, mongoDBInterface, awsInterface, bucketName, inconsistency.CollectionName)
+		}
+		return nil
+	case MissingLoggerCollection:
+		for _, plantConfig := range plantConfigs {
+			if plantConfig.CollectionNameLogger == inconsistency.CollectionName {
+				return CreateCollections(ctx, mongoDBInterface, plantConfig.CollectionNameLogger, plantConfig.IntervalSec)
+			}
+		}
+		return nil
+	case OrphanedCollections:
+		return DeleteCollections(ctx, mongoDBInterface, inconsistency.CollectionName)
+	case OrphanedArchives:
+		return deleteArchives(ctx, mongoDBInterface, awsInterface, bucketName, inconsistency.CollectionName)
+	case DeviceWithoutPlant:
+		_, err := mongoDBInterface.RepositoryInterface.DeleteManyMongo(ctx, config.DatabaseNamePlantDevice, bson.M{"public_plant_id": inconsistency.PublicPlantID, "device_id": inconsistency.DeviceID}, config.CollectionNamePlantDevice)
+		return err
+	}
+	return fmt.Errorf("Unknown inconsistency '%s' in 'repairInconsistency()'", inconsistency.Kind)
+}
+
+// deleteArchives deletes the archive files of the plant logger collection collectionNameLogger, see archive.DeleteArchives
+func deleteArchives(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, awsInterface *aws.MethodInterface, bucketName, collectionNameLogger string) error {
+	if awsInterface == nil {
+		return fmt.Errorf("Error in 'deleteArchives()'. S3 bucket isn't configured, cannot delete archive files of collection '%s'", collectionNameLogger)
+	}
+	_, err := archive.DeleteArchives(ctx, mongoDBInterface, awsInterface, bucketName, collectionNameLogger)
+	return err
+}
@@ -0,0 +1,104 @@
+package planthandler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/services/archive"
+	"github.com/paulmuenzner/powerplantmanager/services/rollup"
+	awsMemory "github.com/paulmuenzner/powerplantmanager/utils/aws/memory"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func insertPlant(t *testing.T, mongoDBInterface *mongodb.MethodInterface, publicPlantID, collectionNameLogger string, createdAt time.Time, withPlant, withConfig bool) {
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+	if withPlant {
+		_, err := mongoDBInterface.RepositoryInterface.InsertOneToMongo(ctx, config.DatabaseNamePlants, model.PhotovoltaicPlant{ID: id, PublicPlantID: publicPlantID, CreatedAt: createdAt}, config.CollectionNamePhotovoltaicPlant)
+		assert.NoError(t, err)
+	}
+	if withConfig {
+		plantConfig := model.PlantLoggerConfig{ID: id, PublicPlantID: publicPlantID, IntervalSec: 60, CollectionNameLogger: collectionNameLogger, CreatedAt: createdAt}
+		_, err := mongoDBInterface.RepositoryInterface.InsertOneToMongo(ctx, config.DatabaseNamePlantLoggerConfig, plantConfig, config.CollectionNamePlantLoggerConfig)
+		assert.NoError(t, err)
+	}
+}
+
+func TestCheckAndRepair(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	repository := mongoDBInterface.RepositoryInterface
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-24 * time.Hour)
+
+	// Consistent plant
+	insertPlant(t, mongoDBInterface, "100", "plant_logger_100", past, true, true)
+	assert.NoError(t, CreateCollections(ctx, mongoDBInterface, "plant_logger_100", 60))
+	// Plant being added right now, its collection is still missing
+	insertPlant(t, mongoDBInterface, "101", "plant_logger_101", now, true, true)
+	// Remains of plants added or deleted partially
+	insertPlant(t, mongoDBInterface, "102", "plant_logger_102", past, true, false)
+	insertPlant(t, mongoDBInterface, "103", "plant_logger_103", past, false, true)
+	insertPlant(t, mongoDBInterface, "104", "plant_logger_104", past, true, true)
+	assert.NoError(t, CreateCollections(ctx, mongoDBInterface, "plant_logger_103", 60))
+	assert.NoError(t, repository.CreateNewCollection(ctx, config.DatabaseNamePlantLogger, rollup.CollectionName("plant_logger_105", rollup.LevelDay)))
+	assert.NoError(t, repository.CreateNewCollection(ctx, config.DatabaseNamePlantLogger, "plant_quarantine_105"))
+	_, err := repository.InsertOneToMongo(ctx, config.DatabaseNamePlantDevice, model.PlantDevice{ID: primitive.NewObjectID(), PublicPlantID: "106", DeviceID: "inv-1"}, config.CollectionNamePlantDevice)
+	assert.NoError(t, err)
+	// Archive files of a deleted plant and of a plant partially deleted
+	awsInterface := awsMemory.NewMethodInterface()
+	for _, collectionNameLogger := range []string{"plant_logger_103", "plant_logger_107"} {
+		objectKey := archive.ObjectKey(collectionNameLogger, past, now)
+		assert.NoError(t, awsInterface.RepositoryInterfaceS3.UploadFile(ctx, "bucket", objectKey, []byte("archive")))
+		_, err = repository.InsertOneToMongo(ctx, config.DatabaseNamePlantLogger, model.PlantArchive{ID: primitive.NewObjectID(), CollectionNameLogger: collectionNameLogger, Day: past, ObjectKey: objectKey}, config.CollectionNamePlantArchive)
+		assert.NoError(t, err)
+	}
+	// Shared collections of the database aren't plant collections
+	assert.NoError(t, repository.CreateNewCollection(ctx, config.DatabaseNamePlantLogger, config.CollectionNamePlantRollupState))
+
+	inconsistencies, err := Check(ctx, mongoDBInterface, awsInterface, "bucket", false, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []Inconsistency{
+		{Kind: ConfigWithoutPlant, PublicPlantID: "103", CollectionName: "plant_logger_103"},
+		{Kind: DeviceWithoutPlant, PublicPlantID: "106", DeviceID: "inv-1"},
+		{Kind: MissingLoggerCollection, PublicPlantID: "104", CollectionName: "plant_logger_104"},
+		{Kind: OrphanedArchives, CollectionName: "plant_logger_107"},
+		{Kind: OrphanedCollections, CollectionName: "plant_logger_105"},
+		{Kind: PlantWithoutConfig, PublicPlantID: "102"},
+	}, inconsistencies)
+
+	inconsistencies, err = Check(ctx, mongoDBInterface, awsInterface, "bucket", true, now)
+	assert.NoError(t, err)
+	assert.Len(t, inconsistencies, 6)
+	for _, inconsistency := range inconsistencies {
+		assert.True(t, inconsistency.Repaired, inconsistency.Kind)
+		assert.NoError(t, inconsistency.Err)
+	}
+
+	inconsistencies, err = Check(ctx, mongoDBInterface, awsInterface, "bucket", false, now)
+	assert.NoError(t, err)
+	assert.Empty(t, inconsistencies)
+	collections, err := repository.ListCollections(ctx, config.DatabaseNamePlantLogger, bson.M{})
+	assert.NoError(t, err)
+	names := []string{}
+	for _, collection := range collections {
+		names = append(names, collection.Name)
+	}
+	assert.ElementsMatch(t, []string{"plant_logger_100", "plant_logger_104", config.CollectionNamePlantRollupState, config.CollectionNamePlantArchive}, names)
+
+	for _, collectionNameLogger := range []string{"plant_logger_103", "plant_logger_107"} {
+		exists, err := awsInterface.RepositoryInterfaceS3.S3ObjectExists(ctx, archive.ObjectKey(collectionNameLogger, past, now), "bucket")
+		assert.NoError(t, err)
+		assert.False(t, exists, collectionNameLogger)
+	}
+	archives, err := repository.CountDocumentsInMongo(ctx, config.DatabaseNamePlantLogger, config.CollectionNamePlantArchive, nil)
+	assert.NoError(t, err)
+	assert.Zero(t, archives)
+}
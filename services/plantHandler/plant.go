@@ -0,0 +1,70 @@
+package planthandler
+
+import (
+	"context"
+	"fmt"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	"github.com/paulmuenzner/powerplantmanager/services/rollup"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// A plant is stored in three places: its document in pv_plants, its logger config in plant_logger_config, both sharing the same _id,
+// and its collections in DatabaseNamePlantLogger. Documents are written within transactions. Collections can't be created or dropped
+// within transactions, so they're created after and dropped after the documents. Collections left by a failure are found by Check.
+
+// CollectionNames returns the collections of the plant logger collection collectionNameLogger: the collection itself, the readings kept by
+// its migration to a time-series collection, its quarantine, audit and identity collections. Rollup collections are part of the rollup service, see rollup.CollectionName
+func CollectionNames(collectionNameLogger string) []string {
+	return []string{
+		collectionNameLogger,
+		collectionNameLogger + "_premigration",
+		loggerhandler.QuarantineCollectionName(collectionNameLogger),
+		loggerhandler.AuditCollectionName(collectionNameLogger),
+		loggerhandler.IdentityCollectionName(collectionNameLogger),
+	}
+}
+
+// CreateCollections creates the time-series collection of a new plant logger and its indexes. Its other collections are created on first use
+func CreateCollections(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, intervalSec int) error {
+	if err := loggerhandler.CreateLoggerCollection(ctx, mongoDBInterface, collectionNameLogger, intervalSec); err != nil {
+		return fmt.Errorf("Error in 'CreateCollections()' using 'CreateLoggerCollection()' for collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+	// Indexes of shared collections are created by schema migrations
+	if err := loggerhandler.EnsureIdempotencyIndexes(ctx, mongoDBInterface, collectionNameLogger); err != nil {
+		return fmt.Errorf("Error in 'CreateCollections()' using 'EnsureIdempotencyIndexes()' for collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+	return nil
+}
+
+// DeleteDocuments deletes the plant document, logger config and devices of plant publicPlantID.
+// Pass the unit of work of a transaction, so all or none are deleted
+func DeleteDocuments(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, publicPlantID string) error {
+	filter := bson.M{"public_plant_id": publicPlantID}
+	if _, err := mongoDBInterface.RepositoryInterface.DeleteDocumentMongo(ctx, config.DatabaseNamePlantLoggerConfig, filter, config.CollectionNamePlantLoggerConfig); err != nil {
+		return fmt.Errorf("Error in 'DeleteDocuments()' using 'DeleteDocumentMongo()' in collection '%s'. Error: %w", config.CollectionNamePlantLoggerConfig, err)
+	}
+	if _, err := mongoDBInterface.RepositoryInterface.DeleteDocumentMongo(ctx, config.DatabaseNamePlants, filter, config.CollectionNamePhotovoltaicPlant); err != nil {
+		return fmt.Errorf("Error in 'DeleteDocuments()' using 'DeleteDocumentMongo()' in collection '%s'. Error: %w", config.CollectionNamePhotovoltaicPlant, err)
+	}
+	if _, err := mongoDBInterface.RepositoryInterface.DeleteManyMongo(ctx, config.DatabaseNamePlantDevice, filter, config.CollectionNamePlantDevice); err != nil {
+		return fmt.Errorf("Error in 'DeleteDocuments()' using 'DeleteManyMongo()' in collection '%s'. Error: %w", config.CollectionNamePlantDevice, err)
+	}
+	return nil
+}
+
+// DeleteCollections drops the collections and rollups of the plant logger collection collectionNameLogger, eg. after its plant has been deleted
+func DeleteCollections(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string) error {
+	for _, collectionName := range CollectionNames(collectionNameLogger) {
+		if err := mongoDBInterface.RepositoryInterface.DeleteCollectionMongo(ctx, config.DatabaseNamePlantLogger, collectionName); err != nil {
+			return fmt.Errorf("Error in 'DeleteCollections()' using 'DeleteCollectionMongo()' for collection '%s'. Error: %w", collectionName, err)
+		}
+	}
+	if err := rollup.Delete(ctx, mongoDBInterface, collectionNameLogger); err != nil {
+		return fmt.Errorf("Error in 'DeleteCollections()' using 'Delete()' for collection '%s'. Error: %w", collectionNameLogger, err)
+	}
+	return nil
+}
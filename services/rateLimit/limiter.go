@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// bucket is the token bucket of one client
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter limits requests per client key with token buckets. Each client has a budget of burst requests, refilled with ratePerSec requests per second.
+// Buckets refilled completely are removed, as they equal new ones. So memory only grows with clients active within burst/ratePerSec seconds.
+type Limiter struct {
+	mutex      sync.Mutex
+	ratePerSec float64
+	burst      int
+	buckets    map[string]*bucket
+	swept      time.Time
+}
+
+// Decision is the result of a request passing the limiter
+type Decision struct {
+	Allowed    bool
+	Limit      int           // Budget, ie. burst
+	Remaining  int           // Requests left without waiting
+	Reset      time.Duration // Time until the budget is refilled completely
+	RetryAfter time.Duration // Time until the next request is allowed. Zero if allowed
+}
+
+// NewLimiter creates a limiter with a budget of burst requests per client, refilled with ratePerSec requests per second
+func NewLimiter(ratePerSec float64, burst int) *Limiter {
+	return &Limiter{ratePerSec: ratePerSec, burst: burst, buckets: map[string]*bucket{}}
+}
+
+// Allow takes one request of the budget of key at now
+func (limiter *Limiter) Allow(key string, now time.Time) Decision {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	limiter.sweep(now)
+
+	current, exists := limiter.buckets[key]
+	if !exists {
+		current = &bucket{tokens: float64(limiter.burst), updated: now}
+		limiter.buckets[key] = current
+	}
+	limiter.refill(current, now)
+
+	decision := Decision{Limit: limiter.burst}
+	if current.tokens >= 1 {
+		current.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = limiter.duration(1 - current.tokens)
+	}
+	decision.Remaining = int(math.Floor(current.tokens))
+	decision.Reset = limiter.duration(float64(limiter.burst) - current.tokens)
+	return decision
+}
+
+func (limiter *Limiter) refill(current *bucket, now time.Time) {
+	if elapsed := now.Sub(current.updated).Seconds(); elapsed > 0 {
+		current.tokens = math.Min(float64(limiter.burst), current.tokens+elapsed*limiter.ratePerSec)
+		current.updated = now
+	}
+}
+
+// duration returns the time to refill tokens
+func (limiter *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / limiter.ratePerSec * float64(time.Second)))
+}
+
+// sweep removes refilled buckets, at most once per time to refill a budget
+func (limiter *Limiter) sweep(now time.Time) {
+	refillTime := limiter.duration(float64(limiter.burst))
+	if now.Sub(limiter.swept) < refillTime {
+		return
+	}
+	limiter.swept = now
+	for key, current := range limiter.buckets {
+		if now.Sub(current.updated) >= refillTime {
+			delete(limiter.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterBudget(t *testing.T) {
+	limiter := NewLimiter(2, 3)
+	now := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		decision := limiter.Allow("client", now)
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 3, decision.Limit)
+		assert.Equal(t, remaining, decision.Remaining)
+	}
+	decision := limiter.Allow("client", now)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 500*time.Millisecond, decision.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, decision.Reset)
+
+	// Other clients have own budgets
+	assert.True(t, limiter.Allow("other", now).Allowed)
+
+	// Refilled with 2 requests per second
+	assert.True(t, limiter.Allow("client", now.Add(500*time.Millisecond)).Allowed)
+	assert.False(t, limiter.Allow("client", now.Add(500*time.Millisecond)).Allowed)
+	decision = limiter.Allow("client", now.Add(time.Hour))
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 2, decision.Remaining)
+}
+
+func TestLimiterSweepsRefilledBuckets(t *testing.T) {
+	limiter := NewLimiter(1, 2)
+	now := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	limiter.Allow("a", now)
+	limiter.Allow("b", now.Add(time.Second))
+
+	limiter.Allow("c", now.Add(2*time.Second))
+	assert.NotContains(t, limiter.buckets, "a")
+	assert.Contains(t, limiter.buckets, "b")
+	assert.Contains(t, limiter.buckets, "c")
+}
+
+func TestMiddleware(t *testing.T) {
+	policies := Policies{
+		Auth:      Policy{Name: "auth", Limiter: NewLimiter(1, 1), Key: KeyByIP},
+		Dashboard: Policy{Name: "dashboard", Limiter: NewLimiter(1, 5), Key: KeyByIP},
+		Ingestion: Policy{Name: "ingestion", Limiter: NewLimiter(1, 1), Key: KeyByPlantKey},
+	}
+	handler := Middleware(policies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(path, plantKey string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, path, nil)
+		request.RemoteAddr = "203.0.113.7:51234"
+		if plantKey != "" {
+			request = request.WithContext(context.WithValue(request.Context(), "requestBody", map[string]interface{}{"key": plantKey}))
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	response := send("/auth/signin", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "1", response.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", response.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "1", response.Header().Get("X-RateLimit-Reset"))
+
+	response = send("/auth/signin", "")
+	assert.Equal(t, http.StatusTooManyRequests, response.Code)
+	assert.Equal(t, "1", response.Header().Get("Retry-After"))
+
+	// Separate budget of dashboard routes
+	response = send("/plants/statistics", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "5", response.Header().Get("X-RateLimit-Limit"))
+
+	// Loggers behind the same IP are limited by plant key
+	assert.Equal(t, http.StatusOK, send("/plants/log/970407102018637", "plantKeyA").Code)
+	assert.Equal(t, http.StatusOK, send("/plants/log/970407102018637", "plantKeyB").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("/plants/log/970407102018637/batch", "plantKeyA").Code)
+}
+
+func TestMiddlewareIngestionCeiling(t *testing.T) {
+	ingestion := NewLimiter(1, 1)
+	policies := Policies{
+		Auth:      Policy{Name: "auth", Limiter: NewLimiter(1, 1), Key: KeyByIP},
+		Dashboard: Policy{Name: "dashboard", Limiter: NewLimiter(1, 5), Key: KeyByIP},
+		Ingestion: Policy{Name: "ingestion", Limiter: ingestion, Key: KeyByPlantKey, Ceiling: NewLimiter(1, 3)},
+	}
+	handler := Middleware(policies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(remoteAddr, plantKey string) int {
+		request := httptest.NewRequest(http.MethodPost, "/plants/log/970407102018637", nil)
+		request.RemoteAddr = remoteAddr
+		request = request.WithContext(context.WithValue(request.Context(), "requestBody", map[string]interface{}{"key": plantKey}))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	// Rotating keys doesn't lift the ceiling of the IP
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, send("203.0.113.7:51234", fmt.Sprintf("rotatedKey%d", i)))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.7:51234", "rotatedKey3"))
+	assert.Equal(t, 3, len(ingestion.buckets), "no bucket for requests beyond the ceiling")
+
+	// Other IPs have own ceilings
+	assert.Equal(t, http.StatusOK, send("198.51.100.1:40000", "plantKeyA"))
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"expvar"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	ip "github.com/paulmuenzner/powerplantmanager/utils/ip"
+
+	"github.com/gorilla/mux"
+)
+
+// Policy is a budget of requests per client of a group of routes
+type Policy struct {
+	Name    string
+	Limiter *Limiter
+	Key     func(r *http.Request) string // Client of a request
+	Ceiling *Limiter                     // Optional budget per IP, checked before the budget per client. Nil if clients are IPs
+}
+
+// Policies holds the policies of auth routes, logging routes (ingestion) and all other routes (dashboard)
+type Policies struct {
+	Auth      Policy
+	Dashboard Policy
+	Ingestion Policy
+}
+
+// Rejected requests by policy, published as expvar 'rateLimit'
+var rejections = expvar.NewMap("rateLimit")
+
+// NewPolicies creates the policies configured in config. Auth and dashboard routes are limited by IP, logging routes by plant key,
+// so loggers sharing an IP (eg. carrier NAT) don't share a budget. As plant keys are not verified yet, logging routes are additionally limited by a
+// larger budget per IP. Otherwise a client rotating keys would get unlimited requests and grow the buckets of the limiter without bound.
+func NewPolicies() Policies {
+	return Policies{
+		Auth:      Policy{Name: "auth", Limiter: NewLimiter(config.RateLimitAuthPerSec, config.RateLimitAuthBurst), Key: KeyByIP},
+		Dashboard: Policy{Name: "dashboard", Limiter: NewLimiter(config.RateLimitDashboardPerSec, config.RateLimitDashboardBurst), Key: KeyByIP},
+		Ingestion: Policy{Name: "ingestion", Limiter: NewLimiter(config.RateLimitIngestionPerSec, config.RateLimitIngestionBurst), Key: KeyByPlantKey,
+			Ceiling: NewLimiter(config.RateLimitIngestionPerIPPerSec, config.RateLimitIngestionPerIPBurst)},
+	}
+}
+
+// For returns the policy of a request by its path
+func (policies Policies) For(r *http.Request) Policy {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/auth/"):
+		return policies.Auth
+	case strings.HasPrefix(r.URL.Path, "/plants/log/"):
+		return policies.Ingestion
+	}
+	return policies.Dashboard
+}
+
+// Middleware limits requests by the policy of their route. Responses carry the headers X-RateLimit-Limit (budget), X-RateLimit-Remaining
+// and X-RateLimit-Reset (seconds until the budget is refilled). Rejected requests are answered with 429 and Retry-After.
+// Must run after BodyRequestParser, as legacy loggers send their plant key in the request body.
+func Middleware(policies Policies) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy := policies.For(r)
+			now := time.Now()
+			decision := Decision{Allowed: true}
+			if policy.Ceiling != nil {
+				decision = policy.Ceiling.Allow(KeyByIP(r), now)
+			}
+			// Requests beyond the ceiling don't create buckets per client
+			if decision.Allowed {
+				decision = policy.Limiter.Allow(policy.Key(r), now)
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(seconds(decision.Reset)))
+			if !decision.Allowed {
+				rejections.Add(policy.Name, 1)
+				w.Header().Set("Retry-After", strconv.Itoa(max(seconds(decision.RetryAfter), 1)))
+				errHandler.HandleError(w, "Too many requests. Please retry later.", errHandler.TooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func seconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
+
+// KeyByIP identifies clients by IP, as set by ip.RealIP
+func KeyByIP(r *http.Request) string {
+	clientIP, err := ip.ExtractIP(r)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return clientIP
+}
+
+// KeyByPlantKey identifies loggers by plant or device key, provided by header (signed requests) or request body. Falls back to the IP without key.
+func KeyByPlantKey(r *http.Request) string {
+	key := r.Header.Get(loggerhandler.HeaderPlantKey)
+	if key == "" {
+		data, _ := r.Context().Value("requestBody").(map[string]interface{})
+		key, _ = data["key"].(string)
+	}
+	if key == "" || len(key) > config.RateLimitKeyMaxLength {
+		return "ip:" + KeyByIP(r)
+	}
+	return "key:" + key
+}
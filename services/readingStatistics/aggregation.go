@@ -0,0 +1,369 @@
+package readingstatistics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"github.com/paulmuenzner/powerplantmanager/utils/statistic"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Aggregate of the values of one channel, of one device if grouped by device
+type channelAggregate struct {
+	ID struct {
+		DeviceID string `bson:"device_id"`
+		Channel  string `bson:"k"`
+	} `bson:"_id"`
+	Count     float64   `bson:"count"`
+	Min       float64   `bson:"min"`
+	Max       float64   `bson:"max"`
+	Sum       float64   `bson:"sum"`
+	Quantiles []float64 `bson:"quantiles"` // At quantiles
+}
+
+// Sums of the deviations of the values of a channel aggregate from its mean
+type deviationSums struct {
+	Sum        float64 `bson:"sum"`
+	SumSquares float64 `bson:"sum_squares"`
+	SumCubes   float64 `bson:"sum_cubes"`
+}
+
+// Result of the pipeline of aggregate, one document of facets
+type facets struct {
+	Channels    []channelAggregate `bson:"channels"`
+	Devices     []channelAggregate `bson:"devices"`
+	DeviceIDs   []deviceGroup      `bson:"device_ids"` // Devices with readings, whether they provide the channels analyzed or not
+	Correlation []correlationGroup `bson:"correlation"`
+}
+
+// Count and sums of pairs of power output (x) and solar radiation (y) of a device
+type correlationGroup struct {
+	Device deviceGroup `bson:",inline"`
+	Count  float64     `bson:"count"`
+	SumX   float64     `bson:"sum_x"`
+	SumY   float64     `bson:"sum_y"`
+}
+
+// Sums of the deviations of pairs of a correlation group from their means
+type coDeviationSums struct {
+	SumX        float64 `bson:"sum_x"`
+	SumY        float64 `bson:"sum_y"`
+	SumSquaresX float64 `bson:"sum_squares_x"`
+	SumSquaresY float64 `bson:"sum_squares_y"`
+	SumProducts float64 `bson:"sum_products"`
+}
+
+type deviceGroup struct {
+	ID struct {
+		DeviceID string `bson:"device_id"`
+	} `bson:"_id"`
+}
+
+// aggregate computes the statistics of query by aggregation pipelines: one grouping the values of each channel ($group, $percentile),
+// one summing their deviations from the mean and finding the outliers ($bucket) and one grouping the measurement times
+func aggregate(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, query Query) (Result, error) {
+	facet := bson.M{"channels": valueStages(query.Channels, bson.M{"k": "$values.k"})}
+	if query.GroupByDevice {
+		facet["devices"] = valueStages(query.Channels, bson.M{"device_id": "$device_id", "k": "$values.k"})
+		facet["device_ids"] = bson.A{bson.M{"$group": bson.M{"_id": bson.M{"device_id": "$device_id"}}}}
+	}
+	if correlationChannels(query.Channels) {
+		facet["correlation"] = bson.A{
+			bson.M{"$match": bson.M{"power_output": bson.M{"$exists": true}, "solar_radiation": bson.M{"$exists": true}}},
+			bson.M{"$group": bson.M{
+				"_id":   bson.M{"device_id": "$device_id"},
+				"count": bson.M{"$sum": 1},
+				"sum_x": bson.M{"$sum": "$power_output"},
+				"sum_y": bson.M{"$sum": "$solar_radiation"},
+			}},
+		}
+	}
+	var results []facets
+	pipeline := append(readingStages(query), bson.D{{Key: "$facet", Value: facet}})
+	if err := mongoDBInterface.RepositoryInterface.AggregateInMongo(ctx, config.DatabaseNamePlantLogger, query.CollectionNameLogger, pipeline, &results); err != nil {
+		return Result{}, fmt.Errorf("Error in 'aggregate()' using 'AggregateInMongo()' in collection '%s'. Error: %w", query.CollectionNameLogger, err)
+	}
+	if len(results) == 0 {
+		results = []facets{{}}
+	}
+	result := results[0]
+
+	groups := append(slices.Clone(result.Channels), result.Devices...)
+	moments, outliers, err := findDeviations(ctx, mongoDBInterface, query, groups, len(result.Channels))
+	if err != nil {
+		return Result{}, err
+	}
+
+	data := channelsData(query.Channels, groups[:len(result.Channels)], moments[:len(result.Channels)], outliers[:len(result.Channels)])
+	correlationByDevice := map[string]statistic.CoMoments{}
+	if correlationChannels(query.Channels) {
+		correlationByDevice, err = findCoMoments(ctx, mongoDBInterface, query, result.Correlation)
+		if err != nil {
+			return Result{}, err
+		}
+		var coMoments statistic.CoMoments
+		for _, group := range result.Correlation {
+			coMoments.Add(correlationByDevice[group.Device.ID.DeviceID])
+		}
+		data["correlationPowerSolar"] = coMoments.Correlation()
+	}
+	if slices.Contains(query.Channels, "powerOutput") {
+		sumPowerOutput := 0.0
+		for _, group := range result.Channels {
+			if group.ID.Channel == "powerOutput" {
+				sumPowerOutput = group.Sum
+			}
+		}
+		data["energyWh"] = energyWh(sumPowerOutput, query.IntervalSec)
+	}
+
+	if query.GroupByDevice {
+		devices := map[string]interface{}{}
+		for _, device := range result.DeviceIDs {
+			deviceGroups, deviceMoments, deviceOutliers := []channelAggregate{}, []statistic.Moments{}, [][]float64{}
+			for index := len(result.Channels); index < len(groups); index++ {
+				if groups[index].ID.DeviceID == device.ID.DeviceID {
+					deviceGroups = append(deviceGroups, groups[index])
+					deviceMoments = append(deviceMoments, moments[index])
+					deviceOutliers = append(deviceOutliers, outliers[index])
+				}
+			}
+			deviceData := channelsData(query.Channels, deviceGroups, deviceMoments, deviceOutliers)
+			if correlationChannels(query.Channels) {
+				deviceData["correlationPowerSolar"] = correlationByDevice[device.ID.DeviceID].Correlation()
+			}
+			deviceID := device.ID.DeviceID
+			if deviceID == "" {
+				deviceID = "unassigned"
+			}
+			devices[deviceID] = deviceData
+		}
+		data["devices"] = devices
+	}
+
+	measuredAt, err := findMeasurementTimes(ctx, mongoDBInterface, query)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Data: data, MeasuredAt: measuredAt}, nil
+}
+
+// readingStages returns the stages of the readings of query, shaped into their device (empty if reported for the plant as a whole), 'values' of
+// {k: channel, v: value} and 'power_output' and 'solar_radiation' if provided. Readings stored before channel schemas existed have the values of their legacy fields
+func readingStages(query Query) mongo.Pipeline {
+	legacyValues := bson.M{}
+	for channel, field := range loggerhandler.LegacyFields {
+		legacyValues[channel] = bson.M{"$ifNull": bson.A{"$" + field, 0}}
+	}
+	return mongo.Pipeline{
+		{{Key: "$match", Value: query.Filter}},
+		{{Key: "$project", Value: bson.M{
+			"_id":       0,
+			"device_id": bson.M{"$ifNull": bson.A{"$device_id", ""}},
+			"channels":  bson.M{"$ifNull": bson.A{"$values", legacyValues}},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"device_id":       1,
+			"power_output":    "$channels.powerOutput",
+			"solar_radiation": "$channels.solarRadiation",
+			"values":          bson.M{"$objectToArray": "$channels"},
+		}}},
+	}
+}
+
+// valueStages returns the stages grouping the values of channels by id into channel aggregates
+func valueStages(channels []string, id bson.M) bson.A {
+	return bson.A{
+		bson.M{"$unwind": "$values"},
+		bson.M{"$match": bson.M{"values.k": bson.M{"$in": channels}}},
+		bson.M{"$group": bson.M{
+			"_id":       id,
+			"count":     bson.M{"$sum": 1},
+			"min":       bson.M{"$min": "$values.v"},
+			"max":       bson.M{"$max": "$values.v"},
+			"sum":       bson.M{"$sum": "$values.v"},
+			"quantiles": bson.M{"$percentile": bson.M{"input": "$values.v", "p": quantilesArray(), "method": "approximate"}},
+		}},
+	}
+}
+
+func quantilesArray() bson.A {
+	p := bson.A{}
+	for _, quantile := range quantiles {
+		p = append(p, quantile)
+	}
+	return p
+}
+
+// channelsData returns the key figures of each of channels from its aggregate among groups, with moments and outliers of the same index
+func channelsData(channels []string, groups []channelAggregate, moments []statistic.Moments, outliers [][]float64) map[string]interface{} {
+	data := map[string]interface{}{}
+	for _, channel := range channels {
+		data[channel] = noValues()
+		for index, group := range groups {
+			if group.ID.Channel != channel || group.Count == 0 || len(group.Quantiles) != len(quantiles) {
+				continue
+			}
+			data[channel] = keyFigures(moments[index], group.Min, group.Max, group.Quantiles, outliers[index])
+		}
+	}
+	return data
+}
+
+// findDeviations returns the moments and the outliers, sorted, of each of groups. The first plantGroups groups are of the plant, the others of devices.
+// Variance and skewness are computed from the deviations of the values from the mean of their group, as sums of squares and cubes of the values cancel out.
+// Values of a group are grouped into the range between its bounds and the default bucket, the outliers. Groups without values beyond their bounds are skipped
+func findDeviations(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, query Query, groups []channelAggregate, plantGroups int) ([]statistic.Moments, [][]float64, error) {
+	moments := make([]statistic.Moments, len(groups))
+	outliers := make([][]float64, len(groups))
+	facet := bson.M{}
+	for index, group := range groups {
+		outliers[index] = []float64{}
+		if group.Count == 0 || len(group.Quantiles) != len(quantiles) {
+			continue
+		}
+		match := bson.M{"values.k": group.ID.Channel}
+		if index >= plantGroups {
+			match["device_id"] = group.ID.DeviceID
+		}
+		deviation := bson.M{"$subtract": bson.A{"$values.v", group.Sum / group.Count}}
+		facet[fmt.Sprintf("moments_%d", index)] = bson.A{
+			bson.M{"$unwind": "$values"},
+			bson.M{"$match": match},
+			bson.M{"$group": bson.M{
+				"_id":         nil,
+				"sum":         bson.M{"$sum": deviation},
+				"sum_squares": bson.M{"$sum": bson.M{"$multiply": bson.A{deviation, deviation}}},
+				"sum_cubes":   bson.M{"$sum": bson.M{"$multiply": bson.A{deviation, deviation, deviation}}},
+			}},
+		}
+
+		_, lowerBound, upperBound := bounds(group.Quantiles[0], group.Quantiles[2])
+		if group.Min >= lowerBound && group.Max <= upperBound {
+			continue
+		}
+		facet[fmt.Sprintf("outliers_%d", index)] = bson.A{
+			bson.M{"$unwind": "$values"},
+			bson.M{"$match": match},
+			// Upper boundaries are exclusive, the upper bound isn't an outlier
+			bson.M{"$bucket": bson.M{
+				"groupBy":    "$values.v",
+				"boundaries": bson.A{lowerBound, math.Nextafter(upperBound, math.Inf(1))},
+				"default":    "outliers",
+				"output":     bson.M{"values": bson.M{"$push": "$values.v"}},
+			}},
+			bson.M{"$match": bson.M{"_id": "outliers"}},
+		}
+	}
+	if len(facet) == 0 {
+		return moments, outliers, nil
+	}
+
+	var results []map[string][]struct {
+		Deviations deviationSums `bson:",inline"`
+		Values     []float64     `bson:"values"`
+	}
+	pipeline := append(readingStages(query), bson.D{{Key: "$facet", Value: facet}})
+	if err := mongoDBInterface.RepositoryInterface.AggregateInMongo(ctx, config.DatabaseNamePlantLogger, query.CollectionNameLogger, pipeline, &results); err != nil {
+		return nil, nil, fmt.Errorf("Error in 'findDeviations()' using 'AggregateInMongo()' in collection '%s'. Error: %w", query.CollectionNameLogger, err)
+	}
+	if len(results) == 0 {
+		return moments, outliers, nil
+	}
+	for index, group := range groups {
+		if sums := results[0][fmt.Sprintf("moments_%d", index)]; len(sums) > 0 {
+			moments[index] = statistic.CenteredMoments(group.Count, group.Sum/group.Count, sums[0].Deviations.Sum, sums[0].Deviations.SumSquares, sums[0].Deviations.SumCubes)
+		}
+		if buckets := results[0][fmt.Sprintf("outliers_%d", index)]; len(buckets) > 0 {
+			outliers[index] = buckets[0].Values
+			slices.Sort(outliers[index])
+		}
+	}
+	return moments, outliers, nil
+}
+
+// findCoMoments returns the co-moments of power output and solar radiation of each device of groups, by device id.
+// They're computed from the deviations of the pairs from the means of their group, as sums of products of the values cancel out, see findDeviations
+func findCoMoments(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, query Query, groups []correlationGroup) (map[string]statistic.CoMoments, error) {
+	coMoments := map[string]statistic.CoMoments{}
+	facet := bson.M{}
+	for index, group := range groups {
+		if group.Count == 0 {
+			continue
+		}
+		deviationX := bson.M{"$subtract": bson.A{"$power_output", group.SumX / group.Count}}
+		deviationY := bson.M{"$subtract": bson.A{"$solar_radiation", group.SumY / group.Count}}
+		facet[fmt.Sprintf("co_moments_%d", index)] = bson.A{
+			bson.M{"$match": bson.M{"device_id": group.Device.ID.DeviceID, "power_output": bson.M{"$exists": true}, "solar_radiation": bson.M{"$exists": true}}},
+			bson.M{"$group": bson.M{
+				"_id":           nil,
+				"sum_x":         bson.M{"$sum": deviationX},
+				"sum_y":         bson.M{"$sum": deviationY},
+				"sum_squares_x": bson.M{"$sum": bson.M{"$multiply": bson.A{deviationX, deviationX}}},
+				"sum_squares_y": bson.M{"$sum": bson.M{"$multiply": bson.A{deviationY, deviationY}}},
+				"sum_products":  bson.M{"$sum": bson.M{"$multiply": bson.A{deviationX, deviationY}}},
+			}},
+		}
+	}
+	if len(facet) == 0 {
+		return coMoments, nil
+	}
+
+	var results []map[string][]coDeviationSums
+	pipeline := append(readingStages(query), bson.D{{Key: "$facet", Value: facet}})
+	if err := mongoDBInterface.RepositoryInterface.AggregateInMongo(ctx, config.DatabaseNamePlantLogger, query.CollectionNameLogger, pipeline, &results); err != nil {
+		return nil, fmt.Errorf("Error in 'findCoMoments()' using 'AggregateInMongo()' in collection '%s'. Error: %w", query.CollectionNameLogger, err)
+	}
+	if len(results) == 0 {
+		return coMoments, nil
+	}
+	for index, group := range groups {
+		if sums := results[0][fmt.Sprintf("co_moments_%d", index)]; len(sums) > 0 {
+			coMoments[group.Device.ID.DeviceID] = statistic.CenteredCoMoments(group.Count, group.SumX/group.Count, group.SumY/group.Count,
+				sums[0].SumX, sums[0].SumY, sums[0].SumSquaresX, sums[0].SumSquaresY, sums[0].SumProducts)
+		}
+	}
+	return coMoments, nil
+}
+
+// findMeasurementTimes returns the measurement times of the readings of query, grouped, so times measured by several devices are returned once.
+// The times are held in memory, ErrTooManyReadings is returned beyond GapAnalysisMaxReadings
+func findMeasurementTimes(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, query Query) ([]time.Time, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: query.Filter}},
+		{{Key: "$group", Value: bson.M{"_id": "$measured_at"}}},
+		{{Key: "$match", Value: bson.M{"_id": bson.M{"$ne": nil}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+	cursor, err := mongoDBInterface.RepositoryInterface.AggregateCursorInMongo(ctx, config.DatabaseNamePlantLogger, query.CollectionNameLogger, pipeline, config.DatabaseCursorBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("Error in 'findMeasurementTimes()' using 'AggregateCursorInMongo()' in collection '%s'. Error: %w", query.CollectionNameLogger, err)
+	}
+	defer cursor.Close(ctx)
+
+	measuredAt := []time.Time{}
+	for cursor.Next(ctx) {
+		var group struct {
+			MeasuredAt time.Time `bson:"_id"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return nil, fmt.Errorf("Error in 'findMeasurementTimes()' using 'Decode()' in collection '%s'. Error: %v", query.CollectionNameLogger, err)
+		}
+		if len(measuredAt) == config.GapAnalysisMaxReadings {
+			return nil, fmt.Errorf("Error in 'findMeasurementTimes()' in collection '%s', more than %d measurement times. Error: %w", query.CollectionNameLogger, config.GapAnalysisMaxReadings, ErrTooManyReadings)
+		}
+		measuredAt = append(measuredAt, group.MeasuredAt)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("Error in 'findMeasurementTimes()' using 'Next()' in collection '%s'. Error: %w", query.CollectionNameLogger, err)
+	}
+	return measuredAt, nil
+}
@@ -0,0 +1,107 @@
+package readingstatistics
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"github.com/paulmuenzner/powerplantmanager/utils/statistic"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Query of the statistics of readings of one plant logger collection
+type Query struct {
+	CollectionNameLogger string
+	Filter               bson.M // Readings analyzed
+	Channels             []string
+	GroupByDevice        bool // Statistics per device in addition. Readings reported for the plant as a whole are grouped as 'unassigned'
+	IntervalSec          int  // Logging interval, each 'powerOutput' reading covers it
+}
+
+// Result of a query
+type Result struct {
+	// Statistics of each channel, 'correlationPowerSolar' if both, power output and solar radiation, are analyzed, 'energyWh' if power output is analyzed
+	// and 'devices' if grouped by device
+	Data map[string]interface{}
+	// Measurement times of the readings for their completeness, see gap analysis. Times measured by several devices may be repeated
+	MeasuredAt []time.Time
+}
+
+// ErrTooManyReadings is returned if the readings of a query exceed StatisticsStreamMaxReadings, or their measurement times GapAnalysisMaxReadings, as both are held in memory
+var ErrTooManyReadings = errors.New("too many readings in period")
+
+// Set once MongoDB turned out to lack operators of the aggregation, so later statistics are computed in Go right away
+var databaseUnsupported atomic.Bool
+
+// Compute computes the statistics of the readings matching query. If StatisticsInDatabase is set, they're aggregated by MongoDB, which only returns
+// the key figures. Otherwise, or if MongoDB lacks the operators, readings are streamed and the key figures computed in Go.
+// Both return the same key figures, apart from rounding. Quantiles of MongoDB are approximations for large numbers of values.
+func Compute(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, query Query) (Result, error) {
+	if config.StatisticsInDatabase && !databaseUnsupported.Load() {
+		result, err := aggregate(ctx, mongoDBInterface, query)
+		if err == nil || !mongodb.IsUnsupportedPipeline(err) {
+			return result, err
+		}
+		databaseUnsupported.Store(true)
+		logger.GetLogger().Warnf("MongoDB doesn't support the aggregation of statistics, eg. '$percentile' before MongoDB 7.0. Statistics of readings are computed from streamed readings. Error: %v", err)
+	}
+	return stream(ctx, mongoDBInterface, query)
+}
+
+// correlationChannels are the channels of correlationPowerSolar
+func correlationChannels(channels []string) bool {
+	return slices.Contains(channels, "powerOutput") && slices.Contains(channels, "solarRadiation")
+}
+
+// noValues returns the key figures of a channel without values, which cannot be computed and are null
+func noValues() map[string]interface{} {
+	return map[string]interface{}{
+		"mean": nil, "variance": nil, "median": nil, "standardDeviation": nil, "skewness": nil,
+		"quantile25": nil, "quantile75": nil, "quantile90": nil, "quantile95": nil,
+		"interquartileRange": nil, "lowerBound": nil, "upperBound": nil, "outliers": []float64{},
+		"min": nil, "max": nil,
+	}
+}
+
+// Quantiles of the key figures, median included
+var quantiles = []float64{0.25, 0.5, 0.75, 0.9, 0.95}
+
+// keyFigures returns the key figures of a channel from the moments, min and max, quantiles at quantiles and outliers of its values
+func keyFigures(moments statistic.Moments, minimum, maximum float64, values []float64, outliers []float64) map[string]interface{} {
+	quantile25, median, quantile75, quantile90, quantile95 := values[0], values[1], values[2], values[3], values[4]
+	iqr, lowerBound, upperBound := bounds(quantile25, quantile75)
+	return map[string]interface{}{
+		"mean":               moments.Mean,
+		"variance":           moments.Variance(),
+		"median":             median,
+		"standardDeviation":  moments.StandardDeviation(),
+		"skewness":           moments.Skewness(),
+		"quantile25":         quantile25,
+		"quantile75":         quantile75,
+		"quantile90":         quantile90,
+		"quantile95":         quantile95,
+		"interquartileRange": iqr,
+		"lowerBound":         lowerBound,
+		"upperBound":         upperBound,
+		"outliers":           outliers,
+		"min":                minimum,
+		"max":                maximum,
+	}
+}
+
+// bounds returns the interquartile range and the bounds beyond which values are outliers, as Quantile()
+func bounds(quantile25, quantile75 float64) (iqr, lowerBound, upperBound float64) {
+	iqr = quantile75 - quantile25
+	return iqr, quantile25 - 1.5*iqr, quantile75 + 1.5*iqr
+}
+
+// energyWh returns the energy in Wh of 'powerOutput' readings summing up to sumPowerOutput, each covering the logging interval of intervalSec seconds
+func energyWh(sumPowerOutput float64, intervalSec int) float64 {
+	return sumPowerOutput * float64(intervalSec) / 3600
+}
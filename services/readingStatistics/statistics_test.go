@@ -0,0 +1,177 @@
+package readingstatistics
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	gapanalysis "github.com/paulmuenzner/powerplantmanager/services/gapAnalysis"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	"github.com/paulmuenzner/powerplantmanager/utils/statistic"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// assertSameStatistics asserts equal statistics apart from rounding
+func assertSameStatistics(t *testing.T, expected, actual interface{}, path string) {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !assert.True(t, ok, path) {
+			return
+		}
+		assert.Len(t, a, len(e), path)
+		for key := range e {
+			assertSameStatistics(t, e[key], a[key], path+"."+key)
+		}
+	case float64:
+		a, ok := actual.(float64)
+		if !assert.True(t, ok, path) {
+			return
+		}
+		if math.IsNaN(e) {
+			assert.True(t, math.IsNaN(a), path)
+			return
+		}
+		assert.InDelta(t, e, a, 1e-6*max(1, math.Abs(e)), path)
+	default:
+		assert.Equal(t, expected, actual, path)
+	}
+}
+
+func TestAggregateMatchesStream(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	collectionNameLogger := "plant_logger_1"
+
+	readings := []interface{}{}
+	for i := 0; i < 40; i++ {
+		measuredAt := start.Add(time.Duration(i) * time.Minute)
+		values := map[string]float64{"powerOutput": float64(1000 + 10*i), "solarRadiation": float64(400 + i*i%17)}
+		if i == 7 {
+			values["powerOutput"] = 9000 // Outlier
+		}
+		if i%5 == 0 {
+			delete(values, "solarRadiation") // Optional channel
+		}
+		readings = append(readings,
+			model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inv-1", Values: values, MeasuredAt: measuredAt},
+			model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inv-2", Values: map[string]float64{"powerOutput": float64(800 + i%3)}, MeasuredAt: measuredAt},
+		)
+	}
+	// Readings stored before channel schemas existed, reported for the plant as a whole
+	readings = append(readings,
+		bson.M{"power_output": 1500.0, "solar_radiation": 610.0, "measured_at": start.Add(time.Hour)},
+		bson.M{"power_output": 1400.0, "measured_at": start.Add(time.Hour + time.Minute)},
+	)
+	// Device not providing the channels analyzed, and a voided reading
+	readings = append(readings,
+		model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "meter-1", Values: map[string]float64{"acFrequency": 50}, MeasuredAt: start},
+		model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: "inv-1", Values: map[string]float64{"powerOutput": -5000}, MeasuredAt: start, Voided: true},
+	)
+	_, err := mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, readings, collectionNameLogger)
+	assert.NoError(t, err)
+
+	for _, channels := range [][]string{{"powerOutput", "solarRadiation"}, {"solarRadiation", "tAmbient"}} {
+		query := Query{
+			CollectionNameLogger: collectionNameLogger,
+			Filter:               loggerhandler.ExcludeVoided(bson.M{"measured_at": bson.M{"$gte": start, "$lt": start.Add(2 * time.Hour)}}),
+			Channels:             channels,
+			GroupByDevice:        true,
+			IntervalSec:          60,
+		}
+		expected, err := stream(ctx, mongoDBInterface, query)
+		assert.NoError(t, err)
+		actual, err := Compute(ctx, mongoDBInterface, query)
+		assert.NoError(t, err)
+		assert.False(t, databaseUnsupported.Load())
+
+		assertSameStatistics(t, expected.Data, actual.Data, "data")
+		assert.ElementsMatch(t, []string{"inv-1", "inv-2", "meter-1", "unassigned"}, keys(actual.Data["devices"].(map[string]interface{})))
+		// Times measured by several devices are grouped
+		assert.Len(t, actual.MeasuredAt, 42)
+		assert.Len(t, expected.MeasuredAt, 83)
+		assert.Equal(t, gapanalysis.Analyze(expected.MeasuredAt, 60, model.Coordinates{}, start, start.Add(2*time.Hour)), gapanalysis.Analyze(actual.MeasuredAt, 60, model.Coordinates{}, start, start.Add(2*time.Hour)))
+	}
+
+	query := Query{CollectionNameLogger: collectionNameLogger, Filter: bson.M{}, Channels: []string{"powerOutput"}, IntervalSec: 60}
+	result, err := Compute(ctx, mongoDBInterface, query)
+	assert.NoError(t, err)
+	powerOutput := result.Data["powerOutput"].(map[string]interface{})
+	assert.Equal(t, []float64{-5000, 9000}, powerOutput["outliers"])
+	assert.Equal(t, 9000.0, powerOutput["max"])
+	assert.NotContains(t, result.Data, "devices")
+	assert.NotContains(t, result.Data, "correlationPowerSolar")
+}
+
+func TestAggregateLargeOffset(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	collectionNameLogger := "plant_logger_2"
+
+	// Grid voltage like values: large offset, small spread
+	readings := []interface{}{}
+	var moments statistic.Moments
+	for i := 0; i < 30000; i++ {
+		voltage := 230.1 + 0.002*float64(i%3-1)
+		if i == 0 {
+			voltage = 230.1 + 0.01
+		}
+		moments.AddValue(voltage)
+		readings = append(readings, model.PlantLogger{ID: primitive.NewObjectID(), Values: map[string]float64{"voltageOutput": voltage}, MeasuredAt: start.Add(time.Duration(i) * time.Second)})
+	}
+	_, err := mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, readings, collectionNameLogger)
+	assert.NoError(t, err)
+
+	query := Query{CollectionNameLogger: collectionNameLogger, Filter: bson.M{}, Channels: []string{"voltageOutput"}, IntervalSec: 1}
+	result, err := aggregate(ctx, mongoDBInterface, query)
+	assert.NoError(t, err)
+	voltageOutput := result.Data["voltageOutput"].(map[string]interface{})
+	assert.InEpsilon(t, moments.Variance(), voltageOutput["variance"], 1e-9)
+	assert.InEpsilon(t, moments.Skewness(), voltageOutput["skewness"], 1e-6)
+}
+
+func TestAggregateCorrelationLargeOffset(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	collectionNameLogger := "plant_logger_3"
+
+	// Large offsets and small spreads, products of the values cancel out
+	readings := []interface{}{}
+	powerOutputs, solarRadiation := []float64{}, []float64{}
+	for i := 0; i < 30000; i++ {
+		deviceID := "inv-1"
+		if i%2 == 1 {
+			deviceID = "inv-2"
+		}
+		powerOutput := 1e7 + 0.01*float64(i%7)
+		radiation := 1e7 + 0.02*float64(i%7) + 0.01*float64(i%3-1)
+		powerOutputs, solarRadiation = append(powerOutputs, powerOutput), append(solarRadiation, radiation)
+		readings = append(readings, model.PlantLogger{ID: primitive.NewObjectID(), DeviceID: deviceID, Values: map[string]float64{"powerOutput": powerOutput, "solarRadiation": radiation}, MeasuredAt: start.Add(time.Duration(i) * time.Second)})
+	}
+	_, err := mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, readings, collectionNameLogger)
+	assert.NoError(t, err)
+
+	query := Query{CollectionNameLogger: collectionNameLogger, Filter: bson.M{}, Channels: []string{"powerOutput", "solarRadiation"}, IntervalSec: 1}
+	result, err := aggregate(ctx, mongoDBInterface, query)
+	assert.NoError(t, err)
+	expected, err := statistic.Correlation(powerOutputs, solarRadiation, nil)
+	assert.NoError(t, err)
+	assert.InEpsilon(t, expected, result.Data["correlationPowerSolar"], 1e-6)
+}
+
+func keys(data map[string]interface{}) []string {
+	result := []string{}
+	for key := range data {
+		result = append(result, key)
+	}
+	return result
+}
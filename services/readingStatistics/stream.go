@@ -0,0 +1,153 @@
+package readingstatistics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"github.com/paulmuenzner/powerplantmanager/utils/statistic"
+)
+
+// stream computes the statistics of query in Go from readings streamed, only the values of the channels analyzed and the measurement times are kept.
+// Quantiles and outliers need all values, so memory grows with the number of readings, twice if grouped by device.
+// At most StatisticsStreamMaxReadings readings are read, ErrTooManyReadings is returned beyond
+func stream(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, query Query) (Result, error) {
+	findOptions := mongodb.FindOptions{Projection: loggerhandler.ChannelProjection(query.Channels), BatchSize: config.DatabaseCursorBatchSize}
+	cursor, err := mongoDBInterface.RepositoryInterface.FindCursorInMongo(ctx, config.DatabaseNamePlantLogger, query.Filter, query.CollectionNameLogger, findOptions)
+	if err != nil {
+		return Result{}, fmt.Errorf("Error in 'stream()' using 'FindCursorInMongo()' in collection '%s'. Error: %w", query.CollectionNameLogger, err)
+	}
+	defer cursor.Close(ctx)
+
+	readings := newAccumulator(query.Channels)
+	readingsByDevice := map[string]*accumulator{}
+	for cursor.Next(ctx) {
+		var plantLog model.PlantLogger
+		if err := cursor.Decode(&plantLog); err != nil {
+			return Result{}, fmt.Errorf("Error in 'stream()' using 'Decode()' in collection '%s'. Error: %v", query.CollectionNameLogger, err)
+		}
+		if len(readings.measuredAt) == config.StatisticsStreamMaxReadings {
+			return Result{}, fmt.Errorf("Error in 'stream()' in collection '%s', more than %d readings. Error: %w", query.CollectionNameLogger, config.StatisticsStreamMaxReadings, ErrTooManyReadings)
+		}
+		readings.add(plantLog)
+		if query.GroupByDevice {
+			deviceID := plantLog.DeviceID
+			if deviceID == "" {
+				deviceID = "unassigned"
+			}
+			if readingsByDevice[deviceID] == nil {
+				readingsByDevice[deviceID] = newAccumulator(query.Channels)
+			}
+			readingsByDevice[deviceID].add(plantLog)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return Result{}, fmt.Errorf("Error in 'stream()' using 'Next()' in collection '%s'. Error: %w", query.CollectionNameLogger, err)
+	}
+
+	data := readings.statistics()
+	if slices.Contains(query.Channels, "powerOutput") {
+		data["energyWh"] = readings.energyWh(query.IntervalSec)
+	}
+	if query.GroupByDevice {
+		devices := map[string]interface{}{}
+		for deviceID, deviceReadings := range readingsByDevice {
+			devices[deviceID] = deviceReadings.statistics()
+		}
+		data["devices"] = devices
+	}
+	return Result{Data: data, MeasuredAt: readings.measuredAt}, nil
+}
+
+// accumulator collects what the statistics of streamed readings need: the values of each channel analyzed and the measurement times.
+// Readings without value of a channel (optional channel) are skipped for this channel
+type accumulator struct {
+	channels    []string
+	values      map[string][]float64
+	correlation bool // Both, power output and solar radiation, are analyzed
+	// Power output and solar radiation of readings providing both
+	powerOutputs   []float64
+	solarRadiation []float64
+	measuredAt     []time.Time
+}
+
+func newAccumulator(channels []string) *accumulator {
+	return &accumulator{channels: channels, values: map[string][]float64{}, correlation: correlationChannels(channels)}
+}
+
+func (a *accumulator) add(plantLog model.PlantLogger) {
+	for _, channel := range a.channels {
+		if value, exists := loggerhandler.ChannelValue(plantLog, channel); exists {
+			a.values[channel] = append(a.values[channel], value)
+		}
+	}
+	if a.correlation {
+		powerOutput, hasPowerOutput := loggerhandler.ChannelValue(plantLog, "powerOutput")
+		radiation, hasSolarRadiation := loggerhandler.ChannelValue(plantLog, "solarRadiation")
+		if hasPowerOutput && hasSolarRadiation {
+			a.powerOutputs = append(a.powerOutputs, powerOutput)
+			a.solarRadiation = append(a.solarRadiation, radiation)
+		}
+	}
+	a.measuredAt = append(a.measuredAt, plantLog.MeasuredAt)
+}
+
+// statistics computes the statistics of each channel and, if both channels are analyzed, the correlation of power output and solar radiation
+func (a *accumulator) statistics() map[string]interface{} {
+	data := map[string]interface{}{}
+	for _, channel := range a.channels {
+		data[channel] = channelStatistics(a.values[channel])
+	}
+	if a.correlation {
+		correlationPowerSolar, _ := statistic.Correlation(a.powerOutputs, a.solarRadiation, nil)
+		data["correlationPowerSolar"] = correlationPowerSolar
+	}
+	return data
+}
+
+// energyWh returns the energy of the readings in Wh, each 'powerOutput' reading covering the logging interval of intervalSec seconds
+func (a *accumulator) energyWh(intervalSec int) float64 {
+	energy := 0.0
+	for _, powerOutput := range a.values["powerOutput"] {
+		energy += powerOutput * float64(intervalSec) / 3600
+	}
+	return energy
+}
+
+// channelStatistics computes the statistical key figures of the values of one channel
+func channelStatistics(values []float64) map[string]interface{} {
+	if len(values) == 0 {
+		return noValues()
+	}
+
+	mean, _ := statistic.Mean(values)
+	median, _ := statistic.Median(values, 0.5, nil)
+	variance, _ := statistic.Variance(values, nil)
+	standardDeviation := math.Sqrt(variance) // As from rollups and MongoDB
+	skewness, _ := statistic.Skewness(values, nil)
+	quantile25, quantile75, iqr, lowerBound, upperBound, outliers, quantile90, quantile95 := statistic.Quantile(values, nil)
+
+	return map[string]interface{}{
+		"mean":               mean,
+		"variance":           variance,
+		"median":             median,
+		"standardDeviation":  standardDeviation,
+		"skewness":           skewness,
+		"quantile25":         quantile25,
+		"quantile75":         quantile75,
+		"quantile90":         quantile90,
+		"quantile95":         quantile95,
+		"interquartileRange": iqr,
+		"lowerBound":         lowerBound,
+		"upperBound":         upperBound,
+		"outliers":           outliers,
+		"min":                slices.Min(values),
+		"max":                slices.Max(values),
+	}
+}
@@ -0,0 +1,126 @@
+package rollup
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	model "github.com/paulmuenzner/powerplantmanager/models"
+)
+
+// Export merges rollups into one rollup per period of level, rolled up to the plant or, with byDevice, one per device and period.
+// Hourly rollups of partial days at the edges of a period (see FindPeriod) are merged into their day. Sorted by period, then device
+func Export(rollups []model.PlantRollup, level Level, byDevice bool) []model.PlantRollup {
+	merged := map[model.RollupKey]*model.PlantRollup{}
+	keys := []model.RollupKey{}
+	for _, plantRollup := range rollups {
+		key := model.RollupKey{PeriodStart: level.Truncate(plantRollup.PeriodStart)}
+		if byDevice {
+			key.DeviceID = plantRollup.DeviceID
+		}
+		exported, exists := merged[key]
+		if !exists {
+			exported = &model.PlantRollup{ID: key, DeviceID: key.DeviceID, PeriodStart: key.PeriodStart, Channels: map[string]model.ChannelAggregate{}}
+			merged[key] = exported
+			keys = append(keys, key)
+		}
+		exported.Readings += plantRollup.Readings
+		for channel, aggregate := range plantRollup.Channels {
+			exported.Channels[channel] = mergeAggregates(exported.Channels[channel], aggregate)
+		}
+		if plantRollup.EnergyWh != nil {
+			energyWh := *plantRollup.EnergyWh
+			if exported.EnergyWh != nil {
+				energyWh += *exported.EnergyWh
+			}
+			exported.EnergyWh = &energyWh
+		}
+		if plantRollup.UpdatedAt.After(exported.UpdatedAt) {
+			exported.UpdatedAt = plantRollup.UpdatedAt
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].PeriodStart.Equal(keys[j].PeriodStart) {
+			return keys[i].PeriodStart.Before(keys[j].PeriodStart)
+		}
+		return keys[i].DeviceID < keys[j].DeviceID
+	})
+	exported := make([]model.PlantRollup, 0, len(keys))
+	for _, key := range keys {
+		exported = append(exported, *merged[key])
+	}
+	return exported
+}
+
+// mergeAggregates merges the aggregates of one channel of two rollups. Mean, m2 and m3 are merged as moments, see aggregateMoments
+func mergeAggregates(aggregate, other model.ChannelAggregate) model.ChannelAggregate {
+	if other.Count == 0 {
+		return aggregate
+	}
+	if aggregate.Count == 0 {
+		aggregate = model.ChannelAggregate{Min: other.Min, Max: other.Max}
+	}
+	moments := aggregateMoments(aggregate)
+	moments.Add(aggregateMoments(other))
+	m2, m3 := moments.M2, moments.M3
+	return model.ChannelAggregate{
+		Count: aggregate.Count + other.Count,
+		Min:   min(aggregate.Min, other.Min),
+		Max:   max(aggregate.Max, other.Max),
+		Sum:   aggregate.Sum + other.Sum,
+		Mean:  moments.Mean,
+		M2:    &m2,
+		M3:    &m3,
+	}
+}
+
+// WriteCSV writes exported rollups (see Export) as CSV with a header row: 'period_start', 'device_id' if byDevice, 'readings', 'energy_wh'
+// and '<channel>.mean', '<channel>.min' and '<channel>.max' of each of channelNames. Empty cells stand for missing values,
+// an empty 'device_id' for readings reported for the plant as a whole.
+func WriteCSV(w io.Writer, rollups []model.PlantRollup, channelNames []string, byDevice bool) error {
+	writer := csv.NewWriter(w)
+	header := []string{"period_start"}
+	if byDevice {
+		header = append(header, "device_id")
+	}
+	header = append(header, "readings", "energy_wh")
+	for _, channel := range channelNames {
+		header = append(header, channel+".mean", channel+".min", channel+".max")
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, plantRollup := range rollups {
+		row := []string{plantRollup.PeriodStart.UTC().Format(time.RFC3339)}
+		if byDevice {
+			row = append(row, plantRollup.DeviceID)
+		}
+		energyWh := ""
+		if plantRollup.EnergyWh != nil {
+			energyWh = formatValue(*plantRollup.EnergyWh)
+		}
+		row = append(row, strconv.Itoa(plantRollup.Readings), energyWh)
+		for _, channel := range channelNames {
+			aggregate, exists := plantRollup.Channels[channel]
+			if !exists || aggregate.Count == 0 {
+				row = append(row, "", "", "")
+				continue
+			}
+			row = append(row, formatValue(aggregate.Mean), formatValue(aggregate.Min), formatValue(aggregate.Max))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatValue formats a value with the fewest digits representing it exactly
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
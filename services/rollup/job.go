@@ -0,0 +1,75 @@
+package rollup
+
+import (
+	"context"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Job updates the rollups of all plants every RollupJobIntervalSec. Statistics from rollups lag behind new readings by up to this interval.
+type Job struct {
+	mongoDBInterface *mongodb.MethodInterface
+	stop             chan struct{}
+	done             chan struct{}
+}
+
+// NewJob creates a rollup job. Call Start to run it in the background.
+func NewJob(mongoDBInterface *mongodb.MethodInterface) *Job {
+	return &Job{mongoDBInterface: mongoDBInterface}
+}
+
+// Start runs the job in the background, beginning with an update right away
+func (job *Job) Start() {
+	job.stop = make(chan struct{})
+	job.done = make(chan struct{})
+	go job.run()
+}
+
+// Stop stops the job, waiting for an update in process
+func (job *Job) Stop() {
+	close(job.stop)
+	<-job.done
+}
+
+// run updates the rollups of all plants until stopped
+func (job *Job) run() {
+	defer close(job.done)
+	ctx := context.Background() // Runs in process are finished on Stop, not canceled
+	ticker := time.NewTicker(time.Duration(config.RollupJobIntervalSec) * time.Second)
+	defer ticker.Stop()
+	job.updateAll(ctx, time.Now())
+	for {
+		select {
+		case <-job.stop:
+			return
+		case now := <-ticker.C:
+			job.updateAll(ctx, now)
+		}
+	}
+}
+
+// updateAll updates the rollups of each plant. Plants failing are retried with the next update
+func (job *Job) updateAll(ctx context.Context, now time.Time) {
+	var plantConfigs []model.PlantLoggerConfig
+	err := job.mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNamePlantLoggerConfig, bson.M{}, config.CollectionNamePlantLoggerConfig, bson.D{}, &plantConfigs)
+	if err != nil {
+		logger.GetLogger().Errorf("Error in 'updateAll()' using 'FindManyInMongo()' in collection '%s' part of database '%s'. Error: %v", config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, err)
+		return
+	}
+	for _, plantConfig := range plantConfigs {
+		select {
+		case <-job.stop:
+			return
+		default:
+		}
+		if err := Update(ctx, job.mongoDBInterface, plantConfig, now); err != nil {
+			logger.GetLogger().Errorf("Updating rollups of plant '%s' failed in 'updateAll()'. Error: %v", plantConfig.PublicPlantID, err)
+		}
+	}
+}
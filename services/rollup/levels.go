@@ -0,0 +1,105 @@
+package rollup
+
+import (
+	"strings"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+)
+
+// Level of detail readings are queried at
+type Level string
+
+const (
+	LevelRaw  Level = "raw"  // Each reading
+	LevelHour Level = "hour" // Hourly rollups
+	LevelDay  Level = "day"  // Daily rollups (UTC)
+)
+
+// Levels of rollups, finest first
+var Levels = []Level{LevelHour, LevelDay}
+
+// CollectionName returns the name of the plant's rollup collection of level, sharing the id of its logger collection
+// Eg. 'plant_rollup_hour_123456789012' for logger collection 'plant_logger_123456789012'
+func CollectionName(collectionNameLogger string, level Level) string {
+	return "plant_rollup_" + string(level) + "_" + strings.TrimPrefix(collectionNameLogger, "plant_logger_")
+}
+
+// ChooseLevel returns the level of detail of a query between start and end. Long periods are queried from rollups.
+func ChooseLevel(start, end time.Time) Level {
+	period := end.Sub(start)
+	switch {
+	case period <= time.Duration(config.StatisticsRawMaxDays)*24*time.Hour:
+		return LevelRaw
+	case period <= time.Duration(config.StatisticsHourlyMaxDays)*24*time.Hour:
+		return LevelHour
+	}
+	return LevelDay
+}
+
+// Duration returns the period covered by a rollup of level
+func (level Level) Duration() time.Duration {
+	if level == LevelDay {
+		return 24 * time.Hour
+	}
+	return time.Hour
+}
+
+// Truncate returns the start of the rollup of level containing t (UTC)
+func (level Level) Truncate(t time.Time) time.Time {
+	return t.UTC().Truncate(level.Duration())
+}
+
+// Split returns the part of the period between start and end covered by whole rollups of level and the partial periods before and after it.
+// Whole is empty (Start equals End) if the period doesn't cover a whole rollup
+func (level Level) Split(start, end time.Time) (whole Range, edges []Range) {
+	wholeStart := level.Truncate(start)
+	if wholeStart.Before(start) {
+		wholeStart = wholeStart.Add(level.Duration())
+	}
+	wholeEnd := level.Truncate(end)
+	if !wholeStart.Before(wholeEnd) {
+		return Range{Start: start, End: start}, []Range{{Start: start, End: end}}
+	}
+	if start.Before(wholeStart) {
+		edges = append(edges, Range{Start: start, End: wholeStart})
+	}
+	if wholeEnd.Before(end) {
+		edges = append(edges, Range{Start: wholeEnd, End: end})
+	}
+	return Range{Start: wholeStart, End: wholeEnd}, edges
+}
+
+// Range is a period from Start (inclusive) to End (exclusive)
+type Range struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Ranges merges the hours starting at hourStarts into ranges of consecutive hours. hourStarts must be sorted
+func Ranges(hourStarts []time.Time) []Range {
+	ranges := []Range{}
+	for _, hourStart := range hourStarts {
+		if last := len(ranges) - 1; last >= 0 && !hourStart.After(ranges[last].End) {
+			ranges[last].End = hourStart.Add(time.Hour)
+			continue
+		}
+		ranges = append(ranges, Range{Start: hourStart, End: hourStart.Add(time.Hour)})
+	}
+	return ranges
+}
+
+// DayRanges returns ranges extended to full days, merged if overlapping or adjacent
+func DayRanges(ranges []Range) []Range {
+	days := []Range{}
+	for _, r := range ranges {
+		start := LevelDay.Truncate(r.Start)
+		end := LevelDay.Truncate(r.End.Add(-time.Nanosecond)).Add(24 * time.Hour)
+		if last := len(days) - 1; last >= 0 && !start.After(days[last].End) {
+			days[last].End = end
+			continue
+		}
+		days = append(days, Range{Start: start, End: end})
+	}
+	return days
+}
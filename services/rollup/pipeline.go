@@ -0,0 +1,186 @@
+package rollup
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Legacy measurement fields of readings stored before channel schemas existed, by channel name. See ChannelValue
+var legacyFields = map[string]string{
+	"voltageOutput":  "voltage_output",
+	"currentOutput":  "current_output",
+	"powerOutput":    "power_output",
+	"solarRadiation": "solar_radiation",
+	"tAmbient":       "t_ambient",
+	"tModule":        "t_module",
+	"relHumidity":    "rel_humidity",
+	"windSpeed":      "wind_speed",
+}
+
+// Pseudo channel counting the readings of a rollup. Removed before rollups are stored
+const readingsChannel = "_readings"
+
+// measurementTime is the measurement time of a reading. Logs stored before measurement times were introduced only carry 'created_at'
+var measurementTime = bson.M{"$ifNull": bson.A{"$measured_at", "$created_at"}}
+
+// hourPipeline returns the pipeline rolling up the readings measured in r, voided readings excluded, into the hourly rollup collection collectionNameHour
+func hourPipeline(r Range, collectionNameHour string, intervalSec int, now time.Time) mongo.Pipeline {
+	pipeline := append(hourStages(r, ""), shapeStages(intervalSec, now)...)
+	return append(pipeline, mergeStage(collectionNameHour))
+}
+
+// hourStages returns the stages grouping the readings measured in r, voided readings excluded, by device, hour and channel. Of one device if deviceID isn't empty
+func hourStages(r Range, deviceID string) mongo.Pipeline {
+	timeRange := bson.M{"$gte": r.Start, "$lt": r.End}
+	legacyValues := bson.M{}
+	for channel, field := range legacyFields {
+		legacyValues[channel] = bson.M{"$ifNull": bson.A{"$" + field, 0}}
+	}
+	match := bson.M{
+		"$or": bson.A{
+			bson.M{"measured_at": timeRange},
+			bson.M{"measured_at": bson.M{"$exists": false}, "created_at": timeRange},
+		},
+		"voided": bson.M{"$ne": true},
+	}
+	if deviceID != "" {
+		match["device_id"] = deviceID
+	}
+
+	return append(mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		// One document per reading and channel, plus one counting the reading
+		{{Key: "$project", Value: bson.M{
+			"_id":          0,
+			"device_id":    1,
+			"period_start": bson.M{"$dateTrunc": bson.M{"date": measurementTime, "unit": "hour"}},
+			"values": bson.M{"$concatArrays": bson.A{
+				bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$values", legacyValues}}},
+				bson.A{bson.M{"k": readingsChannel, "v": 1}},
+			}},
+		}}},
+		{{Key: "$unwind", Value: "$values"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"device_id": "$device_id", "period_start": "$period_start", "k": "$values.k"},
+			"count": bson.M{"$sum": 1},
+			"min":   bson.M{"$min": "$values.v"},
+			"max":   bson.M{"$max": "$values.v"},
+			"sum":   bson.M{"$sum": "$values.v"},
+			"parts": bson.M{"$push": bson.M{"count": 1, "mean": "$values.v", "m2": 0, "m3": 0}},
+		}}},
+	}, momentsStages()...)
+}
+
+// dayPipeline returns the pipeline rolling up hourly rollups of r, run on the hourly rollup collection, into the daily rollup collection collectionNameDay
+func dayPipeline(r Range, collectionNameDay string, intervalSec int, now time.Time) mongo.Pipeline {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"period_start": bson.M{"$gte": r.Start, "$lt": r.End}}}},
+		// One document per hour and channel, plus one counting the readings of the hour
+		{{Key: "$project", Value: bson.M{
+			"_id":          0,
+			"device_id":    1,
+			"period_start": bson.M{"$dateTrunc": bson.M{"date": "$period_start", "unit": "day"}},
+			"values": bson.M{"$concatArrays": bson.A{
+				bson.M{"$objectToArray": "$channels"},
+				bson.A{bson.M{"k": readingsChannel, "v": bson.M{"count": "$readings", "min": 0, "max": 0, "sum": 0, "mean": 0, "m2": 0, "m3": 0}}},
+			}},
+		}}},
+		{{Key: "$unwind", Value: "$values"}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"device_id": "$device_id", "period_start": "$period_start", "k": "$values.k"},
+			"count": bson.M{"$sum": "$values.v.count"},
+			"min":   bson.M{"$min": "$values.v.min"},
+			"max":   bson.M{"$max": "$values.v.max"},
+			"sum":   bson.M{"$sum": "$values.v.sum"},
+			"parts": bson.M{"$push": bson.M{"count": "$values.v.count", "mean": "$values.v.mean", "m2": "$values.v.m2", "m3": "$values.v.m3"}},
+		}}},
+	}
+	pipeline = append(pipeline, momentsStages()...)
+	pipeline = append(pipeline, shapeStages(intervalSec, now)...)
+	return append(pipeline, mergeStage(collectionNameDay))
+}
+
+// momentsStages returns the stages merging the moments (count, mean, m2, m3) of the parts of channel aggregates, see Moments.
+// The sums of squared and cubed deviations are computed from the deviations of the means of the parts from the mean of the aggregate,
+// as sums of squares and cubes of the values cancel out for values with a large offset and small spread
+func momentsStages() mongo.Pipeline {
+	deviation := "$deviation"
+	return mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"mean": bson.M{"$divide": bson.A{"$sum", "$count"}}}}},
+		{{Key: "$unwind", Value: "$parts"}},
+		{{Key: "$set", Value: bson.M{"deviation": bson.M{"$subtract": bson.A{"$parts.mean", "$mean"}}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$_id",
+			"count": bson.M{"$first": "$count"},
+			"min":   bson.M{"$first": "$min"},
+			"max":   bson.M{"$first": "$max"},
+			"sum":   bson.M{"$first": "$sum"},
+			"mean":  bson.M{"$first": "$mean"},
+			"m2":    bson.M{"$sum": bson.M{"$add": bson.A{"$parts.m2", bson.M{"$multiply": bson.A{"$parts.count", deviation, deviation}}}}},
+			"m3": bson.M{"$sum": bson.M{"$add": bson.A{
+				"$parts.m3",
+				bson.M{"$multiply": bson.A{3, deviation, "$parts.m2"}},
+				bson.M{"$multiply": bson.A{"$parts.count", deviation, deviation, deviation}},
+			}}},
+		}}},
+	}
+}
+
+// shapeStages returns the stages shaping channel aggregates grouped by device, period and channel into rollups.
+// Energy assumes each 'powerOutput' reading covers the logging interval of intervalSec seconds.
+func shapeStages(intervalSec int, now time.Time) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"device_id": "$_id.device_id", "period_start": "$_id.period_start"},
+			"channels": bson.M{"$push": bson.M{"k": "$_id.k", "v": bson.M{
+				"count": "$count",
+				"min":   "$min",
+				"max":   "$max",
+				"sum":   "$sum",
+				"mean":  "$mean",
+				"m2":    "$m2",
+				"m3":    "$m3",
+			}}},
+		}}},
+		{{Key: "$set", Value: bson.M{"channels": bson.M{"$arrayToObject": "$channels"}}}},
+		{{Key: "$set", Value: bson.M{
+			"device_id":    "$_id.device_id",
+			"period_start": "$_id.period_start",
+			"readings":     "$channels." + readingsChannel + ".count",
+			"energy_wh": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$type": "$channels.powerOutput"}, "object"}},
+				bson.M{"$multiply": bson.A{"$channels.powerOutput.sum", float64(intervalSec) / 3600}},
+				"$$REMOVE",
+			}},
+			"updated_at": now.UTC(),
+		}}},
+		{{Key: "$unset", Value: "channels." + readingsChannel}},
+	}
+}
+
+// mergeStage returns the stage storing rollups in collectionName, replacing rollups of the same device and period
+func mergeStage(collectionName string) bson.D {
+	return bson.D{{Key: "$merge", Value: bson.M{"into": collectionName, "on": "_id", "whenMatched": "replace", "whenNotMatched": "insert"}}}
+}
+
+// changedHoursPipeline returns the pipeline finding the hours of readings stored or amended since since, sorted. All hours with readings if since is zero.
+// Readings stored before the time of storage was recorded are found by their time of receipt
+func changedHoursPipeline(since time.Time) mongo.Pipeline {
+	pipeline := mongo.Pipeline{}
+	if !since.IsZero() {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{
+			"$or": bson.A{
+				bson.M{"stored_at": bson.M{"$gte": since}},
+				bson.M{"stored_at": bson.M{"$exists": false}, "received_at": bson.M{"$gte": since}},
+				bson.M{"amended_at": bson.M{"$gte": since}},
+			},
+		}}})
+	}
+	return append(pipeline,
+		bson.D{{Key: "$group", Value: bson.M{"_id": bson.M{"$dateTrunc": bson.M{"date": measurementTime, "unit": "hour"}}}}},
+		bson.D{{Key: "$match", Value: bson.M{"_id": bson.M{"$ne": nil}}}},
+		bson.D{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	)
+}
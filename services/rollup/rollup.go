@@ -0,0 +1,130 @@
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Plant logger collections whose indexes finding changed readings have been ensured by this process
+var ensuredIndexes sync.Map
+
+// FindState returns the progress of the rollups of the plant logger collection collectionNameLogger and if rollups exist
+func FindState(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string) (model.PlantRollupState, bool, error) {
+	var state model.PlantRollupState
+	found, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(ctx, config.DatabaseNamePlantLogger, bson.M{"_id": collectionNameLogger}, config.CollectionNamePlantRollupState, bson.D{}, &state)
+	return state, found, err
+}
+
+// Update rolls up the readings of a plant stored or amended since its previous update. The first update rolls up all readings.
+// Readings stored up to RollupReprocessWindowSec before the previous update are rolled up again, as their insert may have been in progress while it ran.
+// Readings spooled or imported are stored long after their receipt, so they're found by their time of storage, not of receipt.
+func Update(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, plantLoggerConfig model.PlantLoggerConfig, now time.Time) error {
+	collectionNameLogger := plantLoggerConfig.CollectionNameLogger
+	if _, ensured := ensuredIndexes.Load(collectionNameLogger); !ensured {
+		for _, fieldName := range []string{"stored_at", "received_at", "amended_at"} {
+			if err := mongoDBInterface.RepositoryInterface.CreateIndex(ctx, collectionNameLogger, config.DatabaseNamePlantLogger, fieldName); err != nil {
+				return fmt.Errorf("Error in 'Update()' using 'CreateIndex()' for field '%s' of collection '%s'. Error: %v", fieldName, collectionNameLogger, err)
+			}
+		}
+		ensuredIndexes.Store(collectionNameLogger, true)
+	}
+
+	state, found, err := FindState(ctx, mongoDBInterface, collectionNameLogger)
+	if err != nil {
+		return fmt.Errorf("Error in 'Update()' using 'FindState()' for collection '%s'. Error: %v", collectionNameLogger, err)
+	}
+	var since time.Time
+	if found {
+		since = state.CheckedAt.Add(-time.Duration(config.RollupReprocessWindowSec) * time.Second)
+	}
+
+	var changedHours []struct {
+		HourStart time.Time `bson:"_id"`
+	}
+	err = mongoDBInterface.RepositoryInterface.AggregateInMongo(ctx, config.DatabaseNamePlantLogger, collectionNameLogger, changedHoursPipeline(since), &changedHours)
+	if err != nil {
+		return fmt.Errorf("Error in 'Update()' using 'AggregateInMongo()' finding changed hours of collection '%s'. Error: %v", collectionNameLogger, err)
+	}
+	// Readings beyond the retention period may be archived already. Their rollups are kept, so readings arriving late for these hours aren't rolled up
+	retentionCutoff := loggerhandler.RetentionCutoff(plantLoggerConfig, now)
+	hourStarts := make([]time.Time, 0, len(changedHours))
+	for _, changedHour := range changedHours {
+		if changedHour.HourStart.Before(retentionCutoff) {
+			continue
+		}
+		hourStarts = append(hourStarts, changedHour.HourStart.UTC())
+	}
+
+	if err := Recompute(ctx, mongoDBInterface, plantLoggerConfig, Ranges(hourStarts), now); err != nil {
+		return err
+	}
+
+	state = model.PlantRollupState{ID: collectionNameLogger, CheckedAt: now.UTC()}
+	if found {
+		_, err = mongoDBInterface.RepositoryInterface.UpdateOneInMongo(ctx, config.DatabaseNamePlantLogger, bson.M{"_id": collectionNameLogger}, bson.M{"$set": bson.M{"checked_at": state.CheckedAt}}, config.CollectionNamePlantRollupState)
+	} else {
+		_, err = mongoDBInterface.RepositoryInterface.InsertOneToMongo(ctx, config.DatabaseNamePlantLogger, state, config.CollectionNamePlantRollupState)
+	}
+	if err != nil {
+		return fmt.Errorf("Error in 'Update()' saving rollup state of collection '%s'. Error: %v", collectionNameLogger, err)
+	}
+	return nil
+}
+
+// Recompute replaces the hourly rollups of the hours in ranges and the daily rollups of the days they touch with rollups of the stored readings.
+// Rollups of hours without readings left, eg. all voided, are removed.
+func Recompute(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, plantLoggerConfig model.PlantLoggerConfig, ranges []Range, now time.Time) error {
+	collectionNameLogger := plantLoggerConfig.CollectionNameLogger
+	collectionNameHour := CollectionName(collectionNameLogger, LevelHour)
+	collectionNameDay := CollectionName(collectionNameLogger, LevelDay)
+	intervalSec := plantLoggerConfig.IntervalSec
+	if intervalSec == 0 {
+		intervalSec = config.IntervalSecDefault
+	}
+
+	for _, r := range ranges {
+		if err := replaceRollups(ctx, mongoDBInterface, collectionNameLogger, collectionNameHour, r, hourPipeline(r, collectionNameHour, intervalSec, now)); err != nil {
+			return err
+		}
+	}
+	for _, r := range DayRanges(ranges) {
+		if err := replaceRollups(ctx, mongoDBInterface, collectionNameHour, collectionNameDay, r, dayPipeline(r, collectionNameDay, intervalSec, now)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceRollups removes the rollups of r from collectionName and runs pipeline on sourceCollectionName, merging new rollups into collectionName
+func replaceRollups(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, sourceCollectionName, collectionName string, r Range, pipeline mongo.Pipeline) error {
+	_, err := mongoDBInterface.RepositoryInterface.DeleteManyMongo(ctx, config.DatabaseNamePlantLogger, bson.M{"period_start": bson.M{"$gte": r.Start, "$lt": r.End}}, collectionName)
+	if err != nil {
+		return fmt.Errorf("Error in 'replaceRollups()' using 'DeleteManyMongo()' in collection '%s'. Error: %v", collectionName, err)
+	}
+	err = mongoDBInterface.RepositoryInterface.AggregateInMongo(ctx, config.DatabaseNamePlantLogger, sourceCollectionName, pipeline, nil)
+	if err != nil {
+		return fmt.Errorf("Error in 'replaceRollups()' using 'AggregateInMongo()' rolling up collection '%s' into '%s'. Error: %v", sourceCollectionName, collectionName, err)
+	}
+	return nil
+}
+
+// Delete removes the rollups and rollup state of the plant logger collection collectionNameLogger, eg. of a deleted plant
+func Delete(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string) error {
+	for _, level := range Levels {
+		if err := mongoDBInterface.RepositoryInterface.DeleteCollectionMongo(ctx, config.DatabaseNamePlantLogger, CollectionName(collectionNameLogger, level)); err != nil {
+			return err
+		}
+	}
+	_, err := mongoDBInterface.RepositoryInterface.DeleteDocumentMongo(ctx, config.DatabaseNamePlantLogger, bson.M{"_id": collectionNameLogger}, config.CollectionNamePlantRollupState)
+	return err
+}
@@ -0,0 +1,206 @@
+package rollup
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	"github.com/paulmuenzner/powerplantmanager/utils/statistic"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCollectionName(t *testing.T) {
+	assert.Equal(t, "plant_rollup_hour_123456789012", CollectionName("plant_logger_123456789012", LevelHour))
+	assert.Equal(t, "plant_rollup_day_123456789012", CollectionName("plant_logger_123456789012", LevelDay))
+}
+
+func TestChooseLevel(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, LevelRaw, ChooseLevel(start, start.Add(24*time.Hour)))
+	assert.Equal(t, LevelRaw, ChooseLevel(start, start.AddDate(0, 0, 7)))
+	assert.Equal(t, LevelHour, ChooseLevel(start, start.AddDate(0, 0, 8)))
+	assert.Equal(t, LevelHour, ChooseLevel(start, start.AddDate(0, 3, 0)))
+	assert.Equal(t, LevelDay, ChooseLevel(start, start.AddDate(1, 0, 0)))
+}
+
+func TestRanges(t *testing.T) {
+	hour := time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC)
+	hourStarts := []time.Time{hour, hour.Add(time.Hour), hour.Add(2 * time.Hour), hour.Add(5 * time.Hour)}
+
+	ranges := Ranges(hourStarts)
+	assert.Equal(t, []Range{
+		{Start: hour, End: hour.Add(3 * time.Hour)},
+		{Start: hour.Add(5 * time.Hour), End: hour.Add(6 * time.Hour)},
+	}, ranges)
+
+	// Both ranges touch 2 January, so days are merged
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []Range{{Start: day, End: day.AddDate(0, 0, 2)}}, DayRanges(ranges))
+
+	assert.Empty(t, Ranges(nil))
+	assert.Empty(t, DayRanges(nil))
+}
+
+func TestSplit(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+	whole, edges := LevelDay.Split(start, start.AddDate(0, 0, 3))
+	assert.Equal(t, Range{Start: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)}, whole)
+	assert.Equal(t, []Range{
+		{Start: start, End: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), End: start.AddDate(0, 0, 3)},
+	}, edges)
+
+	// Aligned periods have no edges, periods shorter than a rollup no whole part
+	whole, edges = LevelHour.Split(whole.Start, whole.End)
+	assert.Equal(t, Range{Start: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)}, whole)
+	assert.Empty(t, edges)
+	whole, edges = LevelDay.Split(start, start.Add(time.Hour))
+	assert.Equal(t, whole.Start, whole.End)
+	assert.Equal(t, []Range{{Start: start, End: start.Add(time.Hour)}}, edges)
+}
+
+func TestFindPeriodRollsUpEdges(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	plantLoggerConfig := model.PlantLoggerConfig{CollectionNameLogger: "plant_logger_1", IntervalSec: 600}
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	readings := []interface{}{}
+	for i := 0; i < 4*24*6; i++ {
+		// Grid voltage like values: large offset, small spread
+		voltage := 230.1 + 0.002*float64(i%3-1) + 0.01*float64(i%97/96)
+		readings = append(readings, model.PlantLogger{ID: primitive.NewObjectID(), Values: map[string]float64{"powerOutput": float64(i), "voltageOutput": voltage}, MeasuredAt: first.Add(time.Duration(i) * 10 * time.Minute), ReceivedAt: first})
+	}
+	_, err := mongoDBInterface.RepositoryInterface.InsertManyToMongo(ctx, config.DatabaseNamePlantLogger, readings, plantLoggerConfig.CollectionNameLogger)
+	assert.NoError(t, err)
+	assert.NoError(t, Update(ctx, mongoDBInterface, plantLoggerConfig, first.AddDate(0, 0, 5)))
+
+	// 10:30 on the first day to 14:30 on the fourth day, whole days in between
+	start, end := first.Add(10*time.Hour+30*time.Minute), first.AddDate(0, 0, 3).Add(14*time.Hour+30*time.Minute)
+	rollups, err := FindPeriod(ctx, mongoDBInterface, plantLoggerConfig, LevelDay, start, end, "")
+	assert.NoError(t, err)
+	values := []float64{}
+	var voltageMoments statistic.Moments
+	for i := 63; i < 3*24*6+87; i++ {
+		values = append(values, float64(i))
+		voltageMoments.AddValue(230.1 + 0.002*float64(i%3-1) + 0.01*float64(i%97/96))
+	}
+	assert.Equal(t, len(values), Readings(rollups))
+	mean, _ := statistic.Mean(values)
+	variance, _ := statistic.Variance(values, nil)
+	data := Statistics(rollups, []string{"powerOutput", "voltageOutput"})
+	powerOutput := data["powerOutput"].(map[string]interface{})
+	assert.InDelta(t, mean, powerOutput["mean"], 1e-9)
+	assert.InEpsilon(t, variance, powerOutput["variance"], 1e-9)
+	assert.Equal(t, 63.0, powerOutput["min"])
+	assert.Equal(t, float64(3*24*6+86), powerOutput["max"])
+	voltageOutput := data["voltageOutput"].(map[string]interface{})
+	assert.InEpsilon(t, voltageMoments.Variance(), voltageOutput["variance"], 1e-9)
+	assert.InEpsilon(t, voltageMoments.Skewness(), voltageOutput["skewness"], 1e-6)
+}
+
+func TestUpdateRollsUpReadingsStoredLate(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	plantLoggerConfig := model.PlantLoggerConfig{CollectionNameLogger: "plant_logger_1", IntervalSec: 60}
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, Update(ctx, mongoDBInterface, plantLoggerConfig, now))
+
+	// Received a day before, spooled and stored after the previous update
+	receivedAt, storedAt := now.AddDate(0, 0, -1), now.Add(time.Minute)
+	reading := model.PlantLogger{ID: primitive.NewObjectID(), Values: map[string]float64{"powerOutput": 500}, MeasuredAt: receivedAt, ReceivedAt: receivedAt, StoredAt: &storedAt}
+	_, err := mongoDBInterface.RepositoryInterface.InsertOneToMongo(ctx, config.DatabaseNamePlantLogger, reading, plantLoggerConfig.CollectionNameLogger)
+	assert.NoError(t, err)
+	assert.NoError(t, Update(ctx, mongoDBInterface, plantLoggerConfig, now.Add(5*time.Minute)))
+
+	rollups, err := FindRollups(ctx, mongoDBInterface, plantLoggerConfig.CollectionNameLogger, LevelHour, receivedAt.Add(-time.Hour), now, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, Readings(rollups))
+}
+
+// aggregate returns the aggregate of values as rolled up by the hourly pipeline
+func aggregate(values ...float64) model.ChannelAggregate {
+	result := model.ChannelAggregate{Count: len(values), Min: values[0], Max: values[0]}
+	var moments statistic.Moments
+	for _, value := range values {
+		result.Min = min(result.Min, value)
+		result.Max = max(result.Max, value)
+		result.Sum += value
+		moments.AddValue(value)
+	}
+	result.Mean, result.M2, result.M3 = moments.Mean, &moments.M2, &moments.M3
+	return result
+}
+
+func TestStatisticsMatchReadings(t *testing.T) {
+	first := []float64{100, 250, 400}
+	second := []float64{50, 900}
+	energyFirst, energySecond := 187.5, 237.5
+	rollups := []model.PlantRollup{
+		{Readings: 3, Channels: map[string]model.ChannelAggregate{"powerOutput": aggregate(first...)}, EnergyWh: &energyFirst},
+		// Stored before deviations from the mean
+		{Readings: 2, Channels: map[string]model.ChannelAggregate{"powerOutput": {Count: 2, Min: 50, Max: 900, Sum: 950, Mean: 475, SumSquares: 50*50 + 900*900, SumCubes: 50*50*50 + 900*900*900}}, EnergyWh: &energySecond},
+		{Readings: 1, Channels: map[string]model.ChannelAggregate{"solarRadiation": aggregate(300)}},
+	}
+
+	data := Statistics(rollups, []string{"powerOutput", "tModule"})
+	powerOutput := data["powerOutput"].(map[string]interface{})
+	values := append(append([]float64{}, first...), second...)
+	mean, _ := statistic.Mean(values)
+	variance, _ := statistic.Variance(values, nil)
+	skewness, _ := statistic.Skewness(values, nil)
+	assert.InDelta(t, mean, powerOutput["mean"], 1e-9)
+	assert.InDelta(t, variance, powerOutput["variance"], 1e-6)
+	assert.InDelta(t, skewness, powerOutput["skewness"], 1e-9)
+	assert.Equal(t, 50.0, powerOutput["min"])
+	assert.Equal(t, 900.0, powerOutput["max"])
+	assert.Nil(t, data["tModule"].(map[string]interface{})["mean"])
+	assert.NotContains(t, data, "correlationPowerSolar")
+
+	energyWh, provided := Energy(rollups)
+	assert.True(t, provided)
+	assert.Equal(t, 425.0, energyWh)
+	assert.Equal(t, 6, Readings(rollups))
+}
+
+func TestExport(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	energyWh := 100.0
+	rollups := []model.PlantRollup{
+		{DeviceID: "inverter2", PeriodStart: day.Add(time.Hour), Readings: 2, Channels: map[string]model.ChannelAggregate{"powerOutput": aggregate(50, 900)}},
+		{DeviceID: "inverter1", PeriodStart: day, Readings: 3, Channels: map[string]model.ChannelAggregate{"powerOutput": aggregate(100, 250, 400)}, EnergyWh: &energyWh},
+		{DeviceID: "inverter1", PeriodStart: day.AddDate(0, 0, 1), Readings: 1, Channels: map[string]model.ChannelAggregate{"tAmbient": aggregate(12.5)}},
+	}
+
+	// Rolled up to the plant per day
+	exported := Export(rollups, LevelDay, false)
+	assert.Len(t, exported, 2)
+	merged := aggregate(100, 250, 400, 50, 900)
+	powerOutput := exported[0].Channels["powerOutput"]
+	assert.Equal(t, day, exported[0].PeriodStart)
+	assert.Equal(t, 5, exported[0].Readings)
+	assert.Equal(t, merged.Count, powerOutput.Count)
+	assert.Equal(t, 50.0, powerOutput.Min)
+	assert.Equal(t, 900.0, powerOutput.Max)
+	assert.Equal(t, 1700.0, powerOutput.Sum)
+	assert.InDelta(t, merged.Mean, powerOutput.Mean, 1e-9)
+	assert.InEpsilon(t, *merged.M2, *powerOutput.M2, 1e-9)
+	assert.Equal(t, 100.0, *exported[0].EnergyWh)
+
+	// Per device and hour
+	exported = Export(rollups, LevelHour, true)
+	assert.Len(t, exported, 3)
+	assert.Equal(t, "inverter1", exported[0].DeviceID)
+	assert.Equal(t, "inverter2", exported[1].DeviceID)
+
+	var csv strings.Builder
+	assert.NoError(t, WriteCSV(&csv, exported, []string{"powerOutput", "tAmbient"}, true))
+	assert.Equal(t, "period_start,device_id,readings,energy_wh,powerOutput.mean,powerOutput.min,powerOutput.max,tAmbient.mean,tAmbient.min,tAmbient.max\n"+
+		"2024-01-01T00:00:00Z,inverter1,3,100,250,100,400,,,\n"+
+		"2024-01-01T01:00:00Z,inverter2,2,,475,50,900,,,\n"+
+		"2024-01-02T00:00:00Z,inverter1,1,,,,,12.5,12.5,12.5\n", csv.String())
+}
@@ -0,0 +1,182 @@
+package rollup
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"github.com/paulmuenzner/powerplantmanager/utils/statistic"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FindPeriod returns rollups covering exactly the period between start and end, of one device if deviceID isn't empty. The part covered by whole rollups of level
+// is found in the stored rollups, partial hours or days at its edges are rolled up from the readings by hour. See FindRollups and RollUpReadings
+func FindPeriod(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, plantLoggerConfig model.PlantLoggerConfig, level Level, start, end time.Time, deviceID string) ([]model.PlantRollup, error) {
+	whole, edges := level.Split(start, end)
+	rollups := []model.PlantRollup{}
+	if whole.Start.Before(whole.End) {
+		wholeRollups, err := FindRollups(ctx, mongoDBInterface, plantLoggerConfig.CollectionNameLogger, level, whole.Start, whole.End, deviceID)
+		if err != nil {
+			return nil, err
+		}
+		rollups = append(rollups, wholeRollups...)
+	}
+	for _, edge := range edges {
+		edgeRollups, err := RollUpReadings(ctx, mongoDBInterface, plantLoggerConfig, edge, deviceID)
+		if err != nil {
+			return nil, err
+		}
+		rollups = append(rollups, edgeRollups...)
+	}
+	return rollups, nil
+}
+
+// RollUpReadings returns hourly rollups of the readings measured in r without storing them, of one device if deviceID isn't empty
+func RollUpReadings(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, plantLoggerConfig model.PlantLoggerConfig, r Range, deviceID string) ([]model.PlantRollup, error) {
+	intervalSec := plantLoggerConfig.IntervalSec
+	if intervalSec == 0 {
+		intervalSec = config.IntervalSecDefault
+	}
+	var rollups []model.PlantRollup
+	pipeline := append(hourStages(r, deviceID), shapeStages(intervalSec, time.Now())...)
+	if err := mongoDBInterface.RepositoryInterface.AggregateInMongo(ctx, config.DatabaseNamePlantLogger, plantLoggerConfig.CollectionNameLogger, pipeline, &rollups); err != nil {
+		return nil, fmt.Errorf("Error in 'RollUpReadings()' using 'AggregateInMongo()' in collection '%s'. Error: %w", plantLoggerConfig.CollectionNameLogger, err)
+	}
+	return rollups, nil
+}
+
+// FindRollups returns the rollups of level of the plant logger collection collectionNameLogger starting between start and end, of one device if deviceID isn't empty
+func FindRollups(ctx context.Context, mongoDBInterface *mongodb.MethodInterface, collectionNameLogger string, level Level, start, end time.Time, deviceID string) ([]model.PlantRollup, error) {
+	filter := bson.M{"period_start": bson.M{"$gte": start, "$lt": end}}
+	if deviceID != "" {
+		filter["device_id"] = deviceID
+	}
+	var rollups []model.PlantRollup
+	err := mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNamePlantLogger, filter, CollectionName(collectionNameLogger, level), bson.D{{Key: "period_start", Value: 1}}, &rollups)
+	if err != nil {
+		return nil, fmt.Errorf("Error in 'FindRollups()' using 'FindManyInMongo()' in collection '%s'. Error: %v", CollectionName(collectionNameLogger, level), err)
+	}
+	return rollups, nil
+}
+
+// Key figures of statistics approximated from rollups, see Statistics
+var ApproximatedKeyFigures = []string{"median", "quantile25", "quantile75", "quantile90", "quantile95", "interquartileRange", "lowerBound", "upperBound", "outliers", "correlationPowerSolar"}
+
+// Statistics computes the statistics of each channel of rollups in the shape of statistics of readings and, if both channels are analyzed, the correlation of power output and solar radiation.
+// Mean, variance, standard deviation and skewness are exact. Median, quantiles and outliers are approximated by the means of the rollups weighted by their number of values,
+// the correlation by the means of rollups providing both channels.
+func Statistics(rollups []model.PlantRollup, statisticsChannels []string) map[string]interface{} {
+	data := map[string]interface{}{}
+	for _, channel := range statisticsChannels {
+		data[channel] = channelStatistics(rollups, channel)
+	}
+
+	if slices.Contains(statisticsChannels, "powerOutput") && slices.Contains(statisticsChannels, "solarRadiation") {
+		powerOutputs, solarRadiation, weights := []float64{}, []float64{}, []float64{}
+		for _, rollup := range rollups {
+			powerOutput, hasPowerOutput := rollup.Channels["powerOutput"]
+			radiation, hasSolarRadiation := rollup.Channels["solarRadiation"]
+			if hasPowerOutput && hasSolarRadiation {
+				powerOutputs = append(powerOutputs, powerOutput.Mean)
+				solarRadiation = append(solarRadiation, radiation.Mean)
+				weights = append(weights, float64(min(powerOutput.Count, radiation.Count)))
+			}
+		}
+		correlationPowerSolar, _ := statistic.Correlation(powerOutputs, solarRadiation, weights)
+		data["correlationPowerSolar"] = correlationPowerSolar
+	}
+
+	return data
+}
+
+// Energy returns the energy of rollups in Wh and if any rollup provides energy
+func Energy(rollups []model.PlantRollup) (float64, bool) {
+	energyWh, provided := 0.0, false
+	for _, rollup := range rollups {
+		if rollup.EnergyWh != nil {
+			energyWh += *rollup.EnergyWh
+			provided = true
+		}
+	}
+	return energyWh, provided
+}
+
+// Readings returns the number of readings rolled up in rollups
+func Readings(rollups []model.PlantRollup) int {
+	readings := 0
+	for _, rollup := range rollups {
+		readings += rollup.Readings
+	}
+	return readings
+}
+
+// channelStatistics computes the statistical key figures of one channel of rollups. Without values key figures are null
+func channelStatistics(rollups []model.PlantRollup, channel string) map[string]interface{} {
+	var moments statistic.Moments
+	means, weights := []float64{}, []float64{}
+	minimum, maximum := 0.0, 0.0
+	for _, rollup := range rollups {
+		aggregate, exists := rollup.Channels[channel]
+		if !exists || aggregate.Count == 0 {
+			continue
+		}
+		if moments.Count == 0 || aggregate.Min < minimum {
+			minimum = aggregate.Min
+		}
+		if moments.Count == 0 || aggregate.Max > maximum {
+			maximum = aggregate.Max
+		}
+		moments.Add(aggregateMoments(aggregate))
+		means = append(means, aggregate.Mean)
+		weights = append(weights, float64(aggregate.Count))
+	}
+
+	if moments.Count == 0 {
+		return map[string]interface{}{
+			"mean": nil, "variance": nil, "median": nil, "standardDeviation": nil, "skewness": nil,
+			"quantile25": nil, "quantile75": nil, "quantile90": nil, "quantile95": nil,
+			"interquartileRange": nil, "lowerBound": nil, "upperBound": nil, "outliers": []float64{},
+			"min": nil, "max": nil,
+		}
+	}
+
+	median, _ := statistic.Median(means, 0.5, weights)
+	quantile25, quantile75, iqr, lowerBound, upperBound, outliers, quantile90, quantile95 := statistic.Quantile(means, weights)
+
+	return map[string]interface{}{
+		"mean":               moments.Mean,
+		"variance":           moments.Variance(),
+		"median":             median,
+		"standardDeviation":  moments.StandardDeviation(),
+		"skewness":           moments.Skewness(),
+		"quantile25":         quantile25,
+		"quantile75":         quantile75,
+		"quantile90":         quantile90,
+		"quantile95":         quantile95,
+		"interquartileRange": iqr,
+		"lowerBound":         lowerBound,
+		"upperBound":         upperBound,
+		"outliers":           outliers,
+		"min":                minimum,
+		"max":                maximum,
+	}
+}
+
+// aggregateMoments returns the moments of the values of a channel aggregate. Rollups stored before deviations from the mean only provide sums of squares and cubes
+func aggregateMoments(aggregate model.ChannelAggregate) statistic.Moments {
+	count, mean := float64(aggregate.Count), aggregate.Mean
+	if aggregate.M2 != nil && aggregate.M3 != nil {
+		return statistic.Moments{Count: count, Mean: mean, M2: *aggregate.M2, M3: *aggregate.M3}
+	}
+	return statistic.Moments{
+		Count: count,
+		Mean:  mean,
+		M2:    aggregate.SumSquares - count*mean*mean,
+		M3:    aggregate.SumCubes - 3*mean*aggregate.SumSquares + 2*count*mean*mean*mean,
+	}
+}
@@ -0,0 +1,73 @@
+package routevalidation
+
+import (
+	"context"
+	"fmt"
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"net/http"
+	"time"
+)
+
+// /////////////////////////////////////////////////////////////////////////////////////////////
+// GET ARCHIVE FILES OF PLANT
+// ///////////////////
+func GetArchivesValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////
+		// REQUEST BODY VALIDATION ///////////////////
+		//
+		_, plant, ok := validateDeviceRequest(w, r, mongoDBInterface, "GetArchivesValidation", []string{"publicPlantID"}, []string{})
+		if !ok {
+			return
+		}
+
+		plantLoggerConfig, ok := findPlantLoggerConfig(r.Context(), w, mongoDBInterface, "GetArchivesValidation", plant)
+		if !ok {
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), "plantLoggerConfig", plantLoggerConfig))
+
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////////
+// REHYDRATE ARCHIVED READINGS
+// ///////////////////
+func RehydrateArchiveValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////
+		// REQUEST BODY VALIDATION ///////////////////
+		//
+		data, plant, ok := validateDeviceRequest(w, r, mongoDBInterface, "RehydrateArchiveValidation", []string{"publicPlantID", "dateStart", "dateEnd"}, []string{})
+		if !ok {
+			return
+		}
+
+		// Validate period, at most RehydrationMaxDays
+		dateStartString, _ := data["dateStart"].(string)
+		dateEndString, _ := data["dateEnd"].(string)
+		dateStart, errStart := time.Parse(time.RFC3339Nano, dateStartString)
+		dateEnd, errEnd := time.Parse(time.RFC3339Nano, dateEndString)
+		if errStart != nil || errEnd != nil || !dateStart.Before(dateEnd) || dateEnd.Sub(dateStart) > time.Duration(config.RehydrationMaxDays)*24*time.Hour {
+			errHandler.HandleError(w, fmt.Sprintf("'dateStart' and 'dateEnd' must be RFC3339 times, 'dateEnd' after 'dateStart' and at most %d days apart.", config.RehydrationMaxDays), errHandler.BadRequest)
+			return
+		}
+
+		plantLoggerConfig, ok := findPlantLoggerConfig(r.Context(), w, mongoDBInterface, "RehydrateArchiveValidation", plant)
+		if !ok {
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), "plantLoggerConfig", plantLoggerConfig))
+		r = r.WithContext(context.WithValue(r.Context(), "rehydrationStart", dateStart))
+		r = r.WithContext(context.WithValue(r.Context(), "rehydrationEnd", dateEnd))
+
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
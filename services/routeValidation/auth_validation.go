@@ -71,7 +71,7 @@ func RegistrationValidation(next http.HandlerFunc, mongoDBInterface *mongodb.Met
 		}
 
 		// Check if email already in database. Each email must be unique
-		exists, _ := mongoDBInterface.RepositoryInterface.IsValueInCollection(config.DatabaseNameUserAuth, config.UserAuthCollectionName, "email", data["email"].(string))
+		exists, _ := mongoDBInterface.RepositoryInterface.IsValueInCollection(r.Context(), config.DatabaseNameUserAuth, config.UserAuthCollectionName, "email", data["email"].(string))
 		if exists {
 			// Prepare email notification
 			errHandler.HandleError(w, "Please choose another email address.", errHandler.BadRequest)
@@ -0,0 +1,281 @@
+package routevalidation
+
+import (
+	"context"
+	"fmt"
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	cookie "github.com/paulmuenzner/powerplantmanager/utils/cookies"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	stringHandler "github.com/paulmuenzner/powerplantmanager/utils/strings"
+	v "github.com/paulmuenzner/powerplantmanager/utils/validate"
+	"net/http"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// /////////////////////////////////////////////////////////////////////////////////////////////
+// ADD PLANT DEVICE
+// ////////////////
+func AddDeviceValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		neutralResponseErr := "We appologize. Adding a device is currently not possible due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+		//////////////////////////////////////////////
+		// REQUEST BODY VALIDATION ///////////////////
+		//
+		data, plant, ok := validateDeviceRequest(w, r, mongoDBInterface, "AddDeviceValidation", []string{"publicPlantID", "type", "name"}, []string{"parentDeviceID"})
+		if !ok {
+			return
+		}
+
+		// Validate device type
+		deviceType, deviceTypeValid := data["type"].(string)
+		if !deviceTypeValid || !loggerhandler.IsDeviceType(deviceType) {
+			errHandler.HandleError(w, "'type' must be one of: "+strings.Join(loggerhandler.DeviceTypes, ", ")+".", errHandler.BadRequest)
+			return
+		}
+
+		// Validate device name
+		name, nameValid := data["name"].(string)
+		validateName := v.Validate(name).
+			MaxLength(config.DeviceNameMaxLength, fmt.Sprintf("Maximum number of characters for device name cannot exceed %d.", config.DeviceNameMaxLength)).
+			GetResult()
+		if !nameValid || name == "" || len(validateName) > 0 {
+			errHandler.HandleError(w, fmt.Sprintf("'name' must be a non-empty string of at most %d characters.", config.DeviceNameMaxLength), errHandler.BadRequest)
+			return
+		}
+
+		// Validate number of devices and optional parent device
+		var devices []model.PlantDevice
+		var filter bson.M = bson.M{"public_plant_id": plant.PublicPlantID}
+		err := mongoDBInterface.RepositoryInterface.FindManyInMongo(r.Context(), config.DatabaseNamePlantDevice, filter, config.CollectionNamePlantDevice, bson.D{}, &devices)
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'AddDeviceValidation()' using 'FindManyInMongo()' in collection '%s' part of database '%s' finding devices of plant %s. Error: %v", config.CollectionNamePlantDevice, config.DatabaseNamePlantDevice, plant.PublicPlantID, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+		if len(devices) >= config.DevicesMax {
+			errHandler.HandleError(w, fmt.Sprintf("Maximum number of devices per plant: %d", config.DevicesMax), errHandler.BadRequest)
+			return
+		}
+
+		parentDeviceID := ""
+		if rawParentDeviceID, hasParent := data["parentDeviceID"]; hasParent {
+			parentDeviceID, _ = rawParentDeviceID.(string)
+			parentExists := false
+			for _, device := range devices {
+				if parentDeviceID != "" && device.DeviceID == parentDeviceID {
+					parentExists = true
+				}
+			}
+			if !parentExists {
+				errHandler.HandleError(w, "'parentDeviceID' must be the id of a device of this plant.", errHandler.BadRequest)
+				return
+			}
+		}
+
+		// Attach plant and new device to context
+		device := model.PlantDevice{
+			PublicPlantID:  plant.PublicPlantID,
+			Type:           deviceType,
+			Name:           name,
+			ParentDeviceID: parentDeviceID,
+		}
+		r = r.WithContext(context.WithValue(r.Context(), "plantRequest", plant))
+		r = r.WithContext(context.WithValue(r.Context(), "plantDevice", device))
+
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////////
+// CREATE NEW DEVICE KEY AND SECRET
+// ////////////////////////////////
+func SetDeviceKeySecretValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, device, ok := validateDeviceOfPlant(w, r, mongoDBInterface, "SetDeviceKeySecretValidation")
+		if !ok {
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), "plantDevice", device))
+
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////////
+// DELETE PLANT DEVICE
+// ///////////////////
+func DeleteDeviceValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		neutralResponseErr := "We appologize. Deletion currently not possible due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+		plant, device, ok := validateDeviceOfPlant(w, r, mongoDBInterface, "DeleteDeviceValidation")
+		if !ok {
+			return
+		}
+
+		// Devices with child devices (eg. inverter with strings) cannot be deleted before their children
+		var childDevice model.PlantDevice
+		var filter bson.M = bson.M{"public_plant_id": plant.PublicPlantID, "parent_device_id": device.DeviceID}
+		hasChildren, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNamePlantDevice, filter, config.CollectionNamePlantDevice, bson.D{}, &childDevice)
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'DeleteDeviceValidation()' using 'FindOneInMongo()' in collection '%s' part of database '%s' finding child devices of device %s. Error: %v", config.CollectionNamePlantDevice, config.DatabaseNamePlantDevice, device.DeviceID, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+		if hasChildren {
+			errHandler.HandleError(w, "Please delete the devices assigned to this device first.", errHandler.BadRequest)
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), "plantDevice", device))
+
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////////
+// GET PLANT DEVICES
+// /////////////////
+func GetDevicesValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, plant, ok := validateDeviceRequest(w, r, mongoDBInterface, "GetDevicesValidation", []string{"publicPlantID"}, []string{})
+		if !ok {
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), "plantRequest", plant))
+
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
+
+// validateDeviceOfPlant validates requests of the form {publicPlantID, deviceID}. The device must belong to a plant owned by the requesting user.
+// Responds to the request on failure. Returns plant, device and if the request is valid.
+func validateDeviceOfPlant(w http.ResponseWriter, r *http.Request, mongoDBInterface *mongodb.MethodInterface, validatorName string) (model.PhotovoltaicPlant, model.PlantDevice, bool) {
+	neutralResponseErr := "We appologize. Device management currently not possible due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+	data, plant, ok := validateDeviceRequest(w, r, mongoDBInterface, validatorName, []string{"publicPlantID", "deviceID"}, []string{})
+	if !ok {
+		return plant, model.PlantDevice{}, false
+	}
+
+	deviceID, deviceIDValid := data["deviceID"].(string)
+	if !deviceIDValid {
+		errHandler.HandleError(w, "'deviceID' must be the id of a device of this plant.", errHandler.BadRequest)
+		return plant, model.PlantDevice{}, false
+	}
+	device, found, err := loggerhandler.FindDevice(r.Context(), mongoDBInterface, plant.PublicPlantID, deviceID)
+	if err != nil {
+		logger.GetLogger().Errorf("Error in '%s()' using 'FindDevice()'. Error: %v", validatorName, err)
+		errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+		return plant, device, false
+	}
+	if !found {
+		errHandler.HandleError(w, "Requested device not found.", errHandler.BadRequest)
+		return plant, device, false
+	}
+
+	return plant, device, true
+}
+
+// validateDeviceRequest validates the auth status, the request body keys and the ownership of the plant with the requested publicPlantID.
+// Responds to the request on failure. Returns the request body, the plant and if the request is valid.
+func validateDeviceRequest(w http.ResponseWriter, r *http.Request, mongoDBInterface *mongodb.MethodInterface, validatorName string, requiredKeys, optionalKeys []string) (map[string]interface{}, model.PhotovoltaicPlant, bool) {
+	neutralResponseErr := "We appologize. Device management currently not possible due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+	var plant model.PhotovoltaicPlant
+
+	//////////////////////////////////////////////
+	// VALIDATE AUTH STATUS //////////////////////
+	//
+	// Validate if logged in
+	expired := cookie.HasCookieExpired(r, config.AuthCookieName)
+	if expired {
+		errHandler.HandleError(w, "You are not authenticated. Please signin.", errHandler.Unauthorized)
+		return nil, plant, false
+	}
+
+	//////////////////////////////////////////////
+	// REQUEST BODY VALIDATION ///////////////////
+	//
+	// Access the parsed JSON data from the context
+	data, ok := r.Context().Value("requestBody").(map[string]interface{})
+	if !ok {
+		logger.GetLogger().Errorf("Error in '%s()'. Cannot parse requestBody. Request: %+v", validatorName, r)
+		errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		return nil, plant, false
+	}
+
+	// Validate if request body exactly contains number and names of expected keys
+	expectedKeys := loggerhandler.ExpectedReadingKeys(data, requiredKeys, optionalKeys)
+	validateKeys := v.Validate(data).
+		HasMapExactKeys(expectedKeys).
+		GetResult()
+
+	if len(validateKeys) > 0 {
+		errHandler.HandleError(w, validateKeys[0], errHandler.BadRequest)
+		return nil, plant, false
+	}
+
+	publicPlantID, publicPlantIdValid := data["publicPlantID"].(string)
+	if !publicPlantIdValid {
+		errHandler.HandleError(w, "Requested plant not found.", errHandler.BadRequest)
+		return nil, plant, false
+	}
+
+	// Validate if plant with publicPlantID exists and if requesting user is authorized to manage its devices
+	plant, ok = findOwnedPlant(w, r, mongoDBInterface, validatorName, publicPlantID)
+	if !ok {
+		return nil, plant, false
+	}
+
+	return data, plant, true
+}
+
+// findOwnedPlant finds the plant with publicPlantID and validates if it's owned by the user of the auth cookie.
+// Responds to the request on failure. Returns the plant and if it's owned by the requesting user.
+func findOwnedPlant(w http.ResponseWriter, r *http.Request, mongoDBInterface *mongodb.MethodInterface, validatorName, publicPlantID string) (model.PhotovoltaicPlant, bool) {
+	neutralResponseErr := "We appologize. Request currently not possible due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+	var plant model.PhotovoltaicPlant
+
+	// Extract data from JWT in cookie
+	claimData, err := cookie.GetCookieData(r, config.AuthCookieName)
+	if err != nil {
+		logger.GetLogger().Errorf("Cannot extract data/claim from cookie in validator '%s()'. Cookie name: %s. Error: %v", validatorName, config.AuthCookieName, err)
+		errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		return plant, false
+	}
+	userIDRaw := claimData["data"].(map[string]interface{})["userId"]
+	userID := stringHandler.InterfaceToString(userIDRaw)
+
+	// Find plant by provided public plant id to validate if owner of plant equals _id in cookie
+	var filter bson.M = bson.M{"public_plant_id": publicPlantID}
+	findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNamePlants, filter, config.CollectionNamePhotovoltaicPlant, bson.D{}, &plant)
+	if err != nil {
+		logger.GetLogger().Errorf("Error in '%s()' using 'FindOneInMongo()' in collection '%s' part of database '%s' finding plant with id %s. Error: %v", validatorName, config.CollectionNamePhotovoltaicPlant, config.DatabaseNamePlants, publicPlantID, err)
+		errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+		return plant, false
+	}
+	if !findOne {
+		logger.GetLogger().Errorf("User with id '%s' requested not existing plant with public plant id '%s' in validator '%s()'.", userID, publicPlantID, validatorName)
+		errHandler.HandleError(w, "Requested plant not found.", errHandler.BadRequest)
+		return plant, false
+	}
+	if plant.User.Hex() != userID {
+		logger.GetLogger().Errorf("User with id '%s' requested plant id '%s' without ownership in validator '%s()'.", userID, publicPlantID, validatorName)
+		errHandler.HandleError(w, "You don't own any plant with your provided ID.", errHandler.BadRequest)
+		return plant, false
+	}
+	return plant, true
+}
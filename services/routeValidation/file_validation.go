@@ -163,10 +163,10 @@ func DeleteImageValidation(next http.HandlerFunc, awsInterface *aws.MethodInterf
 		var filter bson.M = bson.M{"public_file_id": publicFileID}
 		var file model.File
 		var sort bson.D = bson.D{}
-		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(config.DatabaseNameFiles, filter, config.CollectionNameFiles, sort, &file)
+		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNameFiles, filter, config.CollectionNameFiles, sort, &file)
 		if err != nil {
 			logger.GetLogger().Errorf("Error in 'DeleteImageValidation()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s'. Error: %v", config.CollectionNameFiles, config.DatabaseNameFiles, err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 		if !findOne {
@@ -187,14 +187,14 @@ func DeleteImageValidation(next http.HandlerFunc, awsInterface *aws.MethodInterf
 		/////////////////////////////////////
 		// Validate if file exists on S3
 		var key = file.Slug
-		fileExists, err := awsInterface.RepositoryInterfaceS3.S3ObjectExists(os.Getenv("BUCKET_NAME"), key)
-		if !fileExists {
-			logger.GetLogger().Error("Requested file not found on S3 in 'DeleteImageValidation'.", " Public file ID: ", file.PublicFileID)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
-			return
-		}
+		fileExists, err := awsInterface.RepositoryInterfaceS3.S3ObjectExists(r.Context(), os.Getenv("BUCKET_NAME"), key)
 		if err != nil {
 			logger.GetLogger().Error("Cannot validate if file exists on S3 in 'DeleteImageValidation' using 'CheckFileExistsS3'. Error: ", err, " Public file ID: ", file.PublicFileID)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+		if !fileExists {
+			logger.GetLogger().Error("Requested file not found on S3 in 'DeleteImageValidation'.", " Public file ID: ", file.PublicFileID)
 			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
 			return
 		}
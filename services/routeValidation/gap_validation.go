@@ -0,0 +1,126 @@
+package routevalidation
+
+import (
+	"context"
+	"fmt"
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	cookie "github.com/paulmuenzner/powerplantmanager/utils/cookies"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	v "github.com/paulmuenzner/powerplantmanager/utils/validate"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// /////////////////////////////////////////////////////////////////////////////////////////////
+// GET PLANT DATA GAPS
+// ///////////////////
+func GetPlantGapsValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		neutralResponseErr := "We appologize. Gap analysis currently not available due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+		//////////////////////////////////////////////
+		// VALIDATE AUTH STATUS //////////////////////
+		//
+		// Validate if logged in
+		expired := cookie.HasCookieExpired(r, config.AuthCookieName)
+		if expired {
+			errHandler.HandleError(w, "You are not authenticated. Please signin.", errHandler.Unauthorized)
+			return
+		}
+
+		//////////////////////////////////////////////
+		// REQUEST BODY VALIDATION ///////////////////
+		//
+		// Access the parsed JSON data from the context
+		data, ok := r.Context().Value("requestBody").(map[string]interface{})
+		if !ok {
+			logger.GetLogger().Errorf("Error in 'GetPlantGapsValidation()'. Cannot parse requestBody. Request: %+v", r)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			return
+		}
+
+		// Validate if request body exactly contains number and names of expected keys. Device is optional
+		expectedKeys := loggerhandler.ExpectedReadingKeys(data, []string{"publicPlantID", "dateStart", "dateEnd"}, []string{"deviceID"})
+		validateKeys := v.Validate(data).
+			HasMapExactKeys(expectedKeys).
+			GetResult()
+		if len(validateKeys) > 0 {
+			errHandler.HandleError(w, validateKeys[0], errHandler.BadRequest)
+			return
+		}
+
+		// Validate period
+		dateStartString, _ := data["dateStart"].(string)
+		dateEndString, _ := data["dateEnd"].(string)
+		dateStart, errStart := time.Parse(time.RFC3339Nano, dateStartString)
+		dateEnd, errEnd := time.Parse(time.RFC3339Nano, dateEndString)
+		if errStart != nil || errEnd != nil {
+			errHandler.HandleError(w, "'dateStart' and 'dateEnd' must be RFC3339 times.", errHandler.BadRequest)
+			return
+		}
+		if !dateStart.Before(dateEnd) || dateEnd.Sub(dateStart) > time.Duration(config.GapAnalysisMaxDays)*24*time.Hour {
+			errHandler.HandleError(w, fmt.Sprintf("'dateEnd' must lie after 'dateStart', at most %d days.", config.GapAnalysisMaxDays), errHandler.BadRequest)
+			return
+		}
+
+		// Validate if plant exists and is owned by the requesting user
+		publicPlantID, publicPlantIdValid := data["publicPlantID"].(string)
+		if !publicPlantIdValid {
+			errHandler.HandleError(w, "Requested plant not found.", errHandler.BadRequest)
+			return
+		}
+		plant, ok := findOwnedPlant(w, r, mongoDBInterface, "GetPlantGapsValidation", publicPlantID)
+		if !ok {
+			return
+		}
+
+		// Logging interval and collection of the plant
+		var plantLoggerConfig model.PlantLoggerConfig
+		var filter bson.M = bson.M{"_id": plant.ID}
+		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNamePlantLoggerConfig, filter, config.CollectionNamePlantLoggerConfig, bson.D{}, &plantLoggerConfig)
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'GetPlantGapsValidation()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s' for plant ID %s. Error: %v", config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, publicPlantID, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+		if !findOne {
+			logger.GetLogger().Errorf("Plant logger config of plant with public plant id '%s' not found in 'GetPlantGapsValidation()'.", publicPlantID)
+			errHandler.HandleError(w, "Requested plant not available.", errHandler.BadRequest)
+			return
+		}
+
+		// Validate optional device to analyze. Readings of all devices count for the plant if missing
+		if rawDeviceID, hasDeviceID := data["deviceID"]; hasDeviceID {
+			deviceID, deviceIDValid := rawDeviceID.(string)
+			if !deviceIDValid {
+				errHandler.HandleError(w, "'deviceID' must be the id of a device of this plant.", errHandler.BadRequest)
+				return
+			}
+			_, found, err := loggerhandler.FindDevice(r.Context(), mongoDBInterface, publicPlantID, deviceID)
+			if err != nil {
+				logger.GetLogger().Errorf("Error in 'GetPlantGapsValidation()' using 'FindDevice()'. Error: %v", err)
+				errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+				return
+			}
+			if !found {
+				errHandler.HandleError(w, "'deviceID' must be the id of a device of this plant.", errHandler.BadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "gapDeviceID", deviceID))
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), "plantRequest", plant))
+		r = r.WithContext(context.WithValue(r.Context(), "plantLoggerConfig", plantLoggerConfig))
+		r = r.WithContext(context.WithValue(r.Context(), "gapDateStart", dateStart))
+		r = r.WithContext(context.WithValue(r.Context(), "gapDateEnd", dateEnd))
+
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
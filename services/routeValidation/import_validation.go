@@ -0,0 +1,147 @@
+package routevalidation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	importhandler "github.com/paulmuenzner/powerplantmanager/services/importHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	cookie "github.com/paulmuenzner/powerplantmanager/utils/cookies"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// /////////////////////////////////////////////////////////////////////////////////////////////
+// IMPORT PLANT LOGS
+// /////////////////
+func ImportPlantLogsValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		neutralResponseErr := "We appologize. Import currently not possible due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+		//////////////////////////////////////////////
+		// VALIDATE AUTH STATUS //////////////////////
+		//
+		// Validate if logged in
+		expired := cookie.HasCookieExpired(r, config.AuthCookieName)
+		if expired {
+			errHandler.HandleError(w, "You are not authenticated. Please signin.", errHandler.Unauthorized)
+			return
+		}
+
+		//////////////////////////////////////////////
+		// REQUEST BODY VALIDATION ///////////////////
+		//
+		// Large files take longer than the server's read and write timeouts permit for other requests
+		deadline := time.Now().Add(time.Duration(config.ImportTimeoutSec) * time.Second)
+		responseController := http.NewResponseController(w)
+		if err := responseController.SetReadDeadline(deadline); err != nil {
+			logger.GetLogger().Warnf("Cannot extend read deadline in 'ImportPlantLogsValidation()'. Error: %v", err)
+		}
+		if err := responseController.SetWriteDeadline(deadline); err != nil {
+			logger.GetLogger().Warnf("Cannot extend write deadline in 'ImportPlantLogsValidation()'. Error: %v", err)
+		}
+
+		// Multipart form streamed part by part. The file is not buffered, so all fields must precede the file part
+		r.Body = http.MaxBytesReader(w, r.Body, config.ImportMaxBytes)
+		reader, err := r.MultipartReader()
+		if err != nil {
+			errHandler.HandleError(w, "Request must be a multipart form with fields followed by the file part 'file'.", errHandler.BadRequest)
+			return
+		}
+
+		fields := map[string]string{}
+		var file io.Reader
+		for file == nil {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				errHandler.HandleError(w, "Request must be a multipart form with fields followed by the file part 'file'.", errHandler.BadRequest)
+				return
+			}
+			if part.FormName() == "file" {
+				file = part
+				continue
+			}
+			value, err := io.ReadAll(io.LimitReader(part, config.ImportFieldMaxBytes+1))
+			if err != nil || int64(len(value)) > config.ImportFieldMaxBytes {
+				errHandler.HandleError(w, "Form field '"+part.FormName()+"' is too large.", errHandler.BadRequest)
+				return
+			}
+			fields[part.FormName()] = strings.TrimSpace(string(value))
+		}
+		if file == nil {
+			errHandler.HandleError(w, "Missing file part 'file'. It must follow all other fields.", errHandler.BadRequest)
+			return
+		}
+
+		publicPlantID, hasPublicPlantID := fields["publicPlantID"]
+		if !hasPublicPlantID {
+			errHandler.HandleError(w, "Requested plant not found.", errHandler.BadRequest)
+			return
+		}
+
+		// Validate if plant with publicPlantID exists and if requesting user is authorized to import its history
+		plant, ok := findOwnedPlant(w, r, mongoDBInterface, "ImportPlantLogsValidation", publicPlantID)
+		if !ok {
+			return
+		}
+
+		var plantConfig model.PlantLoggerConfig
+		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNamePlantLoggerConfig, bson.M{"_id": plant.ID}, config.CollectionNamePlantLoggerConfig, bson.D{}, &plantConfig)
+		if err != nil || !findOne {
+			logger.GetLogger().Errorf("Error in 'ImportPlantLogsValidation()' using 'FindOneInMongo()' in collection '%s' part of database '%s' finding plant logger config of plant with id %s. Found: %t. Error: %v", config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, publicPlantID, findOne, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			return
+		}
+
+		// Validate column mapping, timestamp format and time zone against the plant's channel schema
+		options, err := importhandler.ParseImportOptions(fields, loggerhandler.EffectiveChannels(plantConfig))
+		if err != nil {
+			errHandler.HandleError(w, err.Error(), errHandler.BadRequest)
+			return
+		}
+
+		// Optional device all rows are tagged with. Must be part of the plant's device registry
+		if options.DeviceID != "" {
+			_, findOne, err := loggerhandler.FindDevice(r.Context(), mongoDBInterface, publicPlantID, options.DeviceID)
+			if err != nil {
+				logger.GetLogger().Errorf("Error in 'ImportPlantLogsValidation()' using 'FindDevice()'. Error: %v", err)
+				errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+				return
+			}
+			if !findOne {
+				errHandler.HandleError(w, loggerhandler.ErrDeviceIDInvalid.Error(), errHandler.BadRequest)
+				return
+			}
+		}
+
+		rows, err := importhandler.NewRowReader(file, options)
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			errHandler.HandleError(w, "Import file is too large.", errHandler.RequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			errHandler.HandleError(w, err.Error(), errHandler.BadRequest)
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), "plantLoggerConfig", plantConfig))
+		r = r.WithContext(context.WithValue(r.Context(), "importOptions", options))
+		r = r.WithContext(context.WithValue(r.Context(), "importRows", rows))
+
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
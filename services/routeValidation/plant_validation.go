@@ -2,14 +2,18 @@ package routevalidation
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	config "github.com/paulmuenzner/powerplantmanager/config"
 	model "github.com/paulmuenzner/powerplantmanager/models"
 	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	gapanalysis "github.com/paulmuenzner/powerplantmanager/services/gapAnalysis"
+	ingestpipeline "github.com/paulmuenzner/powerplantmanager/services/ingestPipeline"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	sunspecpoller "github.com/paulmuenzner/powerplantmanager/services/sunspecPoller"
 	arrayhandler "github.com/paulmuenzner/powerplantmanager/utils/array"
 	"github.com/paulmuenzner/powerplantmanager/utils/convert"
 	cookie "github.com/paulmuenzner/powerplantmanager/utils/cookies"
-	crypto "github.com/paulmuenzner/powerplantmanager/utils/crypto"
 	ip "github.com/paulmuenzner/powerplantmanager/utils/ip"
 	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
 	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
@@ -17,6 +21,7 @@ import (
 	typepackage "github.com/paulmuenzner/powerplantmanager/utils/type"
 	v "github.com/paulmuenzner/powerplantmanager/utils/validate"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
@@ -96,7 +101,7 @@ func AddPlantValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodI
 		}
 
 		// Check if plant name already in database. Each plant name must be unique
-		exists, _ := mongoDBInterface.RepositoryInterface.IsValueInCollection(config.DatabaseNameUserAuth, config.CollectionNamePhotovoltaicPlant, "name", data["name"].(string))
+		exists, _ := mongoDBInterface.RepositoryInterface.IsValueInCollection(r.Context(), config.DatabaseNameUserAuth, config.CollectionNamePhotovoltaicPlant, "name", data["name"].(string))
 		if exists {
 			errHandler.HandleError(w, "Please choose another plant name.", errHandler.BadRequest)
 			return
@@ -195,10 +200,10 @@ func SetKeySecretValidation(next http.HandlerFunc, mongoDBInterface *mongodb.Met
 		var sort bson.D = bson.D{}
 		database := config.DatabaseNamePlants
 		collection := config.CollectionNamePhotovoltaicPlant
-		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(database, filter, collection, sort, &plant)
+		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), database, filter, collection, sort, &plant)
 		if err != nil {
 			logger.GetLogger().Errorf("Error in 'SetKeySecretValidation()' using 'FindOneInMongo()' in collection '%s' part of database '%s' finding plant with id %s. Error: %v", collection, database, publicPlantID, err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 		if !findOne {
@@ -253,8 +258,9 @@ func SetPlantConfigValidation(next http.HandlerFunc, mongoDBInterface *mongodb.M
 			return
 		}
 
-		// Verify number of request values
-		if len(data) != 3 {
+		// Verify number of request values. Clock skew policy, authentication scheme, channel schema, poll targets, coordinates and retention period are optional
+		expectedKeys := loggerhandler.ExpectedReadingKeys(data, []string{"publicPlantID", "ipWhiteList", "intervalSec"}, []string{"clockSkewPolicy", "authScheme", "channels", "pollTargets", "coordinates", "retentionDays"})
+		if len(data) != len(expectedKeys) {
 			logger.GetLogger().Warn("To many request values in 'SetPlantConfigValidation()'. Number: ", len(data), "Content: ", data)
 			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
 			return
@@ -285,7 +291,6 @@ func SetPlantConfigValidation(next http.HandlerFunc, mongoDBInterface *mongodb.M
 		intervalSec := int(intervalSecRaw)
 
 		// Validate if request body exactly contains number and names of expected keys
-		expectedKeys := []string{"publicPlantID", "ipWhiteList", "intervalSec"}
 		validateKeys := v.Validate(data).
 			HasMapExactKeys(expectedKeys).
 			GetResult()
@@ -318,6 +323,58 @@ func SetPlantConfigValidation(next http.HandlerFunc, mongoDBInterface *mongodb.M
 			return
 		}
 
+		// Validate optional clock skew policy for measurement times provided by the logger
+		if rawClockSkewPolicy, hasClockSkewPolicy := data["clockSkewPolicy"]; hasClockSkewPolicy {
+			clockSkewPolicy, err := loggerhandler.ParseClockSkewPolicy(rawClockSkewPolicy)
+			if err != nil {
+				errHandler.HandleError(w, err.Error(), errHandler.BadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "clockSkewPolicy", clockSkewPolicy))
+		}
+
+		// Validate optional channel schema. Replaces the plant's channels, readings are validated against it
+		if rawChannels, hasChannels := data["channels"]; hasChannels {
+			channels, err := loggerhandler.ParseChannels(rawChannels)
+			if err != nil {
+				errHandler.HandleError(w, err.Error(), errHandler.BadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "channels", channels))
+		}
+
+		// Validate optional coordinates of the plant. Used to exclude night from gap analysis
+		if rawCoordinates, hasCoordinates := data["coordinates"]; hasCoordinates {
+			coordinates, err := gapanalysis.ParseCoordinates(rawCoordinates)
+			if err != nil {
+				errHandler.HandleError(w, err.Error(), errHandler.BadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "coordinates", coordinates))
+		}
+
+		// Validate optional retention period. Older readings are archived to the S3 bucket and deleted
+		if rawRetentionDays, hasRetentionDays := data["retentionDays"]; hasRetentionDays {
+			retentionDays, err := loggerhandler.ParseRetentionDays(rawRetentionDays)
+			if err != nil {
+				errHandler.HandleError(w, err.Error(), errHandler.BadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "retentionDays", retentionDays))
+		}
+
+		// Validate optional SunSpec poll targets. Replaces the plant's poll targets, an empty array disables polling
+		var pollTargets []model.PollTarget
+		rawPollTargets, hasPollTargets := data["pollTargets"]
+		if hasPollTargets {
+			parsedPollTargets, err := sunspecpoller.ParsePollTargets(rawPollTargets)
+			if err != nil {
+				errHandler.HandleError(w, err.Error(), errHandler.BadRequest)
+				return
+			}
+			pollTargets = parsedPollTargets
+		}
+
 		////////////////////////////////////////////////////////////////////////////////
 		// Validate if plant with publicPlantID exists and if requesting user is authorized to access and update its config
 		// Extract data from JWT in cookie
@@ -339,10 +396,10 @@ func SetPlantConfigValidation(next http.HandlerFunc, mongoDBInterface *mongodb.M
 		var sort bson.D = bson.D{}
 		collection := config.CollectionNamePhotovoltaicPlant
 		database := config.DatabaseNamePlants
-		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(database, filter, collection, sort, &plant)
+		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), database, filter, collection, sort, &plant)
 		if err != nil {
 			logger.GetLogger().Errorf("Error in 'SetPlantConfigValidation()' using 'FindOneInMongo()' in collection '%s' part of database '%s' finding plant with id %s. Error: %v", collection, database, publicPlantID, err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 		if !findOne {
@@ -359,6 +416,50 @@ func SetPlantConfigValidation(next http.HandlerFunc, mongoDBInterface *mongodb.M
 			return
 		}
 
+		// Devices of poll targets must be part of the plant's device registry
+		if hasPollTargets {
+			for _, target := range pollTargets {
+				if target.DeviceID == "" {
+					continue
+				}
+				_, findOne, err := loggerhandler.FindDevice(r.Context(), mongoDBInterface, publicPlantID, target.DeviceID)
+				if err != nil {
+					logger.GetLogger().Errorf("Error in 'SetPlantConfigValidation()' using 'FindDevice()'. Error: %v", err)
+					errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+					return
+				}
+				if !findOne {
+					errHandler.HandleError(w, fmt.Sprintf("Device '%s' of poll target not found for this plant.", target.DeviceID), errHandler.BadRequest)
+					return
+				}
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "pollTargets", pollTargets))
+		}
+
+		// Validate optional authentication scheme of plant logging API. Signed requests (hmac) need a signing key, created with each new key and secret
+		if rawAuthScheme, hasAuthScheme := data["authScheme"]; hasAuthScheme {
+			authScheme, authSchemeValid := rawAuthScheme.(string)
+			if !authSchemeValid || (authScheme != model.AuthSchemeSecret && authScheme != model.AuthSchemeHMAC && authScheme != model.AuthSchemeBoth) {
+				errHandler.HandleError(w, fmt.Sprintf("'authScheme' must be '%s', '%s' or '%s'.", model.AuthSchemeSecret, model.AuthSchemeHMAC, model.AuthSchemeBoth), errHandler.BadRequest)
+				return
+			}
+
+			if authScheme != model.AuthSchemeSecret {
+				var plantLoggerConfig model.PlantLoggerConfig
+				_, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNamePlantLoggerConfig, bson.M{"_id": plant.ID}, config.CollectionNamePlantLoggerConfig, bson.D{}, &plantLoggerConfig)
+				if err != nil {
+					logger.GetLogger().Errorf("Error in 'SetPlantConfigValidation()' using 'FindOneInMongo()' in collection '%s' part of database '%s' finding plant logger config of plant with id %s. Error: %v", config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, publicPlantID, err)
+					errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+					return
+				}
+				if plantLoggerConfig.SigningKey == "" {
+					errHandler.HandleError(w, "Signed requests need a signing key. Please create a new key and secret first.", errHandler.BadRequest)
+					return
+				}
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "authScheme", authScheme))
+		}
+
 		// Call the next handler if validation passes
 		next.ServeHTTP(w, r)
 	}
@@ -412,195 +513,304 @@ func AddPlantLogValidation(next http.HandlerFunc, mongoDBInterface *mongodb.Meth
 			return
 		}
 
-		// Verify number of request values
-		if len(data) != 10 {
-			logger.GetLogger().Warn("Not correct number of request values in 'AddPlantLogValidation'. Number: ", len(data), " Content: ", data)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		////////////////////////////////////////////////////////////////////////////////
+		// Validate existence of public_plant_id and access permission
+		//
+		// Find plant by provided key and validate permission with url id, ip whitelist and secret or signature
+		// Authentication comes first, as the expected request values depend on the plant's channel schema
+		plantConfig, device, key, authenticated := authenticatePlantLogger(w, r, data, mongoDBInterface, "AddPlantLogValidation", apiID, normalizedIP)
+		if !authenticated {
 			return
 		}
 
-		// Validate if request body exactly contains number and names of expected keys
-		expectedKeys := []string{"key", "secret", "voltageOutput", "currentOutput", "powerOutput", "solarRadiation", "tAmbient", "tModule", "relHumidity", "windSpeed"}
-		validateKeys := v.Validate(data).
-			HasMapExactKeys(expectedKeys).
-			GetResult()
-
-		if len(validateKeys) > 0 {
-			errHandler.HandleError(w, neutralResponseErr, errHandler.BadRequest)
+		////////////////////////////////////////////////////////////////////////////////
+		// VALIDATE READING
+		//
+		// Channel schema, measurement time, device, idempotency, clock skew policy and rate limit. Shared with the MQTT bridge
+		plantLog, isDuplicate, err := loggerhandler.ValidateLogEntry(r.Context(), mongoDBInterface, plantConfig, device, data, loggerCredentialKeys(r), "log", time.Now())
+		var rejection *loggerhandler.ReadingRejection
+		if errors.As(err, &rejection) {
+			// Only keys are logged. Values of legacy loggers contain the secret
+			logger.GetLogger().Warnf("Request with ip %s for plant with key %s in validator 'AddPlantLogValidation()' rejected. Keys: %v. Reason: %s", normalizedIP, key, mapKeys(data), rejection.Reason)
+			errHandler.HandleError(w, rejection.Reason, errHandler.BadRequest)
 			return
 		}
-
-		// Define and check key
-		key, keyValid := data["key"].(string)
-		if !keyValid {
-			logger.GetLogger().Error("Cannot convert key in 'AddPlantLogValidation' to string. Value: ", key)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'AddPlantLogValidation()' using 'ValidateLogEntry()'. Error: %v", err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 
-		// Define and check secret
-		secret, secretValid := data["secret"].(string)
-		if !secretValid {
-			logger.GetLogger().Error("Cannot convert secret in 'AddPlantLogValidation' to string. Value: ", secret)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		// A retry of an already stored reading is answered like the original request without storing it again
+		if isDuplicate {
+			r = r.WithContext(context.WithValue(r.Context(), "plantLogDuplicate", true))
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		// Define and check voltageOutput
-		voltageOutput, voltageOutputValid := data["voltageOutput"].(float64)
-		if !voltageOutputValid {
-			logger.GetLogger().Error("Cannot convert voltageOutput in 'AddPlantLogValidation' to float64. Value: ", voltageOutput)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
-			return
-		}
+		// Attach plant logger config and validated log to context
+		r = r.WithContext(context.WithValue(r.Context(), "plantLoggerConfig", plantConfig))
+		r = r.WithContext(context.WithValue(r.Context(), "plantLog", plantLog))
 
-		// Define and check currentOutput
-		currentOutput, currentOutputValid := data["currentOutput"].(float64)
-		if !currentOutputValid {
-			logger.GetLogger().Error("Cannot convert currentOutput in 'AddPlantLogValidation' to float64. Value: ", currentOutput)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
-			return
-		}
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////////
+// ADD PLANT LOG BATCH
+// ///////////////////
+func AddPlantLogBatchValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodInterface, ingestPipeline *ingestpipeline.Pipeline) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		neutralResponseErr := "Access is currently unavailable due to an internal github.com/paulmuenzner/powerplantmanager error. Our technical team has been notified and is actively addressing the issue."
 
-		// Define and check power output (powerOutput)
-		powerOutput, powerOutputValid := data["powerOutput"].(float64)
-		if !powerOutputValid {
-			logger.GetLogger().Error("Cannot convert powerOutput in 'AddPlantLogValidation' to float64. Value: ", powerOutput)
+		// No cookie validation for this route needed. Validation is realized via ip whitelist, url id and as part of request body: key and secret
+		// Key, secret, url id and ip are validated once for the whole batch. Each reading is validated separately afterwards
+
+		//////////////////////////////////////////////
+		// REQUEST BODY VALIDATION ///////////////////
+		//
+		// Access the parsed JSON data from the context
+		data, ok := r.Context().Value("requestBody").(map[string]interface{})
+		if !ok {
+			logger.GetLogger().Errorf("Error in 'AddPlantLogBatchValidation()'. Cannot parse requestBody. Request: %+v", r)
 			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
 			return
 		}
 
-		// Define and check solar radiation (solarRadiation)
-		solarRadiation, solarRadiationValid := data["solarRadiation"].(float64)
-		if !solarRadiationValid {
-			logger.GetLogger().Error("Cannot convert solarRadiation in 'AddPlantLogValidation' to float64. Value: ", solarRadiation)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		//////////////////////////////////////////////
+		// VALIDATE URL ID ///////////////////////////
+		//
+		// Access URL ID called 'apiID'
+		vars := mux.Vars(r)
+		apiID := vars["apiID"]
+
+		//////////////////////////////////////////////
+		// VALIDATE SOURCE IP ////////////////////////
+		//
+		// Only IPs in white list can submit plant logs
+		clientIP, err := ip.ExtractIP(r)
+		if err != nil {
+			logger.GetLogger().Error("Error clientIP in 'AddPlantLogBatchValidation()'. Error: ", err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.Unauthorized)
 			return
 		}
 
-		// Define and check ambient temperature (tAmbient)
-		tAmbient, tAmbientValid := data["tAmbient"].(float64)
-		if !tAmbientValid {
-			logger.GetLogger().Error("Cannot convert tAmbient in 'AddPlantLogValidation' to float64. Value: ", tAmbient)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		// Normalize compressed IP address to enable faultless comparisons of requesting IP addresses with ipWhitelist
+		normalizedIP, err := ip.NormalizeIP(clientIP)
+		if err != nil {
+			logger.GetLogger().Error("Error normalizing clientIP in 'AddPlantLogBatchValidation()' using 'NormalizeIP()'. Error: ", err, " IP address: ", clientIP)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.Unauthorized)
 			return
 		}
 
-		// Define and check Temperature of the module (tModule)
-		tModule, tModuleValid := data["tModule"].(float64)
-		if !tModuleValid {
-			logger.GetLogger().Error("Cannot convert tModule in 'AddPlantLogValidation' to float64. Value: ", tModule)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		// Validate if request body exactly contains number and names of expected keys. Reporting device is optional
+		expectedKeys := loggerhandler.ExpectedReadingKeys(data, append(loggerCredentialKeys(r), "readings"), []string{"deviceID"})
+		validateKeys := v.Validate(data).
+			HasMapExactKeys(expectedKeys).
+			GetResult()
+
+		if len(validateKeys) > 0 {
+			errHandler.HandleError(w, neutralResponseErr, errHandler.BadRequest)
 			return
 		}
 
-		// Define and check relative humidity (relHumidity)
-		relativeHumidity, relativeHumidityValid := data["relHumidity"].(float64)
-		if !relativeHumidityValid {
-			logger.GetLogger().Error("Cannot convert relHumidity in 'AddPlantLogValidation()' to float64. Value: ", relativeHumidity)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		// Define and check readings
+		readings, readingsValid := data["readings"].([]interface{})
+		if !readingsValid || len(readings) == 0 {
+			errHandler.HandleError(w, "Please provide buffered readings as non-empty array 'readings'.", errHandler.BadRequest)
 			return
 		}
-
-		// Define and check wind speed (windSpeed)
-		windSpeed, windSpeedValid := data["windSpeed"].(float64)
-		if !windSpeedValid {
-			logger.GetLogger().Error("Cannot convert windSpeed in 'AddPlantLogValidation' to float64. Value: ", windSpeed)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		if len(readings) > config.LogBatchMaxReadings {
+			errHandler.HandleError(w, "Maximum number of readings per batch: "+strconv.Itoa(config.LogBatchMaxReadings), errHandler.RequestEntityTooLarge)
 			return
 		}
 
 		////////////////////////////////////////////////////////////////////////////////
 		// Validate existence of public_plant_id and access permission
 		//
-		// Find plant by provided key and validate permission with ip whitelist and secret
-		var filter bson.M = bson.M{"key": key}
-		var plantConfig model.PlantLoggerConfig
-		var sort bson.D = bson.D{}
-		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(config.DatabaseNamePlantLoggerConfig, filter, config.CollectionNamePlantLoggerConfig, sort, &plantConfig)
-		if err != nil {
-			logger.GetLogger().Errorf("Error in 'AddPlantLogValidation()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s' for plant logging key %s and provided secret %s. Error: %v", config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, key, secret, err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
-			return
-		}
-		if !findOne {
-			log := "User with ip " + normalizedIP + " requested non-existing plant with key " + key + " in validator 'AddPlantLogValidation'."
-			logger.GetLogger().Error(log)
-			errHandler.HandleError(w, "Requested plant not found or no permission.", errHandler.BadRequest)
+		// Find plant by provided key and validate permission with url id, ip whitelist and secret or signature
+		plantConfig, device, key, authenticated := authenticatePlantLogger(w, r, data, mongoDBInterface, "AddPlantLogBatchValidation", apiID, normalizedIP)
+		if !authenticated {
 			return
 		}
 
-		// Validate url id
-		isURLIDValid := plantConfig.URLID == apiID
-		if !isURLIDValid {
-			logger.GetLogger().Warnf("Not valid url_id '%s' detected in 'AddPlantLogValidation()' logging plant data. Public plant id: %v", apiID, plantConfig.PublicPlantID)
-			errHandler.HandleError(w, "URL not valid.", errHandler.BadRequest)
+		// Define and check reporting device of all readings
+		deviceID, ok := resolveReadingDevice(r.Context(), w, mongoDBInterface, plantConfig, device, data, "AddPlantLogBatchValidation")
+		if !ok {
 			return
 		}
 
-		// Validate secret
-		isSecretValid := crypto.IsHashValid(secret, plantConfig.Secret)
-		if !isSecretValid {
-			log := "Request with ip " + normalizedIP + " requested existing plant with key " + key + " in validator 'AddPlantLogValidation' by providing wrong secret."
-			logger.GetLogger().Error(log, err)
-			errHandler.HandleError(w, "Requested plant not found or no permission.", errHandler.BadRequest)
+		////////////////////////////////////////////////////////////////////////////////
+		// VALIDATE READINGS
+		//
+		// Get stored readings of the reporting device within the logging interval of the batch. Readings too close to them are rejected (rate limit)
+		receivedAt := time.Now()
+		collectionNameLogger := plantConfig.CollectionNameLogger
+		first, last := loggerhandler.BatchTimeRange(readings, receivedAt)
+		storedTimes, err := loggerhandler.FindLogTimesNear(r.Context(), mongoDBInterface, collectionNameLogger, deviceID, first, last, plantConfig.IntervalSec)
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'AddPlantLogBatchValidation()' using 'FindLogTimesNear()' in collection '%s' part of database '%s' for plant logging key %s. Error: %v", collectionNameLogger, config.DatabaseNamePlantLogger, key, err)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 
-		// Validate ip against white list
-		isIPValid := false
-		for _, ip := range plantConfig.IPWhitelist {
-			if ip == normalizedIP {
-				isIPValid = true
+		// Get already stored readings sharing sequence number or idempotency key with the batch (retries)
+		storedIdentities := loggerhandler.ReadingIdentitySet{}
+		if identityFilter := loggerhandler.ReadingIdentityFilter(loggerhandler.ParseBatchReadingIdentities(readings, deviceID)...); identityFilter != nil {
+			if err := loggerhandler.EnsureIdempotencyIndexes(r.Context(), mongoDBInterface, collectionNameLogger); err != nil {
+				logger.GetLogger().Errorf("Error in 'AddPlantLogBatchValidation()' using 'EnsureIdempotencyIndexes()' for collection '%s'. Error: %v", collectionNameLogger, err)
+				errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+				return
+			}
+
+			var storedLogs []model.PlantLogger
+			err = mongoDBInterface.RepositoryInterface.FindManyInMongo(r.Context(), config.DatabaseNamePlantLogger, identityFilter, collectionNameLogger, bson.D{}, &storedLogs)
+			if err != nil {
+				logger.GetLogger().Errorf("Error in 'AddPlantLogBatchValidation()' using 'FindManyInMongo()' looking up sequence numbers and idempotency keys in collection '%s' part of database '%s'. Error: %v", collectionNameLogger, config.DatabaseNamePlantLogger, err)
+				errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+				return
 			}
+			storedIdentities = loggerhandler.NewReadingIdentitySet(storedLogs)
 		}
 
-		if !isIPValid {
-			log := "Request with not whitelisted ip " + normalizedIP + " requested existing plant with key " + key + " in validator 'AddPlantLogValidation'."
-			logger.GetLogger().Error(log)
-			errHandler.HandleError(w, "Requested plant not found or no permission.", errHandler.BadRequest)
-			return
+		// Readings accepted by the ingestion pipeline but not stored yet count alike
+		pendingTimes, pendingIdentities := ingestPipeline.PendingReadings(collectionNameLogger, deviceID)
+		storedTimes = append(storedTimes, pendingTimes...)
+		sort.Slice(storedTimes, func(i, j int) bool { return storedTimes[i].Before(storedTimes[j]) })
+		for identity := range pendingIdentities {
+			storedIdentities[identity] = true
 		}
 
-		////////////////////////////////////////////////////////////////////////////////
-		// RATE LIMIT
-		//
-		// Validate time interval to prevent spamming (rate limiter)
-		// Get latest entry from logger
-		filter = bson.M{}
-		var sort2 bson.D = bson.D{{Key: "created_at", Value: -1}}
-		var plantLogger model.PlantLogger
-		collectionNameLogger := plantConfig.CollectionNameLogger
-		findOne, err = mongoDBInterface.RepositoryInterface.FindOneInMongo(config.DatabaseNamePlantLogger, filter, collectionNameLogger, sort2, &plantLogger)
-		if err != nil {
-			logger.GetLogger().Errorf("Error in 'AddPlantLogValidation()' using 'FindOneInMongo()' retrieving latest entry in collection '%s' part of database '%s' for plant logging key %s and provided secret %s. Error: %v", collectionNameLogger, config.DatabaseNamePlantLogger, key, secret, err)
+		logBatch := loggerhandler.ValidateLogBatch(readings, plantConfig, deviceID, storedTimes, receivedAt, storedIdentities)
+
+		// Attach validated batch and plant logger collection to context
+		r = r.WithContext(context.WithValue(r.Context(), "collectionNameLogger", collectionNameLogger))
+		r = r.WithContext(context.WithValue(r.Context(), "plantLogBatch", logBatch))
+
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
+
+// loggerCredentialKeys returns the request body keys carrying the logger credentials. Signed requests provide them by header
+func loggerCredentialKeys(r *http.Request) []string {
+	if loggerhandler.IsSignedRequest(r) {
+		return []string{}
+	}
+	return []string{"key", "secret"}
+}
+
+// authenticatePlantLogger authenticates a plant logger either by signature headers or, for legacy loggers, by key and secret of the request body.
+// Responds to the request on failure. Returns the plant logger config, the device if authenticated with device credentials, the logger key and if the logger has been authenticated.
+func authenticatePlantLogger(w http.ResponseWriter, r *http.Request, data map[string]interface{}, mongoDBInterface *mongodb.MethodInterface, validatorName, apiID, normalizedIP string) (model.PlantLoggerConfig, model.PlantDevice, string, bool) {
+	neutralResponseErr := "Access is currently unavailable due to an internal github.com/paulmuenzner/powerplantmanager error. Our technical team has been notified and is actively addressing the issue."
+
+	// Signed request. Key, timestamp, nonce and signature are provided by header
+	if loggerhandler.IsSignedRequest(r) {
+		rawBody, ok := r.Context().Value("requestBodyRaw").([]byte)
+		if !ok {
+			logger.GetLogger().Errorf("Error in '%s()'. Cannot access raw request body to verify signature.", validatorName)
 			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
-			return
+			return model.PlantLoggerConfig{}, model.PlantDevice{}, "", false
 		}
-		if !findOne {
-			logger.GetLogger().Errorf("User with ip %s requested non-existing plant with key %s and secret %s in validator 'AddPlantLogValidation()'.", normalizedIP, key, secret)
-			errHandler.HandleError(w, "Requested plant not found or no permission.", errHandler.BadRequest)
-			return
+		plantConfig, device, key, err := loggerhandler.AuthenticateSignedLogger(mongoDBInterface, r, rawBody, apiID, normalizedIP, time.Now())
+		if err != nil {
+			if !handleLoggerAuthenticationError(w, err, validatorName, key, apiID, normalizedIP, plantConfig) {
+				errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+			}
+			return plantConfig, device, key, false
 		}
+		return plantConfig, device, key, true
+	}
 
-		logInterval := plantConfig.IntervalSec - 60 // Deduct 60 seconds as security buffer
-		dateLatestEntry := plantLogger.CreatedAt
-		datePastMinimum := time.Now().Add(-time.Second * time.Duration(logInterval))
+	// Legacy. Define and check key and secret of request body. The secret is never logged
+	key, keyValid := data["key"].(string)
+	if !keyValid {
+		logger.GetLogger().Errorf("Cannot convert key in '%s()' to string. Value: %v", validatorName, data["key"])
+		errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		return model.PlantLoggerConfig{}, model.PlantDevice{}, "", false
+	}
+	secret, secretValid := data["secret"].(string)
+	if !secretValid {
+		logger.GetLogger().Errorf("Cannot convert secret in '%s()' to string.", validatorName)
+		errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+		return model.PlantLoggerConfig{}, model.PlantDevice{}, key, false
+	}
 
-		// Check if the duration is within allowed seconds
-		if datePastMinimum.Before(dateLatestEntry) {
-			log := "User with ip " + normalizedIP + " requested plant with key " + key + " in validator 'AddPlantLogValidation' and tried to log to often. Minimum required interval (rate limit): " + strconv.Itoa(plantConfig.IntervalSec)
-			logger.GetLogger().Error(log, err)
-			errHandler.HandleError(w, "No permission to save new log. Minimum time difference between logs in seconds: "+strconv.Itoa(plantConfig.IntervalSec), errHandler.BadRequest)
-			return
+	plantConfig, device, err := loggerhandler.AuthenticateLogger(r.Context(), mongoDBInterface, key, secret, apiID, normalizedIP)
+	if err != nil {
+		if !handleLoggerAuthenticationError(w, err, validatorName, key, apiID, normalizedIP, plantConfig) {
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 		}
+		return plantConfig, device, key, false
+	}
+	return plantConfig, device, key, true
+}
 
-		// Attach plantConfig to context
-		r = r.WithContext(context.WithValue(r.Context(), "collectionNameLogger", plantConfig.CollectionNameLogger))
+// resolveReadingDevice determines the reporting device of a plant log request, see 'ResolveReadingDevice()'.
+// Responds to the request on failure. Returns the device id, empty if reported for the plant as a whole, and if the device is valid.
+func resolveReadingDevice(ctx context.Context, w http.ResponseWriter, mongoDBInterface *mongodb.MethodInterface, plantConfig model.PlantLoggerConfig, device model.PlantDevice, data map[string]interface{}, validatorName string) (string, bool) {
+	neutralResponseErr := "Access is currently unavailable due to an internal github.com/paulmuenzner/powerplantmanager error. Our technical team has been notified and is actively addressing the issue."
+
+	deviceID, err := loggerhandler.ResolveReadingDevice(ctx, mongoDBInterface, plantConfig, device, data)
+	switch {
+	case errors.Is(err, loggerhandler.ErrDeviceIDInvalid), errors.Is(err, loggerhandler.ErrDeviceMismatch):
+		logger.GetLogger().Warnf("Request for plant with public plant id '%s' in validator '%s()' rejected. Error: %v", plantConfig.PublicPlantID, validatorName, err)
+		errHandler.HandleError(w, err.Error(), errHandler.BadRequest)
+		return "", false
+	case err != nil:
+		logger.GetLogger().Errorf("Error in '%s()' using 'ResolveReadingDevice()'. Error: %v", validatorName, err)
+		errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+		return "", false
+	}
+	return deviceID, true
+}
 
-		// Call the next handler if validation passes
-		next.ServeHTTP(w, r)
+// mapKeys returns the keys of the parsed request body, eg. for logging requests without revealing their values
+func mapKeys(data map[string]interface{}) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// handleLoggerAuthenticationError logs and responds to a failed plant logger authentication.
+// Returns false, without writing a response, if the error is not caused by the request (eg. database error).
+func handleLoggerAuthenticationError(w http.ResponseWriter, err error, validatorName, key, apiID, normalizedIP string, plantConfig model.PlantLoggerConfig) bool {
+	switch {
+	case errors.Is(err, loggerhandler.ErrLoggerNotFound):
+		logger.GetLogger().Errorf("User with ip %s requested non-existing plant with key %s in validator '%s()'.", normalizedIP, key, validatorName)
+		errHandler.HandleError(w, "Requested plant not found or no permission.", errHandler.BadRequest)
+	case errors.Is(err, loggerhandler.ErrURLIDInvalid):
+		logger.GetLogger().Warnf("Not valid url_id '%s' detected in '%s()' logging plant data. Public plant id: %v", apiID, validatorName, plantConfig.PublicPlantID)
+		errHandler.HandleError(w, "URL not valid.", errHandler.BadRequest)
+	case errors.Is(err, loggerhandler.ErrSecretInvalid):
+		logger.GetLogger().Errorf("Request with ip %s requested existing plant with key %s in validator '%s()' by providing wrong secret.", normalizedIP, key, validatorName)
+		errHandler.HandleError(w, "Requested plant not found or no permission.", errHandler.BadRequest)
+	case errors.Is(err, loggerhandler.ErrIPNotWhitelisted):
+		logger.GetLogger().Errorf("Request with not whitelisted ip %s requested existing plant with key %s in validator '%s()'.", normalizedIP, key, validatorName)
+		errHandler.HandleError(w, "Requested plant not found or no permission.", errHandler.BadRequest)
+	case errors.Is(err, loggerhandler.ErrSchemeNotAllowed):
+		logger.GetLogger().Warnf("Request with ip %s for plant with key %s in validator '%s()' used authentication scheme not enabled for plant. Enabled: '%s'", normalizedIP, key, validatorName, plantConfig.AuthScheme)
+		errHandler.HandleError(w, "Authentication scheme not enabled for this plant.", errHandler.Unauthorized)
+	case errors.Is(err, loggerhandler.ErrSignatureHeadersInvalid):
+		errHandler.HandleError(w, "Signed requests require the headers "+loggerhandler.HeaderPlantKey+", "+loggerhandler.HeaderPlantTimestamp+", "+loggerhandler.HeaderPlantNonce+" and "+loggerhandler.HeaderPlantSignature+".", errHandler.BadRequest)
+	case errors.Is(err, loggerhandler.ErrSignatureExpired):
+		logger.GetLogger().Warnf("Request with ip %s for plant with key %s in validator '%s()' with stale signature timestamp.", normalizedIP, key, validatorName)
+		errHandler.HandleError(w, "Request timestamp expired. Please check the logger clock.", errHandler.Unauthorized)
+	case errors.Is(err, loggerhandler.ErrSignatureInvalid):
+		logger.GetLogger().Errorf("Request with ip %s requested existing plant with key %s in validator '%s()' by providing invalid signature.", normalizedIP, key, validatorName)
+		errHandler.HandleError(w, "Requested plant not found or no permission.", errHandler.Unauthorized)
+	case errors.Is(err, loggerhandler.ErrNonceReused):
+		logger.GetLogger().Errorf("Request with ip %s for plant with key %s in validator '%s()' reused nonce. Potential replay.", normalizedIP, key, validatorName)
+		errHandler.HandleError(w, "Nonce already used.", errHandler.Unauthorized)
+	default:
+		logger.GetLogger().Errorf("Error in '%s()' using 'AuthenticateLogger()'. Error: %v", validatorName, err)
+		return false
 	}
+	return true
 }
 
 // /////////////////////////////////////////////////////////////////////////////////////////////
@@ -681,10 +891,10 @@ func DeletePlantValidation(next http.HandlerFunc, mongoDBInterface *mongodb.Meth
 		var filter bson.M = bson.M{"public_plant_id": publicPlantID}
 		var plantLoggerConfig model.PlantLoggerConfig
 		var sort bson.D = bson.D{}
-		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(config.DatabaseNamePlantLoggerConfig, filter, config.CollectionNamePlantLoggerConfig, sort, &plantLoggerConfig)
+		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNamePlantLoggerConfig, filter, config.CollectionNamePlantLoggerConfig, sort, &plantLoggerConfig)
 		if err != nil {
 			logger.GetLogger().Errorf("Error in 'DeletePlantValidation()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s' finding plant logger for plant ID %s by user ID %s. Error: %v", config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, publicPlantID, userID, err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 		if !findOne {
@@ -696,10 +906,10 @@ func DeletePlantValidation(next http.HandlerFunc, mongoDBInterface *mongodb.Meth
 		// Find user_id in Plant to compare with userId in cookie
 		filter = bson.M{"_id": plantLoggerConfig.ID} // prepare correctness
 		var plant model.PhotovoltaicPlant
-		findOne, err = mongoDBInterface.RepositoryInterface.FindOneInMongo(config.DatabaseNamePlants, filter, config.CollectionNamePhotovoltaicPlant, sort, &plant)
+		findOne, err = mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNamePlants, filter, config.CollectionNamePhotovoltaicPlant, sort, &plant)
 		if err != nil {
 			logger.GetLogger().Errorf("Error in 'DeletePlantValidation()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s' for plant ID %s by user ID %s. Error: %v", config.CollectionNamePhotovoltaicPlant, config.DatabaseNamePlants, publicPlantID, userID, err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 		if !findOne {
@@ -752,8 +962,9 @@ func GetPlantStatisticsValidation(next http.HandlerFunc, mongoDBInterface *mongo
 			return
 		}
 
-		// Verify number of request values
-		if len(data) != 3 {
+		// Verify number of request values. Channels to analyze, device, grouping by device and including voided readings are optional
+		expectedKeys := loggerhandler.ExpectedReadingKeys(data, []string{"publicPlantID", "dateStart", "dateEnd"}, []string{"channels", "deviceID", "groupByDevice", "includeVoided"})
+		if len(data) != len(expectedKeys) {
 			logger.GetLogger().Warn("To many request values in 'GetPlantStatisticsValidation'. Number: ", len(data))
 			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
 			return
@@ -767,7 +978,6 @@ func GetPlantStatisticsValidation(next http.HandlerFunc, mongoDBInterface *mongo
 		}
 
 		// Validate if request body exactly contains number and names of expected keys
-		expectedKeys := []string{"publicPlantID", "dateStart", "dateEnd"}
 		validateKeys := v.Validate(data).HasMapExactKeys(expectedKeys).
 			GetResult()
 
@@ -799,10 +1009,10 @@ func GetPlantStatisticsValidation(next http.HandlerFunc, mongoDBInterface *mongo
 		var filterA bson.M = bson.M{"public_plant_id": publicPlantID}
 		var plantLoggerConfig model.PlantLoggerConfig
 		var sort bson.D = bson.D{}
-		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(config.DatabaseNamePlantLoggerConfig, filterA, config.CollectionNamePlantLoggerConfig, sort, &plantLoggerConfig)
+		findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNamePlantLoggerConfig, filterA, config.CollectionNamePlantLoggerConfig, sort, &plantLoggerConfig)
 		if err != nil {
 			logger.GetLogger().Errorf("Error in 'GetPlantStatisticsValidation()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s' for plant ID %s by user ID %s. Error: %v", config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, publicPlantID, userID, err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 		if !findOne {
@@ -814,10 +1024,10 @@ func GetPlantStatisticsValidation(next http.HandlerFunc, mongoDBInterface *mongo
 		// Find user_id in Plant to compare with userId in cookie
 		var filter bson.M = bson.M{"_id": plantLoggerConfig.ID}
 		var plant model.PhotovoltaicPlant
-		findOne, err = mongoDBInterface.RepositoryInterface.FindOneInMongo(config.DatabaseNamePlants, filter, config.CollectionNamePhotovoltaicPlant, sort, &plant)
+		findOne, err = mongoDBInterface.RepositoryInterface.FindOneInMongo(r.Context(), config.DatabaseNamePlants, filter, config.CollectionNamePhotovoltaicPlant, sort, &plant)
 		if err != nil {
 			logger.GetLogger().Errorf("Error in 'GetPlantStatisticsValidation()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s' for plant logger ID %s by user ID %s. Error: %v", config.CollectionNamePhotovoltaicPlant, config.DatabaseNamePlants, plantLoggerConfig.ID, userID, err)
-			errHandler.HandleError(w, neutralResponseErr, errHandler.InternalServerError)
+			errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
 			return
 		}
 		if !findOne {
@@ -835,7 +1045,70 @@ func GetPlantStatisticsValidation(next http.HandlerFunc, mongoDBInterface *mongo
 			return
 		}
 
+		// Validate optional channels to analyze. Each must be defined in the plant's channel schema
+		if rawChannels, hasChannels := data["channels"]; hasChannels {
+			requestedChannels, channelsValid := rawChannels.([]interface{})
+			if !channelsValid || len(requestedChannels) == 0 || len(requestedChannels) > config.StatisticsChannelsMax {
+				errHandler.HandleError(w, fmt.Sprintf("'channels' must be an array of 1 to %d channel names.", config.StatisticsChannelsMax), errHandler.BadRequest)
+				return
+			}
+			channels := loggerhandler.EffectiveChannels(plantLoggerConfig)
+			statisticsChannels := []string{}
+			for _, requestedChannel := range requestedChannels {
+				name, nameValid := requestedChannel.(string)
+				if _, defined := loggerhandler.FindChannel(channels, name); !nameValid || !defined {
+					errHandler.HandleError(w, fmt.Sprintf("Channel '%v' is not defined for this plant.", requestedChannel), errHandler.BadRequest)
+					return
+				}
+				statisticsChannels = append(statisticsChannels, name)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "statisticsChannels", statisticsChannels))
+		}
+
+		// Validate optional device to analyze. Readings of all devices are rolled up to the plant if missing
+		if rawDeviceID, hasDeviceID := data["deviceID"]; hasDeviceID {
+			deviceID, deviceIDValid := rawDeviceID.(string)
+			if !deviceIDValid {
+				errHandler.HandleError(w, "'deviceID' must be the id of a device of this plant.", errHandler.BadRequest)
+				return
+			}
+			_, found, err := loggerhandler.FindDevice(r.Context(), mongoDBInterface, publicPlantID, deviceID)
+			if err != nil {
+				logger.GetLogger().Errorf("Error in 'GetPlantStatisticsValidation()' using 'FindDevice()'. Error: %v", err)
+				errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+				return
+			}
+			if !found {
+				errHandler.HandleError(w, "'deviceID' must be the id of a device of this plant.", errHandler.BadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "statisticsDeviceID", deviceID))
+		}
+
+		// Validate optional grouping of statistics by device
+		if rawGroupByDevice, hasGroupByDevice := data["groupByDevice"]; hasGroupByDevice {
+			groupByDevice, groupByDeviceValid := rawGroupByDevice.(bool)
+			if !groupByDeviceValid {
+				errHandler.HandleError(w, "'groupByDevice' must be a boolean.", errHandler.BadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "statisticsGroupByDevice", groupByDevice))
+		}
+
+		// Validate optional including of readings voided by the owner. Excluded by default
+		if rawIncludeVoided, hasIncludeVoided := data["includeVoided"]; hasIncludeVoided {
+			includeVoided, includeVoidedValid := rawIncludeVoided.(bool)
+			if !includeVoidedValid {
+				errHandler.HandleError(w, "'includeVoided' must be a boolean.", errHandler.BadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "statisticsIncludeVoided", includeVoided))
+		}
+
 		r = r.WithContext(context.WithValue(r.Context(), "plantCollectionNameLogger", plantLoggerConfig.CollectionNameLogger))
+		// Logging interval and coordinates for the completeness of the readings analyzed
+		r = r.WithContext(context.WithValue(r.Context(), "plantLoggerConfig", plantLoggerConfig))
+		r = r.WithContext(context.WithValue(r.Context(), "plantRequest", plant))
 
 		// Call the next handler if validation passes
 		next.ServeHTTP(w, r)
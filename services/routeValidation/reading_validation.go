@@ -0,0 +1,294 @@
+package routevalidation
+
+import (
+	"context"
+	"fmt"
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	errHandler "github.com/paulmuenzner/powerplantmanager/services/errorHandler"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	rollup "github.com/paulmuenzner/powerplantmanager/services/rollup"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// /////////////////////////////////////////////////////////////////////////////////////////////
+// AMEND READINGS (VOID, UNVOID, ANNOTATE, CORRECT)
+// ///////////////////
+func AmendReadingsValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		neutralResponseErr := "We appologize. Amending readings currently not possible due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+		//////////////////////////////////////////////
+		// REQUEST BODY VALIDATION ///////////////////
+		//
+		// Readings are selected by id or by period, optionally of one device. Annotation and values depend on the action
+		data, plant, ok := validateDeviceRequest(w, r, mongoDBInterface, "AmendReadingsValidation", []string{"publicPlantID", "action", "reason"}, []string{"readingID", "dateStart", "dateEnd", "deviceID", "annotation", "values"})
+		if !ok {
+			return
+		}
+
+		// Validate action and reason
+		action, _ := data["action"].(string)
+		if !loggerhandler.IsAmendmentAction(action) {
+			errHandler.HandleError(w, "'action' must be one of: "+strings.Join(loggerhandler.AmendmentActions, ", ")+".", errHandler.BadRequest)
+			return
+		}
+		reason, reasonValid := data["reason"].(string)
+		if !reasonValid || strings.TrimSpace(reason) == "" || len(reason) > config.ReadingAmendmentTextMaxLength {
+			errHandler.HandleError(w, fmt.Sprintf("'reason' must be a non-empty string of at most %d characters.", config.ReadingAmendmentTextMaxLength), errHandler.BadRequest)
+			return
+		}
+		amendment := loggerhandler.Amendment{Action: action, Reason: reason}
+
+		plantLoggerConfig, ok := findPlantLoggerConfig(r.Context(), w, mongoDBInterface, "AmendReadingsValidation", plant)
+		if !ok {
+			return
+		}
+
+		// Validate annotation, only part of action 'annotate'
+		rawAnnotation, hasAnnotation := data["annotation"]
+		if hasAnnotation != (action == "annotate") {
+			errHandler.HandleError(w, "'annotation' is required for action 'annotate' and not allowed otherwise.", errHandler.BadRequest)
+			return
+		}
+		if hasAnnotation {
+			annotation, annotationValid := rawAnnotation.(string)
+			if !annotationValid || len(annotation) > config.ReadingAmendmentTextMaxLength {
+				errHandler.HandleError(w, fmt.Sprintf("'annotation' must be a string of at most %d characters. An empty string removes the annotation.", config.ReadingAmendmentTextMaxLength), errHandler.BadRequest)
+				return
+			}
+			amendment.Annotation = annotation
+		}
+
+		// Validate corrected values, only part of action 'correct'
+		rawValues, hasValues := data["values"]
+		if hasValues != (action == "correct") {
+			errHandler.HandleError(w, "'values' is required for action 'correct' and not allowed otherwise.", errHandler.BadRequest)
+			return
+		}
+		if hasValues {
+			values, err := loggerhandler.ParseCorrectedValues(rawValues, loggerhandler.EffectiveChannels(plantLoggerConfig))
+			if err != nil {
+				errHandler.HandleError(w, err.Error(), errHandler.BadRequest)
+				return
+			}
+			amendment.Values = values
+		}
+
+		// Validate selection of readings. Either one reading by id or all readings of a period. Corrections apply to single readings only
+		_, hasReadingID := data["readingID"]
+		_, hasDateStart := data["dateStart"]
+		_, hasDateEnd := data["dateEnd"]
+		_, hasDeviceID := data["deviceID"]
+		var filter bson.M
+		switch {
+		case hasReadingID && !hasDateStart && !hasDateEnd && !hasDeviceID:
+			readingIDString, _ := data["readingID"].(string)
+			readingID, err := primitive.ObjectIDFromHex(readingIDString)
+			if err != nil {
+				errHandler.HandleError(w, "'readingID' must be the id of a reading of this plant.", errHandler.BadRequest)
+				return
+			}
+			filter = bson.M{"_id": readingID}
+		case !hasReadingID && hasDateStart && hasDateEnd && action != "correct":
+			dateStartString, _ := data["dateStart"].(string)
+			dateEndString, _ := data["dateEnd"].(string)
+			dateStart, errStart := time.Parse(time.RFC3339Nano, dateStartString)
+			dateEnd, errEnd := time.Parse(time.RFC3339Nano, dateEndString)
+			if errStart != nil || errEnd != nil || !dateStart.Before(dateEnd) {
+				errHandler.HandleError(w, "'dateStart' and 'dateEnd' must be RFC3339 times, 'dateEnd' after 'dateStart'.", errHandler.BadRequest)
+				return
+			}
+			// Logs stored before measurement times were introduced only carry 'created_at'
+			timeRange := bson.M{"$gte": dateStart, "$lt": dateEnd}
+			filter = bson.M{
+				"$or": bson.A{
+					bson.M{"measured_at": timeRange},
+					bson.M{"measured_at": bson.M{"$exists": false}, "created_at": timeRange},
+				},
+			}
+			if hasDeviceID {
+				deviceID, deviceIDValid := data["deviceID"].(string)
+				if !deviceIDValid {
+					errHandler.HandleError(w, "'deviceID' must be the id of a device of this plant.", errHandler.BadRequest)
+					return
+				}
+				_, found, err := loggerhandler.FindDevice(r.Context(), mongoDBInterface, plant.PublicPlantID, deviceID)
+				if err != nil {
+					logger.GetLogger().Errorf("Error in 'AmendReadingsValidation()' using 'FindDevice()'. Error: %v", err)
+					errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+					return
+				}
+				if !found {
+					errHandler.HandleError(w, "'deviceID' must be the id of a device of this plant.", errHandler.BadRequest)
+					return
+				}
+				filter["device_id"] = deviceID
+			}
+		default:
+			errHandler.HandleError(w, "Select readings either by 'readingID' or by 'dateStart' and 'dateEnd' with optional 'deviceID'. Action 'correct' requires 'readingID'.", errHandler.BadRequest)
+			return
+		}
+		filter = loggerhandler.ExcludeRehydrated(filter)
+
+		r = r.WithContext(context.WithValue(r.Context(), "plantRequest", plant))
+		r = r.WithContext(context.WithValue(r.Context(), "plantLoggerConfig", plantLoggerConfig))
+		r = r.WithContext(context.WithValue(r.Context(), "amendment", amendment))
+		r = r.WithContext(context.WithValue(r.Context(), "amendmentFilter", filter))
+
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////////
+// GET AMENDMENT HISTORY OF READINGS
+// ///////////////////
+func GetReadingHistoryValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//////////////////////////////////////////////
+		// REQUEST BODY VALIDATION ///////////////////
+		//
+		// History of all readings of the plant or of one reading
+		data, plant, ok := validateDeviceRequest(w, r, mongoDBInterface, "GetReadingHistoryValidation", []string{"publicPlantID"}, []string{"readingID"})
+		if !ok {
+			return
+		}
+
+		plantLoggerConfig, ok := findPlantLoggerConfig(r.Context(), w, mongoDBInterface, "GetReadingHistoryValidation", plant)
+		if !ok {
+			return
+		}
+
+		if _, hasReadingID := data["readingID"]; hasReadingID {
+			readingIDString, _ := data["readingID"].(string)
+			readingID, err := primitive.ObjectIDFromHex(readingIDString)
+			if err != nil {
+				errHandler.HandleError(w, "'readingID' must be the id of a reading of this plant.", errHandler.BadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "historyReadingID", readingID))
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), "plantLoggerConfig", plantLoggerConfig))
+
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
+
+// /////////////////////////////////////////////////////////////////////////////////////////////
+// EXPORT READINGS (HOURLY OR DAILY AGGREGATES)
+// ///////////////////
+func ExportReadingsValidation(next http.HandlerFunc, mongoDBInterface *mongodb.MethodInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		neutralResponseErr := "We appologize. Export currently not available due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+
+		//////////////////////////////////////////////
+		// REQUEST BODY VALIDATION ///////////////////
+		//
+		// Resolution, device and grouping by device are optional
+		data, plant, ok := validateDeviceRequest(w, r, mongoDBInterface, "ExportReadingsValidation", []string{"publicPlantID", "dateStart", "dateEnd"}, []string{"resolution", "deviceID", "groupByDevice"})
+		if !ok {
+			return
+		}
+
+		// Validate resolution. Hourly if not requested otherwise
+		resolution := rollup.LevelHour
+		if rawResolution, hasResolution := data["resolution"]; hasResolution {
+			resolutionString, _ := rawResolution.(string)
+			resolution = rollup.Level(resolutionString)
+			if resolution != rollup.LevelHour && resolution != rollup.LevelDay {
+				errHandler.HandleError(w, "'resolution' must be 'hour' or 'day'.", errHandler.BadRequest)
+				return
+			}
+		}
+
+		// Validate period
+		maxDays := config.ExportHourlyMaxDays
+		if resolution == rollup.LevelDay {
+			maxDays = config.ExportDailyMaxDays
+		}
+		dateStartString, _ := data["dateStart"].(string)
+		dateEndString, _ := data["dateEnd"].(string)
+		dateStart, errStart := time.Parse(time.RFC3339Nano, dateStartString)
+		dateEnd, errEnd := time.Parse(time.RFC3339Nano, dateEndString)
+		if errStart != nil || errEnd != nil {
+			errHandler.HandleError(w, "'dateStart' and 'dateEnd' must be RFC3339 times.", errHandler.BadRequest)
+			return
+		}
+		if !dateStart.Before(dateEnd) || dateEnd.Sub(dateStart) > time.Duration(maxDays)*24*time.Hour {
+			errHandler.HandleError(w, fmt.Sprintf("'dateEnd' must lie after 'dateStart', at most %d days for resolution '%s'.", maxDays, resolution), errHandler.BadRequest)
+			return
+		}
+
+		plantLoggerConfig, ok := findPlantLoggerConfig(r.Context(), w, mongoDBInterface, "ExportReadingsValidation", plant)
+		if !ok {
+			return
+		}
+
+		// Validate optional device to export. Readings of all devices are rolled up to the plant if missing
+		if rawDeviceID, hasDeviceID := data["deviceID"]; hasDeviceID {
+			deviceID, deviceIDValid := rawDeviceID.(string)
+			if !deviceIDValid {
+				errHandler.HandleError(w, "'deviceID' must be the id of a device of this plant.", errHandler.BadRequest)
+				return
+			}
+			_, found, err := loggerhandler.FindDevice(r.Context(), mongoDBInterface, plant.PublicPlantID, deviceID)
+			if err != nil {
+				logger.GetLogger().Errorf("Error in 'ExportReadingsValidation()' using 'FindDevice()'. Error: %v", err)
+				errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+				return
+			}
+			if !found {
+				errHandler.HandleError(w, "'deviceID' must be the id of a device of this plant.", errHandler.BadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "exportDeviceID", deviceID))
+		}
+
+		// Validate optional grouping of the export by device
+		if rawGroupByDevice, hasGroupByDevice := data["groupByDevice"]; hasGroupByDevice {
+			groupByDevice, groupByDeviceValid := rawGroupByDevice.(bool)
+			if !groupByDeviceValid {
+				errHandler.HandleError(w, "'groupByDevice' must be a boolean.", errHandler.BadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), "exportGroupByDevice", groupByDevice))
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), "plantLoggerConfig", plantLoggerConfig))
+		r = r.WithContext(context.WithValue(r.Context(), "exportResolution", resolution))
+		r = r.WithContext(context.WithValue(r.Context(), "exportDateStart", dateStart))
+		r = r.WithContext(context.WithValue(r.Context(), "exportDateEnd", dateEnd))
+
+		// Call the next handler if validation passes
+		next.ServeHTTP(w, r)
+	}
+}
+
+// findPlantLoggerConfig finds the plant logger config of plant. Responds to the request on failure.
+func findPlantLoggerConfig(ctx context.Context, w http.ResponseWriter, mongoDBInterface *mongodb.MethodInterface, validatorName string, plant model.PhotovoltaicPlant) (model.PlantLoggerConfig, bool) {
+	neutralResponseErr := "We appologize. Request currently not possible due to github.com/paulmuenzner/powerplantmanager update. Please, try again later."
+	var plantLoggerConfig model.PlantLoggerConfig
+	var filter bson.M = bson.M{"_id": plant.ID}
+	findOne, err := mongoDBInterface.RepositoryInterface.FindOneInMongo(ctx, config.DatabaseNamePlantLoggerConfig, filter, config.CollectionNamePlantLoggerConfig, bson.D{}, &plantLoggerConfig)
+	if err != nil {
+		logger.GetLogger().Errorf("Error in '%s()' using 'FindOneInMongo()' when querying collection '%s' part of database '%s' for plant ID %s. Error: %v", validatorName, config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, plant.PublicPlantID, err)
+		errHandler.HandleError(w, neutralResponseErr, errHandler.StatusOf(err, errHandler.InternalServerError))
+		return plantLoggerConfig, false
+	}
+	if !findOne {
+		logger.GetLogger().Errorf("Plant logger config of plant with public plant id '%s' not found in '%s()'.", plant.PublicPlantID, validatorName)
+		errHandler.HandleError(w, "Requested plant not available.", errHandler.BadRequest)
+		return plantLoggerConfig, false
+	}
+	return plantLoggerConfig, true
+}
@@ -0,0 +1,310 @@
+package sunspecpoller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/services/lease"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+	"github.com/paulmuenzner/powerplantmanager/utils/modbus"
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Poller reads measurements from the poll targets of all plants in each plant's logging interval and stores them as plant logs.
+// Readings pass the same validation as readings pushed by loggers, with source 'poll'. Of several server instances, the one holding
+// the lease of a plant polls it.
+type Poller struct {
+	mongoDBInterface *mongodb.MethodInterface
+	dialer           *net.Dialer
+	owner            string               // Owner of the plant leases, see lease.Owner
+	lastPoll         map[string]time.Time // Start of the latest poll by public plant id, used by run only
+	slots            chan struct{}        // Limits the plants polled at the same time to PollerConcurrency
+	waitGroup        sync.WaitGroup       // Polls in process
+	mutex            sync.Mutex           // Guards polling and leased
+	polling          map[string]bool      // Public plant ids polled right now
+	leased           map[string]bool      // Public plant ids whose lease this instance holds
+	stop             chan struct{}
+	done             chan struct{}
+}
+
+// NewPoller creates a poller. Call Start to run it in the background.
+func NewPoller(mongoDBInterface *mongodb.MethodInterface) *Poller {
+	return &Poller{
+		mongoDBInterface: mongoDBInterface,
+		dialer:           NewDialer(),
+		owner:            lease.Owner(),
+		lastPoll:         map[string]time.Time{},
+		slots:            make(chan struct{}, config.PollerConcurrency),
+		polling:          map[string]bool{},
+		leased:           map[string]bool{},
+	}
+}
+
+// Start runs the poller as supervised background worker. It's restarted after a crash
+func (poller *Poller) Start() {
+	poller.stop = make(chan struct{})
+	poller.done = make(chan struct{})
+	go poller.supervise()
+}
+
+// Stop stops the poller, waiting for polls in process. Leases held are released, so other instances take over right away
+func (poller *Poller) Stop() {
+	close(poller.stop)
+	<-poller.done
+}
+
+// supervise runs the poller until stopped, restarting it with delay after a panic
+func (poller *Poller) supervise() {
+	defer close(poller.done)
+	ctx := context.Background() // Polls in process are finished on Stop, not canceled
+	defer poller.releaseLeases(ctx)
+	defer poller.waitGroup.Wait()
+	for !poller.runRecovered(ctx) {
+		logger.GetLogger().Errorf("SunSpec poller crashed. Restarting in %d seconds.", config.PollerRestartDelaySec)
+		select {
+		case <-poller.stop:
+			return
+		case <-time.After(time.Duration(config.PollerRestartDelaySec) * time.Second):
+		}
+	}
+}
+
+// runRecovered runs the poller. Returns true if stopped, false after a panic
+func (poller *Poller) runRecovered(ctx context.Context) (stopped bool) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.GetLogger().Errorf("Panic in 'run()' of SunSpec poller: %v", recovered)
+			stopped = false
+		}
+	}()
+	poller.run(ctx)
+	return true
+}
+
+// run checks for plants due for polling until stopped
+func (poller *Poller) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(config.PollerScanIntervalSec) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-poller.stop:
+			return
+		case now := <-ticker.C:
+			poller.pollDuePlants(ctx, now)
+		}
+	}
+}
+
+// pollDuePlants starts polls of all plants with poll targets whose logging interval has passed since their latest poll, without waiting for them.
+// Plants still polled are skipped. Plants deleted or without poll targets are forgotten and their leases released
+func (poller *Poller) pollDuePlants(ctx context.Context, now time.Time) {
+	var plantConfigs []model.PlantLoggerConfig
+	filter := bson.M{"poll_targets.0": bson.M{"$exists": true}}
+	err := poller.mongoDBInterface.RepositoryInterface.FindManyInMongo(ctx, config.DatabaseNamePlantLoggerConfig, filter, config.CollectionNamePlantLoggerConfig, bson.D{}, &plantConfigs)
+	if err != nil {
+		logger.GetLogger().Errorf("Error in 'pollDuePlants()' using 'FindManyInMongo()' in collection '%s' part of database '%s'. Error: %v", config.CollectionNamePlantLoggerConfig, config.DatabaseNamePlantLoggerConfig, err)
+		return
+	}
+
+	polled := map[string]bool{}
+	for _, plantConfig := range plantConfigs {
+		publicPlantID := plantConfig.PublicPlantID
+		polled[publicPlantID] = true
+		if !IsPollDue(poller.lastPoll[publicPlantID], now, plantConfig.IntervalSec) || poller.isPolling(publicPlantID) {
+			continue
+		}
+		// Remaining plants wait for the next scan if stopped meanwhile
+		select {
+		case poller.slots <- struct{}{}:
+		case <-poller.stop:
+			return
+		}
+		poller.mutex.Lock()
+		poller.polling[publicPlantID] = true
+		poller.mutex.Unlock()
+		poller.lastPoll[publicPlantID] = now
+		poller.waitGroup.Add(1)
+		go poller.pollLeasedPlant(ctx, plantConfig, now)
+	}
+
+	for publicPlantID := range poller.lastPoll {
+		if !polled[publicPlantID] && !poller.isPolling(publicPlantID) {
+			delete(poller.lastPoll, publicPlantID)
+			poller.releaseLease(ctx, publicPlantID)
+		}
+	}
+}
+
+// isPolling checks if a poll of the plant is in process
+func (poller *Poller) isPolling(publicPlantID string) bool {
+	poller.mutex.Lock()
+	defer poller.mutex.Unlock()
+	return poller.polling[publicPlantID]
+}
+
+// pollLeasedPlant polls a plant if this instance acquires or renews its lease. Frees the slot of the poll when done
+func (poller *Poller) pollLeasedPlant(ctx context.Context, plantConfig model.PlantLoggerConfig, now time.Time) {
+	defer poller.waitGroup.Done()
+	defer func() {
+		poller.mutex.Lock()
+		delete(poller.polling, plantConfig.PublicPlantID)
+		poller.mutex.Unlock()
+		<-poller.slots
+	}()
+
+	duration := time.Duration(config.PollerLeaseIntervals*plantConfig.IntervalSec) * time.Second
+	acquired, err := lease.Acquire(ctx, poller.mongoDBInterface, leaseName(plantConfig.PublicPlantID), poller.owner, duration, now)
+	if err != nil {
+		logger.GetLogger().Errorf("Error in 'pollLeasedPlant()' using 'Acquire()' for plant '%s'. The plant is polled with its next logging interval. Error: %v", plantConfig.PublicPlantID, err)
+		return
+	}
+	poller.mutex.Lock()
+	if acquired {
+		poller.leased[plantConfig.PublicPlantID] = true
+	} else {
+		delete(poller.leased, plantConfig.PublicPlantID)
+	}
+	poller.mutex.Unlock()
+	if acquired {
+		poller.pollPlant(ctx, plantConfig, now)
+	}
+}
+
+// releaseLease releases the lease of a plant if held
+func (poller *Poller) releaseLease(ctx context.Context, publicPlantID string) {
+	poller.mutex.Lock()
+	leased := poller.leased[publicPlantID]
+	delete(poller.leased, publicPlantID)
+	poller.mutex.Unlock()
+	if !leased {
+		return
+	}
+	if err := lease.Release(ctx, poller.mongoDBInterface, leaseName(publicPlantID), poller.owner); err != nil {
+		logger.GetLogger().Errorf("Error in 'releaseLease()' using 'Release()' for plant '%s'. The lease expires after %d logging intervals. Error: %v", publicPlantID, config.PollerLeaseIntervals, err)
+	}
+}
+
+// releaseLeases releases the leases of all plants held
+func (poller *Poller) releaseLeases(ctx context.Context) {
+	poller.mutex.Lock()
+	publicPlantIDs := make([]string, 0, len(poller.leased))
+	for publicPlantID := range poller.leased {
+		publicPlantIDs = append(publicPlantIDs, publicPlantID)
+	}
+	poller.mutex.Unlock()
+	for _, publicPlantID := range publicPlantIDs {
+		poller.releaseLease(ctx, publicPlantID)
+	}
+}
+
+// leaseName returns the name of the lease of polling a plant
+func leaseName(publicPlantID string) string {
+	return "sunspec:" + publicPlantID
+}
+
+// PollTimeout returns the timeout of reading the poll targets of a plant: PollerPlantTimeoutSec, at most the logging interval
+func PollTimeout(intervalSec int) time.Duration {
+	return time.Duration(min(config.PollerPlantTimeoutSec, intervalSec)) * time.Second
+}
+
+// IsPollDue checks if the logging interval has passed since the latest poll started at lastPoll
+func IsPollDue(lastPoll, now time.Time, intervalSec int) bool {
+	return lastPoll.IsZero() || now.Sub(lastPoll) >= time.Duration(intervalSec)*time.Second
+}
+
+// pollPlant polls the targets of a plant and stores one reading per device. The start of the poll is the time of receipt of all readings,
+// so consecutive polls keep the logging interval. Targets not read within PollTimeout fail.
+func (poller *Poller) pollPlant(ctx context.Context, plantConfig model.PlantLoggerConfig, receivedAt time.Time) {
+	readCtx, cancel := context.WithTimeout(ctx, PollTimeout(plantConfig.IntervalSec))
+	readings, err := ReadTargets(readCtx, poller.dialer, plantConfig)
+	cancel()
+	if err != nil {
+		logger.GetLogger().Warnf("Polling plant '%s' failed in 'pollPlant()'. Error: %v", plantConfig.PublicPlantID, err)
+	}
+
+	for _, data := range readings {
+		plantLog, isDuplicate, err := loggerhandler.ValidateLogEntry(ctx, poller.mongoDBInterface, plantConfig, model.PlantDevice{}, data, []string{}, "poll", receivedAt)
+		var rejection *loggerhandler.ReadingRejection
+		if errors.As(err, &rejection) {
+			logger.GetLogger().Warnf("Polled reading of plant '%s' rejected in 'pollPlant()'. Reason: %s", plantConfig.PublicPlantID, rejection.Reason)
+			continue
+		}
+		if err != nil {
+			logger.GetLogger().Errorf("Error in 'pollPlant()' using 'ValidateLogEntry()' for plant '%s'. Error: %v", plantConfig.PublicPlantID, err)
+			continue
+		}
+		if isDuplicate {
+			continue
+		}
+		if err := loggerhandler.StoreLogEntry(ctx, poller.mongoDBInterface, plantConfig.CollectionNameLogger, plantLog); err != nil {
+			logger.GetLogger().Errorf("Error in 'pollPlant()' using 'StoreLogEntry()' for plant '%s'. Error: %v", plantConfig.PublicPlantID, err)
+		}
+	}
+}
+
+// ReadTargets reads all poll targets of a plant and merges the values of targets with the same device into one reading, keyed like a request body.
+// Only values of channels of the plant's channel schema are kept. Devices with a failing target are skipped, as their reading would be incomplete;
+// the returned error joins the failures. Targets not read before ctx ends fail.
+func ReadTargets(ctx context.Context, dialer *net.Dialer, plantConfig model.PlantLoggerConfig) ([]map[string]interface{}, error) {
+	channels := loggerhandler.EffectiveChannels(plantConfig)
+	readingsByDevice := map[string]map[string]interface{}{}
+	failedDevices := map[string]bool{}
+	deviceOrder := []string{}
+	var errs []error
+
+	for _, target := range plantConfig.PollTargets {
+		if failedDevices[target.DeviceID] {
+			continue
+		}
+		values, err := ReadTarget(ctx, dialer, target)
+		if err != nil {
+			failedDevices[target.DeviceID] = true
+			errs = append(errs, fmt.Errorf("target %s unit %d: %v", net.JoinHostPort(target.Host, strconv.Itoa(target.Port)), target.UnitID, err))
+			continue
+		}
+
+		data, exists := readingsByDevice[target.DeviceID]
+		if !exists {
+			data = map[string]interface{}{}
+			if target.DeviceID != "" {
+				data["deviceID"] = target.DeviceID
+			}
+			readingsByDevice[target.DeviceID] = data
+			deviceOrder = append(deviceOrder, target.DeviceID)
+		}
+		for channel, value := range values {
+			if _, inSchema := loggerhandler.FindChannel(channels, channel); inSchema {
+				data[channel] = value
+			}
+		}
+	}
+
+	readings := []map[string]interface{}{}
+	for _, deviceID := range deviceOrder {
+		if !failedDevices[deviceID] {
+			readings = append(readings, readingsByDevice[deviceID])
+		}
+	}
+	return readings, errors.Join(errs...)
+}
+
+// ReadTarget connects to a poll target and reads the values of its SunSpec model by channel name, before ctx ends
+func ReadTarget(ctx context.Context, dialer *net.Dialer, target model.PollTarget) (map[string]float64, error) {
+	address := net.JoinHostPort(target.Host, strconv.Itoa(target.Port))
+	client, err := modbus.Dial(ctx, dialer, address, byte(target.UnitID), time.Duration(config.PollerTimeoutSec)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return ReadModel(client, target.Model)
+}
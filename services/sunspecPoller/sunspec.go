@@ -0,0 +1,163 @@
+package sunspecpoller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/utils/modbus"
+)
+
+// SunSpec register map: the marker 'SunS' at the base address is followed by a chain of model blocks, each starting with model id and length.
+// The chain ends with model id 0xFFFF.
+
+const (
+	sunSpecMarkerHigh   uint16 = 0x5375 // 'Su'
+	sunSpecMarkerLow    uint16 = 0x6e53 // 'nS'
+	sunSpecEndModel     uint16 = 0xFFFF
+	sunSpecModelsMax    int    = 100 // Guard against malformed chains
+	notImplementedUint  uint16 = 0xFFFF
+	notImplementedInt   uint16 = 0x8000
+	scaleFactorMaxAbs   int16  = 10
+	scaleFactorFixedOff int    = -1 // Offset of a point with a fixed scale factor instead of a scale factor register
+)
+
+var ErrNotSunSpec = errors.New("no SunSpec register map found")
+
+// point maps a register of a SunSpec model block onto a channel of the default channel schema.
+// Offsets are relative to the first register after model id and length.
+type point struct {
+	channel           string
+	offset            int
+	signed            bool
+	scaleFactorOffset int   // Register of the scale factor, or scaleFactorFixedOff
+	scaleFactor       int16 // Fixed scale factor if scaleFactorOffset is scaleFactorFixedOff
+}
+
+// Inverter models 101 (single phase) and 103 (three phase) share their layout. The DC side maps onto voltage, current and power output.
+var inverterPoints = []point{
+	{channel: "currentOutput", offset: 25, scaleFactorOffset: 26},             // DCA
+	{channel: "voltageOutput", offset: 27, scaleFactorOffset: 28},             // DCV
+	{channel: "powerOutput", offset: 29, signed: true, scaleFactorOffset: 30}, // DCW
+}
+
+// Met station model 307 (base met) has fixed scale factors
+var metStationPoints = []point{
+	{channel: "tAmbient", offset: 0, signed: true, scaleFactorOffset: scaleFactorFixedOff, scaleFactor: -1}, // TmpAmb, 0.1 °C
+	{channel: "relHumidity", offset: 1, signed: true, scaleFactorOffset: scaleFactorFixedOff},               // RH, %
+	{channel: "windSpeed", offset: 3, signed: true, scaleFactorOffset: scaleFactorFixedOff},                 // WndSpd, m/s
+}
+
+// modelPoints returns the mapped points of a SunSpec model and the minimum block length containing them
+func modelPoints(sunSpecModel int) ([]point, int, bool) {
+	switch sunSpecModel {
+	case model.SunSpecModelInverterSinglePhase, model.SunSpecModelInverterThreePhase:
+		return inverterPoints, 31, true
+	case model.SunSpecModelMetStation:
+		return metStationPoints, 4, true
+	}
+	return nil, 0, false
+}
+
+// IsSupportedModel checks if sunSpecModel can be polled
+func IsSupportedModel(sunSpecModel int) bool {
+	_, _, supported := modelPoints(sunSpecModel)
+	return supported
+}
+
+// ReadModel reads the block of sunSpecModel from a device and returns its measurement values by channel name.
+// Points the device doesn't implement are missing in the result.
+func ReadModel(client *modbus.Client, sunSpecModel int) (map[string]float64, error) {
+	points, minLength, supported := modelPoints(sunSpecModel)
+	if !supported {
+		return nil, fmt.Errorf("SunSpec model %d not supported", sunSpecModel)
+	}
+
+	address, length, err := findModel(client, uint16(sunSpecModel))
+	if err != nil {
+		return nil, err
+	}
+	if int(length) < minLength {
+		return nil, fmt.Errorf("SunSpec model %d block too short. Length: %d", sunSpecModel, length)
+	}
+	block, err := client.ReadHoldingRegisters(address, uint16(minLength))
+	if err != nil {
+		return nil, err
+	}
+	return decodePoints(block, points), nil
+}
+
+// findModel returns the address of the first register after model id and length of model's block, and its length
+func findModel(client *modbus.Client, sunSpecModel uint16) (uint16, uint16, error) {
+	for _, base := range []uint16{config.SunSpecBaseAddress, 50000, 0} {
+		marker, err := client.ReadHoldingRegisters(base, 2)
+		var exception *modbus.ExceptionError
+		if errors.As(err, &exception) {
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if marker[0] != sunSpecMarkerHigh || marker[1] != sunSpecMarkerLow {
+			continue
+		}
+
+		address := base + 2
+		for i := 0; i < sunSpecModelsMax; i++ {
+			header, err := client.ReadHoldingRegisters(address, 2)
+			if err != nil {
+				return 0, 0, err
+			}
+			if header[0] == sunSpecEndModel {
+				return 0, 0, fmt.Errorf("SunSpec model %d not provided by device", sunSpecModel)
+			}
+			if header[0] == sunSpecModel {
+				return address + 2, header[1], nil
+			}
+			if int(address)+2+int(header[1]) > math.MaxUint16 {
+				break
+			}
+			address += 2 + header[1]
+		}
+		return 0, 0, fmt.Errorf("SunSpec model %d not found within %d models", sunSpecModel, sunSpecModelsMax)
+	}
+	return 0, 0, ErrNotSunSpec
+}
+
+// decodePoints scales the registers of points of a model block. Not implemented values and scale factors are skipped
+func decodePoints(block []uint16, points []point) map[string]float64 {
+	values := map[string]float64{}
+	for _, p := range points {
+		raw := block[p.offset]
+		scaleFactor := p.scaleFactor
+		if p.scaleFactorOffset != scaleFactorFixedOff {
+			rawScaleFactor := block[p.scaleFactorOffset]
+			scaleFactor = int16(rawScaleFactor)
+			if rawScaleFactor == notImplementedInt || scaleFactor < -scaleFactorMaxAbs || scaleFactor > scaleFactorMaxAbs {
+				continue
+			}
+		}
+
+		var value float64
+		if p.signed {
+			if raw == notImplementedInt {
+				continue
+			}
+			value = float64(int16(raw))
+		} else {
+			if raw == notImplementedUint {
+				continue
+			}
+			value = float64(raw)
+		}
+		// Dividing avoids rounding errors of negative powers of ten, eg. 2305 * 0.1
+		if scaleFactor < 0 {
+			values[p.channel] = value / math.Pow10(-int(scaleFactor))
+		} else {
+			values[p.channel] = value * math.Pow10(int(scaleFactor))
+		}
+	}
+	return values
+}
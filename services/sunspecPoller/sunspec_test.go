@@ -0,0 +1,225 @@
+package sunspecpoller
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	"github.com/paulmuenzner/powerplantmanager/services/lease"
+	"github.com/paulmuenzner/powerplantmanager/utils/modbus"
+	"github.com/paulmuenzner/powerplantmanager/utils/mongoDB/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sunSpecRegisters returns the register map of a device providing the common model 1, inverter model 103 and met station model 307 at base
+func sunSpecRegisters(base uint16) map[uint16]uint16 {
+	registers := map[uint16]uint16{}
+	address := base
+	set := func(values ...uint16) {
+		for _, value := range values {
+			registers[address] = value
+			address++
+		}
+	}
+	set(0x5375, 0x6e53)
+	set(1, 66)
+	set(make([]uint16, 66)...)
+
+	inverter := make([]uint16, 50)
+	inverter[25], inverter[26] = 1020, 0xFFFE // DCA 10.20 A
+	inverter[27], inverter[28] = 2305, 0xFFFF // DCV 230.5 V
+	inverter[29], inverter[30] = 2351, 0      // DCW 2351 W
+	set(103, 50)
+	set(inverter...)
+
+	met := make([]uint16, 11)
+	met[0] = uint16(0xFFFF - 24) // TmpAmb -2.5 °C
+	met[1] = 81                  // RH 81 %
+	met[3] = 0x8000              // WndSpd not implemented
+	set(307, 11)
+	set(met...)
+
+	set(0xFFFF, 0)
+	return registers
+}
+
+func startSimulator(t *testing.T, registers map[uint16]uint16) (string, int) {
+	simulator, err := modbus.NewSimulator("127.0.0.1:0", registers)
+	require.NoError(t, err)
+	t.Cleanup(func() { simulator.Close() })
+	host, port, err := net.SplitHostPort(simulator.Address())
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return host, portNumber
+}
+
+func TestReadTarget(t *testing.T) {
+	host, port := startSimulator(t, sunSpecRegisters(40000))
+	dialer := &net.Dialer{Timeout: time.Second}
+
+	values, err := ReadTarget(context.Background(), dialer, model.PollTarget{Host: host, Port: port, UnitID: 1, Model: model.SunSpecModelInverterThreePhase})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"currentOutput": 10.2, "voltageOutput": 230.5, "powerOutput": 2351}, values)
+
+	// Not implemented wind speed is missing
+	values, err = ReadTarget(context.Background(), dialer, model.PollTarget{Host: host, Port: port, UnitID: 1, Model: model.SunSpecModelMetStation})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"tAmbient": -2.5, "relHumidity": 81}, values)
+
+	// Model not provided by the device
+	_, err = ReadTarget(context.Background(), dialer, model.PollTarget{Host: host, Port: port, UnitID: 1, Model: model.SunSpecModelInverterSinglePhase})
+	assert.Error(t, err)
+}
+
+func TestReadTargetAlternativeBaseAddress(t *testing.T) {
+	host, port := startSimulator(t, sunSpecRegisters(50000))
+	values, err := ReadTarget(context.Background(), &net.Dialer{Timeout: time.Second}, model.PollTarget{Host: host, Port: port, UnitID: 1, Model: model.SunSpecModelInverterThreePhase})
+	assert.NoError(t, err)
+	assert.Equal(t, 230.5, values["voltageOutput"])
+
+	// No SunSpec device
+	host, port = startSimulator(t, map[uint16]uint16{40000: 1, 40001: 2})
+	_, err = ReadTarget(context.Background(), &net.Dialer{Timeout: time.Second}, model.PollTarget{Host: host, Port: port, UnitID: 1, Model: model.SunSpecModelInverterThreePhase})
+	assert.ErrorIs(t, err, ErrNotSunSpec)
+}
+
+func TestReadTargets(t *testing.T) {
+	host, port := startSimulator(t, sunSpecRegisters(40000))
+	dialer := &net.Dialer{Timeout: time.Second}
+	channels := []model.Channel{
+		{Name: "voltageOutput", Unit: "V", Required: true},
+		{Name: "currentOutput", Unit: "A", Required: true},
+		{Name: "powerOutput", Unit: "W", Required: true},
+		{Name: "tAmbient", Unit: "°C", Required: false},
+	}
+	plantConfig := model.PlantLoggerConfig{Channels: channels, PollTargets: []model.PollTarget{
+		{Host: host, Port: port, UnitID: 1, Model: model.SunSpecModelInverterThreePhase},
+		{Host: host, Port: port, UnitID: 1, Model: model.SunSpecModelMetStation},
+		{Host: host, Port: port, UnitID: 1, Model: model.SunSpecModelInverterThreePhase, DeviceID: "100000000001"},
+		{Host: host, Port: port, UnitID: 1, Model: model.SunSpecModelInverterSinglePhase, DeviceID: "100000000002"},
+	}}
+
+	// Targets without device are merged, relative humidity isn't part of the channel schema. The device with a failing target is skipped
+	readings, err := ReadTargets(context.Background(), dialer, plantConfig)
+	assert.Error(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"voltageOutput": 230.5, "currentOutput": 10.2, "powerOutput": 2351.0, "tAmbient": -2.5},
+		{"deviceID": "100000000001", "voltageOutput": 230.5, "currentOutput": 10.2, "powerOutput": 2351.0},
+	}, readings)
+}
+
+func TestDialerRefusesLoopback(t *testing.T) {
+	host, port := startSimulator(t, sunSpecRegisters(40000))
+	_, err := ReadTarget(context.Background(), NewDialer(), model.PollTarget{Host: host, Port: port, UnitID: 1, Model: model.SunSpecModelInverterThreePhase})
+	assert.ErrorIs(t, err, ErrAddressNotAllowed)
+}
+
+func TestParsePollTargets(t *testing.T) {
+	testCases := []struct {
+		name     string
+		raw      interface{}
+		expected []model.PollTarget
+		valid    bool
+	}{
+		{"Empty", []interface{}{}, []model.PollTarget{}, true},
+		{"DefaultPort", []interface{}{map[string]interface{}{"host": "192.168.1.20", "unitID": 1.0, "model": 103.0}}, []model.PollTarget{{Host: "192.168.1.20", Port: 502, UnitID: 1, Model: 103}}, true},
+		{"PortAndDevice", []interface{}{map[string]interface{}{"host": "inverter-1.plant.example", "port": 1502.0, "unitID": 3.0, "model": 307.0, "deviceID": "100000000001"}}, []model.PollTarget{{Host: "inverter-1.plant.example", Port: 1502, UnitID: 3, Model: 307, DeviceID: "100000000001"}}, true},
+		{"NoArray", map[string]interface{}{}, nil, false},
+		{"UnsupportedModel", []interface{}{map[string]interface{}{"host": "192.168.1.20", "unitID": 1.0, "model": 160.0}}, nil, false},
+		{"UnitIDOutOfRange", []interface{}{map[string]interface{}{"host": "192.168.1.20", "unitID": 248.0, "model": 103.0}}, nil, false},
+		{"Loopback", []interface{}{map[string]interface{}{"host": "127.0.0.1", "unitID": 1.0, "model": 103.0}}, nil, false},
+		{"LinkLocal", []interface{}{map[string]interface{}{"host": "169.254.169.254", "unitID": 1.0, "model": 103.0}}, nil, false},
+		{"InvalidHost", []interface{}{map[string]interface{}{"host": "inverter_1/x", "unitID": 1.0, "model": 103.0}}, nil, false},
+		{"UnknownKey", []interface{}{map[string]interface{}{"host": "192.168.1.20", "unitID": 1.0, "model": 103.0, "interval": 60.0}}, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			targets, err := ParsePollTargets(tc.raw)
+			if !tc.valid {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, targets)
+		})
+	}
+}
+
+func TestIsPollDue(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.True(t, IsPollDue(time.Time{}, now, 900))
+	assert.False(t, IsPollDue(now.Add(-899*time.Second), now, 900))
+	assert.True(t, IsPollDue(now.Add(-900*time.Second), now, 900))
+}
+
+func TestPollDuePlants(t *testing.T) {
+	ctx := context.Background()
+	mongoDBInterface := memory.NewMethodInterface()
+	repository := mongoDBInterface.RepositoryInterface
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	host, port := startSimulator(t, sunSpecRegisters(40000))
+	targets := []model.PollTarget{{Host: host, Port: port, UnitID: 1, Model: model.SunSpecModelInverterThreePhase}}
+	channels := []model.Channel{
+		{Name: "voltageOutput", Unit: "V", Required: true},
+		{Name: "currentOutput", Unit: "A", Required: true},
+		{Name: "powerOutput", Unit: "W", Required: true},
+	}
+	for _, plantConfig := range []model.PlantLoggerConfig{
+		{ID: primitive.NewObjectID(), PublicPlantID: "plantA", CollectionNameLogger: "loggerA", IntervalSec: 900, Channels: channels, PollTargets: targets},
+		{ID: primitive.NewObjectID(), PublicPlantID: "plantB", CollectionNameLogger: "loggerB", IntervalSec: 900, Channels: channels, PollTargets: targets},
+		{ID: primitive.NewObjectID(), PublicPlantID: "plantC", IntervalSec: 900},
+	} {
+		_, err := repository.InsertOneToMongo(ctx, config.DatabaseNamePlantLoggerConfig, plantConfig, config.CollectionNamePlantLoggerConfig)
+		require.NoError(t, err)
+	}
+	acquired, err := lease.Acquire(ctx, mongoDBInterface, leaseName("plantB"), "other:1", time.Hour, now)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	// Plants with poll targets are polled, plant B by the instance holding its lease
+	poller := NewPoller(mongoDBInterface)
+	poller.dialer = &net.Dialer{Timeout: time.Second}
+	poller.pollDuePlants(ctx, now)
+	poller.waitGroup.Wait()
+	for collectionNameLogger, expected := range map[string]int{"loggerA": 1, "loggerB": 0} {
+		var plantLogs []model.PlantLogger
+		require.NoError(t, repository.FindManyInMongo(ctx, config.DatabaseNamePlantLogger, bson.M{}, collectionNameLogger, bson.D{}, &plantLogs))
+		assert.Len(t, plantLogs, expected)
+	}
+	assert.Equal(t, map[string]time.Time{"plantA": now, "plantB": now}, poller.lastPoll)
+	assert.Equal(t, map[string]bool{"plantA": true}, poller.leased)
+	assert.Empty(t, poller.polling)
+	assert.Empty(t, poller.slots)
+	var plantLease model.Lease
+	found, err := repository.FindOneInMongo(ctx, config.DatabaseNameLease, bson.M{"_id": leaseName("plantA")}, config.CollectionNameLease, bson.D{}, &plantLease)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, poller.owner, plantLease.Owner)
+	assert.True(t, plantLease.ExpiresAt.Equal(now.Add(time.Duration(config.PollerLeaseIntervals*900)*time.Second)))
+
+	// Deleted plants are forgotten and their leases released
+	_, err = repository.DeleteDocumentMongo(ctx, config.DatabaseNamePlantLoggerConfig, bson.M{"public_plant_id": "plantA"}, config.CollectionNamePlantLoggerConfig)
+	require.NoError(t, err)
+	poller.pollDuePlants(ctx, now.Add(time.Minute))
+	poller.waitGroup.Wait()
+	assert.Equal(t, map[string]time.Time{"plantB": now}, poller.lastPoll)
+	assert.Empty(t, poller.leased)
+	found, err = repository.FindOneInMongo(ctx, config.DatabaseNameLease, bson.M{"_id": leaseName("plantA")}, config.CollectionNameLease, bson.D{}, &plantLease)
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestPollTimeout(t *testing.T) {
+	assert.Equal(t, time.Duration(config.PollerPlantTimeoutSec)*time.Second, PollTimeout(900))
+	assert.Equal(t, 10*time.Second, PollTimeout(10))
+}
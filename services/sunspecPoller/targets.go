@@ -0,0 +1,108 @@
+package sunspecpoller
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	model "github.com/paulmuenzner/powerplantmanager/models"
+	loggerhandler "github.com/paulmuenzner/powerplantmanager/services/loggerHandler"
+	v "github.com/paulmuenzner/powerplantmanager/utils/validate"
+)
+
+var ErrAddressNotAllowed = errors.New("polling of loopback, link-local, multicast or unspecified addresses is not allowed")
+
+// ParsePollTargets converts poll targets of the parsed request body into PollTargets. An empty array removes all poll targets.
+// Each target is an object with 'host', 'unitID', 'model' and optional 'port' and 'deviceID'. The returned error is suitable for the response.
+func ParsePollTargets(raw interface{}) ([]model.PollTarget, error) {
+	rawTargets, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.New("'pollTargets' must be an array of poll targets.")
+	}
+	if len(rawTargets) > config.PollTargetsMax {
+		return nil, fmt.Errorf("Maximum number of poll targets per plant: %d", config.PollTargetsMax)
+	}
+
+	targets := make([]model.PollTarget, 0, len(rawTargets))
+	for index, rawTarget := range rawTargets {
+		data, ok := rawTarget.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Poll target %d must be an object.", index)
+		}
+		validateKeys := v.Validate(data).
+			HasMapExactKeys(loggerhandler.ExpectedReadingKeys(data, []string{"host", "unitID", "model"}, []string{"port", "deviceID"}), fmt.Sprintf("Poll target %d must contain 'host', 'unitID', 'model' and optionally 'port' and 'deviceID'.", index)).
+			GetResult()
+		if len(validateKeys) > 0 {
+			return nil, errors.New(validateKeys[0])
+		}
+
+		host, hostValid := data["host"].(string)
+		if !hostValid || !isValidHost(host) {
+			return nil, fmt.Errorf("Poll target %d: 'host' must be a host name or an ip address which is not loopback, link-local or multicast.", index)
+		}
+		port := config.ModbusPortDefault
+		if rawPort, hasPort := data["port"]; hasPort {
+			portValue, portValid := rawPort.(float64)
+			if !portValid || portValue != float64(int(portValue)) || portValue < 1 || portValue > 65535 {
+				return nil, fmt.Errorf("Poll target %d: 'port' must be a port number between 1 and 65535.", index)
+			}
+			port = int(portValue)
+		}
+		unitID, unitIDValid := data["unitID"].(float64)
+		if !unitIDValid || unitID != float64(int(unitID)) || unitID < 1 || unitID > 247 {
+			return nil, fmt.Errorf("Poll target %d: 'unitID' must be a Modbus unit identifier between 1 and 247.", index)
+		}
+		sunSpecModel, modelValid := data["model"].(float64)
+		if !modelValid || sunSpecModel != float64(int(sunSpecModel)) || !IsSupportedModel(int(sunSpecModel)) {
+			return nil, fmt.Errorf("Poll target %d: 'model' must be SunSpec model %d, %d or %d.", index, model.SunSpecModelInverterSinglePhase, model.SunSpecModelInverterThreePhase, model.SunSpecModelMetStation)
+		}
+		target := model.PollTarget{Host: host, Port: port, UnitID: int(unitID), Model: int(sunSpecModel)}
+		if rawDeviceID, hasDeviceID := data["deviceID"]; hasDeviceID {
+			deviceID, deviceIDValid := rawDeviceID.(string)
+			if !deviceIDValid || deviceID == "" {
+				return nil, fmt.Errorf("Poll target %d: 'deviceID' must be the id of a device of the plant.", index)
+			}
+			target.DeviceID = deviceID
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// isValidHost checks if host is a host name or an ip address allowed for polling
+func isValidHost(host string) bool {
+	if len(host) == 0 || len(host) > config.PollTargetHostMaxLength {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return IsPollableIP(ip)
+	}
+	return config.Regex.Hostname.MatchString(host)
+}
+
+// IsPollableIP checks if the server may connect to ip for polling. Private networks are allowed, as devices are often reached via VPN.
+// Loopback, link-local (eg. cloud metadata services), multicast and unspecified addresses are not.
+func IsPollableIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// NewDialer returns a dialer for poll targets. Host names are resolved on connect, so the resolved address is checked again
+func NewDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout: time.Duration(config.PollerTimeoutSec) * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !IsPollableIP(ip) {
+				return ErrAddressNotAllowed
+			}
+			return nil
+		},
+	}
+}
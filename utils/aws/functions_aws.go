@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"time"
 
+	config "github.com/paulmuenzner/powerplantmanager/config"
 	files "github.com/paulmuenzner/powerplantmanager/utils/files"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -17,25 +20,27 @@ import (
 // /////////////////////////////////////////////////////////////////////
 // //// UPLOADER
 // Upload object to S3
-func (client *S3Client) UploadFile(bucketName string, objectKey string, fileBytes []byte) error {
+func (client *S3Client) UploadFile(ctx context.Context, bucketName string, objectKey string, fileBytes []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.S3TimeoutSec)*time.Second)
+	defer cancel()
 
 	// Determine size of file to upload
 	fileSize, err := files.GetSizeOfByteSlice(fileBytes)
 	if err != nil {
-		return fmt.Errorf("Couldn't define file size for object key '%s' in 'UploadFile()' with 'GetSizeOfByteSlice()'. Error: %v", objectKey, err)
+		return fmt.Errorf("Couldn't define file size for object key '%s' in 'UploadFile()' with 'GetSizeOfByteSlice()'. Error: %w", objectKey, err)
 	}
 
 	// If file size larger than 11MB stream file in chunks with uploadLargeObjectToS3().
 	// ! Minimum file size 5MB to be able to use uploadLargeObjectToS3() according to AWS
 	if fileSize < 11*1024*1024 {
-		err := client.uploadSmallObjectToS3(bucketName, objectKey, fileBytes)
+		err := client.uploadSmallObjectToS3(ctx, bucketName, objectKey, fileBytes)
 		if err != nil {
-			return fmt.Errorf("Error uploading file in 'UploadFile()' with 'uploadSmallObjectToS3' for object key '%s'. Error: %v", objectKey, err)
+			return fmt.Errorf("Error uploading file in 'UploadFile()' with 'uploadSmallObjectToS3' for object key '%s'. Error: %w", objectKey, err)
 		}
 	} else {
-		err := client.uploadLargeObjectToS3(bucketName, objectKey, fileBytes)
+		err := client.uploadLargeObjectToS3(ctx, bucketName, objectKey, fileBytes)
 		if err != nil {
-			return fmt.Errorf("Error uploading file in 'UploadFile()' with 'uploadLargeObjectToS3' for object key '%s'. Error: %v", objectKey, err)
+			return fmt.Errorf("Error uploading file in 'UploadFile()' with 'uploadLargeObjectToS3' for object key '%s'. Error: %w", objectKey, err)
 		}
 	}
 	return nil
@@ -44,7 +49,7 @@ func (client *S3Client) UploadFile(bucketName string, objectKey string, fileByte
 // /////////////////////////////////////////////////////////////////////////////////
 // Upload files breaks large data into parts and uploads the parts concurrently
 // ////////////////////////////////////////////////////////////////////////////
-func (client *S3Client) uploadLargeObjectToS3(bucketName string, objectKey string, fileBytes []byte) error {
+func (client *S3Client) uploadLargeObjectToS3(ctx context.Context, bucketName string, objectKey string, fileBytes []byte) error {
 
 	// Define chunk sizes
 	var partMiBs int64 = 10
@@ -53,14 +58,14 @@ func (client *S3Client) uploadLargeObjectToS3(bucketName string, objectKey strin
 	})
 
 	// Stream the file in chunks directly to the uploader
-	_, err := uploader.Upload(context.TODO(), &s3.PutObjectInput{
+	_, err := uploader.Upload(ctx, &s3.PutObjectInput{
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(objectKey),
 		Body:   bytes.NewReader(fileBytes), // Use the file directly as the input stream
 	})
 
 	if err != nil {
-		return fmt.Errorf("Couldn't upload large object in 'uploadLargeObjectToS3()' with 'uploader.Upload()' to bucket %v with object key:%v. Here's why: %v",
+		return fmt.Errorf("Couldn't upload large object in 'uploadLargeObjectToS3()' with 'uploader.Upload()' to bucket %v with object key:%v. Here's why: %w",
 			bucketName, objectKey, err)
 	}
 
@@ -70,29 +75,53 @@ func (client *S3Client) uploadLargeObjectToS3(bucketName string, objectKey strin
 // ////////////////////////////////////////////////////////
 // Upload file
 // ///////////
-func (client *S3Client) uploadSmallObjectToS3(bucketName string, objectKey string, fileBytes []byte) error {
-	_, err := client.Client.PutObject(context.TODO(), &s3.PutObjectInput{
+func (client *S3Client) uploadSmallObjectToS3(ctx context.Context, bucketName string, objectKey string, fileBytes []byte) error {
+	_, err := client.Client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(objectKey),
 		Body:   bytes.NewReader(fileBytes),
 	})
 	if err != nil {
-		return fmt.Errorf("Couldn't upload file with object key %s to bucket %s. Error in 'uploadSmallObjectToS3' from 'awsS3.Client.PutObject()'. Error: %v", objectKey, bucketName, err)
+		return fmt.Errorf("Couldn't upload file with object key %s to bucket %s. Error in 'uploadSmallObjectToS3' from 'awsS3.Client.PutObject()'. Error: %w", objectKey, bucketName, err)
 	}
 	return err
 }
 
+// /////////////////////////////////////////////////////////////////////
+// //// DOWNLOADER
+// Download object from S3
+func (client *S3Client) DownloadFile(ctx context.Context, bucketName string, objectKey string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.S3TimeoutSec)*time.Second)
+	defer cancel()
+	output, err := client.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't download file with object key %s from bucket %s. Error in 'DownloadFile' from 'awsS3.Client.GetObject()'. Error: %w", objectKey, bucketName, err)
+	}
+	defer output.Body.Close()
+
+	fileBytes, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read file with object key %s from bucket %s in 'DownloadFile' using 'io.ReadAll()'. Error: %w", objectKey, bucketName, err)
+	}
+	return fileBytes, nil
+}
+
 // ////////////////////////////////////////////////////////////
 // Validate if S3 bucket exists
 // ////////////////////////////////
-func (client *S3Client) BucketExists(bucketName string) (bucketExists bool, err error) {
-	_, err = client.Client.HeadBucket(context.TODO(), &s3.HeadBucketInput{
+func (client *S3Client) BucketExists(ctx context.Context, bucketName string) (bucketExists bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.S3TimeoutSec)*time.Second)
+	defer cancel()
+	_, err = client.Client.HeadBucket(ctx, &s3.HeadBucketInput{
 		Bucket: aws.String(bucketName),
 	})
 
 	bucketExists = true
 	if err != nil {
-		err = fmt.Errorf("Either no access to bucket %s or another error determined in 'BucketExists' with 'HeadBucket()'. Error: %v", bucketName, err)
+		err = fmt.Errorf("Either no access to bucket %s or another error determined in 'BucketExists' with 'HeadBucket()'. Error: %w", bucketName, err)
 		bucketExists = false
 	}
 
@@ -102,18 +131,20 @@ func (client *S3Client) BucketExists(bucketName string) (bucketExists bool, err
 // ////////////////////////////////////////////////////////////
 // ////////////////////////////////////////////////////////////
 // Delete object from aws S3 bucket
-func (client *S3Client) DeleteObjects(bucketName string, objectKeys []string) error {
+func (client *S3Client) DeleteObjects(ctx context.Context, bucketName string, objectKeys []string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.S3TimeoutSec)*time.Second)
+	defer cancel()
 
 	var objectIds []types.ObjectIdentifier
 	for _, key := range objectKeys {
 		objectIds = append(objectIds, types.ObjectIdentifier{Key: aws.String(key)})
 	}
-	_, err := client.Client.DeleteObjects(context.TODO(), &s3.DeleteObjectsInput{
+	_, err := client.Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
 		Bucket: aws.String(bucketName),
 		Delete: &types.Delete{Objects: objectIds},
 	})
 	if err != nil {
-		return fmt.Errorf("Couldn't delete objects from bucket %v. Here's why: %v", bucketName, err)
+		return fmt.Errorf("Couldn't delete objects from bucket %v. Here's why: %w", bucketName, err)
 	}
 	return err
 }
@@ -121,7 +152,9 @@ func (client *S3Client) DeleteObjects(bucketName string, objectKeys []string) er
 // ////////////////////////////////////////////////////////////
 // Check if object in S3 bucket exists
 // ///////////////////////////////////
-func (client *S3Client) S3ObjectExists(objectKey, bucketName string) (objectExists bool, err error) {
+func (client *S3Client) S3ObjectExists(ctx context.Context, objectKey, bucketName string) (objectExists bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.S3TimeoutSec)*time.Second)
+	defer cancel()
 	// Create a HeadObjectInput with the specified key and bucket
 	input := &s3.HeadObjectInput{
 		Bucket: aws.String(bucketName),
@@ -129,7 +162,7 @@ func (client *S3Client) S3ObjectExists(objectKey, bucketName string) (objectExis
 	}
 
 	// Execute the HeadObject operation
-	_, err = client.Client.HeadObject(context.TODO(), input)
+	_, err = client.Client.HeadObject(ctx, input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
@@ -147,9 +180,11 @@ func (client *S3Client) S3ObjectExists(objectKey, bucketName string) (objectExis
 // ///////////////////////////////////////////////////////////////////////
 // List all virtual folders inside a virtual S3 folder (folderPrefix)
 // ///////////////////////////////////
-func (client *S3Client) ChangeObjectName(bucketName, oldObjectKey, newObjectKey string) error {
+func (client *S3Client) ChangeObjectName(ctx context.Context, bucketName, oldObjectKey, newObjectKey string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.S3TimeoutSec)*time.Second)
+	defer cancel()
 	// Copy the object to the new key.
-	_, err := client.Client.CopyObject(context.TODO(), &s3.CopyObjectInput{
+	_, err := client.Client.CopyObject(ctx, &s3.CopyObjectInput{
 		Bucket:     aws.String(bucketName),
 		CopySource: aws.String(bucketName + "/" + oldObjectKey),
 		Key:        aws.String(newObjectKey),
@@ -160,7 +195,7 @@ func (client *S3Client) ChangeObjectName(bucketName, oldObjectKey, newObjectKey
 
 	// Delete the old object.
 	objectKeys := []string{oldObjectKey}
-	if err := client.DeleteObjects(bucketName, objectKeys); err != nil {
+	if err := client.DeleteObjects(ctx, bucketName, objectKeys); err != nil {
 		return err
 	}
 
@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -8,13 +9,15 @@ import (
 
 // ///////////////////////////////////////////////////////////
 // Setup interface for AWS S3 repository utilizing Dependency Injection
+// Operations end with ctx or after config.S3TimeoutSec at latest
 // /////////////////////
 type S3Repository interface {
-	UploadFile(bucketName string, objectKey string, fileBytes []byte) error
-	BucketExists(bucketName string) (bucketExists bool, err error)
-	DeleteObjects(bucketName string, objectKeys []string) error
-	S3ObjectExists(objectKey, bucketName string) (objectExists bool, err error)
-	ChangeObjectName(bucketName, oldObjectKey, newObjectKey string) error
+	UploadFile(ctx context.Context, bucketName string, objectKey string, fileBytes []byte) error
+	DownloadFile(ctx context.Context, bucketName string, objectKey string) ([]byte, error)
+	BucketExists(ctx context.Context, bucketName string) (bucketExists bool, err error)
+	DeleteObjects(ctx context.Context, bucketName string, objectKeys []string) error
+	S3ObjectExists(ctx context.Context, objectKey, bucketName string) (objectExists bool, err error)
+	ChangeObjectName(ctx context.Context, bucketName, oldObjectKey, newObjectKey string) error
 }
 
 type S3Client struct {
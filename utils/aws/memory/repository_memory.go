@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/paulmuenzner/powerplantmanager/utils/aws"
+)
+
+// //////////////////////////////////////////////////////////////////////
+// In-memory implementation of aws.S3Repository for tests and local development without S3 bucket.
+// Buckets exist once an object has been uploaded. Operations fail if ctx has ended before they start. Data is lost when the process ends.
+// ///////////////////
+type Repository struct {
+	mutex   sync.Mutex
+	buckets map[string]map[string][]byte // Objects by key by bucket name
+}
+
+var _ aws.S3Repository = &Repository{}
+
+// New returns an empty in-memory repository
+func New() *Repository {
+	return &Repository{buckets: map[string]map[string][]byte{}}
+}
+
+// NewMethodInterface returns the method interface of an empty in-memory repository, eg. to run the API with '-dev' or in tests
+func NewMethodInterface() *aws.MethodInterface {
+	return &aws.MethodInterface{RepositoryInterfaceS3: New()}
+}
+
+func (r *Repository) UploadFile(ctx context.Context, bucketName string, objectKey string, fileBytes []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.buckets[bucketName] == nil {
+		r.buckets[bucketName] = map[string][]byte{}
+	}
+	r.buckets[bucketName][objectKey] = append([]byte{}, fileBytes...)
+	return nil
+}
+
+func (r *Repository) DownloadFile(ctx context.Context, bucketName string, objectKey string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	fileBytes, exists := r.buckets[bucketName][objectKey]
+	if !exists {
+		return nil, fmt.Errorf("Couldn't download file with object key %s from bucket %s. Error in 'DownloadFile'. Error: NoSuchKey", objectKey, bucketName)
+	}
+	return append([]byte{}, fileBytes...), nil
+}
+
+func (r *Repository) BucketExists(ctx context.Context, bucketName string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, exists := r.buckets[bucketName]
+	return exists, nil
+}
+
+// DeleteObjects deletes objects. Missing objects are skipped as by S3
+func (r *Repository) DeleteObjects(ctx context.Context, bucketName string, objectKeys []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, objectKey := range objectKeys {
+		delete(r.buckets[bucketName], objectKey)
+	}
+	return nil
+}
+
+func (r *Repository) S3ObjectExists(ctx context.Context, objectKey, bucketName string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, exists := r.buckets[bucketName][objectKey]
+	return exists, nil
+}
+
+func (r *Repository) ChangeObjectName(ctx context.Context, bucketName, oldObjectKey, newObjectKey string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	fileBytes, exists := r.buckets[bucketName][oldObjectKey]
+	if !exists {
+		return fmt.Errorf("Couldn't copy object %s in bucket %s in 'ChangeObjectName'. Error: NoSuchKey", oldObjectKey, bucketName)
+	}
+	r.buckets[bucketName][newObjectKey] = fileBytes
+	delete(r.buckets[bucketName], oldObjectKey)
+	return nil
+}
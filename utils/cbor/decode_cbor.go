@@ -0,0 +1,302 @@
+package cbor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxDepth is the maximum nesting of arrays and maps
+const MaxDepth = 16
+
+var (
+	ErrUnexpectedEnd = errors.New("cbor: unexpected end of data")
+	ErrTrailingData  = errors.New("cbor: data after top-level item")
+	ErrTooDeep       = fmt.Errorf("cbor: nesting deeper than %d levels", MaxDepth)
+)
+
+// Decode decodes CBOR (RFC 8949) into the values encoding/json produces for JSON: numbers as float64, text strings, bools, nil,
+// []interface{} and map[string]interface{}. So CBOR payloads pass the same validation as JSON.
+// Maps must have text keys without duplicates. Byte strings aren't supported. Epoch-based date/time (tag 1) becomes a RFC3339 string,
+// the content of other tags is kept without tag. Declared lengths are checked against the remaining data before allocating.
+func Decode(data []byte) (interface{}, error) {
+	decoder := &decoder{data: data}
+	value, err := decoder.item(0)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.offset != len(data) {
+		return nil, ErrTrailingData
+	}
+	return value, nil
+}
+
+// breakMarker ends indefinite-length items
+type breakMarker struct{}
+
+type decoder struct {
+	data   []byte
+	offset int
+}
+
+// head reads the initial byte and argument of an item. Indefinite is set for additional information 31
+func (decoder *decoder) head() (major byte, info byte, argument uint64, indefinite bool, err error) {
+	if decoder.offset >= len(decoder.data) {
+		return 0, 0, 0, false, ErrUnexpectedEnd
+	}
+	initial := decoder.data[decoder.offset]
+	decoder.offset++
+	major, info = initial>>5, initial&0x1f
+
+	var size int
+	switch {
+	case info < 24:
+		return major, info, uint64(info), false, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	case info == 31:
+		return major, info, 0, true, nil
+	default:
+		return 0, 0, 0, false, fmt.Errorf("cbor: reserved additional information %d", info)
+	}
+	if len(decoder.data)-decoder.offset < size {
+		return 0, 0, 0, false, ErrUnexpectedEnd
+	}
+	bytes := decoder.data[decoder.offset : decoder.offset+size]
+	decoder.offset += size
+	switch size {
+	case 1:
+		argument = uint64(bytes[0])
+	case 2:
+		argument = uint64(binary.BigEndian.Uint16(bytes))
+	case 4:
+		argument = uint64(binary.BigEndian.Uint32(bytes))
+	default:
+		argument = binary.BigEndian.Uint64(bytes)
+	}
+	return major, info, argument, false, nil
+}
+
+// length checks a declared number of items against the remaining data. Each item takes at least one byte
+func (decoder *decoder) length(argument uint64) (int, error) {
+	if argument > uint64(len(decoder.data)-decoder.offset) {
+		return 0, ErrUnexpectedEnd
+	}
+	return int(argument), nil
+}
+
+// item decodes a data item. Breaks are only allowed where decode is used to read items of indefinite length
+func (decoder *decoder) item(depth int) (interface{}, error) {
+	value, err := decoder.decode(depth)
+	if err != nil {
+		return nil, err
+	}
+	if _, isBreak := value.(breakMarker); isBreak {
+		return nil, errors.New("cbor: unexpected break")
+	}
+	return value, nil
+}
+
+func (decoder *decoder) decode(depth int) (interface{}, error) {
+	if depth > MaxDepth {
+		return nil, ErrTooDeep
+	}
+	major, info, argument, indefinite, err := decoder.head()
+	if err != nil {
+		return nil, err
+	}
+	if indefinite && (major < 2 || major == 6) {
+		return nil, fmt.Errorf("cbor: indefinite length not allowed for major type %d", major)
+	}
+
+	switch major {
+	case 0:
+		return float64(argument), nil
+	case 1:
+		return -1 - float64(argument), nil
+	case 2:
+		return nil, errors.New("cbor: byte strings are not supported")
+	case 3:
+		return decoder.text(argument, indefinite)
+	case 4:
+		return decoder.array(argument, indefinite, depth)
+	case 5:
+		return decoder.object(argument, indefinite, depth)
+	case 6:
+		content, err := decoder.item(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if argument == 1 {
+			return epochToString(content)
+		}
+		return content, nil
+	}
+	return decoder.simple(info, argument, indefinite)
+}
+
+// text reads a text string. Indefinite-length strings are concatenated from definite-length chunks
+func (decoder *decoder) text(argument uint64, indefinite bool) (string, error) {
+	if !indefinite {
+		size, err := decoder.length(argument)
+		if err != nil {
+			return "", err
+		}
+		text := decoder.data[decoder.offset : decoder.offset+size]
+		decoder.offset += size
+		if !utf8.Valid(text) {
+			return "", errors.New("cbor: text string is not valid UTF-8")
+		}
+		return string(text), nil
+	}
+
+	text := ""
+	for {
+		major, info, chunkArgument, chunkIndefinite, err := decoder.head()
+		if err != nil {
+			return "", err
+		}
+		if major == 7 && info == 31 {
+			return text, nil
+		}
+		if major != 3 || chunkIndefinite {
+			return "", errors.New("cbor: invalid chunk of indefinite-length text string")
+		}
+		chunk, err := decoder.text(chunkArgument, false)
+		if err != nil {
+			return "", err
+		}
+		text += chunk
+	}
+}
+
+func (decoder *decoder) array(argument uint64, indefinite bool, depth int) ([]interface{}, error) {
+	array := []interface{}{}
+	if !indefinite {
+		size, err := decoder.length(argument)
+		if err != nil {
+			return nil, err
+		}
+		array = make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			value, err := decoder.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		return array, nil
+	}
+
+	for {
+		value, err := decoder.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if _, isBreak := value.(breakMarker); isBreak {
+			return array, nil
+		}
+		array = append(array, value)
+	}
+}
+
+func (decoder *decoder) object(argument uint64, indefinite bool, depth int) (map[string]interface{}, error) {
+	size := -1
+	if !indefinite {
+		var err error
+		if size, err = decoder.length(argument); err != nil {
+			return nil, err
+		}
+	}
+
+	object := map[string]interface{}{}
+	for i := 0; size < 0 || i < size; i++ {
+		key, err := decoder.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if _, isBreak := key.(breakMarker); isBreak {
+			if size < 0 {
+				return object, nil
+			}
+			return nil, errors.New("cbor: unexpected break")
+		}
+		keyString, ok := key.(string)
+		if !ok {
+			return nil, errors.New("cbor: map keys must be text strings")
+		}
+		if _, exists := object[keyString]; exists {
+			return nil, fmt.Errorf("cbor: duplicate map key '%s'", keyString)
+		}
+		value, err := decoder.item(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		object[keyString] = value
+	}
+	return object, nil
+}
+
+// simple reads simple values and floats of major type 7
+func (decoder *decoder) simple(info byte, argument uint64, indefinite bool) (interface{}, error) {
+	if indefinite {
+		return breakMarker{}, nil
+	}
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23: // null, undefined
+		return nil, nil
+	case 25:
+		return halfToFloat(uint16(argument)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(argument))), nil
+	case 27:
+		return math.Float64frombits(argument), nil
+	}
+	return nil, fmt.Errorf("cbor: unsupported simple value %d", argument)
+}
+
+// halfToFloat converts an IEEE 754 half-precision float
+func halfToFloat(half uint16) float64 {
+	exponent := int(half>>10) & 0x1f
+	mantissa := float64(half & 0x3ff)
+	var value float64
+	switch exponent {
+	case 0:
+		value = math.Ldexp(mantissa, -24)
+	case 31:
+		if mantissa == 0 {
+			value = math.Inf(1)
+		} else {
+			value = math.NaN()
+		}
+	default:
+		value = math.Ldexp(mantissa+1024, exponent-25)
+	}
+	if half&0x8000 != 0 {
+		return -value
+	}
+	return value
+}
+
+// epochToString converts the content of tag 1 (seconds since 1970-01-01 UTC) into a RFC3339 string
+func epochToString(content interface{}) (string, error) {
+	seconds, ok := content.(float64)
+	if !ok || math.IsNaN(seconds) || math.IsInf(seconds, 0) || math.Abs(seconds) > 1<<53 {
+		return "", errors.New("cbor: epoch-based date/time must be a number")
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(math.Round(fraction*1e3))*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano), nil
+}
@@ -0,0 +1,80 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeHex(t *testing.T, data string) (interface{}, error) {
+	bytes, err := hex.DecodeString(data)
+	assert.NoError(t, err)
+	return Decode(bytes)
+}
+
+func TestDecode(t *testing.T) {
+	// Examples of RFC 8949, Appendix A
+	tests := []struct {
+		data     string
+		expected interface{}
+	}{
+		{"00", float64(0)},
+		{"1903e8", float64(1000)},
+		{"3903e7", float64(-1000)},
+		{"f93e00", 1.5},
+		{"fa47c35000", float64(100000)},
+		{"fb3ff199999999999a", 1.1},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"6449455446", "IETF"},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"83010203", []interface{}{float64(1), float64(2), float64(3)}},
+		{"9f018202039f0405ffff", []interface{}{float64(1), []interface{}{float64(2), float64(3)}, []interface{}{float64(4), float64(5)}}},
+		{"a26161016162820203", map[string]interface{}{"a": float64(1), "b": []interface{}{float64(2), float64(3)}}},
+		{"bf6346756ef563416d7421ff", map[string]interface{}{"Fun": true, "Amt": float64(-2)}},
+		{"c074323031332d30332d32315432303a30343a30305a", "2013-03-21T20:04:00Z"},
+		{"c11a514b67b0", "2013-03-21T20:04:00Z"},
+		{"c1fb41d452d9ec200000", "2013-03-21T20:04:00.5Z"},
+	}
+	for _, test := range tests {
+		value, err := decodeHex(t, test.data)
+		assert.NoError(t, err, test.data)
+		assert.Equal(t, test.expected, value, test.data)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []string{
+		"",                   // Empty
+		"0000",               // Trailing data
+		"19",                 // Argument missing
+		"9b00000000ffffffff", // Declared length exceeding data
+		"62c328",             // Invalid UTF-8
+		"4401020304",         // Byte string
+		"a10101",             // Key not text
+		"a2616101616102",     // Duplicate key
+		"ff",                 // Break outside indefinite item
+		"8201ff",             // Break in definite array
+		"1f",                 // Indefinite unsigned integer
+		"7f01ff",             // Invalid chunk
+		"f0",                 // Unassigned simple value
+	}
+	for _, data := range tests {
+		_, err := decodeHex(t, data)
+		assert.Error(t, err, data)
+	}
+}
+
+func TestDecodeMaxDepth(t *testing.T) {
+	nested := make([]byte, 0, MaxDepth+2)
+	for i := 0; i <= MaxDepth; i++ {
+		nested = append(nested, 0x81) // Array of one item
+	}
+	_, err := Decode(append(nested, 0x00))
+	assert.ErrorIs(t, err, ErrTooDeep)
+
+	_, err = Decode(append(nested[1:], 0x00))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Context binding a derived key to plant logger request signing
+const signingKeyContext = "powerplantmanager plant logger request signing"
+
+// DeriveSigningKey derives the hex encoded HMAC-SHA256 signing key of a plant logger from its secret.
+// Logger and server derive the same key: hex(HMAC-SHA256(key: secret, message: signingKeyContext))
+func DeriveSigningKey(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signingKeyContext))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignHMAC returns the hex encoded HMAC-SHA256 of message using the hex encoded key
+func SignHMAC(keyHex, message string) (string, error) {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// IsHMACValid compares the hex encoded signature with the HMAC-SHA256 of message in constant time
+func IsHMACValid(keyHex, message, signatureHex string) bool {
+	expected, err := SignHMAC(keyHex, message)
+	if err != nil {
+		return false
+	}
+	expectedBytes, _ := hex.DecodeString(expected)
+	signatureBytes, err := hex.DecodeString(signatureHex)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expectedBytes, signatureBytes)
+}
+
+// SHA256Hex returns the hex encoded SHA-256 hash of data
+func SHA256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,70 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// EncryptWithKeyHex encrypts plaintext with AES-GCM using a hex encoded 32 byte key, eg. from .env.
+// Returns the base64 encoded nonce and ciphertext, decryptable with DecryptWithKeyHex.
+func EncryptWithKeyHex(plaintext, keyHex string) (string, error) {
+	gcm, err := newGCMFromKeyHex(keyHex)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return base64.URLEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptWithKeyHex decrypts a value encrypted with EncryptWithKeyHex
+func DecryptWithKeyHex(encodedCiphertext, keyHex string) (string, error) {
+	gcm, err := newGCMFromKeyHex(keyHex)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := base64.URLEncoding.DecodeString(encodedCiphertext)
+	if err != nil {
+		return "", err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return "", errors.New("invalid ciphertext size")
+	}
+
+	nonce := ciphertext[:gcm.NonceSize()]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+func newGCMFromKeyHex(keyHex string) (cipher.AEAD, error) {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the key is of the correct size for AES (32 bytes)
+	if len(key) != 32 {
+		return nil, errors.New("invalid key size")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
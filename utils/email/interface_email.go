@@ -1,6 +1,7 @@
 package email
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -9,13 +10,14 @@ import (
 
 // ///////////////////////////////////////////////////////////////////////
 // Setup interface for email repository utilizing Dependency Injection
+// Sending ends with ctx or after config.EmailTimeoutSec at latest
 // /////////////////////
 type Repository interface {
-	EmailRegistrationSuccess(timeStamp time.Time, email string) error
-	EmailInformUserFailedLogin(timeStamp time.Time, email string) error
-	SendEmail(senderEmail, recipientEmail, subject, body string) error
-	EmailRegistrationVerifiedAccount(timeStamp time.Time, email string) error
-	EmailNewRegistration(timeStamp time.Time, email, verifyLinkValidMinutes, encryptedVerifyToken string) error
+	EmailRegistrationSuccess(ctx context.Context, timeStamp time.Time, email string) error
+	EmailInformUserFailedLogin(ctx context.Context, timeStamp time.Time, email string) error
+	SendEmail(ctx context.Context, senderEmail, recipientEmail, subject, body string) error
+	EmailRegistrationVerifiedAccount(ctx context.Context, timeStamp time.Time, email string) error
+	EmailNewRegistration(ctx context.Context, timeStamp time.Time, email, verifyLinkValidMinutes, encryptedVerifyToken string) error
 }
 
 type MailClient struct {
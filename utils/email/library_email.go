@@ -1,6 +1,7 @@
 package email
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -15,7 +16,7 @@ import (
 // ///////////////////////////////////////
 
 // Registration request for already verified account/email address. Send warning notification to account owner
-func (client *MailClient) EmailRegistrationVerifiedAccount(timeStamp time.Time, email string) error {
+func (client *MailClient) EmailRegistrationVerifiedAccount(ctx context.Context, timeStamp time.Time, email string) error {
 	timeStampStringUs := date.TimeStampToUSFormat(timeStamp)
 	senderEmailAddress, err := envHandler.GetEnvValue(config.EmailAddressSenderEnv, "") // Feel free to use default value via base_config
 	// Log as error if no defaultValue provided in GetEnvValue()
@@ -31,15 +32,15 @@ func (client *MailClient) EmailRegistrationVerifiedAccount(timeStamp time.Time,
 	body := strings.ConcatenateStrings(bodyComponents...)
 
 	// Send
-	err = client.SendEmail(senderEmailAddress, email, subject, body)
+	err = client.SendEmail(ctx, senderEmailAddress, email, subject, body)
 	if err != nil {
-		return fmt.Errorf("Error in 'EmailRegistrationVerifiedAccount()' utilizing 'SendEmail()'. Error: %v", err)
+		return fmt.Errorf("Error in 'EmailRegistrationVerifiedAccount()' utilizing 'SendEmail()'. Error: %w", err)
 	}
 	return nil
 }
 
 // Send verification request for new registration request
-func (client *MailClient) EmailNewRegistration(timeStamp time.Time, email, verifyLinkValidMinutes, encryptedVerifyToken string) error {
+func (client *MailClient) EmailNewRegistration(ctx context.Context, timeStamp time.Time, email, verifyLinkValidMinutes, encryptedVerifyToken string) error {
 	timeStampStringUs := date.TimeStampToUSFormat(timeStamp)
 	senderEmailAddress, err := envHandler.GetEnvValue(config.EmailAddressSenderEnv, "") // Feel free to use default value via base_config
 	// Log as error if no defaultValue provided in GetEnvValue()
@@ -56,15 +57,15 @@ func (client *MailClient) EmailNewRegistration(timeStamp time.Time, email, verif
 	body := strings.ConcatenateStrings(bodyComponents...)
 
 	// Send
-	err = client.SendEmail(senderEmailAddress, email, subject, body)
+	err = client.SendEmail(ctx, senderEmailAddress, email, subject, body)
 	if err != nil {
-		return fmt.Errorf("Error in 'EmailNewRegistration()' utilizing 'SendEmail()'. Error: %v", err)
+		return fmt.Errorf("Error in 'EmailNewRegistration()' utilizing 'SendEmail()'. Error: %w", err)
 	}
 	return nil
 }
 
 // Inform account owner on failed login attempt
-func (client *MailClient) EmailInformUserFailedLogin(timeStamp time.Time, email string) error {
+func (client *MailClient) EmailInformUserFailedLogin(ctx context.Context, timeStamp time.Time, email string) error {
 	timeStampStringUs := date.TimeStampToUSFormat(timeStamp)
 	senderEmailAddress, err := envHandler.GetEnvValue(config.EmailAddressSenderEnv, "") // Feel free to use default value via base_config
 	// Log as error if no defaultValue provided in GetEnvValue()
@@ -80,15 +81,15 @@ func (client *MailClient) EmailInformUserFailedLogin(timeStamp time.Time, email
 	body := strings.ConcatenateStrings(bodyComponents...)
 
 	// Send
-	err = client.SendEmail(senderEmailAddress, email, subject, body)
+	err = client.SendEmail(ctx, senderEmailAddress, email, subject, body)
 	if err != nil {
-		return fmt.Errorf("Error in 'EmailInformUserFailedLogin()' utilizing 'SendEmail()'. Error: %v", err)
+		return fmt.Errorf("Error in 'EmailInformUserFailedLogin()' utilizing 'SendEmail()'. Error: %w", err)
 	}
 	return nil
 }
 
 // Send email after successfully verified registration
-func (client *MailClient) EmailRegistrationSuccess(timeStamp time.Time, email string) error {
+func (client *MailClient) EmailRegistrationSuccess(ctx context.Context, timeStamp time.Time, email string) error {
 	timeStampStringUs := date.TimeStampToUSFormat(timeStamp)
 
 	// Subject
@@ -106,9 +107,9 @@ func (client *MailClient) EmailRegistrationSuccess(timeStamp time.Time, email st
 	}
 
 	// Send
-	err = client.SendEmail(senderEmailAddress, email, subject, body)
+	err = client.SendEmail(ctx, senderEmailAddress, email, subject, body)
 	if err != nil {
-		return fmt.Errorf("Error in 'EmailRegistrationSuccess()' utilizing 'SendEmail()'. Error: %v", err)
+		return fmt.Errorf("Error in 'EmailRegistrationSuccess()' utilizing 'SendEmail()'. Error: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/paulmuenzner/powerplantmanager/config"
+	"github.com/paulmuenzner/powerplantmanager/utils/date"
+	email "github.com/paulmuenzner/powerplantmanager/utils/email"
+	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
+)
+
+// Email is an email kept instead of sent
+type Email struct {
+	Sender    string
+	Recipient string
+	Subject   string
+	Body      string
+}
+
+// //////////////////////////////////////////////////////////////////////
+// In-memory implementation of email.Repository for tests and local development without SMTP provider.
+// Emails are kept and logged instead of sent, eg. to open verification links. Operations fail if ctx has ended before they start.
+// ///////////////////
+type Repository struct {
+	mutex  sync.Mutex
+	emails []Email
+}
+
+var _ email.Repository = &Repository{}
+
+// Sender of the emails kept
+const sender = "dev@localhost"
+
+// New returns an empty in-memory repository
+func New() *Repository {
+	return &Repository{}
+}
+
+// NewRepositoryInterface returns the repository interface of an empty in-memory repository, eg. to run the API with '-dev' or in tests
+func NewRepositoryInterface() *email.RepositoryInterface {
+	return &email.RepositoryInterface{RepositoryInterface: New()}
+}
+
+// Emails returns the emails kept, oldest first
+func (r *Repository) Emails() []Email {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]Email{}, r.emails...)
+}
+
+func (r *Repository) SendEmail(ctx context.Context, senderEmail, recipientEmail, subject, body string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.emails = append(r.emails, Email{Sender: senderEmail, Recipient: recipientEmail, Subject: subject, Body: body})
+	logger.GetLogger().Infof("Email to %s not sent, kept in memory. Subject: %s. Body: %s", recipientEmail, subject, body)
+	return nil
+}
+
+func (r *Repository) EmailRegistrationSuccess(ctx context.Context, timeStamp time.Time, recipientEmail string) error {
+	body := fmt.Sprintf("Account verified at %s.", date.TimeStampToUSFormat(timeStamp))
+	return r.SendEmail(ctx, sender, recipientEmail, "Registration successful.", body)
+}
+
+func (r *Repository) EmailInformUserFailedLogin(ctx context.Context, timeStamp time.Time, recipientEmail string) error {
+	body := fmt.Sprintf("Failed login attempt at %s.", date.TimeStampToUSFormat(timeStamp))
+	return r.SendEmail(ctx, sender, recipientEmail, "Failed login attempt!", body)
+}
+
+func (r *Repository) EmailRegistrationVerifiedAccount(ctx context.Context, timeStamp time.Time, recipientEmail string) error {
+	body := fmt.Sprintf("Registration request for verified account at %s.", date.TimeStampToUSFormat(timeStamp))
+	return r.SendEmail(ctx, sender, recipientEmail, "Attention! Registration request.", body)
+}
+
+func (r *Repository) EmailNewRegistration(ctx context.Context, timeStamp time.Time, recipientEmail, verifyLinkValidMinutes, encryptedVerifyToken string) error {
+	body := fmt.Sprintf("Registration request at %s. Verification link, valid %s minutes: %s/auth/verify/%s", date.TimeStampToUSFormat(timeStamp), verifyLinkValidMinutes, config.URL, encryptedVerifyToken)
+	return r.SendEmail(ctx, sender, recipientEmail, "Verify your new account.", body)
+}
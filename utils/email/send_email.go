@@ -1,8 +1,19 @@
 package email
 
-import "gopkg.in/gomail.v2"
+import (
+	"context"
+	"time"
+
+	"github.com/paulmuenzner/powerplantmanager/config"
+
+	"gopkg.in/gomail.v2"
+)
+
+// SendEmail sends an email. The SMTP client can't be canceled, so an email may still be sent after ctx ended or the deadline passed
+func (client *MailClient) SendEmail(ctx context.Context, senderEmail, recipientEmail, subject, body string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.EmailTimeoutSec)*time.Second)
+	defer cancel()
 
-func (client *MailClient) SendEmail(senderEmail, recipientEmail, subject, body string) error {
 	m := gomail.NewMessage()
 	m.SetHeader("From", senderEmail)
 	m.SetHeader("To", recipientEmail)
@@ -14,8 +25,17 @@ func (client *MailClient) SendEmail(senderEmail, recipientEmail, subject, body s
 	m.SetBody("text/html", body)
 
 	// Send
-	if err := client.MyEmailClient.DialAndSend(m); err != nil {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	sent := make(chan error, 1)
+	go func() {
+		sent <- client.MyEmailClient.DialAndSend(m)
+	}()
+	select {
+	case err := <-sent:
 		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return nil
 }
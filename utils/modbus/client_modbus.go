@@ -0,0 +1,108 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Modbus TCP client limited to what polling measurement devices needs: reading holding registers (function code 0x03)
+
+const (
+	functionReadHoldingRegisters byte   = 0x03
+	exceptionFlag                byte   = 0x80
+	MaxRegistersPerRead          uint16 = 125 // Limit of the Modbus specification per request
+	mbapHeaderLength             int    = 7
+)
+
+var ErrResponseInvalid = errors.New("invalid modbus response")
+
+// ExceptionError is returned if the device answers with a Modbus exception, eg. code 2 for an illegal data address
+type ExceptionError struct {
+	Code byte
+}
+
+func (exception *ExceptionError) Error() string {
+	return fmt.Sprintf("modbus exception code %d", exception.Code)
+}
+
+// Client of one Modbus TCP device (unit). Not safe for concurrent use
+type Client struct {
+	conn          net.Conn
+	unitID        byte
+	timeout       time.Duration
+	deadline      time.Time // Deadline of ctx passed to Dial, zero if none
+	transactionID uint16
+}
+
+// Dial connects to the Modbus TCP device at address (host:port) with dialer. Each request must be answered within timeout and before the deadline of ctx.
+func Dial(ctx context.Context, dialer *net.Dialer, address string, unitID byte, timeout time.Duration) (*Client, error) {
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	return &Client{conn: conn, unitID: unitID, timeout: timeout, deadline: deadline}, nil
+}
+
+// Close closes the connection to the device
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
+
+// ReadHoldingRegisters reads quantity holding registers starting at address
+func (client *Client) ReadHoldingRegisters(address, quantity uint16) ([]uint16, error) {
+	if quantity == 0 || quantity > MaxRegistersPerRead {
+		return nil, fmt.Errorf("quantity of registers must be between 1 and %d", MaxRegistersPerRead)
+	}
+	deadline := time.Now().Add(client.timeout)
+	if !client.deadline.IsZero() && client.deadline.Before(deadline) {
+		deadline = client.deadline
+	}
+	if err := client.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	// Request: MBAP header (transaction id, protocol id 0, length, unit id) followed by function code, start address and quantity
+	client.transactionID++
+	request := make([]byte, mbapHeaderLength+5)
+	binary.BigEndian.PutUint16(request[0:], client.transactionID)
+	binary.BigEndian.PutUint16(request[4:], 6)
+	request[6] = client.unitID
+	request[7] = functionReadHoldingRegisters
+	binary.BigEndian.PutUint16(request[8:], address)
+	binary.BigEndian.PutUint16(request[10:], quantity)
+	if _, err := client.conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, mbapHeaderLength)
+	if _, err := io.ReadFull(client.conn, header); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[4:]))
+	if binary.BigEndian.Uint16(header[0:]) != client.transactionID || length < 3 || length > 256 {
+		return nil, ErrResponseInvalid
+	}
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(client.conn, pdu); err != nil {
+		return nil, err
+	}
+
+	if pdu[0] == functionReadHoldingRegisters|exceptionFlag {
+		return nil, &ExceptionError{Code: pdu[1]}
+	}
+	if pdu[0] != functionReadHoldingRegisters || int(pdu[1]) != 2*int(quantity) || len(pdu) != 2+2*int(quantity) {
+		return nil, ErrResponseInvalid
+	}
+
+	registers := make([]uint16, quantity)
+	for i := range registers {
+		registers[i] = binary.BigEndian.Uint16(pdu[2+2*i:])
+	}
+	return registers, nil
+}
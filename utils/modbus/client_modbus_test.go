@@ -0,0 +1,48 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadHoldingRegisters(t *testing.T) {
+	simulator, err := NewSimulator("127.0.0.1:0", map[uint16]uint16{40000: 0x5375, 40001: 0x6e53, 40002: 1})
+	require.NoError(t, err)
+	defer simulator.Close()
+
+	client, err := Dial(context.Background(), &net.Dialer{}, simulator.Address(), 1, time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Not connected once ctx has ended
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Dial(canceledCtx, &net.Dialer{}, simulator.Address(), 1, time.Second)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	registers, err := client.ReadHoldingRegisters(40000, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{0x5375, 0x6e53, 1}, registers)
+
+	// Registers changed in between are read on the same connection
+	simulator.SetRegisters(40002, 7)
+	registers, err = client.ReadHoldingRegisters(40002, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{7}, registers)
+
+	// Not existing address
+	_, err = client.ReadHoldingRegisters(40001, 3)
+	var exception *ExceptionError
+	assert.True(t, errors.As(err, &exception))
+	assert.Equal(t, byte(2), exception.Code)
+
+	// Quantity beyond Modbus limit
+	_, err = client.ReadHoldingRegisters(40000, MaxRegistersPerRead+1)
+	assert.Error(t, err)
+}
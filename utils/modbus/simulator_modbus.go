@@ -0,0 +1,113 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+)
+
+// Simulator is a local Modbus TCP device serving fixed holding registers, eg. to test polling without hardware.
+// Reading registers not set is answered with exception code 2 (illegal data address), as real devices do.
+type Simulator struct {
+	listener  net.Listener
+	mutex     sync.Mutex
+	registers map[uint16]uint16
+	waitGroup sync.WaitGroup
+}
+
+// NewSimulator starts a simulator listening on address, eg. '127.0.0.1:0' for a free port
+func NewSimulator(address string, registers map[uint16]uint16) (*Simulator, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	simulator := &Simulator{listener: listener, registers: registers}
+	simulator.waitGroup.Add(1)
+	go simulator.serve()
+	return simulator, nil
+}
+
+// Address returns host and port the simulator listens on
+func (simulator *Simulator) Address() string {
+	return simulator.listener.Addr().String()
+}
+
+// SetRegisters sets holding registers starting at address
+func (simulator *Simulator) SetRegisters(address uint16, values ...uint16) {
+	simulator.mutex.Lock()
+	defer simulator.mutex.Unlock()
+	for i, value := range values {
+		simulator.registers[address+uint16(i)] = value
+	}
+}
+
+// Close stops the simulator
+func (simulator *Simulator) Close() error {
+	err := simulator.listener.Close()
+	simulator.waitGroup.Wait()
+	return err
+}
+
+func (simulator *Simulator) serve() {
+	defer simulator.waitGroup.Done()
+	for {
+		conn, err := simulator.listener.Accept()
+		if err != nil {
+			return
+		}
+		go simulator.handle(conn)
+	}
+}
+
+// handle answers requests of one connection until closed by the client
+func (simulator *Simulator) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		header := make([]byte, mbapHeaderLength)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(header[4:]))
+		if length < 2 || length > 256 {
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+
+		response := simulator.respond(pdu)
+		binary.BigEndian.PutUint16(header[4:], uint16(len(response)+1))
+		if _, err := conn.Write(append(header, response...)); err != nil {
+			return
+		}
+	}
+}
+
+// respond returns the response pdu to a request pdu
+func (simulator *Simulator) respond(pdu []byte) []byte {
+	if pdu[0] != functionReadHoldingRegisters {
+		return []byte{pdu[0] | exceptionFlag, 1} // Illegal function
+	}
+	if len(pdu) != 5 {
+		return []byte{pdu[0] | exceptionFlag, 3} // Illegal data value
+	}
+	address := binary.BigEndian.Uint16(pdu[1:])
+	quantity := binary.BigEndian.Uint16(pdu[3:])
+	if quantity == 0 || quantity > MaxRegistersPerRead {
+		return []byte{pdu[0] | exceptionFlag, 3}
+	}
+
+	simulator.mutex.Lock()
+	defer simulator.mutex.Unlock()
+	response := []byte{pdu[0], byte(2 * quantity)}
+	for i := uint16(0); i < quantity; i++ {
+		value, exists := simulator.registers[address+i]
+		if !exists {
+			return []byte{pdu[0] | exceptionFlag, 2} // Illegal data address
+		}
+		response = binary.BigEndian.AppendUint16(response, value)
+	}
+	return response
+}
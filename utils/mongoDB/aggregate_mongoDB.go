@@ -0,0 +1,76 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AggregateInMongo runs an aggregation pipeline on collection and decodes the resulting documents into result, a pointer to a slice.
+// Result may be nil for pipelines writing their output with '$merge' or '$out'.
+func (client *Client) AggregateInMongo(ctx context.Context, databaseName string, collection string, pipeline mongo.Pipeline, result interface{}) error {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseAggregateTimeoutSec)
+	defer cancel()
+
+	// Select the database and collection
+	db := client.MongoDB.Database(databaseName)
+	col := db.Collection(collection)
+
+	cursor, err := col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("Error when aggregating documents in collection '%s' of database '%s' in 'AggregateInMongo()' using 'Aggregate()'. Error: %w", collection, databaseName, err)
+	}
+	defer cursor.Close(ctx)
+
+	if result == nil {
+		return nil
+	}
+	if err := cursor.All(ctx, result); err != nil {
+		return fmt.Errorf("Error when decoding aggregation result of collection '%s' of database '%s' in 'AggregateInMongo()' using 'All()'. Error: %w", collection, databaseName, err)
+	}
+	return nil
+}
+
+// AggregateCursorInMongo runs an aggregation pipeline on collection and returns a cursor of the resulting documents, fetched batchSize at a time
+// (server default if 0). As with FindCursorInMongo, the deadline applies to each batch fetched
+func (client *Client) AggregateCursorInMongo(ctx context.Context, databaseName string, collection string, pipeline mongo.Pipeline, batchSize int32) (Cursor, error) {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseAggregateTimeoutSec)
+	defer cancel()
+
+	col := client.MongoDB.Database(databaseName).Collection(collection)
+	opts := options.Aggregate()
+	if batchSize > 0 {
+		opts.SetBatchSize(batchSize)
+	}
+	cur, err := col.Aggregate(ctx, pipeline, opts)
+	if err != nil {
+		return nil, fmt.Errorf("Error when aggregating documents in collection '%s' of database '%s' in 'AggregateCursorInMongo()' using 'Aggregate()'. Error: %w", collection, databaseName, err)
+	}
+	return &cursor{Cursor: cur, client: client, timeoutSec: config.DatabaseAggregateTimeoutSec}, nil
+}
+
+// Error codes of pipelines with a stage, accumulator or expression unknown to the server
+var unsupportedPipelineCodes = []int{
+	15952, // Unknown group operator, eg. '$percentile' before MongoDB 7.0
+	40324, // Unrecognized pipeline stage name
+	168,   // InvalidPipelineOperator, unknown expression
+}
+
+// IsUnsupportedPipeline returns true if err is caused by a pipeline the server doesn't support, as it's older than the pipeline needs
+func IsUnsupportedPipeline(err error) bool {
+	var serverError mongo.ServerError
+	if !errors.As(err, &serverError) {
+		return false
+	}
+	for _, code := range unsupportedPipelineCodes {
+		if serverError.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
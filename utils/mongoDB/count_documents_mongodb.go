@@ -3,13 +3,16 @@ package mongodb
 import (
 	"context"
 
+	config "github.com/paulmuenzner/powerplantmanager/config"
 	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func (client *Client) CountDocumentsInMongo(databaseName string, collection string, result interface{}) (int, error) {
+func (client *Client) CountDocumentsInMongo(ctx context.Context, databaseName string, collection string, result interface{}) (int, error) {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseQueryTimeoutSec)
+	defer cancel()
 
 	// Create a session for the database
 	session, err := client.MongoDB.StartSession()
@@ -23,7 +26,7 @@ func (client *Client) CountDocumentsInMongo(databaseName string, collection stri
 	col := db.Collection(collection)
 
 	// Insert the data into the collection
-	result, err = col.CountDocuments(context.Background(), bson.D{})
+	result, err = col.CountDocuments(ctx, bson.D{})
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return 0, err
@@ -2,14 +2,19 @@ package mongodb
 
 import (
 	"context"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
 )
 
-func (client *Client) CreateNewCollection(databaseName, collectionName string) error {
+func (client *Client) CreateNewCollection(ctx context.Context, databaseName, collectionName string) error {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseAdminTimeoutSec)
+	defer cancel()
+
 	// Access the specified database
 	database := client.MongoDB.Database(databaseName)
 
 	// Create a new collection
-	err := database.CreateCollection(context.Background(), collectionName)
+	err := database.CreateCollection(ctx, collectionName)
 	if err != nil {
 		return err
 	}
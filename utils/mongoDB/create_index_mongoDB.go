@@ -0,0 +1,28 @@
+package mongodb
+
+import (
+	"context"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CreateIndex creates a non-unique ascending index on fieldNames, a compound index if more than one. Creating an already existing index is a no-op.
+// Time-series collections support such secondary indexes, but no unique indexes.
+func (client *Client) CreateIndex(ctx context.Context, collectionName string, databaseName string, fieldNames ...string) error {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseAdminTimeoutSec)
+	defer cancel()
+
+	db := client.MongoDB.Database(databaseName)
+	col := db.Collection(collectionName)
+
+	keys := bson.D{}
+	for _, fieldName := range fieldNames {
+		keys = append(keys, bson.E{Key: fieldName, Value: 1})
+	}
+
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys})
+	return err
+}
@@ -0,0 +1,41 @@
+package mongodb
+
+import (
+	"context"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreatePartialUniqueIndex creates a unique index on fieldName only covering documents containing the field.
+// Documents without the field are not affected by the uniqueness constraint. Creating an already existing index is a no-op.
+// Optional scopeFieldNames precede fieldName in a compound index, so fieldName is only unique per value of the scope fields.
+func (client *Client) CreatePartialUniqueIndex(ctx context.Context, collectionName string, databaseName string, fieldName string, scopeFieldNames ...string) error {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseAdminTimeoutSec)
+	defer cancel()
+
+	db := client.MongoDB.Database(databaseName)
+	col := db.Collection(collectionName)
+
+	indexOptions := options.Index().
+		SetUnique(true).
+		SetPartialFilterExpression(bson.M{fieldName: bson.M{"$exists": true}})
+
+	keys := bson.D{}
+	for _, scopeFieldName := range scopeFieldNames {
+		keys = append(keys, bson.E{Key: scopeFieldName, Value: 1})
+	}
+	keys = append(keys, bson.E{Key: fieldName, Value: 1})
+
+	// Create the index model
+	indexModel := mongo.IndexModel{
+		Keys:    keys,
+		Options: indexOptions,
+	}
+
+	_, err := col.Indexes().CreateOne(ctx, indexModel)
+	return err
+}
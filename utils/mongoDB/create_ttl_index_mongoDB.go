@@ -0,0 +1,28 @@
+package mongodb
+
+import (
+	"context"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateTTLIndex creates an index on the date field fieldName. MongoDB removes documents expireAfterSec seconds after this date.
+func (client *Client) CreateTTLIndex(ctx context.Context, collectionName string, databaseName string, fieldName string, expireAfterSec int32) error {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseAdminTimeoutSec)
+	defer cancel()
+
+	db := client.MongoDB.Database(databaseName)
+	col := db.Collection(collectionName)
+
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: fieldName, Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(expireAfterSec),
+	}
+
+	_, err := col.Indexes().CreateOne(ctx, indexModel)
+	return err
+}
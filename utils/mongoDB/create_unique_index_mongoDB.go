@@ -3,11 +3,16 @@ package mongodb
 import (
 	"context"
 
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func (client *Client) CreateUniqueIndex(collectionName string, databaseName string, fieldName string, unique bool) error {
+func (client *Client) CreateUniqueIndex(ctx context.Context, collectionName string, databaseName string, fieldName string, unique bool) error {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseAdminTimeoutSec)
+	defer cancel()
+
 	// Create a unique index on the email field
 	db := client.MongoDB.Database(databaseName)
 	col := db.Collection(collectionName)
@@ -21,6 +26,6 @@ func (client *Client) CreateUniqueIndex(collectionName string, databaseName stri
 		Options: indexOptions,
 	}
 
-	_, err := col.Indexes().CreateOne(context.Background(), indexModel)
+	_, err := col.Indexes().CreateOne(ctx, indexModel)
 	return err
 }
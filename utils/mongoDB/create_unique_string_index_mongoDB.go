@@ -0,0 +1,33 @@
+package mongodb
+
+import (
+	"context"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateUniqueStringIndex creates a unique index on fieldName only covering documents with a non-empty string in the field.
+// Documents with an empty string, eg. plants without key before key and secret are created, are not affected by the uniqueness constraint. Creating an already existing index is a no-op.
+func (client *Client) CreateUniqueStringIndex(ctx context.Context, collectionName string, databaseName string, fieldName string) error {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseAdminTimeoutSec)
+	defer cancel()
+
+	db := client.MongoDB.Database(databaseName)
+	col := db.Collection(collectionName)
+
+	indexOptions := options.Index().
+		SetUnique(true).
+		SetPartialFilterExpression(bson.M{fieldName: bson.M{"$gt": ""}})
+
+	indexModel := mongo.IndexModel{
+		Keys:    bson.D{{Key: fieldName, Value: 1}},
+		Options: indexOptions,
+	}
+
+	_, err := col.Indexes().CreateOne(ctx, indexModel)
+	return err
+}
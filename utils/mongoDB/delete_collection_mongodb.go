@@ -3,12 +3,15 @@ package mongodb
 import (
 	"context"
 
+	config "github.com/paulmuenzner/powerplantmanager/config"
 	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
 
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func (client *Client) DeleteCollectionMongo(databaseName string, collection string) error {
+func (client *Client) DeleteCollectionMongo(ctx context.Context, databaseName string, collection string) error {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseAdminTimeoutSec)
+	defer cancel()
 
 	// Create a session for the database
 	session, err := client.MongoDB.StartSession()
@@ -21,7 +24,7 @@ func (client *Client) DeleteCollectionMongo(databaseName string, collection stri
 	col := client.MongoDB.Database(databaseName).Collection(collection)
 
 	// Delecte collection
-	err = col.Drop(context.Background())
+	err = col.Drop(ctx)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			logger.GetLogger().Infof("No matching document found in collection '%s' of database '%s' Error: %v", collection, databaseName, err)
@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func (client *Client) DeleteDocumentMongo(databaseName string, filter bson.M, collection string) (interface{}, error) {
+func (client *Client) DeleteDocumentMongo(ctx context.Context, databaseName string, filter bson.M, collection string) (interface{}, error) {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseWriteTimeoutSec)
+	defer cancel()
 
 	// Create a session for the database
 	session, err := client.MongoDB.StartSession()
@@ -22,12 +26,12 @@ func (client *Client) DeleteDocumentMongo(databaseName string, filter bson.M, co
 	col := db.Collection(collection)
 
 	// Delete document from collection
-	result, err := col.DeleteOne(context.TODO(), filter)
+	result, err := col.DeleteOne(ctx, filter)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, fmt.Errorf("No matching document found in collection '%s' of database '%s' in 'DeleteDocumentMongo()' using 'DeleteOne()'. Filter: %+v Error: %v", collection, databaseName, filter, err)
+			return nil, fmt.Errorf("No matching document found in collection '%s' of database '%s' in 'DeleteDocumentMongo()' using 'DeleteOne()'. Filter: %+v Error: %w", collection, databaseName, filter, err)
 		}
-		return nil, fmt.Errorf("Error when deleting document in collection '%s' of database '%s' in 'DeleteDocumentMongo()' using 'DeleteOne()'. Filter: %+v Error: %v", collection, databaseName, filter, err)
+		return nil, fmt.Errorf("Error when deleting document in collection '%s' of database '%s' in 'DeleteDocumentMongo()' using 'DeleteOne()'. Filter: %+v Error: %w", collection, databaseName, filter, err)
 	}
 
 	return result, nil
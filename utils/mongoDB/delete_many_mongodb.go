@@ -0,0 +1,28 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DeleteManyMongo deletes all documents matching filter. Returns the number of deleted documents
+func (client *Client) DeleteManyMongo(ctx context.Context, databaseName string, filter bson.M, collection string) (int64, error) {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseWriteTimeoutSec)
+	defer cancel()
+
+	// Select the database and collection
+	db := client.MongoDB.Database(databaseName)
+	col := db.Collection(collection)
+
+	// Delete documents from collection
+	result, err := col.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("Error when deleting documents in collection '%s' of database '%s' in 'DeleteManyMongo()' using 'DeleteMany()'. Filter: %+v Error: %w", collection, databaseName, filter, err)
+	}
+
+	return result.DeletedCount, nil
+}
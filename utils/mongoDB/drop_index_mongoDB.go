@@ -0,0 +1,26 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DropIndex drops the index indexName, eg. 'sequence_1'. Dropping a non-existing index or an index of a non-existing collection is a no-op.
+func (client *Client) DropIndex(ctx context.Context, collectionName string, databaseName string, indexName string) error {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseAdminTimeoutSec)
+	defer cancel()
+
+	db := client.MongoDB.Database(databaseName)
+	col := db.Collection(collectionName)
+
+	_, err := col.Indexes().DropOne(ctx, indexName)
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && (commandErr.Name == "IndexNotFound" || commandErr.Name == "NamespaceNotFound") {
+		return nil
+	}
+	return err
+}
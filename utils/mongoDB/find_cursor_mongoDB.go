@@ -0,0 +1,68 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Options of FindCursorInMongo. Zero values don't restrict
+type FindOptions struct {
+	Projection bson.M // Fields returned, eg. bson.M{"measured_at": 1}. All fields if nil
+	Sort       bson.D
+	Skip       int64
+	Limit      int64 // All documents if 0
+	BatchSize  int32 // Documents fetched per round trip. Server default if 0
+}
+
+// Cursor iterates the documents found one at a time. Documents are fetched in batches, only the current batch is held in memory.
+// Next returns false once all documents have been read or an error occurred, see Err. Close the cursor when done.
+type Cursor interface {
+	Next(ctx context.Context) bool
+	Decode(result interface{}) error
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// FindCursorInMongo returns a cursor of the documents of collection matching filter. Unlike FindManyInMongo, the deadline applies to each batch
+// fetched, so any number of documents can be read
+func (client *Client) FindCursorInMongo(ctx context.Context, databaseName string, filter bson.M, collection string, findOptions FindOptions) (Cursor, error) {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseQueryTimeoutSec)
+	defer cancel()
+
+	col := client.MongoDB.Database(databaseName).Collection(collection)
+	opts := options.Find().SetSkip(findOptions.Skip).SetLimit(findOptions.Limit)
+	if findOptions.Projection != nil {
+		opts.SetProjection(findOptions.Projection)
+	}
+	if findOptions.Sort != nil {
+		opts.SetSort(findOptions.Sort)
+	}
+	if findOptions.BatchSize > 0 {
+		opts.SetBatchSize(findOptions.BatchSize)
+	}
+
+	cur, err := col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("Error when querying collection '%s' of database '%s' in 'FindCursorInMongo()' using 'Find()'. Error: %w", collection, databaseName, err)
+	}
+	return &cursor{Cursor: cur, client: client, timeoutSec: config.DatabaseQueryTimeoutSec}, nil
+}
+
+// cursor applies the deadline of its operation to each call of Next, which fetches the next batch when the current one is read
+type cursor struct {
+	*mongo.Cursor
+	client     *Client
+	timeoutSec int
+}
+
+func (c *cursor) Next(ctx context.Context) bool {
+	ctx, cancel := c.client.operationContext(ctx, c.timeoutSec)
+	defer cancel()
+	return c.Cursor.Next(ctx)
+}
@@ -3,6 +3,7 @@ package mongodb
 import (
 	"context"
 
+	config "github.com/paulmuenzner/powerplantmanager/config"
 	logger "github.com/paulmuenzner/powerplantmanager/utils/logs"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -10,7 +11,9 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func (client *Client) FindManyInMongo(databaseName string, filter bson.M, collection string, sort bson.D, result interface{}) error {
+func (client *Client) FindManyInMongo(ctx context.Context, databaseName string, filter bson.M, collection string, sort bson.D, result interface{}) error {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseQueryTimeoutSec)
+	defer cancel()
 
 	// Create a session for the database
 	session, err := client.MongoDB.StartSession()
@@ -27,7 +30,7 @@ func (client *Client) FindManyInMongo(databaseName string, filter bson.M, collec
 	options := options.Find().SetSort(sort)
 
 	// Insert the data into the collection
-	cur, err := col.Find(context.Background(), filter, options)
+	cur, err := col.Find(ctx, filter, options)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return err
@@ -35,10 +38,10 @@ func (client *Client) FindManyInMongo(databaseName string, filter bson.M, collec
 		logger.GetLogger().Error("Error when querying collection "+collection+" of database "+databaseName+" in 'FindManyInMongo'.  Error: ", err)
 		return err
 	}
-	defer cur.Close(context.Background())
+	defer cur.Close(ctx)
 
 	// Decode the results into the provided result interface
-	if err := cur.All(context.Background(), result); err != nil {
+	if err := cur.All(ctx, result); err != nil {
 		logger.GetLogger().Error("Error when decoding documents "+collection+" of database "+databaseName+" in 'FindManyInMongo'. Error: ", err)
 		return err
 	}
@@ -4,12 +4,17 @@ import (
 	"context"
 	"fmt"
 
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func (client *Client) FindOneInMongo(databaseName string, filter bson.M, collection string, sort bson.D, result interface{}) (foundOne bool, err error) {
+func (client *Client) FindOneInMongo(ctx context.Context, databaseName string, filter bson.M, collection string, sort bson.D, result interface{}) (foundOne bool, err error) {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseQueryTimeoutSec)
+	defer cancel()
+
 	// Create a session for the database
 	session, err := client.MongoDB.StartSession()
 	if err != nil {
@@ -25,12 +30,12 @@ func (client *Client) FindOneInMongo(databaseName string, filter bson.M, collect
 	options := options.FindOne().SetSort(sort)
 
 	// Execute query
-	err = col.FindOne(context.Background(), filter, options).Decode(result)
+	err = col.FindOne(ctx, filter, options).Decode(result)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return false, nil
 		}
-		return false, fmt.Errorf("%v", err)
+		return false, fmt.Errorf("%w", err)
 	}
 
 	return true, nil
@@ -3,14 +3,19 @@ package mongodb
 import (
 	"context"
 
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
 	"go.mongodb.org/mongo-driver/bson"
 )
 
 // Check if value for field name (key) exists in collection (eg. is email address xyz already registered)
-func (client *Client) IsValueInCollection(databaseName, collectionName, fieldName, fieldValue string) (bool, error) {
+func (client *Client) IsValueInCollection(ctx context.Context, databaseName, collectionName, fieldName, fieldValue string) (bool, error) {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseQueryTimeoutSec)
+	defer cancel()
+
 	db := client.MongoDB.Database(databaseName)
 	col := db.Collection(collectionName)
 	filter := bson.M{fieldName: fieldValue}
-	count, err := col.CountDocuments(context.Background(), filter)
+	count, err := col.CountDocuments(ctx, filter)
 	return count != 0, err
 }
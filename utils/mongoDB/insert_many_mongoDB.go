@@ -0,0 +1,52 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InsertManyToMongo inserts all documents with one bulk write. The insert is unordered, so a failing document does not stop the remaining ones.
+// On partial failure the returned error is a mongo.BulkWriteException carrying the index of each failed document. Returned IDs then still cover all documents of data.
+func (client *Client) InsertManyToMongo(ctx context.Context, databaseName string, data []interface{}, collection string) ([]string, error) {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseWriteTimeoutSec)
+	defer cancel()
+
+	// Create a session for the database
+	session, err := client.MongoDB.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(context.Background())
+
+	// Select the database and collection
+	db := client.MongoDB.Database(databaseName)
+	col := db.Collection(collection)
+
+	// Insert the data into the collection
+	options := options.InsertMany().SetOrdered(false)
+	result, err := col.InsertMany(ctx, data, options)
+	if result == nil {
+		return nil, err
+	}
+
+	// Convert the inserted IDs to string
+	insertedIDs := make([]string, 0, len(result.InsertedIDs))
+	for _, insertedID := range result.InsertedIDs {
+		switch id := insertedID.(type) {
+		case primitive.ObjectID:
+			insertedIDs = append(insertedIDs, id.Hex())
+		case string:
+			insertedIDs = append(insertedIDs, id)
+		default:
+			return insertedIDs, fmt.Errorf("unexpected type for InsertedID in 'InsertManyToMongo()': %T", id)
+		}
+	}
+
+	// Return the IDs of the inserted documents and a potential bulk write error
+	return insertedIDs, err
+}
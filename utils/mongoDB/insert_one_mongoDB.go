@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func (client *Client) InsertOneToMongo(databaseName string, data interface{}, collection string) (string, error) {
+func (client *Client) InsertOneToMongo(ctx context.Context, databaseName string, data interface{}, collection string) (string, error) {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseWriteTimeoutSec)
+	defer cancel()
 
 	// Create a session for the database
 	session, err := client.MongoDB.StartSession()
@@ -21,7 +25,7 @@ func (client *Client) InsertOneToMongo(databaseName string, data interface{}, co
 	col := db.Collection(collection)
 
 	// Insert the data into the collection
-	result, err := col.InsertOne(context.Background(), data)
+	result, err := col.InsertOne(ctx, data)
 	if err != nil {
 		return "", err
 	}
@@ -33,6 +37,8 @@ func (client *Client) InsertOneToMongo(databaseName string, data interface{}, co
 		insertedID = id.Hex()
 	case string:
 		insertedID = id
+	case int32, int64, int:
+		insertedID = fmt.Sprint(id)
 	default:
 		return "", fmt.Errorf("unexpected type for InsertedID in 'InsertOneToMongo()' using : %T", id)
 	}
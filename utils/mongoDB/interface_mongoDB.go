@@ -1,29 +1,50 @@
 package mongodb
 
 import (
+	"context"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // //////////////////////////////////////////////////////////////////////
 // Setup interface for database repository utilizing Dependency Injection
+// Operations end with ctx, eg. the request context, or after the deadline of their kind configured in config at latest
 // ///////////////////
 type Repository interface {
-	InsertOneToMongo(databaseName string, data interface{}, collection string) (string, error)
-	IsValueInCollection(databaseName string, collectionName string, fieldName, fieldValue string) (bool, error)
-	UpdateOneInMongo(databaseName string, filter bson.M, update bson.M, collection string) (*mongo.UpdateResult, error)
-	FindOneInMongo(databaseName string, filter bson.M, collection string, sort bson.D, result interface{}) (foundOne bool, err error)
-	FindManyInMongo(databaseName string, filter bson.M, collection string, sort bson.D, result interface{}) error
-	DeleteDocumentMongo(databaseName string, filter bson.M, collection string) (interface{}, error)
-	DeleteCollectionMongo(databaseName string, collection string) error
-	CreateNewCollection(databaseName, collectionName string) error
-	CountDocumentsInMongo(databaseName string, collection string, result interface{}) (int, error)
-	CreateUniqueIndex(collectionName string, databaseName string, fieldName string, unique bool) error
+	InsertOneToMongo(ctx context.Context, databaseName string, data interface{}, collection string) (string, error)
+	InsertManyToMongo(ctx context.Context, databaseName string, data []interface{}, collection string) ([]string, error)
+	IsValueInCollection(ctx context.Context, databaseName string, collectionName string, fieldName, fieldValue string) (bool, error)
+	UpdateOneInMongo(ctx context.Context, databaseName string, filter bson.M, update bson.M, collection string) (*mongo.UpdateResult, error)
+	UpdateManyInMongo(ctx context.Context, databaseName string, filter bson.M, update bson.M, collection string) (*mongo.UpdateResult, error)
+	FindOneInMongo(ctx context.Context, databaseName string, filter bson.M, collection string, sort bson.D, result interface{}) (foundOne bool, err error)
+	FindManyInMongo(ctx context.Context, databaseName string, filter bson.M, collection string, sort bson.D, result interface{}) error
+	FindCursorInMongo(ctx context.Context, databaseName string, filter bson.M, collection string, findOptions FindOptions) (Cursor, error)
+	AggregateInMongo(ctx context.Context, databaseName string, collection string, pipeline mongo.Pipeline, result interface{}) error
+	AggregateCursorInMongo(ctx context.Context, databaseName string, collection string, pipeline mongo.Pipeline, batchSize int32) (Cursor, error)
+	DeleteDocumentMongo(ctx context.Context, databaseName string, filter bson.M, collection string) (interface{}, error)
+	DeleteManyMongo(ctx context.Context, databaseName string, filter bson.M, collection string) (int64, error)
+	DeleteCollectionMongo(ctx context.Context, databaseName string, collection string) error
+	CreateNewCollection(ctx context.Context, databaseName, collectionName string) error
+	CreateTimeSeriesCollection(ctx context.Context, databaseName, collectionName string, timeSeries TimeSeriesOptions) error
+	SetTimeSeriesGranularity(ctx context.Context, databaseName, collectionName, granularity string) error
+	ConvertToTimeSeriesCollection(ctx context.Context, databaseName, collectionName string, timeSeries TimeSeriesOptions, pipeline mongo.Pipeline) (converted int64, skipped int64, err error)
+	ListCollections(ctx context.Context, databaseName string, filter bson.M) ([]CollectionInfo, error)
+	CountDocumentsInMongo(ctx context.Context, databaseName string, collection string, result interface{}) (int, error)
+	CreateIndex(ctx context.Context, collectionName string, databaseName string, fieldNames ...string) error
+	CreateUniqueIndex(ctx context.Context, collectionName string, databaseName string, fieldName string, unique bool) error
+	CreatePartialUniqueIndex(ctx context.Context, collectionName string, databaseName string, fieldName string, scopeFieldNames ...string) error
+	CreateUniqueStringIndex(ctx context.Context, collectionName string, databaseName string, fieldName string) error
+	DropIndex(ctx context.Context, collectionName string, databaseName string, indexName string) error
+	CreateTTLIndex(ctx context.Context, collectionName string, databaseName string, fieldName string, expireAfterSec int32) error
 	StartSession() (session mongo.Session, err error)
+	RunTransaction(ctx context.Context, fn func(ctx context.Context, unitOfWork Repository) error) error
+	WatchCollection(ctx context.Context, databaseName string, collection string) (*mongo.ChangeStream, error)
 }
 
 type Client struct {
 	MongoDB *mongo.Client
+	session mongo.Session // Session of the transaction of a unit of work, nil otherwise
 }
 
 type ClientConfigData struct {
@@ -0,0 +1,39 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CollectionInfo describes a collection. Type is 'collection', 'timeseries' or 'view'
+type CollectionInfo struct {
+	Name                  string
+	Type                  string
+	TimeSeriesGranularity string // Granularity of time-series collections, empty otherwise
+}
+
+// ListCollections returns the collections of a database matching filter, eg. bson.M{"name": "plant_logger_123456789012"}
+func (client *Client) ListCollections(ctx context.Context, databaseName string, filter bson.M) ([]CollectionInfo, error) {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseQueryTimeoutSec)
+	defer cancel()
+
+	database := client.MongoDB.Database(databaseName)
+
+	specifications, err := database.ListCollectionSpecifications(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("Error when listing collections of database '%s' in 'ListCollections()' using 'ListCollectionSpecifications()'. Error: %w", databaseName, err)
+	}
+	collections := make([]CollectionInfo, 0, len(specifications))
+	for _, specification := range specifications {
+		collection := CollectionInfo{Name: specification.Name, Type: specification.Type}
+		if specification.Options != nil {
+			collection.TimeSeriesGranularity, _ = specification.Options.Lookup("timeseries", "granularity").StringValueOK()
+		}
+		collections = append(collections, collection)
+	}
+	return collections, nil
+}
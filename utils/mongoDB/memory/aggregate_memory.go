@@ -0,0 +1,791 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// aggregate runs pipeline, normalized stages, on documents of database databaseName. The caller must hold the lock.
+// Supported are $match, $project, $set, $addFields, $unset, $unwind, $group, $bucket, $facet, $sort, $limit, $skip, $count, $replaceRoot, $replaceWith and,
+// as last stage, $merge and $out. Unknown stages fail like on the server, see mongodb.IsUnsupportedPipeline.
+func (r *Repository) aggregate(databaseName string, documents []bson.D, pipeline []bson.D) ([]bson.D, error) {
+	// Stages change documents in place, stored documents must not be changed
+	current := make([]bson.D, len(documents))
+	for index, document := range documents {
+		current[index] = deepCopy(document).(bson.D)
+	}
+	for position, stage := range pipeline {
+		if len(stage) != 1 {
+			return nil, fmt.Errorf("A pipeline stage specification object must contain exactly one field")
+		}
+		operator, specification := stage[0].Key, stage[0].Value
+		if (operator == "$merge" || operator == "$out") && position != len(pipeline)-1 {
+			return nil, fmt.Errorf("%s can only be the final stage in the pipeline", operator)
+		}
+		var err error
+		switch operator {
+		case "$match":
+			current, err = stageMatch(current, specification)
+		case "$project":
+			current, err = stageProject(current, specification)
+		case "$set", "$addFields":
+			current, err = stageSet(current, specification)
+		case "$unset":
+			current, err = stageUnset(current, specification)
+		case "$unwind":
+			current, err = stageUnwind(current, specification)
+		case "$group":
+			current, err = stageGroup(current, specification)
+		case "$bucket":
+			current, err = stageBucket(current, specification)
+		case "$facet":
+			current, err = r.stageFacet(databaseName, current, specification)
+		case "$sort":
+			specificationDocument, ok := specification.(bson.D)
+			if !ok {
+				return nil, fmt.Errorf("the $sort key specification must be an object")
+			}
+			err = sortDocuments(current, specificationDocument)
+		case "$limit", "$skip":
+			number, ok := toInt64(specification)
+			if !ok || number < 0 {
+				return nil, fmt.Errorf("invalid argument to %s stage: %v", operator, specification)
+			}
+			number = min(number, int64(len(current)))
+			if operator == "$limit" {
+				current = current[:number]
+			} else {
+				current = current[number:]
+			}
+		case "$count":
+			field, ok := specification.(string)
+			if !ok || field == "" || strings.HasPrefix(field, "$") {
+				return nil, fmt.Errorf("the count field must be a non-empty string not starting with '$'")
+			}
+			if len(current) == 0 {
+				current = []bson.D{}
+			} else {
+				current = []bson.D{{{Key: field, Value: integer(int64(len(current)))}}}
+			}
+		case "$replaceRoot", "$replaceWith":
+			expression := specification
+			if operator == "$replaceRoot" {
+				specificationDocument, _ := specification.(bson.D)
+				var exists bool
+				if expression, exists = get(specificationDocument, "newRoot"); !exists {
+					return nil, fmt.Errorf("no newRoot specified for the $replaceRoot stage")
+				}
+			}
+			current, err = stageReplaceRoot(current, expression)
+		case "$merge":
+			err = r.stageMerge(databaseName, current, specification)
+			current = []bson.D{}
+		case "$out":
+			err = r.stageOut(databaseName, current, specification)
+			current = []bson.D{}
+		default:
+			return nil, mongo.CommandError{Code: 40324, Name: "Location40324", Message: fmt.Sprintf("Unrecognized pipeline stage name: '%s'", operator)}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return current, nil
+}
+
+func stageMatch(documents []bson.D, specification interface{}) ([]bson.D, error) {
+	filter, ok := specification.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("the match filter must be an expression in an object")
+	}
+	result := []bson.D{}
+	for _, document := range documents {
+		matched, err := matches(document, filter)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			result = append(result, document)
+		}
+	}
+	return result, nil
+}
+
+// stageProject includes fields given 1 or true and computed fields, or excludes fields given 0 or false. _id is included unless excluded
+func stageProject(documents []bson.D, specification interface{}) ([]bson.D, error) {
+	projection, ok := specification.(bson.D)
+	if !ok || len(projection) == 0 {
+		return nil, fmt.Errorf("$project specification must be a non-empty object")
+	}
+	inclusion, exclusion := false, false
+	for _, element := range projection {
+		switch value := element.Value.(type) {
+		case bool, int32, int64, float64:
+			if truthy(value) {
+				inclusion = true
+			} else if element.Key != "_id" {
+				exclusion = true
+			}
+		default:
+			inclusion = true
+		}
+	}
+	if inclusion && exclusion {
+		return nil, fmt.Errorf("Invalid $project: cannot mix inclusion and exclusion of fields other than _id")
+	}
+
+	result := make([]bson.D, 0, len(documents))
+	for _, document := range documents {
+		if !inclusion {
+			projected := document
+			for _, element := range projection {
+				if truthy(element.Value) {
+					continue
+				}
+				projected = unset(projected, element.Key)
+			}
+			result = append(result, projected)
+			continue
+		}
+		projected := bson.D{}
+		if _, listed := get(projection, "_id"); !listed {
+			if id, exists := get(document, "_id"); exists {
+				projected = append(projected, bson.E{Key: "_id", Value: id})
+			}
+		}
+		for _, element := range projection {
+			var value interface{}
+			var exists bool
+			switch element.Value.(type) {
+			case bool, int32, int64, float64:
+				if !truthy(element.Value) {
+					continue
+				}
+				value, exists = lookupOne(document, element.Key)
+			default:
+				var err error
+				if value, exists, err = evaluate(element.Value, document); err != nil {
+					return nil, err
+				}
+			}
+			if !exists {
+				continue
+			}
+			var err error
+			if projected, err = set(projected, element.Key, value); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, projected)
+	}
+	return result, nil
+}
+
+// stageSet sets fields to expressions evaluated on the input document. Fields evaluated to $$REMOVE or missing fields are removed
+func stageSet(documents []bson.D, specification interface{}) ([]bson.D, error) {
+	fields, ok := specification.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("$set specification must be an object")
+	}
+	for index, document := range documents {
+		changed := deepCopy(document).(bson.D)
+		for _, field := range fields {
+			value, exists, err := evaluate(field.Value, document)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				changed = unset(changed, field.Key)
+				continue
+			}
+			if changed, err = set(changed, field.Key, value); err != nil {
+				return nil, err
+			}
+		}
+		documents[index] = changed
+	}
+	return documents, nil
+}
+
+func stageUnset(documents []bson.D, specification interface{}) ([]bson.D, error) {
+	fields, ok := specification.(bson.A)
+	if !ok {
+		fields = bson.A{specification}
+	}
+	for _, field := range fields {
+		path, ok := field.(string)
+		if !ok {
+			return nil, fmt.Errorf("$unset specification must be a string or an array of strings")
+		}
+		for index := range documents {
+			documents[index] = unset(documents[index], path)
+		}
+	}
+	return documents, nil
+}
+
+// stageUnwind outputs a document per element of an array field. Documents with missing, null or empty arrays are dropped unless preserveNullAndEmptyArrays is set
+func stageUnwind(documents []bson.D, specification interface{}) ([]bson.D, error) {
+	path, preserve := "", false
+	switch s := specification.(type) {
+	case string:
+		path = s
+	case bson.D:
+		pathValue, _ := get(s, "path")
+		path, _ = pathValue.(string)
+		preserveValue, _ := get(s, "preserveNullAndEmptyArrays")
+		preserve = truthy(preserveValue)
+	}
+	if !strings.HasPrefix(path, "$") || len(path) < 2 {
+		return nil, fmt.Errorf("path option to $unwind stage should be prefixed with a '$': %v", path)
+	}
+	path = path[1:]
+
+	result := []bson.D{}
+	for _, document := range documents {
+		value, exists := lookupOne(document, path)
+		array, isArray := value.(bson.A)
+		switch {
+		case isArray && len(array) > 0:
+			for _, element := range array {
+				unwound, err := set(deepCopy(document).(bson.D), path, element)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, unwound)
+			}
+		case exists && value != nil && !isArray:
+			result = append(result, document)
+		case preserve:
+			if isArray {
+				document = unset(document, path)
+			}
+			result = append(result, document)
+		}
+	}
+	return result, nil
+}
+
+// stageGroup groups documents by the _id expression and computes the accumulators of each group. Groups are output in order of their first document
+func stageGroup(documents []bson.D, specification interface{}) ([]bson.D, error) {
+	fields, ok := specification.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("a group's fields must be specified in an object")
+	}
+	idExpression, exists := get(fields, "_id")
+	if !exists {
+		return nil, fmt.Errorf("a group specification must include an _id")
+	}
+	type group struct {
+		id           interface{}
+		accumulators []*accumulator
+	}
+	groups := map[string]*group{}
+	order := []string{}
+	for _, document := range documents {
+		id, idExists, err := evaluate(idExpression, document)
+		if err != nil {
+			return nil, err
+		}
+		if !idExists {
+			id = nil
+		}
+		key := canonicalKey(id)
+		g, found := groups[key]
+		if !found {
+			g = &group{id: id}
+			for _, field := range fields {
+				if field.Key == "_id" {
+					continue
+				}
+				operation, ok := field.Value.(bson.D)
+				if !ok || len(operation) != 1 {
+					return nil, fmt.Errorf("The field '%s' must be an accumulator object", field.Key)
+				}
+				accumulator, err := newAccumulator(operation[0].Key)
+				if err != nil {
+					return nil, err
+				}
+				g.accumulators = append(g.accumulators, accumulator)
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+		position := 0
+		for _, field := range fields {
+			if field.Key == "_id" {
+				continue
+			}
+			operation := field.Value.(bson.D)
+			value, valueExists, err := evaluate(operation[0].Value, document)
+			if err != nil {
+				return nil, err
+			}
+			if err := g.accumulators[position].add(value, valueExists); err != nil {
+				return nil, err
+			}
+			position++
+		}
+	}
+
+	result := make([]bson.D, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		document := bson.D{{Key: "_id", Value: g.id}}
+		position := 0
+		for _, field := range fields {
+			if field.Key == "_id" {
+				continue
+			}
+			document = append(document, bson.E{Key: field.Key, Value: g.accumulators[position].result()})
+			position++
+		}
+		result = append(result, document)
+	}
+	return result, nil
+}
+
+// bucketField holds the bucket of a document while $bucket groups documents
+const bucketField = "__bucket"
+
+// stageBucket groups documents by the range of boundaries their groupBy value lies in, the lower boundary being the _id of a bucket.
+// Documents outside all ranges are grouped into the default bucket. Buckets are sorted by their boundaries, the default bucket last, empty buckets omitted
+func stageBucket(documents []bson.D, specification interface{}) ([]bson.D, error) {
+	fields, ok := specification.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("the $bucket stage specification must be an object")
+	}
+	groupBy, groupByExists := get(fields, "groupBy")
+	boundariesValue, _ := get(fields, "boundaries")
+	boundaries, ok := boundariesValue.(bson.A)
+	if !groupByExists || !ok || len(boundaries) < 2 {
+		return nil, fmt.Errorf("$bucket requires 'groupBy' and 'boundaries' with at least two values")
+	}
+	for index := 1; index < len(boundaries); index++ {
+		if compare(boundaries[index-1], boundaries[index]) >= 0 {
+			return nil, fmt.Errorf("The 'boundaries' option to $bucket must be sorted in ascending order")
+		}
+	}
+	defaultBucket, hasDefault := get(fields, "default")
+	output, hasOutput := get(fields, "output")
+	if !hasOutput {
+		output = bson.D{{Key: "count", Value: bson.D{{Key: "$sum", Value: int32(1)}}}}
+	}
+	outputFields, ok := output.(bson.D)
+	if !ok {
+		return nil, fmt.Errorf("The $bucket 'output' field must be an object")
+	}
+
+	for index, document := range documents {
+		value, exists, err := evaluate(groupBy, document)
+		if err != nil {
+			return nil, err
+		}
+		bucket, found := interface{}(nil), false
+		for position := 0; exists && position < len(boundaries)-1; position++ {
+			if compare(boundaries[position], value) <= 0 && compare(value, boundaries[position+1]) < 0 {
+				bucket, found = boundaries[position], true
+				break
+			}
+		}
+		if !found {
+			if !hasDefault {
+				return nil, fmt.Errorf("$bucket could not find a matching branch for an input, and no default was specified")
+			}
+			bucket = defaultBucket
+		}
+		documents[index] = append(document, bson.E{Key: bucketField, Value: bucket})
+	}
+
+	groups, err := stageGroup(documents, append(bson.D{{Key: "_id", Value: "$" + bucketField}}, outputFields...))
+	if err != nil {
+		return nil, err
+	}
+	position := func(document bson.D) int {
+		id, _ := get(document, "_id")
+		for index, boundary := range boundaries {
+			if compare(boundary, id) == 0 {
+				return index
+			}
+		}
+		return len(boundaries)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return position(groups[i]) < position(groups[j]) })
+	return groups, nil
+}
+
+// stageFacet runs each pipeline of specification on the documents, returning one document with the resulting documents of each pipeline by its name
+func (r *Repository) stageFacet(databaseName string, documents []bson.D, specification interface{}) ([]bson.D, error) {
+	facets, ok := specification.(bson.D)
+	if !ok || len(facets) == 0 {
+		return nil, fmt.Errorf("the $facet specification must be a non-empty object")
+	}
+	result := bson.D{}
+	for _, facet := range facets {
+		stagesValue, ok := facet.Value.(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("arguments to $facet must be arrays, %s is type %s", facet.Key, typeName(facet.Value))
+		}
+		stages := make([]bson.D, 0, len(stagesValue))
+		for _, stageValue := range stagesValue {
+			stage, ok := stageValue.(bson.D)
+			if !ok || len(stage) != 1 {
+				return nil, fmt.Errorf("subpipeline of $facet must contain stages as objects")
+			}
+			switch stage[0].Key {
+			case "$facet", "$merge", "$out":
+				return nil, fmt.Errorf("%s is not allowed to be used within a $facet stage", stage[0].Key)
+			}
+			stages = append(stages, stage)
+		}
+		facetDocuments, err := r.aggregate(databaseName, documents, stages)
+		if err != nil {
+			return nil, err
+		}
+		values := make(bson.A, len(facetDocuments))
+		for index, document := range facetDocuments {
+			values[index] = document
+		}
+		result = append(result, bson.E{Key: facet.Key, Value: values})
+	}
+	return []bson.D{result}, nil
+}
+
+func stageReplaceRoot(documents []bson.D, expression interface{}) ([]bson.D, error) {
+	for index, document := range documents {
+		value, _, err := evaluate(expression, document)
+		if err != nil {
+			return nil, err
+		}
+		root, ok := value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("'newRoot' expression must evaluate to an object, but resulting value was of type %s", typeName(value))
+		}
+		documents[index] = root
+	}
+	return documents, nil
+}
+
+// sortDocuments sorts documents stably by the fields of specification, 1 ascending, -1 descending. Missing fields sort as null
+func sortDocuments(documents []bson.D, specification bson.D) error {
+	directions := make([]int, len(specification))
+	for index, element := range specification {
+		direction, ok := toFloat(element.Value)
+		if !ok || (direction != 1 && direction != -1) {
+			return fmt.Errorf("$sort key ordering must be 1 (for ascending) or -1 (for descending)")
+		}
+		directions[index] = int(direction)
+	}
+	sort.SliceStable(documents, func(i, j int) bool {
+		for index, element := range specification {
+			a, _ := lookupOne(documents[i], element.Key)
+			b, _ := lookupOne(documents[j], element.Key)
+			if result := compare(a, b) * directions[index]; result != 0 {
+				return result < 0
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+// stageMerge writes documents into a collection: {into: collection or {db, coll}, on: field or fields, whenMatched: replace, merge, keepExisting or fail, whenNotMatched: insert, discard or fail}
+func (r *Repository) stageMerge(databaseName string, documents []bson.D, specification interface{}) error {
+	options, isDocument := specification.(bson.D)
+	if !isDocument {
+		options = bson.D{{Key: "into", Value: specification}}
+	}
+	into, _ := get(options, "into")
+	targetDatabase, targetCollection, err := target(databaseName, into)
+	if err != nil {
+		return fmt.Errorf("$merge: %v", err)
+	}
+	on := []string{"_id"}
+	if onValue, exists := get(options, "on"); exists {
+		on = nil
+		fields, ok := onValue.(bson.A)
+		if !ok {
+			fields = bson.A{onValue}
+		}
+		for _, field := range fields {
+			name, ok := field.(string)
+			if !ok {
+				return fmt.Errorf("$merge 'on' field must be a string or an array of strings")
+			}
+			on = append(on, name)
+		}
+	}
+	whenMatched, whenNotMatched := "merge", "insert"
+	if value, exists := get(options, "whenMatched"); exists {
+		if whenMatched, isDocument = value.(string); !isDocument {
+			return fmt.Errorf("$merge 'whenMatched' pipelines are not supported by the in-memory repository")
+		}
+	}
+	if value, exists := get(options, "whenNotMatched"); exists {
+		whenNotMatched, _ = value.(string)
+	}
+
+	c := r.collection(targetDatabase, targetCollection, true)
+	for _, document := range documents {
+		keyValues := make([]interface{}, len(on))
+		for index, field := range on {
+			value, exists := lookupOne(document, field)
+			if !exists && field != "_id" {
+				return fmt.Errorf("$merge write error: 'on' field '%s' cannot be missing, null or an array", field)
+			}
+			keyValues[index] = value
+		}
+		matched := -1
+		for index, existing := range c.documents {
+			found := true
+			for position, field := range on {
+				value, _ := lookupOne(existing, field)
+				if !equal(value, keyValues[position]) {
+					found = false
+					break
+				}
+			}
+			if found {
+				matched = index
+				break
+			}
+		}
+
+		if matched < 0 {
+			switch whenNotMatched {
+			case "insert":
+				if _, err := c.insert(document); err != nil {
+					return err
+				}
+			case "discard":
+			case "fail":
+				return fmt.Errorf("$merge could not find a matching document in the target collection for at least one document in the source collection")
+			default:
+				return fmt.Errorf("Enumeration value '%s' for field 'whenNotMatched' is not a valid value", whenNotMatched)
+			}
+			continue
+		}
+
+		existing := c.documents[matched]
+		var merged bson.D
+		switch whenMatched {
+		case "replace":
+			merged = document
+		case "merge":
+			merged = deepCopy(existing).(bson.D)
+			for _, element := range document {
+				if merged, err = set(merged, element.Key, element.Value); err != nil {
+					return err
+				}
+			}
+		case "keepExisting":
+			continue
+		case "fail":
+			return fmt.Errorf("$merge failed due to a matching document in the target collection")
+		default:
+			return fmt.Errorf("Enumeration value '%s' for field 'whenMatched' is not a valid value", whenMatched)
+		}
+		// The _id of the matched document is kept
+		existingID, _ := get(existing, "_id")
+		if merged, err = set(unset(merged, "_id"), "_id", existingID); err != nil {
+			return err
+		}
+		if err := c.replace(matched, merged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stageOut replaces a collection by documents: collection name or {db, coll, timeseries: {timeField, metaField, granularity}}
+func (r *Repository) stageOut(databaseName string, documents []bson.D, specification interface{}) error {
+	targetDatabase, targetCollection, err := target(databaseName, specification)
+	if err != nil {
+		return fmt.Errorf("$out: %v", err)
+	}
+	namespace := targetDatabase + "." + targetCollection
+	replacement := newCollection(namespace, nil)
+	if options, ok := specification.(bson.D); ok {
+		if timeSeriesValue, exists := get(options, "timeseries"); exists {
+			timeSeriesDocument, _ := timeSeriesValue.(bson.D)
+			timeSeries, err := timeSeriesOptions(timeSeriesDocument)
+			if err != nil {
+				return fmt.Errorf("$out: %v", err)
+			}
+			replacement = newCollection(namespace, &timeSeries)
+		}
+	}
+	if existing := r.collection(targetDatabase, targetCollection, false); existing != nil {
+		if (existing.timeSeries == nil) != (replacement.timeSeries == nil) {
+			return fmt.Errorf("$out: cannot replace collection '%s.%s' by a collection of a different type", targetDatabase, targetCollection)
+		}
+		if existing.timeSeries == nil {
+			replacement.indexes = existing.indexes
+		}
+	}
+	for _, document := range documents {
+		if _, err := replacement.insert(document); err != nil {
+			return err
+		}
+	}
+	r.database(targetDatabase)[targetCollection] = replacement
+	return nil
+}
+
+// target returns database and collection of the target of $merge or $out, a collection name or {db, coll}
+func target(databaseName string, specification interface{}) (string, string, error) {
+	switch s := specification.(type) {
+	case string:
+		return databaseName, s, nil
+	case bson.D:
+		collectionName, _ := get(s, "coll")
+		name, ok := collectionName.(string)
+		if !ok || name == "" {
+			return "", "", fmt.Errorf("target collection must be a string")
+		}
+		if databaseValue, exists := get(s, "db"); exists {
+			if databaseName, ok = databaseValue.(string); !ok {
+				return "", "", fmt.Errorf("target database must be a string")
+			}
+		}
+		return databaseName, name, nil
+	}
+	return "", "", fmt.Errorf("target must be a string or an object")
+}
+
+// accumulator computes an accumulator of $group or an accumulator expression like {$sum: [...]}
+type accumulator struct {
+	operator string
+	value    interface{}
+	count    int64
+	found    bool
+	values   bson.A
+	seen     map[string]bool
+	numbers  []float64 // Values of $percentile
+	p        bson.A    // Percentiles of $percentile
+}
+
+func newAccumulator(operator string) (*accumulator, error) {
+	switch operator {
+	case "$sum", "$avg", "$min", "$max", "$push", "$addToSet", "$first", "$last", "$count", "$percentile":
+		return &accumulator{operator: operator, value: int32(0), seen: map[string]bool{}}, nil
+	}
+	return nil, mongo.CommandError{Code: 15952, Name: "Location15952", Message: fmt.Sprintf("unknown group operator '%s'", operator)}
+}
+
+// add adds a value. Missing values are ignored except by $first, $last and $count
+func (a *accumulator) add(value interface{}, exists bool) error {
+	switch a.operator {
+	case "$sum", "$avg":
+		// Non-numeric values are ignored
+		if !isNumber(value) {
+			return nil
+		}
+		sum, err := add(a.value, value)
+		if err != nil {
+			return err
+		}
+		a.value = sum
+		a.count++
+	case "$min", "$max":
+		if !exists || value == nil {
+			return nil
+		}
+		result := compare(value, a.value)
+		if !a.found || (a.operator == "$min" && result < 0) || (a.operator == "$max" && result > 0) {
+			a.value = value
+		}
+		a.found = true
+	case "$push", "$addToSet":
+		if !exists {
+			return nil
+		}
+		if a.operator == "$addToSet" {
+			key := canonicalKey(value)
+			if a.seen[key] {
+				return nil
+			}
+			a.seen[key] = true
+		}
+		a.values = append(a.values, value)
+	case "$first", "$last":
+		if !exists {
+			value = nil
+		}
+		if !a.found || a.operator == "$last" {
+			a.value = value
+		}
+		a.found = true
+	case "$count":
+		a.count++
+	case "$percentile":
+		// Evaluated specification {input, p, method}. Non-numeric inputs are ignored
+		specification, ok := value.(bson.D)
+		p, _ := get(specification, "p")
+		method, _ := get(specification, "method")
+		if a.p, ok = p.(bson.A); !ok || len(a.p) == 0 {
+			return fmt.Errorf("$percentile requires 'p' to be a non-empty array of numbers between 0 and 1")
+		}
+		for _, percentile := range a.p {
+			if number, ok := toFloat(percentile); !ok || number < 0 || number > 1 {
+				return fmt.Errorf("$percentile requires 'p' to be a non-empty array of numbers between 0 and 1")
+			}
+		}
+		if method != "approximate" {
+			return fmt.Errorf("$percentile currently only supports 'approximate' as 'method', got %v", method)
+		}
+		input, _ := get(specification, "input")
+		if number, ok := toFloat(input); ok && isNumber(input) {
+			a.numbers = append(a.numbers, number)
+		}
+	}
+	return nil
+}
+
+// result returns the accumulated value: $sum 0 and $avg, $min and $max null without values
+func (a *accumulator) result() interface{} {
+	switch a.operator {
+	case "$avg":
+		if a.count == 0 {
+			return nil
+		}
+		sum, _ := toFloat(a.value)
+		return sum / float64(a.count)
+	case "$min", "$max", "$first", "$last":
+		if !a.found {
+			return nil
+		}
+		return a.value
+	case "$push", "$addToSet":
+		if a.values == nil {
+			return bson.A{}
+		}
+		return a.values
+	case "$count":
+		return integer(a.count)
+	case "$percentile":
+		return percentiles(a.numbers, a.p)
+	}
+	return a.value
+}
+
+// percentiles returns the values at percentiles p, each the lowest value greater than or equal to fraction p of the values, null without values.
+// Exact, where the server approximates them for large numbers of values
+func percentiles(numbers []float64, p bson.A) bson.A {
+	sort.Float64s(numbers)
+	result := make(bson.A, len(p))
+	for index, percentile := range p {
+		if len(numbers) == 0 {
+			continue
+		}
+		fraction, _ := toFloat(percentile)
+		position := max(0, int(math.Ceil(fraction*float64(len(numbers))))-1)
+		result[index] = numbers[min(position, len(numbers)-1)]
+	}
+	return result
+}
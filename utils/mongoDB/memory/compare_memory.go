@@ -0,0 +1,239 @@
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"math"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// typeOrder is the rank of a value's type in MongoDB's comparison order. Values of different types compare by this rank
+func typeOrder(value interface{}) int {
+	switch value.(type) {
+	case primitive.MinKey:
+		return 0
+	case nil, primitive.Null, primitive.Undefined:
+		return 1
+	case int32, int64, float64:
+		return 2
+	case string, primitive.Symbol:
+		return 3
+	case bson.D:
+		return 4
+	case bson.A:
+		return 5
+	case primitive.Binary:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime:
+		return 9
+	case primitive.Timestamp:
+		return 10
+	case primitive.Regex:
+		return 11
+	case primitive.MaxKey:
+		return 13
+	}
+	return 12
+}
+
+// compare compares two normalized values in MongoDB's comparison order. Numbers of different types compare by value
+func compare(a, b interface{}) int {
+	orderA, orderB := typeOrder(a), typeOrder(b)
+	if orderA != orderB {
+		return cmp.Compare(orderA, orderB)
+	}
+	switch x := a.(type) {
+	case int32, int64, float64:
+		return compareNumbers(a, b)
+	case string:
+		return strings.Compare(x, fmt.Sprint(b))
+	case primitive.Symbol:
+		return strings.Compare(string(x), fmt.Sprint(b))
+	case bson.D:
+		y := b.(bson.D)
+		for index := 0; index < len(x) && index < len(y); index++ {
+			if result := strings.Compare(x[index].Key, y[index].Key); result != 0 {
+				return result
+			}
+			if result := compare(x[index].Value, y[index].Value); result != 0 {
+				return result
+			}
+		}
+		return cmp.Compare(len(x), len(y))
+	case bson.A:
+		y := b.(bson.A)
+		for index := 0; index < len(x) && index < len(y); index++ {
+			if result := compare(x[index], y[index]); result != 0 {
+				return result
+			}
+		}
+		return cmp.Compare(len(x), len(y))
+	case primitive.Binary:
+		return bytes.Compare(x.Data, b.(primitive.Binary).Data)
+	case primitive.ObjectID:
+		y := b.(primitive.ObjectID)
+		return bytes.Compare(x[:], y[:])
+	case bool:
+		y := b.(bool)
+		if x == y {
+			return 0
+		}
+		if !x {
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		return cmp.Compare(x, b.(primitive.DateTime))
+	case primitive.Timestamp:
+		y := b.(primitive.Timestamp)
+		if x.T != y.T {
+			return cmp.Compare(x.T, y.T)
+		}
+		return cmp.Compare(x.I, y.I)
+	case primitive.Regex:
+		y := b.(primitive.Regex)
+		return strings.Compare(x.Pattern+"/"+x.Options, y.Pattern+"/"+y.Options)
+	}
+	return 0
+}
+
+// compareNumbers compares two numbers of any numeric type. Integers are compared exactly
+func compareNumbers(a, b interface{}) int {
+	integerA, isIntegerA := toInt64(a)
+	integerB, isIntegerB := toInt64(b)
+	if isIntegerA && isIntegerB {
+		return cmp.Compare(integerA, integerB)
+	}
+	floatA, _ := toFloat(a)
+	floatB, _ := toFloat(b)
+	return cmp.Compare(floatA, floatB)
+}
+
+// equal reports whether two normalized values are equal, numbers by value
+func equal(a, b interface{}) bool {
+	return compare(a, b) == 0
+}
+
+// toFloat converts numbers to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case float64:
+		return number, true
+	}
+	return 0, false
+}
+
+// toInt64 converts integers to int64. Doubles are not converted
+func toInt64(value interface{}) (int64, bool) {
+	switch number := value.(type) {
+	case int32:
+		return int64(number), true
+	case int64:
+		return number, true
+	}
+	return 0, false
+}
+
+// isNumber reports whether value is a number
+func isNumber(value interface{}) bool {
+	_, ok := toFloat(value)
+	return ok
+}
+
+// integer returns n as int32 if it fits, as int64 otherwise. MongoDB widens integer results the same way
+func integer(n int64) interface{} {
+	if n >= math.MinInt32 && n <= math.MaxInt32 {
+		return int32(n)
+	}
+	return n
+}
+
+// typeName returns the name of a value's BSON type as used by $type
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case float64:
+		return "double"
+	case string:
+		return "string"
+	case bson.D:
+		return "object"
+	case bson.A:
+		return "array"
+	case primitive.Binary:
+		return "binData"
+	case primitive.Undefined:
+		return "undefined"
+	case primitive.ObjectID:
+		return "objectId"
+	case bool:
+		return "bool"
+	case primitive.DateTime:
+		return "date"
+	case nil, primitive.Null:
+		return "null"
+	case primitive.Regex:
+		return "regex"
+	case primitive.Symbol:
+		return "symbol"
+	case int32:
+		return "int"
+	case primitive.Timestamp:
+		return "timestamp"
+	case int64:
+		return "long"
+	case primitive.Decimal128:
+		return "decimal"
+	case primitive.MinKey:
+		return "minKey"
+	case primitive.MaxKey:
+		return "maxKey"
+	}
+	return "unknown"
+}
+
+// canonicalKey returns a string identifying values, equal for values comparing equal. Numbers are compared by value, as by MongoDB's indexes and $group
+func canonicalKey(values ...interface{}) string {
+	array := bson.A{}
+	for _, value := range values {
+		array = append(array, canonicalNumbers(value))
+	}
+	raw, err := bson.Marshal(bson.D{{Key: "k", Value: array}})
+	if err != nil {
+		return fmt.Sprint(values...)
+	}
+	return string(raw)
+}
+
+// canonicalNumbers converts all numbers within value to float64
+func canonicalNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int32, int64:
+		number, _ := toFloat(v)
+		return number
+	case bson.D:
+		document := make(bson.D, len(v))
+		for index, element := range v {
+			document[index] = bson.E{Key: element.Key, Value: canonicalNumbers(element.Value)}
+		}
+		return document
+	case bson.A:
+		array := make(bson.A, len(v))
+		for index, element := range v {
+			array[index] = canonicalNumbers(element)
+		}
+		return array
+	}
+	return value
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// cursor iterates documents found by FindCursorInMongo. Documents are never changed in place, so the cursor keeps the documents
+// matching at the time of the query, like a snapshot
+type cursor struct {
+	documents []bson.D
+	current   bson.D
+	err       error
+	closed    bool
+}
+
+// FindCursorInMongo returns a cursor of the documents of collectionName matching filter, projected, sorted, skipped and limited by findOptions.
+// Batch size is ignored, documents are held in memory anyway.
+func (r *Repository) FindCursorInMongo(ctx context.Context, databaseName string, filter bson.M, collectionName string, findOptions mongodb.FindOptions) (mongodb.Cursor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if findOptions.Skip < 0 || findOptions.Limit < 0 {
+		return nil, errors.New("skip and limit must not be negative")
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	documents, err := r.query(databaseName, filter, collectionName, findOptions.Sort)
+	if err != nil {
+		return nil, err
+	}
+	documents = documents[min(int(findOptions.Skip), len(documents)):]
+	if findOptions.Limit > 0 && int(findOptions.Limit) < len(documents) {
+		documents = documents[:findOptions.Limit]
+	}
+	if len(findOptions.Projection) > 0 {
+		projection, err := normalize(findOptions.Projection)
+		if err != nil {
+			return nil, err
+		}
+		if documents, err = stageProject(documents, projection); err != nil {
+			return nil, err
+		}
+	}
+	return &cursor{documents: documents}, nil
+}
+
+// AggregateCursorInMongo returns a cursor of the documents resulting from an aggregation pipeline on collectionName. Batch size is ignored
+func (r *Repository) AggregateCursorInMongo(ctx context.Context, databaseName string, collectionName string, pipeline mongo.Pipeline, batchSize int32) (mongodb.Cursor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	documents, err := r.aggregatePipeline(databaseName, collectionName, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("Error when aggregating documents in collection '%s' of database '%s' in 'AggregateCursorInMongo()' using 'Aggregate()'. Error: %w", collectionName, databaseName, err)
+	}
+	return &cursor{documents: documents}, nil
+}
+
+func (c *cursor) Next(ctx context.Context) bool {
+	if c.closed || c.err != nil || len(c.documents) == 0 {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		c.err = err
+		return false
+	}
+	c.current, c.documents = c.documents[0], c.documents[1:]
+	return true
+}
+
+func (c *cursor) Decode(result interface{}) error {
+	if c.current == nil {
+		return errors.New("Decode called without current document, call Next first")
+	}
+	return decode(c.current, result)
+}
+
+func (c *cursor) Err() error {
+	return c.err
+}
+
+func (c *cursor) Close(context.Context) error {
+	c.closed = true
+	c.documents = nil
+	return nil
+}
@@ -0,0 +1,306 @@
+package memory
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+)
+
+// registry marshals maps with sorted keys. The driver marshals maps, eg. bson.M, in random key order, so documents stored
+// from maps, or _id documents of $group built from maps, would differ between runs
+var registry = newRegistry()
+
+func newRegistry() *bsoncodec.Registry {
+	registry := bson.NewRegistry()
+	registry.RegisterKindEncoder(reflect.Map, bsoncodec.ValueEncoderFunc(encodeSortedMap))
+	return registry
+}
+
+// encodeSortedMap encodes maps with string keys as documents in key order. Other maps are encoded as by the driver
+func encodeSortedMap(context bsoncodec.EncodeContext, writer bsonrw.ValueWriter, value reflect.Value) error {
+	if value.Kind() != reflect.Map || value.Type().Key().Kind() != reflect.String {
+		encoder, err := bson.DefaultRegistry.LookupEncoder(value.Type())
+		if err != nil {
+			return err
+		}
+		return encoder.EncodeValue(context, writer, value)
+	}
+	if value.IsNil() {
+		return writer.WriteNull()
+	}
+	keys := value.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	documentWriter, err := writer.WriteDocument()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		elementWriter, err := documentWriter.WriteDocumentElement(key.String())
+		if err != nil {
+			return err
+		}
+		element := value.MapIndex(key)
+		if element.Kind() == reflect.Interface {
+			if element.IsNil() {
+				if err := elementWriter.WriteNull(); err != nil {
+					return err
+				}
+				continue
+			}
+			element = element.Elem()
+		}
+		encoder, err := context.LookupEncoder(element.Type())
+		if err != nil {
+			return err
+		}
+		if err := encoder.EncodeValue(context, elementWriter, element); err != nil {
+			return err
+		}
+	}
+	return documentWriter.WriteDocumentEnd()
+}
+
+// normalize converts a document of any type the driver can marshal (structs, bson.M, bson.D) into a bson.D with BSON value types:
+// embedded documents as bson.D, arrays as bson.A, times as primitive.DateTime, Go ints as int32 or int64.
+func normalize(value interface{}) (bson.D, error) {
+	if value == nil {
+		return bson.D{}, nil
+	}
+	if v := reflect.ValueOf(value); (v.Kind() == reflect.Map || v.Kind() == reflect.Slice || v.Kind() == reflect.Ptr) && v.IsNil() {
+		return bson.D{}, nil
+	}
+	raw, err := bson.MarshalWithRegistry(registry, value)
+	if err != nil {
+		return nil, err
+	}
+	var document bson.D
+	if err := bson.Unmarshal(raw, &document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// normalizeValue converts a single value into its BSON value type, see normalize
+func normalizeValue(value interface{}) (interface{}, error) {
+	document, err := normalize(bson.D{{Key: "v", Value: value}})
+	if err != nil {
+		return nil, err
+	}
+	return document[0].Value, nil
+}
+
+// decode decodes a document into result, a pointer, as the driver would decode a document read from MongoDB
+func decode(document bson.D, result interface{}) error {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, result)
+}
+
+// decodeAll decodes documents into result, a pointer to a slice
+func decodeAll(documents []bson.D, result interface{}) error {
+	resultValue := reflect.ValueOf(result)
+	if resultValue.Kind() != reflect.Ptr || resultValue.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results argument must be a pointer to a slice, but was a %s", resultValue.Kind())
+	}
+	slice := reflect.MakeSlice(resultValue.Elem().Type(), 0, len(documents))
+	for _, document := range documents {
+		element := reflect.New(slice.Type().Elem())
+		if err := decode(document, element.Interface()); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, element.Elem())
+	}
+	resultValue.Elem().Set(slice)
+	return nil
+}
+
+// deepCopy copies documents and arrays within value. Stored documents are never changed in place, updates work on copies
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		document := make(bson.D, len(v))
+		for index, element := range v {
+			document[index] = bson.E{Key: element.Key, Value: deepCopy(element.Value)}
+		}
+		return document
+	case bson.A:
+		array := make(bson.A, len(v))
+		for index, element := range v {
+			array[index] = deepCopy(element)
+		}
+		return array
+	}
+	return value
+}
+
+// get returns the value of a top-level field
+func get(document bson.D, key string) (interface{}, bool) {
+	for _, element := range document {
+		if element.Key == key {
+			return element.Value, true
+		}
+	}
+	return nil, false
+}
+
+// lookup returns the values at a dotted path. Arrays within the path are traversed, so there may be several values.
+// Numeric path parts select array elements.
+func lookup(value interface{}, path string) []interface{} {
+	return lookupParts(value, strings.Split(path, "."))
+}
+
+func lookupParts(value interface{}, parts []string) []interface{} {
+	if len(parts) == 0 {
+		return []interface{}{value}
+	}
+	switch v := value.(type) {
+	case bson.D:
+		if child, exists := get(v, parts[0]); exists {
+			return lookupParts(child, parts[1:])
+		}
+	case bson.A:
+		if index, err := strconv.Atoi(parts[0]); err == nil {
+			if index >= 0 && index < len(v) {
+				return lookupParts(v[index], parts[1:])
+			}
+			return nil
+		}
+		values := []interface{}{}
+		for _, element := range v {
+			if document, ok := element.(bson.D); ok {
+				values = append(values, lookupParts(document, parts)...)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// lookupOne returns the value at a dotted path without traversing arrays, as field paths of aggregation expressions do for single values
+func lookupOne(document bson.D, path string) (interface{}, bool) {
+	var value interface{} = document
+	for _, part := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case bson.D:
+			child, exists := get(v, part)
+			if !exists {
+				return nil, false
+			}
+			value = child
+		case bson.A:
+			// Field paths through arrays yield the array of the field of each element
+			values := bson.A{}
+			for _, element := range v {
+				if elementDocument, ok := element.(bson.D); ok {
+					if child, exists := get(elementDocument, part); exists {
+						values = append(values, child)
+					}
+				}
+			}
+			value = values
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// set sets the value at a dotted path, creating embedded documents as needed. document must be a copy owned by the caller
+func set(document bson.D, path string, value interface{}) (bson.D, error) {
+	result, err := setParts(document, strings.Split(path, "."), value)
+	if err != nil {
+		return nil, err
+	}
+	return result.(bson.D), nil
+}
+
+func setParts(container interface{}, parts []string, value interface{}) (interface{}, error) {
+	switch v := container.(type) {
+	case bson.D:
+		for index, element := range v {
+			if element.Key != parts[0] {
+				continue
+			}
+			if len(parts) == 1 {
+				v[index].Value = value
+				return v, nil
+			}
+			child, err := setParts(element.Value, parts[1:], value)
+			if err != nil {
+				return nil, err
+			}
+			v[index].Value = child
+			return v, nil
+		}
+		if len(parts) == 1 {
+			return append(v, bson.E{Key: parts[0], Value: value}), nil
+		}
+		child, err := setParts(bson.D{}, parts[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return append(v, bson.E{Key: parts[0], Value: child}), nil
+	case bson.A:
+		index, err := strconv.Atoi(parts[0])
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("Cannot create field '%s' in element of array", parts[0])
+		}
+		for len(v) <= index {
+			v = append(v, nil)
+		}
+		if len(parts) == 1 {
+			v[index] = value
+			return v, nil
+		}
+		child := v[index]
+		if child == nil {
+			child = bson.D{}
+		}
+		if v[index], err = setParts(child, parts[1:], value); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("Cannot create field '%s' in element of type %s", parts[0], typeName(container))
+}
+
+// unset removes the field at a dotted path. document must be a copy owned by the caller
+func unset(document bson.D, path string) bson.D {
+	return unsetParts(document, strings.Split(path, ".")).(bson.D)
+}
+
+func unsetParts(container interface{}, parts []string) interface{} {
+	switch v := container.(type) {
+	case bson.D:
+		for index, element := range v {
+			if element.Key != parts[0] {
+				continue
+			}
+			if len(parts) == 1 {
+				return append(v[:index], v[index+1:]...)
+			}
+			v[index].Value = unsetParts(element.Value, parts[1:])
+			return v
+		}
+	case bson.A:
+		index, err := strconv.Atoi(parts[0])
+		if err != nil || index < 0 || index >= len(v) {
+			return v
+		}
+		if len(parts) == 1 {
+			// Array elements are set to null rather than removed, as by MongoDB
+			v[index] = nil
+			return v
+		}
+		v[index] = unsetParts(v[index], parts[1:])
+	}
+	return container
+}
@@ -0,0 +1,539 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// evaluate evaluates an aggregation expression on document. exists is false for missing fields and $$REMOVE.
+// Supported are field paths, $$ROOT, $$CURRENT, $$REMOVE, literals, documents and arrays of expressions and the operators of evaluateOperator.
+func evaluate(expression interface{}, document bson.D) (value interface{}, exists bool, err error) {
+	switch e := expression.(type) {
+	case string:
+		switch {
+		case e == "$$REMOVE":
+			return nil, false, nil
+		case e == "$$ROOT" || e == "$$CURRENT":
+			return document, true, nil
+		case strings.HasPrefix(e, "$$ROOT.") || strings.HasPrefix(e, "$$CURRENT."):
+			value, exists := lookupOne(document, e[strings.Index(e, ".")+1:])
+			return value, exists, nil
+		case strings.HasPrefix(e, "$$"):
+			return nil, false, fmt.Errorf("Use of undefined variable: %s", e[2:])
+		case strings.HasPrefix(e, "$"):
+			value, exists := lookupOne(document, e[1:])
+			return value, exists, nil
+		}
+		return e, true, nil
+	case bson.D:
+		if len(e) == 1 && strings.HasPrefix(e[0].Key, "$") {
+			return evaluateOperator(e[0].Key, e[0].Value, document)
+		}
+		result := bson.D{}
+		for _, element := range e {
+			value, exists, err := evaluate(element.Value, document)
+			if err != nil {
+				return nil, false, err
+			}
+			if exists {
+				result = append(result, bson.E{Key: element.Key, Value: value})
+			}
+		}
+		return result, true, nil
+	case bson.A:
+		result := bson.A{}
+		for _, element := range e {
+			value, exists, err := evaluate(element, document)
+			if err != nil {
+				return nil, false, err
+			}
+			if !exists {
+				value = nil
+			}
+			result = append(result, value)
+		}
+		return result, true, nil
+	}
+	return expression, true, nil
+}
+
+// evaluateArguments evaluates the arguments of an operator, given as array or as single expression. Missing values become null
+func evaluateArguments(argument interface{}, document bson.D) ([]interface{}, error) {
+	expressions, ok := argument.(bson.A)
+	if !ok {
+		expressions = bson.A{argument}
+	}
+	values := make([]interface{}, len(expressions))
+	for index, expression := range expressions {
+		value, exists, err := evaluate(expression, document)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			values[index] = value
+		}
+	}
+	return values, nil
+}
+
+// evaluateOperator evaluates an expression operator
+func evaluateOperator(operator string, argument interface{}, document bson.D) (interface{}, bool, error) {
+	if operator == "$literal" {
+		return argument, true, nil
+	}
+	if operator == "$cond" {
+		return evaluateCond(argument, document)
+	}
+	if operator == "$dateTrunc" {
+		return evaluateDateTrunc(argument, document)
+	}
+	if operator == "$ifNull" {
+		// The first argument not null or missing, the last argument else
+		expressions, ok := argument.(bson.A)
+		if !ok || len(expressions) < 2 {
+			return nil, false, fmt.Errorf("$ifNull needs at least two arguments")
+		}
+		for index, expression := range expressions {
+			value, exists, err := evaluate(expression, document)
+			if err != nil {
+				return nil, false, err
+			}
+			if (exists && value != nil) || index == len(expressions)-1 {
+				return value, exists, nil
+			}
+		}
+	}
+
+	arguments, err := evaluateArguments(argument, document)
+	if err != nil {
+		return nil, false, err
+	}
+	switch operator {
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$cmp":
+		if len(arguments) != 2 {
+			return nil, false, fmt.Errorf("Expression %s takes exactly 2 arguments", operator)
+		}
+		result := compare(arguments[0], arguments[1])
+		switch operator {
+		case "$eq":
+			return result == 0, true, nil
+		case "$ne":
+			return result != 0, true, nil
+		case "$gt":
+			return result > 0, true, nil
+		case "$gte":
+			return result >= 0, true, nil
+		case "$lt":
+			return result < 0, true, nil
+		case "$lte":
+			return result <= 0, true, nil
+		}
+		return int32(result), true, nil
+	case "$and", "$or":
+		for _, value := range arguments {
+			if operator == "$and" && !truthy(value) {
+				return false, true, nil
+			}
+			if operator == "$or" && truthy(value) {
+				return true, true, nil
+			}
+		}
+		return operator == "$and", true, nil
+	case "$not":
+		return !truthy(arguments[0]), true, nil
+	case "$type":
+		value, exists, err := evaluate(argument, document)
+		if err != nil {
+			return nil, false, err
+		}
+		if !exists {
+			return "missing", true, nil
+		}
+		return typeName(value), true, nil
+	case "$add", "$multiply":
+		var result interface{} = int32(0)
+		if operator == "$multiply" {
+			result = int32(1)
+		}
+		for _, value := range arguments {
+			if value == nil {
+				return nil, true, nil
+			}
+			if operator == "$add" {
+				result, err = add(result, value)
+			} else {
+				result, err = multiply(result, value)
+			}
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		return result, true, nil
+	case "$subtract", "$divide", "$mod", "$pow":
+		if len(arguments) != 2 {
+			return nil, false, fmt.Errorf("Expression %s takes exactly 2 arguments", operator)
+		}
+		if arguments[0] == nil || arguments[1] == nil {
+			return nil, true, nil
+		}
+		result, err := binaryArithmetic(operator, arguments[0], arguments[1])
+		return result, err == nil, err
+	case "$abs", "$floor", "$ceil", "$sqrt", "$round", "$trunc":
+		if arguments[0] == nil {
+			return nil, true, nil
+		}
+		number, ok := toFloat(arguments[0])
+		if !ok {
+			return nil, false, fmt.Errorf("%s only supports numeric types, not %s", operator, typeName(arguments[0]))
+		}
+		if _, isInteger := toInt64(arguments[0]); isInteger && operator != "$sqrt" {
+			if operator == "$abs" && number < 0 {
+				return integer(-int64(number)), true, nil
+			}
+			return arguments[0], true, nil
+		}
+		functions := map[string]func(float64) float64{"$abs": math.Abs, "$floor": math.Floor, "$ceil": math.Ceil, "$sqrt": math.Sqrt, "$round": math.RoundToEven, "$trunc": math.Trunc}
+		return functions[operator](number), true, nil
+	case "$sum", "$avg", "$min", "$max":
+		// Expression form: over the arguments or the elements of a single array argument
+		if len(arguments) == 1 {
+			if array, ok := arguments[0].(bson.A); ok {
+				arguments = array
+			}
+		}
+		accumulator, err := newAccumulator(operator)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, value := range arguments {
+			if err := accumulator.add(value, true); err != nil {
+				return nil, false, err
+			}
+		}
+		return accumulator.result(), true, nil
+	case "$concat":
+		var builder strings.Builder
+		for _, value := range arguments {
+			if value == nil {
+				return nil, true, nil
+			}
+			text, ok := value.(string)
+			if !ok {
+				return nil, false, fmt.Errorf("$concat only supports strings, not %s", typeName(value))
+			}
+			builder.WriteString(text)
+		}
+		return builder.String(), true, nil
+	case "$concatArrays":
+		result := bson.A{}
+		for _, value := range arguments {
+			if value == nil {
+				return nil, true, nil
+			}
+			array, ok := value.(bson.A)
+			if !ok {
+				return nil, false, fmt.Errorf("$concatArrays only supports arrays, not %s", typeName(value))
+			}
+			result = append(result, array...)
+		}
+		return result, true, nil
+	case "$size":
+		array, ok := arguments[0].(bson.A)
+		if !ok {
+			return nil, false, fmt.Errorf("The argument to $size must be an array. Type of argument: %s", typeName(arguments[0]))
+		}
+		return int32(len(array)), true, nil
+	case "$in":
+		if len(arguments) != 2 {
+			return nil, false, fmt.Errorf("Expression $in takes exactly 2 arguments")
+		}
+		array, ok := arguments[1].(bson.A)
+		if !ok {
+			return nil, false, fmt.Errorf("$in requires an array as a second argument, found: %s", typeName(arguments[1]))
+		}
+		return containsEqual(array, arguments[0]), true, nil
+	case "$objectToArray":
+		if arguments[0] == nil {
+			return nil, true, nil
+		}
+		object, ok := arguments[0].(bson.D)
+		if !ok {
+			return nil, false, fmt.Errorf("$objectToArray requires a document input, found: %s", typeName(arguments[0]))
+		}
+		result := bson.A{}
+		for _, element := range object {
+			result = append(result, bson.D{{Key: "k", Value: element.Key}, {Key: "v", Value: element.Value}})
+		}
+		return result, true, nil
+	case "$arrayToObject":
+		if arguments[0] == nil {
+			return nil, true, nil
+		}
+		array, ok := arguments[0].(bson.A)
+		if !ok {
+			return nil, false, fmt.Errorf("$arrayToObject requires an array input, found: %s", typeName(arguments[0]))
+		}
+		result := bson.D{}
+		for _, element := range array {
+			var key interface{}
+			var value interface{}
+			switch pair := element.(type) {
+			case bson.D:
+				key, _ = get(pair, "k")
+				value, _ = get(pair, "v")
+			case bson.A:
+				if len(pair) != 2 {
+					return nil, false, fmt.Errorf("$arrayToObject requires an array of size 2 arrays")
+				}
+				key, value = pair[0], pair[1]
+			default:
+				return nil, false, fmt.Errorf("$arrayToObject requires an array of key-value pairs")
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, false, fmt.Errorf("$arrayToObject requires keys of type string")
+			}
+			// Later pairs replace earlier pairs of the same key
+			replaced := false
+			for index := range result {
+				if result[index].Key == name {
+					result[index].Value = value
+					replaced = true
+				}
+			}
+			if !replaced {
+				result = append(result, bson.E{Key: name, Value: value})
+			}
+		}
+		return result, true, nil
+	case "$toDate":
+		switch value := arguments[0].(type) {
+		case nil, primitive.DateTime:
+			return value, true, nil
+		case int64, float64, int32:
+			number, _ := toFloat(value)
+			return primitive.DateTime(int64(number)), true, nil
+		case string:
+			parsed, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, false, fmt.Errorf("Error parsing date string '%s'", value)
+			}
+			return primitive.NewDateTimeFromTime(parsed), true, nil
+		case primitive.ObjectID:
+			return primitive.NewDateTimeFromTime(value.Timestamp()), true, nil
+		}
+		return nil, false, fmt.Errorf("Unsupported conversion from %s to date in $toDate", typeName(arguments[0]))
+	}
+	return nil, false, mongo.CommandError{Code: 168, Name: "InvalidPipelineOperator", Message: fmt.Sprintf("Unrecognized expression '%s'", operator)}
+}
+
+// evaluateCond evaluates $cond given as array [if, then, else] or document {if, then, else}
+func evaluateCond(argument interface{}, document bson.D) (interface{}, bool, error) {
+	var branches [3]interface{}
+	switch condition := argument.(type) {
+	case bson.A:
+		if len(condition) != 3 {
+			return nil, false, fmt.Errorf("Expression $cond takes exactly 3 arguments")
+		}
+		copy(branches[:], condition)
+	case bson.D:
+		for index, name := range []string{"if", "then", "else"} {
+			branch, exists := get(condition, name)
+			if !exists {
+				return nil, false, fmt.Errorf("Missing '%s' parameter to $cond", name)
+			}
+			branches[index] = branch
+		}
+	default:
+		return nil, false, fmt.Errorf("$cond needs an array or a document")
+	}
+	value, exists, err := evaluate(branches[0], document)
+	if err != nil {
+		return nil, false, err
+	}
+	if exists && truthy(value) {
+		return evaluate(branches[1], document)
+	}
+	return evaluate(branches[2], document)
+}
+
+// evaluateDateTrunc truncates a date in UTC to a unit (year, quarter, month, week, day, hour, minute, second, millisecond), optionally to multiples of binSize units since 2000-01-01
+func evaluateDateTrunc(argument interface{}, document bson.D) (interface{}, bool, error) {
+	parameters, ok := argument.(bson.D)
+	if !ok {
+		return nil, false, fmt.Errorf("$dateTrunc only supports an object as its argument")
+	}
+	dateExpression, _ := get(parameters, "date")
+	unitExpression, _ := get(parameters, "unit")
+	date, dateExists, err := evaluate(dateExpression, document)
+	if err != nil {
+		return nil, false, err
+	}
+	unit, _, err := evaluate(unitExpression, document)
+	if err != nil {
+		return nil, false, err
+	}
+	if !dateExists || date == nil {
+		return nil, true, nil
+	}
+	var t time.Time
+	switch value := date.(type) {
+	case primitive.DateTime:
+		t = value.Time().UTC()
+	case primitive.ObjectID:
+		t = value.Timestamp().UTC()
+	default:
+		return nil, false, fmt.Errorf("$dateTrunc requires 'date' to be a date, but got %s", typeName(date))
+	}
+	binSize := int64(1)
+	if binSizeExpression, exists := get(parameters, "binSize"); exists {
+		value, _, err := evaluate(binSizeExpression, document)
+		if err != nil {
+			return nil, false, err
+		}
+		number, ok := toFloat(value)
+		if !ok || number < 1 {
+			return nil, false, fmt.Errorf("$dateTrunc requires 'binSize' to be a positive number")
+		}
+		binSize = int64(number)
+	}
+
+	reference := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	durations := map[string]time.Duration{"millisecond": time.Millisecond, "second": time.Second, "minute": time.Minute, "hour": time.Hour, "day": 24 * time.Hour}
+	var truncated time.Time
+	switch unit {
+	case "millisecond", "second", "minute", "hour", "day":
+		bin := time.Duration(binSize) * durations[unit.(string)]
+		truncated = reference.Add(t.Sub(reference) / bin * bin)
+		if truncated.After(t) {
+			truncated = truncated.Add(-bin)
+		}
+	case "week":
+		// Weeks start on Sunday. 2000-01-02 was a Sunday
+		bin := time.Duration(binSize) * 7 * 24 * time.Hour
+		weekReference := reference.AddDate(0, 0, 1)
+		truncated = weekReference.Add(t.Sub(weekReference) / bin * bin)
+		if truncated.After(t) {
+			truncated = truncated.Add(-bin)
+		}
+	case "month", "quarter", "year":
+		monthsPerUnit := map[string]int64{"month": 1, "quarter": 3, "year": 12}[unit.(string)]
+		months := int64(t.Year()-2000)*12 + int64(t.Month()-1)
+		bin := binSize * monthsPerUnit
+		months = months - ((months%bin)+bin)%bin
+		truncated = reference.AddDate(0, int(months), 0)
+	default:
+		return nil, false, fmt.Errorf("$dateTrunc parameter 'unit' value cannot be recognized as a time unit: %v", unit)
+	}
+	return primitive.NewDateTimeFromTime(truncated), true, nil
+}
+
+// add adds two numbers or a number of milliseconds to a date. Integers stay integers unless overflowing int64
+func add(a, b interface{}) (interface{}, error) {
+	if date, ok := a.(primitive.DateTime); ok {
+		milliseconds, isNumber := toFloat(b)
+		if !isNumber {
+			return nil, fmt.Errorf("only one date allowed in an $add expression")
+		}
+		return date + primitive.DateTime(milliseconds), nil
+	}
+	if _, ok := b.(primitive.DateTime); ok {
+		return add(b, a)
+	}
+	integerA, isIntegerA := toInt64(a)
+	integerB, isIntegerB := toInt64(b)
+	if isIntegerA && isIntegerB {
+		sum := integerA + integerB
+		if (sum > integerA) == (integerB > 0) {
+			return widen(sum, a, b), nil
+		}
+	}
+	floatA, okA := toFloat(a)
+	floatB, okB := toFloat(b)
+	if !okA || !okB {
+		return nil, fmt.Errorf("$add only supports numeric or date types, not %s and %s", typeName(a), typeName(b))
+	}
+	return floatA + floatB, nil
+}
+
+// multiply multiplies two numbers. Integers stay integers unless overflowing int64
+func multiply(a, b interface{}) (interface{}, error) {
+	integerA, isIntegerA := toInt64(a)
+	integerB, isIntegerB := toInt64(b)
+	if isIntegerA && isIntegerB {
+		product := integerA * integerB
+		if integerA == 0 || (product/integerA == integerB && !(integerA == -1 && integerB == math.MinInt64)) {
+			return widen(product, a, b), nil
+		}
+	}
+	floatA, okA := toFloat(a)
+	floatB, okB := toFloat(b)
+	if !okA || !okB {
+		return nil, fmt.Errorf("$multiply only supports numeric types, not %s and %s", typeName(a), typeName(b))
+	}
+	return floatA * floatB, nil
+}
+
+// widen returns an integer result as int32 if both operands are int32 and the result fits, as int64 otherwise
+func widen(result int64, a, b interface{}) interface{} {
+	_, isInt32A := a.(int32)
+	_, isInt32B := b.(int32)
+	if isInt32A && isInt32B {
+		return integer(result)
+	}
+	return result
+}
+
+// binaryArithmetic evaluates $subtract, $divide, $mod and $pow
+func binaryArithmetic(operator string, a, b interface{}) (interface{}, error) {
+	if operator == "$subtract" {
+		dateA, isDateA := a.(primitive.DateTime)
+		dateB, isDateB := b.(primitive.DateTime)
+		switch {
+		case isDateA && isDateB:
+			return int64(dateA - dateB), nil
+		case isDateA:
+			milliseconds, ok := toFloat(b)
+			if !ok {
+				return nil, fmt.Errorf("can't $subtract %s from a date", typeName(b))
+			}
+			return dateA - primitive.DateTime(milliseconds), nil
+		}
+		integerA, isIntegerA := toInt64(a)
+		integerB, isIntegerB := toInt64(b)
+		if isIntegerA && isIntegerB {
+			return widen(integerA-integerB, a, b), nil
+		}
+	}
+	floatA, okA := toFloat(a)
+	floatB, okB := toFloat(b)
+	if !okA || !okB {
+		return nil, fmt.Errorf("%s only supports numeric types, not %s and %s", operator, typeName(a), typeName(b))
+	}
+	switch operator {
+	case "$subtract":
+		return floatA - floatB, nil
+	case "$divide":
+		if floatB == 0 {
+			return nil, fmt.Errorf("can't $divide by zero")
+		}
+		return floatA / floatB, nil
+	case "$mod":
+		if floatB == 0 {
+			return nil, fmt.Errorf("can't $mod by zero")
+		}
+		integerA, isIntegerA := toInt64(a)
+		integerB, isIntegerB := toInt64(b)
+		if isIntegerA && isIntegerB {
+			return widen(integerA%integerB, a, b), nil
+		}
+		return math.Mod(floatA, floatB), nil
+	}
+	return math.Pow(floatA, floatB), nil
+}
@@ -0,0 +1,328 @@
+package memory
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// matches reports whether document matches filter, a normalized query document.
+// Supported are implicit equality, $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin, $exists, $type, $regex, $not, $elemMatch, $size, $all, $and, $or, $nor and $expr.
+func matches(document bson.D, filter bson.D) (bool, error) {
+	for _, element := range filter {
+		var matched bool
+		var err error
+		switch element.Key {
+		case "$and", "$or", "$nor":
+			matched, err = matchLogical(document, element.Key, element.Value)
+		case "$expr":
+			var value interface{}
+			var exists bool
+			value, exists, err = evaluate(element.Value, document)
+			matched = exists && truthy(value)
+		case "$comment":
+			matched = true
+		default:
+			if strings.HasPrefix(element.Key, "$") {
+				return false, fmt.Errorf("unknown top level operator: %s", element.Key)
+			}
+			matched, err = matchField(document, element.Key, element.Value)
+		}
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchLogical evaluates $and, $or and $nor
+func matchLogical(document bson.D, operator string, value interface{}) (bool, error) {
+	conditions, ok := value.(bson.A)
+	if !ok || len(conditions) == 0 {
+		return false, fmt.Errorf("%s must be a nonempty array", operator)
+	}
+	for _, condition := range conditions {
+		conditionDocument, ok := condition.(bson.D)
+		if !ok {
+			return false, fmt.Errorf("%s argument's entries must be objects", operator)
+		}
+		matched, err := matches(document, conditionDocument)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case operator == "$and" && !matched:
+			return false, nil
+		case operator == "$or" && matched:
+			return true, nil
+		case operator == "$nor" && matched:
+			return false, nil
+		}
+	}
+	return operator != "$or", nil
+}
+
+// isOperatorDocument reports whether condition is a document of query operators, eg. {"$gte": 1}, rather than a value compared for equality
+func isOperatorDocument(condition interface{}) bool {
+	document, ok := condition.(bson.D)
+	return ok && len(document) > 0 && strings.HasPrefix(document[0].Key, "$")
+}
+
+// matchField matches the values at path against condition
+func matchField(document bson.D, path string, condition interface{}) (bool, error) {
+	values := lookup(document, path)
+	if isOperatorDocument(condition) {
+		return matchOperators(values, condition.(bson.D))
+	}
+	if regex, ok := condition.(primitive.Regex); ok {
+		return matchRegex(values, regex.Pattern, regex.Options)
+	}
+	return matchEqual(values, condition), nil
+}
+
+// candidates returns values together with the elements of array values. A condition on an array field matches if the array or any element matches
+func candidates(values []interface{}) []interface{} {
+	result := []interface{}{}
+	for _, value := range values {
+		result = append(result, value)
+		if array, ok := value.(bson.A); ok {
+			result = append(result, array...)
+		}
+	}
+	return result
+}
+
+// matchEqual matches if any value or array element equals condition. Null matches missing fields, too
+func matchEqual(values []interface{}, condition interface{}) bool {
+	if condition == nil && len(values) == 0 {
+		return true
+	}
+	for _, value := range candidates(values) {
+		if equal(value, condition) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOperators matches values against all operators of condition
+func matchOperators(values []interface{}, condition bson.D) (bool, error) {
+	for index, element := range condition {
+		var matched bool
+		var err error
+		switch element.Key {
+		case "$eq":
+			matched = matchEqual(values, element.Value)
+		case "$ne":
+			matched = !matchEqual(values, element.Value)
+		case "$gt", "$gte", "$lt", "$lte":
+			matched = matchComparison(values, element.Key, element.Value)
+		case "$in", "$nin":
+			array, ok := element.Value.(bson.A)
+			if !ok {
+				return false, fmt.Errorf("%s needs an array", element.Key)
+			}
+			for _, option := range array {
+				if regex, ok := option.(primitive.Regex); ok {
+					matched, _ = matchRegex(values, regex.Pattern, regex.Options)
+				} else {
+					matched = matchEqual(values, option)
+				}
+				if matched {
+					break
+				}
+			}
+			if element.Key == "$nin" {
+				matched = !matched
+			}
+		case "$exists":
+			matched = (len(values) > 0) == truthy(element.Value)
+		case "$type":
+			matched = matchType(values, element.Value)
+		case "$regex":
+			pattern, options := "", ""
+			switch regex := element.Value.(type) {
+			case string:
+				pattern = regex
+			case primitive.Regex:
+				pattern, options = regex.Pattern, regex.Options
+			default:
+				return false, fmt.Errorf("$regex has to be a string")
+			}
+			if optionsValue, exists := get(condition, "$options"); exists {
+				options, _ = optionsValue.(string)
+			}
+			matched, err = matchRegex(values, pattern, options)
+		case "$options":
+			_, hasRegex := get(condition, "$regex")
+			if !hasRegex {
+				return false, fmt.Errorf("$options needs a $regex")
+			}
+			matched = true
+		case "$not":
+			var inner bool
+			switch not := element.Value.(type) {
+			case bson.D:
+				inner, err = matchOperators(values, not)
+			case primitive.Regex:
+				inner, err = matchRegex(values, not.Pattern, not.Options)
+			default:
+				return false, fmt.Errorf("$not needs a regex or a document")
+			}
+			matched = !inner
+		case "$elemMatch":
+			matched, err = matchElement(values, element.Value)
+		case "$size":
+			size, ok := toInt64(element.Value)
+			if !ok {
+				number, isNumber := toFloat(element.Value)
+				if !isNumber || number != float64(int64(number)) {
+					return false, fmt.Errorf("$size needs a number")
+				}
+				size = int64(number)
+			}
+			for _, value := range values {
+				if array, ok := value.(bson.A); ok && int64(len(array)) == size {
+					matched = true
+				}
+			}
+		case "$all":
+			array, ok := element.Value.(bson.A)
+			if !ok {
+				return false, fmt.Errorf("$all needs an array")
+			}
+			matched = len(array) > 0
+			for _, required := range array {
+				if !matchEqual(values, required) {
+					matched = false
+					break
+				}
+			}
+		default:
+			return false, fmt.Errorf("unknown operator: %s (position %d)", element.Key, index)
+		}
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchComparison matches if any value or array element of the same type bracket as bound compares as operator demands
+func matchComparison(values []interface{}, operator string, bound interface{}) bool {
+	for _, value := range candidates(values) {
+		if typeOrder(value) != typeOrder(bound) {
+			continue
+		}
+		result := compare(value, bound)
+		switch {
+		case operator == "$gt" && result > 0,
+			operator == "$gte" && result >= 0,
+			operator == "$lt" && result < 0,
+			operator == "$lte" && result <= 0:
+			return true
+		}
+	}
+	// Null bounds match missing fields, too
+	if bound == nil && len(values) == 0 && (operator == "$gte" || operator == "$lte") {
+		return true
+	}
+	return false
+}
+
+// matchType matches if any value is of one of the types, given by name, alias 'number' or BSON type number
+func matchType(values []interface{}, types interface{}) bool {
+	typeList, ok := types.(bson.A)
+	if !ok {
+		typeList = bson.A{types}
+	}
+	numbers := map[int64]string{1: "double", 2: "string", 3: "object", 4: "array", 5: "binData", 7: "objectId", 8: "bool", 9: "date", 10: "null", 11: "regex", 16: "int", 17: "timestamp", 18: "long", 19: "decimal"}
+	for _, value := range candidates(values) {
+		name := typeName(value)
+		for _, wanted := range typeList {
+			if number, isNumber := toFloat(wanted); isNumber {
+				wanted = numbers[int64(number)]
+			}
+			if wanted == name || (wanted == "number" && isNumber(value)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchRegex matches if any string value or array element matches pattern
+func matchRegex(values []interface{}, pattern, options string) (bool, error) {
+	flags := ""
+	for _, option := range options {
+		if strings.ContainsRune("imsx", option) {
+			flags += string(option)
+		}
+	}
+	if flags != "" {
+		pattern = "(?" + strings.ReplaceAll(flags, "x", "") + ")" + pattern
+	}
+	expression, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, fmt.Errorf("Regular expression is invalid: %v", err)
+	}
+	for _, value := range candidates(values) {
+		if text, ok := value.(string); ok && expression.MatchString(text) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchElement matches if any element of an array value matches condition, a query on embedded documents or operators on the elements
+func matchElement(values []interface{}, condition interface{}) (bool, error) {
+	conditionDocument, ok := condition.(bson.D)
+	if !ok || len(conditionDocument) == 0 {
+		return false, fmt.Errorf("$elemMatch needs an Object")
+	}
+	// Operators on the elements themselves, eg. {"$gte": 1}, unlike queries on embedded documents, eg. {"$or": [...]} or {"name": "a"}
+	onElements := isOperatorDocument(conditionDocument)
+	switch conditionDocument[0].Key {
+	case "$and", "$or", "$nor", "$expr":
+		onElements = false
+	}
+	for _, value := range values {
+		array, ok := value.(bson.A)
+		if !ok {
+			continue
+		}
+		for _, element := range array {
+			var matched bool
+			var err error
+			if onElements {
+				matched, err = matchOperators([]interface{}{element}, conditionDocument)
+			} else if elementDocument, isDocument := element.(bson.D); isDocument {
+				matched, err = matches(elementDocument, conditionDocument)
+			}
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// truthy evaluates a value as boolean as MongoDB does: false, null, missing and 0 are false
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return false
+	case bool:
+		return v
+	case int32, int64, float64:
+		number, _ := toFloat(v)
+		return number != 0
+	}
+	return true
+}
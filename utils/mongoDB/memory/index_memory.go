@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// index of a collection. Indexes only serve constraints: unique indexes reject duplicate keys, TTL indexes expire documents.
+// Queries always scan the collection.
+type index struct {
+	name           string
+	keys           bson.D
+	unique         bool
+	partialFilter  bson.D // Unique indexes only cover documents matching partialFilter, all documents if nil
+	expireAfterSec *int32
+}
+
+// newIndex returns an ascending index on keys named as by MongoDB, eg. 'device_id_1_key_1'
+func newIndex(keys []string, unique bool, partialFilter bson.D, expireAfterSec *int32) *index {
+	i := &index{keys: bson.D{}, unique: unique, partialFilter: partialFilter, expireAfterSec: expireAfterSec}
+	names := []string{}
+	for _, key := range keys {
+		i.keys = append(i.keys, bson.E{Key: key, Value: int32(1)})
+		names = append(names, key+"_1")
+	}
+	i.name = strings.Join(names, "_")
+	return i
+}
+
+// idIndex is the unique index on _id of each collection but time-series collections
+func idIndex() *index {
+	i := newIndex([]string{"_id"}, true, nil, nil)
+	i.name = "_id_"
+	return i
+}
+
+// sameOptions reports whether other has the same keys and options
+func (i *index) sameOptions(other *index) bool {
+	sameExpiry := (i.expireAfterSec == nil) == (other.expireAfterSec == nil) && (i.expireAfterSec == nil || *i.expireAfterSec == *other.expireAfterSec)
+	return equal(i.keys, other.keys) && i.unique == other.unique && equal(i.partialFilter, other.partialFilter) && sameExpiry
+}
+
+// covers reports whether the unique constraint applies to document
+func (i *index) covers(document bson.D) bool {
+	if i.partialFilter == nil {
+		return true
+	}
+	matched, err := matches(document, i.partialFilter)
+	return err == nil && matched
+}
+
+// key returns the index key of document. Missing fields count as null
+func (i *index) key(document bson.D) string {
+	values := make([]interface{}, len(i.keys))
+	for position, key := range i.keys {
+		values[position], _ = lookupOne(document, key.Key)
+	}
+	return canonicalKey(values...)
+}
+
+// duplicateKeyError returns the error MongoDB reports for a document violating index
+func duplicateKeyError(namespace string, i *index, document bson.D) mongo.WriteError {
+	values := bson.D{}
+	for _, key := range i.keys {
+		value, _ := lookupOne(document, key.Key)
+		values = append(values, bson.E{Key: key.Key, Value: value})
+	}
+	return mongo.WriteError{Code: 11000, Message: fmt.Sprintf("E11000 duplicate key error collection: %s index: %s dup key: %v", namespace, i.name, values)}
+}
+
+// addIndex adds index to c. Adding an index of the same name or keys and the same options is a no-op
+func (c *collection) addIndex(added *index) error {
+	if c.timeSeries != nil && added.unique {
+		return mongo.CommandError{Code: 72, Name: "InvalidOptions", Message: "Unique indexes are not supported on time-series collections"}
+	}
+	for _, existing := range c.indexes {
+		if existing.name != added.name && !equal(existing.keys, added.keys) {
+			continue
+		}
+		if existing.sameOptions(added) {
+			return nil
+		}
+		return mongo.CommandError{Code: 85, Name: "IndexOptionsConflict", Message: fmt.Sprintf("An existing index has the same name or keys as the requested index but different options. Requested index: %s, existing index: %s", added.name, existing.name)}
+	}
+	if added.unique {
+		keys := map[string]bool{}
+		for _, document := range c.documents {
+			if !added.covers(document) {
+				continue
+			}
+			key := added.key(document)
+			if keys[key] {
+				writeError := duplicateKeyError(c.namespace, added, document)
+				return mongo.CommandError{Code: 11000, Name: "DuplicateKey", Message: writeError.Message}
+			}
+			keys[key] = true
+		}
+	}
+	c.indexes = append(c.indexes, added)
+	c.uniqueKeys = nil
+	return nil
+}
+
+// dropIndex drops the index name. Dropping a non-existing index is a no-op
+func (c *collection) dropIndex(name string) error {
+	if name == "_id_" {
+		return mongo.CommandError{Code: 72, Name: "InvalidOptions", Message: "cannot drop _id index"}
+	}
+	for position, existing := range c.indexes {
+		if existing.name == name {
+			c.indexes = append(c.indexes[:position:position], c.indexes[position+1:]...)
+			c.uniqueKeys = nil
+			return nil
+		}
+	}
+	return nil
+}
+
+// checkUnique returns a duplicate key error if document violates a unique index. The document at position skip, which document replaces, is not compared
+func (c *collection) checkUnique(document bson.D, skip int) error {
+	for _, i := range c.indexes {
+		if !i.unique || !i.covers(document) {
+			continue
+		}
+		key := i.key(document)
+		if skip < 0 {
+			// Inserts look keys up in the key sets, built once for consecutive inserts
+			if c.uniqueKeys[i.name][key] {
+				return duplicateKeyError(c.namespace, i, document)
+			}
+			continue
+		}
+		for position, existing := range c.documents {
+			if position != skip && i.covers(existing) && i.key(existing) == key {
+				return duplicateKeyError(c.namespace, i, document)
+			}
+		}
+	}
+	return nil
+}
+
+// buildUniqueKeys builds the key sets of the unique indexes unless built
+func (c *collection) buildUniqueKeys() {
+	if c.uniqueKeys != nil {
+		return
+	}
+	c.uniqueKeys = map[string]map[string]bool{}
+	for _, i := range c.indexes {
+		if !i.unique {
+			continue
+		}
+		keys := map[string]bool{}
+		for _, document := range c.documents {
+			if i.covers(document) {
+				keys[i.key(document)] = true
+			}
+		}
+		c.uniqueKeys[i.name] = keys
+	}
+}
+
+// expire removes documents whose date in the field of a TTL index is more than expireAfterSec seconds before now.
+// MongoDB removes expired documents once a minute, here they are removed before each operation.
+func (c *collection) expire(now time.Time) {
+	for _, i := range c.indexes {
+		if i.expireAfterSec == nil {
+			continue
+		}
+		expiry := primitive.NewDateTimeFromTime(now.Add(-time.Duration(*i.expireAfterSec) * time.Second))
+		kept := make([]bson.D, 0, len(c.documents))
+		for _, document := range c.documents {
+			value, _ := lookupOne(document, i.keys[0].Key)
+			if date, ok := value.(primitive.DateTime); ok && date < expiry {
+				continue
+			}
+			kept = append(kept, document)
+		}
+		if len(kept) != len(c.documents) {
+			c.documents = kept
+			c.uniqueKeys = nil
+		}
+	}
+}
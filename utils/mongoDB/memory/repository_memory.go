@@ -0,0 +1,730 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// //////////////////////////////////////////////////////////////////////
+// In-memory implementation of mongodb.Repository for tests and local development without MongoDB server.
+// Supports the queries, updates, aggregation stages and indexes used by this application, see matches, applyUpdate and aggregate.
+// Operations fail if ctx has ended before they start. They don't block, so there are no deadlines.
+// Transactions are simulated, see session. Change streams are not supported. Data is lost when the process ends.
+// ///////////////////
+type Repository struct {
+	mutex       sync.Mutex
+	transaction sync.Mutex // Held by the session of the running transaction
+	databases   map[string]map[string]*collection
+	now         func() time.Time
+}
+
+// collection of documents. Stored documents are never changed in place, so snapshots of transactions can share them
+type collection struct {
+	namespace  string // '<database>.<collection>'
+	documents  []bson.D
+	indexes    []*index
+	timeSeries *mongodb.TimeSeriesOptions // Options of time-series collections, nil otherwise
+	uniqueKeys map[string]map[string]bool // Keys of unique indexes by index name, built on demand
+}
+
+var _ mongodb.Repository = &Repository{}
+
+// New returns an empty in-memory repository
+func New() *Repository {
+	return &Repository{databases: map[string]map[string]*collection{}, now: time.Now}
+}
+
+// NewMethodInterface returns the method interface of an empty in-memory repository, eg. to run the API with '-dev' or in tests
+func NewMethodInterface() *mongodb.MethodInterface {
+	return &mongodb.MethodInterface{RepositoryInterface: New()}
+}
+
+func newCollection(namespace string, timeSeries *mongodb.TimeSeriesOptions) *collection {
+	c := &collection{namespace: namespace, timeSeries: timeSeries}
+	if timeSeries == nil {
+		c.indexes = []*index{idIndex()}
+	}
+	return c
+}
+
+// database returns the collections of database databaseName. The caller must hold the lock
+func (r *Repository) database(databaseName string) map[string]*collection {
+	database, exists := r.databases[databaseName]
+	if !exists {
+		database = map[string]*collection{}
+		r.databases[databaseName] = database
+	}
+	return database
+}
+
+// collection returns a collection, created if missing and create is set, nil otherwise. Expired documents are removed. The caller must hold the lock
+func (r *Repository) collection(databaseName, collectionName string, create bool) *collection {
+	database := r.database(databaseName)
+	c, exists := database[collectionName]
+	if !exists {
+		if !create {
+			return nil
+		}
+		c = newCollection(databaseName+"."+collectionName, nil)
+		database[collectionName] = c
+	}
+	c.expire(r.now())
+	return c
+}
+
+// documents returns the documents of a collection, none if it doesn't exist. The caller must hold the lock
+func (r *Repository) documents(databaseName, collectionName string) []bson.D {
+	if c := r.collection(databaseName, collectionName, false); c != nil {
+		return c.documents
+	}
+	return nil
+}
+
+// insert inserts document, a normalized document owned by c. An ObjectID is added as _id if missing. Returns the _id
+func (c *collection) insert(document bson.D) (interface{}, error) {
+	id, exists := get(document, "_id")
+	if !exists {
+		id = primitive.NewObjectID()
+		document = append(bson.D{{Key: "_id", Value: id}}, document...)
+	}
+	if c.timeSeries != nil {
+		if value, _ := get(document, c.timeSeries.TimeField); typeName(value) != "date" {
+			return nil, mongo.WriteError{Code: 2, Message: fmt.Sprintf("'%s' must be present and contain a valid BSON UTC datetime value", c.timeSeries.TimeField)}
+		}
+	}
+	c.buildUniqueKeys()
+	if err := c.checkUnique(document, -1); err != nil {
+		return nil, err
+	}
+	for _, i := range c.indexes {
+		if i.unique && i.covers(document) {
+			c.uniqueKeys[i.name][i.key(document)] = true
+		}
+	}
+	c.documents = append(c.documents, document)
+	return id, nil
+}
+
+// replace replaces the document at position by document, a normalized document owned by c
+func (c *collection) replace(position int, document bson.D) error {
+	if err := c.checkUnique(document, position); err != nil {
+		return err
+	}
+	c.documents[position] = document
+	c.uniqueKeys = nil
+	return nil
+}
+
+// remove removes the documents at positions, given in ascending order
+func (c *collection) remove(positions []int) {
+	if len(positions) == 0 {
+		return
+	}
+	kept := make([]bson.D, 0, len(c.documents)-len(positions))
+	next := 0
+	for position, document := range c.documents {
+		if next < len(positions) && positions[next] == position {
+			next++
+			continue
+		}
+		kept = append(kept, document)
+	}
+	c.documents = kept
+	c.uniqueKeys = nil
+}
+
+// find returns the positions of the documents of c matching filter, at most limit if limit is positive
+func (c *collection) find(filter bson.D, limit int) ([]int, error) {
+	positions := []int{}
+	for position, document := range c.documents {
+		matched, err := matches(document, filter)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			positions = append(positions, position)
+			if len(positions) == limit {
+				break
+			}
+		}
+	}
+	return positions, nil
+}
+
+// query returns copies of the documents of a collection matching filter, sorted by sort
+func (r *Repository) query(databaseName string, filter bson.M, collectionName string, sortFields bson.D) ([]bson.D, error) {
+	normalizedFilter, err := normalize(filter)
+	if err != nil {
+		return nil, err
+	}
+	normalizedSort, err := normalize(sortFields)
+	if err != nil {
+		return nil, err
+	}
+	result := []bson.D{}
+	for _, document := range r.documents(databaseName, collectionName) {
+		matched, err := matches(document, normalizedFilter)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			result = append(result, document)
+		}
+	}
+	if err := sortDocuments(result, normalizedSort); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// insertedID converts an inserted _id to string as the MongoDB client does
+func insertedID(id interface{}) (string, error) {
+	switch v := id.(type) {
+	case primitive.ObjectID:
+		return v.Hex(), nil
+	case string:
+		return v, nil
+	case int32, int64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("unexpected type for InsertedID: %T", id)
+}
+
+func (r *Repository) InsertOneToMongo(ctx context.Context, databaseName string, data interface{}, collectionName string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	document, err := normalize(data)
+	if err != nil {
+		return "", err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id, err := r.collection(databaseName, collectionName, true).insert(document)
+	var writeError mongo.WriteError
+	if errors.As(err, &writeError) {
+		return "", mongo.WriteException{WriteErrors: mongo.WriteErrors{writeError}}
+	}
+	if err != nil {
+		return "", err
+	}
+	return insertedID(id)
+}
+
+// InsertManyToMongo inserts all documents unordered. On partial failure the returned error is a mongo.BulkWriteException as returned by MongoDB
+func (r *Repository) InsertManyToMongo(ctx context.Context, databaseName string, data []interface{}, collectionName string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, mongo.ErrEmptySlice
+	}
+	documents := make([]bson.D, len(data))
+	for index, item := range data {
+		document, err := normalize(item)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := get(document, "_id"); !exists {
+			document = append(bson.D{{Key: "_id", Value: primitive.NewObjectID()}}, document...)
+		}
+		documents[index] = document
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	c := r.collection(databaseName, collectionName, true)
+	insertedIDs := make([]string, 0, len(documents))
+	var bulkWriteException mongo.BulkWriteException
+	for index, document := range documents {
+		id, _ := get(document, "_id")
+		if _, err := c.insert(document); err != nil {
+			var writeError mongo.WriteError
+			if !errors.As(err, &writeError) {
+				return insertedIDs, err
+			}
+			writeError.Index = index
+			bulkWriteException.WriteErrors = append(bulkWriteException.WriteErrors, mongo.BulkWriteError{WriteError: writeError})
+		}
+		idString, err := insertedID(id)
+		if err != nil {
+			return insertedIDs, err
+		}
+		insertedIDs = append(insertedIDs, idString)
+	}
+	if len(bulkWriteException.WriteErrors) > 0 {
+		return insertedIDs, bulkWriteException
+	}
+	return insertedIDs, nil
+}
+
+// Check if value for field name (key) exists in collection
+func (r *Repository) IsValueInCollection(ctx context.Context, databaseName, collectionName, fieldName, fieldValue string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	documents, err := r.query(databaseName, bson.M{fieldName: fieldValue}, collectionName, nil)
+	return len(documents) != 0, err
+}
+
+func (r *Repository) UpdateOneInMongo(ctx context.Context, databaseName string, filter bson.M, update bson.M, collectionName string) (*mongo.UpdateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result, err := r.update(databaseName, filter, update, collectionName, 1)
+	if err != nil {
+		return nil, fmt.Errorf("Error when updating documents in collection '%s' of database '%s' in 'UpdateOneInMongo()' using 'UpdateOne()'. Error: %w", collectionName, databaseName, err)
+	}
+	return result, nil
+}
+
+func (r *Repository) UpdateManyInMongo(ctx context.Context, databaseName string, filter bson.M, update bson.M, collectionName string) (*mongo.UpdateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result, err := r.update(databaseName, filter, update, collectionName, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Error when updating documents in collection '%s' of database '%s' in 'UpdateManyInMongo()' using 'UpdateMany()'. Error: %w", collectionName, databaseName, err)
+	}
+	return result, nil
+}
+
+// update updates the documents matching filter, at most limit if limit is positive. Documents updated before a failing update stay updated, as by MongoDB
+func (r *Repository) update(databaseName string, filter bson.M, update bson.M, collectionName string, limit int) (*mongo.UpdateResult, error) {
+	normalizedFilter, err := normalize(filter)
+	if err != nil {
+		return nil, err
+	}
+	normalizedUpdate, err := normalize(update)
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := &mongo.UpdateResult{}
+	c := r.collection(databaseName, collectionName, false)
+	if c == nil {
+		return result, nil
+	}
+	positions, err := c.find(normalizedFilter, limit)
+	if err != nil {
+		return nil, err
+	}
+	now := r.now()
+	for _, position := range positions {
+		result.MatchedCount++
+		updated, err := applyUpdate(c.documents[position], normalizedUpdate, now)
+		if err != nil {
+			return nil, err
+		}
+		if equal(updated, c.documents[position]) {
+			continue
+		}
+		if err := c.replace(position, updated); err != nil {
+			var writeError mongo.WriteError
+			if errors.As(err, &writeError) {
+				return nil, mongo.WriteException{WriteErrors: mongo.WriteErrors{writeError}}
+			}
+			return nil, err
+		}
+		result.ModifiedCount++
+	}
+	return result, nil
+}
+
+func (r *Repository) FindOneInMongo(ctx context.Context, databaseName string, filter bson.M, collectionName string, sort bson.D, result interface{}) (foundOne bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	documents, err := r.query(databaseName, filter, collectionName, sort)
+	if err != nil {
+		return false, err
+	}
+	if len(documents) == 0 {
+		return false, nil
+	}
+	return true, decode(documents[0], result)
+}
+
+func (r *Repository) FindManyInMongo(ctx context.Context, databaseName string, filter bson.M, collectionName string, sort bson.D, result interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	documents, err := r.query(databaseName, filter, collectionName, sort)
+	if err != nil {
+		return err
+	}
+	return decodeAll(documents, result)
+}
+
+// AggregateInMongo runs an aggregation pipeline on collection and decodes the resulting documents into result, a pointer to a slice.
+// Result may be nil for pipelines writing their output with '$merge' or '$out'.
+func (r *Repository) AggregateInMongo(ctx context.Context, databaseName string, collectionName string, pipeline mongo.Pipeline, result interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	documents, err := r.aggregatePipeline(databaseName, collectionName, pipeline)
+	if err != nil {
+		return fmt.Errorf("Error when aggregating documents in collection '%s' of database '%s' in 'AggregateInMongo()' using 'Aggregate()'. Error: %w", collectionName, databaseName, err)
+	}
+	if result == nil {
+		return nil
+	}
+	return decodeAll(documents, result)
+}
+
+// aggregatePipeline normalizes pipeline and runs it on collectionName
+func (r *Repository) aggregatePipeline(databaseName string, collectionName string, pipeline mongo.Pipeline) ([]bson.D, error) {
+	stages := make([]bson.D, len(pipeline))
+	for index, stage := range pipeline {
+		normalizedStage, err := normalize(stage)
+		if err != nil {
+			return nil, err
+		}
+		stages[index] = normalizedStage
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.aggregate(databaseName, r.documents(databaseName, collectionName), stages)
+}
+
+func (r *Repository) DeleteDocumentMongo(ctx context.Context, databaseName string, filter bson.M, collectionName string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	deleted, err := r.delete(databaseName, filter, collectionName, 1)
+	if err != nil {
+		return nil, fmt.Errorf("Error when deleting document in collection '%s' of database '%s' in 'DeleteDocumentMongo()' using 'DeleteOne()'. Filter: %+v Error: %w", collectionName, databaseName, filter, err)
+	}
+	return &mongo.DeleteResult{DeletedCount: deleted}, nil
+}
+
+// DeleteManyMongo deletes all documents matching filter. Returns the number of deleted documents
+func (r *Repository) DeleteManyMongo(ctx context.Context, databaseName string, filter bson.M, collectionName string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	deleted, err := r.delete(databaseName, filter, collectionName, 0)
+	if err != nil {
+		return 0, fmt.Errorf("Error when deleting documents in collection '%s' of database '%s' in 'DeleteManyMongo()' using 'DeleteMany()'. Filter: %+v Error: %w", collectionName, databaseName, filter, err)
+	}
+	return deleted, nil
+}
+
+// delete deletes the documents matching filter, at most limit if limit is positive
+func (r *Repository) delete(databaseName string, filter bson.M, collectionName string, limit int) (int64, error) {
+	normalizedFilter, err := normalize(filter)
+	if err != nil {
+		return 0, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	c := r.collection(databaseName, collectionName, false)
+	if c == nil {
+		return 0, nil
+	}
+	positions, err := c.find(normalizedFilter, limit)
+	if err != nil {
+		return 0, err
+	}
+	c.remove(positions)
+	return int64(len(positions)), nil
+}
+
+// DeleteCollectionMongo drops a collection. Dropping a non-existing collection is a no-op
+func (r *Repository) DeleteCollectionMongo(ctx context.Context, databaseName string, collectionName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.database(databaseName), collectionName)
+	return nil
+}
+
+func (r *Repository) CreateNewCollection(ctx context.Context, databaseName, collectionName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.createCollection(databaseName, collectionName, nil)
+}
+
+// createCollection creates a collection. The caller must hold the lock
+func (r *Repository) createCollection(databaseName, collectionName string, timeSeries *mongodb.TimeSeriesOptions) error {
+	database := r.database(databaseName)
+	if _, exists := database[collectionName]; exists {
+		return mongo.CommandError{Code: 48, Name: "NamespaceExists", Message: fmt.Sprintf("Collection %s.%s already exists.", databaseName, collectionName)}
+	}
+	database[collectionName] = newCollection(databaseName+"."+collectionName, timeSeries)
+	return nil
+}
+
+// timeSeriesOptions reads the time-series options of a timeseries specification {timeField, metaField, granularity}
+func timeSeriesOptions(specification bson.D) (mongodb.TimeSeriesOptions, error) {
+	var timeSeries mongodb.TimeSeriesOptions
+	timeField, _ := get(specification, "timeField")
+	metaField, _ := get(specification, "metaField")
+	granularity, exists := get(specification, "granularity")
+	if !exists {
+		granularity = "seconds"
+	}
+	timeSeries.TimeField, _ = timeField.(string)
+	timeSeries.MetaField, _ = metaField.(string)
+	timeSeries.Granularity, _ = granularity.(string)
+	return timeSeries, validateTimeSeries(timeSeries)
+}
+
+// granularityOrder ranks granularities from fine to coarse
+var granularityOrder = map[string]int{"seconds": 0, "minutes": 1, "hours": 2}
+
+func validateTimeSeries(timeSeries mongodb.TimeSeriesOptions) error {
+	if timeSeries.TimeField == "" {
+		return mongo.CommandError{Code: 40414, Name: "Location40414", Message: "BSON field 'timeseries.timeField' is missing but a required field"}
+	}
+	if _, ok := granularityOrder[timeSeries.Granularity]; !ok {
+		return mongo.CommandError{Code: 2, Name: "BadValue", Message: fmt.Sprintf("Enumeration value '%s' for field 'timeseries.granularity' is not a valid value", timeSeries.Granularity)}
+	}
+	return nil
+}
+
+// CreateTimeSeriesCollection creates a time-series collection. Documents must contain the time field
+func (r *Repository) CreateTimeSeriesCollection(ctx context.Context, databaseName, collectionName string, timeSeries mongodb.TimeSeriesOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	err := validateTimeSeries(timeSeries)
+	if err == nil {
+		err = r.createCollection(databaseName, collectionName, &timeSeries)
+	}
+	if err != nil {
+		return fmt.Errorf("Error when creating time-series collection '%s' of database '%s' in 'CreateTimeSeriesCollection()' using 'CreateCollection()'. Error: %w", collectionName, databaseName, err)
+	}
+	return nil
+}
+
+// SetTimeSeriesGranularity changes the granularity of a time-series collection. As MongoDB, only coarser granularities are permitted
+func (r *Repository) SetTimeSeriesGranularity(ctx context.Context, databaseName, collectionName, granularity string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var err error
+	c := r.collection(databaseName, collectionName, false)
+	order, valid := granularityOrder[granularity]
+	switch {
+	case c == nil:
+		err = mongo.CommandError{Code: 26, Name: "NamespaceNotFound", Message: "ns does not exist"}
+	case c.timeSeries == nil:
+		err = mongo.CommandError{Code: 72, Name: "InvalidOptions", Message: "option only supported on a time-series collection: timeseries"}
+	case !valid || order < granularityOrder[c.timeSeries.Granularity]:
+		err = mongo.CommandError{Code: 72, Name: "InvalidOptions", Message: fmt.Sprintf("Invalid transition for timeseries.granularity. Can only transition from '%s' to a coarser granularity", c.timeSeries.Granularity)}
+	}
+	if err != nil {
+		return fmt.Errorf("Error when changing granularity of time-series collection '%s' of database '%s' in 'SetTimeSeriesGranularity()' using 'RunCommand()'. Error: %w", collectionName, databaseName, err)
+	}
+	timeSeries := *c.timeSeries
+	timeSeries.Granularity = granularity
+	c.timeSeries = &timeSeries
+	return nil
+}
+
+// ConvertToTimeSeriesCollection converts a collection into a time-series collection of the same name as the MongoDB client does: the original documents,
+// transformed by pipeline, are written to the new time-series collection and kept in '<collectionName>_premigration' if documents without time field have been skipped.
+func (r *Repository) ConvertToTimeSeriesCollection(ctx context.Context, databaseName, collectionName string, timeSeries mongodb.TimeSeriesOptions, pipeline mongo.Pipeline) (converted int64, skipped int64, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	stages := make([]bson.D, 0, len(pipeline)+1)
+	for _, stage := range append(append(mongo.Pipeline{}, pipeline...), bson.D{{Key: "$match", Value: bson.M{timeSeries.TimeField: bson.M{"$type": "date"}}}}) {
+		normalizedStage, err := normalize(stage)
+		if err != nil {
+			return 0, 0, err
+		}
+		stages = append(stages, normalizedStage)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	backupName := collectionName + "_premigration"
+	database := r.database(databaseName)
+	original := r.collection(databaseName, collectionName, false)
+	if original == nil {
+		return 0, 0, fmt.Errorf("Error when renaming collection '%s' of database '%s' to '%s' in 'ConvertToTimeSeriesCollection()'. Error: %v", collectionName, databaseName, backupName, mongo.CommandError{Code: 26, Name: "NamespaceNotFound", Message: "Source collection " + databaseName + "." + collectionName + " does not exist"})
+	}
+	if err := validateTimeSeries(timeSeries); err != nil {
+		return 0, 0, fmt.Errorf("Error in 'ConvertToTimeSeriesCollection()': cannot write documents of collection '%s' to time-series collection: %v", backupName, err)
+	}
+	documents, err := r.aggregate(databaseName, original.documents, stages)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Error in 'ConvertToTimeSeriesCollection()': cannot write documents of collection '%s' to time-series collection: %v", backupName, err)
+	}
+	converted = int64(len(documents))
+	skipped = int64(len(original.documents)) - converted
+
+	replacement := newCollection(original.namespace, &timeSeries)
+	for _, document := range documents {
+		if _, err := replacement.insert(document); err != nil {
+			return 0, 0, fmt.Errorf("Error in 'ConvertToTimeSeriesCollection()': cannot write documents of collection '%s' to time-series collection: %v", backupName, err)
+		}
+	}
+	database[collectionName] = replacement
+	if skipped > 0 {
+		original.namespace = databaseName + "." + backupName
+		database[backupName] = original
+	}
+	return converted, skipped, nil
+}
+
+// ListCollections returns the collections of a database matching filter, eg. bson.M{"name": "plant_logger_123456789012"}, sorted by name
+func (r *Repository) ListCollections(ctx context.Context, databaseName string, filter bson.M) ([]mongodb.CollectionInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	normalizedFilter, err := normalize(filter)
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	names := []string{}
+	for name := range r.database(databaseName) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collections := []mongodb.CollectionInfo{}
+	for _, name := range names {
+		c := r.databases[databaseName][name]
+		collection := mongodb.CollectionInfo{Name: name, Type: "collection"}
+		specification := bson.D{{Key: "name", Value: name}, {Key: "type", Value: "collection"}, {Key: "options", Value: bson.D{}}}
+		if c.timeSeries != nil {
+			collection.Type, collection.TimeSeriesGranularity = "timeseries", c.timeSeries.Granularity
+			timeSeries := bson.D{{Key: "timeField", Value: c.timeSeries.TimeField}, {Key: "granularity", Value: c.timeSeries.Granularity}}
+			if c.timeSeries.MetaField != "" {
+				timeSeries = append(timeSeries, bson.E{Key: "metaField", Value: c.timeSeries.MetaField})
+			}
+			specification = bson.D{{Key: "name", Value: name}, {Key: "type", Value: "timeseries"}, {Key: "options", Value: bson.D{{Key: "timeseries", Value: timeSeries}}}}
+		}
+		matched, err := matches(specification, normalizedFilter)
+		if err != nil {
+			return nil, fmt.Errorf("Error when listing collections of database '%s' in 'ListCollections()' using 'ListCollectionSpecifications()'. Error: %w", databaseName, err)
+		}
+		if matched {
+			collections = append(collections, collection)
+		}
+	}
+	return collections, nil
+}
+
+func (r *Repository) CountDocumentsInMongo(ctx context.Context, databaseName string, collectionName string, result interface{}) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.documents(databaseName, collectionName)), nil
+}
+
+// addIndex adds an index to a collection, created if missing
+func (r *Repository) addIndex(collectionName, databaseName string, added *index) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.collection(databaseName, collectionName, true).addIndex(added)
+}
+
+// CreateIndex creates a non-unique ascending index on fieldNames. Creating an already existing index is a no-op
+func (r *Repository) CreateIndex(ctx context.Context, collectionName string, databaseName string, fieldNames ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.addIndex(collectionName, databaseName, newIndex(fieldNames, false, nil, nil))
+}
+
+func (r *Repository) CreateUniqueIndex(ctx context.Context, collectionName string, databaseName string, fieldName string, unique bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.addIndex(collectionName, databaseName, newIndex([]string{fieldName}, unique, nil, nil))
+}
+
+// CreatePartialUniqueIndex creates a unique index on fieldName only covering documents containing the field, preceded by scopeFieldNames
+func (r *Repository) CreatePartialUniqueIndex(ctx context.Context, collectionName string, databaseName string, fieldName string, scopeFieldNames ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	partialFilter := bson.D{{Key: fieldName, Value: bson.D{{Key: "$exists", Value: true}}}}
+	return r.addIndex(collectionName, databaseName, newIndex(append(append([]string{}, scopeFieldNames...), fieldName), true, partialFilter, nil))
+}
+
+// CreateUniqueStringIndex creates a unique index on fieldName only covering documents with a non-empty string in the field
+func (r *Repository) CreateUniqueStringIndex(ctx context.Context, collectionName string, databaseName string, fieldName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	partialFilter := bson.D{{Key: fieldName, Value: bson.D{{Key: "$gt", Value: ""}}}}
+	return r.addIndex(collectionName, databaseName, newIndex([]string{fieldName}, true, partialFilter, nil))
+}
+
+// DropIndex drops the index indexName, eg. 'sequence_1'. Dropping a non-existing index or an index of a non-existing collection is a no-op
+func (r *Repository) DropIndex(ctx context.Context, collectionName string, databaseName string, indexName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	c := r.collection(databaseName, collectionName, false)
+	if c == nil {
+		return nil
+	}
+	return c.dropIndex(indexName)
+}
+
+// CreateTTLIndex creates an index on the date field fieldName. Documents are removed expireAfterSec seconds after this date
+func (r *Repository) CreateTTLIndex(ctx context.Context, collectionName string, databaseName string, fieldName string, expireAfterSec int32) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.addIndex(collectionName, databaseName, newIndex([]string{fieldName}, false, nil, &expireAfterSec))
+}
+
+// WatchCollection fails as MongoDB servers without replica set do, change streams are not supported
+func (r *Repository) WatchCollection(ctx context.Context, databaseName string, collectionName string) (*mongo.ChangeStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, mongo.CommandError{Code: 40573, Name: "Location40573", Message: "The $changeStream stage is only supported on replica sets"}
+}
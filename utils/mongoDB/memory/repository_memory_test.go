@@ -0,0 +1,285 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type reading struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	Sequence int64              `bson:"sequence"`
+	Power    float64            `bson:"power"`
+	Note     string             `bson:"note,omitempty"`
+	Time     time.Time          `bson:"time"`
+}
+
+func insertReadings(t *testing.T, repository *Repository, count int) time.Time {
+	ctx := context.Background()
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < count; i++ {
+		_, err := repository.InsertOneToMongo(ctx, "db", reading{Sequence: int64(i), Power: float64(i * 10), Time: start.Add(time.Duration(i) * time.Hour)}, "readings")
+		assert.NoError(t, err)
+	}
+	return start
+}
+
+func TestFindWithRangeAndSort(t *testing.T) {
+	ctx := context.Background()
+	repository := New()
+	start := insertReadings(t, repository, 5)
+
+	var result []reading
+	filter := bson.M{"time": bson.M{"$gte": start.Add(time.Hour), "$lt": start.Add(4 * time.Hour)}}
+	assert.NoError(t, repository.FindManyInMongo(ctx, "db", filter, "readings", bson.D{{Key: "time", Value: -1}}, &result))
+	assert.Len(t, result, 3)
+	assert.Equal(t, []int64{3, 2, 1}, []int64{result[0].Sequence, result[1].Sequence, result[2].Sequence})
+
+	var first reading
+	found, err := repository.FindOneInMongo(ctx, "db", bson.M{"power": bson.M{"$gte": 25}}, "readings", bson.D{{Key: "power", Value: 1}}, &first)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(3), first.Sequence)
+
+	found, err = repository.FindOneInMongo(ctx, "db", bson.M{"sequence": 99}, "readings", nil, &first)
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestFindCursor(t *testing.T) {
+	ctx := context.Background()
+	repository := New()
+	insertReadings(t, repository, 5)
+
+	findOptions := mongodb.FindOptions{Projection: bson.M{"sequence": 1}, Sort: bson.D{{Key: "sequence", Value: -1}}, Skip: 1, Limit: 3, BatchSize: 2}
+	cursor, err := repository.FindCursorInMongo(ctx, "db", bson.M{}, "readings", findOptions)
+	assert.NoError(t, err)
+	defer cursor.Close(ctx)
+	sequences := []int64{}
+	for cursor.Next(ctx) {
+		var result reading
+		assert.NoError(t, cursor.Decode(&result))
+		assert.Zero(t, result.Power)
+		assert.False(t, result.ID.IsZero())
+		sequences = append(sequences, result.Sequence)
+	}
+	assert.NoError(t, cursor.Err())
+	assert.Equal(t, []int64{3, 2, 1}, sequences)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cursor, err = repository.FindCursorInMongo(ctx, "db", bson.M{}, "readings", mongodb.FindOptions{})
+	assert.NoError(t, err)
+	cancel()
+	assert.False(t, cursor.Next(canceled))
+	assert.ErrorIs(t, cursor.Err(), context.Canceled)
+}
+
+func TestUpdateAndDelete(t *testing.T) {
+	ctx := context.Background()
+	repository := New()
+	insertReadings(t, repository, 4)
+
+	result, err := repository.UpdateManyInMongo(ctx, "db", bson.M{"sequence": bson.M{"$lt": 2}}, bson.M{"$set": bson.M{"note": "checked"}}, "readings")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.MatchedCount)
+	assert.Equal(t, int64(2), result.ModifiedCount)
+
+	var checked []reading
+	assert.NoError(t, repository.FindManyInMongo(ctx, "db", bson.M{"note": "checked"}, "readings", nil, &checked))
+	assert.Len(t, checked, 2)
+
+	deleted, err := repository.DeleteManyMongo(ctx, "db", bson.M{"note": bson.M{"$exists": false}}, "readings")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	count, err := repository.CountDocumentsInMongo(ctx, "db", "readings", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestUniqueIndex(t *testing.T) {
+	ctx := context.Background()
+	repository := New()
+	assert.NoError(t, repository.CreateUniqueIndex(ctx, "readings", "db", "sequence", true))
+	insertReadings(t, repository, 2)
+
+	_, err := repository.InsertOneToMongo(ctx, "db", reading{Sequence: 1}, "readings")
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+
+	// Documents inserted before a duplicate stay inserted
+	ids, err := repository.InsertManyToMongo(ctx, "db", []interface{}{reading{Sequence: 2}, reading{Sequence: 0}, reading{Sequence: 3}}, "readings")
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+	assert.Len(t, ids, 3)
+	count, _ := repository.CountDocumentsInMongo(ctx, "db", "readings", nil)
+	assert.Equal(t, 4, count)
+
+	_, err = repository.UpdateOneInMongo(ctx, "db", bson.M{"sequence": 3}, bson.M{"$set": bson.M{"sequence": 0}}, "readings")
+	assert.True(t, mongo.IsDuplicateKeyError(err))
+
+	assert.NoError(t, repository.DropIndex(ctx, "readings", "db", "sequence_1"))
+	_, err = repository.InsertOneToMongo(ctx, "db", reading{Sequence: 1}, "readings")
+	assert.NoError(t, err)
+}
+
+func TestTransactionAbortRestoresData(t *testing.T) {
+	ctx := context.Background()
+	repository := New()
+	insertReadings(t, repository, 1)
+	session, err := repository.StartSession()
+	assert.NoError(t, err)
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		if _, err := repository.InsertOneToMongo(ctx, "db", reading{Sequence: 1}, "readings"); err != nil {
+			return nil, err
+		}
+		if err := repository.CreateNewCollection(ctx, "db", "plant_logger_1"); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("failed")
+	})
+	assert.Error(t, err)
+	count, _ := repository.CountDocumentsInMongo(ctx, "db", "readings", nil)
+	assert.Equal(t, 1, count)
+	collections, err := repository.ListCollections(ctx, "db", bson.M{"name": "plant_logger_1"})
+	assert.NoError(t, err)
+	assert.Empty(t, collections)
+
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return repository.InsertOneToMongo(ctx, "db", reading{Sequence: 1}, "readings")
+	})
+	assert.NoError(t, err)
+	count, _ = repository.CountDocumentsInMongo(ctx, "db", "readings", nil)
+	assert.Equal(t, 2, count)
+}
+
+func TestRunTransaction(t *testing.T) {
+	ctx := context.Background()
+	repository := New()
+	insertReadings(t, repository, 1)
+
+	err := repository.RunTransaction(ctx, func(ctx context.Context, unitOfWork mongodb.Repository) error {
+		if _, err := unitOfWork.InsertOneToMongo(ctx, "db", reading{Sequence: 1}, "readings"); err != nil {
+			return err
+		}
+		return unitOfWork.DeleteCollectionMongo(ctx, "db", "readings")
+	})
+	var commandError mongo.CommandError
+	assert.True(t, errors.As(err, &commandError))
+	assert.Equal(t, "OperationNotSupportedInTransaction", commandError.Name)
+	count, _ := repository.CountDocumentsInMongo(ctx, "db", "readings", nil)
+	assert.Equal(t, 1, count)
+
+	err = repository.RunTransaction(ctx, func(ctx context.Context, unitOfWork mongodb.Repository) error {
+		_, err := unitOfWork.DeleteManyMongo(ctx, "db", bson.M{}, "readings")
+		return err
+	})
+	assert.NoError(t, err)
+	count, _ = repository.CountDocumentsInMongo(ctx, "db", "readings", nil)
+	assert.Equal(t, 0, count)
+}
+
+func TestTimeSeriesCollection(t *testing.T) {
+	ctx := context.Background()
+	repository := New()
+	timeSeries := mongodb.TimeSeriesOptions{TimeField: "time", MetaField: "meta", Granularity: "minutes"}
+	assert.NoError(t, repository.CreateTimeSeriesCollection(ctx, "db", "readings", timeSeries))
+	assert.Error(t, repository.CreateTimeSeriesCollection(ctx, "db", "readings", timeSeries))
+
+	_, err := repository.InsertOneToMongo(ctx, "db", bson.M{"power": 1}, "readings")
+	assert.Error(t, err)
+	insertReadings(t, repository, 1)
+
+	assert.Error(t, repository.SetTimeSeriesGranularity(ctx, "db", "readings", "seconds"))
+	assert.NoError(t, repository.SetTimeSeriesGranularity(ctx, "db", "readings", "hours"))
+	collections, err := repository.ListCollections(ctx, "db", bson.M{"type": "timeseries"})
+	assert.NoError(t, err)
+	assert.Equal(t, []mongodb.CollectionInfo{{Name: "readings", Type: "timeseries", TimeSeriesGranularity: "hours"}}, collections)
+}
+
+func TestAggregateGroup(t *testing.T) {
+	ctx := context.Background()
+	repository := New()
+	insertReadings(t, repository, 4)
+
+	var result []struct {
+		Even  bool    `bson:"_id"`
+		Total float64 `bson:"total"`
+		Count int     `bson:"count"`
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"sequence": bson.M{"$gte": 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.M{"$eq": bson.A{bson.M{"$mod": bson.A{"$sequence", 2}}, 0}}},
+			{Key: "total", Value: bson.M{"$sum": "$power"}},
+			{Key: "count", Value: bson.M{"$sum": 1}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+	assert.NoError(t, repository.AggregateInMongo(ctx, "db", "readings", pipeline, &result))
+	assert.Len(t, result, 2)
+	assert.Equal(t, 40.0, result[0].Total)
+	assert.Equal(t, 2, result[0].Count)
+	assert.Equal(t, 20.0, result[1].Total)
+	assert.Equal(t, 1, result[1].Count)
+}
+
+func TestAggregateFacetBucketPercentile(t *testing.T) {
+	ctx := context.Background()
+	repository := New()
+	insertReadings(t, repository, 4)
+
+	var result []struct {
+		Percentiles []struct {
+			Values []float64 `bson:"values"`
+		} `bson:"percentiles"`
+		Buckets []struct {
+			ID    interface{} `bson:"_id"`
+			Count int         `bson:"count"`
+		} `bson:"buckets"`
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$facet", Value: bson.M{
+			"percentiles": bson.A{
+				bson.M{"$group": bson.M{"_id": nil, "values": bson.M{"$percentile": bson.M{"input": "$power", "p": bson.A{0.25, 0.5, 1}, "method": "approximate"}}}},
+			},
+			"buckets": bson.A{
+				bson.M{"$bucket": bson.M{"groupBy": "$power", "boundaries": bson.A{5, 25}, "default": "other"}},
+			},
+		}}},
+	}
+	assert.NoError(t, repository.AggregateInMongo(ctx, "db", "readings", pipeline, &result))
+	assert.Len(t, result, 1)
+	assert.Equal(t, []float64{0, 10, 30}, result[0].Percentiles[0].Values)
+	assert.Len(t, result[0].Buckets, 2)
+	assert.EqualValues(t, 5, result[0].Buckets[0].ID)
+	assert.Equal(t, 2, result[0].Buckets[0].Count)
+	assert.Equal(t, "other", result[0].Buckets[1].ID)
+	assert.Equal(t, 2, result[0].Buckets[1].Count)
+
+	// Cursor of the resulting documents
+	cursor, err := repository.AggregateCursorInMongo(ctx, "db", "readings", mongo.Pipeline{{{Key: "$sort", Value: bson.D{{Key: "power", Value: -1}}}}}, 2)
+	assert.NoError(t, err)
+	var powers []float64
+	for cursor.Next(ctx) {
+		var r reading
+		assert.NoError(t, cursor.Decode(&r))
+		powers = append(powers, r.Power)
+	}
+	assert.NoError(t, cursor.Err())
+	assert.Equal(t, []float64{30, 20, 10, 0}, powers)
+
+	// Unknown stages and operators fail like on servers too old for them
+	err = repository.AggregateInMongo(ctx, "db", "readings", mongo.Pipeline{{{Key: "$densify", Value: bson.M{}}}}, &result)
+	assert.True(t, mongodb.IsUnsupportedPipeline(err))
+	err = repository.AggregateInMongo(ctx, "db", "readings", mongo.Pipeline{{{Key: "$group", Value: bson.M{"_id": nil, "x": bson.M{"$median": "$power"}}}}}, &result)
+	assert.True(t, mongodb.IsUnsupportedPipeline(err))
+	err = repository.AggregateInMongo(ctx, "db", "readings", mongo.Pipeline{{{Key: "$match", Value: bson.M{"sequence": bson.M{"$gte": "x", "$foo": 1}}}}}, &result)
+	assert.False(t, mongodb.IsUnsupportedPipeline(err))
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	mongodb "github.com/paulmuenzner/powerplantmanager/utils/mongoDB"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// session simulates a MongoDB session. Repository methods don't take a session, so a transaction snapshots the whole repository and aborting restores it.
+// Transactions of different sessions run one after another. Writes outside the transaction while it runs are rolled back by an abort, too.
+type session struct {
+	mongo.Session // Never set. Only provides the unexported method of mongo.Session
+	repository    *Repository
+	snapshot      map[string]map[string]*collection // State before the running transaction, nil without transaction
+	ended         bool
+}
+
+// StartSession returns a simulated session
+func (r *Repository) StartSession() (mongo.Session, error) {
+	return &session{repository: r}, nil
+}
+
+// snapshot copies the collections. Documents are shared as they are never changed in place. The caller must hold the lock
+func (r *Repository) snapshot() map[string]map[string]*collection {
+	databases := make(map[string]map[string]*collection, len(r.databases))
+	for databaseName, collections := range r.databases {
+		database := make(map[string]*collection, len(collections))
+		for collectionName, c := range collections {
+			database[collectionName] = &collection{
+				namespace:  c.namespace,
+				documents:  append([]bson.D{}, c.documents...),
+				indexes:    append([]*index{}, c.indexes...),
+				timeSeries: c.timeSeries,
+			}
+		}
+		databases[databaseName] = database
+	}
+	return databases
+}
+
+func (s *session) StartTransaction(...*options.TransactionOptions) error {
+	if s.ended {
+		return errors.New("ended session was used")
+	}
+	if s.snapshot != nil {
+		return errors.New("transaction already in progress")
+	}
+	s.repository.transaction.Lock()
+	s.repository.mutex.Lock()
+	defer s.repository.mutex.Unlock()
+
+	s.snapshot = s.repository.snapshot()
+	return nil
+}
+
+func (s *session) AbortTransaction(context.Context) error {
+	if s.snapshot == nil {
+		return errors.New("no transaction started")
+	}
+	s.repository.mutex.Lock()
+	s.repository.databases = s.snapshot
+	s.repository.mutex.Unlock()
+
+	s.snapshot = nil
+	s.repository.transaction.Unlock()
+	return nil
+}
+
+func (s *session) CommitTransaction(context.Context) error {
+	if s.snapshot == nil {
+		return errors.New("no transaction started")
+	}
+	s.snapshot = nil
+	s.repository.transaction.Unlock()
+	return nil
+}
+
+// WithTransaction runs fn in a transaction, committed if fn succeeds and aborted otherwise. Unlike MongoDB, fn is never retried
+func (s *session) WithTransaction(ctx context.Context, fn func(ctx mongo.SessionContext) (interface{}, error), opts ...*options.TransactionOptions) (interface{}, error) {
+	if err := s.StartTransaction(opts...); err != nil {
+		return nil, err
+	}
+	result, err := fn(mongo.NewSessionContext(ctx, s))
+	if err != nil {
+		s.AbortTransaction(ctx)
+		return nil, err
+	}
+	return result, s.CommitTransaction(ctx)
+}
+
+// RunTransaction runs fn in a simulated transaction, committed if fn returns nil and aborted otherwise. Unlike MongoDB, fn is never retried.
+// Operations MongoDB doesn't support within transactions fail on unitOfWork, so tests catch them
+func (r *Repository) RunTransaction(ctx context.Context, fn func(ctx context.Context, unitOfWork mongodb.Repository) error) error {
+	s, err := r.StartSession()
+	if err != nil {
+		return err
+	}
+	defer s.EndSession(context.Background())
+
+	_, err = s.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionContext, &unitOfWork{Repository: r})
+	})
+	return err
+}
+
+// unitOfWork is the repository within a simulated transaction
+type unitOfWork struct {
+	*Repository
+}
+
+func notSupportedInTransaction(command string) error {
+	return mongo.CommandError{Code: 263, Name: "OperationNotSupportedInTransaction", Message: fmt.Sprintf("Cannot run '%s' in a multi-document transaction.", command)}
+}
+
+func (u *unitOfWork) DeleteCollectionMongo(context.Context, string, string) error {
+	return notSupportedInTransaction("drop")
+}
+
+func (u *unitOfWork) CreateTimeSeriesCollection(context.Context, string, string, mongodb.TimeSeriesOptions) error {
+	return notSupportedInTransaction("create")
+}
+
+func (u *unitOfWork) SetTimeSeriesGranularity(context.Context, string, string, string) error {
+	return notSupportedInTransaction("collMod")
+}
+
+func (u *unitOfWork) ConvertToTimeSeriesCollection(context.Context, string, string, mongodb.TimeSeriesOptions, mongo.Pipeline) (int64, int64, error) {
+	return 0, 0, notSupportedInTransaction("aggregate")
+}
+
+func (u *unitOfWork) RunTransaction(context.Context, func(context.Context, mongodb.Repository) error) error {
+	return errors.New("transaction already in progress")
+}
+
+// EndSession aborts a running transaction and ends the session
+func (s *session) EndSession(ctx context.Context) {
+	if s.snapshot != nil {
+		s.AbortTransaction(ctx)
+	}
+	s.ended = true
+}
+
+func (s *session) ClusterTime() bson.Raw { return nil }
+
+func (s *session) OperationTime() *primitive.Timestamp { return nil }
+
+func (s *session) Client() *mongo.Client { return nil }
+
+func (s *session) ID() bson.Raw { return nil }
+
+func (s *session) AdvanceClusterTime(bson.Raw) error { return nil }
+
+func (s *session) AdvanceOperationTime(*primitive.Timestamp) error { return nil }
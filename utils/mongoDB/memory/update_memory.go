@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// applyUpdate returns a copy of document changed by update, a normalized update document of operators.
+// Supported are $set, $unset, $inc, $min, $max, $push, $addToSet, $pull, $currentDate and $setOnInsert, the latter ignored as there are no upserts.
+func applyUpdate(document bson.D, update bson.D, now time.Time) (bson.D, error) {
+	if len(update) == 0 {
+		return nil, fmt.Errorf("update document must not be empty")
+	}
+	result := deepCopy(document).(bson.D)
+	originalID, _ := get(document, "_id")
+	for _, operation := range update {
+		fields, ok := operation.Value.(bson.D)
+		if !strings.HasPrefix(operation.Key, "$") {
+			return nil, fmt.Errorf("update document requires atomic operators")
+		}
+		if !ok {
+			return nil, fmt.Errorf("Modifiers operate on fields but we found type %s instead", typeName(operation.Value))
+		}
+		for _, field := range fields {
+			var err error
+			result, err = applyOperator(result, operation.Key, field.Key, field.Value, now)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if id, _ := get(result, "_id"); !equal(id, originalID) {
+		return nil, fmt.Errorf("Performing an update on the path '_id' would modify the immutable field '_id'")
+	}
+	return result, nil
+}
+
+// applyOperator applies one update operator to the field at path
+func applyOperator(document bson.D, operator, path string, argument interface{}, now time.Time) (bson.D, error) {
+	current, exists := lookupOne(document, path)
+	switch operator {
+	case "$set":
+		return set(document, path, argument)
+	case "$unset":
+		return unset(document, path), nil
+	case "$setOnInsert":
+		return document, nil
+	case "$inc":
+		if !isNumber(argument) {
+			return nil, fmt.Errorf("Cannot increment with non-numeric argument: {%s: %v}", path, argument)
+		}
+		if !exists {
+			return set(document, path, argument)
+		}
+		if !isNumber(current) {
+			return nil, fmt.Errorf("Cannot apply $inc to a value of non-numeric type. Field '%s' has non-numeric type %s", path, typeName(current))
+		}
+		sum, err := add(current, argument)
+		if err != nil {
+			return nil, err
+		}
+		return set(document, path, sum)
+	case "$min", "$max":
+		result := compare(argument, current)
+		if !exists || (operator == "$min" && result < 0) || (operator == "$max" && result > 0) {
+			return set(document, path, argument)
+		}
+		return document, nil
+	case "$currentDate":
+		return set(document, path, primitive.NewDateTimeFromTime(now))
+	case "$push", "$addToSet":
+		array := bson.A{}
+		if exists {
+			existing, ok := current.(bson.A)
+			if !ok {
+				return nil, fmt.Errorf("The field '%s' must be an array but is of type %s", path, typeName(current))
+			}
+			array = existing
+		}
+		items := bson.A{argument}
+		if argumentDocument, ok := argument.(bson.D); ok {
+			if each, hasEach := get(argumentDocument, "$each"); hasEach {
+				eachArray, ok := each.(bson.A)
+				if !ok {
+					return nil, fmt.Errorf("The argument to $each in %s must be an array", operator)
+				}
+				items = eachArray
+			}
+		}
+		for _, item := range items {
+			if operator == "$addToSet" && containsEqual(array, item) {
+				continue
+			}
+			array = append(array, item)
+		}
+		return set(document, path, array)
+	case "$pull":
+		if !exists {
+			return document, nil
+		}
+		existing, ok := current.(bson.A)
+		if !ok {
+			return nil, fmt.Errorf("Cannot apply $pull to a non-array value")
+		}
+		array := bson.A{}
+		for _, element := range existing {
+			pulled, err := matchesPull(element, argument)
+			if err != nil {
+				return nil, err
+			}
+			if !pulled {
+				array = append(array, element)
+			}
+		}
+		return set(document, path, array)
+	}
+	return nil, fmt.Errorf("Unknown modifier: %s", operator)
+}
+
+// containsEqual reports whether array has an element equal to item
+func containsEqual(array bson.A, item interface{}) bool {
+	for _, element := range array {
+		if equal(element, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPull reports whether an array element is removed by the condition of $pull: a value, operators or a query on embedded documents
+func matchesPull(element interface{}, condition interface{}) (bool, error) {
+	conditionDocument, isDocument := condition.(bson.D)
+	if !isDocument {
+		return equal(element, condition), nil
+	}
+	if isOperatorDocument(conditionDocument) {
+		return matchOperators([]interface{}{element}, conditionDocument)
+	}
+	elementDocument, ok := element.(bson.D)
+	if !ok {
+		return false, nil
+	}
+	return matches(elementDocument, conditionDocument)
+}
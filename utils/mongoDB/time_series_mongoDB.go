@@ -0,0 +1,110 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TimeSeriesOptions of a time-series collection. Granularity is 'seconds', 'minutes' or 'hours'
+type TimeSeriesOptions struct {
+	TimeField   string
+	MetaField   string
+	Granularity string
+}
+
+// CreateTimeSeriesCollection creates a time-series collection. Documents must contain the time field
+func (client *Client) CreateTimeSeriesCollection(ctx context.Context, databaseName, collectionName string, timeSeries TimeSeriesOptions) error {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseAdminTimeoutSec)
+	defer cancel()
+
+	database := client.MongoDB.Database(databaseName)
+
+	timeSeriesOptions := options.TimeSeries().SetTimeField(timeSeries.TimeField).SetGranularity(timeSeries.Granularity)
+	if timeSeries.MetaField != "" {
+		timeSeriesOptions.SetMetaField(timeSeries.MetaField)
+	}
+	err := database.CreateCollection(ctx, collectionName, options.CreateCollection().SetTimeSeriesOptions(timeSeriesOptions))
+	if err != nil {
+		return fmt.Errorf("Error when creating time-series collection '%s' of database '%s' in 'CreateTimeSeriesCollection()' using 'CreateCollection()'. Error: %w", collectionName, databaseName, err)
+	}
+	return nil
+}
+
+// SetTimeSeriesGranularity changes the granularity of a time-series collection. MongoDB only permits coarser granularities
+func (client *Client) SetTimeSeriesGranularity(ctx context.Context, databaseName, collectionName, granularity string) error {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseAdminTimeoutSec)
+	defer cancel()
+
+	database := client.MongoDB.Database(databaseName)
+
+	command := bson.D{{Key: "collMod", Value: collectionName}, {Key: "timeseries", Value: bson.D{{Key: "granularity", Value: granularity}}}}
+	if err := database.RunCommand(ctx, command).Err(); err != nil {
+		return fmt.Errorf("Error when changing granularity of time-series collection '%s' of database '%s' in 'SetTimeSeriesGranularity()' using 'RunCommand()'. Error: %w", collectionName, databaseName, err)
+	}
+	return nil
+}
+
+// ConvertToTimeSeriesCollection converts a collection into a time-series collection of the same name. Time-series collections can't be renamed,
+// so the collection is renamed to '<collectionName>_premigration' and its documents are written to the new time-series collection, transformed by pipeline.
+// Documents without time field after pipeline are skipped. Returns the number of converted and skipped documents.
+// The renamed collection is dropped if no document has been skipped and kept for review otherwise. On failure, the collection is restored.
+// Writes to the collection during the conversion are lost, so it must only run while the server is stopped. There is no deadline, as large collections take long.
+func (client *Client) ConvertToTimeSeriesCollection(ctx context.Context, databaseName, collectionName string, timeSeries TimeSeriesOptions, pipeline mongo.Pipeline) (converted int64, skipped int64, err error) {
+	database := client.MongoDB.Database(databaseName)
+	backupName := collectionName + "_premigration"
+
+	rename := func(from, to string) error {
+		command := bson.D{{Key: "renameCollection", Value: databaseName + "." + from}, {Key: "to", Value: databaseName + "." + to}}
+		return client.MongoDB.Database("admin").RunCommand(ctx, command).Err()
+	}
+	if err := rename(collectionName, backupName); err != nil {
+		return 0, 0, fmt.Errorf("Error when renaming collection '%s' of database '%s' to '%s' in 'ConvertToTimeSeriesCollection()'. Error: %w", collectionName, databaseName, backupName, err)
+	}
+	restore := func(cause error) (int64, int64, error) {
+		if err := database.Collection(collectionName).Drop(ctx); err != nil {
+			return 0, 0, fmt.Errorf("Error in 'ConvertToTimeSeriesCollection()': %v. Cannot drop partially converted collection '%s', original documents are kept in '%s'. Error: %w", cause, collectionName, backupName, err)
+		}
+		if err := rename(backupName, collectionName); err != nil {
+			return 0, 0, fmt.Errorf("Error in 'ConvertToTimeSeriesCollection()': %v. Cannot restore collection '%s', original documents are kept in '%s'. Error: %w", cause, collectionName, backupName, err)
+		}
+		return 0, 0, cause
+	}
+
+	total, err := database.Collection(backupName).CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return restore(fmt.Errorf("cannot count documents of collection '%s': %v", backupName, err))
+	}
+
+	timeSeriesSpec := bson.D{{Key: "timeField", Value: timeSeries.TimeField}, {Key: "granularity", Value: timeSeries.Granularity}}
+	if timeSeries.MetaField != "" {
+		timeSeriesSpec = append(timeSeriesSpec, bson.E{Key: "metaField", Value: timeSeries.MetaField})
+	}
+	stages := append(mongo.Pipeline{}, pipeline...)
+	stages = append(stages,
+		bson.D{{Key: "$match", Value: bson.M{timeSeries.TimeField: bson.M{"$type": "date"}}}},
+		bson.D{{Key: "$out", Value: bson.D{{Key: "db", Value: databaseName}, {Key: "coll", Value: collectionName}, {Key: "timeseries", Value: timeSeriesSpec}}}},
+	)
+	cursor, err := database.Collection(backupName).Aggregate(ctx, stages)
+	if err != nil {
+		return restore(fmt.Errorf("cannot write documents of collection '%s' to time-series collection: %v", backupName, err))
+	}
+	cursor.Close(ctx)
+
+	converted, err = database.Collection(collectionName).CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return restore(fmt.Errorf("cannot count documents of time-series collection '%s': %v", collectionName, err))
+	}
+	skipped = total - converted
+	if skipped == 0 {
+		if err := database.Collection(backupName).Drop(ctx); err != nil {
+			return converted, skipped, fmt.Errorf("Error when dropping collection '%s' of database '%s' after conversion in 'ConvertToTimeSeriesCollection()'. Error: %w", backupName, databaseName, err)
+		}
+	}
+	return converted, skipped, nil
+}
@@ -0,0 +1,38 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RunTransaction runs fn in a transaction, committed if fn returns nil and aborted otherwise. fn may be retried on transient errors.
+// All operations of unitOfWork are bound to the transaction, whichever context they are given.
+// Collections can't be dropped and time-series collections can't be created by unitOfWork, MongoDB doesn't support it within transactions.
+func (client *Client) RunTransaction(ctx context.Context, fn func(ctx context.Context, unitOfWork Repository) error) error {
+	session, err := client.MongoDB.StartSession()
+	if err != nil {
+		return fmt.Errorf("Error in 'RunTransaction()' using 'StartSession()'. Error: %w", err)
+	}
+	defer session.EndSession(context.Background())
+
+	unitOfWork := &Client{MongoDB: client.MongoDB, session: session}
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionContext, unitOfWork)
+	})
+	if err != nil {
+		return fmt.Errorf("Error in 'RunTransaction()' using 'WithTransaction()'. Error: %w", err)
+	}
+	return nil
+}
+
+// operationContext returns the context of a single operation, ending with ctx or after timeoutSec at latest.
+// Operations of a unit of work are bound to its transaction
+func (client *Client) operationContext(ctx context.Context, timeoutSec int) (context.Context, context.CancelFunc) {
+	if client.session != nil {
+		ctx = mongo.NewSessionContext(ctx, client.session)
+	}
+	return context.WithTimeout(ctx, time.Duration(timeoutSec)*time.Second)
+}
@@ -0,0 +1,28 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (client *Client) UpdateManyInMongo(ctx context.Context, databaseName string, filter bson.M, update bson.M, collection string) (*mongo.UpdateResult, error) {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseWriteTimeoutSec)
+	defer cancel()
+
+	// Select the database and collection
+	db := client.MongoDB.Database(databaseName)
+	col := db.Collection(collection)
+
+	// Update all documents matching filter
+	result, err := col.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return nil, fmt.Errorf("Error when updating documents in collection '%s' of database '%s' in 'UpdateManyInMongo()' using 'UpdateMany()'. Error: %w", collection, databaseName, err)
+	}
+
+	return result, nil
+}
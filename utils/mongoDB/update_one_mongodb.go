@@ -4,11 +4,16 @@ import (
 	"context"
 	"fmt"
 
+	config "github.com/paulmuenzner/powerplantmanager/config"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func (client *Client) UpdateOneInMongo(databaseName string, filter bson.M, update bson.M, collection string) (*mongo.UpdateResult, error) {
+func (client *Client) UpdateOneInMongo(ctx context.Context, databaseName string, filter bson.M, update bson.M, collection string) (*mongo.UpdateResult, error) {
+	ctx, cancel := client.operationContext(ctx, config.DatabaseWriteTimeoutSec)
+	defer cancel()
+
 	var result *mongo.UpdateResult
 	// Create a session for the database
 	session, err := client.MongoDB.StartSession()
@@ -22,9 +27,9 @@ func (client *Client) UpdateOneInMongo(databaseName string, filter bson.M, updat
 	col := db.Collection(collection)
 
 	// Insert the data into the collection
-	result, errUpdate := col.UpdateOne(context.TODO(), filter, update)
+	result, errUpdate := col.UpdateOne(ctx, filter, update)
 	if errUpdate != nil {
-		return nil, fmt.Errorf("Error when updating documents in collection '%s' of database '%s' in 'UpdateOneInMongo()' using 'UpdateOne()'. Error: %v", collection, databaseName, err)
+		return nil, fmt.Errorf("Error when updating documents in collection '%s' of database '%s' in 'UpdateOneInMongo()' using 'UpdateOne()'. Error: %w", collection, databaseName, err)
 	}
 
 	// Return the ID of the inserted document
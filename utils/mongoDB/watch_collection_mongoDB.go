@@ -0,0 +1,17 @@
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// WatchCollection opens a change stream on collection. Change streams require a replica set or sharded cluster.
+// The caller reads events with Next and closes the stream. The stream ends with ctx, there is no deadline.
+func (client *Client) WatchCollection(ctx context.Context, databaseName string, collection string) (*mongo.ChangeStream, error) {
+	// Select the database and collection
+	db := client.MongoDB.Database(databaseName)
+	col := db.Collection(collection)
+
+	return col.Watch(ctx, mongo.Pipeline{})
+}
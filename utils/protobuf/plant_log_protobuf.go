@@ -0,0 +1,156 @@
+package protobuf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// Field numbers of message PlantLog, see plant_log.proto
+const (
+	fieldKey            = 1
+	fieldSecret         = 2
+	fieldMeasuredAt     = 11
+	fieldSequence       = 12
+	fieldIdempotencyKey = 13
+	fieldDeviceID       = 14
+	fieldChannels       = 15
+	fieldReadings       = 16
+)
+
+// Keys of string fields by field number
+var stringFields = map[uint64]string{
+	fieldKey:            "key",
+	fieldSecret:         "secret",
+	fieldIdempotencyKey: "idempotencyKey",
+	fieldDeviceID:       "deviceID",
+}
+
+// Measurements of the default channels by field number
+var measurementFields = map[uint64]string{
+	3:  "voltageOutput",
+	4:  "currentOutput",
+	5:  "powerOutput",
+	6:  "solarRadiation",
+	7:  "tAmbient",
+	8:  "tModule",
+	9:  "relHumidity",
+	10: "windSpeed",
+}
+
+// DecodePlantLog decodes message PlantLog of plant_log.proto into the keys of the JSON request body of plant log routes, so it passes the same validation.
+// Numbers become float64, measured_at a RFC3339 string and channels top-level keys. Readings of a batch are decoded into a list of maps.
+// Unknown fields are skipped. Absent fields are absent in the result, as in JSON.
+func DecodePlantLog(data []byte) (map[string]interface{}, error) {
+	return decodePlantLog(data, true)
+}
+
+func decodePlantLog(data []byte, allowReadings bool) (map[string]interface{}, error) {
+	fields, err := readFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	plantLog := map[string]interface{}{}
+	channels := map[string]float64{}
+	for _, current := range fields {
+		if name, isMeasurement := measurementFields[current.number]; isMeasurement {
+			if current.wireType != wireFixed64 {
+				return nil, wireTypeError(current, "double")
+			}
+			plantLog[name] = math.Float64frombits(current.value)
+			continue
+		}
+
+		switch current.number {
+		case fieldKey, fieldSecret, fieldIdempotencyKey, fieldDeviceID:
+			text, err := readString(current)
+			if err != nil {
+				return nil, err
+			}
+			plantLog[stringFields[current.number]] = text
+		case fieldMeasuredAt:
+			if current.wireType != wireVarint {
+				return nil, wireTypeError(current, "int64")
+			}
+			plantLog["measuredAt"] = time.UnixMilli(int64(current.value)).UTC().Format(time.RFC3339Nano)
+		case fieldSequence:
+			if current.wireType != wireVarint {
+				return nil, wireTypeError(current, "uint64")
+			}
+			plantLog["sequence"] = float64(current.value)
+		case fieldChannels:
+			name, value, err := readChannel(current)
+			if err != nil {
+				return nil, err
+			}
+			channels[name] = value
+		case fieldReadings:
+			if !allowReadings {
+				return nil, errors.New("protobuf: readings must not contain readings")
+			}
+			if current.wireType != wireBytes {
+				return nil, wireTypeError(current, "PlantLog")
+			}
+			reading, err := decodePlantLog(current.bytes, false)
+			if err != nil {
+				return nil, err
+			}
+			readings, _ := plantLog["readings"].([]interface{})
+			plantLog["readings"] = append(readings, reading)
+		}
+	}
+
+	for name, value := range channels {
+		if _, exists := plantLog[name]; exists {
+			return nil, fmt.Errorf("protobuf: channel '%s' is set twice", name)
+		}
+		plantLog[name] = value
+	}
+	return plantLog, nil
+}
+
+// readChannel reads an entry of map<string, double> channels
+func readChannel(current field) (string, float64, error) {
+	if current.wireType != wireBytes {
+		return "", 0, wireTypeError(current, "map entry")
+	}
+	fields, err := readFields(current.bytes)
+	if err != nil {
+		return "", 0, err
+	}
+	name, value := "", 0.0
+	for _, entryField := range fields {
+		switch entryField.number {
+		case 1:
+			if name, err = readString(entryField); err != nil {
+				return "", 0, err
+			}
+		case 2:
+			if entryField.wireType != wireFixed64 {
+				return "", 0, wireTypeError(entryField, "double")
+			}
+			value = math.Float64frombits(entryField.value)
+		}
+	}
+	if name == "" {
+		return "", 0, errors.New("protobuf: channel without name")
+	}
+	return name, value, nil
+}
+
+func readString(current field) (string, error) {
+	if current.wireType != wireBytes {
+		return "", wireTypeError(current, "string")
+	}
+	if !utf8.Valid(current.bytes) {
+		return "", fmt.Errorf("protobuf: field %d is not valid UTF-8", current.number)
+	}
+	return string(current.bytes), nil
+}
+
+func wireTypeError(current field, expected string) error {
+	return fmt.Errorf("protobuf: field %d must be of type %s, wire type is %d", current.number, expected, current.wireType)
+}
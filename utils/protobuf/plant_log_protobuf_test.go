@@ -0,0 +1,91 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Encoding helpers, as produced by protoc generated code
+func appendTag(data []byte, number, wireType uint64) []byte {
+	return binary.AppendUvarint(data, number<<3|wireType)
+}
+
+func appendString(data []byte, number uint64, value string) []byte {
+	data = binary.AppendUvarint(appendTag(data, number, wireBytes), uint64(len(value)))
+	return append(data, value...)
+}
+
+func appendDouble(data []byte, number uint64, value float64) []byte {
+	return binary.LittleEndian.AppendUint64(appendTag(data, number, wireFixed64), math.Float64bits(value))
+}
+
+func appendVarint(data []byte, number uint64, value uint64) []byte {
+	return binary.AppendUvarint(appendTag(data, number, wireVarint), value)
+}
+
+func appendMessage(data []byte, number uint64, message []byte) []byte {
+	data = binary.AppendUvarint(appendTag(data, number, wireBytes), uint64(len(message)))
+	return append(data, message...)
+}
+
+func TestDecodePlantLog(t *testing.T) {
+	message := appendString(nil, 1, "plantKey")
+	message = appendString(message, 2, "plantSecret")
+	message = appendDouble(message, 5, 4312.5)
+	message = appendDouble(message, 6, 0)
+	message = appendVarint(message, 11, 1710061200500)
+	message = appendVarint(message, 12, 42)
+	message = appendString(message, 14, "inverter-1")
+	message = appendMessage(message, 15, appendDouble(appendString(nil, 1, "stringCurrent3"), 2, 8.25))
+	message = appendVarint(message, 99, 7) // Unknown field
+
+	plantLog, err := DecodePlantLog(message)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"key":            "plantKey",
+		"secret":         "plantSecret",
+		"powerOutput":    4312.5,
+		"solarRadiation": float64(0),
+		"measuredAt":     "2024-03-10T09:00:00.5Z",
+		"sequence":       float64(42),
+		"deviceID":       "inverter-1",
+		"stringCurrent3": 8.25,
+	}, plantLog)
+}
+
+func TestDecodePlantLogBatch(t *testing.T) {
+	first := appendVarint(appendDouble(nil, 5, 100), 11, 1710061200000)
+	second := appendString(appendDouble(nil, 5, 110), 13, "retry-2")
+	message := appendString(nil, 1, "plantKey")
+	message = appendMessage(message, 16, first)
+	message = appendMessage(message, 16, second)
+
+	plantLog, err := DecodePlantLog(message)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"powerOutput": float64(100), "measuredAt": "2024-03-10T09:00:00Z"},
+		map[string]interface{}{"powerOutput": float64(110), "idempotencyKey": "retry-2"},
+	}, plantLog["readings"])
+
+	// Readings aren't nested further
+	_, err = DecodePlantLog(appendMessage(nil, 16, appendMessage(nil, 16, first)))
+	assert.Error(t, err)
+}
+
+func TestDecodePlantLogInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"truncated":           appendString(nil, 1, "plantKey")[:5],
+		"length exceeds data": {0x0a, 0x10, 'a'},
+		"wrong wire type":     appendVarint(nil, 5, 100),
+		"invalid UTF-8":       appendString(nil, 1, "\xc3\x28"),
+		"channel set twice":   appendMessage(appendDouble(nil, 5, 100), 15, appendDouble(appendString(nil, 1, "powerOutput"), 2, 100)),
+		"field number 0":      {0x00, 0x00},
+	}
+	for name, message := range tests {
+		_, err := DecodePlantLog(message)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,77 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Wire types of the protobuf encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var ErrUnexpectedEnd = errors.New("protobuf: unexpected end of data")
+
+// field is a field of a protobuf message. Value holds varints and fixed numbers, bytes holds length-delimited content
+type field struct {
+	number   uint64
+	wireType uint64
+	value    uint64
+	bytes    []byte
+}
+
+// readFields splits a protobuf message into its fields. Groups (deprecated) aren't supported
+func readFields(data []byte) ([]field, error) {
+	fields := []field{}
+	for offset := 0; offset < len(data); {
+		tag, size := binary.Uvarint(data[offset:])
+		if size <= 0 {
+			return nil, ErrUnexpectedEnd
+		}
+		offset += size
+		current := field{number: tag >> 3, wireType: tag & 7}
+		if current.number == 0 {
+			return nil, errors.New("protobuf: invalid field number 0")
+		}
+
+		switch current.wireType {
+		case wireVarint:
+			current.value, size = binary.Uvarint(data[offset:])
+			if size <= 0 {
+				return nil, ErrUnexpectedEnd
+			}
+			offset += size
+		case wireFixed64:
+			if len(data)-offset < 8 {
+				return nil, ErrUnexpectedEnd
+			}
+			current.value = binary.LittleEndian.Uint64(data[offset:])
+			offset += 8
+		case wireFixed32:
+			if len(data)-offset < 4 {
+				return nil, ErrUnexpectedEnd
+			}
+			current.value = uint64(binary.LittleEndian.Uint32(data[offset:]))
+			offset += 4
+		case wireBytes:
+			length, size := binary.Uvarint(data[offset:])
+			if size <= 0 {
+				return nil, ErrUnexpectedEnd
+			}
+			offset += size
+			if length > uint64(len(data)-offset) {
+				return nil, ErrUnexpectedEnd
+			}
+			current.bytes = data[offset : offset+int(length)]
+			offset += int(length)
+		default:
+			return nil, fmt.Errorf("protobuf: unsupported wire type %d of field %d", current.wireType, current.number)
+		}
+		fields = append(fields, current)
+	}
+	return fields, nil
+}
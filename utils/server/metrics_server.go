@@ -0,0 +1,23 @@
+package server
+
+import (
+	"expvar"
+	"net/http"
+	"time"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+)
+
+// NewMetricsServer returns a server publishing the expvar metrics, eg. 'loggerConfigCache', as JSON on address.
+// It's separate from the API server, so metrics can be restricted to an internal address.
+func NewMetricsServer(address string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	return &http.Server{
+		Addr:         address,
+		Handler:      mux,
+		WriteTimeout: time.Second * time.Duration(config.WriteTimeout),
+		ReadTimeout:  time.Second * time.Duration(config.ReadTimeout),
+		IdleTimeout:  time.Second * time.Duration(config.IdleTimeout),
+	}
+}
@@ -1,39 +1,145 @@
 package server
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strings"
 
 	config "github.com/paulmuenzner/powerplantmanager/config"
+	"github.com/paulmuenzner/powerplantmanager/utils/cbor"
 	cookie "github.com/paulmuenzner/powerplantmanager/utils/cookies"
+	"github.com/paulmuenzner/powerplantmanager/utils/protobuf"
 )
 
+type bodyDecoder func(body []byte) (map[string]interface{}, error)
+
+// Body decoders by media type. Each returns the request body in the form of encoding/json, so all encodings pass the same validation
+var bodyDecoders = map[string]bodyDecoder{
+	"application/json": func(body []byte) (map[string]interface{}, error) {
+		var data map[string]interface{}
+		err := json.Unmarshal(body, &data)
+		return data, err
+	},
+	"application/cbor": func(body []byte) (map[string]interface{}, error) {
+		value, err := cbor.Decode(body)
+		if err != nil {
+			return nil, err
+		}
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("cbor: top-level item must be a map")
+		}
+		return data, nil
+	},
+}
+
+// Body decoders of plant logs by media type, see utils/protobuf/plant_log.proto. Used for logging routes only, other routes answer these media types with 415
+var plantLogBodyDecoders = map[string]bodyDecoder{
+	"application/x-protobuf": protobuf.DecodePlantLog,
+	"application/protobuf":   protobuf.DecodePlantLog,
+}
+
+// isLoggingRoute reports if a request is for a logging route, a single plant log or a batch
+func isLoggingRoute(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/plants/log/")
+}
+
+var errBodyTooLarge = errors.New("request body too large")
+
 // ParserBodyRequest is a middleware for parsing the request body globally
+// Supports JSON, CBOR and, for logging routes, protobuf bodies by Content-Type, optionally gzip-compressed (Content-Encoding 'gzip').
+// Protobuf bodies of other routes are answered with 415. Other content types are left to the handler, eg. multipart imports.
 func BodyRequestParser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Check content type
-		contentType := r.Header.Get("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		decodeBody, supported := bodyDecoders[mediaType]
+		if plantLogDecoder, isPlantLog := plantLogBodyDecoders[mediaType]; isPlantLog {
+			if !isLoggingRoute(r) {
+				http.Error(w, fmt.Sprintf("Unsupported Content-Type '%s'. Protobuf is accepted for plant logs only.", mediaType), http.StatusUnsupportedMediaType)
+				return
+			}
+			decodeBody, supported = plantLogDecoder, true
+		}
+		if !supported {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Keep the raw request body as sent. Needed to verify signed requests (body hash)
+		rawBody, err := io.ReadAll(http.MaxBytesReader(w, r.Body, config.RequestBodyMaxBytes))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				http.Error(w, fmt.Sprintf("Request body exceeds %d bytes.", config.RequestBodyMaxBytes), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Cannot read request body.", http.StatusBadRequest)
+			return
+		}
 
-		if strings.Contains(contentType, "application/json") {
-			// Parse the request body as JSON
-			var data map[string]interface{}
-			err := json.NewDecoder(r.Body).Decode(&data)
+		body := rawBody
+		switch contentEncoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); contentEncoding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			body, err = decompressGzip(rawBody, config.RequestBodyMaxDecompressedBytes)
+			if errors.Is(err, errBodyTooLarge) {
+				http.Error(w, fmt.Sprintf("Decompressed request body exceeds %d bytes.", config.RequestBodyMaxDecompressedBytes), http.StatusRequestEntityTooLarge)
+				return
+			}
 			if err != nil {
-				http.Error(w, "Invalid JSON in request body.", http.StatusBadRequest)
+				http.Error(w, "Invalid gzip data in request body.", http.StatusBadRequest)
 				return
 			}
+		default:
+			http.Error(w, "Unsupported Content-Encoding. Supported are 'gzip' and 'identity'.", http.StatusUnsupportedMediaType)
+			return
+		}
 
-			// Attach JSON data to request context
-			r = r.WithContext(context.WithValue(r.Context(), "requestBody", data))
+		// Parse the request body
+		data, err := decodeBody(body)
+		if err != nil || data == nil {
+			http.Error(w, fmt.Sprintf("Invalid %s in request body.", mediaType), http.StatusBadRequest)
+			return
 		}
 
+		// Attach raw body and parsed data to request context
+		r = r.WithContext(context.WithValue(r.Context(), "requestBodyRaw", rawBody))
+		r = r.WithContext(context.WithValue(r.Context(), "requestBody", data))
+
 		// Call the next handler
 		next.ServeHTTP(w, r)
 	})
 }
 
+// decompressGzip decompresses gzip data up to maxBytes. Returns errBodyTooLarge for more
+func decompressGzip(compressed []byte, maxBytes int64) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	body, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, errBodyTooLarge
+	}
+	return body, nil
+}
+
 // CookieParser is a middleware that reads the user data from the cookie
 // and attaches it to the request context for use in controllers
 func CookieParser(next http.Handler) http.Handler {
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	config "github.com/paulmuenzner/powerplantmanager/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// parseBody sends body of a plant log through BodyRequestParser. Returns the response status and the parsed request body
+func parseBody(body []byte, contentType, contentEncoding string) (int, map[string]interface{}) {
+	return parseBodyAt("/plants/log/1", body, contentType, contentEncoding)
+}
+
+// parseBodyAt sends body to path through BodyRequestParser
+func parseBodyAt(path string, body []byte, contentType, contentEncoding string) (int, map[string]interface{}) {
+	var parsed map[string]interface{}
+	handler := BodyRequestParser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parsed, _ = r.Context().Value("requestBody").(map[string]interface{})
+	}))
+	request := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("Content-Encoding", contentEncoding)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Code, parsed
+}
+
+func compress(t *testing.T, body []byte) []byte {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err := writer.Write(body)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+	return compressed.Bytes()
+}
+
+func TestBodyRequestParserEncodings(t *testing.T) {
+	expected := map[string]interface{}{"key": "plantKey", "powerOutput": 4312.5}
+	json := []byte(`{"key":"plantKey","powerOutput":4312.5}`)
+	// {"key": "plantKey", "powerOutput": 4312.5}
+	cbor := []byte{0xa2, 0x63, 'k', 'e', 'y', 0x68, 'p', 'l', 'a', 'n', 't', 'K', 'e', 'y',
+		0x6b, 'p', 'o', 'w', 'e', 'r', 'O', 'u', 't', 'p', 'u', 't', 0xfb, 0x40, 0xb0, 0xd8, 0x80, 0, 0, 0, 0}
+	// key = 1, power_output = 5
+	protobuf := []byte{0x0a, 0x08, 'p', 'l', 'a', 'n', 't', 'K', 'e', 'y', 0x29, 0, 0, 0, 0, 0x80, 0xd8, 0xb0, 0x40}
+
+	tests := []struct {
+		body            []byte
+		contentType     string
+		contentEncoding string
+	}{
+		{json, "application/json", ""},
+		{json, "application/json; charset=utf-8", "identity"},
+		{compress(t, json), "application/json", "gzip"},
+		{cbor, "application/cbor", ""},
+		{compress(t, cbor), "application/cbor", "gzip"},
+		{protobuf, "application/x-protobuf", ""},
+		{compress(t, protobuf), "application/protobuf", "gzip"},
+	}
+	for _, test := range tests {
+		status, parsed := parseBody(test.body, test.contentType, test.contentEncoding)
+		assert.Equal(t, http.StatusOK, status, test.contentType)
+		assert.Equal(t, expected, parsed, test.contentType)
+	}
+}
+
+func TestBodyRequestParserRejects(t *testing.T) {
+	status, _ := parseBody([]byte(`{"key":`), "application/json", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = parseBody([]byte(`{}`), "application/json", "br")
+	assert.Equal(t, http.StatusUnsupportedMediaType, status)
+
+	status, _ = parseBody([]byte(`{}`), "application/json", "gzip")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = parseBody(make([]byte, config.RequestBodyMaxBytes+1), "application/json", "")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+
+	// Zip bomb. Few kilobytes compressed, more than the limit decompressed
+	bomb := compress(t, bytes.Repeat([]byte(" "), int(config.RequestBodyMaxDecompressedBytes)+1))
+	assert.Less(t, int64(len(bomb)), config.RequestBodyMaxBytes)
+	status, _ = parseBody(bomb, "application/json", "gzip")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+
+	// Protobuf bodies are plant logs, accepted by logging routes only
+	protobuf := []byte{0x0a, 0x08, 'p', 'l', 'a', 'n', 't', 'K', 'e', 'y'}
+	status, _ = parseBodyAt("/plants/log/1/batch", protobuf, "application/x-protobuf", "")
+	assert.Equal(t, http.StatusOK, status)
+	for _, path := range []string{"/plants/add", "/auth/signin", "/plants/logs"} {
+		status, _ = parseBodyAt(path, protobuf, "application/x-protobuf", "")
+		assert.Equal(t, http.StatusUnsupportedMediaType, status, path)
+		status, _ = parseBodyAt(path, protobuf, "application/protobuf", "")
+		assert.Equal(t, http.StatusUnsupportedMediaType, status, path)
+	}
+
+	// Other content types are left to the handler
+	status, parsed := parseBody([]byte("--boundary"), "multipart/form-data; boundary=boundary", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Nil(t, parsed)
+}
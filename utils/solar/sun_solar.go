@@ -0,0 +1,68 @@
+package solar
+
+import (
+	"math"
+	"time"
+)
+
+// Daylight of a day at a location
+type Daylight int
+
+const (
+	DaylightNormal     Daylight = iota // Sun rises and sets
+	DaylightPolarDay                   // Sun doesn't set
+	DaylightPolarNight                 // Sun doesn't rise
+)
+
+// Solar zenith angle at sunrise and sunset in degrees, including refraction and the radius of the sun
+const zenithSunrise = 90.833
+
+// SunriseSunset returns sunrise and sunset (UTC) on the day of date (UTC date) at latitude and longitude in degrees, using the NOAA solar equations.
+// Accurate to a few minutes between the polar circles. Sunrise and sunset are zero for polar day and night.
+// East of Greenwich sunrise may lie on the previous UTC day, west of it sunset may lie on the next UTC day.
+func SunriseSunset(date time.Time, latitude, longitude float64) (time.Time, time.Time, Daylight) {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Solar noon and position of the sun are computed at approximate solar noon of the location
+	noonMinutes := 720 - 4*longitude
+	declination, equationOfTime := sunPosition(day.Add(time.Duration(noonMinutes * float64(time.Minute))))
+	noonMinutes -= equationOfTime
+
+	latitudeRad := radians(latitude)
+	cosHourAngle := (math.Cos(radians(zenithSunrise)) - math.Sin(latitudeRad)*math.Sin(declination)) / (math.Cos(latitudeRad) * math.Cos(declination))
+	switch {
+	case cosHourAngle < -1:
+		return time.Time{}, time.Time{}, DaylightPolarDay
+	case cosHourAngle > 1:
+		return time.Time{}, time.Time{}, DaylightPolarNight
+	}
+	hourAngleMinutes := 4 * degrees(math.Acos(cosHourAngle))
+
+	sunrise := day.Add(time.Duration((noonMinutes - hourAngleMinutes) * float64(time.Minute))).Round(time.Second)
+	sunset := day.Add(time.Duration((noonMinutes + hourAngleMinutes) * float64(time.Minute))).Round(time.Second)
+	return sunrise, sunset, DaylightNormal
+}
+
+// sunPosition returns declination of the sun in radians and the equation of time in minutes at t
+func sunPosition(t time.Time) (float64, float64) {
+	fractionalYear := 2 * math.Pi / float64(daysInYear(t.Year())) * (float64(t.YearDay()-1) + (float64(t.Hour())-12)/24)
+
+	equationOfTime := 229.18 * (0.000075 + 0.001868*math.Cos(fractionalYear) - 0.032077*math.Sin(fractionalYear) -
+		0.014615*math.Cos(2*fractionalYear) - 0.040849*math.Sin(2*fractionalYear))
+	declination := 0.006918 - 0.399912*math.Cos(fractionalYear) + 0.070257*math.Sin(fractionalYear) -
+		0.006758*math.Cos(2*fractionalYear) + 0.000907*math.Sin(2*fractionalYear) -
+		0.002697*math.Cos(3*fractionalYear) + 0.00148*math.Sin(3*fractionalYear)
+	return declination, equationOfTime
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
@@ -0,0 +1,40 @@
+package solar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSunriseSunset(t *testing.T) {
+	// Reference times of the NOAA solar calculator, UTC
+	tests := []struct {
+		name                string
+		date                time.Time
+		latitude, longitude float64
+		sunrise, sunset     time.Time
+	}{
+		{"Berlin summer solstice", time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), 52.52, 13.405,
+			time.Date(2024, 6, 21, 2, 43, 0, 0, time.UTC), time.Date(2024, 6, 21, 19, 33, 0, 0, time.UTC)},
+		{"Berlin winter solstice", time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), 52.52, 13.405,
+			time.Date(2024, 12, 21, 7, 15, 0, 0, time.UTC), time.Date(2024, 12, 21, 14, 54, 0, 0, time.UTC)},
+		{"Phoenix", time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), 33.45, -112.07,
+			time.Date(2024, 3, 10, 13, 43, 0, 0, time.UTC), time.Date(2024, 3, 11, 1, 31, 0, 0, time.UTC)},
+		{"Sydney", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), -33.87, 151.21,
+			time.Date(2024, 1, 14, 19, 0, 0, 0, time.UTC), time.Date(2024, 1, 15, 9, 10, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		sunrise, sunset, daylight := SunriseSunset(test.date, test.latitude, test.longitude)
+		assert.Equal(t, DaylightNormal, daylight, test.name)
+		assert.WithinDuration(t, test.sunrise, sunrise, 3*time.Minute, test.name)
+		assert.WithinDuration(t, test.sunset, sunset, 3*time.Minute, test.name)
+	}
+}
+
+func TestSunriseSunsetPolar(t *testing.T) {
+	_, _, daylight := SunriseSunset(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), 78.22, 15.65) // Longyearbyen
+	assert.Equal(t, DaylightPolarDay, daylight)
+	_, _, daylight = SunriseSunset(time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), 78.22, 15.65)
+	assert.Equal(t, DaylightPolarNight, daylight)
+}
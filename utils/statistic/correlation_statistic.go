@@ -6,14 +6,18 @@ import (
 	"gonum.org/v1/gonum/stat"
 )
 
-func Correlation(x, y []float64) (float64, error) {
+func Correlation(x, y []float64, weights []float64) (float64, error) {
 	// Ensure both slices have the same length
 	if len(x) != len(y) {
 		return 0, fmt.Errorf("error in 'Correlation()'. slice of weights and data must have same lengths. slice length x: %d. slice length y: %d", len(x), len(y))
 	}
 
+	if weights != nil && len(weights) != len(x) {
+		return 0, fmt.Errorf("error in 'Correlation()'. slice of weights and data must have same lengths. slice length weights: %d. slice length x: %d", len(weights), len(x))
+	}
+
 	// Calculate the correlation coefficient
-	corr := stat.Correlation(x, y, nil)
+	corr := stat.Correlation(x, y, weights)
 
 	return corr, nil
 }
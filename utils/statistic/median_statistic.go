@@ -3,7 +3,6 @@ package statistic
 import (
 	"fmt"
 	typepackage "github.com/paulmuenzner/powerplantmanager/utils/type"
-	"sort"
 
 	"gonum.org/v1/gonum/stat"
 )
@@ -14,9 +13,6 @@ func Median(data []float64, p float64, weights []float64) (float64, error) {
 		return 0, fmt.Errorf("error in 'Median()'. p must be between 0 and 1. p: %f", p)
 	}
 
-	// Sort the data
-	sort.Float64s(data)
-
 	// Check if the slice is not empty
 	isSliceEmpty := typepackage.IsSliceEmpty[float64](data)
 	if isSliceEmpty {
@@ -28,8 +24,11 @@ func Median(data []float64, p float64, weights []float64) (float64, error) {
 		}
 	}
 
+	// Sort the data along with their weights
+	stat.SortWeighted(data, weights)
+
 	// Calculate the median using Quantile
-	median := stat.Quantile(p, stat.Empirical, data, nil)
+	median := stat.Quantile(p, stat.Empirical, data, weights)
 
 	return median, nil
 }
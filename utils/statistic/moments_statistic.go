@@ -0,0 +1,128 @@
+package statistic
+
+import (
+	"math"
+)
+
+// Moments of a set of values: count, mean and sums of squared and cubed deviations from the mean.
+// Moments of several sets merge (see Add), so mean, variance and skewness of pre-aggregated values (eg. hourly rollups) are exact.
+// Deviations are centered on the mean, so values with a large offset and small spread (eg. grid voltage) don't cancel out.
+type Moments struct {
+	Count float64
+	Mean  float64
+	M2    float64 // Sum of squared deviations from the mean
+	M3    float64 // Sum of cubed deviations from the mean
+}
+
+// CenteredMoments returns the moments of count values with mean from the sums of their deviations from an approximate mean (first, squared, cubed).
+// The deviations of the approximate mean, eg. due to rounding, are corrected
+func CenteredMoments(count, mean, sumDeviations, sumSquaredDeviations, sumCubedDeviations float64) Moments {
+	if count == 0 {
+		return Moments{}
+	}
+	shift := sumDeviations / count
+	return Moments{
+		Count: count,
+		Mean:  mean + shift,
+		M2:    sumSquaredDeviations - count*shift*shift,
+		M3:    sumCubedDeviations - 3*shift*sumSquaredDeviations + 2*count*shift*shift*shift,
+	}
+}
+
+// AddValue adds one value
+func (moments *Moments) AddValue(value float64) {
+	moments.Add(Moments{Count: 1, Mean: value})
+}
+
+// Add merges the moments of another set of values (Chan et al.)
+func (moments *Moments) Add(other Moments) {
+	if other.Count == 0 {
+		return
+	}
+	if moments.Count == 0 {
+		*moments = other
+		return
+	}
+	count := moments.Count + other.Count
+	delta := other.Mean - moments.Mean
+	m2 := moments.M2 + other.M2 + delta*delta*moments.Count*other.Count/count
+	m3 := moments.M3 + other.M3 + delta*delta*delta*moments.Count*other.Count*(moments.Count-other.Count)/(count*count) +
+		3*delta*(moments.Count*other.M2-other.Count*moments.M2)/count
+	moments.Mean += delta * other.Count / count
+	moments.Count, moments.M2, moments.M3 = count, m2, m3
+}
+
+// Variance returns the sample variance of the values, as Variance()
+func (moments Moments) Variance() float64 {
+	return moments.M2 / (moments.Count - 1)
+}
+
+// StandardDeviation returns the sample standard deviation of the values, the square root of Variance()
+func (moments Moments) StandardDeviation() float64 {
+	return math.Sqrt(moments.Variance())
+}
+
+// Skewness returns the sample skewness of the values, as Skewness()
+func (moments Moments) Skewness() float64 {
+	count := moments.Count
+	standardDeviation := moments.StandardDeviation()
+	return moments.M3 / (standardDeviation * standardDeviation * standardDeviation) * count / ((count - 1) * (count - 2))
+}
+
+// CoMoments of a set of pairs of values x and y: count, means and sums of squared deviations and of products of deviations from the means.
+// Like Moments, co-moments of several sets merge (see Add) and are centered on the means, so the correlation of values with a large offset is exact.
+type CoMoments struct {
+	Count float64
+	MeanX float64
+	MeanY float64
+	M2X   float64 // Sum of squared deviations of x from its mean
+	M2Y   float64 // Sum of squared deviations of y from its mean
+	CXY   float64 // Sum of products of the deviations of x and y from their means
+}
+
+// CenteredCoMoments returns the co-moments of count pairs with means meanX and meanY from the sums of their deviations from approximate means
+// (first and squared of x and y, products of both). The deviations of the approximate means are corrected, as by CenteredMoments
+func CenteredCoMoments(count, meanX, meanY, sumDeviationsX, sumDeviationsY, sumSquaredDeviationsX, sumSquaredDeviationsY, sumProductDeviations float64) CoMoments {
+	if count == 0 {
+		return CoMoments{}
+	}
+	shiftX, shiftY := sumDeviationsX/count, sumDeviationsY/count
+	return CoMoments{
+		Count: count,
+		MeanX: meanX + shiftX,
+		MeanY: meanY + shiftY,
+		M2X:   sumSquaredDeviationsX - count*shiftX*shiftX,
+		M2Y:   sumSquaredDeviationsY - count*shiftY*shiftY,
+		CXY:   sumProductDeviations - count*shiftX*shiftY,
+	}
+}
+
+// AddPair adds one pair of values
+func (coMoments *CoMoments) AddPair(x, y float64) {
+	coMoments.Add(CoMoments{Count: 1, MeanX: x, MeanY: y})
+}
+
+// Add merges the co-moments of another set of pairs (Chan et al.)
+func (coMoments *CoMoments) Add(other CoMoments) {
+	if other.Count == 0 {
+		return
+	}
+	if coMoments.Count == 0 {
+		*coMoments = other
+		return
+	}
+	count := coMoments.Count + other.Count
+	deltaX, deltaY := other.MeanX-coMoments.MeanX, other.MeanY-coMoments.MeanY
+	weight := coMoments.Count * other.Count / count
+	coMoments.M2X += other.M2X + deltaX*deltaX*weight
+	coMoments.M2Y += other.M2Y + deltaY*deltaY*weight
+	coMoments.CXY += other.CXY + deltaX*deltaY*weight
+	coMoments.MeanX += deltaX * other.Count / count
+	coMoments.MeanY += deltaY * other.Count / count
+	coMoments.Count = count
+}
+
+// Correlation returns the Pearson correlation of x and y, as Correlation(). NaN without pairs or if x or y is constant
+func (coMoments CoMoments) Correlation() float64 {
+	return coMoments.CXY / math.Sqrt(coMoments.M2X*coMoments.M2Y)
+}
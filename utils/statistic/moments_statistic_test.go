@@ -0,0 +1,117 @@
+package statistic
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMomentsMatchValues(t *testing.T) {
+	values := []float64{0, 12.5, 230, 815.25, 1020, 990.5, 640, 80, 3, 0}
+
+	// Moments of two sets of values add up to the moments of all values
+	var moments, second Moments
+	for index, value := range values {
+		set := &moments
+		if index >= 4 {
+			set = &second
+		}
+		set.AddValue(value)
+	}
+	moments.Add(second)
+
+	mean, _ := Mean(values)
+	variance, _ := Variance(values, nil)
+	skewness, _ := Skewness(values, nil)
+	assert.InDelta(t, mean, moments.Mean, 1e-9)
+	assert.InDelta(t, variance, moments.Variance(), 1e-6)
+	assert.InDelta(t, math.Sqrt(variance), moments.StandardDeviation(), 1e-9)
+	assert.InDelta(t, skewness, moments.Skewness(), 1e-9)
+}
+
+func TestMomentsLargeOffset(t *testing.T) {
+	// Grid voltage like values: large offset, small spread
+	values := make([]float64, 200000)
+	for index := range values {
+		values[index] = 230.1 + 0.002*float64(index%3-1)
+	}
+	values[0] = 230.1 + 0.01 // Skewed to the right
+
+	var moments, second Moments
+	for index, value := range values {
+		if index%2 == 0 {
+			moments.AddValue(value)
+		} else {
+			second.AddValue(value)
+		}
+	}
+	moments.Add(second)
+
+	// Sums of deviations from the mean of all values, computed with extended precision
+	const m2, m3 = 0.5334279995050935, 9.199858000548794e-07
+	variance, _ := Variance(values, nil)
+	assert.InEpsilon(t, variance, moments.Variance(), 1e-9)
+	assert.InEpsilon(t, m2, moments.M2, 1e-9)
+	assert.InEpsilon(t, m3, moments.M3, 1e-6)
+
+	// Deviations from an approximate mean, as summed by MongoDB
+	sum, sumSquares, sumCubes := 0.0, 0.0, 0.0
+	for _, value := range values {
+		deviation := value - 230.1
+		sum += deviation
+		sumSquares += deviation * deviation
+		sumCubes += deviation * deviation * deviation
+	}
+	centered := CenteredMoments(float64(len(values)), 230.1, sum, sumSquares, sumCubes)
+	assert.InEpsilon(t, m2, centered.M2, 1e-9)
+	assert.InEpsilon(t, m3, centered.M3, 1e-6)
+	assert.InEpsilon(t, moments.Skewness(), centered.Skewness(), 1e-6)
+}
+
+func TestCoMomentsLargeOffset(t *testing.T) {
+	// Large offsets and small spreads, products of the values cancel out
+	x, y := make([]float64, 100000), make([]float64, 100000)
+	for index := range x {
+		x[index] = 1e7 + 0.01*float64(index%7)
+		y[index] = 1e7 + 0.02*float64(index%7) + 0.01*float64(index%3-1)
+	}
+	correlation, _ := Correlation(x, y, nil)
+
+	var coMoments, second CoMoments
+	for index := range x {
+		if index%2 == 0 {
+			coMoments.AddPair(x[index], y[index])
+		} else {
+			second.AddPair(x[index], y[index])
+		}
+	}
+	coMoments.Add(second)
+	assert.Equal(t, float64(len(x)), coMoments.Count)
+	assert.InEpsilon(t, correlation, coMoments.Correlation(), 1e-9)
+
+	// Deviations from approximate means, as summed by MongoDB
+	var sumX, sumY, sumSquaresX, sumSquaresY, sumProducts float64
+	for index := range x {
+		deviationX, deviationY := x[index]-1e7, y[index]-1e7
+		sumX += deviationX
+		sumY += deviationY
+		sumSquaresX += deviationX * deviationX
+		sumSquaresY += deviationY * deviationY
+		sumProducts += deviationX * deviationY
+	}
+	centered := CenteredCoMoments(float64(len(x)), 1e7, 1e7, sumX, sumY, sumSquaresX, sumSquaresY, sumProducts)
+	assert.InEpsilon(t, correlation, centered.Correlation(), 1e-9)
+	assert.InDelta(t, coMoments.MeanX, centered.MeanX, 1e-6)
+	assert.True(t, math.IsNaN(CoMoments{}.Correlation()))
+}
+
+func TestWeightedQuantile(t *testing.T) {
+	// Means of three rollups of 1, 2 and 7 readings
+	q25, q75, _, _, _, _, _, _ := Quantile([]float64{30, 10, 20}, []float64{7, 1, 2})
+	assert.Equal(t, 20.0, q25)
+	assert.Equal(t, 30.0, q75)
+
+	median, _ := Median([]float64{30, 10, 20}, 0.5, []float64{7, 1, 2})
+	assert.Equal(t, 30.0, median)
+}
@@ -1,21 +1,19 @@
 package statistic
 
 import (
-	"sort"
-
 	"gonum.org/v1/gonum/stat"
 )
 
-func Quantile(data []float64) (q25 float64, q75 float64, iqr float64, lowerBound float64, upperBound float64, outliers []float64, q90 float64, q95 float64) {
+func Quantile(data []float64, weights []float64) (q25 float64, q75 float64, iqr float64, lowerBound float64, upperBound float64, outliers []float64, q90 float64, q95 float64) {
 
-	// Sort the data
-	sort.Float64s(data)
+	// Sort the data along with their weights
+	stat.SortWeighted(data, weights)
 
 	// Calculate the first and third quartiles
-	q25 = stat.Quantile(0.25, stat.Empirical, data, nil)
-	q75 = stat.Quantile(0.75, stat.Empirical, data, nil)
-	q90 = stat.Quantile(0.9, stat.Empirical, data, nil)
-	q95 = stat.Quantile(0.95, stat.Empirical, data, nil)
+	q25 = stat.Quantile(0.25, stat.Empirical, data, weights)
+	q75 = stat.Quantile(0.75, stat.Empirical, data, weights)
+	q90 = stat.Quantile(0.9, stat.Empirical, data, weights)
+	q95 = stat.Quantile(0.95, stat.Empirical, data, weights)
 
 	// Calculate the interquartile range (IQR)
 	iqr = q75 - q25